@@ -0,0 +1,53 @@
+package main
+
+import "github.com/cascades-fbp/cascades/library"
+
+var registryEntry = &library.Entry{
+	Description: `Merges pages of paginated API responses into a single JSON array. Pages of a collection arrive
+at IN as a bracketed substream of responses (or of bodies, i.e. from BODY port of http/client), a page outside of
+a substream is a collection of its own. Items are found by a dot separated path in page bodies. In array mode the
+merged response is based on the first page, in stream mode every item is emitted as a packet of a bracketed
+substream. Limits on the number and size of items truncate the collection and set X-Pagination-Truncated header.
+A page with error status or a malformed body fails the collection and is forwarded instead of the merged array.`,
+	Elementary: true,
+	Inports: []library.EntryPort{
+		library.EntryPort{
+			Name:        "OPTIONS",
+			Type:        "json",
+			Description: `Optional configuration port, i.e. {"input": "response", "mode": "array", "items": "data.items", "max_items": 10000, "max_bytes": 8388608}`,
+			Required:    false,
+		},
+		library.EntryPort{
+			Name:        "IN",
+			Type:        "json",
+			Description: "Input port for substreams of page responses in predefined JSON format or page bodies",
+			Required:    true,
+		},
+	},
+	Outports: []library.EntryPort{
+		library.EntryPort{
+			Name:        "OUT",
+			Type:        "json",
+			Description: "Output port for merged responses, arrays or substreams of items",
+			Required:    true,
+		},
+		library.EntryPort{
+			Name:        "ERR",
+			Type:        "json",
+			Description: "Optional error port for invalid IPs and failed collections (error JSON object defined in utils of HTTP components library)",
+			Required:    false,
+		},
+		library.EntryPort{
+			Name:        "LOG",
+			Type:        "json",
+			Description: "Optional output port for structured log entries (log entry JSON object defined in utils of HTTP components library)",
+			Required:    false,
+		},
+		library.EntryPort{
+			Name:        "HEARTBEAT",
+			Type:        "json",
+			Description: "Optional output port for periodic liveness IPs (heartbeat JSON object defined in utils of HTTP components library)",
+			Required:    false,
+		},
+	},
+}
@@ -0,0 +1,280 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+
+	httputils "github.com/cascades-fbp/cascades-http/utils"
+	"github.com/cascades-fbp/cascades/components/utils"
+	"github.com/cascades-fbp/cascades/runtime"
+	zmq "github.com/pebbe/zmq4"
+)
+
+var (
+	// Flags
+	optionsEndpoint   = flag.String("port.options", "", "Component's options port endpoint")
+	inputEndpoint     = flag.String("port.in", "", "Component's input port endpoint")
+	outputEndpoint    = flag.String("port.out", "", "Component's output port endpoint")
+	errorEndpoint     = flag.String("port.err", "", "Component's error port endpoint")
+	logEndpoint       = flag.String("port.log", "", "Component's log port endpoint")
+	heartbeatEndpoint = flag.String("port.heartbeat", "", "Component's heartbeat port endpoint")
+	metricsAddr       = flag.String("metrics", "", "Address to expose metrics on, i.e. 127.0.0.1:9090 (disabled if empty)")
+	jsonFlag          = flag.Bool("json", false, "Print component documentation in JSON")
+	debug             = flag.Bool("debug", false, "Enable debug mode")
+
+	// Internal
+	optionsPort, inPort, outPort, errPort, logPort, heartbeatPort *zmq.Socket
+	err                                                           error
+	logger                                                        = httputils.NewLogger("http/aggregator")
+	liveness                                                      = httputils.NewLiveness("http/aggregator")
+	metrics                                                       = httputils.NewMetrics(logger, liveness)
+	shutdown                                                      *httputils.Shutdown
+)
+
+// validateArgs checks all required flags
+func validateArgs() {
+	if *inputEndpoint == "" {
+		flag.Usage()
+		os.Exit(1)
+	}
+	if *outputEndpoint == "" {
+		flag.Usage()
+		os.Exit(1)
+	}
+}
+
+// openPorts create ZMQ sockets
+func openPorts() {
+	if *optionsEndpoint != "" {
+		optionsPort, err = utils.CreateInputPort("http/aggregator.options", *optionsEndpoint, nil)
+		utils.AssertError(err)
+	}
+
+	inPort, err = utils.CreateInputPort("http/aggregator.in", *inputEndpoint, nil)
+	utils.AssertError(err)
+
+	outPort, err = utils.CreateOutputPort("http/aggregator.out", *outputEndpoint, nil)
+	utils.AssertError(err)
+
+	if *errorEndpoint != "" {
+		errPort, err = utils.CreateOutputPort("http/aggregator.err", *errorEndpoint, nil)
+		utils.AssertError(err)
+	}
+
+	if *logEndpoint != "" {
+		logPort, err = utils.CreateOutputPort("http/aggregator.log", *logEndpoint, nil)
+		utils.AssertError(err)
+		logger.SetSink(func(ip [][]byte) { logPort.SendMessageDontwait(ip) })
+	}
+
+	if *heartbeatEndpoint != "" {
+		heartbeatPort, err = utils.CreateOutputPort("http/aggregator.heartbeat", *heartbeatEndpoint, nil)
+		utils.AssertError(err)
+		liveness.Start(func(ip [][]byte) { heartbeatPort.SendMessageDontwait(ip) })
+	}
+}
+
+// closePorts closes all active ports and terminates ZMQ context
+func closePorts() {
+	shutdown.Close(optionsPort, inPort)
+	liveness.Stop()
+	shutdown.Flush(outPort, errPort, heartbeatPort, logPort)
+	zmq.Term()
+}
+
+func main() {
+	flag.Parse()
+
+	if *jsonFlag {
+		doc, _ := registryEntry.JSON()
+		fmt.Println(string(doc))
+		os.Exit(0)
+	}
+
+	log.SetFlags(0)
+	if *debug {
+		logger.SetLevel(httputils.LevelDebug)
+		logger.SetOutput(os.Stdout)
+	}
+	log.SetOutput(logger.Writer())
+
+	validateArgs()
+
+	shutdown = httputils.NewShutdown(logger)
+	openPorts()
+
+	if *metricsAddr != "" {
+		err = metrics.Serve(*metricsAddr)
+		utils.AssertError(err)
+	}
+
+	err = runtime.SetupShutdownByDisconnect(inPort, "http/aggregator.in", shutdown.Signals())
+	utils.AssertError(err)
+
+	// Wait for the configuration on the options port
+	options := &Options{Input: InputResponse, Mode: ModeArray}
+	for optionsPort != nil {
+		log.Println("Waiting for configuration...")
+		ip, err := shutdown.Receive(optionsPort)
+		if err == httputils.ErrShutdown {
+			shutdown.Exit(closePorts)
+		}
+		if err != nil {
+			logger.Error("Error receiving IP", "error", err)
+			continue
+		}
+		if !runtime.IsValidIP(ip) || !runtime.IsPacket(ip) {
+			continue
+		}
+		opts := &Options{Input: InputResponse, Mode: ModeArray}
+		if err = json.Unmarshal(ip[1], opts); err != nil {
+			logger.Error("Failed to unmarshal options", "error", err)
+			continue
+		}
+		if opts.Input != InputResponse && opts.Input != InputBody {
+			logger.Error("Invalid options", "error", fmt.Sprintf("unsupported input %q", opts.Input))
+			continue
+		}
+		if opts.Mode != ModeArray && opts.Mode != ModeStream {
+			logger.Error("Invalid options", "error", fmt.Sprintf("unsupported mode %q", opts.Mode))
+			continue
+		}
+		options = opts
+		optionsPort.Close()
+		optionsPort = nil
+	}
+
+	agg := NewAggregator(options)
+
+	// Depth of nested brackets, only the outermost substream is a collection
+	depth := 0
+
+	// Process incoming messages until shutdown
+	for {
+		ip, err := shutdown.Receive(inPort)
+		if err == httputils.ErrShutdown {
+			break
+		}
+		if err != nil {
+			logger.Error("Error receiving message", "error", err)
+			continue
+		}
+		liveness.Inc()
+		if !httputils.IsValidIP(ip) {
+			logger.Warn("Received invalid IP")
+			continue
+		}
+
+		switch {
+		case runtime.IsOpenBracket(ip):
+			depth++
+			if depth == 1 {
+				begin(agg, options)
+			}
+		case runtime.IsCloseBracket(ip):
+			if depth == 0 {
+				logger.Warn("Received unbalanced close bracket")
+				continue
+			}
+			depth--
+			if depth == 0 {
+				finish(agg, options)
+			}
+		case depth == 0:
+			// A page outside of a substream is a collection of its own
+			begin(agg, options)
+			add(agg, options, ip)
+			finish(agg, options)
+		default:
+			add(agg, options, ip)
+		}
+	}
+	shutdown.Exit(closePorts)
+}
+
+// begin starts a new collection
+func begin(agg *Aggregator, options *Options) {
+	agg.Reset()
+	if options.Mode == ModeStream {
+		outPort.SendMessage(runtime.NewOpenBracket())
+	}
+}
+
+// add merges a page into the current collection, in stream mode its items are
+// emitted right away
+func add(agg *Aggregator, options *Options, ip [][]byte) {
+	var (
+		items []json.RawMessage
+		id    string
+		err   error
+	)
+	if options.Input == InputBody {
+		items, err = agg.AddBody(ip[1])
+	} else {
+		var resp *httputils.HTTPResponse
+		if resp, err = httputils.IP2Response(ip); err != nil {
+			logger.Warn("Failed to convert IP to response", "error", err)
+			sendError(httputils.NewError("http/aggregator", httputils.ErrInvalidIP, err))
+			return
+		}
+		id = resp.ID
+		items, err = agg.AddResponse(resp)
+	}
+	if err != nil {
+		logger.Warn("Failed to merge page, the rest of the collection is ignored", "id", id, "error", err)
+		sendError(httputils.NewError("http/aggregator", httputils.ErrUpstream, err).WithRequest(id))
+		return
+	}
+	if options.Mode == ModeStream {
+		for _, item := range items {
+			outPort.SendMessage(runtime.NewPacket(item))
+		}
+	}
+}
+
+// finish emits the result of the current collection. Failed collections of
+// responses are answered with the failed page, those of bodies aren't emitted.
+func finish(agg *Aggregator, options *Options) {
+	if limit := agg.Truncated(); limit != "" {
+		logger.Warn("Collection was truncated", "limit", limit, "pages", agg.Pages(), "items", agg.Count())
+	}
+	logger.Debug("Merged collection", "pages", agg.Pages(), "items", agg.Count())
+
+	if options.Mode == ModeStream {
+		outPort.SendMessage(runtime.NewCloseBracket())
+		return
+	}
+	if options.Input == InputBody {
+		if agg.Err() == nil {
+			outPort.SendMessage(runtime.NewPacket(agg.Body()))
+		}
+		return
+	}
+	resp := agg.Response()
+	if resp == nil {
+		logger.Warn("Received empty collection")
+		return
+	}
+	ip, err := httputils.Response2IP(resp)
+	if err != nil {
+		logger.Error("Failed to convert response to IP", "error", err)
+		sendError(httputils.NewError("http/aggregator", httputils.ErrInternal, err).WithRequest(resp.ID))
+		return
+	}
+	outPort.SendMessage(ip)
+}
+
+// sendError reports a failure to the ERR port if it's connected
+func sendError(e *httputils.Error) {
+	if errPort == nil {
+		return
+	}
+	ip, err := httputils.Error2IP(e)
+	if err != nil {
+		return
+	}
+	errPort.SendMessageDontwait(ip)
+}
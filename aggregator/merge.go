@@ -0,0 +1,197 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	httputils "github.com/cascades-fbp/cascades-http/utils"
+)
+
+// Options describe the configuration IP of the component
+type Options struct {
+	Input    string `json:"input"`     // Pages are responses (default) or raw bodies, i.e. from BODY port of http/client
+	Mode     string `json:"mode"`      // Output a merged array (default) or a stream of items
+	Items    string `json:"items"`     // Dot separated path to the items array in page bodies, the body itself if empty
+	MaxItems int    `json:"max_items"` // Items collected at most, the rest is dropped (unlimited if 0)
+	MaxBytes int    `json:"max_bytes"` // Size of collected items at most, the rest is dropped (unlimited if 0)
+}
+
+// Input kinds and output modes
+const (
+	InputResponse = "response"
+	InputBody     = "body"
+	ModeArray     = "array"
+	ModeStream    = "stream"
+)
+
+// TruncatedHeader is set on merged responses if a limit was reached, value is
+// "items" or "memory"
+const TruncatedHeader = "X-Pagination-Truncated"
+
+// Aggregator merges pages of a single collection, it isn't safe for concurrent use
+type Aggregator struct {
+	options   *Options
+	first     *httputils.HTTPResponse
+	failed    *httputils.HTTPResponse
+	items     []json.RawMessage
+	count     int
+	size      int
+	pages     int
+	truncated string
+	err       error
+}
+
+// NewAggregator creates an aggregator, options are expected to have defaults applied
+func NewAggregator(options *Options) *Aggregator {
+	return &Aggregator{options: options}
+}
+
+// Reset prepares the aggregator for the next collection
+func (a *Aggregator) Reset() {
+	*a = Aggregator{options: a.options}
+}
+
+// AddResponse adds a page response. Pages with error status fail the collection,
+// the response is kept to be forwarded instead of the merged result.
+func (a *Aggregator) AddResponse(resp *httputils.HTTPResponse) ([]json.RawMessage, error) {
+	if a.err != nil {
+		return nil, nil
+	}
+	if a.first == nil {
+		a.first = resp
+	}
+	if resp.StatusCode >= 400 {
+		a.failed = resp
+		a.err = fmt.Errorf("page %d failed with status %d", a.pages+1, resp.StatusCode)
+		return nil, a.err
+	}
+	if err := resp.Materialize(); err != nil {
+		a.failed = resp
+		a.err = err
+		return nil, err
+	}
+	items, err := a.AddBody(resp.Body)
+	if err != nil {
+		a.failed = resp
+	}
+	return items, err
+}
+
+// AddBody adds a page body returning items accepted within limits. Once a page
+// fails the rest of the collection is ignored.
+func (a *Aggregator) AddBody(body []byte) ([]json.RawMessage, error) {
+	if a.err != nil {
+		return nil, nil
+	}
+	a.pages++
+	items, err := extractItems(body, a.options.Items)
+	if err != nil {
+		a.err = fmt.Errorf("page %d: %w", a.pages, err)
+		return nil, a.err
+	}
+	var accepted []json.RawMessage
+	for _, item := range items {
+		if a.truncated != "" {
+			break
+		}
+		if a.options.MaxItems > 0 && a.count >= a.options.MaxItems {
+			a.truncated = "items"
+			break
+		}
+		if a.options.MaxBytes > 0 && a.size+len(item) > a.options.MaxBytes {
+			a.truncated = "memory"
+			break
+		}
+		a.count++
+		a.size += len(item)
+		accepted = append(accepted, item)
+	}
+	if a.options.Mode == ModeArray {
+		a.items = append(a.items, accepted...)
+	}
+	return accepted, nil
+}
+
+// Pages returns the number of pages added
+func (a *Aggregator) Pages() int {
+	return a.pages
+}
+
+// Count returns the number of items accepted
+func (a *Aggregator) Count() int {
+	return a.count
+}
+
+// Truncated returns the limit which was reached, empty string if none
+func (a *Aggregator) Truncated() string {
+	return a.truncated
+}
+
+// Err returns the error which failed the collection
+func (a *Aggregator) Err() error {
+	return a.err
+}
+
+// Body returns collected items as a JSON array
+func (a *Aggregator) Body() []byte {
+	if len(a.items) == 0 {
+		return []byte("[]")
+	}
+	size := a.size + len(a.items) + 1
+	body := make([]byte, 0, size)
+	body = append(body, '[')
+	for i, item := range a.items {
+		if i > 0 {
+			body = append(body, ',')
+		}
+		body = append(body, item...)
+	}
+	return append(body, ']')
+}
+
+// Response returns the merged response based on the first page, nil if no pages were added
+func (a *Aggregator) Response() *httputils.HTTPResponse {
+	if a.failed != nil {
+		return a.failed
+	}
+	if a.first == nil {
+		return nil
+	}
+	resp := a.first
+	// Headers describing a single page don't apply anymore
+	for _, h := range []string{"Link", "ETag", "Last-Modified", "Content-Range", "Content-Encoding"} {
+		resp.DelHeader(h)
+	}
+	resp.WithBody("application/json; charset=utf-8", a.Body())
+	if a.truncated != "" {
+		resp.SetHeader(TruncatedHeader, a.truncated)
+	}
+	return resp
+}
+
+// extractItems returns items of a page body found by a dot separated path
+func extractItems(body []byte, path string) ([]json.RawMessage, error) {
+	raw := json.RawMessage(body)
+	if path != "" {
+		for _, key := range strings.Split(path, ".") {
+			var obj map[string]json.RawMessage
+			if err := json.Unmarshal(raw, &obj); err != nil {
+				return nil, fmt.Errorf("decoding %s: %w", key, err)
+			}
+			value, ok := obj[key]
+			if !ok {
+				return nil, fmt.Errorf("%s is missing", key)
+			}
+			raw = value
+		}
+	}
+	if string(raw) == "null" {
+		return nil, nil
+	}
+	var items []json.RawMessage
+	if err := json.Unmarshal(raw, &items); err != nil {
+		return nil, fmt.Errorf("decoding items: %w", err)
+	}
+	return items, nil
+}
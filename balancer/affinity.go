@@ -0,0 +1,82 @@
+package main
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// AffinityTable maps session keys to output indexes
+type AffinityTable struct {
+	ttl     time.Duration
+	entries map[string]*affinity
+	dirty   bool
+}
+
+type affinity struct {
+	Index    int       `json:"index"`
+	LastSeen time.Time `json:"last_seen"`
+}
+
+// NewAffinityTable creates an empty table. Entries not seen for ttl are expired.
+func NewAffinityTable(ttl time.Duration) *AffinityTable {
+	return &AffinityTable{
+		ttl:     ttl,
+		entries: make(map[string]*affinity),
+	}
+}
+
+// Get returns output index for a given key
+func (t *AffinityTable) Get(key string, now time.Time) (int, bool) {
+	a, ok := t.entries[key]
+	if !ok {
+		return 0, false
+	}
+	if t.ttl > 0 && now.Sub(a.LastSeen) > t.ttl {
+		delete(t.entries, key)
+		t.dirty = true
+		return 0, false
+	}
+	a.LastSeen = now
+	return a.Index, true
+}
+
+// Set assigns output index to a given key
+func (t *AffinityTable) Set(key string, index int, now time.Time) {
+	t.entries[key] = &affinity{Index: index, LastSeen: now}
+	t.dirty = true
+}
+
+// Forget removes all keys assigned to a given output index
+func (t *AffinityTable) Forget(index int) {
+	for k, a := range t.entries {
+		if a.Index == index {
+			delete(t.entries, k)
+			t.dirty = true
+		}
+	}
+}
+
+// Export returns JSON snapshot of the table if it changed since the last export
+func (t *AffinityTable) Export() ([]byte, bool) {
+	if !t.dirty {
+		return nil, false
+	}
+	data, err := json.Marshal(t.entries)
+	if err != nil {
+		return nil, false
+	}
+	t.dirty = false
+	return data, true
+}
+
+// Import merges previously exported snapshot into the table
+func (t *AffinityTable) Import(data []byte) error {
+	var entries map[string]*affinity
+	if err := json.Unmarshal(data, &entries); err != nil {
+		return err
+	}
+	for k, a := range entries {
+		t.entries[k] = a
+	}
+	return nil
+}
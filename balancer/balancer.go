@@ -0,0 +1,185 @@
+package main
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+
+	httputils "github.com/cascades-fbp/cascades-http/utils"
+)
+
+// Balancing modes
+const (
+	RoundRobin = "roundrobin"
+	Sticky     = "sticky"
+	Hash       = "hash"
+)
+
+// Keys for hash mode
+const (
+	HashByPath   = "path"
+	HashByHeader = "header"
+	HashByIP     = "ip"
+)
+
+// Options describe the configuration IP of the component
+type Options struct {
+	Mode     string `json:"mode"`     // roundrobin (default), sticky or hash
+	Cookie   string `json:"cookie"`   // Cookie identifying a session (sticky mode)
+	Header   string `json:"header"`   // Header identifying a session (sticky mode) or hash key (hash mode)
+	TTL      string `json:"ttl"`      // Affinity expiration interval (sticky mode)
+	HashBy   string `json:"hash_by"`  // Hash key: path (default), header or ip (hash mode)
+	Replicas int    `json:"replicas"` // Virtual nodes per output on the hash ring (hash mode)
+}
+
+// Balancer picks an output index for every request
+type Balancer struct {
+	options  *Options
+	active   []int
+	next     int
+	affinity *AffinityTable
+	ring     *HashRing
+}
+
+// NewBalancer creates a balancer for a given number of outputs
+func NewBalancer(options *Options, outputs int) (*Balancer, error) {
+	b := &Balancer{options: options}
+	if options.Mode == "" {
+		options.Mode = RoundRobin
+	}
+	switch options.Mode {
+	case RoundRobin:
+	case Sticky:
+		if options.Cookie == "" && options.Header == "" {
+			return nil, fmt.Errorf("sticky mode requires cookie or header")
+		}
+		var ttl time.Duration
+		if options.TTL != "" {
+			d, err := time.ParseDuration(options.TTL)
+			if err != nil {
+				return nil, err
+			}
+			ttl = d
+		}
+		b.affinity = NewAffinityTable(ttl)
+	case Hash:
+		switch options.HashBy {
+		case "":
+			options.HashBy = HashByPath
+		case HashByPath, HashByIP:
+		case HashByHeader:
+			if options.Header == "" {
+				return nil, fmt.Errorf("hashing by header requires header name")
+			}
+		default:
+			return nil, fmt.Errorf("unsupported hash key %s", options.HashBy)
+		}
+		b.ring = NewHashRing(options.Replicas)
+	default:
+		return nil, fmt.Errorf("unsupported balancing mode %s", options.Mode)
+	}
+
+	indexes := make([]int, outputs)
+	for i := range indexes {
+		indexes[i] = i
+	}
+	b.SetActive(indexes)
+	return b, nil
+}
+
+// SetActive updates the set of output indexes requests can be sent to
+func (b *Balancer) SetActive(indexes []int) {
+	sort.Ints(indexes)
+	if b.affinity != nil {
+		alive := make(map[int]bool, len(indexes))
+		for _, i := range indexes {
+			alive[i] = true
+		}
+		for _, i := range b.active {
+			if !alive[i] {
+				b.affinity.Forget(i)
+			}
+		}
+	}
+	b.active = indexes
+	b.next = 0
+	if b.ring != nil {
+		b.ring.Build(indexes)
+	}
+}
+
+// Pick returns output index for a given request or -1 if no outputs are active
+func (b *Balancer) Pick(req *httputils.HTTPRequest) int {
+	if len(b.active) == 0 {
+		return -1
+	}
+	switch b.options.Mode {
+	case Sticky:
+		return b.pickSticky(req)
+	case Hash:
+		return b.ring.Get(b.hashKey(req))
+	}
+	return b.pickRoundRobin()
+}
+
+func (b *Balancer) pickRoundRobin() int {
+	index := b.active[b.next%len(b.active)]
+	b.next = (b.next + 1) % len(b.active)
+	return index
+}
+
+func (b *Balancer) pickSticky(req *httputils.HTTPRequest) int {
+	key := b.sessionKey(req)
+	if key == "" {
+		return b.pickRoundRobin()
+	}
+	now := time.Now()
+	if index, ok := b.affinity.Get(key, now); ok {
+		return index
+	}
+	index := b.pickRoundRobin()
+	b.affinity.Set(key, index, now)
+	return index
+}
+
+// sessionKey resolves the value identifying client session
+func (b *Balancer) sessionKey(req *httputils.HTTPRequest) string {
+	r := &http.Request{Header: http.Header(req.Header)}
+	if b.options.Cookie != "" {
+		if c, err := r.Cookie(b.options.Cookie); err == nil && c.Value != "" {
+			return c.Value
+		}
+	}
+	if b.options.Header != "" {
+		return r.Header.Get(b.options.Header)
+	}
+	return ""
+}
+
+// hashKey resolves the value requests are distributed by in hash mode
+func (b *Balancer) hashKey(req *httputils.HTTPRequest) string {
+	h := http.Header(req.Header)
+	switch b.options.HashBy {
+	case HashByHeader:
+		return h.Get(b.options.Header)
+	case HashByIP:
+		if forwarded := h.Get("X-Forwarded-For"); forwarded != "" {
+			return strings.TrimSpace(strings.Split(forwarded, ",")[0])
+		}
+		if real := h.Get("X-Real-Ip"); real != "" {
+			return real
+		}
+		if host, _, err := net.SplitHostPort(req.RemoteAddr); err == nil {
+			return host
+		}
+		return req.RemoteAddr
+	}
+	if u, err := url.ParseRequestURI(req.URI); err == nil {
+		return u.Path
+	}
+	return req.URI
+}
@@ -0,0 +1,64 @@
+package main
+
+import "github.com/cascades-fbp/cascades/library"
+
+var registryEntry = &library.Entry{
+	Description: `Distributes incoming JSON requests across OUT[index] output ports. Default mode is round-robin.
+In sticky mode requests carrying the same cookie or header value keep going to the same output port.
+In hash mode requests are placed on a consistent hash ring by path, header or client IP, so that
+only a small share of keys moves when outputs are activated or deactivated. The affinity table can be exported on TABLE port and imported via AFFINITY port to survive restarts.`,
+	Elementary: true,
+	Inports: []library.EntryPort{
+		library.EntryPort{
+			Name:        "OPTIONS",
+			Type:        "json",
+			Description: `Optional configuration port, i.e. {"mode": "sticky", "cookie": "session", "ttl": "30m"} or {"mode": "hash", "hash_by": "path|header|ip"}`,
+			Required:    false,
+		},
+		library.EntryPort{
+			Name:        "BACKENDS",
+			Type:        "json",
+			Description: "Optional port with JSON array of active output indexes, i.e. [0, 2]",
+			Required:    false,
+		},
+		library.EntryPort{
+			Name:        "AFFINITY",
+			Type:        "json",
+			Description: "Optional port for importing a previously exported affinity table",
+			Required:    false,
+		},
+		library.EntryPort{
+			Name:        "IN",
+			Type:        "json",
+			Description: "Input port for requests in predefined JSON format",
+			Required:    true,
+		},
+	},
+	Outports: []library.EntryPort{
+		library.EntryPort{
+			Name:        "OUT",
+			Type:        "json",
+			Description: "Output array port for balanced requests",
+			Required:    true,
+			Addressable: true,
+		},
+		library.EntryPort{
+			Name:        "TABLE",
+			Type:        "json",
+			Description: "Optional port for exporting the affinity table when it changes",
+			Required:    false,
+		},
+		library.EntryPort{
+			Name:        "LOG",
+			Type:        "json",
+			Description: "Optional output port for structured log entries (log entry JSON object defined in utils of HTTP components library)",
+			Required:    false,
+		},
+		library.EntryPort{
+			Name:        "HEARTBEAT",
+			Type:        "json",
+			Description: "Optional output port for periodic liveness IPs (heartbeat JSON object defined in utils of HTTP components library)",
+			Required:    false,
+		},
+	},
+}
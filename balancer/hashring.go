@@ -0,0 +1,52 @@
+package main
+
+import (
+	"hash/crc32"
+	"sort"
+	"strconv"
+)
+
+// HashRing is a consistent hash ring of output indexes
+type HashRing struct {
+	replicas int
+	hashes   []uint32
+	owners   map[uint32]int
+}
+
+// NewHashRing creates a ring with a given number of virtual nodes per output
+func NewHashRing(replicas int) *HashRing {
+	if replicas <= 0 {
+		replicas = 100
+	}
+	return &HashRing{replicas: replicas}
+}
+
+// Build places given output indexes on the ring
+func (r *HashRing) Build(indexes []int) {
+	r.hashes = make([]uint32, 0, len(indexes)*r.replicas)
+	r.owners = make(map[uint32]int, len(indexes)*r.replicas)
+	for _, index := range indexes {
+		for i := 0; i < r.replicas; i++ {
+			h := crc32.ChecksumIEEE([]byte(strconv.Itoa(index) + "#" + strconv.Itoa(i)))
+			if _, ok := r.owners[h]; ok {
+				continue
+			}
+			r.owners[h] = index
+			r.hashes = append(r.hashes, h)
+		}
+	}
+	sort.Slice(r.hashes, func(i, j int) bool { return r.hashes[i] < r.hashes[j] })
+}
+
+// Get returns output index owning a given key or -1 if the ring is empty
+func (r *HashRing) Get(key string) int {
+	if len(r.hashes) == 0 {
+		return -1
+	}
+	h := crc32.ChecksumIEEE([]byte(key))
+	i := sort.Search(len(r.hashes), func(i int) bool { return r.hashes[i] >= h })
+	if i == len(r.hashes) {
+		i = 0
+	}
+	return r.owners[r.hashes[i]]
+}
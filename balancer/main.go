@@ -0,0 +1,238 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+	"time"
+
+	httputils "github.com/cascades-fbp/cascades-http/utils"
+	"github.com/cascades-fbp/cascades/components/utils"
+	"github.com/cascades-fbp/cascades/runtime"
+	zmq "github.com/pebbe/zmq4"
+)
+
+var (
+	// Flags
+	optionsEndpoint   = flag.String("port.options", "", "Component's options port endpoint")
+	backendsEndpoint  = flag.String("port.backends", "", "Component's active backends port endpoint")
+	affinityEndpoint  = flag.String("port.affinity", "", "Component's affinity import port endpoint")
+	inputEndpoint     = flag.String("port.in", "", "Component's input port endpoint")
+	outputEndpoint    = flag.String("port.out", "", "Component's output array port endpoints")
+	tableEndpoint     = flag.String("port.table", "", "Component's affinity export port endpoint")
+	logEndpoint       = flag.String("port.log", "", "Component's log port endpoint")
+	heartbeatEndpoint = flag.String("port.heartbeat", "", "Component's heartbeat port endpoint")
+	metricsAddr       = flag.String("metrics", "", "Address to expose metrics on, i.e. 127.0.0.1:9090 (disabled if empty)")
+	jsonFlag          = flag.Bool("json", false, "Print component documentation in JSON")
+	debug             = flag.Bool("debug", false, "Enable debug mode")
+
+	// Internal
+	optionsPort, backendsPort, affinityPort, inPort, tablePort, logPort, heartbeatPort *zmq.Socket
+	outPorts                                                                           []*zmq.Socket
+	err                                                                                error
+	logger                                                                             = httputils.NewLogger("http/balancer")
+	liveness                                                                           = httputils.NewLiveness("http/balancer")
+	metrics                                                                            = httputils.NewMetrics(logger, liveness)
+	shutdown                                                                           *httputils.Shutdown
+)
+
+// validateArgs checks all required flags
+func validateArgs() {
+	if *inputEndpoint == "" {
+		flag.Usage()
+		os.Exit(1)
+	}
+	if *outputEndpoint == "" {
+		flag.Usage()
+		os.Exit(1)
+	}
+}
+
+// openPorts create ZMQ sockets
+func openPorts() {
+	if *optionsEndpoint != "" {
+		optionsPort, err = utils.CreateInputPort("http/balancer.options", *optionsEndpoint, nil)
+		utils.AssertError(err)
+	}
+	if *backendsEndpoint != "" {
+		backendsPort, err = utils.CreateInputPort("http/balancer.backends", *backendsEndpoint, nil)
+		utils.AssertError(err)
+	}
+	if *affinityEndpoint != "" {
+		affinityPort, err = utils.CreateInputPort("http/balancer.affinity", *affinityEndpoint, nil)
+		utils.AssertError(err)
+	}
+
+	inPort, err = utils.CreateInputPort("http/balancer.in", *inputEndpoint, nil)
+	utils.AssertError(err)
+
+	var port *zmq.Socket
+	for i, endpoint := range strings.Split(*outputEndpoint, ",") {
+		port, err = utils.CreateOutputPort(fmt.Sprintf("http/balancer.out[%v]", i), strings.TrimSpace(endpoint), nil)
+		utils.AssertError(err)
+		outPorts = append(outPorts, port)
+	}
+
+	if *tableEndpoint != "" {
+		tablePort, err = utils.CreateOutputPort("http/balancer.table", *tableEndpoint, nil)
+		utils.AssertError(err)
+	}
+
+	if *logEndpoint != "" {
+		logPort, err = utils.CreateOutputPort("http/balancer.log", *logEndpoint, nil)
+		utils.AssertError(err)
+		logger.SetSink(func(ip [][]byte) { logPort.SendMessageDontwait(ip) })
+	}
+
+	if *heartbeatEndpoint != "" {
+		heartbeatPort, err = utils.CreateOutputPort("http/balancer.heartbeat", *heartbeatEndpoint, nil)
+		utils.AssertError(err)
+		liveness.Start(func(ip [][]byte) { heartbeatPort.SendMessageDontwait(ip) })
+	}
+}
+
+// closePorts closes all active ports and terminates ZMQ context
+func closePorts() {
+	shutdown.Close(optionsPort, backendsPort, affinityPort, inPort)
+	liveness.Stop()
+	shutdown.Flush(append(outPorts, tablePort, heartbeatPort, logPort)...)
+	zmq.Term()
+}
+
+func main() {
+	flag.Parse()
+
+	if *jsonFlag {
+		doc, _ := registryEntry.JSON()
+		fmt.Println(string(doc))
+		os.Exit(0)
+	}
+
+	log.SetFlags(0)
+	if *debug {
+		logger.SetLevel(httputils.LevelDebug)
+		logger.SetOutput(os.Stdout)
+	}
+	log.SetOutput(logger.Writer())
+
+	validateArgs()
+
+	shutdown = httputils.NewShutdown(logger)
+	openPorts()
+
+	if *metricsAddr != "" {
+		err = metrics.Serve(*metricsAddr)
+		utils.AssertError(err)
+	}
+
+	err = runtime.SetupShutdownByDisconnect(inPort, "http/balancer.in", shutdown.Signals())
+	utils.AssertError(err)
+
+	// Wait for the configuration on the options port
+	options := &Options{}
+	for optionsPort != nil {
+		log.Println("Waiting for configuration...")
+		ip, err := shutdown.Receive(optionsPort)
+		if err == httputils.ErrShutdown {
+			shutdown.Exit(closePorts)
+		}
+		if err != nil {
+			logger.Error("Error receiving IP", "error", err)
+			continue
+		}
+		if !runtime.IsValidIP(ip) || !runtime.IsPacket(ip) {
+			continue
+		}
+		if err = json.Unmarshal(ip[1], options); err != nil {
+			logger.Error("Failed to unmarshal options", "error", err)
+			continue
+		}
+		optionsPort.Close()
+		optionsPort = nil
+	}
+
+	balancer, err := NewBalancer(options, len(outPorts))
+	utils.AssertError(err)
+
+	poller := zmq.NewPoller()
+	poller.Add(inPort, zmq.POLLIN)
+	if backendsPort != nil {
+		poller.Add(backendsPort, zmq.POLLIN)
+	}
+	if affinityPort != nil {
+		poller.Add(affinityPort, zmq.POLLIN)
+	}
+
+	lastExport := time.Now()
+
+	// Main loop
+	for !shutdown.Stopping() {
+		sockets, err := poller.Poll(time.Second)
+		if err != nil {
+			logger.Error("Error polling ports", "error", err)
+			continue
+		}
+
+		for _, socket := range sockets {
+			ip, err := socket.Socket.RecvMessageBytes(0)
+			if err != nil {
+				logger.Error("Error receiving message", "error", err)
+				continue
+			}
+			liveness.Inc()
+			if !httputils.IsValidIP(ip) || !runtime.IsPacket(ip) {
+				continue
+			}
+
+			switch socket.Socket {
+			case backendsPort:
+				var indexes []int
+				if err = json.Unmarshal(ip[1], &indexes); err != nil {
+					logger.Error("Failed to unmarshal backends", "error", err)
+					continue
+				}
+				valid := indexes[:0]
+				for _, i := range indexes {
+					if i >= 0 && i < len(outPorts) {
+						valid = append(valid, i)
+					}
+				}
+				balancer.SetActive(valid)
+				logger.Info("Active outputs changed", "outputs", valid)
+
+			case affinityPort:
+				if balancer.affinity == nil {
+					continue
+				}
+				if err = balancer.affinity.Import(ip[1]); err != nil {
+					logger.Warn("Failed to import affinity table", "error", err)
+				}
+
+			case inPort:
+				req, err := httputils.IP2Request(ip)
+				if err != nil {
+					logger.Warn("Failed to convert IP to request", "error", err)
+					continue
+				}
+				index := balancer.Pick(req)
+				if index < 0 {
+					logger.Warn("No active outputs, dropping request", "id", req.ID)
+					continue
+				}
+				outPorts[index].SendMessage(ip)
+			}
+		}
+
+		// Export affinity table at most once a second
+		if tablePort != nil && balancer.affinity != nil && time.Since(lastExport) >= time.Second {
+			if data, ok := balancer.affinity.Export(); ok {
+				tablePort.SendMessageDontwait(runtime.NewPacket(data))
+			}
+			lastExport = time.Now()
+		}
+	}
+	shutdown.Exit(closePorts)
+}
@@ -0,0 +1,186 @@
+package main
+
+import (
+	"strconv"
+	"strings"
+	"time"
+
+	httputils "github.com/cascades-fbp/cascades-http/utils"
+)
+
+// Options describe the configuration IP of the component
+type Options struct {
+	Threshold      int                `json:"threshold"`       // Score from which clients are classified as bots
+	RateLimit      int                `json:"rate_limit"`      // Requests of a client per window considered automated (disabled if zero)
+	RateWindow     httputils.Duration `json:"rate_window"`     // Window requests are counted in
+	Crawlers       []string           `json:"crawlers"`        // Extra User-Agent tokens of crawlers
+	Tools          []string           `json:"tools"`           // Extra User-Agent tokens of automation tools
+	AllowCrawlers  bool               `json:"allow_crawlers"`  // Don't treat known crawlers as bots
+	Header         string             `json:"header"`          // Also set the score in this request header, i.e. X-Bot-Score
+	TrustForwarded bool               `json:"trust_forwarded"` // Count requests by X-Forwarded-For address
+}
+
+// Classes of clients
+const (
+	ClassHuman      = "human"
+	ClassCrawler    = "crawler"
+	ClassAutomation = "automation"
+	ClassBot        = "bot"
+)
+
+// crawlers are tokens of well known crawler User-Agents
+var crawlers = []string{
+	"Googlebot", "bingbot", "DuckDuckBot", "Baiduspider", "YandexBot", "Applebot", "Yahoo! Slurp",
+	"facebookexternalhit", "Twitterbot", "LinkedInBot", "Slackbot", "Discordbot", "TelegramBot",
+	"AhrefsBot", "SemrushBot", "MJ12bot", "DotBot", "PetalBot", "Bytespider", "GPTBot", "CCBot", "Amazonbot",
+}
+
+// tools are tokens of HTTP libraries, command line clients and headless browsers
+var tools = []string{
+	"curl", "Wget", "python-requests", "python-urllib", "python-httpx", "aiohttp", "Go-http-client",
+	"Java/", "okhttp", "Apache-HttpClient", "libwww-perl", "Scrapy", "node-fetch", "axios", "HTTPie",
+	"PostmanRuntime", "HeadlessChrome", "PhantomJS",
+}
+
+// botTokens are generic parts of self-declared bot User-Agents
+var botTokens = []string{"bot", "crawl", "spider", "scrape"}
+
+// Classifier scores requests by User-Agent, header heuristics and request
+// rate of clients, it isn't safe for concurrent use
+type Classifier struct {
+	options  *Options
+	crawlers []string
+	tools    []string
+	windows  map[string]*rateWindow
+	cleaned  time.Time
+}
+
+// rateWindow counts requests of a client since start
+type rateWindow struct {
+	start time.Time
+	count int
+}
+
+// NewClassifier creates a classifier, options are expected to have defaults applied
+func NewClassifier(options *Options) *Classifier {
+	return &Classifier{
+		options:  options,
+		crawlers: append(append([]string{}, crawlers...), options.Crawlers...),
+		tools:    append(append([]string{}, tools...), options.Tools...),
+		windows:  make(map[string]*rateWindow),
+	}
+}
+
+// Classify scores a request
+func (c *Classifier) Classify(req *httputils.HTTPRequest, now time.Time) *httputils.BotInfo {
+	info := &httputils.BotInfo{Class: ClassHuman}
+	add := func(score int, reason string) {
+		info.Score += score
+		info.Reasons = append(info.Reasons, reason)
+	}
+
+	ua := req.GetHeader("User-Agent")
+	lower := strings.ToLower(ua)
+	switch {
+	case ua == "":
+		add(60, "no user-agent")
+	case c.match(lower, c.crawlers, info):
+		info.Class = ClassCrawler
+		add(90, "crawler user-agent")
+	case c.match(lower, c.tools, info):
+		info.Class = ClassAutomation
+		add(80, "automation user-agent")
+	case containsAny(lower, botTokens):
+		add(70, "bot user-agent")
+	}
+
+	// Browsers always send these, clients faking only the User-Agent often don't
+	if strings.HasPrefix(ua, "Mozilla/") && info.Class == ClassHuman {
+		if req.GetHeader("Accept") == "" {
+			add(15, "no accept")
+		}
+		if req.GetHeader("Accept-Language") == "" {
+			add(20, "no accept-language")
+		}
+		if req.GetHeader("Accept-Encoding") == "" {
+			add(15, "no accept-encoding")
+		}
+		if (strings.Contains(ua, "Chrome/") || strings.Contains(ua, "Firefox/")) && req.GetHeader("Sec-Fetch-Mode") == "" {
+			add(10, "no fetch metadata")
+		}
+	}
+
+	if limit := c.options.RateLimit; limit > 0 {
+		switch count := c.count(req.ClientIP(c.options.TrustForwarded), now); {
+		case count > 4*limit:
+			add(50, "request rate")
+		case count > limit:
+			add(30, "request rate")
+		}
+	}
+
+	if info.Score > 100 {
+		info.Score = 100
+	}
+	if info.Class == ClassHuman && info.Score >= c.options.Threshold {
+		info.Class = ClassBot
+	}
+	return info
+}
+
+// IsBot reports whether a classified request should be treated as a bot
+func (c *Classifier) IsBot(info *httputils.BotInfo) bool {
+	if info.Class == ClassCrawler && c.options.AllowCrawlers {
+		return false
+	}
+	return info.Score >= c.options.Threshold
+}
+
+// Annotate sets classification of a request
+func (c *Classifier) Annotate(req *httputils.HTTPRequest, info *httputils.BotInfo) {
+	req.Bot = info
+	if c.options.Header != "" {
+		req.SetHeader(c.options.Header, strconv.Itoa(info.Score))
+	}
+}
+
+// match finds the first token of a list in a lower case User-Agent
+func (c *Classifier) match(ua string, tokens []string, info *httputils.BotInfo) bool {
+	for _, t := range tokens {
+		if strings.Contains(ua, strings.ToLower(t)) {
+			info.Name = t
+			return true
+		}
+	}
+	return false
+}
+
+// count returns the number of requests of a client in the current window,
+// windows of inactive clients are removed
+func (c *Classifier) count(client string, now time.Time) int {
+	window := time.Duration(c.options.RateWindow)
+	if now.Sub(c.cleaned) > window {
+		for k, w := range c.windows {
+			if now.Sub(w.start) > window {
+				delete(c.windows, k)
+			}
+		}
+		c.cleaned = now
+	}
+	w, ok := c.windows[client]
+	if !ok || now.Sub(w.start) > window {
+		w = &rateWindow{start: now}
+		c.windows[client] = w
+	}
+	w.count++
+	return w.count
+}
+
+func containsAny(s string, tokens []string) bool {
+	for _, t := range tokens {
+		if strings.Contains(s, t) {
+			return true
+		}
+	}
+	return false
+}
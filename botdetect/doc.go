@@ -0,0 +1,57 @@
+package main
+
+import "github.com/cascades-fbp/cascades/library"
+
+var registryEntry = &library.Entry{
+	Description: `Scores incoming request IPs from 0 to 100 by likelihood of automated clients. Known crawler and
+tool User-Agents, missing headers every browser sends and request rate per client address raise the score. Requests
+are annotated with bot field (score, class, name and reasons) and optionally a score header for routing on headers.
+Requests scoring at least the threshold go to BOT port if it's connected, all others to OUT.`,
+	Elementary: true,
+	Inports: []library.EntryPort{
+		library.EntryPort{
+			Name:        "OPTIONS",
+			Type:        "json",
+			Description: `Configuration port, i.e. {"threshold": 50, "rate_limit": 120, "rate_window": "1m", "allow_crawlers": true, "header": "X-Bot-Score", "trust_forwarded": false}`,
+			Required:    true,
+		},
+		library.EntryPort{
+			Name:        "IN",
+			Type:        "json",
+			Description: "Input port for requests in predefined JSON format",
+			Required:    true,
+		},
+	},
+	Outports: []library.EntryPort{
+		library.EntryPort{
+			Name:        "OUT",
+			Type:        "json",
+			Description: "Output port for annotated requests",
+			Required:    true,
+		},
+		library.EntryPort{
+			Name:        "BOT",
+			Type:        "json",
+			Description: "Optional output port for annotated requests classified as bots",
+			Required:    false,
+		},
+		library.EntryPort{
+			Name:        "ERR",
+			Type:        "json",
+			Description: "Optional error port for invalid IPs (error JSON object defined in utils of HTTP components library)",
+			Required:    false,
+		},
+		library.EntryPort{
+			Name:        "LOG",
+			Type:        "json",
+			Description: "Optional output port for structured log entries (log entry JSON object defined in utils of HTTP components library)",
+			Required:    false,
+		},
+		library.EntryPort{
+			Name:        "HEARTBEAT",
+			Type:        "json",
+			Description: "Optional output port for periodic liveness IPs (heartbeat JSON object defined in utils of HTTP components library)",
+			Required:    false,
+		},
+	},
+}
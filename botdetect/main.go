@@ -0,0 +1,210 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"time"
+
+	httputils "github.com/cascades-fbp/cascades-http/utils"
+	"github.com/cascades-fbp/cascades/components/utils"
+	"github.com/cascades-fbp/cascades/runtime"
+	zmq "github.com/pebbe/zmq4"
+)
+
+var (
+	// Flags
+	optionsEndpoint   = flag.String("port.options", "", "Component's options port endpoint")
+	inputEndpoint     = flag.String("port.in", "", "Component's input port endpoint")
+	outputEndpoint    = flag.String("port.out", "", "Component's output port endpoint")
+	botEndpoint       = flag.String("port.bot", "", "Component's bot port endpoint")
+	errorEndpoint     = flag.String("port.err", "", "Component's error port endpoint")
+	logEndpoint       = flag.String("port.log", "", "Component's log port endpoint")
+	heartbeatEndpoint = flag.String("port.heartbeat", "", "Component's heartbeat port endpoint")
+	metricsAddr       = flag.String("metrics", "", "Address to expose metrics on, i.e. 127.0.0.1:9090 (disabled if empty)")
+	jsonFlag          = flag.Bool("json", false, "Print component documentation in JSON")
+	debug             = flag.Bool("debug", false, "Enable debug mode")
+
+	// Internal
+	optionsPort, inPort, outPort, botPort, errPort, logPort, heartbeatPort *zmq.Socket
+	err                                                                    error
+	logger                                                                 = httputils.NewLogger("http/botdetect")
+	liveness                                                               = httputils.NewLiveness("http/botdetect")
+	metrics                                                                = httputils.NewMetrics(logger, liveness)
+	shutdown                                                               *httputils.Shutdown
+)
+
+// validateArgs checks all required flags
+func validateArgs() {
+	if *optionsEndpoint == "" {
+		flag.Usage()
+		os.Exit(1)
+	}
+	if *inputEndpoint == "" {
+		flag.Usage()
+		os.Exit(1)
+	}
+	if *outputEndpoint == "" {
+		flag.Usage()
+		os.Exit(1)
+	}
+}
+
+// openPorts create ZMQ sockets
+func openPorts() {
+	optionsPort, err = utils.CreateInputPort("http/botdetect.options", *optionsEndpoint, nil)
+	utils.AssertError(err)
+
+	inPort, err = utils.CreateInputPort("http/botdetect.in", *inputEndpoint, nil)
+	utils.AssertError(err)
+
+	outPort, err = utils.CreateOutputPort("http/botdetect.out", *outputEndpoint, nil)
+	utils.AssertError(err)
+
+	if *botEndpoint != "" {
+		botPort, err = utils.CreateOutputPort("http/botdetect.bot", *botEndpoint, nil)
+		utils.AssertError(err)
+	}
+
+	if *errorEndpoint != "" {
+		errPort, err = utils.CreateOutputPort("http/botdetect.err", *errorEndpoint, nil)
+		utils.AssertError(err)
+	}
+
+	if *logEndpoint != "" {
+		logPort, err = utils.CreateOutputPort("http/botdetect.log", *logEndpoint, nil)
+		utils.AssertError(err)
+		logger.SetSink(func(ip [][]byte) { logPort.SendMessageDontwait(ip) })
+	}
+
+	if *heartbeatEndpoint != "" {
+		heartbeatPort, err = utils.CreateOutputPort("http/botdetect.heartbeat", *heartbeatEndpoint, nil)
+		utils.AssertError(err)
+		liveness.Start(func(ip [][]byte) { heartbeatPort.SendMessageDontwait(ip) })
+	}
+}
+
+// closePorts closes all active ports and terminates ZMQ context
+func closePorts() {
+	shutdown.Close(optionsPort, inPort)
+	liveness.Stop()
+	shutdown.Flush(outPort, botPort, errPort, heartbeatPort, logPort)
+	zmq.Term()
+}
+
+func main() {
+	flag.Parse()
+
+	if *jsonFlag {
+		doc, _ := registryEntry.JSON()
+		fmt.Println(string(doc))
+		os.Exit(0)
+	}
+
+	log.SetFlags(0)
+	if *debug {
+		logger.SetLevel(httputils.LevelDebug)
+		logger.SetOutput(os.Stdout)
+	}
+	log.SetOutput(logger.Writer())
+
+	validateArgs()
+
+	shutdown = httputils.NewShutdown(logger)
+	openPorts()
+
+	if *metricsAddr != "" {
+		err = metrics.Serve(*metricsAddr)
+		utils.AssertError(err)
+	}
+
+	err = runtime.SetupShutdownByDisconnect(inPort, "http/botdetect.in", shutdown.Signals())
+	utils.AssertError(err)
+
+	// Wait for the configuration on the options port
+	options := &Options{Threshold: 50, RateLimit: 120, RateWindow: httputils.Duration(time.Minute)}
+	for {
+		log.Println("Waiting for configuration...")
+		ip, err := shutdown.Receive(optionsPort)
+		if err == httputils.ErrShutdown {
+			shutdown.Exit(closePorts)
+		}
+		if err != nil {
+			logger.Error("Error receiving IP", "error", err)
+			continue
+		}
+		if !runtime.IsValidIP(ip) || !runtime.IsPacket(ip) {
+			continue
+		}
+		if err = json.Unmarshal(ip[1], options); err != nil {
+			logger.Error("Failed to unmarshal options", "error", err)
+			continue
+		}
+		break
+	}
+	optionsPort.Close()
+	optionsPort = nil
+	if options.RateWindow <= 0 {
+		options.RateWindow = httputils.Duration(time.Minute)
+	}
+
+	classifier := NewClassifier(options)
+
+	// Process incoming messages until shutdown
+	for {
+		ip, err := shutdown.Receive(inPort)
+		if err == httputils.ErrShutdown {
+			break
+		}
+		if err != nil {
+			logger.Error("Error receiving message", "error", err)
+			continue
+		}
+		liveness.Inc()
+		if !httputils.IsValidIP(ip) {
+			logger.Warn("Received invalid IP")
+			continue
+		}
+		if !runtime.IsPacket(ip) {
+			outPort.SendMessage(ip)
+			continue
+		}
+
+		req, err := httputils.IP2Request(ip)
+		if err != nil {
+			logger.Warn("Failed to convert IP to request", "error", err)
+			sendError(httputils.NewError("http/botdetect", httputils.ErrInvalidIP, err))
+			continue
+		}
+		info := classifier.Classify(req, time.Now())
+		classifier.Annotate(req, info)
+		ip, err = httputils.Request2IP(req)
+		if err != nil {
+			logger.Error("Failed to convert request to IP", "error", err)
+			sendError(httputils.NewError("http/botdetect", httputils.ErrInternal, err).WithRequest(req.ID))
+			continue
+		}
+
+		if botPort != nil && classifier.IsBot(info) {
+			logger.Debug("Classified bot", "id", req.ID, "score", info.Score, "class", info.Class, "reasons", info.Reasons)
+			botPort.SendMessage(ip)
+			continue
+		}
+		outPort.SendMessage(ip)
+	}
+	shutdown.Exit(closePorts)
+}
+
+// sendError reports a failure to the ERR port if it's connected
+func sendError(e *httputils.Error) {
+	if errPort == nil {
+		return
+	}
+	ip, err := httputils.Error2IP(e)
+	if err != nil {
+		return
+	}
+	errPort.SendMessageDontwait(ip)
+}
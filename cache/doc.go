@@ -0,0 +1,72 @@
+package main
+
+import "github.com/cascades-fbp/cascades/library"
+
+var registryEntry = &library.Entry{
+	Description: `Caches responses to GET/HEAD requests. Requests from REQUEST port are answered from cache on HIT
+port or forwarded to OUT on a miss. Upstream responses from RESPONSE port are stored and forwarded to RESP.
+Cached content can be invalidated at runtime via PURGE port using URI patterns or surrogate keys
+(taken from Surrogate-Key response header). Cache-Control and Expires response headers are honored,
+the configured ttl applies to responses without explicit freshness. Range requests hitting the cache are
+answered with 206 partial content, multiple ranges in a multipart/byteranges body, unless If-Range doesn't
+match the cached response.`,
+	Elementary: true,
+	Inports: []library.EntryPort{
+		library.EntryPort{
+			Name:        "OPTIONS",
+			Type:        "json",
+			Description: `Optional configuration port, i.e. {"ttl": "60s", "max_entries": 1000, "max_decompressed": 67108864}`,
+			Required:    false,
+		},
+		library.EntryPort{
+			Name:        "REQUEST",
+			Type:        "json",
+			Description: "Input port for requests in predefined JSON format",
+			Required:    true,
+		},
+		library.EntryPort{
+			Name:        "RESPONSE",
+			Type:        "json",
+			Description: "Input port for responses from the upstream",
+			Required:    true,
+		},
+		library.EntryPort{
+			Name:        "PURGE",
+			Type:        "json",
+			Description: `Optional invalidation port, i.e. {"pattern": "/users/*"} or {"key": "user-42"} (plain string is a pattern)`,
+			Required:    false,
+		},
+	},
+	Outports: []library.EntryPort{
+		library.EntryPort{
+			Name:        "OUT",
+			Type:        "json",
+			Description: "Output port for requests which are not in cache",
+			Required:    true,
+		},
+		library.EntryPort{
+			Name:        "HIT",
+			Type:        "json",
+			Description: "Output port for responses served from cache",
+			Required:    true,
+		},
+		library.EntryPort{
+			Name:        "RESP",
+			Type:        "json",
+			Description: "Output port for forwarded upstream responses",
+			Required:    true,
+		},
+		library.EntryPort{
+			Name:        "LOG",
+			Type:        "json",
+			Description: "Optional output port for structured log entries (log entry JSON object defined in utils of HTTP components library)",
+			Required:    false,
+		},
+		library.EntryPort{
+			Name:        "HEARTBEAT",
+			Type:        "json",
+			Description: "Optional output port for periodic liveness IPs (heartbeat JSON object defined in utils of HTTP components library)",
+			Required:    false,
+		},
+	},
+}
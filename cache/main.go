@@ -0,0 +1,290 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+	"time"
+
+	httputils "github.com/cascades-fbp/cascades-http/utils"
+	"github.com/cascades-fbp/cascades/components/utils"
+	"github.com/cascades-fbp/cascades/runtime"
+	zmq "github.com/pebbe/zmq4"
+)
+
+var (
+	// Flags
+	optionsEndpoint   = flag.String("port.options", "", "Component's options port endpoint")
+	requestEndpoint   = flag.String("port.request", "", "Component's request port endpoint")
+	responseEndpoint  = flag.String("port.response", "", "Component's upstream response port endpoint")
+	purgeEndpoint     = flag.String("port.purge", "", "Component's purge port endpoint")
+	outputEndpoint    = flag.String("port.out", "", "Component's output port endpoint")
+	hitEndpoint       = flag.String("port.hit", "", "Component's cache hit port endpoint")
+	respEndpoint      = flag.String("port.resp", "", "Component's forwarded response port endpoint")
+	logEndpoint       = flag.String("port.log", "", "Component's log port endpoint")
+	heartbeatEndpoint = flag.String("port.heartbeat", "", "Component's heartbeat port endpoint")
+	metricsAddr       = flag.String("metrics", "", "Address to expose metrics on, i.e. 127.0.0.1:9090 (disabled if empty)")
+	jsonFlag          = flag.Bool("json", false, "Print component documentation in JSON")
+	debug             = flag.Bool("debug", false, "Enable debug mode")
+
+	// Internal
+	optionsPort, requestPort, responsePort, purgePort, outPort, hitPort, respPort, logPort, heartbeatPort *zmq.Socket
+	err                                                                                                   error
+	logger                                                                                                = httputils.NewLogger("http/cache")
+	liveness                                                                                              = httputils.NewLiveness("http/cache")
+	metrics                                                                                               = httputils.NewMetrics(logger, liveness)
+	shutdown                                                                                              *httputils.Shutdown
+)
+
+// validateArgs checks all required flags
+func validateArgs() {
+	if *requestEndpoint == "" {
+		flag.Usage()
+		os.Exit(1)
+	}
+	if *responseEndpoint == "" {
+		flag.Usage()
+		os.Exit(1)
+	}
+	if *outputEndpoint == "" {
+		flag.Usage()
+		os.Exit(1)
+	}
+	if *hitEndpoint == "" {
+		flag.Usage()
+		os.Exit(1)
+	}
+	if *respEndpoint == "" {
+		flag.Usage()
+		os.Exit(1)
+	}
+}
+
+// openPorts create ZMQ sockets
+func openPorts() {
+	if *optionsEndpoint != "" {
+		optionsPort, err = utils.CreateInputPort("http/cache.options", *optionsEndpoint, nil)
+		utils.AssertError(err)
+	}
+
+	requestPort, err = utils.CreateInputPort("http/cache.request", *requestEndpoint, nil)
+	utils.AssertError(err)
+
+	responsePort, err = utils.CreateInputPort("http/cache.response", *responseEndpoint, nil)
+	utils.AssertError(err)
+
+	if *purgeEndpoint != "" {
+		purgePort, err = utils.CreateInputPort("http/cache.purge", *purgeEndpoint, nil)
+		utils.AssertError(err)
+	}
+
+	outPort, err = utils.CreateOutputPort("http/cache.out", *outputEndpoint, nil)
+	utils.AssertError(err)
+
+	hitPort, err = utils.CreateOutputPort("http/cache.hit", *hitEndpoint, nil)
+	utils.AssertError(err)
+
+	respPort, err = utils.CreateOutputPort("http/cache.resp", *respEndpoint, nil)
+	utils.AssertError(err)
+
+	if *logEndpoint != "" {
+		logPort, err = utils.CreateOutputPort("http/cache.log", *logEndpoint, nil)
+		utils.AssertError(err)
+		logger.SetSink(func(ip [][]byte) { logPort.SendMessageDontwait(ip) })
+	}
+
+	if *heartbeatEndpoint != "" {
+		heartbeatPort, err = utils.CreateOutputPort("http/cache.heartbeat", *heartbeatEndpoint, nil)
+		utils.AssertError(err)
+		liveness.Start(func(ip [][]byte) { heartbeatPort.SendMessageDontwait(ip) })
+	}
+}
+
+// closePorts closes all active ports and terminates ZMQ context
+func closePorts() {
+	shutdown.Close(optionsPort, requestPort, responsePort, purgePort)
+	liveness.Stop()
+	shutdown.Flush(outPort, hitPort, respPort, heartbeatPort, logPort)
+	zmq.Term()
+}
+
+// parsePurge accepts JSON invalidation object or a plain URI pattern
+func parsePurge(data []byte) (*Purge, error) {
+	p := &Purge{}
+	s := strings.TrimSpace(string(data))
+	if strings.HasPrefix(s, "{") {
+		if err := json.Unmarshal(data, p); err != nil {
+			return nil, err
+		}
+	} else {
+		p.Pattern = s
+	}
+	if p.Pattern == "" && p.Key == "" {
+		return nil, fmt.Errorf("either pattern or key is required")
+	}
+	return p, nil
+}
+
+// ifRange checks if If-Range of a request, if any, matches ETag or Last-Modified
+// of a cached response, otherwise the full response is sent
+func ifRange(req *httputils.HTTPRequest, resp *httputils.HTTPResponse) bool {
+	v := req.GetHeader("If-Range")
+	if v == "" {
+		return true
+	}
+	if etag := resp.GetHeader("ETag"); etag != "" && !strings.HasPrefix(etag, "W/") && v == etag {
+		return true
+	}
+	return v == resp.GetHeader("Last-Modified")
+}
+
+func main() {
+	flag.Parse()
+
+	if *jsonFlag {
+		doc, _ := registryEntry.JSON()
+		fmt.Println(string(doc))
+		os.Exit(0)
+	}
+
+	log.SetFlags(0)
+	if *debug {
+		logger.SetLevel(httputils.LevelDebug)
+		logger.SetOutput(os.Stdout)
+	}
+	log.SetOutput(logger.Writer())
+
+	validateArgs()
+
+	shutdown = httputils.NewShutdown(logger)
+	openPorts()
+
+	if *metricsAddr != "" {
+		err = metrics.Serve(*metricsAddr)
+		utils.AssertError(err)
+	}
+
+	err = runtime.SetupShutdownByDisconnect(requestPort, "http/cache.request", shutdown.Signals())
+	utils.AssertError(err)
+
+	// Wait for the configuration on the options port
+	options := &Options{TTL: "60s", MaxEntries: 1000}
+	for optionsPort != nil {
+		log.Println("Waiting for configuration...")
+		ip, err := shutdown.Receive(optionsPort)
+		if err == httputils.ErrShutdown {
+			shutdown.Exit(closePorts)
+		}
+		if err != nil {
+			logger.Error("Error receiving IP", "error", err)
+			continue
+		}
+		if !runtime.IsValidIP(ip) || !runtime.IsPacket(ip) {
+			continue
+		}
+		if err = json.Unmarshal(ip[1], options); err != nil {
+			logger.Error("Failed to unmarshal options", "error", err)
+			continue
+		}
+		optionsPort.Close()
+		optionsPort = nil
+	}
+
+	ttl, err := time.ParseDuration(options.TTL)
+	utils.AssertError(err)
+	store := NewStore(ttl, options.MaxEntries)
+
+	poller := zmq.NewPoller()
+	poller.Add(requestPort, zmq.POLLIN)
+	poller.Add(responsePort, zmq.POLLIN)
+	if purgePort != nil {
+		poller.Add(purgePort, zmq.POLLIN)
+	}
+
+	// Map of request ID to requests waiting for upstream response
+	misses := make(map[string]*httputils.HTTPRequest)
+
+	// Main loop
+	for !shutdown.Stopping() {
+		sockets, err := poller.Poll(shutdown.PollInterval)
+		if err != nil {
+			logger.Error("Error polling ports", "error", err)
+			continue
+		}
+
+		now := time.Now()
+		for _, socket := range sockets {
+			ip, err := socket.Socket.RecvMessageBytes(0)
+			if err != nil {
+				logger.Error("Error receiving message", "error", err)
+				continue
+			}
+			liveness.Inc()
+			if !httputils.IsValidIP(ip) || !runtime.IsPacket(ip) {
+				continue
+			}
+
+			switch socket.Socket {
+			case requestPort:
+				req, err := httputils.IP2Request(ip)
+				if err != nil {
+					logger.Warn("Failed to convert IP to request", "error", err)
+					continue
+				}
+				if !Cacheable(req) {
+					outPort.SendMessage(ip)
+					continue
+				}
+				if cached, ok := store.Get(Key(req), now); ok {
+					log.Println("Cache hit for", Key(req))
+					resp := cached.Clone()
+					resp.ID = req.ID
+					if !httputils.AcceptsGzip(req) && resp.GetHeader("Content-Encoding") == "gzip" {
+						// Cached variant is compressed but the client can't decode it,
+						// the request goes upstream if it can't be decompressed
+						if err = httputils.GunzipResponse(resp, options.MaxDecompressed); err != nil {
+							logger.Error("Failed to decompress cached response", "error", err)
+							misses[req.ID] = req
+							outPort.SendMessage(ip)
+							continue
+						}
+					}
+					// Range requests get partial content of the cached response, several
+					// ranges in one multipart/byteranges body
+					if rangeHeader := req.GetHeader("Range"); rangeHeader != "" && req.Method == "GET" && ifRange(req, resp) {
+						resp = httputils.RangeResponse(rangeHeader, resp)
+					}
+					ip, _ = httputils.Response2IP(resp)
+					hitPort.SendMessage(ip)
+					continue
+				}
+				misses[req.ID] = req
+				outPort.SendMessage(ip)
+
+			case responsePort:
+				resp, err := httputils.IP2Response(ip)
+				if err != nil {
+					logger.Warn("Failed to convert IP to response", "error", err)
+					continue
+				}
+				if req, ok := misses[resp.ID]; ok {
+					delete(misses, resp.ID)
+					store.Put(Key(req), req.URI, resp, now)
+				}
+				respPort.SendMessage(ip)
+
+			case purgePort:
+				p, err := parsePurge(ip[1])
+				if err != nil {
+					logger.Warn("Invalid purge request", "error", err)
+					continue
+				}
+				logger.Info("Purged entries", "count", store.Purge(p))
+			}
+		}
+	}
+	shutdown.Exit(closePorts)
+}
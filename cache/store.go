@@ -0,0 +1,146 @@
+package main
+
+import (
+	"net/http"
+	"net/url"
+	"path"
+	"strings"
+	"time"
+
+	httputils "github.com/cascades-fbp/cascades-http/utils"
+)
+
+// Options describe the configuration IP of the component
+type Options struct {
+	TTL             string `json:"ttl"`              // Lifetime of cached responses
+	MaxEntries      int    `json:"max_entries"`      // Maximal number of cached responses
+	MaxDecompressed int64  `json:"max_decompressed"` // Cap of gzip bodies decoded for clients without gzip support, 64 MiB by default
+}
+
+// Purge describes an invalidation IP
+type Purge struct {
+	Pattern string `json:"pattern"` // URI pattern in path.Match syntax (matched against path when it has no query)
+	Key     string `json:"key"`     // Surrogate key
+}
+
+type entry struct {
+	response *httputils.HTTPResponse
+	uri      string
+	keys     []string
+	expires  time.Time
+}
+
+// Store keeps cached responses by method and URI
+type Store struct {
+	ttl        time.Duration
+	maxEntries int
+	entries    map[string]*entry
+}
+
+// NewStore creates an empty store
+func NewStore(ttl time.Duration, maxEntries int) *Store {
+	return &Store{
+		ttl:        ttl,
+		maxEntries: maxEntries,
+		entries:    make(map[string]*entry),
+	}
+}
+
+// Cacheable reports whether responses to a given request may be cached
+func Cacheable(req *httputils.HTTPRequest) bool {
+	return req.Method == "GET" || req.Method == "HEAD"
+}
+
+// Key returns cache key for a given request
+func Key(req *httputils.HTTPRequest) string {
+	return req.Method + " " + req.URI
+}
+
+// Get returns cached response for a key
+func (s *Store) Get(key string, now time.Time) (*httputils.HTTPResponse, bool) {
+	e, ok := s.entries[key]
+	if !ok {
+		return nil, false
+	}
+	if now.After(e.expires) {
+		delete(s.entries, key)
+		return nil, false
+	}
+	return e.response, true
+}
+
+// Put stores a response for a key if it's cacheable. Freshness given by response
+// headers takes precedence over the configured TTL.
+func (s *Store) Put(key, uri string, resp *httputils.HTTPResponse, now time.Time) {
+	if resp.StatusCode != http.StatusOK {
+		return
+	}
+	ttl := s.ttl
+	if lifetime, ok := httputils.FreshnessLifetime(resp.Header, true); ok {
+		ttl = lifetime
+	}
+	if ttl <= 0 {
+		return
+	}
+	if len(s.entries) >= s.maxEntries {
+		s.evict(now)
+	}
+	s.entries[key] = &entry{
+		response: resp,
+		uri:      uri,
+		keys:     strings.Fields(http.Header(resp.Header).Get("Surrogate-Key")),
+		expires:  now.Add(ttl),
+	}
+}
+
+// Purge removes entries matching invalidation request and returns their number
+func (s *Store) Purge(p *Purge) int {
+	n := 0
+	for k, e := range s.entries {
+		if p.Key != "" && hasKey(e.keys, p.Key) || p.Pattern != "" && matchURI(p.Pattern, e.uri) {
+			delete(s.entries, k)
+			n++
+		}
+	}
+	return n
+}
+
+// evict removes expired entries or, if none expired, the one expiring first
+func (s *Store) evict(now time.Time) {
+	var (
+		oldest    string
+		oldestExp time.Time
+	)
+	for k, e := range s.entries {
+		if now.After(e.expires) {
+			delete(s.entries, k)
+			continue
+		}
+		if oldest == "" || e.expires.Before(oldestExp) {
+			oldest, oldestExp = k, e.expires
+		}
+	}
+	if len(s.entries) >= s.maxEntries && oldest != "" {
+		delete(s.entries, oldest)
+	}
+}
+
+func hasKey(keys []string, key string) bool {
+	for _, k := range keys {
+		if k == key {
+			return true
+		}
+	}
+	return false
+}
+
+func matchURI(pattern, uri string) bool {
+	if ok, _ := path.Match(pattern, uri); ok {
+		return true
+	}
+	if u, err := url.ParseRequestURI(uri); err == nil {
+		ok, _ := path.Match(pattern, u.Path)
+		return ok
+	}
+	return false
+}
@@ -0,0 +1,56 @@
+package main
+
+import "github.com/cascades-fbp/cascades/library"
+
+var registryEntry = &library.Entry{
+	Description: `Splits incoming JSON requests between STABLE and CANARY output ports. A configured percentage of
+matching requests is routed to CANARY. Assignment is sticky when a cookie or header is configured: the same
+value always lands on the same side for a given percentage. Percentage can be updated live via PERCENT port.`,
+	Elementary: true,
+	Inports: []library.EntryPort{
+		library.EntryPort{
+			Name:        "OPTIONS",
+			Type:        "json",
+			Description: `Configuration port, i.e. {"percent": 5, "cookie": "session", "header": "X-User-Id", "prefix": "/api/"}`,
+			Required:    true,
+		},
+		library.EntryPort{
+			Name:        "PERCENT",
+			Type:        "string",
+			Description: "Optional port for updating the canary percentage (0-100) at runtime",
+			Required:    false,
+		},
+		library.EntryPort{
+			Name:        "IN",
+			Type:        "json",
+			Description: "Input port for receiving requests in predefined JSON format",
+			Required:    true,
+		},
+	},
+	Outports: []library.EntryPort{
+		library.EntryPort{
+			Name:        "STABLE",
+			Type:        "json",
+			Description: "Output port for requests assigned to the stable version",
+			Required:    true,
+		},
+		library.EntryPort{
+			Name:        "CANARY",
+			Type:        "json",
+			Description: "Output port for requests assigned to the canary version",
+			Required:    true,
+		},
+		library.EntryPort{
+			Name:        "LOG",
+			Type:        "json",
+			Description: "Optional output port for structured log entries (log entry JSON object defined in utils of HTTP components library)",
+			Required:    false,
+		},
+		library.EntryPort{
+			Name:        "HEARTBEAT",
+			Type:        "json",
+			Description: "Optional output port for periodic liveness IPs (heartbeat JSON object defined in utils of HTTP components library)",
+			Required:    false,
+		},
+	},
+}
@@ -0,0 +1,212 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"strconv"
+	"strings"
+
+	httputils "github.com/cascades-fbp/cascades-http/utils"
+	"github.com/cascades-fbp/cascades/components/utils"
+	"github.com/cascades-fbp/cascades/runtime"
+	zmq "github.com/pebbe/zmq4"
+)
+
+var (
+	// Flags
+	optionsEndpoint   = flag.String("port.options", "", "Component's options port endpoint")
+	percentEndpoint   = flag.String("port.percent", "", "Component's percentage update port endpoint")
+	inputEndpoint     = flag.String("port.in", "", "Component's input port endpoint")
+	stableEndpoint    = flag.String("port.stable", "", "Component's stable output port endpoint")
+	canaryEndpoint    = flag.String("port.canary", "", "Component's canary output port endpoint")
+	logEndpoint       = flag.String("port.log", "", "Component's log port endpoint")
+	heartbeatEndpoint = flag.String("port.heartbeat", "", "Component's heartbeat port endpoint")
+	metricsAddr       = flag.String("metrics", "", "Address to expose metrics on, i.e. 127.0.0.1:9090 (disabled if empty)")
+	jsonFlag          = flag.Bool("json", false, "Print component documentation in JSON")
+	debug             = flag.Bool("debug", false, "Enable debug mode")
+
+	// Internal
+	optionsPort, percentPort, inPort, stablePort, canaryPort, logPort, heartbeatPort *zmq.Socket
+	err                                                                              error
+	logger                                                                           = httputils.NewLogger("http/canary")
+	liveness                                                                         = httputils.NewLiveness("http/canary")
+	metrics                                                                          = httputils.NewMetrics(logger, liveness)
+	shutdown                                                                         *httputils.Shutdown
+)
+
+// validateArgs checks all required flags
+func validateArgs() {
+	if *optionsEndpoint == "" {
+		flag.Usage()
+		os.Exit(1)
+	}
+	if *inputEndpoint == "" {
+		flag.Usage()
+		os.Exit(1)
+	}
+	if *stableEndpoint == "" {
+		flag.Usage()
+		os.Exit(1)
+	}
+	if *canaryEndpoint == "" {
+		flag.Usage()
+		os.Exit(1)
+	}
+}
+
+// openPorts create ZMQ sockets
+func openPorts() {
+	optionsPort, err = utils.CreateInputPort("http/canary.options", *optionsEndpoint, nil)
+	utils.AssertError(err)
+
+	if *percentEndpoint != "" {
+		percentPort, err = utils.CreateInputPort("http/canary.percent", *percentEndpoint, nil)
+		utils.AssertError(err)
+	}
+
+	inPort, err = utils.CreateInputPort("http/canary.in", *inputEndpoint, nil)
+	utils.AssertError(err)
+
+	stablePort, err = utils.CreateOutputPort("http/canary.stable", *stableEndpoint, nil)
+	utils.AssertError(err)
+
+	canaryPort, err = utils.CreateOutputPort("http/canary.canary", *canaryEndpoint, nil)
+	utils.AssertError(err)
+
+	if *logEndpoint != "" {
+		logPort, err = utils.CreateOutputPort("http/canary.log", *logEndpoint, nil)
+		utils.AssertError(err)
+		logger.SetSink(func(ip [][]byte) { logPort.SendMessageDontwait(ip) })
+	}
+
+	if *heartbeatEndpoint != "" {
+		heartbeatPort, err = utils.CreateOutputPort("http/canary.heartbeat", *heartbeatEndpoint, nil)
+		utils.AssertError(err)
+		liveness.Start(func(ip [][]byte) { heartbeatPort.SendMessageDontwait(ip) })
+	}
+}
+
+// closePorts closes all active ports and terminates ZMQ context
+func closePorts() {
+	shutdown.Close(optionsPort, percentPort, inPort)
+	liveness.Stop()
+	shutdown.Flush(stablePort, canaryPort, heartbeatPort, logPort)
+	zmq.Term()
+}
+
+func main() {
+	flag.Parse()
+
+	if *jsonFlag {
+		doc, _ := registryEntry.JSON()
+		fmt.Println(string(doc))
+		os.Exit(0)
+	}
+
+	log.SetFlags(0)
+	if *debug {
+		logger.SetLevel(httputils.LevelDebug)
+		logger.SetOutput(os.Stdout)
+	}
+	log.SetOutput(logger.Writer())
+
+	validateArgs()
+
+	shutdown = httputils.NewShutdown(logger)
+	openPorts()
+
+	if *metricsAddr != "" {
+		err = metrics.Serve(*metricsAddr)
+		utils.AssertError(err)
+	}
+
+	err = runtime.SetupShutdownByDisconnect(inPort, "http/canary.in", shutdown.Signals())
+	utils.AssertError(err)
+
+	// Wait for the configuration on the options port
+	var options Options
+	for {
+		log.Println("Waiting for configuration...")
+		ip, err := shutdown.Receive(optionsPort)
+		if err == httputils.ErrShutdown {
+			shutdown.Exit(closePorts)
+		}
+		if err != nil {
+			logger.Error("Error receiving IP", "error", err)
+			continue
+		}
+		if !runtime.IsValidIP(ip) || !runtime.IsPacket(ip) {
+			continue
+		}
+		if err = json.Unmarshal(ip[1], &options); err != nil {
+			logger.Error("Failed to unmarshal options", "error", err)
+			continue
+		}
+		break
+	}
+	optionsPort.Close()
+	optionsPort = nil
+
+	splitter := NewSplitter(options)
+
+	poller := zmq.NewPoller()
+	poller.Add(inPort, zmq.POLLIN)
+	if percentPort != nil {
+		poller.Add(percentPort, zmq.POLLIN)
+	}
+
+	// Main loop
+	for !shutdown.Stopping() {
+		sockets, err := poller.Poll(shutdown.PollInterval)
+		if err != nil {
+			logger.Error("Error polling ports", "error", err)
+			continue
+		}
+		for _, socket := range sockets {
+			ip, err := socket.Socket.RecvMessageBytes(0)
+			if err != nil {
+				logger.Error("Error receiving message", "error", err)
+				continue
+			}
+			liveness.Inc()
+			if !httputils.IsValidIP(ip) {
+				logger.Warn("Received invalid IP")
+				continue
+			}
+
+			switch socket.Socket {
+			case percentPort:
+				if !runtime.IsPacket(ip) {
+					continue
+				}
+				percent, err := strconv.ParseFloat(strings.TrimSpace(string(ip[1])), 64)
+				if err != nil {
+					logger.Warn("Invalid percentage", "error", err)
+					continue
+				}
+				splitter.SetPercent(percent)
+				logger.Info("Canary percentage updated", "percent", percent)
+
+			case inPort:
+				if !runtime.IsPacket(ip) {
+					stablePort.SendMessage(ip)
+					continue
+				}
+				req, err := httputils.IP2Request(ip)
+				if err != nil {
+					logger.Warn("Failed to convert IP to request", "error", err)
+					continue
+				}
+				if splitter.IsCanary(req) {
+					canaryPort.SendMessage(ip)
+				} else {
+					stablePort.SendMessage(ip)
+				}
+			}
+		}
+	}
+	shutdown.Exit(closePorts)
+}
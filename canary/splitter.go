@@ -0,0 +1,71 @@
+package main
+
+import (
+	"hash/fnv"
+	"math/rand"
+	"net/http"
+	"strings"
+
+	httputils "github.com/cascades-fbp/cascades-http/utils"
+)
+
+// Options describe the configuration IP of the component
+type Options struct {
+	Percent float64 `json:"percent"` // Share of matching requests sent to canary (0-100)
+	Cookie  string  `json:"cookie"`  // Cookie used for sticky assignment
+	Header  string  `json:"header"`  // Header used for sticky assignment (if cookie is absent)
+	Prefix  string  `json:"prefix"`  // Only requests with URI starting with prefix are split
+}
+
+// Splitter decides whether a given request goes to the canary
+type Splitter struct {
+	options Options
+}
+
+// NewSplitter returns a splitter for given options
+func NewSplitter(options Options) *Splitter {
+	s := &Splitter{options: options}
+	s.SetPercent(options.Percent)
+	return s
+}
+
+// SetPercent updates the canary share, clamping it to 0-100
+func (s *Splitter) SetPercent(percent float64) {
+	if percent < 0 {
+		percent = 0
+	}
+	if percent > 100 {
+		percent = 100
+	}
+	s.options.Percent = percent
+}
+
+// IsCanary reports whether a request should be routed to the canary
+func (s *Splitter) IsCanary(req *httputils.HTTPRequest) bool {
+	if s.options.Percent == 0 || !strings.HasPrefix(req.URI, s.options.Prefix) {
+		return false
+	}
+
+	key := s.stickyKey(req)
+	if key == "" {
+		return rand.Float64()*100 < s.options.Percent
+	}
+
+	h := fnv.New32a()
+	h.Write([]byte(key))
+	return float64(h.Sum32()%10000) < s.options.Percent*100
+}
+
+// stickyKey resolves a value identifying the client for a sticky assignment
+func (s *Splitter) stickyKey(req *httputils.HTTPRequest) string {
+	r := &http.Request{Header: http.Header(req.Header)}
+	if s.options.Cookie != "" {
+		if c, err := r.Cookie(s.options.Cookie); err == nil && c.Value != "" {
+			return c.Value
+		}
+	}
+	if s.options.Header != "" {
+		return r.Header.Get(s.options.Header)
+	}
+	return ""
+}
@@ -0,0 +1,64 @@
+package main
+
+import "github.com/cascades-fbp/cascades/library"
+
+var registryEntry = &library.Entry{
+	Description: `Injects faults into request and response streams for resilience testing of graphs. Requests
+from IN are forwarded to OUT and responses from RESPONSE to RESP, a configurable fraction of matching requests
+is delayed, dropped without response or answered with a 5xx status on RESP instead of being forwarded. Bodies of
+a fraction of responses are truncated keeping the original Content-Length, so clients see an interrupted
+transfer. Options can be sent again at runtime, i.e. {"disabled": true} stops the experiment.`,
+	Elementary: true,
+	Inports: []library.EntryPort{
+		library.EntryPort{
+			Name:        "OPTIONS",
+			Type:        "json",
+			Description: `Configuration port, i.e. {"paths": ["/api/"], "seed": 42, "annotate": true, "latency": {"rate": 0.2, "min": "100ms", "max": "2s"}, "drop": {"rate": 0.01}, "error": {"rate": 0.05, "statuses": [502, 503]}, "truncate": {"rate": 0.05, "fraction": 0.5}} (can be sent again at runtime)`,
+			Required:    true,
+		},
+		library.EntryPort{
+			Name:        "IN",
+			Type:        "json",
+			Description: "Input port for requests in predefined JSON format",
+			Required:    true,
+		},
+		library.EntryPort{
+			Name:        "RESPONSE",
+			Type:        "json",
+			Description: "Optional input port for responses from the upstream (required for truncating bodies)",
+			Required:    false,
+		},
+	},
+	Outports: []library.EntryPort{
+		library.EntryPort{
+			Name:        "OUT",
+			Type:        "json",
+			Description: "Output port for forwarded requests",
+			Required:    true,
+		},
+		library.EntryPort{
+			Name:        "RESP",
+			Type:        "json",
+			Description: "Output port for injected error responses and forwarded upstream responses",
+			Required:    true,
+		},
+		library.EntryPort{
+			Name:        "ERR",
+			Type:        "json",
+			Description: "Optional error port for invalid IPs (error JSON object defined in utils of HTTP components library)",
+			Required:    false,
+		},
+		library.EntryPort{
+			Name:        "LOG",
+			Type:        "json",
+			Description: "Optional output port for structured log entries (log entry JSON object defined in utils of HTTP components library)",
+			Required:    false,
+		},
+		library.EntryPort{
+			Name:        "HEARTBEAT",
+			Type:        "json",
+			Description: "Optional output port for periodic liveness IPs (heartbeat JSON object defined in utils of HTTP components library)",
+			Required:    false,
+		},
+	},
+}
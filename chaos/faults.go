@@ -0,0 +1,248 @@
+package main
+
+import (
+	"container/heap"
+	"fmt"
+	"math/rand"
+	"net/http"
+	"strings"
+	"time"
+
+	httputils "github.com/cascades-fbp/cascades-http/utils"
+)
+
+// Options describe the configuration IP of the component
+type Options struct {
+	Disabled bool             `json:"disabled"` // Pass everything unchanged, i.e. to stop an experiment at runtime
+	Methods  []string         `json:"methods"`  // Only requests with these methods are affected (all if empty)
+	Paths    []string         `json:"paths"`    // Only requests with these path prefixes are affected (all if empty)
+	Seed     int64            `json:"seed"`     // Seed of the random generator for reproducible runs (random if 0)
+	Annotate bool             `json:"annotate"` // Set X-Chaos-Fault header on affected requests and responses
+	Latency  *LatencyOptions  `json:"latency"`
+	Drop     *DropOptions     `json:"drop"`
+	Error    *ErrorOptions    `json:"error"`
+	Truncate *TruncateOptions `json:"truncate"`
+}
+
+// LatencyOptions delay requests by a random duration between min and max
+type LatencyOptions struct {
+	Rate float64            `json:"rate"`
+	Min  httputils.Duration `json:"min"`
+	Max  httputils.Duration `json:"max"`
+}
+
+// DropOptions swallow requests, clients get no response until they time out
+type DropOptions struct {
+	Rate float64 `json:"rate"`
+}
+
+// ErrorOptions answer requests with one of statuses instead of forwarding them
+type ErrorOptions struct {
+	Rate     float64 `json:"rate"`
+	Statuses []int   `json:"statuses"` // 500, 502, 503 and 504 by default
+	Body     string  `json:"body"`
+}
+
+// TruncateOptions cut bodies of upstream responses to a fraction of their size
+type TruncateOptions struct {
+	Rate     float64 `json:"rate"`
+	Fraction float64 `json:"fraction"` // Part of the body kept, 0.5 by default
+}
+
+// FaultHeader describes injected faults if annotate is set
+const FaultHeader = "X-Chaos-Fault"
+
+// Fault is the decision for a single request
+type Fault struct {
+	Delay    time.Duration
+	Drop     bool
+	Status   int
+	Truncate bool
+}
+
+// String describes the fault for logs and FaultHeader, empty if there is none
+func (f Fault) String() string {
+	var parts []string
+	if f.Drop {
+		parts = append(parts, "drop")
+	}
+	if f.Status != 0 {
+		parts = append(parts, fmt.Sprintf("error=%d", f.Status))
+	}
+	if f.Delay > 0 {
+		parts = append(parts, "latency="+f.Delay.String())
+	}
+	if f.Truncate {
+		parts = append(parts, "truncate")
+	}
+	return strings.Join(parts, ", ")
+}
+
+// Injector decides which faults requests get, it isn't safe for concurrent use
+type Injector struct {
+	options *Options
+	rnd     *rand.Rand
+}
+
+// NewInjector validates options and creates an injector
+func NewInjector(options *Options) (*Injector, error) {
+	rates := map[string]float64{}
+	if l := options.Latency; l != nil {
+		rates["latency"] = l.Rate
+		if l.Max == 0 {
+			l.Max = l.Min
+		}
+		if l.Min < 0 || l.Max < l.Min {
+			return nil, fmt.Errorf("latency: invalid range %s-%s", time.Duration(l.Min), time.Duration(l.Max))
+		}
+	}
+	if options.Drop != nil {
+		rates["drop"] = options.Drop.Rate
+	}
+	if e := options.Error; e != nil {
+		rates["error"] = e.Rate
+		if len(e.Statuses) == 0 {
+			e.Statuses = []int{
+				http.StatusInternalServerError,
+				http.StatusBadGateway,
+				http.StatusServiceUnavailable,
+				http.StatusGatewayTimeout,
+			}
+		}
+		for _, status := range e.Statuses {
+			if status < 400 || status > 599 {
+				return nil, fmt.Errorf("error: invalid status %d", status)
+			}
+		}
+		if e.Body == "" {
+			e.Body = "Injected fault"
+		}
+	}
+	if t := options.Truncate; t != nil {
+		rates["truncate"] = t.Rate
+		if t.Fraction == 0 {
+			t.Fraction = 0.5
+		}
+		if t.Fraction < 0 || t.Fraction >= 1 {
+			return nil, fmt.Errorf("truncate: fraction must be in [0, 1)")
+		}
+	}
+	for name, rate := range rates {
+		if rate < 0 || rate > 1 {
+			return nil, fmt.Errorf("%s: rate must be in [0, 1]", name)
+		}
+	}
+
+	seed := options.Seed
+	if seed == 0 {
+		seed = time.Now().UnixNano()
+	}
+	return &Injector{options: options, rnd: rand.New(rand.NewSource(seed))}, nil
+}
+
+// Decide returns faults for a request. Dropped requests get no other fault, an error
+// response may still be delayed.
+func (i *Injector) Decide(req *httputils.HTTPRequest) Fault {
+	var f Fault
+	o := i.options
+	if o.Disabled || !i.matches(req) {
+		return f
+	}
+	if o.Drop != nil && i.hit(o.Drop.Rate) {
+		f.Drop = true
+		return f
+	}
+	if o.Error != nil && i.hit(o.Error.Rate) {
+		f.Status = o.Error.Statuses[i.rnd.Intn(len(o.Error.Statuses))]
+	}
+	if o.Latency != nil && i.hit(o.Latency.Rate) {
+		f.Delay = time.Duration(o.Latency.Min)
+		if spread := int64(o.Latency.Max - o.Latency.Min); spread > 0 {
+			f.Delay += time.Duration(i.rnd.Int63n(spread + 1))
+		}
+	}
+	if f.Status == 0 && o.Truncate != nil && i.hit(o.Truncate.Rate) {
+		f.Truncate = true
+	}
+	return f
+}
+
+// TruncateBody cuts a body to the configured fraction
+func (i *Injector) TruncateBody(body []byte) []byte {
+	return body[:int(float64(len(body))*i.options.Truncate.Fraction)]
+}
+
+func (i *Injector) hit(rate float64) bool {
+	return rate > 0 && i.rnd.Float64() < rate
+}
+
+func (i *Injector) matches(req *httputils.HTTPRequest) bool {
+	if len(i.options.Methods) > 0 {
+		found := false
+		for _, m := range i.options.Methods {
+			if strings.EqualFold(m, req.Method) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	if len(i.options.Paths) == 0 {
+		return true
+	}
+	path := req.URI
+	if j := strings.IndexAny(path, "?#"); j >= 0 {
+		path = path[:j]
+	}
+	for _, p := range i.options.Paths {
+		if strings.HasPrefix(path, p) {
+			return true
+		}
+	}
+	return false
+}
+
+// delayed is an IP waiting for its due time together with its destination
+type delayed struct {
+	due      time.Time
+	ip       [][]byte
+	response bool
+}
+
+// delayQueue is a min-heap of delayed IPs by due time
+type delayQueue []delayed
+
+func (q delayQueue) Len() int            { return len(q) }
+func (q delayQueue) Less(i, j int) bool  { return q[i].due.Before(q[j].due) }
+func (q delayQueue) Swap(i, j int)       { q[i], q[j] = q[j], q[i] }
+func (q *delayQueue) Push(x interface{}) { *q = append(*q, x.(delayed)) }
+func (q *delayQueue) Pop() interface{} {
+	old := *q
+	d := old[len(old)-1]
+	*q = old[:len(old)-1]
+	return d
+}
+
+// Delay queues an IP until due
+func (q *delayQueue) Delay(ip [][]byte, due time.Time, response bool) {
+	heap.Push(q, delayed{due: due, ip: ip, response: response})
+}
+
+// Release returns IPs due at a given time
+func (q *delayQueue) Release(now time.Time) []delayed {
+	var res []delayed
+	for len(*q) > 0 && !(*q)[0].due.After(now) {
+		res = append(res, heap.Pop(q).(delayed))
+	}
+	return res
+}
+
+// Next returns how long until the next IP is due, ok is false if the queue is empty
+func (q delayQueue) Next(now time.Time) (time.Duration, bool) {
+	if len(q) == 0 {
+		return 0, false
+	}
+	return q[0].due.Sub(now), true
+}
@@ -0,0 +1,361 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"time"
+
+	httputils "github.com/cascades-fbp/cascades-http/utils"
+	"github.com/cascades-fbp/cascades/components/utils"
+	"github.com/cascades-fbp/cascades/runtime"
+	zmq "github.com/pebbe/zmq4"
+)
+
+var (
+	// Flags
+	optionsEndpoint   = flag.String("port.options", "", "Component's options port endpoint")
+	inputEndpoint     = flag.String("port.in", "", "Component's input port endpoint")
+	responseEndpoint  = flag.String("port.response", "", "Component's response port endpoint")
+	outputEndpoint    = flag.String("port.out", "", "Component's output port endpoint")
+	respEndpoint      = flag.String("port.resp", "", "Component's response output port endpoint")
+	errorEndpoint     = flag.String("port.err", "", "Component's error port endpoint")
+	logEndpoint       = flag.String("port.log", "", "Component's log port endpoint")
+	heartbeatEndpoint = flag.String("port.heartbeat", "", "Component's heartbeat port endpoint")
+	metricsAddr       = flag.String("metrics", "", "Address to expose metrics on, i.e. 127.0.0.1:9090 (disabled if empty)")
+	jsonFlag          = flag.Bool("json", false, "Print component documentation in JSON")
+	debug             = flag.Bool("debug", false, "Enable debug mode")
+
+	// Internal
+	optionsPort, inPort, responsePort, outPort, respPort, errPort, logPort, heartbeatPort *zmq.Socket
+	err                                                                                   error
+	logger                                                                                = httputils.NewLogger("http/chaos")
+	liveness                                                                              = httputils.NewLiveness("http/chaos")
+	metrics                                                                               = httputils.NewMetrics(logger, liveness)
+	shutdown                                                                              *httputils.Shutdown
+)
+
+// Responses to truncate are forgotten if they don't arrive within this interval
+const truncateTTL = 5 * time.Minute
+
+// validateArgs checks all required flags
+func validateArgs() {
+	if *optionsEndpoint == "" {
+		flag.Usage()
+		os.Exit(1)
+	}
+	if *inputEndpoint == "" {
+		flag.Usage()
+		os.Exit(1)
+	}
+	if *outputEndpoint == "" {
+		flag.Usage()
+		os.Exit(1)
+	}
+	if *respEndpoint == "" {
+		flag.Usage()
+		os.Exit(1)
+	}
+}
+
+// openPorts create ZMQ sockets
+func openPorts() {
+	optionsPort, err = utils.CreateInputPort("http/chaos.options", *optionsEndpoint, nil)
+	utils.AssertError(err)
+
+	inPort, err = utils.CreateInputPort("http/chaos.in", *inputEndpoint, nil)
+	utils.AssertError(err)
+
+	if *responseEndpoint != "" {
+		responsePort, err = utils.CreateInputPort("http/chaos.response", *responseEndpoint, nil)
+		utils.AssertError(err)
+	}
+
+	outPort, err = utils.CreateOutputPort("http/chaos.out", *outputEndpoint, nil)
+	utils.AssertError(err)
+
+	respPort, err = utils.CreateOutputPort("http/chaos.resp", *respEndpoint, nil)
+	utils.AssertError(err)
+
+	if *errorEndpoint != "" {
+		errPort, err = utils.CreateOutputPort("http/chaos.err", *errorEndpoint, nil)
+		utils.AssertError(err)
+	}
+
+	if *logEndpoint != "" {
+		logPort, err = utils.CreateOutputPort("http/chaos.log", *logEndpoint, nil)
+		utils.AssertError(err)
+		logger.SetSink(func(ip [][]byte) { logPort.SendMessageDontwait(ip) })
+	}
+
+	if *heartbeatEndpoint != "" {
+		heartbeatPort, err = utils.CreateOutputPort("http/chaos.heartbeat", *heartbeatEndpoint, nil)
+		utils.AssertError(err)
+		liveness.Start(func(ip [][]byte) { heartbeatPort.SendMessageDontwait(ip) })
+	}
+}
+
+// closePorts closes all active ports and terminates ZMQ context
+func closePorts() {
+	shutdown.Close(optionsPort, inPort, responsePort)
+	liveness.Stop()
+	shutdown.Flush(outPort, respPort, errPort, heartbeatPort, logPort)
+	zmq.Term()
+}
+
+// configure parses options IP and creates an injector for them
+func configure(data []byte) (*Injector, error) {
+	options := &Options{}
+	if err := json.Unmarshal(data, options); err != nil {
+		return nil, err
+	}
+	if options.Truncate != nil && options.Truncate.Rate > 0 && responsePort == nil {
+		return nil, fmt.Errorf("truncating bodies requires RESPONSE port")
+	}
+	return NewInjector(options)
+}
+
+func main() {
+	flag.Parse()
+
+	if *jsonFlag {
+		doc, _ := registryEntry.JSON()
+		fmt.Println(string(doc))
+		os.Exit(0)
+	}
+
+	log.SetFlags(0)
+	if *debug {
+		logger.SetLevel(httputils.LevelDebug)
+		logger.SetOutput(os.Stdout)
+	}
+	log.SetOutput(logger.Writer())
+
+	validateArgs()
+
+	shutdown = httputils.NewShutdown(logger)
+	openPorts()
+
+	if *metricsAddr != "" {
+		err = metrics.Serve(*metricsAddr)
+		utils.AssertError(err)
+	}
+
+	err = runtime.SetupShutdownByDisconnect(inPort, "http/chaos.in", shutdown.Signals())
+	utils.AssertError(err)
+
+	// Wait for the configuration on the options port
+	var injector *Injector
+	for injector == nil {
+		log.Println("Waiting for configuration...")
+		ip, err := shutdown.Receive(optionsPort)
+		if err == httputils.ErrShutdown {
+			shutdown.Exit(closePorts)
+		}
+		if err != nil {
+			logger.Error("Error receiving IP", "error", err)
+			continue
+		}
+		if !runtime.IsValidIP(ip) || !runtime.IsPacket(ip) {
+			continue
+		}
+		if injector, err = configure(ip[1]); err != nil {
+			logger.Error("Invalid options", "error", err)
+		}
+	}
+
+	var (
+		queue     delayQueue
+		truncates = make(map[string]time.Time)
+		cleaned   = time.Now()
+	)
+
+	// Options port stays open to change or stop experiments at runtime
+	poller := zmq.NewPoller()
+	poller.Add(optionsPort, zmq.POLLIN)
+	poller.Add(inPort, zmq.POLLIN)
+	if responsePort != nil {
+		poller.Add(responsePort, zmq.POLLIN)
+	}
+
+	// Main loop
+	for !shutdown.Stopping() {
+		timeout := shutdown.PollInterval
+		if next, ok := queue.Next(time.Now()); ok && next < timeout {
+			// Negative timeout would block until a message arrives
+			timeout = next
+			if timeout < 0 {
+				timeout = 0
+			}
+		}
+		sockets, err := poller.Poll(timeout)
+		if err != nil {
+			logger.Error("Error polling ports", "error", err)
+			continue
+		}
+
+		for _, socket := range sockets {
+			ip, err := socket.Socket.RecvMessageBytes(0)
+			if err != nil {
+				logger.Error("Error receiving message", "error", err)
+				continue
+			}
+			liveness.Inc()
+			if !httputils.IsValidIP(ip) {
+				logger.Warn("Received invalid IP")
+				continue
+			}
+
+			switch socket.Socket {
+			case optionsPort:
+				if !runtime.IsPacket(ip) {
+					continue
+				}
+				i, err := configure(ip[1])
+				if err != nil {
+					logger.Error("Invalid options, keeping the previous ones", "error", err)
+					continue
+				}
+				injector = i
+				logger.Info("Applied new options")
+
+			case inPort:
+				if !runtime.IsPacket(ip) {
+					outPort.SendMessage(ip)
+					continue
+				}
+				handleRequest(injector, &queue, truncates, ip)
+
+			case responsePort:
+				if !runtime.IsPacket(ip) {
+					respPort.SendMessage(ip)
+					continue
+				}
+				handleResponse(injector, truncates, ip)
+			}
+		}
+
+		now := time.Now()
+		for _, d := range queue.Release(now) {
+			send(d)
+		}
+		if now.Sub(cleaned) > truncateTTL {
+			for id, t := range truncates {
+				if now.Sub(t) > truncateTTL {
+					delete(truncates, id)
+				}
+			}
+			cleaned = now
+		}
+	}
+
+	// Delayed IPs are sent right away instead of being lost
+	for _, d := range queue.Release(time.Now().Add(24 * time.Hour)) {
+		send(d)
+	}
+	shutdown.Exit(closePorts)
+}
+
+// handleRequest injects faults into a request
+func handleRequest(injector *Injector, queue *delayQueue, truncates map[string]time.Time, ip [][]byte) {
+	req, err := httputils.IP2Request(ip)
+	if err != nil {
+		logger.Warn("Failed to convert IP to request", "error", err)
+		sendError(httputils.NewError("http/chaos", httputils.ErrInvalidIP, err))
+		return
+	}
+	f := injector.Decide(req)
+	fault := f.String()
+	if fault == "" {
+		outPort.SendMessage(ip)
+		return
+	}
+	logger.Info("Injected fault", "id", req.ID, "method", req.Method, "uri", httputils.Redaction.URL(req.URI), "fault", fault)
+	if f.Drop {
+		return
+	}
+
+	response := f.Status != 0
+	if response {
+		resp := httputils.NewResponse(f.Status).
+			WithID(req.ID).
+			WithTrace(req.Trace).
+			WithText("%s", injector.options.Error.Body)
+		if injector.options.Annotate {
+			resp.SetHeader(FaultHeader, fault)
+		}
+		ip = resp.MustIP()
+	} else {
+		if f.Truncate {
+			truncates[req.ID] = time.Now()
+		}
+		if injector.options.Annotate {
+			req.SetHeader(FaultHeader, fault)
+			if ip, err = httputils.Request2IP(req); err != nil {
+				logger.Error("Failed to convert request to IP", "error", err)
+				sendError(httputils.NewError("http/chaos", httputils.ErrInternal, err).WithRequest(req.ID))
+				return
+			}
+		}
+	}
+
+	if f.Delay > 0 {
+		queue.Delay(ip, time.Now().Add(f.Delay), response)
+		return
+	}
+	send(delayed{ip: ip, response: response})
+}
+
+// handleResponse truncates bodies of responses selected at request time. The original
+// Content-Length is kept, so clients see an interrupted transfer.
+func handleResponse(injector *Injector, truncates map[string]time.Time, ip [][]byte) {
+	resp, err := httputils.IP2Response(ip)
+	if err != nil {
+		logger.Warn("Failed to convert IP to response", "error", err)
+		sendError(httputils.NewError("http/chaos", httputils.ErrInvalidIP, err))
+		return
+	}
+	if _, ok := truncates[resp.ID]; !ok || injector.options.Disabled || injector.options.Truncate == nil {
+		respPort.SendMessage(ip)
+		return
+	}
+	delete(truncates, resp.ID)
+	if err = resp.Materialize(); err != nil {
+		logger.Warn("Failed to read response body", "id", resp.ID, "error", err)
+		respPort.SendMessage(ip)
+		return
+	}
+	resp.Body = injector.TruncateBody(resp.Body)
+	if injector.options.Annotate {
+		resp.SetHeader(FaultHeader, "truncate")
+	}
+	if ip, err = httputils.Response2IP(resp); err != nil {
+		logger.Error("Failed to convert response to IP", "error", err)
+		sendError(httputils.NewError("http/chaos", httputils.ErrInternal, err).WithRequest(resp.ID))
+		return
+	}
+	respPort.SendMessage(ip)
+}
+
+// send emits a request to OUT or a response to RESP
+func send(d delayed) {
+	if d.response {
+		respPort.SendMessage(d.ip)
+		return
+	}
+	outPort.SendMessage(d.ip)
+}
+
+// sendError reports a failure to the ERR port if it's connected
+func sendError(e *httputils.Error) {
+	if errPort == nil {
+		return
+	}
+	ip, err := httputils.Error2IP(e)
+	if err != nil {
+		return
+	}
+	errPort.SendMessageDontwait(ip)
+}
@@ -0,0 +1,140 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/cascades-fbp/cascades/runtime"
+)
+
+// Credentials are a payload of the AUTH port, i.e. user:pass or JSON object.
+// With host set they are sent only to that host.
+type Credentials struct {
+	Username string `json:"username"`
+	Password string `json:"password"`
+	Host     string `json:"host"`
+}
+
+// Token is a JSON payload of the TOKEN port, i.e. issued by http/oauth2
+type Token struct {
+	AccessToken string `json:"access_token"`
+	TokenType   string `json:"token_type"`
+	ExpiresAt   string `json:"expires_at"` // RFC 3339 time, optional
+}
+
+// bearerToken is the token sent with requests until a new one arrives
+type bearerToken struct {
+	value     string
+	expires   time.Time
+	refreshed bool // Refresh was requested already
+}
+
+var (
+	basicAuth *Credentials // Set by the AUTH port, nil sends no credentials
+	bearer    *bearerToken // Set by the TOKEN port, takes precedence over credentials
+)
+
+// setAuth changes Basic credentials of requests to an AUTH payload, empty one
+// removes them
+func setAuth(payload []byte) error {
+	payload = bytes.TrimSpace(payload)
+	if len(payload) == 0 {
+		basicAuth = nil
+		return nil
+	}
+	c := &Credentials{}
+	if payload[0] == '{' {
+		if err := json.Unmarshal(payload, c); err != nil {
+			return err
+		}
+	} else {
+		i := bytes.IndexByte(payload, ':')
+		if i < 0 {
+			return fmt.Errorf("credentials are not in user:pass format")
+		}
+		c.Username, c.Password = string(payload[:i]), string(payload[i+1:])
+	}
+	if c.Username == "" {
+		return fmt.Errorf("credentials without username")
+	}
+	if strings.Contains(c.Username, ":") {
+		return fmt.Errorf("username contains colon")
+	}
+	basicAuth = c
+	return nil
+}
+
+// setToken replaces the bearer token of requests with a TOKEN payload, a raw
+// token or JSON object, empty one removes it
+func setToken(payload []byte) error {
+	payload = bytes.TrimSpace(payload)
+	if len(payload) == 0 {
+		bearer = nil
+		return nil
+	}
+	t := &bearerToken{value: string(payload)}
+	if payload[0] == '{' {
+		var token Token
+		if err := json.Unmarshal(payload, &token); err != nil {
+			return err
+		}
+		if token.TokenType != "" && !strings.EqualFold(token.TokenType, "bearer") {
+			return fmt.Errorf("unsupported token type %q", token.TokenType)
+		}
+		if token.ExpiresAt != "" {
+			expires, err := time.Parse(time.RFC3339, token.ExpiresAt)
+			if err != nil {
+				return fmt.Errorf("invalid expires_at: %v", err)
+			}
+			t.expires = expires
+		}
+		t.value = token.AccessToken
+	}
+	if t.value == "" || strings.ContainsAny(t.value, " \t\r\n") {
+		return fmt.Errorf("invalid token")
+	}
+	bearer = t
+	return nil
+}
+
+// requestRefresh asks for a new token on the REFRESH port once per token,
+// i.e. when it expired or a server rejected it
+func requestRefresh(reason string) {
+	if bearer == nil || bearer.refreshed {
+		return
+	}
+	bearer.refreshed = true
+	logger.Warn("Bearer token needs to be refreshed", "reason", reason)
+	if refreshPort == nil {
+		return
+	}
+	payload, _ := json.Marshal(map[string]string{"reason": reason})
+	refreshPort.SendMessageDontwait(runtime.NewPacket(payload))
+}
+
+// authorize sets Authorization header of a request without one from the token
+// or credentials received last. It returns true if the token was sent.
+func authorize(request *http.Request) bool {
+	if request.Header.Get("Authorization") != "" {
+		return false
+	}
+	if bearer != nil {
+		if !bearer.expires.IsZero() && time.Now().After(bearer.expires) {
+			requestRefresh("expired")
+		}
+		request.Header.Set("Authorization", "Bearer "+bearer.value)
+		return true
+	}
+	if basicAuth == nil {
+		return false
+	}
+	if basicAuth.Host != "" && !strings.EqualFold(basicAuth.Host, request.URL.Host) && !strings.EqualFold(basicAuth.Host, request.URL.Hostname()) {
+		return false
+	}
+	request.SetBasicAuth(basicAuth.Username, basicAuth.Password)
+	return false
+}
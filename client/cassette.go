@@ -0,0 +1,302 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	httputils "github.com/cascades-fbp/cascades-http/utils"
+)
+
+// Cassette record modes
+const (
+	RecordOnce = "once" // Record if the cassette doesn't exist yet, replay only otherwise
+	RecordNone = "none" // Replay only
+	RecordAll  = "all"  // Always perform requests and record a new cassette
+	RecordNew  = "new"  // Replay matching interactions and record the others
+)
+
+// errCassetteMiss is returned when no recorded interaction matches a request in replay only mode
+var errCassetteMiss = errors.New("no matching interaction in cassette")
+
+// matchers compare a live request with a recorded one
+var matchers = map[string]func(live, recorded *httputils.HTTPRequest) bool{
+	"method": func(live, recorded *httputils.HTTPRequest) bool {
+		return strings.EqualFold(live.Method, recorded.Method)
+	},
+	"url": func(live, recorded *httputils.HTTPRequest) bool {
+		a, b := parseURL(live.URI), parseURL(recorded.URI)
+		return a.Scheme == b.Scheme && a.Host == b.Host && a.Path == b.Path && sameQuery(a, b)
+	},
+	"host": func(live, recorded *httputils.HTTPRequest) bool {
+		return parseURL(live.URI).Host == parseURL(recorded.URI).Host
+	},
+	"path": func(live, recorded *httputils.HTTPRequest) bool {
+		return parseURL(live.URI).Path == parseURL(recorded.URI).Path
+	},
+	"query": func(live, recorded *httputils.HTTPRequest) bool {
+		return sameQuery(parseURL(live.URI), parseURL(recorded.URI))
+	},
+	"body": func(live, recorded *httputils.HTTPRequest) bool {
+		return sameBody(live.Body, recorded.Body)
+	},
+}
+
+// Cassette is a round tripper recording exchanges to a HAR file and replaying them
+// without network access. Recorded secrets are redacted as in dumps, so matching
+// on them isn't possible.
+type Cassette struct {
+	path  string
+	mode  string
+	rules []string
+	next  http.RoundTripper
+
+	mu        sync.Mutex
+	exchanges []httputils.HTTPExchange
+	used      []bool
+}
+
+// OpenCassette loads a cassette file, match is a comma separated list of rules
+// (method, url, host, path, query, body)
+func OpenCassette(path, mode, match string, next http.RoundTripper) (*Cassette, error) {
+	c := &Cassette{path: path, mode: mode, next: next}
+	for _, rule := range strings.Split(match, ",") {
+		rule = strings.TrimSpace(rule)
+		if rule == "" {
+			continue
+		}
+		if _, ok := matchers[rule]; !ok {
+			return nil, fmt.Errorf("unknown match rule %q", rule)
+		}
+		c.rules = append(c.rules, rule)
+	}
+
+	switch mode {
+	case RecordAll:
+		return c, nil
+	case RecordOnce, RecordNone, RecordNew:
+	default:
+		return nil, fmt.Errorf("unknown record mode %q", mode)
+	}
+	data, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) && mode != RecordNone {
+		if mode == RecordOnce {
+			c.mode = RecordAll
+		}
+		return c, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if c.exchanges, err = httputils.HAR2Exchanges(data); err != nil {
+		return nil, fmt.Errorf("parsing cassette: %w", err)
+	}
+	c.used = make([]bool, len(c.exchanges))
+	if mode == RecordOnce {
+		c.mode = RecordNone
+	}
+	return c, nil
+}
+
+// Mode returns the effective record mode
+func (c *Cassette) Mode() string {
+	return c.mode
+}
+
+// Len returns the number of interactions in the cassette
+func (c *Cassette) Len() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return len(c.exchanges)
+}
+
+// RoundTrip replays a matching interaction or performs and records the request
+func (c *Cassette) RoundTrip(request *http.Request) (*http.Response, error) {
+	var body []byte
+	if request.Body != nil {
+		var err error
+		if body, err = ioutil.ReadAll(request.Body); err != nil {
+			return nil, err
+		}
+		request.Body.Close()
+		request.Body = ioutil.NopCloser(bytes.NewReader(body))
+	}
+	req := &httputils.HTTPRequest{
+		Method: request.Method,
+		URI:    request.URL.String(),
+		Header: request.Header,
+		Body:   body,
+	}
+
+	if c.mode != RecordAll {
+		if resp := c.replay(req); resp != nil {
+			logger.Debug("Replayed interaction from cassette", "method", req.Method, "url", httputils.Redaction.URL(req.URI))
+			return c.response(request, resp), nil
+		}
+		if c.mode == RecordNone {
+			return nil, errCassetteMiss
+		}
+	}
+
+	started := time.Now()
+	response, err := c.next.RoundTrip(request)
+	if err != nil {
+		return nil, err
+	}
+	data, err := ioutil.ReadAll(response.Body)
+	response.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+	response.Body = ioutil.NopCloser(bytes.NewReader(data))
+
+	resp := &httputils.HTTPResponse{
+		StatusCode: response.StatusCode,
+		Header:     response.Header,
+		Trailer:    response.Trailer,
+		Body:       data,
+	}
+	if err = c.record(httputils.HTTPExchange{Request: req, Response: resp, Started: started, Duration: time.Since(started)}); err != nil {
+		logger.Error("Failed to save cassette", "file", c.path, "error", err)
+	}
+	return response, nil
+}
+
+// replay finds the first unused matching interaction, the last used one is
+// repeated once all matching interactions were played
+func (c *Cassette) replay(req *httputils.HTTPRequest) *httputils.HTTPResponse {
+	live := httputils.Redaction.Request(req)
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	found := -1
+	for i, e := range c.exchanges {
+		if !c.matches(live, e.Request) {
+			continue
+		}
+		found = i
+		if !c.used[i] {
+			break
+		}
+	}
+	if found < 0 {
+		return nil
+	}
+	c.used[found] = true
+	return c.exchanges[found].Response
+}
+
+func (c *Cassette) matches(live, recorded *httputils.HTTPRequest) bool {
+	for _, rule := range c.rules {
+		if !matchers[rule](live, recorded) {
+			return false
+		}
+	}
+	return true
+}
+
+// record appends an exchange and rewrites the cassette file atomically
+func (c *Cassette) record(e httputils.HTTPExchange) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.exchanges = append(c.exchanges, e)
+	c.used = append(c.used, true)
+
+	data, err := httputils.Exchanges2HAR(c.exchanges)
+	if err != nil {
+		return err
+	}
+	tmp, err := ioutil.TempFile(filepath.Dir(c.path), filepath.Base(c.path)+".*")
+	if err != nil {
+		return err
+	}
+	if _, err = tmp.Write(data); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return err
+	}
+	if err = tmp.Close(); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+	return os.Rename(tmp.Name(), c.path)
+}
+
+// response builds an HTTP response of a recorded one
+func (c *Cassette) response(request *http.Request, resp *httputils.HTTPResponse) *http.Response {
+	header := http.Header(resp.Header).Clone()
+	return &http.Response{
+		Status:        fmt.Sprintf("%d %s", resp.StatusCode, http.StatusText(resp.StatusCode)),
+		StatusCode:    resp.StatusCode,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        header,
+		Body:          ioutil.NopCloser(bytes.NewReader(resp.Body)),
+		ContentLength: int64(len(resp.Body)),
+		Request:       request,
+	}
+}
+
+func parseURL(raw string) *url.URL {
+	u, err := url.Parse(raw)
+	if err != nil {
+		return &url.URL{Path: raw}
+	}
+	return u
+}
+
+// sameQuery compares query parameters regardless of their order
+func sameQuery(a, b *url.URL) bool {
+	qa, qb := a.Query(), b.Query()
+	if len(qa) != len(qb) {
+		return false
+	}
+	for k, va := range qa {
+		vb := qb[k]
+		if len(va) != len(vb) {
+			return false
+		}
+		for i := range va {
+			if va[i] != vb[i] {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// sameBody compares JSON bodies semantically and other bodies byte by byte
+func sameBody(a, b []byte) bool {
+	if bytes.Equal(a, b) {
+		return true
+	}
+	var va, vb interface{}
+	if json.Unmarshal(a, &va) != nil || json.Unmarshal(b, &vb) != nil {
+		return false
+	}
+	ca, _ := json.Marshal(va)
+	cb, _ := json.Marshal(vb)
+	return bytes.Equal(ca, cb)
+}
+
+// cassette wraps a transport if -cassette flag is set
+func cassette(tr http.RoundTripper) (http.RoundTripper, error) {
+	if *cassetteFile == "" {
+		return tr, nil
+	}
+	c, err := OpenCassette(*cassetteFile, *recordMode, *matchRules, tr)
+	if err != nil {
+		return nil, err
+	}
+	logger.Info("Opened cassette", "file", *cassetteFile, "mode", c.Mode(), "interactions", c.Len())
+	return c, nil
+}
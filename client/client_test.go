@@ -0,0 +1,262 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/cascades-fbp/cascades-http/testutils"
+	httputils "github.com/cascades-fbp/cascades-http/utils"
+	"github.com/cascades-fbp/cascades/runtime"
+	zmq "github.com/pebbe/zmq4"
+)
+
+// readyTimeout is how long a test waits for an IP of the client
+const readyTimeout = 30 * time.Second
+
+// clientGraph is a running client with sockets of its ports
+type clientGraph struct {
+	in       *zmq.Socket
+	defaults *zmq.Socket
+	resp     *zmq.Socket
+	body     *zmq.Socket
+	err      *zmq.Socket
+}
+
+// startClient runs the client with given flags
+func startClient(t testing.TB, args ...string) *clientGraph {
+	t.Helper()
+	g := testutils.NewGraph(t)
+	cg := &clientGraph{
+		resp: g.Output(g.Endpoint("resp")),
+		body: g.Output(g.Endpoint("body")),
+		err:  g.Output(g.Endpoint("err")),
+	}
+	g.Start("client", map[string]string{
+		"req":      g.Endpoint("req"),
+		"defaults": g.Endpoint("defaults"),
+		"resp":     g.Endpoint("resp"),
+		"body":     g.Endpoint("body"),
+		"err":      g.Endpoint("err"),
+	}, args...)
+	cg.in = g.Input(g.Endpoint("req"))
+	cg.defaults = g.Input(g.Endpoint("defaults"))
+	return cg
+}
+
+// send sends request options to REQ
+func (cg *clientGraph) send(t testing.TB, options *httputils.HTTPClientOptions) {
+	t.Helper()
+	payload, err := json.Marshal(options)
+	if err != nil {
+		t.Fatalf("Failed to marshal request options: %v", err)
+	}
+	if _, err = cg.in.SendMessage(runtime.NewPacket(payload)); err != nil {
+		t.Fatalf("Failed to send request options: %v", err)
+	}
+}
+
+// response waits for a response on RESP
+func (cg *clientGraph) response(t testing.TB) *httputils.HTTPResponse {
+	t.Helper()
+	resp, err := httputils.IP2Response(testutils.Receive(t, cg.resp, readyTimeout))
+	if err != nil {
+		t.Fatalf("IP2Response() error = %v", err)
+	}
+	return resp
+}
+
+// testServer serves JSON on /users, redirects /old to /users and fails on /fail
+func testServer(t testing.TB) *httptest.Server {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/users", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("X-Method", r.Method)
+		fmt.Fprint(w, `{"ok":true}`)
+	})
+	mux.HandleFunc("/old", func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, "/users", http.StatusFound)
+	})
+	mux.HandleFunc("/fail", func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "unavailable", http.StatusServiceUnavailable)
+	})
+	srv := httptest.NewServer(mux)
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func TestClientResponse(t *testing.T) {
+	if testing.Short() {
+		t.Skip("runs the client binary")
+	}
+	srv := testServer(t)
+	cg := startClient(t)
+
+	tests := []struct {
+		method    string
+		path      string
+		status    int
+		url       string
+		redirects []string
+	}{
+		{"GET", "/users", http.StatusOK, srv.URL + "/users", nil},
+		{"POST", "/users", http.StatusOK, srv.URL + "/users", nil},
+		{"GET", "/old", http.StatusOK, srv.URL + "/users", []string{srv.URL + "/old"}},
+		{"GET", "/fail", http.StatusServiceUnavailable, srv.URL + "/fail", nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.method+" "+tt.path, func(t *testing.T) {
+			cg.send(t, &httputils.HTTPClientOptions{URL: srv.URL + tt.path, Method: tt.method})
+			resp := cg.response(t)
+			if resp.StatusCode != tt.status || resp.URL != tt.url {
+				t.Errorf("response = %d from %s, want %d from %s", resp.StatusCode, resp.URL, tt.status, tt.url)
+			}
+			if fmt.Sprint(resp.Redirects) != fmt.Sprint(tt.redirects) {
+				t.Errorf("redirects = %v, want %v", resp.Redirects, tt.redirects)
+			}
+			body := testutils.Receive(t, cg.body, readyTimeout)
+			if len(body) < 2 || string(body[1]) != string(resp.Body) {
+				t.Errorf("BODY = %q, want %q", body, resp.Body)
+			}
+		})
+	}
+}
+
+func TestClientBody(t *testing.T) {
+	if testing.Short() {
+		t.Skip("runs the client binary")
+	}
+	srv := testServer(t)
+	cg := startClient(t)
+
+	cg.send(t, &httputils.HTTPClientOptions{URL: srv.URL + "/users", Method: "PUT"})
+	resp := cg.response(t)
+	if string(resp.Body) != `{"ok":true}` {
+		t.Errorf("body = %q", resp.Body)
+	}
+	if m := resp.GetHeader("X-Method"); m != "PUT" {
+		t.Errorf("server received %s, want PUT", m)
+	}
+	var v map[string]bool
+	if err := resp.DecodeJSON(&v); err != nil || !v["ok"] {
+		t.Errorf("JSON body = %v, error = %v", v, err)
+	}
+}
+
+func TestClientBinary(t *testing.T) {
+	if testing.Short() {
+		t.Skip("runs the client binary")
+	}
+	srv := testServer(t)
+	cg := startClient(t, "-binary")
+
+	cg.send(t, &httputils.HTTPClientOptions{URL: srv.URL + "/users", Method: "GET"})
+	ip := testutils.Receive(t, cg.resp, readyTimeout)
+	if !httputils.IsFramedIP(ip) {
+		t.Fatalf("RESP = %q, want framed IP", ip)
+	}
+	resp, err := httputils.IP2Response(ip)
+	if err != nil {
+		t.Fatalf("IP2Response() error = %v", err)
+	}
+	if resp.StatusCode != http.StatusOK || string(resp.Body) != `{"ok":true}` {
+		t.Errorf("response = %d %q", resp.StatusCode, resp.Body)
+	}
+
+	body := testutils.Receive(t, cg.body, readyTimeout)
+	if len(body) != 3 || string(body[2]) != `{"ok":true}` {
+		t.Fatalf("BODY = %q, want raw body frame", body)
+	}
+	var meta BodyFrame
+	if err = json.Unmarshal(body[1], &meta); err != nil {
+		t.Fatalf("BODY metadata %q: %v", body[1], err)
+	}
+	if meta.Status != http.StatusOK || meta.Type != "application/json" || meta.URL != srv.URL+"/users" {
+		t.Errorf("BODY metadata = %+v", meta)
+	}
+}
+
+func TestClientDefaults(t *testing.T) {
+	if testing.Short() {
+		t.Skip("runs the client binary")
+	}
+	srv := testServer(t)
+	cg := startClient(t)
+
+	// Defaults are applied before requests received later, wait for one to be
+	// sure they're read
+	cg.send(t, &httputils.HTTPClientOptions{URL: srv.URL + "/users", Method: "GET"})
+	cg.response(t)
+	testutils.Receive(t, cg.body, readyTimeout)
+	if _, err := cg.defaults.SendMessage(runtime.NewPacket([]byte(`{"follow_redirects": false, "timeout": "5s"}`))); err != nil {
+		t.Fatalf("Failed to send defaults: %v", err)
+	}
+
+	deadline := time.Now().Add(readyTimeout)
+	for {
+		cg.send(t, &httputils.HTTPClientOptions{URL: srv.URL + "/old", Method: "GET"})
+		resp := cg.response(t)
+		testutils.Receive(t, cg.body, readyTimeout)
+		if resp.StatusCode == http.StatusFound {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("Redirects are still followed after %v", readyTimeout)
+		}
+	}
+
+	follow := true
+	cg.send(t, &httputils.HTTPClientOptions{URL: srv.URL + "/old", Method: "GET", FollowRedirects: &follow})
+	if resp := cg.response(t); resp.StatusCode != http.StatusOK {
+		t.Errorf("follow_redirects of the request: status = %d, want %d", resp.StatusCode, http.StatusOK)
+	}
+}
+
+func TestClientError(t *testing.T) {
+	if testing.Short() {
+		t.Skip("runs the client binary")
+	}
+	srv := testServer(t)
+	url := srv.URL
+	srv.Close()
+	cg := startClient(t)
+
+	cg.send(t, &httputils.HTTPClientOptions{URL: url + "/users", Method: "GET"})
+	e, err := httputils.IP2Error(testutils.Receive(t, cg.err, readyTimeout))
+	if err != nil {
+		t.Fatalf("IP2Error() error = %v", err)
+	}
+	if e.Component != "http/client" || e.Category != httputils.ErrNetwork {
+		t.Errorf("error = %+v, want network error of http/client", e)
+	}
+}
+
+func BenchmarkClient(b *testing.B) {
+	srv := testServer(b)
+	cg := startClient(b)
+	options := &httputils.HTTPClientOptions{URL: srv.URL + "/users", Method: "GET"}
+
+	// Client polls REQ port with pauses when it's empty, warm it up first
+	cg.send(b, options)
+	cg.response(b)
+	testutils.Receive(b, cg.body, readyTimeout)
+
+	payload, _ := json.Marshal(options)
+	ip := runtime.NewPacket(payload)
+	b.ResetTimer()
+	go func() {
+		for i := 0; i < b.N; i++ {
+			if _, err := cg.in.SendMessage(ip); err != nil {
+				b.Error(err)
+				return
+			}
+		}
+	}()
+	for i := 0; i < b.N; i++ {
+		testutils.Receive(b, cg.resp, readyTimeout)
+		testutils.Receive(b, cg.body, readyTimeout)
+	}
+}
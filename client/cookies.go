@@ -0,0 +1,122 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/cookiejar"
+	"net/url"
+	"strings"
+	"sync"
+
+	httputils "github.com/cascades-fbp/cascades-http/utils"
+	"github.com/cascades-fbp/cascades/runtime"
+	"golang.org/x/net/publicsuffix"
+)
+
+// CookieSet is a payload of COOKIES ports, cookies of a given URL. Seeds may
+// omit url if every cookie has a domain.
+type CookieSet struct {
+	URL     string              `json:"url,omitempty"`
+	Cookies []*httputils.Cookie `json:"cookies"`
+}
+
+// Jar keeps cookies between requests and records cookies set by responses,
+// including redirects, until they are taken
+type Jar struct {
+	jar *cookiejar.Jar
+	mu  sync.Mutex
+	set []CookieSet
+}
+
+// NewJar creates an empty jar, cookies for public suffixes are rejected
+func NewJar() *Jar {
+	jar, _ := cookiejar.New(&cookiejar.Options{PublicSuffixList: publicsuffix.List})
+	return &Jar{jar: jar}
+}
+
+// SetCookies implements http.CookieJar
+func (j *Jar) SetCookies(u *url.URL, cookies []*http.Cookie) {
+	j.jar.SetCookies(u, cookies)
+	if len(cookies) == 0 {
+		return
+	}
+	s := CookieSet{URL: u.String()}
+	for _, c := range cookies {
+		s.Cookies = append(s.Cookies, httputils.NewCookie(c))
+	}
+	j.mu.Lock()
+	j.set = append(j.set, s)
+	j.mu.Unlock()
+}
+
+// Cookies implements http.CookieJar
+func (j *Jar) Cookies(u *url.URL) []*http.Cookie {
+	return j.jar.Cookies(u)
+}
+
+// Seed stores cookies of a COOKIES payload without recording them
+func (j *Jar) Seed(payload []byte) error {
+	var s CookieSet
+	if err := json.Unmarshal(payload, &s); err != nil {
+		return err
+	}
+	if len(s.Cookies) == 0 {
+		return fmt.Errorf("no cookies")
+	}
+	var base *url.URL
+	if s.URL != "" {
+		u, err := url.Parse(s.URL)
+		if err != nil || u.Host == "" {
+			return fmt.Errorf("invalid url %q", s.URL)
+		}
+		base = u
+	}
+	for _, c := range s.Cookies {
+		if c.Name == "" {
+			return fmt.Errorf("cookie without name")
+		}
+		u := base
+		if u == nil {
+			if c.Domain == "" {
+				return fmt.Errorf("cookie %s has neither url nor domain", c.Name)
+			}
+			u = &url.URL{Scheme: "https", Host: strings.TrimPrefix(c.Domain, "."), Path: c.Path}
+		}
+		j.jar.SetCookies(u, []*http.Cookie{c.HTTP()})
+	}
+	return nil
+}
+
+// Take returns cookies set by responses since the last call
+func (j *Jar) Take() []CookieSet {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	set := j.set
+	j.set = nil
+	return set
+}
+
+// cookiesEnabled checks if requests use the cookie jar, with -cookies flag or
+// any of the cookie ports
+func cookiesEnabled() bool {
+	return *cookiesFlag || *cookiesEndpoint != "" || *setCookieEndpoint != ""
+}
+
+// sendCookies emits cookies set by responses to the SETCOOKIE port if it's connected
+func sendCookies() {
+	if jar == nil {
+		return
+	}
+	for _, s := range jar.Take() {
+		if setCookiePort == nil {
+			continue
+		}
+		payload, err := json.Marshal(s)
+		if err != nil {
+			logger.Error("Failed to marshal cookies", "error", err)
+			continue
+		}
+		setCookiePort.SendMessage(runtime.NewPacket(payload))
+	}
+}
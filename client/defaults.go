@@ -0,0 +1,88 @@
+package main
+
+import (
+	"crypto/tls"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	httputils "github.com/cascades-fbp/cascades-http/utils"
+)
+
+// Defaults is a payload of the DEFAULTS port changing settings of requests
+// which don't override them. Omitted fields keep their current values.
+type Defaults struct {
+	Timeout         *httputils.Duration `json:"timeout"`          // Timeout of requests, 0 for none
+	FollowRedirects *bool               `json:"follow_redirects"` // Follow redirects, true by default
+	MaxBody         *int64              `json:"max_body"`         // Limit of response bodies overriding max_body_size, -1 for none
+	TLSVerify       *bool               `json:"tls_verify"`       // Verify server certificates
+	Proxy           *string             `json:"proxy"`            // Proxy URL or none, empty falls back to -proxy flag
+}
+
+var (
+	followRedirects = true
+	portMaxBody     *int64 // Set by the DEFAULTS port, takes precedence over options
+	portTLSVerify   *bool  // Set by the DEFAULTS port, takes precedence over options and flags
+)
+
+// applyDefaults changes defaults of requests to a DEFAULTS payload. Nothing is
+// changed if any of its fields is invalid.
+func applyDefaults(payload []byte, client *http.Client, tr *http.Transport) error {
+	var d Defaults
+	if err := json.Unmarshal(payload, &d); err != nil {
+		return err
+	}
+	if d.Timeout != nil && *d.Timeout < 0 {
+		return fmt.Errorf("negative timeout")
+	}
+	if d.Proxy != nil && *d.Proxy != "" {
+		if _, err := proxyFunc(*d.Proxy); err != nil {
+			return err
+		}
+	}
+
+	if d.Proxy != nil {
+		if err := setProxy([]byte(*d.Proxy), tr); err != nil {
+			return err
+		}
+	}
+	if d.Timeout != nil {
+		timeout := time.Duration(*d.Timeout)
+		portTimeout = &timeout
+		client.Timeout = timeout
+	}
+	if d.FollowRedirects != nil {
+		followRedirects = *d.FollowRedirects
+	}
+	if d.MaxBody != nil {
+		portMaxBody = d.MaxBody
+		maxBodySize = *d.MaxBody
+	}
+	if d.TLSVerify != nil {
+		portTLSVerify = d.TLSVerify
+		applyTLSVerify(tr)
+	}
+	return nil
+}
+
+// applyTLSVerify sets verification of server certificates by the DEFAULTS port
+// on a transport
+func applyTLSVerify(tr *http.Transport) {
+	if portTLSVerify == nil {
+		return
+	}
+	cfg := &tls.Config{}
+	if tr.TLSClientConfig != nil {
+		cfg = tr.TLSClientConfig.Clone()
+	}
+	cfg.InsecureSkipVerify = !*portTLSVerify
+	if cfg.InsecureSkipVerify {
+		logger.Warn("Verification of server certificates is disabled")
+	}
+	tr.TLSClientConfig = cfg
+	tr.CloseIdleConnections()
+	if transports != nil {
+		transports.Reset()
+	}
+}
@@ -0,0 +1,363 @@
+package main
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"net"
+	"net/http/httptrace"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	httputils "github.com/cascades-fbp/cascades-http/utils"
+)
+
+// DNSSection describes DNS caching in the client section of options, lookups
+// aren't cached if the section is missing
+type DNSSection struct {
+	MinTTL      httputils.Duration  `json:"min_ttl"`      // Answers are cached at least this long, 1s by default
+	MaxTTL      httputils.Duration  `json:"max_ttl"`      // Answers are cached at most this long, 5m by default
+	NegativeTTL httputils.Duration  `json:"negative_ttl"` // Unknown hosts are cached this long, 5s by default (disabled if negative)
+	Overrides   map[string][]string `json:"overrides"`    // Fixed addresses of hosts bypassing DNS, i.e. {"api.example.com": ["10.0.0.1"]}
+}
+
+const (
+	defaultMinTTL      = time.Second
+	defaultMaxTTL      = 5 * time.Minute
+	defaultNegativeTTL = 5 * time.Second
+	maxDNSEntries      = 4096
+)
+
+// dnsEntry is a cached answer, err is set for negative entries
+type dnsEntry struct {
+	addrs   []net.IPAddr
+	err     error
+	expires time.Time
+	ready   chan struct{}
+}
+
+// DNSCache resolves hosts of dialed addresses caching answers for their TTL.
+// TTLs are taken from DNS responses read by the Go resolver, answers without one
+// (i.e. from /etc/hosts) are cached for the minimum TTL.
+type DNSCache struct {
+	resolver *net.Resolver
+	dialer   net.Dialer
+
+	mu      sync.Mutex
+	section *DNSSection
+	entries map[string]*dnsEntry
+
+	hits, misses, negative int64
+}
+
+// NewDNSCache creates a disabled cache, lookups are cached once a section is applied
+func NewDNSCache() *DNSCache {
+	c := &DNSCache{entries: make(map[string]*dnsEntry)}
+	c.resolver = &net.Resolver{PreferGo: true, Dial: c.dialDNS}
+	return c
+}
+
+// Apply enables caching with a section or disables it if section is nil, cached
+// answers are dropped either way
+func (c *DNSCache) Apply(section *DNSSection) {
+	if section != nil {
+		s := *section
+		if s.MinTTL <= 0 {
+			s.MinTTL = httputils.Duration(defaultMinTTL)
+		}
+		if s.MaxTTL <= 0 {
+			s.MaxTTL = httputils.Duration(defaultMaxTTL)
+		}
+		if s.MaxTTL < s.MinTTL {
+			s.MaxTTL = s.MinTTL
+		}
+		if s.NegativeTTL == 0 {
+			s.NegativeTTL = httputils.Duration(defaultNegativeTTL)
+		}
+		section = &s
+	}
+	c.mu.Lock()
+	c.section = section
+	c.entries = make(map[string]*dnsEntry)
+	c.mu.Unlock()
+}
+
+// Watch registers cache metrics
+func (c *DNSCache) Watch(m *httputils.Metrics) {
+	m.CounterFunc("cascades_http_dns_cache_hits_total", "Number of lookups answered from the DNS cache", func() float64 {
+		return float64(atomic.LoadInt64(&c.hits))
+	})
+	m.CounterFunc("cascades_http_dns_cache_misses_total", "Number of lookups sent to DNS", func() float64 {
+		return float64(atomic.LoadInt64(&c.misses))
+	})
+	m.CounterFunc("cascades_http_dns_cache_negative_hits_total", "Number of lookups of unknown hosts answered from the DNS cache", func() float64 {
+		return float64(atomic.LoadInt64(&c.negative))
+	})
+	m.GaugeFunc("cascades_http_dns_cache_entries", "Number of hosts in the DNS cache", func() float64 {
+		c.mu.Lock()
+		defer c.mu.Unlock()
+		return float64(len(c.entries))
+	})
+}
+
+// DialContext dials an address resolving its host through the cache, addresses
+// of a host are tried in order until one connects
+func (c *DNSCache) DialContext(ctx context.Context, network, address string) (net.Conn, error) {
+	c.mu.Lock()
+	section := c.section
+	c.mu.Unlock()
+	host, port, err := net.SplitHostPort(address)
+	if section == nil || err != nil || net.ParseIP(host) != nil {
+		return c.dialer.DialContext(ctx, network, address)
+	}
+
+	trace := httptrace.ContextClientTrace(ctx)
+	if trace != nil && trace.DNSStart != nil {
+		trace.DNSStart(httptrace.DNSStartInfo{Host: host})
+	}
+	addrs, err := c.lookup(ctx, section, host)
+	if trace != nil && trace.DNSDone != nil {
+		trace.DNSDone(httptrace.DNSDoneInfo{Addrs: addrs, Err: err})
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var first error
+	for _, addr := range addrs {
+		conn, err := c.dialer.DialContext(ctx, network, net.JoinHostPort(addr.String(), port))
+		if err == nil {
+			return conn, nil
+		}
+		if first == nil {
+			first = err
+		}
+		if ctx.Err() != nil {
+			break
+		}
+	}
+	return nil, first
+}
+
+// lookup returns addresses of a host, concurrent lookups of the same host wait
+// for the first one
+func (c *DNSCache) lookup(ctx context.Context, section *DNSSection, host string) ([]net.IPAddr, error) {
+	if fixed, ok := section.Overrides[host]; ok {
+		addrs := make([]net.IPAddr, 0, len(fixed))
+		for _, a := range fixed {
+			if ip := net.ParseIP(a); ip != nil {
+				addrs = append(addrs, net.IPAddr{IP: ip})
+			}
+		}
+		if len(addrs) > 0 {
+			return addrs, nil
+		}
+	}
+
+	now := time.Now()
+	c.mu.Lock()
+	if e, ok := c.entries[host]; ok {
+		c.mu.Unlock()
+		select {
+		case <-e.ready:
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+		if now.Before(e.expires) {
+			if e.err != nil {
+				atomic.AddInt64(&c.negative, 1)
+				return nil, e.err
+			}
+			atomic.AddInt64(&c.hits, 1)
+			return e.addrs, nil
+		}
+		c.mu.Lock()
+		if c.entries[host] == e {
+			delete(c.entries, host)
+		}
+		c.mu.Unlock()
+		return c.lookup(ctx, section, host)
+	}
+	e := &dnsEntry{ready: make(chan struct{})}
+	if len(c.entries) >= maxDNSEntries {
+		c.sweep(now)
+	}
+	c.entries[host] = e
+	c.mu.Unlock()
+
+	atomic.AddInt64(&c.misses, 1)
+	ttl := &answerTTL{}
+	// Lookups are detached from the request, so a cancelled one doesn't fail
+	// requests waiting for the same host
+	lctx, cancel := context.WithTimeout(withAnswerTTL(context.Background(), ttl), 10*time.Second)
+	addrs, err := c.resolver.LookupIPAddr(lctx, host)
+	cancel()
+
+	e.addrs, e.err = addrs, err
+	var d time.Duration
+	var dnsErr *net.DNSError
+	switch {
+	case err == nil:
+		d = ttl.get()
+		if d < time.Duration(section.MinTTL) {
+			d = time.Duration(section.MinTTL)
+		}
+		if d > time.Duration(section.MaxTTL) {
+			d = time.Duration(section.MaxTTL)
+		}
+	case errors.As(err, &dnsErr) && dnsErr.IsNotFound && section.NegativeTTL > 0:
+		d = time.Duration(section.NegativeTTL)
+	}
+	e.expires = time.Now().Add(d)
+	close(e.ready)
+	if d == 0 {
+		c.mu.Lock()
+		if c.entries[host] == e {
+			delete(c.entries, host)
+		}
+		c.mu.Unlock()
+	}
+	logger.Debug("Resolved host", "host", host, "addresses", len(addrs), "ttl", d.String(), "error", err)
+	return addrs, err
+}
+
+// sweep drops expired entries, it's called with the lock held
+func (c *DNSCache) sweep(now time.Time) {
+	for host, e := range c.entries {
+		select {
+		case <-e.ready:
+			if !now.Before(e.expires) {
+				delete(c.entries, host)
+			}
+		default:
+		}
+	}
+}
+
+// dialDNS connects to a name server for the resolver, answers read from the
+// connection report their TTL to the lookup in progress
+func (c *DNSCache) dialDNS(ctx context.Context, network, address string) (net.Conn, error) {
+	conn, err := c.dialer.DialContext(ctx, network, address)
+	if err != nil {
+		return nil, err
+	}
+	ttl, _ := ctx.Value(answerTTLKey{}).(*answerTTL)
+	if ttl == nil {
+		return conn, nil
+	}
+	_, stream := conn.(*net.TCPConn)
+	return &ttlConn{Conn: conn, ttl: ttl, stream: stream}, nil
+}
+
+// answerTTL collects the lowest TTL of answers to a lookup, which sends A and
+// AAAA queries concurrently
+type answerTTL struct {
+	mu  sync.Mutex
+	ttl time.Duration
+	set bool
+}
+
+type answerTTLKey struct{}
+
+func withAnswerTTL(ctx context.Context, ttl *answerTTL) context.Context {
+	return context.WithValue(ctx, answerTTLKey{}, ttl)
+}
+
+func (a *answerTTL) observe(ttl time.Duration) {
+	a.mu.Lock()
+	if !a.set || ttl < a.ttl {
+		a.ttl, a.set = ttl, true
+	}
+	a.mu.Unlock()
+}
+
+func (a *answerTTL) get() time.Duration {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return a.ttl
+}
+
+// ttlConn inspects DNS messages read by the resolver. Over TCP messages are
+// prefixed by their length, which the resolver reads separately.
+type ttlConn struct {
+	net.Conn
+	ttl    *answerTTL
+	stream bool
+	buf    []byte
+}
+
+func (c *ttlConn) Read(p []byte) (int, error) {
+	n, err := c.Conn.Read(p)
+	if !c.stream {
+		if ttl, ok := minAnswerTTL(p[:n]); ok {
+			c.ttl.observe(ttl)
+		}
+		return n, err
+	}
+	c.buf = append(c.buf, p[:n]...)
+	for len(c.buf) >= 2 {
+		size := int(binary.BigEndian.Uint16(c.buf))
+		if len(c.buf) < 2+size {
+			break
+		}
+		if ttl, ok := minAnswerTTL(c.buf[2 : 2+size]); ok {
+			c.ttl.observe(ttl)
+		}
+		c.buf = c.buf[2+size:]
+	}
+	return n, err
+}
+
+// minAnswerTTL parses a DNS response returning the lowest TTL of its answer
+// records, ok is false for malformed messages and messages without answers
+func minAnswerTTL(msg []byte) (time.Duration, bool) {
+	if len(msg) < 12 || msg[2]&0x80 == 0 {
+		return 0, false
+	}
+	questions := int(binary.BigEndian.Uint16(msg[4:]))
+	answers := int(binary.BigEndian.Uint16(msg[6:]))
+	off := 12
+	for i := 0; i < questions; i++ {
+		if off = skipName(msg, off); off < 0 || off+4 > len(msg) {
+			return 0, false
+		}
+		off += 4
+	}
+	var (
+		min   uint32
+		found bool
+	)
+	for i := 0; i < answers; i++ {
+		if off = skipName(msg, off); off < 0 || off+10 > len(msg) {
+			return 0, false
+		}
+		ttl := binary.BigEndian.Uint32(msg[off+4:])
+		length := int(binary.BigEndian.Uint16(msg[off+8:]))
+		if !found || ttl < min {
+			min, found = ttl, true
+		}
+		off += 10 + length
+	}
+	return time.Duration(min) * time.Second, found
+}
+
+// skipName returns the offset after a possibly compressed name, -1 if malformed
+func skipName(msg []byte, off int) int {
+	for off < len(msg) {
+		l := int(msg[off])
+		switch {
+		case l == 0:
+			return off + 1
+		case l&0xC0 == 0xC0:
+			if off+2 > len(msg) {
+				return -1
+			}
+			return off + 2
+		case l&0xC0 != 0:
+			return -1
+		}
+		off += 1 + l
+	}
+	return -1
+}
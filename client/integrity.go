@@ -0,0 +1,211 @@
+package main
+
+import (
+	"bytes"
+	"crypto/md5"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"hash"
+	"io"
+	"net/http"
+	"strings"
+
+	httputils "github.com/cascades-fbp/cascades-http/utils"
+)
+
+// Checksum describes expected digests of a download, sent to the CHECKSUM port
+// before the request
+type Checksum struct {
+	URL    string `json:"url"`    // URL of the download, the next request if empty
+	SHA256 string `json:"sha256"` // Hex or base64 encoded SHA-256 of the body
+	MD5    string `json:"md5"`    // Hex or base64 encoded MD5 of the body
+}
+
+// maxPendingChecksums bounds checksums waiting for their requests
+const maxPendingChecksums = 1024
+
+// digests are hashes known by name, names of Digest and Content-Digest headers are case-insensitive
+var digests = map[string]func() hash.Hash{
+	"sha-256": sha256.New,
+	"sha-512": sha512.New,
+	"md5":     md5.New,
+}
+
+// IntegrityError is returned when a body doesn't match an expected digest
+type IntegrityError struct {
+	Algorithm string
+	Source    string // checksum or name of the header
+	Expected  []byte
+	Actual    []byte
+}
+
+func (e *IntegrityError) Error() string {
+	return fmt.Sprintf("%s of body doesn't match %s: expected %x, got %x", e.Algorithm, e.Source, e.Expected, e.Actual)
+}
+
+// checksumQueue keeps checksums received from the CHECKSUM port until the
+// matching request is performed
+type checksumQueue struct {
+	byURL map[string]*Checksum
+	next  *Checksum
+}
+
+var checksums = checksumQueue{byURL: make(map[string]*Checksum)}
+
+// Add queues a checksum IP payload
+func (q *checksumQueue) Add(payload []byte) error {
+	var c *Checksum
+	if err := json.Unmarshal(payload, &c); err != nil {
+		return err
+	}
+	if c == nil || (c.SHA256 == "" && c.MD5 == "") {
+		return fmt.Errorf("checksum has neither sha256 nor md5")
+	}
+	for alg, value := range map[string]string{"sha-256": c.SHA256, "md5": c.MD5} {
+		if value != "" && decodeDigest(value, digests[alg]().Size()) == nil {
+			return fmt.Errorf("invalid %s checksum %q", alg, value)
+		}
+	}
+	if c.URL == "" {
+		q.next = c
+		return nil
+	}
+	if _, ok := q.byURL[c.URL]; !ok && len(q.byURL) >= maxPendingChecksums {
+		return fmt.Errorf("too many pending checksums")
+	}
+	q.byURL[c.URL] = c
+	return nil
+}
+
+// Take returns and forgets the checksum of a request URL
+func (q *checksumQueue) Take(url string) *Checksum {
+	if c, ok := q.byURL[url]; ok {
+		delete(q.byURL, url)
+		return c
+	}
+	c := q.next
+	q.next = nil
+	return c
+}
+
+// expectation is a digest a body has to match
+type expectation struct {
+	algorithm string
+	source    string
+	value     []byte
+	hash      hash.Hash
+}
+
+// verifyingBody hashes a body while it's read and fails at its end if a digest
+// doesn't match, so corrupted payloads never reach outputs
+type verifyingBody struct {
+	io.ReadCloser
+	expected []*expectation
+}
+
+func (b *verifyingBody) Read(p []byte) (int, error) {
+	n, err := b.ReadCloser.Read(p)
+	for _, e := range b.expected {
+		e.hash.Write(p[:n])
+	}
+	if err == io.EOF {
+		for _, e := range b.expected {
+			if actual := e.hash.Sum(nil); !bytes.Equal(actual, e.value) {
+				return n, &IntegrityError{Algorithm: e.algorithm, Source: e.source, Expected: e.value, Actual: actual}
+			}
+		}
+	}
+	return n, err
+}
+
+// verifyResponse wraps the body of a successful response checking it against
+// a checksum and, with verify_digest set, against digest headers
+func verifyResponse(response *http.Response, checksum *Checksum) {
+	if response.StatusCode < 200 || response.StatusCode > 299 || response.Request.Method == http.MethodHead {
+		return
+	}
+	partial := response.StatusCode == http.StatusPartialContent
+
+	var expected []*expectation
+	add := func(alg, source, value string) {
+		newHash, ok := digests[alg]
+		if !ok || value == "" {
+			return
+		}
+		h := newHash()
+		if v := decodeDigest(value, h.Size()); v != nil {
+			expected = append(expected, &expectation{algorithm: alg, source: source, value: v, hash: h})
+		} else {
+			logger.Warn("Ignoring malformed digest", "source", source, "value", value)
+		}
+	}
+	// A checksum and the Digest header describe the whole file, not a range of it
+	if checksum != nil && !partial {
+		add("sha-256", "checksum", checksum.SHA256)
+		add("md5", "checksum", checksum.MD5)
+	}
+	// Header digests describe encoded bytes, which transparently decompressed bodies aren't
+	if verifyDigest && !response.Uncompressed {
+		for _, d := range parseDigests(response.Header.Get("Content-Digest"), true) {
+			add(d[0], "Content-Digest", d[1])
+		}
+		if !partial {
+			for _, d := range parseDigests(response.Header.Get("Digest"), false) {
+				add(d[0], "Digest", d[1])
+			}
+		}
+		add("md5", "Content-MD5", response.Header.Get("Content-MD5"))
+	}
+	if len(expected) > 0 {
+		response.Body = &verifyingBody{ReadCloser: response.Body, expected: expected}
+	}
+}
+
+// parseDigests returns lowercase algorithm and value pairs of a Digest (RFC 3230)
+// or Content-Digest (RFC 9530, values are byte sequences enclosed in colons) header
+func parseDigests(header string, structured bool) [][2]string {
+	var res [][2]string
+	for _, item := range strings.Split(header, ",") {
+		i := strings.Index(item, "=")
+		if i < 0 {
+			continue
+		}
+		alg := strings.ToLower(strings.TrimSpace(item[:i]))
+		value := strings.TrimSpace(item[i+1:])
+		if structured {
+			if len(value) < 2 || value[0] != ':' || value[len(value)-1] != ':' {
+				continue
+			}
+			value = value[1 : len(value)-1]
+		}
+		res = append(res, [2]string{alg, value})
+	}
+	return res
+}
+
+// decodeDigest decodes a hex or base64 digest of a given size, nil if invalid
+func decodeDigest(value string, size int) []byte {
+	if len(value) == 2*size {
+		if v, err := hex.DecodeString(value); err == nil {
+			return v
+		}
+	}
+	if v, err := base64.StdEncoding.DecodeString(value); err == nil && len(v) == size {
+		return v
+	}
+	return nil
+}
+
+// integrityError converts an integrity failure to the error sent to the ERR port
+func integrityError(ierr *IntegrityError, url string) *httputils.Error {
+	return httputils.NewError("http/client", httputils.ErrIntegrity, ierr).
+		WithDetail("url", httputils.Redaction.URL(url)).
+		WithDetail("algorithm", ierr.Algorithm).
+		WithDetail("source", ierr.Source).
+		WithDetail("expected", hex.EncodeToString(ierr.Expected)).
+		WithDetail("actual", hex.EncodeToString(ierr.Actual))
+}
@@ -1,17 +1,16 @@
 package main
 
 import (
-	"crypto/tls"
+	"bytes"
 	"encoding/json"
+	"errors"
 	"flag"
 	"fmt"
-	"io/ioutil"
 	"log"
 	"net/http"
 	"os"
-	"os/signal"
 	"strings"
-	"syscall"
+	"sync/atomic"
 	"time"
 
 	httputils "github.com/cascades-fbp/cascades-http/utils"
@@ -22,19 +21,60 @@ import (
 
 var (
 	// Flags
-	requestEndpoint  = flag.String("port.req", "", "Component's input port endpoint")
-	responseEndpoint = flag.String("port.resp", "", "Component's output port endpoint")
-	bodyEndpoint     = flag.String("port.body", "", "Component's output port endpoint")
-	errorEndpoint    = flag.String("port.err", "", "Component's error port endpoint")
-	jsonFlag         = flag.Bool("json", false, "Print component documentation in JSON")
-	debug            = flag.Bool("debug", false, "Enable debug mode")
+	optionsEndpoint   = flag.String("port.options", "", "Component's options port endpoint")
+	configEndpoint    = flag.String("port.config", "", "Component's configuration reload port endpoint")
+	requestEndpoint   = flag.String("port.req", "", "Component's input port endpoint")
+	checksumEndpoint  = flag.String("port.checksum", "", "Component's checksum port endpoint")
+	authEndpoint      = flag.String("port.auth", "", "Component's credentials port endpoint")
+	tokenEndpoint     = flag.String("port.token", "", "Component's bearer token port endpoint")
+	cookiesEndpoint   = flag.String("port.cookies", "", "Component's seed cookies port endpoint")
+	payloadEndpoint   = flag.String("port.payload", "", "Component's request body port endpoint")
+	timeoutEndpoint   = flag.String("port.timeout", "", "Component's request timeout port endpoint")
+	proxyEndpoint     = flag.String("port.proxy", "", "Component's proxy port endpoint")
+	defaultsEndpoint  = flag.String("port.defaults", "", "Component's request defaults port endpoint")
+	typeEndpoint      = flag.String("port.type", "", "Component's request content type port endpoint")
+	responseEndpoint  = flag.String("port.resp", "", "Component's output port endpoint")
+	bodyEndpoint      = flag.String("port.body", "", "Component's output port endpoint")
+	setCookieEndpoint = flag.String("port.setcookie", "", "Component's set cookies port endpoint")
+	refreshEndpoint   = flag.String("port.refresh", "", "Component's token refresh port endpoint")
+	errorEndpoint     = flag.String("port.err", "", "Component's error port endpoint")
+	logEndpoint       = flag.String("port.log", "", "Component's log port endpoint")
+	heartbeatEndpoint = flag.String("port.heartbeat", "", "Component's heartbeat port endpoint")
+	metricsAddr       = flag.String("metrics", "", "Address to expose metrics on, i.e. 127.0.0.1:9090 (disabled if empty)")
+	debugEndpoint     = flag.String("port.debug", "", "Component's debug port endpoint")
+	requestTimeout    = flag.Duration("timeout", 30*time.Second, "Default timeout of requests (0 for none)")
+	queueDir          = flag.String("queue", "", "Directory of the persistent queue of requests (disabled if empty)")
+	configFile        = flag.String("config", "", "Options JSON file re-applied on SIGHUP")
+	otlpEndpoint      = flag.String("otlp", "", "OTLP/HTTP endpoint to export trace spans to, i.e. http://127.0.0.1:4318/v1/traces (disabled if empty)")
+	cassetteFile      = flag.String("cassette", "", "HAR file to record interactions to and replay them from (disabled if empty)")
+	recordMode        = flag.String("record", RecordOnce, "Cassette record mode: once, none, all or new")
+	matchRules        = flag.String("match", "method,url", "Comma separated rules matching requests to recorded ones: method, url, host, path, query, body")
+	proxyURL          = flag.String("proxy", "", "URL of http, https or socks5 proxy of requests, none for direct connections (HTTP_PROXY, HTTPS_PROXY and NO_PROXY if empty)")
+	tlsCAFile         = flag.String("tls.ca", "", "CA bundle file to verify server certificates with (system roots if empty)")
+	tlsServerName     = flag.String("tls.server-name", "", "Server name to verify certificates against instead of the requested host")
+	tlsCertFile       = flag.String("tls.cert", "", "Client certificate file for servers requiring mutual TLS")
+	tlsKeyFile        = flag.String("tls.key", "", "Private key file of the client certificate")
+	tlsInsecure       = flag.Bool("tls.insecure", false, "Skip verification of server certificates")
+	cookiesFlag       = flag.Bool("cookies", false, "Keep cookies set by responses and send them with later requests")
+	streamFlag        = flag.Bool("stream", false, "Stream response bodies to BODY as substreams of chunks instead of buffering them")
+	chunkSize         = flag.Int("chunk", 64<<10, "Size of chunks of streamed response bodies in bytes")
+	binaryFlag        = flag.Bool("binary", false, "Send response bodies in raw frames following JSON metadata, on RESP instead of base64 and on BODY instead of the bare payload (streamed bodies are not affected)")
+	utf8Flag          = flag.Bool("utf8", false, "Transcode text response bodies to UTF-8 when Content-Type charset or BOM names another encoding")
+	jsonFlag          = flag.Bool("json", false, "Print component documentation in JSON")
+	debug             = flag.Bool("debug", false, "Enable debug mode")
 
 	// Internal
-	// Internal
-	reqPort, respPort, bodyPort, errPort *zmq.Socket
-	reqCh, respCh, bodyCh, errCh         chan bool
-	exitCh                               chan os.Signal
-	err                                  error
+	optionsPort, configPort, reqPort, checksumPort, authPort, tokenPort, cookiesPort, payloadPort, typePort, timeoutPort, proxyPort, defaultsPort, respPort, bodyPort, setCookiePort, refreshPort, errPort, logPort, heartbeatPort, debugPort *zmq.Socket
+	respOutlet, bodyOutlet                                                                                                                                                                                                                    *httputils.Outlet
+	optionsCh, reqCh, respCh, bodyCh, errCh                                                                                                                                                                                                   chan bool
+	err                                                                                                                                                                                                                                       error
+	logger                                                                                                                                                                                                                                    = httputils.NewLogger("http/client")
+	liveness                                                                                                                                                                                                                                  = httputils.NewLiveness("http/client")
+	metrics                                                                                                                                                                                                                                   = httputils.NewMetrics(logger, liveness)
+	dumper                                                                                                                                                                                                                                    = httputils.NewDumper("http/client")
+	shutdown                                                                                                                                                                                                                                  *httputils.Shutdown
+	tracer                                                                                                                                                                                                                                    *httputils.Tracer
+	jar                                                                                                                                                                                                                                       *Jar
 )
 
 func main() {
@@ -48,34 +88,37 @@ func main() {
 
 	log.SetFlags(0)
 	if *debug {
-		log.SetOutput(os.Stdout)
-	} else {
-		log.SetOutput(ioutil.Discard)
+		logger.SetLevel(httputils.LevelDebug)
+		logger.SetOutput(os.Stdout)
 	}
+	log.SetOutput(logger.Writer())
 
 	validateArgs()
 
 	// Communication channels
+	optionsCh = make(chan bool)
 	reqCh = make(chan bool)
 	bodyCh = make(chan bool)
 	respCh = make(chan bool)
 	errCh = make(chan bool)
-	exitCh = make(chan os.Signal, 1)
 
-	// Start the communication & processing logic
-	go mainLoop()
+	shutdown = httputils.NewShutdown(logger)
+	tracer = httputils.NewTracer("http/client", *otlpEndpoint, logger)
+	tracer.Start()
 
-	// Wait for the end...
-	signal.Notify(exitCh, os.Interrupt, syscall.SIGTERM)
-	<-exitCh
+	if *metricsAddr != "" {
+		err = metrics.Serve(*metricsAddr)
+		utils.AssertError(err)
+	}
 
-	log.Println("Done")
+	// Process requests until shutdown or all upstreams are gone
+	mainLoop()
+	shutdown.Exit(closePorts)
 }
 
 // mainLoop initiates all ports and handles the traffic
 func mainLoop() {
 	openPorts()
-	defer closePorts()
 
 	ports := 1
 	if bodyPort != nil {
@@ -88,8 +131,11 @@ func mainLoop() {
 		ports++
 	}
 
+	// upstreams counts connected senders of REQ and OPTIONS ports. Once all
+	// of them disconnect the client exits after draining REQ port.
+	var upstreams int32
+
 	waitCh := make(chan bool)
-	reqExitCh := make(chan bool, 1)
 	go func(num int) {
 		total := 0
 		for {
@@ -97,13 +143,20 @@ func mainLoop() {
 			case v := <-reqCh:
 				if v {
 					total++
+					atomic.AddInt32(&upstreams, 1)
 				} else {
-					reqExitCh <- true
+					atomic.AddInt32(&upstreams, -1)
+				}
+			case v := <-optionsCh:
+				if v {
+					atomic.AddInt32(&upstreams, 1)
+				} else {
+					atomic.AddInt32(&upstreams, -1)
 				}
 			case v := <-bodyCh:
 				if !v {
 					log.Println("BODY port is closed. Interrupting execution")
-					exitCh <- syscall.SIGTERM
+					shutdown.Stop()
 					break
 				} else {
 					total++
@@ -111,7 +164,7 @@ func mainLoop() {
 			case v := <-respCh:
 				if !v {
 					log.Println("RESP port is closed. Interrupting execution")
-					exitCh <- syscall.SIGTERM
+					shutdown.Stop()
 					break
 				} else {
 					total++
@@ -119,7 +172,7 @@ func mainLoop() {
 			case v := <-errCh:
 				if !v {
 					log.Println("ERR port is closed. Interrupting execution")
-					exitCh <- syscall.SIGTERM
+					shutdown.Stop()
 					break
 				} else {
 					total++
@@ -137,118 +190,476 @@ func mainLoop() {
 		log.Println("Ports connected")
 		waitCh = nil
 	case <-time.Tick(30 * time.Second):
-		log.Println("Timeout: port connections were not established within provided interval")
-		exitCh <- syscall.SIGTERM
+		logger.Error("Port connections were not established within provided interval")
+		return
+	case <-shutdown.Done():
 		return
 	}
 
-	// This is obviously dangerous but we need it to deal with our custom CA's
-	tr := &http.Transport{
-		TLSClientConfig: &tls.Config{InsecureSkipVerify: true},
+	// Server certificates are verified unless -tls.insecure is set, custom CAs
+	// are trusted with -tls.ca and -tls.cert is presented to servers asking for it
+	tr := &http.Transport{DialContext: dnsCache.DialContext}
+	tr.Proxy, _ = proxyFunc(*proxyURL)
+	if tr.TLSClientConfig, err = clientTLS(nil).ClientConfig(); err != nil {
+		logger.Error("Invalid TLS configuration", "error", err)
+		return
 	}
+	if *tlsInsecure {
+		logger.Warn("Verification of server certificates is disabled")
+	}
+	dnsCache.Watch(metrics)
+	metrics.CounterFunc("cascades_http_client_retries_total", "Number of failed requests sent again", func() float64 {
+		return float64(atomic.LoadInt64(&retries))
+	})
+	transports = NewTransports(tr)
 	client := &http.Client{Transport: tr}
-	client.Timeout = 30 * time.Second
+	client.Timeout = *requestTimeout
+	if cookiesEnabled() {
+		jar = NewJar()
+		client.Jar = jar
+	}
+	if client.Transport, err = cassette(transports); err != nil {
+		logger.Error("Failed to open cassette", "file", *cassetteFile, "error", err)
+		return
+	}
+
+	persistent := *queueDir != ""
+	var queue *Scheduler
+	if queue, err = OpenScheduler(*queueDir); err != nil {
+		logger.Error("Failed to open queue", "dir", *queueDir, "error", err)
+		return
+	}
+	defer queue.Close()
+	if persistent {
+		logger.Info("Opened persistent queue", "dir", *queueDir, "pending", queue.Len())
+	}
 
 	// Main loop
 	var (
-		ip            [][]byte
-		clientOptions *httputils.HTTPClientOptions
-		request       *http.Request
+		ip       [][]byte
+		retryAt  time.Time
+		received int
 	)
 
+	reloads := httputils.WatchConfigFile(*configFile, logger)
+
 	log.Println("Started")
 
-	for {
-		ip, err = reqPort.RecvMessageBytes(zmq.DONTWAIT)
-		if err != nil {
-			select {
-			case <-reqExitCh:
-				log.Println("REQ port is closed. Interrupting execution")
-				exitCh <- syscall.SIGTERM
+	for !shutdown.Stopping() {
+		if optionsPort != nil {
+			if ip, err = optionsPort.RecvMessageBytes(zmq.DONTWAIT); err == nil && runtime.IsValidIP(ip) {
+				if err = applyOptions(ip[1], client, tr); err != nil {
+					logger.Error("Failed to apply options", "error", err)
+					sendError(httputils.NewError("http/client", httputils.ErrInvalidIP, err))
+				}
+			}
+		}
+
+		// Configuration reloads are applied the same way as options
+		var reload []byte
+		if configPort != nil {
+			if ip, err = configPort.RecvMessageBytes(zmq.DONTWAIT); err == nil && runtime.IsValidIP(ip) {
+				reload = ip[1]
+			}
+		}
+		select {
+		case data := <-reloads:
+			reload = data
+		default:
+		}
+		if reload != nil {
+			if err = applyOptions(reload, client, tr); err != nil {
+				logger.Error("Failed to reload configuration", "error", err)
+				sendError(httputils.NewError("http/client", httputils.ErrInvalidIP, err))
+			}
+		}
+
+		// Checksums are expected to arrive before their requests
+		for checksumPort != nil {
+			if ip, err = checksumPort.RecvMessageBytes(zmq.DONTWAIT); err != nil {
 				break
-			default:
-				// IN port is still open
 			}
-			time.Sleep(2 * time.Second)
-			continue
+			if !runtime.IsValidIP(ip) {
+				continue
+			}
+			if err = checksums.Add(ip[1]); err != nil {
+				logger.Warn("Invalid checksum", "error", err)
+				sendError(httputils.NewError("http/client", httputils.ErrInvalidIP, err))
+			}
 		}
-		if !runtime.IsValidIP(ip) {
-			log.Println("Invalid IP:", ip)
-			continue
+
+		// Credentials apply to requests sent from now on, including queued ones
+		for authPort != nil {
+			if ip, err = authPort.RecvMessageBytes(zmq.DONTWAIT); err != nil {
+				break
+			}
+			if !runtime.IsValidIP(ip) || !runtime.IsPacket(ip) {
+				continue
+			}
+			if err = setAuth(ip[1]); err != nil {
+				logger.Warn("Invalid credentials", "error", err)
+				sendError(httputils.NewError("http/client", httputils.ErrInvalidIP, err))
+				continue
+			}
+			logger.Info("Changed credentials")
+		}
+		for tokenPort != nil {
+			if ip, err = tokenPort.RecvMessageBytes(zmq.DONTWAIT); err != nil {
+				break
+			}
+			if !runtime.IsValidIP(ip) || !runtime.IsPacket(ip) {
+				continue
+			}
+			if err = setToken(ip[1]); err != nil {
+				logger.Warn("Invalid token", "error", err)
+				sendError(httputils.NewError("http/client", httputils.ErrInvalidIP, err))
+				continue
+			}
+			logger.Info("Changed bearer token")
 		}
 
-		err = json.Unmarshal(ip[1], &clientOptions)
-		if err != nil {
-			log.Println("ERROR: failed to unmarshal request options:", err.Error())
-			continue
+		// Seed cookies, i.e. of a session logged in elsewhere
+		for cookiesPort != nil {
+			if ip, err = cookiesPort.RecvMessageBytes(zmq.DONTWAIT); err != nil {
+				break
+			}
+			if !runtime.IsValidIP(ip) || !runtime.IsPacket(ip) {
+				continue
+			}
+			if err = jar.Seed(ip[1]); err != nil {
+				logger.Warn("Invalid cookies", "error", err)
+				sendError(httputils.NewError("http/client", httputils.ErrInvalidIP, err))
+			}
 		}
-		if clientOptions == nil {
-			log.Println("ERROR: received nil request options")
-			continue
+
+		for timeoutPort != nil {
+			if ip, err = timeoutPort.RecvMessageBytes(zmq.DONTWAIT); err != nil {
+				break
+			}
+			if !runtime.IsValidIP(ip) || !runtime.IsPacket(ip) {
+				continue
+			}
+			if err = setTimeout(ip[1], client); err != nil {
+				logger.Warn("Invalid timeout", "value", string(ip[1]))
+				sendError(httputils.NewError("http/client", httputils.ErrInvalidIP, err))
+				continue
+			}
+			logger.Info("Changed request timeout", "timeout", client.Timeout.String())
 		}
 
-		if clientOptions.Form != nil {
-			request, err = http.NewRequest(clientOptions.Method, clientOptions.URL, strings.NewReader(clientOptions.Form.Encode()))
-		} else {
-			request, err = http.NewRequest(clientOptions.Method, clientOptions.URL, nil)
+		for proxyPort != nil {
+			if ip, err = proxyPort.RecvMessageBytes(zmq.DONTWAIT); err != nil {
+				break
+			}
+			if !runtime.IsValidIP(ip) || !runtime.IsPacket(ip) {
+				continue
+			}
+			if err = setProxy(ip[1], tr); err != nil {
+				logger.Warn("Invalid proxy", "value", httputils.Redaction.URL(string(ip[1])))
+				sendError(httputils.NewError("http/client", httputils.ErrInvalidIP, err))
+				continue
+			}
+			logger.Info("Changed proxy", "proxy", httputils.Redaction.URL(string(ip[1])))
 		}
-		utils.AssertError(err)
 
-		if clientOptions.ContentType != "" {
-			request.Header.Add("Content-Type", clientOptions.ContentType)
+		for defaultsPort != nil {
+			if ip, err = defaultsPort.RecvMessageBytes(zmq.DONTWAIT); err != nil {
+				break
+			}
+			if !runtime.IsValidIP(ip) || !runtime.IsPacket(ip) {
+				continue
+			}
+			if err = applyDefaults(ip[1], client, tr); err != nil {
+				logger.Warn("Invalid request defaults", "error", err)
+				sendError(httputils.NewError("http/client", httputils.ErrInvalidIP, err))
+				continue
+			}
+			logger.Info("Changed request defaults", "timeout", client.Timeout.String(), "follow_redirects", followRedirects, "max_body", maxBodySize)
 		}
 
-		for k, v := range clientOptions.Headers {
-			request.Header.Add(k, v[0])
+		// Raw bodies and their content type are expected before their requests too
+		for typePort != nil {
+			if ip, err = typePort.RecvMessageBytes(zmq.DONTWAIT); err != nil {
+				break
+			}
+			if runtime.IsValidIP(ip) && runtime.IsPacket(ip) {
+				bodies.SetType(ip[1])
+			}
+		}
+		for payloadPort != nil {
+			if ip, err = payloadPort.RecvMessageBytes(zmq.DONTWAIT); err != nil {
+				break
+			}
+			if !runtime.IsValidIP(ip) || !runtime.IsPacket(ip) {
+				continue
+			}
+			if err = bodies.Add(ip[1]); err != nil {
+				logger.Warn("Dropped request body", "error", err)
+				sendError(httputils.NewError("http/client", httputils.ErrOverflow, err))
+			}
 		}
 
-		response, err := client.Do(request)
-		if err != nil {
-			log.Printf("ERROR performing HTTP %s %s: %s", request.Method, request.URL, err.Error())
-			if errPort != nil {
-				errPort.SendMessageDontwait(runtime.NewPacket([]byte(err.Error())))
+		// Pending requests are collected before one is sent, so the most urgent
+		// goes first. In memory only max_pending of them are held.
+		gotRequest := false
+		if persistent || queue.Len() < maxPending {
+			ip, err = reqPort.RecvMessageBytes(zmq.DONTWAIT)
+			gotRequest = err == nil
+		}
+		if gotRequest {
+			liveness.Inc()
+			if !runtime.IsValidIP(ip) {
+				logger.Warn("Received invalid IP", "frames", len(ip))
+				continue
+			}
+			payload, err := bodies.Attach(ip[1])
+			if err != nil {
+				logger.Warn("Failed to attach body to request", "error", err)
+			}
+			if err = queue.Push(payload); err != nil {
+				logger.Error("Failed to queue request, performing it right away", "error", err)
+				perform(client, payload, false)
+				continue
+			}
+			if received++; received < maxPending {
+				continue
 			}
-			clientOptions = nil
-			continue
 		}
-		resp, err := httputils.Response2Response(response)
-		if err != nil {
-			log.Printf("ERROR converting response to reply: %s", err.Error())
-			if errPort != nil {
-				errPort.SendMessageDontwait(runtime.NewPacket([]byte(err.Error())))
+		received = 0
+
+		// Requests are sent by priority, with -queue the first one is retried
+		// until the network or downstream is available again
+		if queue.Len() > 0 && !time.Now().Before(retryAt) {
+			payload, err := queue.Peek()
+			if err != nil {
+				logger.Error("Failed to read queue", "error", err)
+				retryAt = time.Now().Add(queueRetryInterval)
+				continue
+			}
+			if perform(client, payload, persistent) {
+				retryAt = time.Now().Add(queueRetryInterval)
+				continue
+			}
+			if err = queue.Ack(); err != nil {
+				logger.Error("Failed to acknowledge queued request", "error", err)
 			}
-			clientOptions = nil
 			continue
 		}
-		ip, err = httputils.Response2IP(resp)
-		if err != nil {
-			log.Printf("ERROR converting reply to IP: %s", err.Error())
-			if errPort != nil {
-				errPort.SendMessageDontwait(runtime.NewPacket([]byte(err.Error())))
-			}
-			clientOptions = nil
+		if gotRequest {
 			continue
 		}
 
-		if respPort != nil {
-			respPort.SendMessage(ip)
+		// REQ port is drained and nothing can be sent from the queue now
+		if atomic.LoadInt32(&upstreams) <= 0 {
+			if queue.Len() > 0 {
+				logger.Info("All upstreams disconnected, requests are left in the queue", "pending", queue.Len())
+			}
+			logger.Info("All upstreams disconnected and REQ port is drained. Interrupting execution")
+			return
 		}
-		if bodyPort != nil {
-			bodyPort.SendMessage(runtime.NewPacket(resp.Body))
+		select {
+		case <-shutdown.Done():
+		case <-time.After(2 * time.Second):
 		}
+	}
+	if !persistent && queue.Len() > 0 {
+		logger.Warn("Requests held in memory are dropped on shutdown", "pending", queue.Len())
+	}
+}
 
-		select {
-		case <-reqCh:
-			log.Println("REQ port is closed. Interrupting execution")
-			exitCh <- syscall.SIGTERM
-			break
-		default:
-			// file port is still open
+// perform sends an HTTP request described by a REQ payload and emits the
+// response. With retry set, network failures are only logged and true is
+// returned so the request can be repeated later.
+func perform(client *http.Client, payload []byte, retry bool) bool {
+	var (
+		clientOptions *httputils.HTTPClientOptions
+		request       *http.Request
+		err           error
+	)
+	err = json.Unmarshal(payload, &clientOptions)
+	if err != nil {
+		logger.Warn("Failed to unmarshal request options", "error", err)
+		return false
+	}
+	if clientOptions == nil {
+		logger.Warn("Received nil request options")
+		return false
+	}
+
+	if clientOptions.Body != nil {
+		request, err = http.NewRequest(clientOptions.Method, clientOptions.URL, bytes.NewReader(clientOptions.Body))
+	} else if clientOptions.Form != nil {
+		request, err = http.NewRequest(clientOptions.Method, clientOptions.URL, strings.NewReader(clientOptions.Form.Encode()))
+	} else {
+		request, err = http.NewRequest(clientOptions.Method, clientOptions.URL, nil)
+	}
+	if err != nil {
+		logger.Warn("Failed to create HTTP request", "error", err)
+		sendError(httputils.NewError("http/client", httputils.ErrInvalidRequest, err))
+		return false
+	}
+	var redirects []string
+	client, ctx, err := requestClient(client, clientOptions, &redirects)
+	if err != nil {
+		logger.Warn("Invalid request options", "error", err)
+		sendError(httputils.NewError("http/client", httputils.ErrInvalidRequest, err))
+		return false
+	}
+	request = request.WithContext(ctx)
+
+	if clientOptions.ContentType != "" {
+		request.Header.Add("Content-Type", clientOptions.ContentType)
+	} else if clientOptions.Body != nil && !hasContentType(clientOptions) {
+		request.Header.Set("Content-Type", http.DetectContentType(clientOptions.Body))
+	}
+
+	if userAgent != "" {
+		request.Header.Set("User-Agent", userAgent)
+	}
+	for k, v := range clientOptions.Headers {
+		request.Header.Add(k, v[0])
+	}
+	withToken := authorize(request)
+
+	// The span continues the trace of the request options or their headers
+	parent := clientOptions.Trace
+	if parent == "" {
+		parent = request.Header.Get(httputils.TraceparentHeader)
+	}
+	span := tracer.StartSpan("HTTP "+request.Method, parent, httputils.SpanClient)
+	defer span.End()
+	span.SetAttribute("http.method", request.Method)
+	span.SetAttribute("http.url", request.URL.String())
+	if traceparent := span.Traceparent(); traceparent != "" {
+		request.Header.Set(httputils.TraceparentHeader, traceparent)
+	}
+
+	if debugPort != nil && dumper.Enabled() {
+		body := clientOptions.Body
+		if body == nil && clientOptions.Form != nil {
+			body = []byte(clientOptions.Form.Encode())
+		}
+		sendDump(dumper.RequestOut(request, "", body))
+	}
+
+	response, attempts, err := send(client, request, clientOptions)
+	sendCookies()
+	span.SetAttribute("http.attempts", attempts)
+	if err != nil {
+		span.SetError(err)
+		category := httputils.ClassifyError(err)
+		if retry && (category == httputils.ErrNetwork || category == httputils.ErrTimeout) {
+			logger.Warn("Failed to perform queued HTTP request, will retry", "method", request.Method, "url", request.URL.String(), "error", err)
+			return true
+		}
+		logger.Error("Failed to perform HTTP request", "method", request.Method, "url", request.URL.String(), "attempts", attempts, "error", err)
+		checksums.Take(clientOptions.URL)
+		sendError(httputils.NewError("http/client", category, err))
+		return false
+	}
+	span.SetStatusCode(response.StatusCode)
+	if withToken && response.StatusCode == http.StatusUnauthorized {
+		requestRefresh("unauthorized")
+	}
+	verifyResponse(response, checksums.Take(clientOptions.URL))
+	limitResponse(response, clientOptions.MaxBody)
+
+	// Streamed bodies are sent to BODY after the response without them
+	stream := streaming(clientOptions)
+	var resp *httputils.HTTPResponse
+	if stream {
+		resp = &httputils.HTTPResponse{StatusCode: response.StatusCode, Header: response.Header}
+	} else {
+		resp, err = httputils.Response2Response(response)
+	}
+	if err != nil {
+		bodyError(span, request, err)
+		return false
+	}
+	if *utf8Flag && !stream {
+		if err = resp.DecodeUTF8(); err != nil {
+			logger.Warn("Failed to transcode response body to UTF-8, sending it unchanged", "url", request.URL.String(), "error", err)
 		}
+	}
+	resp.Trace = parent
+	resp.URL = response.Request.URL.String()
+	resp.Redirects = redirects
+	resp.Attempts = attempts
+	if debugPort != nil && dumper.Enabled() {
+		sendDump(dumper.Response(response, resp.ID, resp.Body))
+	}
+	// In binary mode the body follows JSON metadata in a raw frame instead of base64
+	encode := httputils.Response2IP
+	if *binaryFlag {
+		encode = httputils.Response2FramedIP
+	}
+	ip, err := encode(resp)
+	if err != nil {
+		logger.Error("Failed to convert reply to IP", "error", err)
+		sendError(httputils.NewError("http/client", httputils.ErrInternal, err))
+		return false
+	}
 
-		clientOptions = nil
-		continue
+	if respOutlet != nil {
+		respOutlet.Send(ip)
+	}
+	if stream {
+		if _, err = streamBody(response.Body); err != nil {
+			bodyError(span, request, err)
+		}
+		return false
+	}
+	if bodyOutlet != nil {
+		if ip, err = bodyIP(resp); err != nil {
+			logger.Error("Failed to convert body to IP", "error", err)
+			sendError(httputils.NewError("http/client", httputils.ErrInternal, err))
+			return false
+		}
+		bodyOutlet.Send(ip)
 	}
+	return false
+}
+
+// bodyError reports a failure reading the body of a response
+func bodyError(span *httputils.Span, request *http.Request, err error) {
+	span.SetError(err)
+	var ierr *IntegrityError
+	if errors.As(err, &ierr) {
+		logger.Error("Response failed integrity check", "url", request.URL.String(), "error", err)
+		sendError(integrityError(ierr, request.URL.String()))
+		return
+	}
+	logger.Error("Failed to read response body", "url", request.URL.String(), "error", err)
+	sendError(httputils.NewError("http/client", httputils.ErrNetwork, err))
+}
+
+// sendError reports a failure to the ERR port if it's connected
+func sendError(e *httputils.Error) {
+	if errPort == nil {
+		return
+	}
+	ip, err := httputils.Error2IP(e)
+	if err != nil {
+		return
+	}
+	errPort.SendMessageDontwait(ip)
+}
+
+// reportOverflow returns a drop handler of a given output reporting to the ERR port
+func reportOverflow(port string) func(ip [][]byte) {
+	return func(ip [][]byte) {
+		logger.Warn("Output queue is full, IP dropped", "port", port)
+		sendError(httputils.NewError("http/client", httputils.ErrOverflow, fmt.Errorf("queue of %s is full", port)))
+	}
+}
+
+// sendDump emits a wire-level dump to the DEBUG port
+func sendDump(ip [][]byte, err error) {
+	if err != nil {
+		logger.Warn("Failed to dump HTTP message", "error", err)
+		return
+	}
+	debugPort.SendMessageDontwait(ip)
 }
 
 // validateArgs checks all required flags
@@ -257,6 +668,21 @@ func validateArgs() {
 		flag.Usage()
 		os.Exit(1)
 	}
+	if (*tlsCertFile == "") != (*tlsKeyFile == "") {
+		fmt.Println("ERROR: -tls.cert and -tls.key must be set together!")
+		flag.Usage()
+		os.Exit(1)
+	}
+	if _, err := proxyFunc(*proxyURL); err != nil {
+		fmt.Printf("ERROR: %v!\n", err)
+		flag.Usage()
+		os.Exit(1)
+	}
+	if *chunkSize <= 0 {
+		fmt.Println("ERROR: -chunk must be positive!")
+		flag.Usage()
+		os.Exit(1)
+	}
 	if *responseEndpoint == "" && *bodyEndpoint == "" {
 		flag.Usage()
 		os.Exit(1)
@@ -265,35 +691,116 @@ func validateArgs() {
 
 // openPorts create ZMQ sockets and start socket monitoring loops
 func openPorts() {
+	if *optionsEndpoint != "" {
+		optionsPort, err = utils.CreateInputPort("http/client.options", *optionsEndpoint, optionsCh)
+		utils.AssertError(err)
+	}
+
+	if *configEndpoint != "" {
+		configPort, err = utils.CreateInputPort("http/client.config", *configEndpoint, nil)
+		utils.AssertError(err)
+	}
+
 	reqPort, err = utils.CreateInputPort("http/client.req", *requestEndpoint, reqCh)
 	utils.AssertError(err)
 
+	if *checksumEndpoint != "" {
+		checksumPort, err = utils.CreateInputPort("http/client.checksum", *checksumEndpoint, nil)
+		utils.AssertError(err)
+	}
+
+	if *authEndpoint != "" {
+		authPort, err = utils.CreateInputPort("http/client.auth", *authEndpoint, nil)
+		utils.AssertError(err)
+	}
+
+	if *tokenEndpoint != "" {
+		tokenPort, err = utils.CreateInputPort("http/client.token", *tokenEndpoint, nil)
+		utils.AssertError(err)
+	}
+
+	if *cookiesEndpoint != "" {
+		cookiesPort, err = utils.CreateInputPort("http/client.cookies", *cookiesEndpoint, nil)
+		utils.AssertError(err)
+	}
+
+	if *payloadEndpoint != "" {
+		payloadPort, err = utils.CreateInputPort("http/client.payload", *payloadEndpoint, nil)
+		utils.AssertError(err)
+	}
+
+	if *timeoutEndpoint != "" {
+		timeoutPort, err = utils.CreateInputPort("http/client.timeout", *timeoutEndpoint, nil)
+		utils.AssertError(err)
+	}
+
+	if *proxyEndpoint != "" {
+		proxyPort, err = utils.CreateInputPort("http/client.proxy", *proxyEndpoint, nil)
+		utils.AssertError(err)
+	}
+	if *defaultsEndpoint != "" {
+		defaultsPort, err = utils.CreateInputPort("http/client.defaults", *defaultsEndpoint, nil)
+		utils.AssertError(err)
+	}
+
+	if *typeEndpoint != "" {
+		typePort, err = utils.CreateInputPort("http/client.type", *typeEndpoint, nil)
+		utils.AssertError(err)
+	}
+
 	if *responseEndpoint != "" {
 		respPort, err = utils.CreateOutputPort("http/client.resp", *responseEndpoint, respCh)
 		utils.AssertError(err)
+		respOutlet = httputils.NewOutlet("http/client.resp", respPort, &httputils.BackpressureOptions{}, reportOverflow("http/client.resp"))
+		metrics.WatchOutlet(respOutlet)
 	}
 	if *bodyEndpoint != "" {
 		bodyPort, err = utils.CreateOutputPort("http/client.body", *bodyEndpoint, bodyCh)
 		utils.AssertError(err)
+		bodyOutlet = httputils.NewOutlet("http/client.body", bodyPort, &httputils.BackpressureOptions{}, reportOverflow("http/client.body"))
+		metrics.WatchOutlet(bodyOutlet)
+	}
+	if *setCookieEndpoint != "" {
+		setCookiePort, err = utils.CreateOutputPort("http/client.setcookie", *setCookieEndpoint, nil)
+		utils.AssertError(err)
+	}
+	if *refreshEndpoint != "" {
+		refreshPort, err = utils.CreateOutputPort("http/client.refresh", *refreshEndpoint, nil)
+		utils.AssertError(err)
 	}
 	if *errorEndpoint != "" {
 		errPort, err = utils.CreateOutputPort("http/client.err", *errorEndpoint, errCh)
 		utils.AssertError(err)
 	}
+
+	if *logEndpoint != "" {
+		logPort, err = utils.CreateOutputPort("http/client.log", *logEndpoint, nil)
+		utils.AssertError(err)
+		logger.SetSink(func(ip [][]byte) { logPort.SendMessageDontwait(ip) })
+	}
+
+	if *debugEndpoint != "" {
+		debugPort, err = utils.CreateOutputPort("http/client.debug", *debugEndpoint, nil)
+		utils.AssertError(err)
+	}
+
+	if *heartbeatEndpoint != "" {
+		heartbeatPort, err = utils.CreateOutputPort("http/client.heartbeat", *heartbeatEndpoint, nil)
+		utils.AssertError(err)
+		liveness.Start(func(ip [][]byte) { heartbeatPort.SendMessageDontwait(ip) })
+	}
 }
 
 // closePorts closes all active ports and terminates ZMQ context
 func closePorts() {
 	log.Println("Closing ports...")
-	reqPort.Close()
-	if bodyPort != nil {
-		bodyPort.Close()
-	}
-	if respPort != nil {
-		respPort.Close()
-	}
-	if errPort != nil {
-		errPort.Close()
+	shutdown.Close(optionsPort, configPort, reqPort, checksumPort, authPort, tokenPort, cookiesPort, payloadPort, typePort, timeoutPort, proxyPort, defaultsPort)
+	shutdown.Drain(respOutlet, bodyOutlet)
+	if warmer != nil {
+		warmer.Stop()
 	}
+	liveness.Stop()
+	tracer.Stop()
+	shutdown.Flush(bodyPort, respPort, setCookiePort, refreshPort, errPort, debugPort, heartbeatPort, logPort)
 	zmq.Term()
 }
@@ -0,0 +1,205 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	httputils "github.com/cascades-fbp/cascades-http/utils"
+)
+
+// Section describes client specific section of the options IP
+type Section struct {
+	UserAgent       string         `json:"user_agent"`       // Default User-Agent header
+	Warmup          *WarmupSection `json:"warmup"`           // Connections kept warm, i.e. {"hosts": ["api.example.com"]}
+	DNS             *DNSSection    `json:"dns"`              // DNS caching, i.e. {"max_ttl": "1m"}
+	VerifyDigest    bool           `json:"verify_digest"`    // Check bodies against Content-Digest, Digest and Content-MD5 headers
+	StarvationLimit int            `json:"starvation_limit"` // Requests of higher priority a waiting one is passed over by at most, 10 by default
+	MaxPending      int            `json:"max_pending"`      // Requests held in memory for prioritization without -queue, 1000 by default
+	MaxRedirects    int            `json:"max_redirects"`    // Redirects followed at most, 10 by default and -1 for none
+	Retry           *RetrySection  `json:"retry"`            // Retries of failed requests, i.e. {"attempts": 3, "backoff": "200ms"}
+}
+
+var (
+	userAgent       string
+	maxBodySize     int64
+	verifyDigest    bool
+	starvationLimit = defaultStarvationLimit
+	maxPending      = defaultMaxPending
+	maxRedirects    = defaultMaxRedirects
+	portTimeout     *time.Duration // Set by the TIMEOUT or DEFAULTS port, takes precedence over options
+	warmer          *Warmer
+	transports      *Transports
+	dnsCache        = NewDNSCache()
+)
+
+// applyOptions reconfigures a given client with options IP payload
+func applyOptions(payload []byte, client *http.Client, tr *http.Transport) error {
+	options, err := httputils.ParseOptions(payload)
+	if err != nil {
+		return err
+	}
+	var section Section
+	if err = httputils.DecodeSection(options.Client, &section); err != nil {
+		return err
+	}
+
+	// Probes share the transport and settings being changed, they are resumed
+	// with the previous hosts if options fail to apply
+	if warmer == nil {
+		warmer = NewWarmer(tr)
+	}
+	warmup := warmer.Section()
+	warmer.Stop()
+	defer func() { warmer.Start(warmup) }()
+
+	client.Timeout = *requestTimeout
+	if options.Timeouts.Request > 0 {
+		client.Timeout = time.Duration(options.Timeouts.Request)
+	}
+	if portTimeout != nil {
+		client.Timeout = *portTimeout
+	}
+	tr.IdleConnTimeout = time.Duration(options.Timeouts.Idle)
+	if options.TLS != nil {
+		cfg, err := clientTLS(options.TLS).ClientConfig()
+		if err != nil {
+			return err
+		}
+		tr.TLSClientConfig = cfg
+		tr.CloseIdleConnections()
+		applyTLSVerify(tr)
+	}
+	if err = options.Backpressure.Validate(); err != nil {
+		return err
+	}
+	for _, outlet := range []*httputils.Outlet{respOutlet, bodyOutlet} {
+		if outlet != nil {
+			outlet.Apply(&options.Backpressure)
+		}
+	}
+	maxBodySize = options.Limits.MaxBodySize
+	if portMaxBody != nil {
+		maxBodySize = *portMaxBody
+	}
+	dumper.Apply(&options.Dump)
+	userAgent = section.UserAgent
+	verifyDigest = section.VerifyDigest
+	starvationLimit = defaultStarvationLimit
+	if section.StarvationLimit > 0 {
+		starvationLimit = section.StarvationLimit
+	}
+	maxPending = defaultMaxPending
+	if section.MaxPending > 0 {
+		maxPending = section.MaxPending
+	}
+	maxRedirects = defaultMaxRedirects
+	if section.MaxRedirects != 0 {
+		maxRedirects = section.MaxRedirects
+	}
+	if err = applyRetry(section.Retry); err != nil {
+		return err
+	}
+	dnsCache.Apply(section.DNS)
+	if transports != nil {
+		transports.Reset()
+	}
+	httputils.Redaction.Apply(&options.Redact)
+	if err = options.Logging.Apply(logger); err != nil {
+		return err
+	}
+
+	warmup = section.Warmup
+
+	logger.Info("Applied options", "timeout", client.Timeout.String(), "max_body_size", maxBodySize)
+	return nil
+}
+
+// defaultMaxRedirects is the limit of net/http
+const defaultMaxRedirects = 10
+
+// setTimeout changes the default timeout of requests to a TIMEOUT payload, a
+// duration (i.e. 500ms) or a number of seconds, 0 disables the timeout
+func setTimeout(payload []byte, client *http.Client) error {
+	value := strings.Trim(strings.TrimSpace(string(payload)), `"`)
+	d, err := time.ParseDuration(value)
+	if err != nil {
+		seconds, ferr := strconv.ParseFloat(value, 64)
+		if ferr != nil {
+			return fmt.Errorf("invalid timeout %q", value)
+		}
+		d = time.Duration(seconds * float64(time.Second))
+	}
+	if d < 0 {
+		return fmt.Errorf("negative timeout %q", value)
+	}
+	portTimeout = &d
+	client.Timeout = d
+	return nil
+}
+
+// clientTLS merges tls section of options over -tls flags
+func clientTLS(section *httputils.TLSOptions) *httputils.TLSOptions {
+	t := &httputils.TLSOptions{
+		CertFile:   *tlsCertFile,
+		KeyFile:    *tlsKeyFile,
+		CAFile:     *tlsCAFile,
+		ServerName: *tlsServerName,
+		Insecure:   *tlsInsecure,
+	}
+	if section == nil {
+		return t
+	}
+	merged := *section
+	if merged.CertFile == "" && merged.KeyFile == "" {
+		merged.CertFile, merged.KeyFile = t.CertFile, t.KeyFile
+	}
+	if merged.CAFile == "" {
+		merged.CAFile = t.CAFile
+	}
+	if merged.ServerName == "" {
+		merged.ServerName = t.ServerName
+	}
+	merged.Insecure = merged.Insecure || t.Insecure
+	return &merged
+}
+
+// errBodyTooLarge is returned when response body exceeds configured limit
+var errBodyTooLarge = errors.New("response body exceeds max_body_size")
+
+// limitedBody fails reading after a given number of bytes
+type limitedBody struct {
+	io.ReadCloser
+	left int64
+}
+
+func (b *limitedBody) Read(p []byte) (int, error) {
+	if b.left <= 0 {
+		return 0, errBodyTooLarge
+	}
+	if int64(len(p)) > b.left+1 {
+		p = p[:b.left+1]
+	}
+	n, err := b.ReadCloser.Read(p)
+	b.left -= int64(n)
+	if b.left < 0 {
+		return n, errBodyTooLarge
+	}
+	return n, err
+}
+
+// limitResponse applies max_body_size or a per-request limit overriding it
+// (negative for none) to the response body
+func limitResponse(response *http.Response, override int64) {
+	limit := maxBodySize
+	if override != 0 {
+		limit = override
+	}
+	if limit > 0 {
+		response.Body = &limitedBody{ReadCloser: response.Body, left: limit}
+	}
+}
@@ -0,0 +1,130 @@
+package main
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	httputils "github.com/cascades-fbp/cascades-http/utils"
+)
+
+// transportKey selects a variant of the transport for requests overriding
+// TLS verification or proxy
+type transportKey struct {
+	verify int8 // 0 keeps the client setting, 1 verifies, -1 skips verification
+	proxy  string
+}
+
+type transportKeyContext struct{}
+
+// Transports sends requests with per-request TLS verification or proxy through
+// clones of the client transport, created once per combination of overrides
+type Transports struct {
+	base   *http.Transport
+	mu     sync.Mutex
+	clones map[transportKey]*http.Transport
+}
+
+// NewTransports creates variants of a given transport
+func NewTransports(base *http.Transport) *Transports {
+	return &Transports{base: base, clones: make(map[transportKey]*http.Transport)}
+}
+
+// RoundTrip implements http.RoundTripper
+func (t *Transports) RoundTrip(request *http.Request) (*http.Response, error) {
+	key, ok := request.Context().Value(transportKeyContext{}).(transportKey)
+	if !ok || key == (transportKey{}) {
+		return t.base.RoundTrip(request)
+	}
+	return t.transport(key).RoundTrip(request)
+}
+
+// Reset drops clones, so changes of the base transport apply to all requests
+func (t *Transports) Reset() {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	for _, clone := range t.clones {
+		clone.CloseIdleConnections()
+	}
+	t.clones = make(map[transportKey]*http.Transport)
+}
+
+func (t *Transports) transport(key transportKey) *http.Transport {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if clone, ok := t.clones[key]; ok {
+		return clone
+	}
+	clone := t.base.Clone()
+	if key.verify != 0 {
+		cfg := &tls.Config{}
+		if clone.TLSClientConfig != nil {
+			cfg = clone.TLSClientConfig.Clone()
+		}
+		cfg.InsecureSkipVerify = key.verify < 0
+		clone.TLSClientConfig = cfg
+	}
+	if key.proxy != "" {
+		// Validated by requestClient
+		clone.Proxy, _ = proxyFunc(key.proxy)
+	}
+	t.clones[key] = clone
+	return clone
+}
+
+// requestClient applies per-request overrides of a REQ payload, returning the
+// client and context to send the request with. URLs the request is redirected
+// from are appended to redirects.
+func requestClient(client *http.Client, options *httputils.HTTPClientOptions, redirects *[]string) (*http.Client, context.Context, error) {
+	ctx := context.Background()
+	var key transportKey
+	if options.TLSVerify != nil {
+		key.verify = -1
+		if *options.TLSVerify {
+			key.verify = 1
+		}
+	}
+	if options.Proxy != "" {
+		if _, err := proxyFunc(options.Proxy); err != nil {
+			return nil, nil, err
+		}
+		key.proxy = options.Proxy
+	}
+	if key != (transportKey{}) {
+		ctx = context.WithValue(ctx, transportKeyContext{}, key)
+	}
+	if options.Timeout < 0 {
+		return nil, nil, fmt.Errorf("negative timeout")
+	}
+	if options.Attempts < 0 || options.Backoff < 0 {
+		return nil, nil, fmt.Errorf("negative retry attempts or backoff")
+	}
+	c := *client
+	if options.Timeout > 0 {
+		c.Timeout = time.Duration(options.Timeout)
+	}
+
+	// The last redirect response is returned once the limit is reached
+	limit := maxRedirects
+	if options.MaxRedirects != 0 {
+		limit = options.MaxRedirects
+	}
+	follow := followRedirects
+	if options.FollowRedirects != nil {
+		follow = *options.FollowRedirects
+	}
+	if !follow {
+		limit = 0
+	}
+	c.CheckRedirect = func(request *http.Request, via []*http.Request) error {
+		if len(via) > limit {
+			return http.ErrUseLastResponse
+		}
+		*redirects = append(*redirects, via[len(via)-1].URL.String())
+		return nil
+	}
+	return &c, ctx, nil
+}
@@ -0,0 +1,82 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+
+	httputils "github.com/cascades-fbp/cascades-http/utils"
+)
+
+// maxPendingBodies bounds bodies waiting for their requests
+const maxPendingBodies = 1024
+
+// bodyQueue keeps raw bodies received from the PAYLOAD port until requests
+// which may carry a body take them in order of arrival
+type bodyQueue struct {
+	items       [][]byte
+	contentType string // Last value received from the TYPE port
+}
+
+var bodies bodyQueue
+
+// Add queues a body IP payload
+func (q *bodyQueue) Add(payload []byte) error {
+	if len(q.items) >= maxPendingBodies {
+		return fmt.Errorf("too many pending bodies")
+	}
+	q.items = append(q.items, payload)
+	return nil
+}
+
+// SetType changes the content type of bodies taken from now on, empty one
+// falls back to request headers and detection
+func (q *bodyQueue) SetType(payload []byte) {
+	q.contentType = strings.TrimSpace(string(payload))
+}
+
+// Attach sets the next pending body to a REQ payload of a POST, PUT or PATCH
+// request which has neither form nor body. Otherwise the payload is returned
+// unchanged.
+func (q *bodyQueue) Attach(payload []byte) ([]byte, error) {
+	if len(q.items) == 0 {
+		return payload, nil
+	}
+	var options *httputils.HTTPClientOptions
+	if err := json.Unmarshal(payload, &options); err != nil || options == nil {
+		return payload, err
+	}
+	if options.Form != nil || options.Body != nil {
+		return payload, nil
+	}
+	switch strings.ToUpper(options.Method) {
+	case http.MethodPost, http.MethodPut, http.MethodPatch:
+	default:
+		return payload, nil
+	}
+	options.Body = q.items[0]
+	if !hasContentType(options) {
+		options.ContentType = q.contentType
+	}
+	data, err := json.Marshal(options)
+	if err != nil {
+		return payload, err
+	}
+	q.items[0] = nil
+	q.items = q.items[1:]
+	return data, nil
+}
+
+// hasContentType checks if a request sets Content-Type by its field or headers
+func hasContentType(options *httputils.HTTPClientOptions) bool {
+	if options.ContentType != "" {
+		return true
+	}
+	for k := range options.Headers {
+		if http.CanonicalHeaderKey(k) == "Content-Type" {
+			return true
+		}
+	}
+	return false
+}
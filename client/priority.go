@@ -0,0 +1,238 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync/atomic"
+)
+
+const (
+	// Priorities of requests are clamped to this range, higher ones are sent first
+	minPriority = -10
+	maxPriority = 10
+
+	defaultStarvationLimit = 10
+	defaultMaxPending      = 1000
+
+	// laneDirPrefix names directories of persistent lanes other than priority 0,
+	// which is kept in the queue directory itself
+	laneDirPrefix = "priority_"
+)
+
+// fifo is a queue of request payloads, either persistent or in memory
+type fifo interface {
+	Push(data []byte) error
+	Peek() ([]byte, error)
+	Ack() error
+	Len() int
+	Close() error
+}
+
+// memoryQueue is a fifo of requests used without -queue flag
+type memoryQueue struct {
+	items [][]byte
+}
+
+func (q *memoryQueue) Push(data []byte) error {
+	q.items = append(q.items, data)
+	return nil
+}
+
+func (q *memoryQueue) Peek() ([]byte, error) {
+	if len(q.items) == 0 {
+		return nil, nil
+	}
+	return q.items[0], nil
+}
+
+func (q *memoryQueue) Ack() error {
+	if len(q.items) > 0 {
+		q.items[0] = nil
+		q.items = q.items[1:]
+	}
+	return nil
+}
+
+func (q *memoryQueue) Len() int     { return len(q.items) }
+func (q *memoryQueue) Close() error { return nil }
+
+// lane holds requests of a single priority
+type lane struct {
+	priority   int
+	queue      fifo
+	skipped    int // Requests of higher priority sent while this lane was waiting
+	depth      int64
+	dispatched int64
+}
+
+// Scheduler sends requests of higher priority first. A lane passed over
+// starvation_limit times is served next regardless of its priority, so
+// background requests still make progress under constant user-facing load.
+// It's not safe for concurrent use.
+type Scheduler struct {
+	dir      string
+	lanes    []*lane // By priority, highest first
+	current  *lane   // Lane of the last peeked request
+	promoted int64
+	count    int64
+}
+
+// OpenScheduler creates a scheduler of in-memory lanes, or of persistent lanes
+// in a directory if it isn't empty. Lanes left by a previous run are reopened.
+func OpenScheduler(dir string) (*Scheduler, error) {
+	s := &Scheduler{dir: dir}
+	metrics.CounterFunc("cascades_http_request_queue_promoted_total", "Number of requests sent before higher priorities to prevent starvation", func() float64 {
+		return float64(atomic.LoadInt64(&s.promoted))
+	})
+	if dir == "" {
+		return s, nil
+	}
+	if _, err := s.lane(0); err != nil {
+		return nil, err
+	}
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		s.Close()
+		return nil, err
+	}
+	for _, e := range entries {
+		if !e.IsDir() || !strings.HasPrefix(e.Name(), laneDirPrefix) {
+			continue
+		}
+		p, err := strconv.Atoi(strings.TrimPrefix(e.Name(), laneDirPrefix))
+		if err != nil || p == 0 || p < minPriority || p > maxPriority {
+			continue
+		}
+		if _, err = s.lane(p); err != nil {
+			s.Close()
+			return nil, err
+		}
+	}
+	return s, nil
+}
+
+// Push queues a request payload by its priority field
+func (s *Scheduler) Push(data []byte) error {
+	var options struct {
+		Priority int `json:"priority"`
+	}
+	json.Unmarshal(data, &options)
+	p := options.Priority
+	if p < minPriority {
+		p = minPriority
+	}
+	if p > maxPriority {
+		p = maxPriority
+	}
+	l, err := s.lane(p)
+	if err != nil {
+		return err
+	}
+	if err = l.queue.Push(data); err != nil {
+		return err
+	}
+	atomic.AddInt64(&l.depth, 1)
+	atomic.AddInt64(&s.count, 1)
+	return nil
+}
+
+// Len returns the number of pending requests
+func (s *Scheduler) Len() int {
+	return int(atomic.LoadInt64(&s.count))
+}
+
+// Peek returns the next request to send or nil if there are none, the same
+// request is returned until it's acknowledged unless a more urgent one arrives
+func (s *Scheduler) Peek() ([]byte, error) {
+	s.current = nil
+	for _, l := range s.lanes {
+		if l.queue.Len() == 0 {
+			continue
+		}
+		if s.current == nil {
+			s.current = l
+		} else if l.skipped >= starvationLimit && l.skipped > s.current.skipped {
+			s.current = l
+		}
+	}
+	if s.current == nil {
+		return nil, nil
+	}
+	return s.current.queue.Peek()
+}
+
+// Ack removes the last peeked request, lanes of lower priority waiting for it
+// count it as skipped
+func (s *Scheduler) Ack() error {
+	l := s.current
+	if l == nil {
+		return nil
+	}
+	if err := l.queue.Ack(); err != nil {
+		return err
+	}
+	s.current = nil
+	atomic.AddInt64(&l.depth, -1)
+	atomic.AddInt64(&s.count, -1)
+	atomic.AddInt64(&l.dispatched, 1)
+	if l.skipped >= starvationLimit {
+		atomic.AddInt64(&s.promoted, 1)
+	}
+	l.skipped = 0
+	for _, other := range s.lanes {
+		if other.priority < l.priority && other.queue.Len() > 0 {
+			other.skipped++
+		}
+	}
+	return nil
+}
+
+// Close closes persistent lanes
+func (s *Scheduler) Close() error {
+	var first error
+	for _, l := range s.lanes {
+		if err := l.queue.Close(); err != nil && first == nil {
+			first = err
+		}
+	}
+	return first
+}
+
+// lane returns the lane of a priority opening it and registering its metrics once
+func (s *Scheduler) lane(p int) (*lane, error) {
+	i := sort.Search(len(s.lanes), func(i int) bool { return s.lanes[i].priority <= p })
+	if i < len(s.lanes) && s.lanes[i].priority == p {
+		return s.lanes[i], nil
+	}
+	l := &lane{priority: p, queue: &memoryQueue{}}
+	if s.dir != "" {
+		dir := s.dir
+		if p != 0 {
+			dir = filepath.Join(s.dir, laneDirPrefix+strconv.Itoa(p))
+		}
+		q, err := OpenQueue(dir)
+		if err != nil {
+			return nil, fmt.Errorf("opening lane of priority %d: %w", p, err)
+		}
+		l.queue = q
+		l.depth = int64(q.Len())
+		atomic.AddInt64(&s.count, l.depth)
+	}
+	s.lanes = append(s.lanes, nil)
+	copy(s.lanes[i+1:], s.lanes[i:])
+	s.lanes[i] = l
+
+	priority := strconv.Itoa(p)
+	metrics.GaugeFunc("cascades_http_request_queue_depth", "Number of requests waiting to be sent by priority", func() float64 {
+		return float64(atomic.LoadInt64(&l.depth))
+	}, "priority", priority)
+	metrics.CounterFunc("cascades_http_request_queue_dispatched_total", "Number of requests sent from the queue by priority", func() float64 {
+		return float64(atomic.LoadInt64(&l.dispatched))
+	}, "priority", priority)
+	return l, nil
+}
@@ -0,0 +1,68 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// parseProxy validates URL of http, https or socks5 proxy
+func parseProxy(value string) (*url.URL, error) {
+	u, err := url.Parse(value)
+	if err != nil {
+		return nil, fmt.Errorf("invalid proxy: %v", err)
+	}
+	switch u.Scheme {
+	case "http", "https", "socks5":
+	default:
+		return nil, fmt.Errorf("unsupported proxy scheme %q", u.Scheme)
+	}
+	if u.Host == "" {
+		return nil, fmt.Errorf("proxy %q without host", value)
+	}
+	return u, nil
+}
+
+// proxyFunc returns the proxy selector of the transport for a given proxy:
+// empty one follows HTTP_PROXY, HTTPS_PROXY and NO_PROXY environment
+// variables, none connects directly
+func proxyFunc(value string) (func(*http.Request) (*url.URL, error), error) {
+	switch value {
+	case "":
+		return http.ProxyFromEnvironment, nil
+	case "none", "direct":
+		return nil, nil
+	}
+	u, err := parseProxy(value)
+	if err != nil {
+		return nil, err
+	}
+	return http.ProxyURL(u), nil
+}
+
+// setProxy changes the default proxy of requests to a PROXY payload, a proxy
+// URL or none, empty one falls back to -proxy flag
+func setProxy(payload []byte, tr *http.Transport) error {
+	proxy := strings.Trim(strings.TrimSpace(string(payload)), `"`)
+	if proxy == "" {
+		proxy = *proxyURL
+	}
+	f, err := proxyFunc(proxy)
+	if err != nil {
+		return err
+	}
+
+	// Probes share the transport being changed
+	if warmer != nil {
+		warmup := warmer.Section()
+		warmer.Stop()
+		defer warmer.Start(warmup)
+	}
+	tr.Proxy = f
+	tr.CloseIdleConnections()
+	if transports != nil {
+		transports.Reset()
+	}
+	return nil
+}
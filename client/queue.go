@@ -0,0 +1,213 @@
+package main
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	queueLogFile    = "queue.log"
+	queueOffsetFile = "queue.offset"
+
+	// recordHeader is length and CRC32 of a record payload
+	recordHeader = 8
+	// maxRecordSize protects from reading garbage as a huge record
+	maxRecordSize = 64 << 20
+	// compactSize is the log size truncated once all records are acknowledged
+	compactSize = 1 << 20
+	// queueRetryInterval is the pause before a failed queued request is repeated
+	queueRetryInterval = 5 * time.Second
+)
+
+// errCorruptRecord is returned for records with invalid size or checksum
+var errCorruptRecord = errors.New("corrupt queue record")
+
+// Queue is a persistent FIFO of request payloads kept in a directory. Records
+// are appended to queue.log, the position of the first pending one is stored in
+// queue.offset. Both are synced to disk before Push and Ack return, so accepted
+// requests survive restarts of the component. It's not safe for concurrent use.
+type Queue struct {
+	dir   string
+	log   *os.File
+	head  int64 // Offset of the first pending record
+	end   int64 // End of the last valid record
+	count int
+}
+
+// OpenQueue opens or creates a queue in a given directory. A record partially
+// written before a crash is discarded.
+func OpenQueue(dir string) (*Queue, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, err
+	}
+	f, err := os.OpenFile(filepath.Join(dir, queueLogFile), os.O_RDWR|os.O_CREATE, 0644)
+	if err != nil {
+		return nil, err
+	}
+	q := &Queue{dir: dir, log: f}
+	if q.head, err = q.readOffset(); err != nil {
+		f.Close()
+		return nil, err
+	}
+	if err = q.scan(); err != nil {
+		f.Close()
+		return nil, err
+	}
+	return q, nil
+}
+
+// Len returns the number of pending records
+func (q *Queue) Len() int {
+	return q.count
+}
+
+// Push appends a record
+func (q *Queue) Push(data []byte) error {
+	if len(data) > maxRecordSize {
+		return fmt.Errorf("record of %d bytes exceeds maximal size", len(data))
+	}
+	buf := make([]byte, recordHeader+len(data))
+	binary.BigEndian.PutUint32(buf[0:4], uint32(len(data)))
+	binary.BigEndian.PutUint32(buf[4:8], crc32.ChecksumIEEE(data))
+	copy(buf[recordHeader:], data)
+	if _, err := q.log.WriteAt(buf, q.end); err != nil {
+		return err
+	}
+	if err := q.log.Sync(); err != nil {
+		return err
+	}
+	q.end += int64(len(buf))
+	q.count++
+	return nil
+}
+
+// Peek returns the first pending record or nil if the queue is empty
+func (q *Queue) Peek() ([]byte, error) {
+	if q.count == 0 {
+		return nil, nil
+	}
+	data, _, err := q.read(q.head)
+	return data, err
+}
+
+// Ack removes the first pending record
+func (q *Queue) Ack() error {
+	if q.count == 0 {
+		return nil
+	}
+	_, next, err := q.read(q.head)
+	if err != nil {
+		return err
+	}
+	if q.count == 1 && next >= compactSize {
+		// Everything is delivered, start the log over. The offset left
+		// beyond the truncated log is reset when the queue is opened.
+		if err = q.log.Truncate(0); err != nil {
+			return err
+		}
+		if err = q.writeOffset(0); err != nil {
+			return err
+		}
+		q.head, q.end, q.count = 0, 0, 0
+		return nil
+	}
+	if err = q.writeOffset(next); err != nil {
+		return err
+	}
+	q.head = next
+	q.count--
+	return nil
+}
+
+// Close closes the log file
+func (q *Queue) Close() error {
+	return q.log.Close()
+}
+
+// read reads a record at a given offset returning offset of the next one
+func (q *Queue) read(offset int64) ([]byte, int64, error) {
+	header := make([]byte, recordHeader)
+	if _, err := q.log.ReadAt(header, offset); err != nil {
+		return nil, 0, err
+	}
+	size := binary.BigEndian.Uint32(header[0:4])
+	if size > maxRecordSize {
+		return nil, 0, fmt.Errorf("%w: size %d at offset %d", errCorruptRecord, size, offset)
+	}
+	data := make([]byte, size)
+	if _, err := q.log.ReadAt(data, offset+recordHeader); err != nil {
+		return nil, 0, err
+	}
+	if crc32.ChecksumIEEE(data) != binary.BigEndian.Uint32(header[4:8]) {
+		return nil, 0, fmt.Errorf("%w: checksum mismatch at offset %d", errCorruptRecord, offset)
+	}
+	return data, offset + recordHeader + int64(size), nil
+}
+
+// scan counts pending records and truncates the log after the last valid one
+func (q *Queue) scan() error {
+	info, err := q.log.Stat()
+	if err != nil {
+		return err
+	}
+	if q.head > info.Size() {
+		logger.Warn("Queue offset is beyond the log, starting over", "offset", q.head, "size", info.Size())
+		q.head = 0
+	}
+	q.end = q.head
+	for q.end < info.Size() {
+		_, next, err := q.read(q.end)
+		if err != nil {
+			if err == io.EOF || err == io.ErrUnexpectedEOF || errors.Is(err, errCorruptRecord) {
+				logger.Warn("Discarding incomplete tail of the queue", "offset", q.end, "error", err)
+				break
+			}
+			return err
+		}
+		q.end = next
+		q.count++
+	}
+	if q.end < info.Size() {
+		return q.log.Truncate(q.end)
+	}
+	return nil
+}
+
+func (q *Queue) readOffset() (int64, error) {
+	data, err := ioutil.ReadFile(filepath.Join(q.dir, queueOffsetFile))
+	if errors.Is(err, os.ErrNotExist) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, err
+	}
+	return strconv.ParseInt(strings.TrimSpace(string(data)), 10, 64)
+}
+
+// writeOffset replaces the offset file atomically
+func (q *Queue) writeOffset(offset int64) error {
+	path := filepath.Join(q.dir, queueOffsetFile)
+	tmp, err := os.Create(path + ".tmp")
+	if err != nil {
+		return err
+	}
+	if _, err = tmp.WriteString(strconv.FormatInt(offset, 10)); err == nil {
+		err = tmp.Sync()
+	}
+	if cerr := tmp.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
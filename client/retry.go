@@ -0,0 +1,151 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strconv"
+	"sync/atomic"
+	"time"
+
+	httputils "github.com/cascades-fbp/cascades-http/utils"
+)
+
+// RetrySection configures retries of failed requests inside the client
+type RetrySection struct {
+	Attempts   int                `json:"attempts"`    // Attempts of every request including the first one, 1 (no retries) by default
+	Backoff    httputils.Duration `json:"backoff"`     // Delay before the first retry, doubled with every attempt (500ms by default)
+	MaxBackoff httputils.Duration `json:"max_backoff"` // Upper bound of delays including Retry-After of responses, 30s by default
+	Statuses   []int              `json:"statuses"`    // Retried response statuses, all 5xx if empty
+	Network    *bool              `json:"network"`     // Retry connection errors and timeouts, true by default
+	All        bool               `json:"all"`         // Retry non-idempotent methods (POST, PATCH) too
+}
+
+const (
+	defaultBackoff    = 500 * time.Millisecond
+	defaultMaxBackoff = 30 * time.Second
+)
+
+var (
+	retrySection RetrySection
+	retries      int64
+)
+
+// idempotent methods are retried without all set in retry section, others
+// only when the request sets attempts itself
+var idempotent = map[string]bool{
+	http.MethodGet:     true,
+	http.MethodHead:    true,
+	http.MethodPut:     true,
+	http.MethodDelete:  true,
+	http.MethodOptions: true,
+	http.MethodTrace:   true,
+}
+
+// applyRetry validates the retry section and applies defaults
+func applyRetry(section *RetrySection) error {
+	r := RetrySection{}
+	if section != nil {
+		r = *section
+	}
+	if r.Attempts < 0 || r.Backoff < 0 || r.MaxBackoff < 0 {
+		return fmt.Errorf("negative values in retry section")
+	}
+	if r.Attempts == 0 {
+		r.Attempts = 1
+	}
+	if r.Backoff == 0 {
+		r.Backoff = httputils.Duration(defaultBackoff)
+	}
+	if r.MaxBackoff == 0 {
+		r.MaxBackoff = httputils.Duration(defaultMaxBackoff)
+	}
+	retrySection = r
+	return nil
+}
+
+// send performs a request retrying failures by the retry section or overrides
+// of the request. It returns the last response or error and the number of attempts.
+func send(client *http.Client, request *http.Request, options *httputils.HTTPClientOptions) (*http.Response, int, error) {
+	attempts := retrySection.Attempts
+	if !retrySection.All && !idempotent[request.Method] {
+		attempts = 1
+	}
+	if options.Attempts > 0 {
+		attempts = options.Attempts
+	}
+	backoff := time.Duration(retrySection.Backoff)
+	if options.Backoff > 0 {
+		backoff = time.Duration(options.Backoff)
+	}
+
+	for attempt := 1; ; attempt++ {
+		if attempt > 1 && request.GetBody != nil {
+			body, err := request.GetBody()
+			if err != nil {
+				return nil, attempt - 1, err
+			}
+			request.Body = body
+		}
+		response, err := client.Do(request)
+		if attempt >= attempts || !retryable(response, err) || shutdown.Stopping() {
+			return response, attempt, err
+		}
+
+		delay := backoff
+		if response != nil {
+			if after := retryAfter(response.Header.Get("Retry-After")); after > delay {
+				delay = after
+			}
+			io.Copy(ioutil.Discard, io.LimitReader(response.Body, 64<<10))
+			response.Body.Close()
+			err = fmt.Errorf("status %d", response.StatusCode)
+		}
+		if max := time.Duration(retrySection.MaxBackoff); delay > max {
+			delay = max
+		}
+		logger.Warn("HTTP request failed, retrying", "method", request.Method, "url", request.URL.String(), "attempt", attempt, "delay", delay.String(), "error", err)
+		atomic.AddInt64(&retries, 1)
+		select {
+		case <-shutdown.Done():
+			return nil, attempt, fmt.Errorf("retry interrupted by shutdown: %v", err)
+		case <-time.After(delay):
+		}
+		backoff *= 2
+	}
+}
+
+// retryable checks if a failed attempt should be repeated
+func retryable(response *http.Response, err error) bool {
+	if err != nil {
+		if retrySection.Network != nil && !*retrySection.Network {
+			return false
+		}
+		category := httputils.ClassifyError(err)
+		return category == httputils.ErrNetwork || category == httputils.ErrTimeout
+	}
+	if len(retrySection.Statuses) == 0 {
+		return response.StatusCode >= 500
+	}
+	for _, status := range retrySection.Statuses {
+		if response.StatusCode == status {
+			return true
+		}
+	}
+	return false
+}
+
+// retryAfter parses Retry-After header in seconds or as HTTP date
+func retryAfter(value string) time.Duration {
+	if value == "" {
+		return 0
+	}
+	if seconds, err := strconv.Atoi(value); err == nil && seconds > 0 {
+		return time.Duration(seconds) * time.Second
+	}
+	if t, err := http.ParseTime(value); err == nil {
+		return time.Until(t)
+	}
+	return 0
+}
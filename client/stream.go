@@ -0,0 +1,69 @@
+package main
+
+import (
+	"encoding/json"
+	"io"
+
+	httputils "github.com/cascades-fbp/cascades-http/utils"
+	"github.com/cascades-fbp/cascades/runtime"
+)
+
+// streaming checks if the body of a request's response is sent to BODY in
+// chunks instead of being buffered, which needs BODY to be connected
+func streaming(options *httputils.HTTPClientOptions) bool {
+	if bodyOutlet == nil {
+		return false
+	}
+	if options.Stream != nil {
+		return *options.Stream
+	}
+	return *streamFlag
+}
+
+// streamBody sends a body to BODY as a bracketed substream of chunks of -chunk
+// bytes (the last one may be shorter). The substream is closed also when
+// reading fails, the error is returned with the number of bytes sent.
+func streamBody(body io.ReadCloser) (int64, error) {
+	defer body.Close()
+	bodyOutlet.Send(runtime.NewOpenBracket())
+	defer bodyOutlet.Send(runtime.NewCloseBracket())
+
+	var total int64
+	buf := make([]byte, *chunkSize)
+	for {
+		n, err := io.ReadFull(body, buf)
+		if n > 0 {
+			chunk := make([]byte, n)
+			copy(chunk, buf[:n])
+			bodyOutlet.Send(runtime.NewPacket(chunk))
+			total += int64(n)
+		}
+		if err == io.EOF || err == io.ErrUnexpectedEOF {
+			return total, nil
+		}
+		if err != nil {
+			return total, err
+		}
+	}
+}
+
+// BodyFrame is the JSON frame preceding the raw body frame on BODY with -binary flag
+type BodyFrame struct {
+	Status int    `json:"status"` // Status code of the response
+	URL    string `json:"url"`    // Final URL of the response
+	Type   string `json:"type"`   // Content-Type of the body
+}
+
+// bodyIP returns an IP of a buffered body for BODY: raw bytes in the payload,
+// or with -binary flag a framed IP with the raw body in a frame following
+// BodyFrame JSON, like framed responses of RESP
+func bodyIP(resp *httputils.HTTPResponse) ([][]byte, error) {
+	if !*binaryFlag {
+		return runtime.NewPacket(resp.Body), nil
+	}
+	meta, err := json.Marshal(&BodyFrame{Status: resp.StatusCode, URL: resp.URL, Type: resp.GetHeader("Content-Type")})
+	if err != nil {
+		return nil, err
+	}
+	return append(runtime.NewPacket(meta), resp.Body), nil
+}
@@ -0,0 +1,221 @@
+package main
+
+import (
+	"context"
+	"crypto/tls"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptrace"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	httputils "github.com/cascades-fbp/cascades-http/utils"
+)
+
+// WarmupSection describes warm-up of connections in the client section of options
+type WarmupSection struct {
+	Hosts       []string           `json:"hosts"`       // Base URLs or host[:port] (https is assumed) to keep connections to
+	Path        string             `json:"path"`        // Path probed with HEAD requests, / by default
+	Interval    httputils.Duration `json:"interval"`    // Probe interval keeping connections from going idle, 30s by default
+	Connections int                `json:"connections"` // Connections kept per host, 1 by default
+}
+
+const (
+	defaultWarmupInterval = 30 * time.Second
+	warmupTimeout         = 10 * time.Second
+)
+
+// probeStats are the last results of probing a host, durations are in seconds
+type probeStats struct {
+	mu        sync.Mutex
+	dns       float64
+	connect   float64
+	handshake float64
+	probes    int64
+	failures  int64
+}
+
+// target is a probed URL with stats of its host
+type target struct {
+	url   string
+	stats *probeStats
+}
+
+// Warmer pre-establishes connections of a transport and keeps them warm, so the
+// first requests to known hosts don't pay for DNS, TCP and TLS handshakes
+type Warmer struct {
+	tr      *http.Transport
+	stats   map[string]*probeStats
+	section *WarmupSection
+
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+}
+
+// NewWarmer creates a warmer of a given transport
+func NewWarmer(tr *http.Transport) *Warmer {
+	return &Warmer{tr: tr, stats: make(map[string]*probeStats)}
+}
+
+// Section returns the section the warmer was started with
+func (w *Warmer) Section() *WarmupSection {
+	return w.section
+}
+
+// Start begins probing hosts of a section in background, probes run right away
+// and then periodically. Running probes are stopped first.
+func (w *Warmer) Start(section *WarmupSection) {
+	w.Stop()
+	w.section = section
+	if section == nil || len(section.Hosts) == 0 {
+		return
+	}
+	interval := time.Duration(section.Interval)
+	if interval <= 0 {
+		interval = defaultWarmupInterval
+	}
+	conns := section.Connections
+	if conns <= 0 {
+		conns = 1
+	}
+	path := section.Path
+	if path == "" {
+		path = "/"
+	}
+	var targets []target
+	for _, host := range section.Hosts {
+		u := strings.TrimSuffix(host, "/")
+		if !strings.Contains(u, "://") {
+			u = "https://" + u
+		}
+		targets = append(targets, target{url: u + path, stats: w.watch(u)})
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	w.cancel = cancel
+	w.wg.Add(1)
+	go func() {
+		defer w.wg.Done()
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			w.probeAll(ctx, targets, conns)
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+	logger.Info("Started connection warm-up", "hosts", len(targets), "interval", interval.String(), "connections", conns)
+}
+
+// Stop cancels probing and waits for running probes to finish
+func (w *Warmer) Stop() {
+	if w.cancel == nil {
+		return
+	}
+	w.cancel()
+	w.wg.Wait()
+	w.cancel = nil
+}
+
+// watch returns stats of a host registering their metrics once
+func (w *Warmer) watch(host string) *probeStats {
+	if s, ok := w.stats[host]; ok {
+		return s
+	}
+	s := &probeStats{}
+	w.stats[host] = s
+	read := func(v *float64) func() float64 {
+		return func() float64 {
+			s.mu.Lock()
+			defer s.mu.Unlock()
+			return *v
+		}
+	}
+	metrics.GaugeFunc("cascades_http_warmup_dns_seconds", "Duration of DNS lookup of the last new warm-up connection", read(&s.dns), "host", host)
+	metrics.GaugeFunc("cascades_http_warmup_connect_seconds", "Duration of TCP connect of the last new warm-up connection", read(&s.connect), "host", host)
+	metrics.GaugeFunc("cascades_http_warmup_handshake_seconds", "Duration of TLS handshake of the last new warm-up connection", read(&s.handshake), "host", host)
+	metrics.CounterFunc("cascades_http_warmup_probes_total", "Number of warm-up probes", func() float64 {
+		return float64(atomic.LoadInt64(&s.probes))
+	}, "host", host)
+	metrics.CounterFunc("cascades_http_warmup_failures_total", "Number of failed warm-up probes", func() float64 {
+		return float64(atomic.LoadInt64(&s.failures))
+	}, "host", host)
+	return s
+}
+
+// probeAll probes all hosts with a number of concurrent requests each, so as many
+// connections are kept in the idle pool of the transport
+func (w *Warmer) probeAll(ctx context.Context, targets []target, conns int) {
+	var wg sync.WaitGroup
+	for _, t := range targets {
+		for i := 0; i < conns; i++ {
+			wg.Add(1)
+			go func(t target) {
+				defer wg.Done()
+				w.probe(ctx, t.url, t.stats)
+			}(t)
+		}
+	}
+	wg.Wait()
+}
+
+// probe sends a HEAD request reporting handshake latency of new connections
+func (w *Warmer) probe(ctx context.Context, u string, s *probeStats) {
+	ctx, cancel := context.WithTimeout(ctx, warmupTimeout)
+	defer cancel()
+
+	var (
+		dnsStart, connectStart, tlsStart time.Time
+		dns, connect, handshake          time.Duration
+		reused                           bool
+	)
+	trace := &httptrace.ClientTrace{
+		DNSStart:          func(httptrace.DNSStartInfo) { dnsStart = time.Now() },
+		DNSDone:           func(httptrace.DNSDoneInfo) { dns = time.Since(dnsStart) },
+		ConnectStart:      func(string, string) { connectStart = time.Now() },
+		ConnectDone:       func(string, string, error) { connect = time.Since(connectStart) },
+		TLSHandshakeStart: func() { tlsStart = time.Now() },
+		TLSHandshakeDone:  func(tls.ConnectionState, error) { handshake = time.Since(tlsStart) },
+		GotConn:           func(info httptrace.GotConnInfo) { reused = info.Reused },
+	}
+	request, err := http.NewRequest(http.MethodHead, u, nil)
+	if err != nil {
+		logger.Warn("Invalid warm-up URL", "url", httputils.Redaction.URL(u), "error", err)
+		return
+	}
+	request = request.WithContext(httptrace.WithClientTrace(ctx, trace))
+	if userAgent != "" {
+		request.Header.Set("User-Agent", userAgent)
+	}
+
+	atomic.AddInt64(&s.probes, 1)
+	started := time.Now()
+	response, err := w.tr.RoundTrip(request)
+	if err != nil {
+		if ctx.Err() == context.Canceled {
+			return
+		}
+		atomic.AddInt64(&s.failures, 1)
+		logger.Warn("Warm-up probe failed", "url", httputils.Redaction.URL(u), "error", err)
+		return
+	}
+	// The body has to be drained for the connection to return to the idle pool
+	io.Copy(ioutil.Discard, response.Body)
+	response.Body.Close()
+
+	if reused {
+		logger.Debug("Warm-up connection is alive", "url", httputils.Redaction.URL(u), "status", response.StatusCode, "latency", time.Since(started).String())
+		return
+	}
+	s.mu.Lock()
+	s.dns, s.connect, s.handshake = dns.Seconds(), connect.Seconds(), handshake.Seconds()
+	s.mu.Unlock()
+	logger.Info("Established warm-up connection", "url", httputils.Redaction.URL(u), "status", response.StatusCode,
+		"dns", dns.String(), "connect", connect.String(), "tls", handshake.String(), "latency", time.Since(started).String())
+}
@@ -0,0 +1,172 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/cascades-fbp/cascades-http/testutils"
+	httputils "github.com/cascades-fbp/cascades-http/utils"
+)
+
+// timeout of waiting for a single IP or HTTP response
+const timeout = 30 * time.Second
+
+// Env holds addresses substituted into graphs: {{listen}} is a free local TCP
+// address, {{upstream}} URL and {{upstream_host}} host of a test upstream server
+type Env struct {
+	Listen   string
+	Upstream string
+}
+
+// Example is a graph shipped in graphs/ with a check of its behavior
+type Example struct {
+	Name        string
+	Description string
+	Check       func(t *runner, n *Network, env *Env)
+}
+
+var examples = []*Example{
+	{"api-proxy", "Egress proxy restricted to the upstream API", checkAPIProxy},
+	{"webhook", "Webhook receiver answering routed requests", checkWebhook},
+	{"poller", "Periodic health probing of the upstream", checkPoller},
+}
+
+// upstreamHandler is the API used by examples
+func upstreamHandler(rw http.ResponseWriter, req *http.Request) {
+	switch req.URL.Path {
+	case "/health":
+		fmt.Fprint(rw, "ok")
+	default:
+		rw.Header().Set("Content-Type", "application/json")
+		fmt.Fprintf(rw, `{"path": %q}`, req.URL.Path)
+	}
+}
+
+func checkAPIProxy(t *runner, n *Network, env *Env) {
+	events := n.Outport(t, "EVENTS")
+	proxy, _ := url.Parse("http://" + env.Listen)
+	client := &http.Client{
+		Transport: &http.Transport{Proxy: http.ProxyURL(proxy)},
+		Timeout:   timeout,
+	}
+
+	resp := retryHTTP(t, func() (*http.Response, error) {
+		return client.Get(env.Upstream + "/api/users")
+	})
+	body := readBody(t, resp)
+	if resp.StatusCode != http.StatusOK || !strings.Contains(body, "/api/users") {
+		t.Fatalf("Unexpected upstream response through proxy: %d %s", resp.StatusCode, body)
+	}
+	testutils.Receive(t, events, timeout)
+
+	// Same upstream by a different name isn't on the allow-list
+	blocked := strings.Replace(env.Upstream, "127.0.0.1", "localhost", 1)
+	resp, err := client.Get(blocked + "/api/users")
+	if err != nil {
+		t.Fatalf("Request to blocked destination failed: %v", err)
+	}
+	readBody(t, resp)
+	if resp.StatusCode != http.StatusForbidden {
+		t.Fatalf("Expected 403 for blocked destination, got %d", resp.StatusCode)
+	}
+}
+
+func checkWebhook(t *runner, n *Network, env *Env) {
+	hooks := n.Outport(t, "HOOKS")
+	base := "http://" + env.Listen
+
+	resp := retryHTTP(t, func() (*http.Response, error) {
+		return http.Get(base + "/unknown")
+	})
+	readBody(t, resp)
+	if resp.StatusCode != http.StatusNotFound {
+		t.Fatalf("Expected 404 for unknown path, got %d", resp.StatusCode)
+	}
+
+	type result struct {
+		resp *http.Response
+		err  error
+	}
+	done := make(chan result, 1)
+	go func() {
+		resp, err := http.Post(base+"/hooks/github", "application/json", strings.NewReader(`{"zen": "Keep it simple"}`))
+		done <- result{resp, err}
+	}()
+
+	req, err := httputils.IP2Request(testutils.Receive(t, hooks, timeout))
+	if err != nil {
+		t.Fatalf("Invalid request IP on HOOKS: %v", err)
+	}
+	if name := req.Form["name"]; len(name) != 1 || name[0] != "github" {
+		t.Fatalf("Expected name parameter github, got %v", name)
+	}
+	if !strings.Contains(string(req.Body), "Keep it simple") {
+		t.Fatalf("Unexpected webhook body %q", req.Body)
+	}
+	n.Send(t, "REPLY", httputils.NewResponse(http.StatusAccepted).WithID(req.ID).WithText("accepted").MustIP())
+
+	r := <-done
+	if r.err != nil {
+		t.Fatalf("Webhook request failed: %v", r.err)
+	}
+	body := readBody(t, r.resp)
+	if r.resp.StatusCode != http.StatusAccepted || body != "accepted" {
+		t.Fatalf("Unexpected webhook response: %d %s", r.resp.StatusCode, body)
+	}
+}
+
+func checkPoller(t *runner, n *Network, env *Env) {
+	var stats struct {
+		Name string `json:"name"`
+		Up   bool   `json:"up"`
+	}
+	ip := testutils.Receive(t, n.Outport(t, "STATS"), timeout)
+	if err := json.Unmarshal(ip[1], &stats); err != nil {
+		t.Fatalf("Invalid probe result: %v", err)
+	}
+	if stats.Name != "upstream" || !stats.Up {
+		t.Fatalf("Expected upstream to be up, got %s", ip[1])
+	}
+
+	var transition struct {
+		State string `json:"state"`
+	}
+	ip = testutils.Receive(t, n.Outport(t, "STATUS"), timeout)
+	if err := json.Unmarshal(ip[1], &transition); err != nil {
+		t.Fatalf("Invalid transition: %v", err)
+	}
+	if transition.State != "UP" {
+		t.Fatalf("Expected UP transition, got %s", ip[1])
+	}
+}
+
+// retryHTTP repeats a request until components start listening
+func retryHTTP(t *runner, do func() (*http.Response, error)) *http.Response {
+	t.Helper()
+	deadline := time.Now().Add(timeout)
+	for {
+		resp, err := do()
+		if err == nil {
+			return resp
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("No HTTP response in %v: %v", timeout, err)
+		}
+		time.Sleep(100 * time.Millisecond)
+	}
+}
+
+func readBody(t *runner, resp *http.Response) string {
+	t.Helper()
+	defer resp.Body.Close()
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatalf("Failed to read response body: %v", err)
+	}
+	return string(body)
+}
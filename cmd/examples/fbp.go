@@ -0,0 +1,183 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// Port is a port of a process, Index is -1 for non-array ports
+type Port struct {
+	Process string
+	Name    string
+	Index   int
+}
+
+func (p Port) String() string {
+	if p.Index < 0 {
+		return fmt.Sprintf("%s.%s", p.Process, p.Name)
+	}
+	return fmt.Sprintf("%s.%s[%d]", p.Process, p.Name, p.Index)
+}
+
+// Connection connects an output port (or IIP data) to an input port
+type Connection struct {
+	Data string // Initial IP if not empty
+	Src  Port
+	Tgt  Port
+}
+
+// Graph is a parsed .fbp network. Exported ports are left for the runner to
+// send IPs into (Inports) or collect them from (Outports).
+type Graph struct {
+	Processes   map[string]string // Process name to component, i.e. Server -> http/server
+	Connections []*Connection
+	Inports     map[string]Port
+	Outports    map[string]Port
+}
+
+var (
+	processRe = regexp.MustCompile(`^([A-Za-z_][\w]*)(?:\(([\w/\-]*)\))?$`)
+	portRe    = regexp.MustCompile(`^([A-Z_][A-Z0-9_]*)(?:\[(\d+)\])?$`)
+	exportRe  = regexp.MustCompile(`^(INPORT|OUTPORT)=([A-Za-z_]\w*)\.([A-Z_][A-Z0-9_]*(?:\[\d+\])?):([A-Z_][A-Z0-9_]*)$`)
+)
+
+// ParseFBP parses a subset of the FBP language: comments, IIPs, connection chains
+// and INPORT/OUTPORT exports, i.e.
+//
+//	'127.0.0.1:8080' -> OPTIONS Server(http/server)
+//	Server OUT -> REQUEST Router(http/router) FAIL -> IN Server
+//	OUTPORT=Router.SUCCESS[0]:HOOKS
+func ParseFBP(src string) (*Graph, error) {
+	g := &Graph{
+		Processes: make(map[string]string),
+		Inports:   make(map[string]Port),
+		Outports:  make(map[string]Port),
+	}
+	scanner := bufio.NewScanner(strings.NewReader(src))
+	for n := 1; scanner.Scan(); n++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		if err := g.parseLine(line); err != nil {
+			return nil, fmt.Errorf("line %d: %v", n, err)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	for _, c := range g.Connections {
+		for _, p := range []Port{c.Src, c.Tgt} {
+			if p.Process != "" && g.Processes[p.Process] == "" {
+				return nil, fmt.Errorf("component of process %s is not defined", p.Process)
+			}
+		}
+	}
+	return g, nil
+}
+
+func (g *Graph) parseLine(line string) error {
+	if m := exportRe.FindStringSubmatch(line); m != nil {
+		port, err := parsePort(m[3])
+		if err != nil {
+			return err
+		}
+		port.Process = m[2]
+		if m[1] == "INPORT" {
+			g.Inports[m[4]] = port
+		} else {
+			g.Outports[m[4]] = port
+		}
+		return nil
+	}
+
+	var data string
+	if strings.HasPrefix(line, "'") {
+		end := strings.Index(line[1:], "'")
+		if end < 0 {
+			return fmt.Errorf("unterminated IIP")
+		}
+		data = line[1 : end+1]
+		line = "_IIP_ " + strings.TrimSpace(line[end+2:])
+	}
+
+	segments := strings.Split(line, "->")
+	if len(segments) < 2 {
+		return fmt.Errorf("expected connection in %q", line)
+	}
+	var src Port
+	for i, segment := range segments {
+		fields := strings.Fields(segment)
+		switch {
+		case i == 0 && data != "":
+			if len(fields) != 1 {
+				return fmt.Errorf("unexpected %q after IIP", segment)
+			}
+			continue
+		case i == 0:
+			if len(fields) != 2 {
+				return fmt.Errorf("expected \"<Process> <PORT>\" in %q", segment)
+			}
+			name, err := g.parseProcess(fields[0])
+			if err != nil {
+				return err
+			}
+			if src, err = parsePort(fields[1]); err != nil {
+				return err
+			}
+			src.Process = name
+			continue
+		}
+
+		last := i == len(segments)-1
+		if (last && len(fields) != 2) || (!last && len(fields) != 3) {
+			return fmt.Errorf("expected \"<PORT> <Process> [<PORT>]\" in %q", segment)
+		}
+		tgt, err := parsePort(fields[0])
+		if err != nil {
+			return err
+		}
+		if tgt.Process, err = g.parseProcess(fields[1]); err != nil {
+			return err
+		}
+		g.Connections = append(g.Connections, &Connection{Data: data, Src: src, Tgt: tgt})
+		data = ""
+		if !last {
+			if src, err = parsePort(fields[2]); err != nil {
+				return err
+			}
+			src.Process = tgt.Process
+		}
+	}
+	return nil
+}
+
+// parseProcess registers process with optional component declaration
+func (g *Graph) parseProcess(s string) (string, error) {
+	m := processRe.FindStringSubmatch(s)
+	if m == nil {
+		return "", fmt.Errorf("invalid process %q", s)
+	}
+	if m[2] != "" {
+		if c, ok := g.Processes[m[1]]; ok && c != m[2] {
+			return "", fmt.Errorf("process %s redeclared as %s", m[1], m[2])
+		}
+		g.Processes[m[1]] = m[2]
+	}
+	return m[1], nil
+}
+
+func parsePort(s string) (Port, error) {
+	m := portRe.FindStringSubmatch(s)
+	if m == nil {
+		return Port{}, fmt.Errorf("invalid port %q", s)
+	}
+	p := Port{Name: m[1], Index: -1}
+	if m[2] != "" {
+		p.Index, _ = strconv.Atoi(m[2])
+	}
+	return p, nil
+}
@@ -0,0 +1,166 @@
+// Command examples runs end-to-end graphs from graphs/ built of cascades-http
+// components and checks that they behave as described. Components are built
+// from the module, so the command has to be started inside of it.
+//
+//	go run ./cmd/examples -run webhook -v
+package main
+
+import (
+	"embed"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+)
+
+//go:embed graphs/*.fbp
+var graphs embed.FS
+
+var (
+	// Flags
+	runFlag  = flag.String("run", ".", "Run only examples matching the regular expression")
+	listFlag = flag.Bool("list", false, "List examples and print their graphs")
+	verbose  = flag.Bool("v", false, "Print component logs of passing examples too")
+)
+
+func main() {
+	flag.Parse()
+
+	re, err := regexp.Compile(*runFlag)
+	if err != nil {
+		fmt.Println("ERROR: invalid -run expression:", err.Error())
+		os.Exit(1)
+	}
+
+	failed := 0
+	for _, ex := range examples {
+		if !re.MatchString(ex.Name) {
+			continue
+		}
+		src, err := graphs.ReadFile("graphs/" + ex.Name + ".fbp")
+		if err != nil {
+			fmt.Println("ERROR: missing graph of example", ex.Name)
+			os.Exit(1)
+		}
+		if *listFlag {
+			fmt.Printf("%s: %s\n\n%s\n", ex.Name, ex.Description, src)
+			continue
+		}
+
+		start := time.Now()
+		r := run(ex, string(src))
+		if r.failed || *verbose {
+			for _, line := range r.logs {
+				fmt.Println("    " + line)
+			}
+		}
+		status := "PASS"
+		if r.failed {
+			status = "FAIL"
+			failed++
+		}
+		fmt.Printf("--- %s: %s (%.2fs)\n", status, ex.Name, time.Since(start).Seconds())
+	}
+	if failed > 0 {
+		os.Exit(1)
+	}
+}
+
+// run starts the example graph and performs its check
+func run(ex *Example, src string) (r *runner) {
+	r = &runner{}
+	defer r.finish()
+	defer func() {
+		if v := recover(); v != nil {
+			if _, ok := v.(fatal); !ok {
+				r.Log(fmt.Sprint("panic: ", v))
+				r.failed = true
+			}
+		}
+	}()
+
+	upstream := httptest.NewServer(http.HandlerFunc(upstreamHandler))
+	r.Cleanup(upstream.Close)
+
+	env := &Env{Listen: freeAddr(r), Upstream: upstream.URL}
+	src = strings.NewReplacer(
+		"{{listen}}", env.Listen,
+		"{{upstream}}", env.Upstream,
+		"{{upstream_host}}", strings.TrimPrefix(env.Upstream, "http://"),
+	).Replace(src)
+
+	g, err := ParseFBP(src)
+	if err != nil {
+		r.Fatalf("Invalid graph: %v", err)
+	}
+	ex.Check(r, Start(r, g), env)
+	return r
+}
+
+// freeAddr returns a local TCP address nobody listens on
+func freeAddr(t *runner) string {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Failed to find free port: %v", err)
+	}
+	defer ln.Close()
+	return ln.Addr().String()
+}
+
+// fatal is a panic value stopping a failed example
+type fatal struct{}
+
+// runner implements testutils.T for examples running outside of tests
+type runner struct {
+	mu       sync.Mutex
+	logs     []string
+	cleanups []func()
+	dirs     []string
+	failed   bool
+}
+
+func (r *runner) Helper() {}
+
+func (r *runner) Log(args ...interface{}) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.logs = append(r.logs, strings.TrimSuffix(fmt.Sprintln(args...), "\n"))
+}
+
+func (r *runner) Fatalf(format string, args ...interface{}) {
+	r.Log(fmt.Sprintf(format, args...))
+	r.mu.Lock()
+	r.failed = true
+	r.mu.Unlock()
+	panic(fatal{})
+}
+
+func (r *runner) TempDir() string {
+	dir, err := ioutil.TempDir("", "cascades-http-example")
+	if err != nil {
+		r.Fatalf("Failed to create temporary directory: %v", err)
+	}
+	r.dirs = append(r.dirs, dir)
+	return dir
+}
+
+func (r *runner) Cleanup(f func()) {
+	r.cleanups = append(r.cleanups, f)
+}
+
+// finish runs cleanups in reverse order and removes temporary directories
+func (r *runner) finish() {
+	for i := len(r.cleanups) - 1; i >= 0; i-- {
+		r.cleanups[i]()
+	}
+	for _, dir := range r.dirs {
+		os.RemoveAll(dir)
+	}
+}
@@ -0,0 +1,141 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/cascades-fbp/cascades-http/testutils"
+	"github.com/cascades-fbp/cascades/runtime"
+	zmq "github.com/pebbe/zmq4"
+)
+
+// Network is a running graph
+type Network struct {
+	graph    *testutils.Graph
+	inports  map[string]*zmq.Socket
+	outports map[string]*zmq.Socket
+}
+
+// portFlags collects endpoints of a process port by array index
+type portFlags map[string]map[int]string
+
+func (f portFlags) add(p Port, endpoint string) error {
+	name := strings.ToLower(p.Name)
+	if f[name] == nil {
+		f[name] = make(map[int]string)
+	}
+	if prev, ok := f[name][p.Index]; ok && prev != endpoint {
+		return fmt.Errorf("port %s is connected more than once", p)
+	}
+	f[name][p.Index] = endpoint
+	return nil
+}
+
+// flags joins array port endpoints in the order of indexes
+func (f portFlags) flags(process string) (map[string]string, error) {
+	ports := make(map[string]string, len(f))
+	for name, endpoints := range f {
+		indexes := make([]int, 0, len(endpoints))
+		for i := range endpoints {
+			indexes = append(indexes, i)
+		}
+		sort.Ints(indexes)
+		list := make([]string, len(indexes))
+		for i, index := range indexes {
+			if index >= 0 && index != i {
+				return nil, fmt.Errorf("array port %s.%s has no connection at index %d", process, strings.ToUpper(name), i)
+			}
+			list[i] = endpoints[index]
+		}
+		ports[name] = strings.Join(list, ",")
+	}
+	return ports, nil
+}
+
+// Start launches all processes of a parsed graph and sends its IIPs
+func Start(t testutils.T, g *Graph) *Network {
+	t.Helper()
+	n := &Network{
+		graph:    testutils.NewGraph(t),
+		inports:  make(map[string]*zmq.Socket),
+		outports: make(map[string]*zmq.Socket),
+	}
+	endpoint := func(p Port) string {
+		name := p.Process + "." + p.Name
+		if p.Index >= 0 {
+			name = fmt.Sprintf("%s.%d", name, p.Index)
+		}
+		return n.graph.Endpoint(strings.ToLower(name))
+	}
+
+	ports := make(map[string]portFlags, len(g.Processes))
+	for name := range g.Processes {
+		ports[name] = portFlags{}
+	}
+	connect := func(p Port, e string) {
+		if err := ports[p.Process].add(p, e); err != nil {
+			t.Fatalf("Invalid graph: %v", err)
+		}
+	}
+
+	for _, c := range g.Connections {
+		e := endpoint(c.Tgt)
+		connect(c.Tgt, e)
+		if c.Data == "" {
+			connect(c.Src, e)
+		}
+	}
+	for name, p := range g.Inports {
+		e := endpoint(p)
+		connect(p, e)
+		n.inports[name] = n.graph.Input(e)
+	}
+	for name, p := range g.Outports {
+		e := n.graph.Endpoint("export." + strings.ToLower(name))
+		connect(p, e)
+		n.outports[name] = n.graph.Output(e)
+	}
+
+	names := make([]string, 0, len(g.Processes))
+	for name := range g.Processes {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		flags, err := ports[name].flags(name)
+		if err != nil {
+			t.Fatalf("Invalid graph: %v", err)
+		}
+		n.graph.Start(strings.TrimPrefix(g.Processes[name], "http/"), flags)
+	}
+
+	for _, c := range g.Connections {
+		if c.Data != "" {
+			n.graph.Inject(endpoint(c.Tgt), runtime.NewPacket([]byte(c.Data)))
+		}
+	}
+	return n
+}
+
+// Send sends an IP to an exported inport
+func (n *Network) Send(t testutils.T, inport string, ip [][]byte) {
+	t.Helper()
+	s, ok := n.inports[inport]
+	if !ok {
+		t.Fatalf("Graph has no inport %s", inport)
+	}
+	if _, err := s.SendMessage(ip); err != nil {
+		t.Fatalf("Failed to send IP to %s: %v", inport, err)
+	}
+}
+
+// Outport returns socket receiving IPs of an exported outport
+func (n *Network) Outport(t testutils.T, outport string) *zmq.Socket {
+	t.Helper()
+	s, ok := n.outports[outport]
+	if !ok {
+		t.Fatalf("Graph has no outport %s", outport)
+	}
+	return s
+}
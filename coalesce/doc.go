@@ -0,0 +1,57 @@
+package main
+
+import "github.com/cascades-fbp/cascades/library"
+
+var registryEntry = &library.Entry{
+	Description: `Collapses concurrent identical GET requests into a single upstream call. The first request for a
+given method, URI (and configured headers) is forwarded to OUT, identical requests arriving before its
+response are held. The upstream response from RESPONSE port is fanned out to all waiters on RESP.
+Other methods are passed through. Waiters are answered with 504 if the upstream doesn't respond in time.`,
+	Elementary: true,
+	Inports: []library.EntryPort{
+		library.EntryPort{
+			Name:        "OPTIONS",
+			Type:        "json",
+			Description: `Optional configuration port, i.e. {"vary": ["Accept", "Authorization"], "timeout": "30s"}`,
+			Required:    false,
+		},
+		library.EntryPort{
+			Name:        "IN",
+			Type:        "json",
+			Description: "Input port for requests in predefined JSON format",
+			Required:    true,
+		},
+		library.EntryPort{
+			Name:        "RESPONSE",
+			Type:        "json",
+			Description: "Input port for responses from the upstream",
+			Required:    true,
+		},
+	},
+	Outports: []library.EntryPort{
+		library.EntryPort{
+			Name:        "OUT",
+			Type:        "json",
+			Description: "Output port for requests sent to the upstream",
+			Required:    true,
+		},
+		library.EntryPort{
+			Name:        "RESP",
+			Type:        "json",
+			Description: "Output port for responses to all requests",
+			Required:    true,
+		},
+		library.EntryPort{
+			Name:        "LOG",
+			Type:        "json",
+			Description: "Optional output port for structured log entries (log entry JSON object defined in utils of HTTP components library)",
+			Required:    false,
+		},
+		library.EntryPort{
+			Name:        "HEARTBEAT",
+			Type:        "json",
+			Description: "Optional output port for periodic liveness IPs (heartbeat JSON object defined in utils of HTTP components library)",
+			Required:    false,
+		},
+	},
+}
@@ -0,0 +1,92 @@
+package main
+
+import (
+	"net/http"
+	"strings"
+	"time"
+
+	httputils "github.com/cascades-fbp/cascades-http/utils"
+)
+
+// Options describe the configuration IP of the component
+type Options struct {
+	Vary    []string `json:"vary"`    // Headers which make otherwise identical requests different
+	Timeout string   `json:"timeout"` // Maximal time to wait for the upstream response
+}
+
+// flight is an upstream call shared by several requests
+type flight struct {
+	key     string
+	leader  string
+	waiters []string
+	started time.Time
+}
+
+// Flights tracks upstream calls in progress
+type Flights struct {
+	vary     []string
+	byKey    map[string]*flight
+	byLeader map[string]*flight
+}
+
+// NewFlights creates an empty tracker
+func NewFlights(vary []string) *Flights {
+	return &Flights{
+		vary:     vary,
+		byKey:    make(map[string]*flight),
+		byLeader: make(map[string]*flight),
+	}
+}
+
+// Join registers a request. It returns true if the request has to be sent upstream
+// (it's not coalescable or it's the first of its kind).
+func (f *Flights) Join(req *httputils.HTTPRequest, now time.Time) bool {
+	if req.Method != "GET" {
+		return true
+	}
+	key := f.key(req)
+	if fl, ok := f.byKey[key]; ok {
+		fl.waiters = append(fl.waiters, req.ID)
+		return false
+	}
+	fl := &flight{key: key, leader: req.ID, started: now}
+	f.byKey[key] = fl
+	f.byLeader[req.ID] = fl
+	return true
+}
+
+// Land completes a flight by its leader's ID and returns IDs of waiters
+func (f *Flights) Land(id string) []string {
+	fl, ok := f.byLeader[id]
+	if !ok {
+		return nil
+	}
+	f.remove(fl)
+	return fl.waiters
+}
+
+// Expire removes flights older than timeout and returns IDs of their waiters
+func (f *Flights) Expire(timeout time.Duration, now time.Time) []string {
+	var ids []string
+	for _, fl := range f.byKey {
+		if now.Sub(fl.started) > timeout {
+			f.remove(fl)
+			ids = append(ids, fl.waiters...)
+		}
+	}
+	return ids
+}
+
+func (f *Flights) remove(fl *flight) {
+	delete(f.byKey, fl.key)
+	delete(f.byLeader, fl.leader)
+}
+
+func (f *Flights) key(req *httputils.HTTPRequest) string {
+	parts := []string{req.Method, req.URI}
+	h := http.Header(req.Header)
+	for _, name := range f.vary {
+		parts = append(parts, strings.Join(h[http.CanonicalHeaderKey(name)], ","))
+	}
+	return strings.Join(parts, "\x00")
+}
@@ -0,0 +1,223 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"time"
+
+	httputils "github.com/cascades-fbp/cascades-http/utils"
+	"github.com/cascades-fbp/cascades/components/utils"
+	"github.com/cascades-fbp/cascades/runtime"
+	zmq "github.com/pebbe/zmq4"
+)
+
+var (
+	// Flags
+	optionsEndpoint   = flag.String("port.options", "", "Component's options port endpoint")
+	inputEndpoint     = flag.String("port.in", "", "Component's input port endpoint")
+	responseEndpoint  = flag.String("port.response", "", "Component's upstream response port endpoint")
+	outputEndpoint    = flag.String("port.out", "", "Component's output port endpoint")
+	respEndpoint      = flag.String("port.resp", "", "Component's response port endpoint")
+	logEndpoint       = flag.String("port.log", "", "Component's log port endpoint")
+	heartbeatEndpoint = flag.String("port.heartbeat", "", "Component's heartbeat port endpoint")
+	metricsAddr       = flag.String("metrics", "", "Address to expose metrics on, i.e. 127.0.0.1:9090 (disabled if empty)")
+	jsonFlag          = flag.Bool("json", false, "Print component documentation in JSON")
+	debug             = flag.Bool("debug", false, "Enable debug mode")
+
+	// Internal
+	optionsPort, inPort, responsePort, outPort, respPort, logPort, heartbeatPort *zmq.Socket
+	err                                                                          error
+	logger                                                                       = httputils.NewLogger("http/coalesce")
+	liveness                                                                     = httputils.NewLiveness("http/coalesce")
+	metrics                                                                      = httputils.NewMetrics(logger, liveness)
+	shutdown                                                                     *httputils.Shutdown
+)
+
+// validateArgs checks all required flags
+func validateArgs() {
+	if *inputEndpoint == "" {
+		flag.Usage()
+		os.Exit(1)
+	}
+	if *responseEndpoint == "" {
+		flag.Usage()
+		os.Exit(1)
+	}
+	if *outputEndpoint == "" {
+		flag.Usage()
+		os.Exit(1)
+	}
+	if *respEndpoint == "" {
+		flag.Usage()
+		os.Exit(1)
+	}
+}
+
+// openPorts create ZMQ sockets
+func openPorts() {
+	if *optionsEndpoint != "" {
+		optionsPort, err = utils.CreateInputPort("http/coalesce.options", *optionsEndpoint, nil)
+		utils.AssertError(err)
+	}
+
+	inPort, err = utils.CreateInputPort("http/coalesce.in", *inputEndpoint, nil)
+	utils.AssertError(err)
+
+	responsePort, err = utils.CreateInputPort("http/coalesce.response", *responseEndpoint, nil)
+	utils.AssertError(err)
+
+	outPort, err = utils.CreateOutputPort("http/coalesce.out", *outputEndpoint, nil)
+	utils.AssertError(err)
+
+	respPort, err = utils.CreateOutputPort("http/coalesce.resp", *respEndpoint, nil)
+	utils.AssertError(err)
+
+	if *logEndpoint != "" {
+		logPort, err = utils.CreateOutputPort("http/coalesce.log", *logEndpoint, nil)
+		utils.AssertError(err)
+		logger.SetSink(func(ip [][]byte) { logPort.SendMessageDontwait(ip) })
+	}
+
+	if *heartbeatEndpoint != "" {
+		heartbeatPort, err = utils.CreateOutputPort("http/coalesce.heartbeat", *heartbeatEndpoint, nil)
+		utils.AssertError(err)
+		liveness.Start(func(ip [][]byte) { heartbeatPort.SendMessageDontwait(ip) })
+	}
+}
+
+// closePorts closes all active ports and terminates ZMQ context
+func closePorts() {
+	shutdown.Close(optionsPort, inPort, responsePort)
+	liveness.Stop()
+	shutdown.Flush(outPort, respPort, heartbeatPort, logPort)
+	zmq.Term()
+}
+
+// respond sends a copy of response with a given request ID
+func respond(resp httputils.HTTPResponse, id string) {
+	resp.ID = id
+	ip, err := httputils.Response2IP(&resp)
+	if err != nil {
+		logger.Warn("Failed to convert response to IP", "error", err)
+		return
+	}
+	respPort.SendMessage(ip)
+}
+
+func main() {
+	flag.Parse()
+
+	if *jsonFlag {
+		doc, _ := registryEntry.JSON()
+		fmt.Println(string(doc))
+		os.Exit(0)
+	}
+
+	log.SetFlags(0)
+	if *debug {
+		logger.SetLevel(httputils.LevelDebug)
+		logger.SetOutput(os.Stdout)
+	}
+	log.SetOutput(logger.Writer())
+
+	validateArgs()
+
+	shutdown = httputils.NewShutdown(logger)
+	openPorts()
+
+	if *metricsAddr != "" {
+		err = metrics.Serve(*metricsAddr)
+		utils.AssertError(err)
+	}
+
+	err = runtime.SetupShutdownByDisconnect(inPort, "http/coalesce.in", shutdown.Signals())
+	utils.AssertError(err)
+
+	// Wait for the configuration on the options port
+	options := &Options{Timeout: "30s"}
+	for optionsPort != nil {
+		log.Println("Waiting for configuration...")
+		ip, err := shutdown.Receive(optionsPort)
+		if err == httputils.ErrShutdown {
+			shutdown.Exit(closePorts)
+		}
+		if err != nil {
+			logger.Error("Error receiving IP", "error", err)
+			continue
+		}
+		if !runtime.IsValidIP(ip) || !runtime.IsPacket(ip) {
+			continue
+		}
+		if err = json.Unmarshal(ip[1], options); err != nil {
+			logger.Error("Failed to unmarshal options", "error", err)
+			continue
+		}
+		optionsPort.Close()
+		optionsPort = nil
+	}
+
+	timeout, err := time.ParseDuration(options.Timeout)
+	utils.AssertError(err)
+
+	flights := NewFlights(options.Vary)
+
+	poller := zmq.NewPoller()
+	poller.Add(inPort, zmq.POLLIN)
+	poller.Add(responsePort, zmq.POLLIN)
+
+	// Main loop
+	for !shutdown.Stopping() {
+		sockets, err := poller.Poll(time.Second)
+		if err != nil {
+			logger.Error("Error polling ports", "error", err)
+			continue
+		}
+
+		now := time.Now()
+		for _, id := range flights.Expire(timeout, now) {
+			respond(httputils.HTTPResponse{StatusCode: http.StatusGatewayTimeout}, id)
+		}
+
+		for _, socket := range sockets {
+			ip, err := socket.Socket.RecvMessageBytes(0)
+			if err != nil {
+				logger.Error("Error receiving message", "error", err)
+				continue
+			}
+			liveness.Inc()
+			if !httputils.IsValidIP(ip) || !runtime.IsPacket(ip) {
+				continue
+			}
+
+			switch socket.Socket {
+			case inPort:
+				req, err := httputils.IP2Request(ip)
+				if err != nil {
+					logger.Warn("Failed to convert IP to request", "error", err)
+					continue
+				}
+				if flights.Join(req, now) {
+					outPort.SendMessage(ip)
+				} else {
+					log.Println("Coalesced request", req.ID)
+				}
+
+			case responsePort:
+				resp, err := httputils.IP2Response(ip)
+				if err != nil {
+					logger.Warn("Failed to convert IP to response", "error", err)
+					continue
+				}
+				respPort.SendMessage(ip)
+				for _, id := range flights.Land(resp.ID) {
+					respond(*resp, id)
+				}
+			}
+		}
+	}
+	shutdown.Exit(closePorts)
+}
@@ -0,0 +1,65 @@
+package main
+
+import "github.com/cascades-fbp/cascades/library"
+
+var registryEntry = &library.Entry{
+	Description: `Limits the number of in-flight requests to an upstream and adapts the limit with AIMD algorithm.
+Requests from IN are forwarded to OUT while the limit allows, otherwise they are queued. Responses from
+the upstream arriving on RESPONSE are forwarded to RESP and release slots. Fast successful responses
+increase the limit additively, errors (5xx) and slow responses decrease it multiplicatively.
+Requests that don't fit the queue are answered with 503 on REJECT port.`,
+	Elementary: true,
+	Inports: []library.EntryPort{
+		library.EntryPort{
+			Name: "OPTIONS",
+			Type: "json",
+			Description: `Optional configuration port, i.e. {"initial": 10, "min": 1, "max": 200, "latency": "250ms",
+"backoff": 0.9, "queue": 100, "timeout": "30s"}`,
+			Required: false,
+		},
+		library.EntryPort{
+			Name:        "IN",
+			Type:        "json",
+			Description: "Input port for requests in predefined JSON format",
+			Required:    true,
+		},
+		library.EntryPort{
+			Name:        "RESPONSE",
+			Type:        "json",
+			Description: "Input port for responses from the upstream",
+			Required:    true,
+		},
+	},
+	Outports: []library.EntryPort{
+		library.EntryPort{
+			Name:        "OUT",
+			Type:        "json",
+			Description: "Output port for requests sent to the upstream",
+			Required:    true,
+		},
+		library.EntryPort{
+			Name:        "RESP",
+			Type:        "json",
+			Description: "Output port for forwarded upstream responses",
+			Required:    true,
+		},
+		library.EntryPort{
+			Name:        "REJECT",
+			Type:        "json",
+			Description: "Output port for 503 responses to requests rejected due to overload",
+			Required:    false,
+		},
+		library.EntryPort{
+			Name:        "LOG",
+			Type:        "json",
+			Description: "Optional output port for structured log entries (log entry JSON object defined in utils of HTTP components library)",
+			Required:    false,
+		},
+		library.EntryPort{
+			Name:        "HEARTBEAT",
+			Type:        "json",
+			Description: "Optional output port for periodic liveness IPs (heartbeat JSON object defined in utils of HTTP components library)",
+			Required:    false,
+		},
+	},
+}
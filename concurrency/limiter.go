@@ -0,0 +1,110 @@
+package main
+
+import (
+	"fmt"
+	"time"
+)
+
+// Options describe the configuration IP of the component
+type Options struct {
+	Initial int     `json:"initial"` // Initial concurrency limit
+	Min     int     `json:"min"`     // Minimal concurrency limit
+	Max     int     `json:"max"`     // Maximal concurrency limit
+	Latency string  `json:"latency"` // Latency above which the limit is decreased
+	Backoff float64 `json:"backoff"` // Multiplicative decrease factor (0-1)
+	Queue   int     `json:"queue"`   // Maximal number of waiting requests
+	Timeout string  `json:"timeout"` // In-flight requests without response are released after timeout
+}
+
+// Limiter implements additive-increase/multiplicative-decrease concurrency limit
+type Limiter struct {
+	limit    float64
+	min, max float64
+	latency  time.Duration
+	backoff  float64
+	inflight map[string]time.Time
+}
+
+// NewLimiter creates a limiter for given options
+func NewLimiter(o *Options) (*Limiter, error) {
+	latency, err := time.ParseDuration(o.Latency)
+	if err != nil {
+		return nil, err
+	}
+	if o.Min < 1 || o.Max < o.Min || o.Initial < o.Min || o.Initial > o.Max {
+		return nil, fmt.Errorf("invalid limits: min=%d initial=%d max=%d", o.Min, o.Initial, o.Max)
+	}
+	if o.Backoff <= 0 || o.Backoff >= 1 {
+		return nil, fmt.Errorf("backoff must be between 0 and 1")
+	}
+	return &Limiter{
+		limit:    float64(o.Initial),
+		min:      float64(o.Min),
+		max:      float64(o.Max),
+		latency:  latency,
+		backoff:  o.Backoff,
+		inflight: make(map[string]time.Time),
+	}, nil
+}
+
+// Limit returns current concurrency limit
+func (l *Limiter) Limit() int {
+	return int(l.limit)
+}
+
+// Inflight returns number of requests currently in flight
+func (l *Limiter) Inflight() int {
+	return len(l.inflight)
+}
+
+// Acquire registers a request in flight if the limit allows
+func (l *Limiter) Acquire(id string, now time.Time) bool {
+	if len(l.inflight) >= l.Limit() {
+		return false
+	}
+	l.inflight[id] = now
+	return true
+}
+
+// Release frees a slot taken by a request and adapts the limit.
+// It reports whether the request was known.
+func (l *Limiter) Release(id string, status int, now time.Time) bool {
+	started, ok := l.inflight[id]
+	if !ok {
+		return false
+	}
+	delete(l.inflight, id)
+
+	if status >= 500 || now.Sub(started) > l.latency {
+		l.decrease()
+	} else {
+		// Additive increase by one per limit worth of successful responses
+		l.limit += 1 / l.limit
+		if l.limit > l.max {
+			l.limit = l.max
+		}
+	}
+	return true
+}
+
+// Expire releases requests in flight for longer than timeout and returns their number
+func (l *Limiter) Expire(timeout time.Duration, now time.Time) int {
+	n := 0
+	for id, started := range l.inflight {
+		if now.Sub(started) > timeout {
+			delete(l.inflight, id)
+			n++
+		}
+	}
+	if n > 0 {
+		l.decrease()
+	}
+	return n
+}
+
+func (l *Limiter) decrease() {
+	l.limit *= l.backoff
+	if l.limit < l.min {
+		l.limit = l.min
+	}
+}
@@ -0,0 +1,254 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"time"
+
+	httputils "github.com/cascades-fbp/cascades-http/utils"
+	"github.com/cascades-fbp/cascades/components/utils"
+	"github.com/cascades-fbp/cascades/runtime"
+	zmq "github.com/pebbe/zmq4"
+)
+
+var (
+	// Flags
+	optionsEndpoint   = flag.String("port.options", "", "Component's options port endpoint")
+	inputEndpoint     = flag.String("port.in", "", "Component's input port endpoint")
+	responseEndpoint  = flag.String("port.response", "", "Component's upstream response port endpoint")
+	outputEndpoint    = flag.String("port.out", "", "Component's output port endpoint")
+	respEndpoint      = flag.String("port.resp", "", "Component's forwarded response port endpoint")
+	rejectEndpoint    = flag.String("port.reject", "", "Component's reject port endpoint")
+	logEndpoint       = flag.String("port.log", "", "Component's log port endpoint")
+	heartbeatEndpoint = flag.String("port.heartbeat", "", "Component's heartbeat port endpoint")
+	metricsAddr       = flag.String("metrics", "", "Address to expose metrics on, i.e. 127.0.0.1:9090 (disabled if empty)")
+	jsonFlag          = flag.Bool("json", false, "Print component documentation in JSON")
+	debug             = flag.Bool("debug", false, "Enable debug mode")
+
+	// Internal
+	optionsPort, inPort, responsePort, outPort, respPort, rejectPort, logPort, heartbeatPort *zmq.Socket
+	err                                                                                      error
+	logger                                                                                   = httputils.NewLogger("http/concurrency")
+	liveness                                                                                 = httputils.NewLiveness("http/concurrency")
+	metrics                                                                                  = httputils.NewMetrics(logger, liveness)
+	shutdown                                                                                 *httputils.Shutdown
+)
+
+// queued is a request waiting for a free slot
+type queued struct {
+	id string
+	ip [][]byte
+}
+
+// validateArgs checks all required flags
+func validateArgs() {
+	if *inputEndpoint == "" {
+		flag.Usage()
+		os.Exit(1)
+	}
+	if *responseEndpoint == "" {
+		flag.Usage()
+		os.Exit(1)
+	}
+	if *outputEndpoint == "" {
+		flag.Usage()
+		os.Exit(1)
+	}
+	if *respEndpoint == "" {
+		flag.Usage()
+		os.Exit(1)
+	}
+}
+
+// openPorts create ZMQ sockets
+func openPorts() {
+	if *optionsEndpoint != "" {
+		optionsPort, err = utils.CreateInputPort("http/concurrency.options", *optionsEndpoint, nil)
+		utils.AssertError(err)
+	}
+
+	inPort, err = utils.CreateInputPort("http/concurrency.in", *inputEndpoint, nil)
+	utils.AssertError(err)
+
+	responsePort, err = utils.CreateInputPort("http/concurrency.response", *responseEndpoint, nil)
+	utils.AssertError(err)
+
+	outPort, err = utils.CreateOutputPort("http/concurrency.out", *outputEndpoint, nil)
+	utils.AssertError(err)
+
+	respPort, err = utils.CreateOutputPort("http/concurrency.resp", *respEndpoint, nil)
+	utils.AssertError(err)
+
+	if *rejectEndpoint != "" {
+		rejectPort, err = utils.CreateOutputPort("http/concurrency.reject", *rejectEndpoint, nil)
+		utils.AssertError(err)
+	}
+
+	if *logEndpoint != "" {
+		logPort, err = utils.CreateOutputPort("http/concurrency.log", *logEndpoint, nil)
+		utils.AssertError(err)
+		logger.SetSink(func(ip [][]byte) { logPort.SendMessageDontwait(ip) })
+	}
+
+	if *heartbeatEndpoint != "" {
+		heartbeatPort, err = utils.CreateOutputPort("http/concurrency.heartbeat", *heartbeatEndpoint, nil)
+		utils.AssertError(err)
+		liveness.Start(func(ip [][]byte) { heartbeatPort.SendMessageDontwait(ip) })
+	}
+}
+
+// closePorts closes all active ports and terminates ZMQ context
+func closePorts() {
+	shutdown.Close(optionsPort, inPort, responsePort)
+	liveness.Stop()
+	shutdown.Flush(outPort, respPort, rejectPort, heartbeatPort, logPort)
+	zmq.Term()
+}
+
+func main() {
+	flag.Parse()
+
+	if *jsonFlag {
+		doc, _ := registryEntry.JSON()
+		fmt.Println(string(doc))
+		os.Exit(0)
+	}
+
+	log.SetFlags(0)
+	if *debug {
+		logger.SetLevel(httputils.LevelDebug)
+		logger.SetOutput(os.Stdout)
+	}
+	log.SetOutput(logger.Writer())
+
+	validateArgs()
+
+	shutdown = httputils.NewShutdown(logger)
+	openPorts()
+
+	if *metricsAddr != "" {
+		err = metrics.Serve(*metricsAddr)
+		utils.AssertError(err)
+	}
+
+	err = runtime.SetupShutdownByDisconnect(inPort, "http/concurrency.in", shutdown.Signals())
+	utils.AssertError(err)
+
+	// Wait for the configuration on the options port
+	options := &Options{
+		Initial: 10,
+		Min:     1,
+		Max:     200,
+		Latency: "250ms",
+		Backoff: 0.9,
+		Queue:   100,
+		Timeout: "30s",
+	}
+	for optionsPort != nil {
+		log.Println("Waiting for configuration...")
+		ip, err := shutdown.Receive(optionsPort)
+		if err == httputils.ErrShutdown {
+			shutdown.Exit(closePorts)
+		}
+		if err != nil {
+			logger.Error("Error receiving IP", "error", err)
+			continue
+		}
+		if !runtime.IsValidIP(ip) || !runtime.IsPacket(ip) {
+			continue
+		}
+		if err = json.Unmarshal(ip[1], options); err != nil {
+			logger.Error("Failed to unmarshal options", "error", err)
+			continue
+		}
+		optionsPort.Close()
+		optionsPort = nil
+	}
+
+	limiter, err := NewLimiter(options)
+	utils.AssertError(err)
+	timeout, err := time.ParseDuration(options.Timeout)
+	utils.AssertError(err)
+
+	poller := zmq.NewPoller()
+	poller.Add(inPort, zmq.POLLIN)
+	poller.Add(responsePort, zmq.POLLIN)
+
+	queue := []queued{}
+
+	// Main loop
+	for !shutdown.Stopping() {
+		sockets, err := poller.Poll(time.Second)
+		if err != nil {
+			logger.Error("Error polling ports", "error", err)
+			continue
+		}
+
+		now := time.Now()
+		if n := limiter.Expire(timeout, now); n > 0 {
+			logger.Warn("Released requests without response", "count", n, "limit", limiter.Limit())
+		}
+
+		for _, socket := range sockets {
+			ip, err := socket.Socket.RecvMessageBytes(0)
+			if err != nil {
+				logger.Error("Error receiving message", "error", err)
+				continue
+			}
+			liveness.Inc()
+			if !httputils.IsValidIP(ip) || !runtime.IsPacket(ip) {
+				continue
+			}
+
+			switch socket.Socket {
+			case inPort:
+				req, err := httputils.IP2Request(ip)
+				if err != nil {
+					logger.Warn("Failed to convert IP to request", "error", err)
+					continue
+				}
+				if len(queue) == 0 && limiter.Acquire(req.ID, now) {
+					outPort.SendMessage(ip)
+					continue
+				}
+				if len(queue) >= options.Queue {
+					logger.Warn("Queue is full, rejecting request", "id", req.ID)
+					reject(req.ID)
+					continue
+				}
+				queue = append(queue, queued{id: req.ID, ip: ip})
+
+			case responsePort:
+				resp, err := httputils.IP2Response(ip)
+				if err != nil {
+					logger.Warn("Failed to convert IP to response", "error", err)
+					continue
+				}
+				limiter.Release(resp.ID, resp.StatusCode, now)
+				respPort.SendMessage(ip)
+			}
+		}
+
+		// Dispatch waiting requests while the limit allows
+		for len(queue) > 0 && limiter.Acquire(queue[0].id, now) {
+			outPort.SendMessage(queue[0].ip)
+			queue = queue[1:]
+		}
+	}
+	shutdown.Exit(closePorts)
+}
+
+// reject emits 503 response for a given request ID
+func reject(id string) {
+	if rejectPort == nil {
+		return
+	}
+	rejectPort.SendMessage(httputils.NewResponse(http.StatusServiceUnavailable).
+		WithID(id).
+		WithHeader("Retry-After", "1").
+		MustIP())
+}
@@ -0,0 +1,45 @@
+package main
+
+import "github.com/cascades-fbp/cascades/library"
+
+var registryEntry = &library.Entry{
+	Description: `Resolves backend endpoints via DNS SRV records or Consul health API, watches for changes and
+emits the current set of endpoints (JSON array of "host:port" strings) every time it changes.
+Usable as a source of backends for proxy, balancer and client graphs.`,
+	Elementary: true,
+	Inports: []library.EntryPort{
+		library.EntryPort{
+			Name: "OPTIONS",
+			Type: "json",
+			Description: `Configuration port, i.e. {"type": "srv", "name": "_http._tcp.api.example.com", "interval": "10s"}
+or {"type": "consul", "name": "api", "consul": "http://127.0.0.1:8500", "tag": "v2"}`,
+			Required: true,
+		},
+	},
+	Outports: []library.EntryPort{
+		library.EntryPort{
+			Name:        "OUT",
+			Type:        "json",
+			Description: "Output port for the current set of endpoints",
+			Required:    true,
+		},
+		library.EntryPort{
+			Name:        "ERR",
+			Type:        "string",
+			Description: "Error port for resolution failures",
+			Required:    false,
+		},
+		library.EntryPort{
+			Name:        "LOG",
+			Type:        "json",
+			Description: "Optional output port for structured log entries (log entry JSON object defined in utils of HTTP components library)",
+			Required:    false,
+		},
+		library.EntryPort{
+			Name:        "HEARTBEAT",
+			Type:        "json",
+			Description: "Optional output port for periodic liveness IPs (heartbeat JSON object defined in utils of HTTP components library)",
+			Required:    false,
+		},
+	},
+}
@@ -0,0 +1,179 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"reflect"
+
+	httputils "github.com/cascades-fbp/cascades-http/utils"
+	"github.com/cascades-fbp/cascades/components/utils"
+	"github.com/cascades-fbp/cascades/runtime"
+	zmq "github.com/pebbe/zmq4"
+)
+
+var (
+	// Flags
+	optionsEndpoint   = flag.String("port.options", "", "Component's options port endpoint")
+	outputEndpoint    = flag.String("port.out", "", "Component's output port endpoint")
+	errorEndpoint     = flag.String("port.err", "", "Component's error port endpoint")
+	logEndpoint       = flag.String("port.log", "", "Component's log port endpoint")
+	heartbeatEndpoint = flag.String("port.heartbeat", "", "Component's heartbeat port endpoint")
+	metricsAddr       = flag.String("metrics", "", "Address to expose metrics on, i.e. 127.0.0.1:9090 (disabled if empty)")
+	jsonFlag          = flag.Bool("json", false, "Print component documentation in JSON")
+	debug             = flag.Bool("debug", false, "Enable debug mode")
+
+	// Internal
+	optionsPort, outPort, errPort, logPort, heartbeatPort *zmq.Socket
+	err                                                   error
+	logger                                                = httputils.NewLogger("http/discovery")
+	liveness                                              = httputils.NewLiveness("http/discovery")
+	metrics                                               = httputils.NewMetrics(logger, liveness)
+	shutdown                                              *httputils.Shutdown
+)
+
+// validateArgs checks all required flags
+func validateArgs() {
+	if *optionsEndpoint == "" {
+		flag.Usage()
+		os.Exit(1)
+	}
+	if *outputEndpoint == "" {
+		flag.Usage()
+		os.Exit(1)
+	}
+}
+
+// openPorts create ZMQ sockets
+func openPorts() {
+	optionsPort, err = utils.CreateInputPort("http/discovery.options", *optionsEndpoint, nil)
+	utils.AssertError(err)
+
+	outPort, err = utils.CreateOutputPort("http/discovery.out", *outputEndpoint, nil)
+	utils.AssertError(err)
+
+	if *errorEndpoint != "" {
+		errPort, err = utils.CreateOutputPort("http/discovery.err", *errorEndpoint, nil)
+		utils.AssertError(err)
+	}
+
+	if *logEndpoint != "" {
+		logPort, err = utils.CreateOutputPort("http/discovery.log", *logEndpoint, nil)
+		utils.AssertError(err)
+		logger.SetSink(func(ip [][]byte) { logPort.SendMessageDontwait(ip) })
+	}
+
+	if *heartbeatEndpoint != "" {
+		heartbeatPort, err = utils.CreateOutputPort("http/discovery.heartbeat", *heartbeatEndpoint, nil)
+		utils.AssertError(err)
+		liveness.Start(func(ip [][]byte) { heartbeatPort.SendMessageDontwait(ip) })
+	}
+}
+
+// closePorts closes all active ports and terminates ZMQ context
+func closePorts() {
+	shutdown.Close(optionsPort)
+	liveness.Stop()
+	shutdown.Flush(outPort, errPort, heartbeatPort, logPort)
+	zmq.Term()
+}
+
+func main() {
+	flag.Parse()
+
+	if *jsonFlag {
+		doc, _ := registryEntry.JSON()
+		fmt.Println(string(doc))
+		os.Exit(0)
+	}
+
+	log.SetFlags(0)
+	if *debug {
+		logger.SetLevel(httputils.LevelDebug)
+		logger.SetOutput(os.Stdout)
+	}
+	log.SetOutput(logger.Writer())
+
+	validateArgs()
+
+	shutdown = httputils.NewShutdown(logger)
+	openPorts()
+
+	if *metricsAddr != "" {
+		err = metrics.Serve(*metricsAddr)
+		utils.AssertError(err)
+	}
+
+	// Wait for the configuration on the options port
+	var resolver Resolver
+	for {
+		log.Println("Waiting for configuration...")
+		ip, err := shutdown.Receive(optionsPort)
+		if err == httputils.ErrShutdown {
+			shutdown.Exit(closePorts)
+		}
+		if err != nil {
+			logger.Error("Error receiving IP", "error", err)
+			continue
+		}
+		if !runtime.IsValidIP(ip) || !runtime.IsPacket(ip) {
+			continue
+		}
+		var options Options
+		if err = json.Unmarshal(ip[1], &options); err != nil {
+			logger.Error("Failed to unmarshal options", "error", err)
+			continue
+		}
+		resolver, err = NewResolver(&options)
+		if err != nil {
+			logger.Error("Invalid configuration", "error", err)
+			continue
+		}
+		break
+	}
+	optionsPort.Close()
+	optionsPort = nil
+
+	// Resolve in the background as it blocks until a change or an interval
+	type resolution struct {
+		endpoints []string
+		err       error
+	}
+	resultCh := make(chan resolution)
+	go func() {
+		for {
+			endpoints, err := resolver.Resolve()
+			resultCh <- resolution{endpoints, err}
+		}
+	}()
+
+	// Watch for changes until shutdown
+	var current []string
+	for {
+		var res resolution
+		select {
+		case res = <-resultCh:
+		case <-shutdown.Done():
+			shutdown.Exit(closePorts)
+		}
+		liveness.Inc()
+		endpoints, err := res.endpoints, res.err
+		if err != nil {
+			logger.Error("Error resolving endpoints", "error", err)
+			if errPort != nil {
+				errPort.SendMessageDontwait(runtime.NewPacket([]byte(err.Error())))
+			}
+			continue
+		}
+		if current != nil && reflect.DeepEqual(current, endpoints) {
+			continue
+		}
+		current = endpoints
+
+		logger.Info("Endpoints changed", "endpoints", endpoints)
+		data, _ := json.Marshal(endpoints)
+		outPort.SendMessage(runtime.NewPacket(data))
+	}
+}
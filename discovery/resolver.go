@@ -0,0 +1,146 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Options describe the configuration IP of the component
+type Options struct {
+	Type     string `json:"type"`     // srv or consul
+	Name     string `json:"name"`     // SRV record or Consul service name
+	Consul   string `json:"consul"`   // Consul agent address (http://127.0.0.1:8500 by default)
+	Tag      string `json:"tag"`      // Optional Consul service tag filter
+	Interval string `json:"interval"` // Polling interval for SRV and blocking wait for Consul
+}
+
+// Resolver returns current set of endpoints. Implementations may block until a change
+// happens or an interval passes.
+type Resolver interface {
+	Resolve() ([]string, error)
+}
+
+// NewResolver creates a resolver for given options
+func NewResolver(options *Options) (Resolver, error) {
+	if options.Name == "" {
+		return nil, fmt.Errorf("name is required")
+	}
+	interval := 10 * time.Second
+	if options.Interval != "" {
+		d, err := time.ParseDuration(options.Interval)
+		if err != nil {
+			return nil, err
+		}
+		interval = d
+	}
+
+	switch options.Type {
+	case "", "srv":
+		return &srvResolver{name: options.Name, interval: interval}, nil
+	case "consul":
+		addr := options.Consul
+		if addr == "" {
+			addr = "http://127.0.0.1:8500"
+		}
+		return &consulResolver{
+			addr:     strings.TrimRight(addr, "/"),
+			service:  options.Name,
+			tag:      options.Tag,
+			interval: interval,
+			client:   &http.Client{Timeout: interval + 10*time.Second},
+		}, nil
+	}
+	return nil, fmt.Errorf("unsupported discovery type %s", options.Type)
+}
+
+// srvResolver polls DNS SRV records
+type srvResolver struct {
+	name     string
+	interval time.Duration
+	polled   bool
+}
+
+func (r *srvResolver) Resolve() ([]string, error) {
+	if r.polled {
+		time.Sleep(r.interval)
+	}
+	r.polled = true
+
+	_, records, err := net.LookupSRV("", "", r.name)
+	if err != nil {
+		return nil, err
+	}
+	endpoints := make([]string, 0, len(records))
+	for _, rec := range records {
+		host := strings.TrimSuffix(rec.Target, ".")
+		endpoints = append(endpoints, net.JoinHostPort(host, strconv.Itoa(int(rec.Port))))
+	}
+	sort.Strings(endpoints)
+	return endpoints, nil
+}
+
+// consulResolver uses blocking queries against Consul health API
+type consulResolver struct {
+	addr     string
+	service  string
+	tag      string
+	interval time.Duration
+	index    string
+	client   *http.Client
+}
+
+type consulEntry struct {
+	Node struct {
+		Address string
+	}
+	Service struct {
+		Address string
+		Port    int
+	}
+}
+
+func (r *consulResolver) Resolve() ([]string, error) {
+	q := url.Values{}
+	q.Set("passing", "1")
+	q.Set("wait", r.interval.String())
+	if r.tag != "" {
+		q.Set("tag", r.tag)
+	}
+	if r.index != "" {
+		q.Set("index", r.index)
+	}
+
+	resp, err := r.client.Get(r.addr + "/v1/health/service/" + url.PathEscape(r.service) + "?" + q.Encode())
+	if err != nil {
+		time.Sleep(r.interval)
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		time.Sleep(r.interval)
+		return nil, fmt.Errorf("consul responded with status %d", resp.StatusCode)
+	}
+	r.index = resp.Header.Get("X-Consul-Index")
+
+	var entries []consulEntry
+	if err = json.NewDecoder(resp.Body).Decode(&entries); err != nil {
+		return nil, err
+	}
+	endpoints := make([]string, 0, len(entries))
+	for _, e := range entries {
+		host := e.Service.Address
+		if host == "" {
+			host = e.Node.Address
+		}
+		endpoints = append(endpoints, net.JoinHostPort(host, strconv.Itoa(e.Service.Port)))
+	}
+	sort.Strings(endpoints)
+	return endpoints, nil
+}
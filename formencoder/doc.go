@@ -0,0 +1,56 @@
+package main
+
+import "github.com/cascades-fbp/cascades/library"
+
+var registryEntry = &library.Entry{
+	Description: `Converts JSON objects into application/x-www-form-urlencoded or multipart/form-data bodies.
+Nested keys are flattened either with brackets (a[b]=1, a[]=1) or with dots (a.b=1). For every object
+a BODY IP and a matching Content-Type IP are emitted, ready for http/client.`,
+	Elementary: true,
+	Inports: []library.EntryPort{
+		library.EntryPort{
+			Name:        "OPTIONS",
+			Type:        "json",
+			Description: `Optional configuration port, i.e. {"multipart": false, "style": "brackets|dots"}`,
+			Required:    false,
+		},
+		library.EntryPort{
+			Name:        "IN",
+			Type:        "json",
+			Description: "Input port for JSON objects to encode",
+			Required:    true,
+		},
+	},
+	Outports: []library.EntryPort{
+		library.EntryPort{
+			Name:        "BODY",
+			Type:        "string",
+			Description: "Output port for encoded bodies",
+			Required:    true,
+		},
+		library.EntryPort{
+			Name:        "TYPE",
+			Type:        "string",
+			Description: "Output port for Content-Type of every encoded body",
+			Required:    false,
+		},
+		library.EntryPort{
+			Name:        "ERR",
+			Type:        "string",
+			Description: "Error port for inputs that couldn't be encoded",
+			Required:    false,
+		},
+		library.EntryPort{
+			Name:        "LOG",
+			Type:        "json",
+			Description: "Optional output port for structured log entries (log entry JSON object defined in utils of HTTP components library)",
+			Required:    false,
+		},
+		library.EntryPort{
+			Name:        "HEARTBEAT",
+			Type:        "json",
+			Description: "Optional output port for periodic liveness IPs (heartbeat JSON object defined in utils of HTTP components library)",
+			Required:    false,
+		},
+	},
+}
@@ -0,0 +1,207 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+
+	httputils "github.com/cascades-fbp/cascades-http/utils"
+	"github.com/cascades-fbp/cascades/components/utils"
+	"github.com/cascades-fbp/cascades/runtime"
+	zmq "github.com/pebbe/zmq4"
+)
+
+var (
+	// Flags
+	optionsEndpoint   = flag.String("port.options", "", "Component's options port endpoint")
+	inputEndpoint     = flag.String("port.in", "", "Component's input port endpoint")
+	bodyEndpoint      = flag.String("port.body", "", "Component's body output port endpoint")
+	typeEndpoint      = flag.String("port.type", "", "Component's content type output port endpoint")
+	errorEndpoint     = flag.String("port.err", "", "Component's error port endpoint")
+	logEndpoint       = flag.String("port.log", "", "Component's log port endpoint")
+	heartbeatEndpoint = flag.String("port.heartbeat", "", "Component's heartbeat port endpoint")
+	metricsAddr       = flag.String("metrics", "", "Address to expose metrics on, i.e. 127.0.0.1:9090 (disabled if empty)")
+	jsonFlag          = flag.Bool("json", false, "Print component documentation in JSON")
+	debug             = flag.Bool("debug", false, "Enable debug mode")
+
+	// Internal
+	optionsPort, inPort, bodyPort, typePort, errPort, logPort, heartbeatPort *zmq.Socket
+	err                                                                      error
+	logger                                                                   = httputils.NewLogger("http/formencoder")
+	liveness                                                                 = httputils.NewLiveness("http/formencoder")
+	metrics                                                                  = httputils.NewMetrics(logger, liveness)
+	shutdown                                                                 *httputils.Shutdown
+)
+
+// Options describe the configuration IP of the component
+type Options struct {
+	Multipart bool   `json:"multipart"` // Produce multipart/form-data instead of urlencoded body
+	Style     string `json:"style"`     // Nested keys flattening style: brackets (default) or dots
+}
+
+// validateArgs checks all required flags
+func validateArgs() {
+	if *inputEndpoint == "" {
+		flag.Usage()
+		os.Exit(1)
+	}
+	if *bodyEndpoint == "" {
+		flag.Usage()
+		os.Exit(1)
+	}
+}
+
+// openPorts create ZMQ sockets
+func openPorts() {
+	if *optionsEndpoint != "" {
+		optionsPort, err = utils.CreateInputPort("http/formencoder.options", *optionsEndpoint, nil)
+		utils.AssertError(err)
+	}
+
+	inPort, err = utils.CreateInputPort("http/formencoder.in", *inputEndpoint, nil)
+	utils.AssertError(err)
+
+	bodyPort, err = utils.CreateOutputPort("http/formencoder.body", *bodyEndpoint, nil)
+	utils.AssertError(err)
+
+	if *typeEndpoint != "" {
+		typePort, err = utils.CreateOutputPort("http/formencoder.type", *typeEndpoint, nil)
+		utils.AssertError(err)
+	}
+	if *errorEndpoint != "" {
+		errPort, err = utils.CreateOutputPort("http/formencoder.err", *errorEndpoint, nil)
+		utils.AssertError(err)
+	}
+
+	if *logEndpoint != "" {
+		logPort, err = utils.CreateOutputPort("http/formencoder.log", *logEndpoint, nil)
+		utils.AssertError(err)
+		logger.SetSink(func(ip [][]byte) { logPort.SendMessageDontwait(ip) })
+	}
+
+	if *heartbeatEndpoint != "" {
+		heartbeatPort, err = utils.CreateOutputPort("http/formencoder.heartbeat", *heartbeatEndpoint, nil)
+		utils.AssertError(err)
+		liveness.Start(func(ip [][]byte) { heartbeatPort.SendMessageDontwait(ip) })
+	}
+}
+
+// closePorts closes all active ports and terminates ZMQ context
+func closePorts() {
+	shutdown.Close(optionsPort, inPort)
+	liveness.Stop()
+	shutdown.Flush(bodyPort, typePort, errPort, heartbeatPort, logPort)
+	zmq.Term()
+}
+
+func main() {
+	flag.Parse()
+
+	if *jsonFlag {
+		doc, _ := registryEntry.JSON()
+		fmt.Println(string(doc))
+		os.Exit(0)
+	}
+
+	log.SetFlags(0)
+	if *debug {
+		logger.SetLevel(httputils.LevelDebug)
+		logger.SetOutput(os.Stdout)
+	}
+	log.SetOutput(logger.Writer())
+
+	validateArgs()
+
+	shutdown = httputils.NewShutdown(logger)
+	openPorts()
+
+	if *metricsAddr != "" {
+		err = metrics.Serve(*metricsAddr)
+		utils.AssertError(err)
+	}
+
+	err = runtime.SetupShutdownByDisconnect(inPort, "http/formencoder.in", shutdown.Signals())
+	utils.AssertError(err)
+
+	// Wait for the configuration on the options port
+	var options Options
+	for optionsPort != nil {
+		log.Println("Waiting for configuration...")
+		ip, err := shutdown.Receive(optionsPort)
+		if err == httputils.ErrShutdown {
+			shutdown.Exit(closePorts)
+		}
+		if err != nil {
+			logger.Error("Error receiving IP", "error", err)
+			continue
+		}
+		if !runtime.IsValidIP(ip) || !runtime.IsPacket(ip) {
+			continue
+		}
+		if err = json.Unmarshal(ip[1], &options); err != nil {
+			logger.Error("Failed to unmarshal options", "error", err)
+			continue
+		}
+		optionsPort.Close()
+		optionsPort = nil
+	}
+
+	style := httputils.BracketStyle
+	if options.Style == "dots" {
+		style = httputils.DotStyle
+	}
+
+	// Process incoming messages until shutdown
+	for {
+		ip, err := shutdown.Receive(inPort)
+		if err == httputils.ErrShutdown {
+			break
+		}
+		if err != nil {
+			logger.Error("Error receiving message", "error", err)
+			continue
+		}
+		liveness.Inc()
+		if !runtime.IsValidIP(ip) || !runtime.IsPacket(ip) {
+			logger.Warn("Received invalid IP")
+			continue
+		}
+
+		values, err := httputils.FlattenJSON(ip[1], style)
+		if err != nil {
+			logger.Warn("Failed to flatten JSON", "error", err)
+			sendError(err)
+			continue
+		}
+
+		var (
+			body        []byte
+			contentType = "application/x-www-form-urlencoded"
+		)
+		if options.Multipart {
+			body, contentType, err = httputils.EncodeMultipart(values)
+			if err != nil {
+				logger.Error("Failed to encode multipart body", "error", err)
+				sendError(err)
+				continue
+			}
+		} else {
+			body = []byte(values.Encode())
+		}
+
+		bodyPort.SendMessage(runtime.NewPacket(body))
+		if typePort != nil {
+			typePort.SendMessage(runtime.NewPacket([]byte(contentType)))
+		}
+	}
+	shutdown.Exit(closePorts)
+}
+
+// sendError emits error to ERR port if it's connected
+func sendError(err error) {
+	if errPort != nil {
+		errPort.SendMessageDontwait(runtime.NewPacket([]byte(err.Error())))
+	}
+}
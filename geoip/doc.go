@@ -0,0 +1,57 @@
+package main
+
+import "github.com/cascades-fbp/cascades/library"
+
+var registryEntry = &library.Entry{
+	Description: `Looks up client addresses of incoming request IPs in MaxMind databases (GeoIP2/GeoLite2 City or
+Country and ASN) and annotates requests with geo field (country, region, city, coordinates, time zone, ASN and its
+organization). The country code can also be set in a header for routing on headers. With allow_countries or
+deny_countries requests from other countries are answered on REJECT port (451 by default).`,
+	Elementary: true,
+	Inports: []library.EntryPort{
+		library.EntryPort{
+			Name:        "OPTIONS",
+			Type:        "json",
+			Description: `Configuration port, i.e. {"city_db": "/var/lib/geoip/GeoLite2-City.mmdb", "asn_db": "/var/lib/geoip/GeoLite2-ASN.mmdb", "header": "X-Country", "deny_countries": ["KP"]}`,
+			Required:    true,
+		},
+		library.EntryPort{
+			Name:        "IN",
+			Type:        "json",
+			Description: "Input port for requests in predefined JSON format",
+			Required:    true,
+		},
+	},
+	Outports: []library.EntryPort{
+		library.EntryPort{
+			Name:        "OUT",
+			Type:        "json",
+			Description: "Output port for annotated requests",
+			Required:    true,
+		},
+		library.EntryPort{
+			Name:        "REJECT",
+			Type:        "json",
+			Description: "Output port for responses to requests from filtered countries (required if countries are filtered)",
+			Required:    false,
+		},
+		library.EntryPort{
+			Name:        "ERR",
+			Type:        "json",
+			Description: "Optional error port for invalid IPs (error JSON object defined in utils of HTTP components library)",
+			Required:    false,
+		},
+		library.EntryPort{
+			Name:        "LOG",
+			Type:        "json",
+			Description: "Optional output port for structured log entries (log entry JSON object defined in utils of HTTP components library)",
+			Required:    false,
+		},
+		library.EntryPort{
+			Name:        "HEARTBEAT",
+			Type:        "json",
+			Description: "Optional output port for periodic liveness IPs (heartbeat JSON object defined in utils of HTTP components library)",
+			Required:    false,
+		},
+	},
+}
@@ -0,0 +1,237 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+
+	httputils "github.com/cascades-fbp/cascades-http/utils"
+	"github.com/cascades-fbp/cascades/components/utils"
+	"github.com/cascades-fbp/cascades/runtime"
+	zmq "github.com/pebbe/zmq4"
+)
+
+var (
+	// Flags
+	optionsEndpoint   = flag.String("port.options", "", "Component's options port endpoint")
+	inputEndpoint     = flag.String("port.in", "", "Component's input port endpoint")
+	outputEndpoint    = flag.String("port.out", "", "Component's output port endpoint")
+	rejectEndpoint    = flag.String("port.reject", "", "Component's reject port endpoint")
+	errorEndpoint     = flag.String("port.err", "", "Component's error port endpoint")
+	logEndpoint       = flag.String("port.log", "", "Component's log port endpoint")
+	heartbeatEndpoint = flag.String("port.heartbeat", "", "Component's heartbeat port endpoint")
+	metricsAddr       = flag.String("metrics", "", "Address to expose metrics on, i.e. 127.0.0.1:9090 (disabled if empty)")
+	jsonFlag          = flag.Bool("json", false, "Print component documentation in JSON")
+	debug             = flag.Bool("debug", false, "Enable debug mode")
+
+	// Internal
+	optionsPort, inPort, outPort, rejectPort, errPort, logPort, heartbeatPort *zmq.Socket
+	err                                                                       error
+	logger                                                                    = httputils.NewLogger("http/geoip")
+	liveness                                                                  = httputils.NewLiveness("http/geoip")
+	metrics                                                                   = httputils.NewMetrics(logger, liveness)
+	shutdown                                                                  *httputils.Shutdown
+)
+
+// validateArgs checks all required flags
+func validateArgs() {
+	if *optionsEndpoint == "" {
+		flag.Usage()
+		os.Exit(1)
+	}
+	if *inputEndpoint == "" {
+		flag.Usage()
+		os.Exit(1)
+	}
+	if *outputEndpoint == "" {
+		flag.Usage()
+		os.Exit(1)
+	}
+}
+
+// openPorts create ZMQ sockets
+func openPorts() {
+	optionsPort, err = utils.CreateInputPort("http/geoip.options", *optionsEndpoint, nil)
+	utils.AssertError(err)
+
+	inPort, err = utils.CreateInputPort("http/geoip.in", *inputEndpoint, nil)
+	utils.AssertError(err)
+
+	outPort, err = utils.CreateOutputPort("http/geoip.out", *outputEndpoint, nil)
+	utils.AssertError(err)
+
+	if *rejectEndpoint != "" {
+		rejectPort, err = utils.CreateOutputPort("http/geoip.reject", *rejectEndpoint, nil)
+		utils.AssertError(err)
+	}
+
+	if *errorEndpoint != "" {
+		errPort, err = utils.CreateOutputPort("http/geoip.err", *errorEndpoint, nil)
+		utils.AssertError(err)
+	}
+
+	if *logEndpoint != "" {
+		logPort, err = utils.CreateOutputPort("http/geoip.log", *logEndpoint, nil)
+		utils.AssertError(err)
+		logger.SetSink(func(ip [][]byte) { logPort.SendMessageDontwait(ip) })
+	}
+
+	if *heartbeatEndpoint != "" {
+		heartbeatPort, err = utils.CreateOutputPort("http/geoip.heartbeat", *heartbeatEndpoint, nil)
+		utils.AssertError(err)
+		liveness.Start(func(ip [][]byte) { heartbeatPort.SendMessageDontwait(ip) })
+	}
+}
+
+// closePorts closes all active ports and terminates ZMQ context
+func closePorts() {
+	shutdown.Close(optionsPort, inPort)
+	liveness.Stop()
+	shutdown.Flush(outPort, rejectPort, errPort, heartbeatPort, logPort)
+	zmq.Term()
+}
+
+func main() {
+	flag.Parse()
+
+	if *jsonFlag {
+		doc, _ := registryEntry.JSON()
+		fmt.Println(string(doc))
+		os.Exit(0)
+	}
+
+	log.SetFlags(0)
+	if *debug {
+		logger.SetLevel(httputils.LevelDebug)
+		logger.SetOutput(os.Stdout)
+	}
+	log.SetOutput(logger.Writer())
+
+	validateArgs()
+
+	shutdown = httputils.NewShutdown(logger)
+	openPorts()
+
+	if *metricsAddr != "" {
+		err = metrics.Serve(*metricsAddr)
+		utils.AssertError(err)
+	}
+
+	err = runtime.SetupShutdownByDisconnect(inPort, "http/geoip.in", shutdown.Signals())
+	utils.AssertError(err)
+
+	// Wait for the configuration on the options port
+	var (
+		options  *Options
+		resolver *Resolver
+		filter   *Filter
+	)
+	for resolver == nil {
+		log.Println("Waiting for configuration...")
+		ip, err := shutdown.Receive(optionsPort)
+		if err == httputils.ErrShutdown {
+			shutdown.Exit(closePorts)
+		}
+		if err != nil {
+			logger.Error("Error receiving IP", "error", err)
+			continue
+		}
+		if !runtime.IsValidIP(ip) || !runtime.IsPacket(ip) {
+			continue
+		}
+		options = &Options{Status: http.StatusUnavailableForLegalReasons}
+		if err = json.Unmarshal(ip[1], options); err != nil {
+			logger.Error("Failed to unmarshal options", "error", err)
+			continue
+		}
+		if filter = NewFilter(options); filter != nil && rejectPort == nil {
+			logger.Error("Filtering countries requires REJECT port")
+			continue
+		}
+		if resolver, err = NewResolver(options); err != nil {
+			logger.Error("Failed to open GeoIP database", "error", err)
+			continue
+		}
+	}
+	optionsPort.Close()
+	optionsPort = nil
+
+	// Process incoming messages until shutdown
+	for {
+		ip, err := shutdown.Receive(inPort)
+		if err == httputils.ErrShutdown {
+			break
+		}
+		if err != nil {
+			logger.Error("Error receiving message", "error", err)
+			continue
+		}
+		liveness.Inc()
+		if !httputils.IsValidIP(ip) {
+			logger.Warn("Received invalid IP")
+			continue
+		}
+		if !runtime.IsPacket(ip) {
+			outPort.SendMessage(ip)
+			continue
+		}
+
+		req, err := httputils.IP2Request(ip)
+		if err != nil {
+			logger.Warn("Failed to convert IP to request", "error", err)
+			sendError(httputils.NewError("http/geoip", httputils.ErrInvalidIP, err))
+			continue
+		}
+		addr := req.ClientIP(options.TrustForwarded)
+		info, err := resolver.Resolve(addr)
+		if err != nil {
+			logger.Warn("Failed to resolve client address", "id", req.ID, "addr", addr, "error", err)
+		}
+
+		if filter != nil && !filter.Allowed(info) {
+			country := ""
+			if info != nil {
+				country = info.Country
+			}
+			logger.Info("Rejected request", "id", req.ID, "addr", addr, "country", country)
+			resp := httputils.NewResponse(options.Status).
+				WithID(req.ID).
+				WithTrace(req.Trace).
+				WithText("Not available in your region")
+			rejectPort.SendMessage(resp.MustIP())
+			continue
+		}
+
+		if info == nil {
+			outPort.SendMessage(ip)
+			continue
+		}
+		req.Geo = info
+		if options.Header != "" && info.Country != "" {
+			req.SetHeader(options.Header, info.Country)
+		}
+		ip, err = httputils.Request2IP(req)
+		if err != nil {
+			logger.Error("Failed to convert request to IP", "error", err)
+			sendError(httputils.NewError("http/geoip", httputils.ErrInternal, err).WithRequest(req.ID))
+			continue
+		}
+		outPort.SendMessage(ip)
+	}
+	shutdown.Exit(closePorts)
+}
+
+// sendError reports a failure to the ERR port if it's connected
+func sendError(e *httputils.Error) {
+	if errPort == nil {
+		return
+	}
+	ip, err := httputils.Error2IP(e)
+	if err != nil {
+		return
+	}
+	errPort.SendMessageDontwait(ip)
+}
@@ -0,0 +1,316 @@
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io/ioutil"
+	"math"
+	"net"
+)
+
+// Reader of MaxMind DB files (https://maxmind.github.io/MaxMind-DB/)
+
+// metadataMarker precedes metadata at the end of a database
+var metadataMarker = []byte("\xab\xcd\xefMaxMind.com")
+
+// Metadata describes a database
+type Metadata struct {
+	NodeCount    uint
+	RecordSize   uint
+	IPVersion    uint
+	DatabaseType string
+	BuildEpoch   uint
+}
+
+// Database is a MaxMind DB loaded in memory
+type Database struct {
+	Metadata Metadata
+	tree     []byte
+	data     []byte
+	ipv4Root uint // Node of ::/96 where IPv4 lookups start in IPv6 trees
+}
+
+// OpenDatabase reads a database file
+func OpenDatabase(path string) (*Database, error) {
+	buf, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return NewDatabase(buf)
+}
+
+// NewDatabase parses a database
+func NewDatabase(buf []byte) (*Database, error) {
+	start := bytes.LastIndex(buf, metadataMarker)
+	if start < 0 {
+		return nil, fmt.Errorf("not a MaxMind DB: metadata not found")
+	}
+	meta := buf[start+len(metadataMarker):]
+	v, _, err := (&decoder{data: meta}).decode(0)
+	if err != nil {
+		return nil, fmt.Errorf("invalid metadata: %v", err)
+	}
+	m, ok := v.(map[string]interface{})
+	if !ok {
+		return nil, fmt.Errorf("invalid metadata")
+	}
+	db := &Database{Metadata: Metadata{
+		NodeCount:    uintValue(m["node_count"]),
+		RecordSize:   uintValue(m["record_size"]),
+		IPVersion:    uintValue(m["ip_version"]),
+		BuildEpoch:   uintValue(m["build_epoch"]),
+		DatabaseType: stringValue(m["database_type"]),
+	}}
+	if major := uintValue(m["binary_format_major_version"]); major != 2 {
+		return nil, fmt.Errorf("unsupported binary format version %d", major)
+	}
+
+	switch db.Metadata.RecordSize {
+	case 24, 28, 32:
+	default:
+		return nil, fmt.Errorf("unsupported record size %d", db.Metadata.RecordSize)
+	}
+	treeSize := db.Metadata.RecordSize * 2 / 8 * db.Metadata.NodeCount
+	if treeSize+16 > uint(start) {
+		return nil, fmt.Errorf("search tree exceeds the file")
+	}
+	db.tree = buf[:treeSize]
+	db.data = buf[treeSize+16 : start]
+
+	if db.Metadata.IPVersion == 6 {
+		node := uint(0)
+		for i := 0; i < 96 && node < db.Metadata.NodeCount; i++ {
+			node = db.record(node, 0)
+		}
+		db.ipv4Root = node
+	}
+	return db, nil
+}
+
+// Lookup returns the record of the network containing an address
+func (db *Database) Lookup(ip net.IP) (map[string]interface{}, bool, error) {
+	node := uint(0)
+	bits := 128
+	if ip4 := ip.To4(); ip4 != nil {
+		ip = ip4
+		bits = 32
+		if db.Metadata.IPVersion == 6 {
+			node = db.ipv4Root
+		}
+	} else if db.Metadata.IPVersion == 4 {
+		return nil, false, nil
+	}
+
+	for i := 0; i < bits && node < db.Metadata.NodeCount; i++ {
+		bit := uint(ip[i>>3]>>(7-uint(i&7))) & 1
+		node = db.record(node, bit)
+	}
+	if node == db.Metadata.NodeCount {
+		return nil, false, nil
+	}
+	if node < db.Metadata.NodeCount {
+		return nil, false, fmt.Errorf("invalid search tree")
+	}
+
+	offset := node - db.Metadata.NodeCount - 16
+	v, _, err := (&decoder{data: db.data}).decode(offset)
+	if err != nil {
+		return nil, false, err
+	}
+	m, ok := v.(map[string]interface{})
+	if !ok {
+		return nil, false, fmt.Errorf("record isn't a map")
+	}
+	return m, true, nil
+}
+
+// record returns left (bit 0) or right (bit 1) record of a node
+func (db *Database) record(node, bit uint) uint {
+	switch db.Metadata.RecordSize {
+	case 24:
+		b := db.tree[node*6+bit*3:]
+		return uint(b[0])<<16 | uint(b[1])<<8 | uint(b[2])
+	case 28:
+		b := db.tree[node*7:]
+		if bit == 0 {
+			return uint(b[3]&0xf0)<<20 | uint(b[0])<<16 | uint(b[1])<<8 | uint(b[2])
+		}
+		return uint(b[3]&0x0f)<<24 | uint(b[4])<<16 | uint(b[5])<<8 | uint(b[6])
+	default:
+		return uint(binary.BigEndian.Uint32(db.tree[node*8+bit*4:]))
+	}
+}
+
+// Data section types
+const (
+	typeExtended  = 0
+	typePointer   = 1
+	typeString    = 2
+	typeDouble    = 3
+	typeBytes     = 4
+	typeUint16    = 5
+	typeUint32    = 6
+	typeMap       = 7
+	typeInt32     = 8
+	typeUint64    = 9
+	typeUint128   = 10
+	typeArray     = 11
+	typeContainer = 12
+	typeEnd       = 13
+	typeBool      = 14
+	typeFloat     = 15
+)
+
+// decoder reads values of the data section, pointers are relative to its start
+type decoder struct {
+	data []byte
+}
+
+// decode returns the value at an offset and offset following it
+func (d *decoder) decode(offset uint) (interface{}, uint, error) {
+	typ, size, offset, err := d.control(offset)
+	if err != nil {
+		return nil, 0, err
+	}
+	if typ == typePointer {
+		v, _, err := d.decode(size)
+		return v, offset, err
+	}
+	if typ == typeMap || typ == typeArray {
+		return d.decodeContainer(typ, size, offset)
+	}
+	if offset+size > uint(len(d.data)) {
+		return nil, 0, fmt.Errorf("value exceeds the data section")
+	}
+	b := d.data[offset : offset+size]
+	next := offset + size
+	switch typ {
+	case typeString:
+		return string(b), next, nil
+	case typeDouble:
+		if size != 8 {
+			return nil, 0, fmt.Errorf("invalid double size %d", size)
+		}
+		return math.Float64frombits(binary.BigEndian.Uint64(b)), next, nil
+	case typeFloat:
+		if size != 4 {
+			return nil, 0, fmt.Errorf("invalid float size %d", size)
+		}
+		return float64(math.Float32frombits(binary.BigEndian.Uint32(b))), next, nil
+	case typeBytes, typeUint128:
+		return append([]byte{}, b...), next, nil
+	case typeUint16, typeUint32, typeUint64:
+		if size > 8 {
+			return nil, 0, fmt.Errorf("invalid integer size %d", size)
+		}
+		var v uint64
+		for _, c := range b {
+			v = v<<8 | uint64(c)
+		}
+		return v, next, nil
+	case typeInt32:
+		var v uint32
+		for _, c := range b {
+			v = v<<8 | uint32(c)
+		}
+		return int64(int32(v)), next, nil
+	case typeBool:
+		return size != 0, offset, nil
+	}
+	return nil, 0, fmt.Errorf("unsupported data type %d", typ)
+}
+
+// decodeContainer decodes size pairs of a map or elements of an array
+func (d *decoder) decodeContainer(typ, size, offset uint) (interface{}, uint, error) {
+	if typ == typeArray {
+		a := make([]interface{}, 0, size)
+		for i := uint(0); i < size; i++ {
+			v, next, err := d.decode(offset)
+			if err != nil {
+				return nil, 0, err
+			}
+			a = append(a, v)
+			offset = next
+		}
+		return a, offset, nil
+	}
+	m := make(map[string]interface{}, size)
+	for i := uint(0); i < size; i++ {
+		k, next, err := d.decode(offset)
+		if err != nil {
+			return nil, 0, err
+		}
+		key, ok := k.(string)
+		if !ok {
+			return nil, 0, fmt.Errorf("map key isn't a string")
+		}
+		v, next, err := d.decode(next)
+		if err != nil {
+			return nil, 0, err
+		}
+		m[key] = v
+		offset = next
+	}
+	return m, offset, nil
+}
+
+// control reads the control byte of a value returning its type, size (or
+// target of pointers) and offset of the payload
+func (d *decoder) control(offset uint) (typ, size, next uint, err error) {
+	b := d.data
+	if offset >= uint(len(b)) {
+		return 0, 0, 0, fmt.Errorf("offset %d exceeds the data section", offset)
+	}
+	ctrl := b[offset]
+	offset++
+	typ = uint(ctrl >> 5)
+	if typ == typePointer {
+		n := uint(ctrl>>3&0x3) + 1
+		if offset+n > uint(len(b)) {
+			return 0, 0, 0, fmt.Errorf("truncated pointer")
+		}
+		var p uint
+		if n < 4 {
+			p = uint(ctrl & 0x7)
+		}
+		for _, c := range b[offset : offset+n] {
+			p = p<<8 | uint(c)
+		}
+		p += [...]uint{0, 2048, 526336, 0}[n-1]
+		return typ, p, offset + n, nil
+	}
+	if typ == typeExtended {
+		if offset >= uint(len(b)) {
+			return 0, 0, 0, fmt.Errorf("truncated extended type")
+		}
+		typ = 7 + uint(b[offset])
+		offset++
+	}
+	size = uint(ctrl & 0x1f)
+	if size >= 29 {
+		n := size - 28
+		if offset+n > uint(len(b)) {
+			return 0, 0, 0, fmt.Errorf("truncated size")
+		}
+		var v uint
+		for _, c := range b[offset : offset+n] {
+			v = v<<8 | uint(c)
+		}
+		size = v + [...]uint{29, 285, 65821}[n-1]
+		offset += n
+	}
+	return typ, size, offset, nil
+}
+
+func uintValue(v interface{}) uint {
+	if n, ok := v.(uint64); ok {
+		return uint(n)
+	}
+	return 0
+}
+
+func stringValue(v interface{}) string {
+	s, _ := v.(string)
+	return s
+}
@@ -0,0 +1,148 @@
+package main
+
+import (
+	"fmt"
+	"net"
+	"strings"
+
+	httputils "github.com/cascades-fbp/cascades-http/utils"
+)
+
+// Options describe the configuration IP of the component
+type Options struct {
+	CityDB         string   `json:"city_db"`         // GeoIP2/GeoLite2 City or Country database
+	ASNDB          string   `json:"asn_db"`          // GeoIP2/GeoLite2 ASN database
+	TrustForwarded bool     `json:"trust_forwarded"` // Resolve X-Forwarded-For address of requests
+	Header         string   `json:"header"`          // Also set the country code in this request header, i.e. X-Country
+	AllowCountries []string `json:"allow_countries"` // Only pass requests from these countries
+	DenyCountries  []string `json:"deny_countries"`  // Reject requests from these countries
+	AllowUnknown   bool     `json:"allow_unknown"`   // Pass requests from unresolved addresses despite allow_countries
+	Status         int      `json:"status"`          // Status of rejections, 451 by default
+}
+
+// Resolver looks up locations of addresses
+type Resolver struct {
+	city *Database
+	asn  *Database
+}
+
+// NewResolver opens configured databases
+func NewResolver(options *Options) (*Resolver, error) {
+	if options.CityDB == "" && options.ASNDB == "" {
+		return nil, fmt.Errorf("either city_db or asn_db is required")
+	}
+	r := &Resolver{}
+	var err error
+	if options.CityDB != "" {
+		if r.city, err = OpenDatabase(options.CityDB); err != nil {
+			return nil, fmt.Errorf("%s: %v", options.CityDB, err)
+		}
+	}
+	if options.ASNDB != "" {
+		if r.asn, err = OpenDatabase(options.ASNDB); err != nil {
+			return nil, fmt.Errorf("%s: %v", options.ASNDB, err)
+		}
+	}
+	return r, nil
+}
+
+// Resolve returns location of an address, nil if it isn't in databases
+func (r *Resolver) Resolve(addr string) (*httputils.GeoInfo, error) {
+	ip := net.ParseIP(addr)
+	if ip == nil {
+		return nil, fmt.Errorf("invalid address %q", addr)
+	}
+	info := &httputils.GeoInfo{}
+	found := false
+	if r.city != nil {
+		m, ok, err := r.city.Lookup(ip)
+		if err != nil {
+			return nil, err
+		}
+		if ok {
+			found = true
+			country := lookup(m, "country")
+			if country == nil {
+				// Anycast and satellite networks only have the registered country
+				country = lookup(m, "registered_country")
+			}
+			info.Country = stringValue(lookup(country, "iso_code"))
+			info.CountryName = stringValue(lookup(country, "names", "en"))
+			info.Continent = stringValue(lookup(m, "continent", "code"))
+			info.City = stringValue(lookup(m, "city", "names", "en"))
+			info.PostalCode = stringValue(lookup(m, "postal", "code"))
+			info.TimeZone = stringValue(lookup(m, "location", "time_zone"))
+			info.Latitude, _ = lookup(m, "location", "latitude").(float64)
+			info.Longitude, _ = lookup(m, "location", "longitude").(float64)
+			if subdivisions, ok := lookup(m, "subdivisions").([]interface{}); ok && len(subdivisions) > 0 {
+				if code := stringValue(lookup(subdivisions[0], "iso_code")); code != "" && info.Country != "" {
+					info.Region = info.Country + "-" + code
+				}
+			}
+		}
+	}
+	if r.asn != nil {
+		m, ok, err := r.asn.Lookup(ip)
+		if err != nil {
+			return nil, err
+		}
+		if ok {
+			found = true
+			info.ASN = uint32(uintValue(m["autonomous_system_number"]))
+			info.ASOrg = stringValue(m["autonomous_system_organization"])
+		}
+	}
+	if !found {
+		return nil, nil
+	}
+	return info, nil
+}
+
+// lookup follows keys of nested maps
+func lookup(v interface{}, keys ...string) interface{} {
+	for _, k := range keys {
+		m, ok := v.(map[string]interface{})
+		if !ok {
+			return nil
+		}
+		v = m[k]
+	}
+	return v
+}
+
+// Filter decides which countries are passed
+type Filter struct {
+	allow        map[string]bool
+	deny         map[string]bool
+	allowUnknown bool
+}
+
+// NewFilter creates a filter from options, nil if no countries are filtered
+func NewFilter(options *Options) *Filter {
+	if len(options.AllowCountries) == 0 && len(options.DenyCountries) == 0 {
+		return nil
+	}
+	f := &Filter{allowUnknown: options.AllowUnknown}
+	if len(options.AllowCountries) > 0 {
+		f.allow = make(map[string]bool)
+		for _, c := range options.AllowCountries {
+			f.allow[strings.ToUpper(c)] = true
+		}
+	}
+	f.deny = make(map[string]bool)
+	for _, c := range options.DenyCountries {
+		f.deny[strings.ToUpper(c)] = true
+	}
+	return f
+}
+
+// Allowed reports whether requests from a location pass
+func (f *Filter) Allowed(info *httputils.GeoInfo) bool {
+	if info == nil || info.Country == "" {
+		return f.allow == nil || f.allowUnknown
+	}
+	if f.deny[info.Country] {
+		return false
+	}
+	return f.allow == nil || f.allow[info.Country]
+}
@@ -0,0 +1,140 @@
+package main
+
+import (
+	"encoding/base64"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	httputils "github.com/cascades-fbp/cascades-http/utils"
+)
+
+// call keeps what's needed to encode a response for a given request
+type call struct {
+	text        bool
+	contentType string
+}
+
+// IsGRPCWeb reports whether a given content type belongs to gRPC-Web protocol
+func IsGRPCWeb(contentType string) bool {
+	return strings.HasPrefix(contentType, "application/grpc-web")
+}
+
+// TranslateRequest removes gRPC-Web framing from a request
+func TranslateRequest(req *httputils.HTTPRequest) (*httputils.HTTPRequest, *call, error) {
+	h := http.Header(req.Header)
+	ct := h.Get("Content-Type")
+	if !IsGRPCWeb(ct) {
+		return nil, nil, fmt.Errorf("unsupported content type %q", ct)
+	}
+	c := &call{
+		text:        strings.HasPrefix(ct, "application/grpc-web-text"),
+		contentType: ct,
+	}
+
+	body := req.Body
+	if c.text {
+		decoded, err := DecodeText(body)
+		if err != nil {
+			return nil, nil, err
+		}
+		body = decoded
+	}
+	messages, err := DecodeFrames(body)
+	if err != nil {
+		return nil, nil, err
+	}
+	if len(messages) != 1 {
+		return nil, nil, fmt.Errorf("expected exactly one message, got %d", len(messages))
+	}
+
+	out := req.Clone()
+	out.Method = "POST"
+	out.Body = messages[0]
+	out.ContentLength = int64(len(messages[0]))
+	if out.Header == nil {
+		out.Header = make(map[string][]string)
+	}
+	oh := http.Header(out.Header)
+	if strings.Contains(ct, "+json") {
+		oh.Set("Content-Type", "application/json")
+	} else {
+		oh.Set("Content-Type", "application/protobuf")
+	}
+	oh.Set("Content-Length", strconv.Itoa(len(messages[0])))
+	return out, c, nil
+}
+
+// TranslateResponse frames handler response for a gRPC-Web client
+func TranslateResponse(resp *httputils.HTTPResponse, c *call) *httputils.HTTPResponse {
+	code := StatusCode(resp.StatusCode)
+	trailers := http.Header{}
+	trailers.Set("grpc-status", strconv.Itoa(code))
+	if code != codeOK {
+		trailers.Set("grpc-message", http.StatusText(resp.StatusCode))
+	}
+	if upstream := http.Header(resp.Trailer); upstream.Get("grpc-status") != "" {
+		// gRPC upstream reports the status in trailers
+		trailers = upstream
+		if c, err := strconv.Atoi(upstream.Get("grpc-status")); err == nil {
+			code = c
+		}
+	}
+
+	var body []byte
+	if code == codeOK {
+		body = append(body, EncodeFrame(dataFrame, resp.Body)...)
+	}
+	body = append(body, EncodeFrame(trailerFrame, EncodeTrailers(trailers))...)
+	if c.text {
+		body = []byte(base64.StdEncoding.EncodeToString(body))
+	}
+
+	h := http.Header{}
+	for k, v := range resp.Header {
+		if k != "Content-Type" && k != "Content-Length" {
+			h[k] = v
+		}
+	}
+	h.Set("Content-Type", c.contentType)
+	h.Set("Access-Control-Allow-Origin", "*")
+	h.Set("Access-Control-Expose-Headers", "grpc-status,grpc-message")
+
+	return &httputils.HTTPResponse{
+		ID:         resp.ID,
+		StatusCode: http.StatusOK,
+		Header:     h,
+		Body:       body,
+	}
+}
+
+// PreflightResponse answers CORS preflight requests of gRPC-Web clients
+func PreflightResponse(req *httputils.HTTPRequest) *httputils.HTTPResponse {
+	h := http.Header{}
+	h.Set("Access-Control-Allow-Origin", "*")
+	h.Set("Access-Control-Allow-Methods", "POST, OPTIONS")
+	h.Set("Access-Control-Allow-Headers", "content-type,x-grpc-web,x-user-agent,grpc-timeout,authorization")
+	h.Set("Access-Control-Max-Age", "86400")
+	return &httputils.HTTPResponse{
+		ID:         req.ID,
+		StatusCode: http.StatusNoContent,
+		Header:     h,
+	}
+}
+
+// ErrorResponse returns gRPC-Web response for requests which couldn't be translated
+func ErrorResponse(id string, err error) *httputils.HTTPResponse {
+	trailers := http.Header{}
+	trailers.Set("grpc-status", strconv.Itoa(codeInvalidArgument))
+	trailers.Set("grpc-message", err.Error())
+	h := http.Header{}
+	h.Set("Content-Type", "application/grpc-web+proto")
+	h.Set("Access-Control-Allow-Origin", "*")
+	return &httputils.HTTPResponse{
+		ID:         id,
+		StatusCode: http.StatusOK,
+		Header:     h,
+		Body:       EncodeFrame(trailerFrame, EncodeTrailers(trailers)),
+	}
+}
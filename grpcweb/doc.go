@@ -0,0 +1,52 @@
+package main
+
+import "github.com/cascades-fbp/cascades/library"
+
+var registryEntry = &library.Entry{
+	Description: `Bridges gRPC-Web requests received by http/server to plain HTTP handlers. The gRPC-Web framing
+(binary or base64 text mode) of requests from IN is removed and the unary message is forwarded to OUT as a
+POST request with application/protobuf (or application/json for grpc-web+json) body. Responses from handlers
+arriving at RESPONSE are framed back with gRPC trailers and emitted on RESP. HTTP status codes are mapped
+to grpc-status. CORS preflight requests are answered directly.`,
+	Elementary: true,
+	Inports: []library.EntryPort{
+		library.EntryPort{
+			Name:        "IN",
+			Type:        "json",
+			Description: "Input port for gRPC-Web requests in predefined JSON format",
+			Required:    true,
+		},
+		library.EntryPort{
+			Name:        "RESPONSE",
+			Type:        "json",
+			Description: "Input port for responses from handlers",
+			Required:    true,
+		},
+	},
+	Outports: []library.EntryPort{
+		library.EntryPort{
+			Name:        "OUT",
+			Type:        "json",
+			Description: "Output port for translated requests",
+			Required:    true,
+		},
+		library.EntryPort{
+			Name:        "RESP",
+			Type:        "json",
+			Description: "Output port for gRPC-Web responses",
+			Required:    true,
+		},
+		library.EntryPort{
+			Name:        "LOG",
+			Type:        "json",
+			Description: "Optional output port for structured log entries (log entry JSON object defined in utils of HTTP components library)",
+			Required:    false,
+		},
+		library.EntryPort{
+			Name:        "HEARTBEAT",
+			Type:        "json",
+			Description: "Optional output port for periodic liveness IPs (heartbeat JSON object defined in utils of HTTP components library)",
+			Required:    false,
+		},
+	},
+}
@@ -0,0 +1,125 @@
+package main
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/binary"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+)
+
+// Frame types of gRPC-Web protocol
+const (
+	dataFrame    byte = 0x00
+	trailerFrame byte = 0x80
+)
+
+// gRPC status codes used in HTTP status mapping
+const (
+	codeOK               = 0
+	codeUnknown          = 2
+	codeInvalidArgument  = 3
+	codeDeadlineExceeded = 4
+	codeNotFound         = 5
+	codePermissionDenied = 7
+	codeUnimplemented    = 12
+	codeInternal         = 13
+	codeUnavailable      = 14
+	codeUnauthenticated  = 16
+)
+
+// DecodeFrames returns payloads of data frames in a gRPC-Web body
+func DecodeFrames(body []byte) ([][]byte, error) {
+	var messages [][]byte
+	for len(body) > 0 {
+		if len(body) < 5 {
+			return nil, fmt.Errorf("truncated frame header")
+		}
+		flag := body[0]
+		length := binary.BigEndian.Uint32(body[1:5])
+		if uint32(len(body)-5) < length {
+			return nil, fmt.Errorf("truncated frame payload")
+		}
+		if flag&trailerFrame == 0 {
+			messages = append(messages, body[5:5+length])
+		}
+		body = body[5+length:]
+	}
+	return messages, nil
+}
+
+// EncodeFrame renders a single gRPC-Web frame
+func EncodeFrame(flag byte, payload []byte) []byte {
+	frame := make([]byte, 5+len(payload))
+	frame[0] = flag
+	binary.BigEndian.PutUint32(frame[1:5], uint32(len(payload)))
+	copy(frame[5:], payload)
+	return frame
+}
+
+// EncodeTrailers renders trailer frame payload
+func EncodeTrailers(trailers http.Header) []byte {
+	names := make([]string, 0, len(trailers))
+	for name := range trailers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var buf bytes.Buffer
+	for _, name := range names {
+		for _, v := range trailers[name] {
+			buf.WriteString(strings.ToLower(name) + ": " + v + "\r\n")
+		}
+	}
+	return buf.Bytes()
+}
+
+// DecodeText decodes body of grpc-web-text requests which may consist of several base64 chunks
+func DecodeText(body []byte) ([]byte, error) {
+	var res []byte
+	for len(body) > 0 {
+		// Every chunk ends at its padding
+		end := bytes.IndexByte(body, '=')
+		if end == -1 {
+			end = len(body)
+		} else {
+			for end < len(body) && body[end] == '=' {
+				end++
+			}
+		}
+		chunk, err := base64.StdEncoding.DecodeString(string(body[:end]))
+		if err != nil {
+			return nil, err
+		}
+		res = append(res, chunk...)
+		body = body[end:]
+	}
+	return res, nil
+}
+
+// StatusCode maps HTTP status of a handler response to gRPC status code
+func StatusCode(status int) int {
+	switch {
+	case status >= 200 && status < 300:
+		return codeOK
+	case status == http.StatusBadRequest:
+		return codeInvalidArgument
+	case status == http.StatusUnauthorized:
+		return codeUnauthenticated
+	case status == http.StatusForbidden:
+		return codePermissionDenied
+	case status == http.StatusNotFound:
+		return codeNotFound
+	case status == http.StatusMethodNotAllowed, status == http.StatusNotImplemented:
+		return codeUnimplemented
+	case status == http.StatusTooManyRequests, status == http.StatusBadGateway, status == http.StatusServiceUnavailable:
+		return codeUnavailable
+	case status == http.StatusGatewayTimeout:
+		return codeDeadlineExceeded
+	case status >= 500:
+		return codeInternal
+	}
+	return codeUnknown
+}
@@ -0,0 +1,193 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"os"
+
+	httputils "github.com/cascades-fbp/cascades-http/utils"
+	"github.com/cascades-fbp/cascades/components/utils"
+	"github.com/cascades-fbp/cascades/runtime"
+	zmq "github.com/pebbe/zmq4"
+)
+
+var (
+	// Flags
+	inputEndpoint     = flag.String("port.in", "", "Component's input port endpoint")
+	responseEndpoint  = flag.String("port.response", "", "Component's handler response port endpoint")
+	outputEndpoint    = flag.String("port.out", "", "Component's output port endpoint")
+	respEndpoint      = flag.String("port.resp", "", "Component's response port endpoint")
+	logEndpoint       = flag.String("port.log", "", "Component's log port endpoint")
+	heartbeatEndpoint = flag.String("port.heartbeat", "", "Component's heartbeat port endpoint")
+	metricsAddr       = flag.String("metrics", "", "Address to expose metrics on, i.e. 127.0.0.1:9090 (disabled if empty)")
+	jsonFlag          = flag.Bool("json", false, "Print component documentation in JSON")
+	debug             = flag.Bool("debug", false, "Enable debug mode")
+
+	// Internal
+	inPort, responsePort, outPort, respPort, logPort, heartbeatPort *zmq.Socket
+	err                                                             error
+	logger                                                          = httputils.NewLogger("http/grpcweb")
+	liveness                                                        = httputils.NewLiveness("http/grpcweb")
+	metrics                                                         = httputils.NewMetrics(logger, liveness)
+	shutdown                                                        *httputils.Shutdown
+)
+
+// validateArgs checks all required flags
+func validateArgs() {
+	if *inputEndpoint == "" {
+		flag.Usage()
+		os.Exit(1)
+	}
+	if *responseEndpoint == "" {
+		flag.Usage()
+		os.Exit(1)
+	}
+	if *outputEndpoint == "" {
+		flag.Usage()
+		os.Exit(1)
+	}
+	if *respEndpoint == "" {
+		flag.Usage()
+		os.Exit(1)
+	}
+}
+
+// openPorts create ZMQ sockets
+func openPorts() {
+	inPort, err = utils.CreateInputPort("http/grpcweb.in", *inputEndpoint, nil)
+	utils.AssertError(err)
+
+	responsePort, err = utils.CreateInputPort("http/grpcweb.response", *responseEndpoint, nil)
+	utils.AssertError(err)
+
+	outPort, err = utils.CreateOutputPort("http/grpcweb.out", *outputEndpoint, nil)
+	utils.AssertError(err)
+
+	respPort, err = utils.CreateOutputPort("http/grpcweb.resp", *respEndpoint, nil)
+	utils.AssertError(err)
+
+	if *logEndpoint != "" {
+		logPort, err = utils.CreateOutputPort("http/grpcweb.log", *logEndpoint, nil)
+		utils.AssertError(err)
+		logger.SetSink(func(ip [][]byte) { logPort.SendMessageDontwait(ip) })
+	}
+
+	if *heartbeatEndpoint != "" {
+		heartbeatPort, err = utils.CreateOutputPort("http/grpcweb.heartbeat", *heartbeatEndpoint, nil)
+		utils.AssertError(err)
+		liveness.Start(func(ip [][]byte) { heartbeatPort.SendMessageDontwait(ip) })
+	}
+}
+
+// closePorts closes all active ports and terminates ZMQ context
+func closePorts() {
+	shutdown.Close(inPort, responsePort)
+	liveness.Stop()
+	shutdown.Flush(outPort, respPort, heartbeatPort, logPort)
+	zmq.Term()
+}
+
+// sendResponse converts response to IP and sends it to RESP port
+func sendResponse(resp *httputils.HTTPResponse) {
+	ip, err := httputils.Response2IP(resp)
+	if err != nil {
+		logger.Warn("Failed to convert response to IP", "error", err)
+		return
+	}
+	respPort.SendMessage(ip)
+}
+
+func main() {
+	flag.Parse()
+
+	if *jsonFlag {
+		doc, _ := registryEntry.JSON()
+		fmt.Println(string(doc))
+		os.Exit(0)
+	}
+
+	log.SetFlags(0)
+	if *debug {
+		logger.SetLevel(httputils.LevelDebug)
+		logger.SetOutput(os.Stdout)
+	}
+	log.SetOutput(logger.Writer())
+
+	validateArgs()
+
+	shutdown = httputils.NewShutdown(logger)
+	openPorts()
+
+	if *metricsAddr != "" {
+		err = metrics.Serve(*metricsAddr)
+		utils.AssertError(err)
+	}
+
+	err = runtime.SetupShutdownByDisconnect(inPort, "http/grpcweb.in", shutdown.Signals())
+	utils.AssertError(err)
+
+	poller := zmq.NewPoller()
+	poller.Add(inPort, zmq.POLLIN)
+	poller.Add(responsePort, zmq.POLLIN)
+
+	// Map of request ID to pending calls
+	calls := make(map[string]*call)
+
+	// Main loop
+	for !shutdown.Stopping() {
+		sockets, err := poller.Poll(shutdown.PollInterval)
+		if err != nil {
+			logger.Error("Error polling ports", "error", err)
+			continue
+		}
+		for _, socket := range sockets {
+			ip, err := socket.Socket.RecvMessageBytes(0)
+			if err != nil {
+				logger.Error("Error receiving message", "error", err)
+				continue
+			}
+			liveness.Inc()
+			if !httputils.IsValidIP(ip) || !runtime.IsPacket(ip) {
+				continue
+			}
+
+			switch socket.Socket {
+			case inPort:
+				req, err := httputils.IP2Request(ip)
+				if err != nil {
+					logger.Warn("Failed to convert IP to request", "error", err)
+					continue
+				}
+				if req.Method == "OPTIONS" {
+					sendResponse(PreflightResponse(req))
+					continue
+				}
+				out, c, err := TranslateRequest(req)
+				if err != nil {
+					logger.Warn("Failed to translate request", "error", err)
+					sendResponse(ErrorResponse(req.ID, err))
+					continue
+				}
+				calls[req.ID] = c
+				ip, _ = httputils.Request2IP(out)
+				outPort.SendMessage(ip)
+
+			case responsePort:
+				resp, err := httputils.IP2Response(ip)
+				if err != nil {
+					logger.Warn("Failed to convert IP to response", "error", err)
+					continue
+				}
+				c, ok := calls[resp.ID]
+				if !ok {
+					logger.Warn("Didn't find call for a given ID", "id", resp.ID)
+					continue
+				}
+				delete(calls, resp.ID)
+				sendResponse(TranslateResponse(resp, c))
+			}
+		}
+	}
+	shutdown.Exit(closePorts)
+}
@@ -0,0 +1,274 @@
+package main
+
+import (
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"sort"
+	"strings"
+	"time"
+
+	httputils "github.com/cascades-fbp/cascades-http/utils"
+)
+
+// Options describe the configuration IP of the component
+type Options struct {
+	URL                string              `json:"url"`                  // ldap://host:389 or ldaps://host:636
+	StartTLS           bool                `json:"start_tls"`            // Upgrade ldap:// connections with StartTLS
+	CAFile             string              `json:"ca_file"`              // PEM bundle to verify the server instead of system roots
+	InsecureSkipVerify bool                `json:"insecure_skip_verify"` // Don't verify the server certificate
+	Timeout            httputils.Duration  `json:"timeout"`              // Timeout of connecting and every operation
+	BindDN             string              `json:"bind_dn"`              // Service account searching users, anonymous if empty
+	BindPassword       string              `json:"bind_password"`        // Password of the service account
+	BaseDN             string              `json:"base_dn"`              // Subtree users are searched in
+	UserFilter         string              `json:"user_filter"`          // Filter of users with {user} placeholder, (uid={user}) by default
+	GroupAttribute     string              `json:"group_attribute"`      // Attribute of users listing their group DNs, memberOf by default
+	GroupBaseDN        string              `json:"group_base_dn"`        // Subtree groups are searched in, base_dn by default
+	GroupFilter        string              `json:"group_filter"`         // Filter of groups with {dn} and {user} placeholders, i.e. (member={dn})
+	Roles              map[string][]string `json:"roles"`                // Roles granted by group name or DN
+	Realm              string              `json:"realm"`                // Protection space of Basic challenges
+	PoolSize           int                 `json:"pool_size"`            // Maximal number of idle connections
+	IdleTimeout        httputils.Duration  `json:"idle_timeout"`         // Idle connections are closed after this interval
+	CacheTTL           httputils.Duration  `json:"cache_ttl"`            // Remember successful logins for this long (disabled if zero)
+}
+
+// Identity is the resolved user of valid credentials
+type Identity struct {
+	User   string
+	DN     string
+	Groups []string
+	Roles  []string
+}
+
+// errInvalidCredentials is returned for unknown users and wrong passwords
+var errInvalidCredentials = errors.New("invalid credentials")
+
+// cachedIdentity is a remembered login
+type cachedIdentity struct {
+	identity *Identity
+	expires  time.Time
+}
+
+// Authenticator checks credentials against the directory, it isn't safe for
+// concurrent use
+type Authenticator struct {
+	options *Options
+	pool    *Pool
+	roles   map[string][]string // Roles by lower case group name or DN
+	cache   map[[sha256.Size]byte]cachedIdentity
+	cleaned time.Time
+}
+
+// NewAuthenticator validates options and creates an authenticator
+func NewAuthenticator(o *Options) (*Authenticator, error) {
+	if o.URL == "" || o.BaseDN == "" {
+		return nil, fmt.Errorf("url and base_dn are required")
+	}
+	if o.UserFilter == "" {
+		o.UserFilter = "(uid={user})"
+	}
+	if _, err := compileFilter(strings.Replace(o.UserFilter, "{user}", "x", -1)); err != nil {
+		return nil, err
+	}
+	if o.GroupAttribute == "" {
+		o.GroupAttribute = "memberOf"
+	}
+	if o.GroupBaseDN == "" {
+		o.GroupBaseDN = o.BaseDN
+	}
+	if o.GroupFilter != "" {
+		if _, err := compileFilter(strings.NewReplacer("{dn}", "x", "{user}", "x").Replace(o.GroupFilter)); err != nil {
+			return nil, err
+		}
+	}
+	if o.Realm == "" {
+		o.Realm = "cascades"
+	}
+	if o.PoolSize <= 0 {
+		o.PoolSize = 4
+	}
+	if o.IdleTimeout <= 0 {
+		o.IdleTimeout = httputils.Duration(time.Minute)
+	}
+	if o.Timeout <= 0 {
+		o.Timeout = httputils.Duration(5 * time.Second)
+	}
+
+	config := &tls.Config{InsecureSkipVerify: o.InsecureSkipVerify}
+	if o.CAFile != "" {
+		pem, err := ioutil.ReadFile(o.CAFile)
+		if err != nil {
+			return nil, err
+		}
+		config.RootCAs = x509.NewCertPool()
+		if !config.RootCAs.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("no certificates in %s", o.CAFile)
+		}
+	}
+
+	a := &Authenticator{
+		options: o,
+		roles:   make(map[string][]string, len(o.Roles)),
+		cache:   make(map[[sha256.Size]byte]cachedIdentity),
+	}
+	for group, roles := range o.Roles {
+		a.roles[strings.ToLower(group)] = roles
+	}
+	a.pool = NewPool(func() (*Conn, error) {
+		c, err := Dial(o.URL, config, o.StartTLS, time.Duration(o.Timeout))
+		if err != nil {
+			return nil, err
+		}
+		if err = c.Bind(o.BindDN, o.BindPassword); err != nil {
+			c.Close()
+			return nil, fmt.Errorf("service bind failed: %v", err)
+		}
+		return c, nil
+	}, o.PoolSize, time.Duration(o.IdleTimeout))
+	return a, nil
+}
+
+// Authenticate resolves the identity of credentials, errInvalidCredentials
+// is returned if they're rejected and other errors if the directory failed
+func (a *Authenticator) Authenticate(user, password string) (*Identity, error) {
+	if user == "" || password == "" {
+		return nil, errInvalidCredentials
+	}
+	key := sha256.Sum256([]byte(user + "\x00" + password))
+	now := time.Now()
+	if c, ok := a.cache[key]; ok && now.Before(c.expires) {
+		return c.identity, nil
+	}
+
+	c, err := a.pool.Get()
+	if err != nil {
+		return nil, err
+	}
+	id, err := a.authenticate(c, user, password)
+	if err != nil && isConnError(err) {
+		// Pooled connection could have been dropped by the server
+		c.Close()
+		if c, err = a.pool.Dial(); err != nil {
+			return nil, err
+		}
+		id, err = a.authenticate(c, user, password)
+	}
+	if err != nil && isConnError(err) {
+		c.Close()
+		return nil, err
+	}
+	a.pool.Put(c)
+	if err != nil {
+		return nil, err
+	}
+
+	if ttl := time.Duration(a.options.CacheTTL); ttl > 0 {
+		if now.Sub(a.cleaned) > ttl {
+			for k, c := range a.cache {
+				if now.After(c.expires) {
+					delete(a.cache, k)
+				}
+			}
+			a.cleaned = now
+		}
+		a.cache[key] = cachedIdentity{identity: id, expires: now.Add(ttl)}
+	}
+	return id, nil
+}
+
+// authenticate finds the user and groups with the service account and checks
+// the password binding as the user. The connection is bound as the service
+// account again afterwards.
+func (a *Authenticator) authenticate(c *Conn, user, password string) (*Identity, error) {
+	o := a.options
+	filter := strings.Replace(o.UserFilter, "{user}", EscapeFilter(user), -1)
+	entries, err := c.Search(o.BaseDN, filter, []string{o.GroupAttribute}, 2)
+	if e, ok := err.(*LDAPError); ok && e.Code == 4 {
+		// sizeLimitExceeded
+		return nil, fmt.Errorf("%w: user %q is ambiguous", errInvalidCredentials, user)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if len(entries) != 1 {
+		return nil, fmt.Errorf("%w: user %q matched %d entries", errInvalidCredentials, user, len(entries))
+	}
+	entry := entries[0]
+
+	groups := entry.Attributes[strings.ToLower(o.GroupAttribute)]
+	if o.GroupFilter != "" {
+		filter := strings.NewReplacer("{dn}", EscapeFilter(entry.DN), "{user}", EscapeFilter(user)).Replace(o.GroupFilter)
+		found, err := c.Search(o.GroupBaseDN, filter, []string{"cn"}, 0)
+		if err != nil {
+			return nil, err
+		}
+		for _, g := range found {
+			groups = append(groups, g.DN)
+		}
+	}
+
+	bindErr := c.Bind(entry.DN, password)
+	if err = c.Bind(o.BindDN, o.BindPassword); err != nil {
+		return nil, err
+	}
+	if _, ok := bindErr.(*LDAPError); ok {
+		return nil, fmt.Errorf("%w: %v", errInvalidCredentials, bindErr)
+	}
+	if bindErr != nil {
+		return nil, bindErr
+	}
+
+	return &Identity{
+		User:   user,
+		DN:     entry.DN,
+		Groups: groupNames(groups),
+		Roles:  a.mapRoles(groups),
+	}, nil
+}
+
+// mapRoles returns sorted roles granted to group DNs by their DN or name
+func (a *Authenticator) mapRoles(groups []string) []string {
+	seen := make(map[string]bool)
+	var roles []string
+	for _, dn := range groups {
+		for _, key := range []string{strings.ToLower(dn), strings.ToLower(rdnValue(dn))} {
+			for _, role := range a.roles[key] {
+				if !seen[role] {
+					seen[role] = true
+					roles = append(roles, role)
+				}
+			}
+		}
+	}
+	sort.Strings(roles)
+	return roles
+}
+
+// Close closes pooled connections
+func (a *Authenticator) Close() {
+	a.pool.Close()
+}
+
+// groupNames returns sorted unique names of group DNs
+func groupNames(groups []string) []string {
+	seen := make(map[string]bool)
+	var names []string
+	for _, dn := range groups {
+		name := rdnValue(dn)
+		if !seen[name] {
+			seen[name] = true
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+// isConnError reports failures leaving a connection unusable
+func isConnError(err error) bool {
+	var e *LDAPError
+	return !errors.As(err, &e) && !errors.Is(err, errInvalidCredentials)
+}
@@ -0,0 +1,58 @@
+package main
+
+import "github.com/cascades-fbp/cascades/library"
+
+var registryEntry = &library.Entry{
+	Description: `Validates Basic auth credentials of incoming request IPs against an LDAP/AD server. The user is
+searched with the service account (bind_dn) and the password is checked by binding as the found entry over pooled
+connections. Groups are taken from the group attribute of the user (memberOf) or found with group_filter and mapped
+to roles. Authenticated requests are forwarded to OUT with user, groups and roles fields set, the rest are answered
+with 401 (or 503 if the directory is unavailable) on REJECT port.`,
+	Elementary: true,
+	Inports: []library.EntryPort{
+		library.EntryPort{
+			Name:        "OPTIONS",
+			Type:        "json",
+			Description: `Configuration port, i.e. {"url": "ldaps://ldap.example.com", "bind_dn": "cn=svc,dc=example,dc=com", "bind_password": "secret", "base_dn": "ou=people,dc=example,dc=com", "user_filter": "(sAMAccountName={user})", "roles": {"Admins": ["admin"], "cn=devs,ou=groups,dc=example,dc=com": ["write", "read"]}, "cache_ttl": "1m"}`,
+			Required:    true,
+		},
+		library.EntryPort{
+			Name:        "IN",
+			Type:        "json",
+			Description: "Input port for requests in predefined JSON format",
+			Required:    true,
+		},
+	},
+	Outports: []library.EntryPort{
+		library.EntryPort{
+			Name:        "OUT",
+			Type:        "json",
+			Description: "Output port for authenticated requests",
+			Required:    true,
+		},
+		library.EntryPort{
+			Name:        "REJECT",
+			Type:        "json",
+			Description: "Output port for 401 responses to requests without valid credentials",
+			Required:    true,
+		},
+		library.EntryPort{
+			Name:        "ERR",
+			Type:        "json",
+			Description: "Optional error port for invalid IPs and directory failures (error JSON object defined in utils of HTTP components library)",
+			Required:    false,
+		},
+		library.EntryPort{
+			Name:        "LOG",
+			Type:        "json",
+			Description: "Optional output port for structured log entries (log entry JSON object defined in utils of HTTP components library)",
+			Required:    false,
+		},
+		library.EntryPort{
+			Name:        "HEARTBEAT",
+			Type:        "json",
+			Description: "Optional output port for periodic liveness IPs (heartbeat JSON object defined in utils of HTTP components library)",
+			Required:    false,
+		},
+	},
+}
@@ -0,0 +1,551 @@
+package main
+
+import (
+	"bufio"
+	"crypto/tls"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// Subset of LDAPv3 (RFC 4511) needed to authenticate users: simple bind,
+// search and StartTLS over BER encoded messages.
+
+const (
+	ldapVersion = 3
+
+	// Application tags of protocol operations
+	tagBindRequest     = 0x60
+	tagBindResponse    = 0x61
+	tagUnbindRequest   = 0x42
+	tagSearchRequest   = 0x63
+	tagSearchEntry     = 0x64
+	tagSearchDone      = 0x65
+	tagExtendedRequest = 0x77
+	tagExtendedResult  = 0x78
+
+	// Universal tags
+	tagBoolean     = 0x01
+	tagInteger     = 0x02
+	tagOctetString = 0x04
+	tagEnumerated  = 0x0a
+	tagSequence    = 0x30
+
+	scopeWholeSubtree = 2
+	startTLSOID       = "1.3.6.1.4.1.1466.20037"
+
+	// ResultInvalidCredentials is returned by servers for wrong passwords
+	ResultInvalidCredentials = 49
+
+	// maxMessageSize limits server messages to protect from bogus lengths
+	maxMessageSize = 16 << 20
+)
+
+// LDAPError is a non-success result of an operation
+type LDAPError struct {
+	Code    int
+	Message string
+}
+
+func (e *LDAPError) Error() string {
+	if e.Message != "" {
+		return fmt.Sprintf("ldap result %d: %s", e.Code, e.Message)
+	}
+	return fmt.Sprintf("ldap result %d", e.Code)
+}
+
+// Entry is an object returned by a search, attribute names are lower case
+type Entry struct {
+	DN         string
+	Attributes map[string][]string
+}
+
+// Conn is a connection to an LDAP server, it isn't safe for concurrent use
+type Conn struct {
+	conn    net.Conn
+	reader  *bufio.Reader
+	timeout time.Duration
+	id      int64
+}
+
+// Dial connects to ldap:// or ldaps:// URL, startTLS upgrades plain connections
+func Dial(rawurl string, config *tls.Config, startTLS bool, timeout time.Duration) (*Conn, error) {
+	u, err := url.Parse(rawurl)
+	if err != nil {
+		return nil, err
+	}
+	host := u.Host
+	if u.Port() == "" {
+		port := "389"
+		if u.Scheme == "ldaps" {
+			port = "636"
+		}
+		host = net.JoinHostPort(u.Hostname(), port)
+	}
+	if config.ServerName == "" {
+		config = config.Clone()
+		config.ServerName = u.Hostname()
+	}
+
+	dialer := &net.Dialer{Timeout: timeout}
+	var conn net.Conn
+	switch u.Scheme {
+	case "ldap":
+		conn, err = dialer.Dial("tcp", host)
+	case "ldaps":
+		conn, err = tls.DialWithDialer(dialer, "tcp", host, config)
+	default:
+		return nil, fmt.Errorf("unsupported scheme %q", u.Scheme)
+	}
+	if err != nil {
+		return nil, err
+	}
+	c := &Conn{conn: conn, reader: bufio.NewReader(conn), timeout: timeout}
+	if startTLS && u.Scheme == "ldap" {
+		if err = c.startTLS(config); err != nil {
+			conn.Close()
+			return nil, err
+		}
+	}
+	return c, nil
+}
+
+// startTLS runs the StartTLS extended operation (RFC 4511, 4.14)
+func (c *Conn) startTLS(config *tls.Config) error {
+	req := berTLV(tagExtendedRequest, berTLV(0x80, []byte(startTLSOID)))
+	if _, err := c.request(req, tagExtendedResult, nil); err != nil {
+		return err
+	}
+	tc := tls.Client(c.conn, config)
+	tc.SetDeadline(time.Now().Add(c.timeout))
+	if err := tc.Handshake(); err != nil {
+		return err
+	}
+	c.conn = tc
+	c.reader = bufio.NewReader(tc)
+	return nil
+}
+
+// Bind authenticates the connection with a simple bind. An empty password
+// is rejected since servers treat it as an unauthenticated bind (RFC 4513, 5.1.2)
+// unless the DN is empty too.
+func (c *Conn) Bind(dn, password string) error {
+	if password == "" && dn != "" {
+		return &LDAPError{Code: ResultInvalidCredentials, Message: "empty password"}
+	}
+	req := berTLV(tagBindRequest, berJoin(
+		berInteger(tagInteger, ldapVersion),
+		berTLV(tagOctetString, []byte(dn)),
+		berTLV(0x80, []byte(password)),
+	))
+	_, err := c.request(req, tagBindResponse, nil)
+	return err
+}
+
+// Search returns entries below base DN matching a filter in RFC 4515 syntax
+func (c *Conn) Search(base, filter string, attributes []string, sizeLimit int) ([]*Entry, error) {
+	f, err := compileFilter(filter)
+	if err != nil {
+		return nil, err
+	}
+	var attrs []byte
+	for _, a := range attributes {
+		attrs = append(attrs, berTLV(tagOctetString, []byte(a))...)
+	}
+	req := berTLV(tagSearchRequest, berJoin(
+		berTLV(tagOctetString, []byte(base)),
+		berInteger(tagEnumerated, scopeWholeSubtree),
+		berInteger(tagEnumerated, 0), // neverDerefAliases
+		berInteger(tagInteger, int64(sizeLimit)),
+		berInteger(tagInteger, int64(c.timeout/time.Second)),
+		berTLV(tagBoolean, []byte{0}),
+		f,
+		berTLV(tagSequence, attrs),
+	))
+
+	var entries []*Entry
+	_, err = c.request(req, tagSearchDone, func(op berElement) error {
+		if op.tag != tagSearchEntry {
+			// Continuation references aren't followed
+			return nil
+		}
+		e, err := parseEntry(op.data)
+		if err != nil {
+			return err
+		}
+		entries = append(entries, e)
+		return nil
+	})
+	return entries, err
+}
+
+// Close unbinds and closes the connection
+func (c *Conn) Close() error {
+	c.id++
+	c.conn.SetDeadline(time.Now().Add(c.timeout))
+	c.conn.Write(berTLV(tagSequence, berJoin(berInteger(tagInteger, c.id), berTLV(tagUnbindRequest, nil))))
+	return c.conn.Close()
+}
+
+// request sends an operation and reads messages until the one tagged done,
+// its LDAPResult is returned. Other responses are passed to a given function.
+func (c *Conn) request(op []byte, done byte, f func(berElement) error) ([]berElement, error) {
+	c.id++
+	c.conn.SetDeadline(time.Now().Add(c.timeout))
+	if _, err := c.conn.Write(berTLV(tagSequence, berJoin(berInteger(tagInteger, c.id), op))); err != nil {
+		return nil, err
+	}
+	for {
+		msg, err := readElement(c.reader)
+		if err != nil {
+			return nil, err
+		}
+		fields, err := berElements(msg.data)
+		if err != nil || len(fields) < 2 || fields[0].tag != tagInteger {
+			return nil, fmt.Errorf("malformed ldap message")
+		}
+		if id := berInt(fields[0].data); id != c.id {
+			// Unsolicited notifications have ID 0 and only announce disconnects
+			if id == 0 {
+				return nil, fmt.Errorf("server sent notice of disconnection")
+			}
+			continue
+		}
+		if fields[1].tag != done {
+			if f == nil {
+				return nil, fmt.Errorf("unexpected ldap operation 0x%x", fields[1].tag)
+			}
+			if err = f(fields[1]); err != nil {
+				return nil, err
+			}
+			continue
+		}
+		result, err := berElements(fields[1].data)
+		if err != nil || len(result) < 3 || result[0].tag != tagEnumerated {
+			return nil, fmt.Errorf("malformed ldap result")
+		}
+		if code := int(berInt(result[0].data)); code != 0 {
+			return nil, &LDAPError{Code: code, Message: string(result[2].data)}
+		}
+		return result, nil
+	}
+}
+
+// parseEntry decodes SearchResultEntry
+func parseEntry(b []byte) (*Entry, error) {
+	fields, err := berElements(b)
+	if err != nil || len(fields) != 2 {
+		return nil, fmt.Errorf("malformed search entry")
+	}
+	e := &Entry{DN: string(fields[0].data), Attributes: make(map[string][]string)}
+	attrs, err := berElements(fields[1].data)
+	if err != nil {
+		return nil, err
+	}
+	for _, a := range attrs {
+		parts, err := berElements(a.data)
+		if err != nil || len(parts) != 2 {
+			return nil, fmt.Errorf("malformed search entry attribute")
+		}
+		values, err := berElements(parts[1].data)
+		if err != nil {
+			return nil, err
+		}
+		name := strings.ToLower(string(parts[0].data))
+		for _, v := range values {
+			e.Attributes[name] = append(e.Attributes[name], string(v.data))
+		}
+	}
+	return e, nil
+}
+
+// berElement is a decoded TLV
+type berElement struct {
+	tag  byte
+	data []byte
+}
+
+// readElement reads one TLV from a stream
+func readElement(r *bufio.Reader) (berElement, error) {
+	tag, err := r.ReadByte()
+	if err != nil {
+		return berElement{}, err
+	}
+	l, err := r.ReadByte()
+	if err != nil {
+		return berElement{}, err
+	}
+	length := int(l)
+	if l&0x80 != 0 {
+		n := int(l & 0x7f)
+		if n == 0 || n > 4 {
+			return berElement{}, fmt.Errorf("unsupported ber length")
+		}
+		length = 0
+		for i := 0; i < n; i++ {
+			b, err := r.ReadByte()
+			if err != nil {
+				return berElement{}, err
+			}
+			length = length<<8 | int(b)
+		}
+	}
+	if length > maxMessageSize {
+		return berElement{}, fmt.Errorf("ldap message of %d bytes is too large", length)
+	}
+	data := make([]byte, length)
+	if _, err = io.ReadFull(r, data); err != nil {
+		return berElement{}, err
+	}
+	return berElement{tag: tag, data: data}, nil
+}
+
+// berElements splits contents of a constructed element
+func berElements(b []byte) ([]berElement, error) {
+	var elements []berElement
+	for len(b) > 0 {
+		if len(b) < 2 {
+			return nil, fmt.Errorf("truncated ber element")
+		}
+		tag, length, b2 := b[0], int(b[1]), b[2:]
+		if b[1]&0x80 != 0 {
+			n := int(b[1] & 0x7f)
+			if n == 0 || n > 4 || len(b2) < n {
+				return nil, fmt.Errorf("invalid ber length")
+			}
+			length = 0
+			for _, v := range b2[:n] {
+				length = length<<8 | int(v)
+			}
+			b2 = b2[n:]
+		}
+		if length > len(b2) {
+			return nil, fmt.Errorf("truncated ber element")
+		}
+		elements = append(elements, berElement{tag: tag, data: b2[:length]})
+		b = b2[length:]
+	}
+	return elements, nil
+}
+
+// berTLV encodes an element with definite length
+func berTLV(tag byte, data []byte) []byte {
+	b := []byte{tag}
+	switch n := len(data); {
+	case n < 0x80:
+		b = append(b, byte(n))
+	case n <= 0xff:
+		b = append(b, 0x81, byte(n))
+	case n <= 0xffff:
+		b = append(b, 0x82, byte(n>>8), byte(n))
+	default:
+		b = append(b, 0x84, byte(n>>24), byte(n>>16), byte(n>>8), byte(n))
+	}
+	return append(b, data...)
+}
+
+// berInteger encodes an integer in minimal two's complement form
+func berInteger(tag byte, v int64) []byte {
+	var b []byte
+	for {
+		b = append([]byte{byte(v)}, b...)
+		v >>= 8
+		if (v == 0 && b[0]&0x80 == 0) || (v == -1 && b[0]&0x80 != 0) {
+			break
+		}
+	}
+	return berTLV(tag, b)
+}
+
+// berInt decodes two's complement integer
+func berInt(b []byte) int64 {
+	var v int64
+	for i, c := range b {
+		if i == 0 && c&0x80 != 0 {
+			v = -1
+		}
+		v = v<<8 | int64(c)
+	}
+	return v
+}
+
+func berJoin(parts ...[]byte) []byte {
+	var b []byte
+	for _, p := range parts {
+		b = append(b, p...)
+	}
+	return b
+}
+
+// compileFilter encodes a filter string (RFC 4515), extensible matches aren't supported
+func compileFilter(filter string) ([]byte, error) {
+	b, rest, err := filterItem(strings.TrimSpace(filter))
+	if err != nil {
+		return nil, fmt.Errorf("invalid filter %q: %v", filter, err)
+	}
+	if rest != "" {
+		return nil, fmt.Errorf("invalid filter %q: unexpected %q", filter, rest)
+	}
+	return b, nil
+}
+
+// filterItem encodes a parenthesized filter returning the rest of the string
+func filterItem(f string) ([]byte, string, error) {
+	if !strings.HasPrefix(f, "(") || len(f) < 3 {
+		return nil, "", fmt.Errorf("expected (")
+	}
+	f = f[1:]
+	switch f[0] {
+	case '&', '|', '!':
+		tag := map[byte]byte{'&': 0xa0, '|': 0xa1, '!': 0xa2}[f[0]]
+		f = f[1:]
+		var content []byte
+		count := 0
+		for strings.HasPrefix(f, "(") {
+			item, rest, err := filterItem(f)
+			if err != nil {
+				return nil, "", err
+			}
+			content = append(content, item...)
+			f = rest
+			count++
+		}
+		if !strings.HasPrefix(f, ")") {
+			return nil, "", fmt.Errorf("expected )")
+		}
+		if count == 0 || tag == 0xa2 && count != 1 {
+			return nil, "", fmt.Errorf("invalid number of subfilters")
+		}
+		return berTLV(tag, content), f[1:], nil
+	}
+
+	end := strings.IndexByte(f, ')')
+	if end < 0 {
+		return nil, "", fmt.Errorf("expected )")
+	}
+	item, rest := f[:end], f[end+1:]
+	eq := strings.IndexByte(item, '=')
+	if eq <= 0 {
+		return nil, "", fmt.Errorf("expected attribute=value")
+	}
+	attr, value := item[:eq], item[eq+1:]
+	tag := byte(0xa3) // equalityMatch
+	switch attr[len(attr)-1] {
+	case '~':
+		tag = 0xa8
+	case '>':
+		tag = 0xa5
+	case '<':
+		tag = 0xa6
+	case ':':
+		return nil, "", fmt.Errorf("extensible match isn't supported")
+	}
+	if tag != 0xa3 {
+		attr = attr[:len(attr)-1]
+	}
+
+	if tag == 0xa3 && value == "*" {
+		return berTLV(0x87, []byte(attr)), rest, nil
+	}
+	if tag == 0xa3 && strings.Contains(value, "*") {
+		parts := strings.Split(value, "*")
+		var subs []byte
+		for i, p := range parts {
+			if p == "" {
+				continue
+			}
+			v, err := unescapeFilterValue(p)
+			if err != nil {
+				return nil, "", err
+			}
+			t := byte(0x81) // any
+			if i == 0 {
+				t = 0x80 // initial
+			} else if i == len(parts)-1 {
+				t = 0x82 // final
+			}
+			subs = append(subs, berTLV(t, v)...)
+		}
+		return berTLV(0xa4, berJoin(berTLV(tagOctetString, []byte(attr)), berTLV(tagSequence, subs))), rest, nil
+	}
+	v, err := unescapeFilterValue(value)
+	if err != nil {
+		return nil, "", err
+	}
+	return berTLV(tag, berJoin(berTLV(tagOctetString, []byte(attr)), berTLV(tagOctetString, v))), rest, nil
+}
+
+// unescapeFilterValue decodes \XX escapes of a filter value
+func unescapeFilterValue(v string) ([]byte, error) {
+	var b []byte
+	for i := 0; i < len(v); i++ {
+		if v[i] != '\\' {
+			b = append(b, v[i])
+			continue
+		}
+		if i+2 >= len(v) {
+			return nil, fmt.Errorf("truncated escape in %q", v)
+		}
+		c, err := hex.DecodeString(v[i+1 : i+3])
+		if err != nil {
+			return nil, fmt.Errorf("invalid escape in %q", v)
+		}
+		b = append(b, c[0])
+		i += 2
+	}
+	return b, nil
+}
+
+// EscapeFilter escapes special characters of a value inserted into a filter
+func EscapeFilter(v string) string {
+	var b strings.Builder
+	for i := 0; i < len(v); i++ {
+		switch c := v[i]; c {
+		case '\\', '*', '(', ')', 0:
+			fmt.Fprintf(&b, "\\%02x", c)
+		default:
+			b.WriteByte(c)
+		}
+	}
+	return b.String()
+}
+
+// rdnValue returns the value of the first RDN of a DN, i.e. Admins of
+// CN=Admins,OU=Groups,DC=example,DC=com
+func rdnValue(dn string) string {
+	var b strings.Builder
+	value := false
+	for i := 0; i < len(dn); i++ {
+		c := dn[i]
+		switch {
+		case c == '\\' && i+1 < len(dn):
+			if i+2 < len(dn) {
+				if h, err := hex.DecodeString(dn[i+1 : i+3]); err == nil {
+					if value {
+						b.WriteByte(h[0])
+					}
+					i += 2
+					continue
+				}
+			}
+			i++
+			if value {
+				b.WriteByte(dn[i])
+			}
+		case c == ',' || c == '+':
+			return strings.TrimSpace(b.String())
+		case c == '=' && !value:
+			value = true
+		case value:
+			b.WriteByte(c)
+		}
+	}
+	if !value {
+		return dn
+	}
+	return strings.TrimSpace(b.String())
+}
@@ -0,0 +1,166 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/hex"
+	"strings"
+	"testing"
+)
+
+func TestCompileFilter(t *testing.T) {
+	tests := []struct {
+		filter string
+		want   string
+	}{
+		{"(uid=jdoe)", "a30b0403756964" + "04046a646f65"},
+		{" (objectClass=*) ", "870b" + hex.EncodeToString([]byte("objectClass"))},
+		{"(&(uid=a)(!(cn=b)))", "a015" + "a3080403756964040161" + "a209" + "a3070402636e040162"},
+		{"(cn=J*n)", "a40c" + "0402636e" + "3006" + "80014a" + "82016e"},
+		{"(cn=*a*)", "a409" + "0402636e" + "3003" + "810161"},
+		{"(cn=a\\2ab)", "a309" + "0402636e" + "0403612a62"},
+		{"(age>=21)", "a509" + "0403616765" + "04023231"},
+		{"(age<=21)", "a609" + "0403616765" + "04023231"},
+		{"(cn~=jon)", "a809" + "0402636e" + "04036a6f6e"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.filter, func(t *testing.T) {
+			got, err := compileFilter(tt.filter)
+			if err != nil {
+				t.Fatalf("compileFilter() error = %v", err)
+			}
+			if hex.EncodeToString(got) != tt.want {
+				t.Errorf("compileFilter() = %x, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCompileFilterInvalid(t *testing.T) {
+	for _, filter := range []string{
+		"", "uid=x", "(uid=x", "(uid)", "(=x)", "(a=b))", "(&)", "(!(a=b)(c=d))",
+		"(&(a=b)", "(cn:dn:=x)", "(cn=\\zz)", "(cn=\\2)", "(cn=a*\\2)",
+	} {
+		if _, err := compileFilter(filter); err == nil {
+			t.Errorf("compileFilter(%q) expected error", filter)
+		}
+	}
+}
+
+// equalityValue decodes attribute and value of an equalityMatch filter
+func equalityValue(t *testing.T, e berElement) (string, string) {
+	t.Helper()
+	if e.tag != 0xa3 {
+		t.Fatalf("filter tag = %x, want equalityMatch", e.tag)
+	}
+	parts, err := berElements(e.data)
+	if err != nil || len(parts) != 2 {
+		t.Fatalf("malformed equalityMatch %x: %v", e.data, err)
+	}
+	return string(parts[0].data), string(parts[1].data)
+}
+
+func TestEscapeFilter(t *testing.T) {
+	tests := []struct {
+		value string
+		want  string
+	}{
+		{"jdoe", "jdoe"},
+		{"*)(uid=*", "\\2a\\29\\28uid=\\2a"},
+		{`a\b`, `a\5cb`},
+		{"x\x00y", "x\\00y"},
+		{"Zoë", "Zoë"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.value, func(t *testing.T) {
+			if got := EscapeFilter(tt.value); got != tt.want {
+				t.Errorf("EscapeFilter() = %v, want %v", got, tt.want)
+			}
+			b, err := compileFilter("(uid=" + EscapeFilter(tt.value) + ")")
+			if err != nil {
+				t.Fatalf("compileFilter() error = %v", err)
+			}
+			elements, err := berElements(b)
+			if err != nil || len(elements) != 1 {
+				t.Fatalf("berElements() = %v, error = %v", elements, err)
+			}
+			if attr, value := equalityValue(t, elements[0]); attr != "uid" || value != tt.value {
+				t.Errorf("compiled filter = %s=%q, want uid=%q", attr, value, tt.value)
+			}
+		})
+	}
+}
+
+func TestFilterInjection(t *testing.T) {
+	const template = "(&(objectClass=person)(uid={user}))"
+	for _, user := range []string{"*", "*)(uid=*", "admin)(|(uid=*", "x)(objectClass=*))(&(uid=x"} {
+		t.Run(user, func(t *testing.T) {
+			b, err := compileFilter(strings.Replace(template, "{user}", EscapeFilter(user), -1))
+			if err != nil {
+				t.Fatalf("compileFilter() error = %v", err)
+			}
+			and, err := berElements(b)
+			if err != nil || len(and) != 1 || and[0].tag != 0xa0 {
+				t.Fatalf("filter %x isn't a single and", b)
+			}
+			items, err := berElements(and[0].data)
+			if err != nil || len(items) != 2 {
+				t.Fatalf("and filter has %d items, error = %v", len(items), err)
+			}
+			if attr, value := equalityValue(t, items[1]); attr != "uid" || value != user {
+				t.Errorf("user filter = %s=%q, want uid=%q", attr, value, user)
+			}
+		})
+	}
+}
+
+func TestBERElements(t *testing.T) {
+	for _, n := range []int{0, 1, 0x7f, 0x80, 0xff, 0x100, 0x10000} {
+		data := bytes.Repeat([]byte{'a'}, n)
+		b := berJoin(berTLV(tagOctetString, data), berInteger(0x02, -129))
+		elements, err := berElements(b)
+		if err != nil || len(elements) != 2 {
+			t.Fatalf("berElements() of %d bytes = %d elements, error = %v", n, len(elements), err)
+		}
+		if !bytes.Equal(elements[0].data, data) || berInt(elements[1].data) != -129 {
+			t.Errorf("berElements() of %d bytes decoded %d bytes and %d", n, len(elements[0].data), berInt(elements[1].data))
+		}
+
+		e, err := readElement(bufio.NewReader(bytes.NewReader(b)))
+		if err != nil || !bytes.Equal(e.data, data) {
+			t.Errorf("readElement() of %d bytes = %d bytes, error = %v", n, len(e.data), err)
+		}
+	}
+}
+
+func TestBERElementsTruncated(t *testing.T) {
+	tests := []struct {
+		name string
+		b    []byte
+	}{
+		{"tag only", []byte{0x30}},
+		{"short content", []byte{0x04, 0x05, 'a'}},
+		{"missing length bytes", []byte{0x04, 0x81}},
+		{"short length bytes", []byte{0x04, 0x82, 0x01}},
+		{"indefinite length", []byte{0x30, 0x80, 0x00, 0x00}},
+		{"too many length bytes", []byte{0x04, 0x85, 0, 0, 0, 0, 1, 'a'}},
+		{"short long form content", []byte{0x04, 0x82, 0x00, 0x05, 'a'}},
+		{"second element", []byte{0x04, 0x01, 'a', 0x04}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if elements, err := berElements(tt.b); err == nil {
+				t.Errorf("berElements() = %v, expected error", elements)
+			}
+			if tt.name == "second element" {
+				return
+			}
+			if e, err := readElement(bufio.NewReader(bytes.NewReader(tt.b))); err == nil {
+				t.Errorf("readElement() = %v, expected error", e)
+			}
+		})
+	}
+	if _, err := parseEntry([]byte{0x04, 0x02, 'd', 'n', 0x30, 0x03, 0x30, 0x01}); err == nil {
+		t.Error("parseEntry() expected error for truncated attributes")
+	}
+}
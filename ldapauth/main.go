@@ -0,0 +1,255 @@
+package main
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"strings"
+
+	httputils "github.com/cascades-fbp/cascades-http/utils"
+	"github.com/cascades-fbp/cascades/components/utils"
+	"github.com/cascades-fbp/cascades/runtime"
+	zmq "github.com/pebbe/zmq4"
+)
+
+var (
+	// Flags
+	optionsEndpoint   = flag.String("port.options", "", "Component's options port endpoint")
+	inputEndpoint     = flag.String("port.in", "", "Component's input port endpoint")
+	outputEndpoint    = flag.String("port.out", "", "Component's output port endpoint")
+	rejectEndpoint    = flag.String("port.reject", "", "Component's reject port endpoint")
+	errorEndpoint     = flag.String("port.err", "", "Component's error port endpoint")
+	logEndpoint       = flag.String("port.log", "", "Component's log port endpoint")
+	heartbeatEndpoint = flag.String("port.heartbeat", "", "Component's heartbeat port endpoint")
+	metricsAddr       = flag.String("metrics", "", "Address to expose metrics on, i.e. 127.0.0.1:9090 (disabled if empty)")
+	jsonFlag          = flag.Bool("json", false, "Print component documentation in JSON")
+	debug             = flag.Bool("debug", false, "Enable debug mode")
+
+	// Internal
+	optionsPort, inPort, outPort, rejectPort, errPort, logPort, heartbeatPort *zmq.Socket
+	err                                                                       error
+	logger                                                                    = httputils.NewLogger("http/ldapauth")
+	liveness                                                                  = httputils.NewLiveness("http/ldapauth")
+	metrics                                                                   = httputils.NewMetrics(logger, liveness)
+	shutdown                                                                  *httputils.Shutdown
+)
+
+// validateArgs checks all required flags
+func validateArgs() {
+	if *optionsEndpoint == "" {
+		flag.Usage()
+		os.Exit(1)
+	}
+	if *inputEndpoint == "" {
+		flag.Usage()
+		os.Exit(1)
+	}
+	if *outputEndpoint == "" {
+		flag.Usage()
+		os.Exit(1)
+	}
+	if *rejectEndpoint == "" {
+		flag.Usage()
+		os.Exit(1)
+	}
+}
+
+// openPorts create ZMQ sockets
+func openPorts() {
+	optionsPort, err = utils.CreateInputPort("http/ldapauth.options", *optionsEndpoint, nil)
+	utils.AssertError(err)
+
+	inPort, err = utils.CreateInputPort("http/ldapauth.in", *inputEndpoint, nil)
+	utils.AssertError(err)
+
+	outPort, err = utils.CreateOutputPort("http/ldapauth.out", *outputEndpoint, nil)
+	utils.AssertError(err)
+
+	rejectPort, err = utils.CreateOutputPort("http/ldapauth.reject", *rejectEndpoint, nil)
+	utils.AssertError(err)
+
+	if *errorEndpoint != "" {
+		errPort, err = utils.CreateOutputPort("http/ldapauth.err", *errorEndpoint, nil)
+		utils.AssertError(err)
+	}
+
+	if *logEndpoint != "" {
+		logPort, err = utils.CreateOutputPort("http/ldapauth.log", *logEndpoint, nil)
+		utils.AssertError(err)
+		logger.SetSink(func(ip [][]byte) { logPort.SendMessageDontwait(ip) })
+	}
+
+	if *heartbeatEndpoint != "" {
+		heartbeatPort, err = utils.CreateOutputPort("http/ldapauth.heartbeat", *heartbeatEndpoint, nil)
+		utils.AssertError(err)
+		liveness.Start(func(ip [][]byte) { heartbeatPort.SendMessageDontwait(ip) })
+	}
+}
+
+// closePorts closes all active ports and terminates ZMQ context
+func closePorts() {
+	shutdown.Close(optionsPort, inPort)
+	liveness.Stop()
+	shutdown.Flush(outPort, rejectPort, errPort, heartbeatPort, logPort)
+	zmq.Term()
+}
+
+func main() {
+	flag.Parse()
+
+	if *jsonFlag {
+		doc, _ := registryEntry.JSON()
+		fmt.Println(string(doc))
+		os.Exit(0)
+	}
+
+	log.SetFlags(0)
+	if *debug {
+		logger.SetLevel(httputils.LevelDebug)
+		logger.SetOutput(os.Stdout)
+	}
+	log.SetOutput(logger.Writer())
+
+	validateArgs()
+
+	shutdown = httputils.NewShutdown(logger)
+	openPorts()
+
+	if *metricsAddr != "" {
+		err = metrics.Serve(*metricsAddr)
+		utils.AssertError(err)
+	}
+
+	err = runtime.SetupShutdownByDisconnect(inPort, "http/ldapauth.in", shutdown.Signals())
+	utils.AssertError(err)
+
+	// Wait for the configuration on the options port
+	var auth *Authenticator
+	for auth == nil {
+		log.Println("Waiting for configuration...")
+		ip, err := shutdown.Receive(optionsPort)
+		if err == httputils.ErrShutdown {
+			shutdown.Exit(closePorts)
+		}
+		if err != nil {
+			logger.Error("Error receiving IP", "error", err)
+			continue
+		}
+		if !runtime.IsValidIP(ip) || !runtime.IsPacket(ip) {
+			continue
+		}
+		options := &Options{}
+		if err = json.Unmarshal(ip[1], options); err != nil {
+			logger.Error("Failed to unmarshal options", "error", err)
+			continue
+		}
+		if auth, err = NewAuthenticator(options); err != nil {
+			logger.Error("Invalid LDAP configuration", "error", err)
+			continue
+		}
+	}
+	optionsPort.Close()
+	optionsPort = nil
+
+	challenge := fmt.Sprintf(`Basic realm="%s", charset="UTF-8"`, auth.options.Realm)
+
+	// Process incoming messages until shutdown
+	for {
+		ip, err := shutdown.Receive(inPort)
+		if err == httputils.ErrShutdown {
+			break
+		}
+		if err != nil {
+			logger.Error("Error receiving message", "error", err)
+			continue
+		}
+		liveness.Inc()
+		if !httputils.IsValidIP(ip) || !runtime.IsPacket(ip) {
+			logger.Warn("Received invalid IP")
+			continue
+		}
+
+		req, err := httputils.IP2Request(ip)
+		if err != nil {
+			logger.Warn("Failed to convert IP to request", "error", err)
+			sendError(httputils.NewError("http/ldapauth", httputils.ErrInvalidIP, err))
+			continue
+		}
+
+		var id *Identity
+		user, password, ok := parseBasicAuth(req.GetHeader("Authorization"))
+		if ok {
+			id, err = auth.Authenticate(user, password)
+		} else {
+			err = errInvalidCredentials
+		}
+		switch {
+		case errors.Is(err, errInvalidCredentials):
+			logger.Info("Rejected request", "id", req.ID, "user", user, "reason", err)
+			resp := httputils.NewResponse(http.StatusUnauthorized).
+				WithID(req.ID).
+				WithTrace(req.Trace).
+				WithHeader("WWW-Authenticate", challenge).
+				WithText("Authentication required")
+			rejectPort.SendMessage(resp.MustIP())
+			continue
+		case err != nil:
+			logger.Error("Directory lookup failed", "id", req.ID, "user", user, "error", err)
+			category := httputils.ErrNetwork
+			var e *LDAPError
+			if errors.As(err, &e) {
+				category = httputils.ErrUpstream
+			}
+			sendError(httputils.NewError("http/ldapauth", category, err).WithRequest(req.ID))
+			resp := httputils.NewResponse(http.StatusServiceUnavailable).
+				WithID(req.ID).
+				WithTrace(req.Trace).
+				WithText("Authentication service unavailable")
+			rejectPort.SendMessage(resp.MustIP())
+			continue
+		}
+
+		logger.Debug("Authenticated request", "id", req.ID, "user", id.User, "dn", id.DN, "roles", id.Roles)
+		req.User, req.Groups, req.Roles = id.User, id.Groups, id.Roles
+		ip, err = httputils.Request2IP(req)
+		if err != nil {
+			logger.Error("Failed to convert request to IP", "error", err)
+			sendError(httputils.NewError("http/ldapauth", httputils.ErrInternal, err).WithRequest(req.ID))
+			continue
+		}
+		outPort.SendMessage(ip)
+	}
+	auth.Close()
+	shutdown.Exit(closePorts)
+}
+
+// parseBasicAuth returns credentials of Basic Authorization header
+func parseBasicAuth(header string) (user, password string, ok bool) {
+	const prefix = "basic "
+	if len(header) < len(prefix) || strings.ToLower(header[:len(prefix)]) != prefix {
+		return "", "", false
+	}
+	decoded, err := base64.StdEncoding.DecodeString(strings.TrimSpace(header[len(prefix):]))
+	if err != nil {
+		return "", "", false
+	}
+	user, password, ok = strings.Cut(string(decoded), ":")
+	return user, password, ok
+}
+
+// sendError reports a failure to the ERR port if it's connected
+func sendError(e *httputils.Error) {
+	if errPort == nil {
+		return
+	}
+	ip, err := httputils.Error2IP(e)
+	if err != nil {
+		return
+	}
+	errPort.SendMessageDontwait(ip)
+}
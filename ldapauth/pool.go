@@ -0,0 +1,71 @@
+package main
+
+import (
+	"sync"
+	"time"
+)
+
+// idleConn is a pooled connection and when it was returned
+type idleConn struct {
+	conn  *Conn
+	since time.Time
+}
+
+// Pool keeps bound connections for reuse
+type Pool struct {
+	dial        func() (*Conn, error) // Connects and binds a new connection
+	size        int
+	idleTimeout time.Duration
+
+	mu   sync.Mutex
+	idle []idleConn
+}
+
+// NewPool creates a pool keeping up to size idle connections
+func NewPool(dial func() (*Conn, error), size int, idleTimeout time.Duration) *Pool {
+	return &Pool{dial: dial, size: size, idleTimeout: idleTimeout}
+}
+
+// Get returns the most recently used idle connection or dials a new one,
+// connections idle for too long are closed since servers drop them anyway
+func (p *Pool) Get() (*Conn, error) {
+	now := time.Now()
+	p.mu.Lock()
+	for len(p.idle) > 0 {
+		ic := p.idle[len(p.idle)-1]
+		p.idle = p.idle[:len(p.idle)-1]
+		if now.Sub(ic.since) < p.idleTimeout {
+			p.mu.Unlock()
+			return ic.conn, nil
+		}
+		ic.conn.Close()
+	}
+	p.mu.Unlock()
+	return p.dial()
+}
+
+// Dial returns a new connection bypassing idle ones
+func (p *Pool) Dial() (*Conn, error) {
+	return p.dial()
+}
+
+// Put returns a healthy connection to the pool, it's closed if the pool is full
+func (p *Pool) Put(c *Conn) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if len(p.idle) >= p.size {
+		c.Close()
+		return
+	}
+	p.idle = append(p.idle, idleConn{conn: c, since: time.Now()})
+}
+
+// Close closes all idle connections
+func (p *Pool) Close() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	for _, ic := range p.idle {
+		ic.conn.Close()
+	}
+	p.idle = nil
+}
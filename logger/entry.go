@@ -0,0 +1,58 @@
+package main
+
+import (
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+
+	httputils "github.com/cascades-fbp/cascades-http/utils"
+)
+
+// Entry is a structured log line
+type Entry struct {
+	Time         string  `json:"time"`
+	ID           string  `json:"id"`
+	Method       string  `json:"method"`
+	URI          string  `json:"uri"`
+	Route        string  `json:"route"`
+	Status       int     `json:"status"`
+	Latency      float64 `json:"latency_ms"`
+	UserAgent    string  `json:"user_agent,omitempty"`
+	Referer      string  `json:"referer,omitempty"`
+	User         string  `json:"user,omitempty"`
+	RequestSize  int64   `json:"request_size"`
+	ResponseSize int     `json:"response_size"`
+}
+
+// pending is a request waiting for its response
+type pending struct {
+	request *httputils.HTTPRequest
+	arrived time.Time
+}
+
+// NewEntry creates a log entry for a given request/response pair with secrets
+// redacted from URLs
+func NewEntry(p *pending, resp *httputils.HTTPResponse, now time.Time) *Entry {
+	h := http.Header(p.request.Header)
+	e := &Entry{
+		Time:         p.arrived.UTC().Format(time.RFC3339Nano),
+		ID:           p.request.ID,
+		Method:       p.request.Method,
+		URI:          httputils.Redaction.URL(p.request.URI),
+		Route:        p.request.URI,
+		Status:       resp.StatusCode,
+		Latency:      float64(now.Sub(p.arrived)) / float64(time.Millisecond),
+		UserAgent:    h.Get("User-Agent"),
+		Referer:      httputils.Redaction.URL(h.Get("Referer")),
+		User:         p.request.User,
+		ResponseSize: len(resp.Body),
+	}
+	if u, err := url.ParseRequestURI(p.request.URI); err == nil {
+		e.Route = u.Path
+	}
+	if size, err := strconv.ParseInt(h.Get("Content-Length"), 10, 64); err == nil {
+		e.RequestSize = size
+	}
+	return e
+}
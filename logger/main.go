@@ -0,0 +1,188 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"time"
+
+	httputils "github.com/cascades-fbp/cascades-http/utils"
+	"github.com/cascades-fbp/cascades/components/utils"
+	"github.com/cascades-fbp/cascades/runtime"
+	zmq "github.com/pebbe/zmq4"
+)
+
+const (
+	// Requests without response are forgotten after this interval
+	pendingTTL = 5 * time.Minute
+)
+
+var (
+	// Flags
+	requestEndpoint   = flag.String("port.request", "", "Component's request input port endpoint")
+	responseEndpoint  = flag.String("port.response", "", "Component's response input port endpoint")
+	outputEndpoint    = flag.String("port.out", "", "Component's output port endpoint")
+	logEndpoint       = flag.String("port.log", "", "Component's log port endpoint")
+	heartbeatEndpoint = flag.String("port.heartbeat", "", "Component's heartbeat port endpoint")
+	metricsAddr       = flag.String("metrics", "", "Address to expose metrics on, i.e. 127.0.0.1:9090 (disabled if empty)")
+	jsonFlag          = flag.Bool("json", false, "Print component documentation in JSON")
+	debug             = flag.Bool("debug", false, "Enable debug mode")
+
+	// Internal
+	requestPort, responsePort, outPort, logPort, heartbeatPort *zmq.Socket
+	err                                                        error
+	logger                                                     = httputils.NewLogger("http/logger")
+	liveness                                                   = httputils.NewLiveness("http/logger")
+	metrics                                                    = httputils.NewMetrics(logger, liveness)
+	shutdown                                                   *httputils.Shutdown
+)
+
+// validateArgs checks all required flags
+func validateArgs() {
+	if *requestEndpoint == "" {
+		flag.Usage()
+		os.Exit(1)
+	}
+	if *responseEndpoint == "" {
+		flag.Usage()
+		os.Exit(1)
+	}
+	if *outputEndpoint == "" {
+		flag.Usage()
+		os.Exit(1)
+	}
+}
+
+// openPorts create ZMQ sockets
+func openPorts() {
+	requestPort, err = utils.CreateInputPort("http/logger.request", *requestEndpoint, nil)
+	utils.AssertError(err)
+
+	responsePort, err = utils.CreateInputPort("http/logger.response", *responseEndpoint, nil)
+	utils.AssertError(err)
+
+	outPort, err = utils.CreateOutputPort("http/logger.out", *outputEndpoint, nil)
+	utils.AssertError(err)
+
+	if *logEndpoint != "" {
+		logPort, err = utils.CreateOutputPort("http/logger.log", *logEndpoint, nil)
+		utils.AssertError(err)
+		logger.SetSink(func(ip [][]byte) { logPort.SendMessageDontwait(ip) })
+	}
+
+	if *heartbeatEndpoint != "" {
+		heartbeatPort, err = utils.CreateOutputPort("http/logger.heartbeat", *heartbeatEndpoint, nil)
+		utils.AssertError(err)
+		liveness.Start(func(ip [][]byte) { heartbeatPort.SendMessageDontwait(ip) })
+	}
+}
+
+// closePorts closes all active ports and terminates ZMQ context
+func closePorts() {
+	shutdown.Close(requestPort, responsePort)
+	liveness.Stop()
+	shutdown.Flush(outPort, heartbeatPort, logPort)
+	zmq.Term()
+}
+
+func main() {
+	flag.Parse()
+
+	if *jsonFlag {
+		doc, _ := registryEntry.JSON()
+		fmt.Println(string(doc))
+		os.Exit(0)
+	}
+
+	log.SetFlags(0)
+	if *debug {
+		logger.SetLevel(httputils.LevelDebug)
+		logger.SetOutput(os.Stdout)
+	}
+	log.SetOutput(logger.Writer())
+
+	validateArgs()
+
+	shutdown = httputils.NewShutdown(logger)
+	openPorts()
+
+	if *metricsAddr != "" {
+		err = metrics.Serve(*metricsAddr)
+		utils.AssertError(err)
+	}
+
+	err = runtime.SetupShutdownByDisconnect(responsePort, "http/logger.response", shutdown.Signals())
+	utils.AssertError(err)
+
+	poller := zmq.NewPoller()
+	poller.Add(requestPort, zmq.POLLIN)
+	poller.Add(responsePort, zmq.POLLIN)
+
+	requests := make(map[string]*pending)
+	lastCleanup := time.Now()
+
+	// Main loop
+	for !shutdown.Stopping() {
+		sockets, err := poller.Poll(time.Second)
+		if err != nil {
+			logger.Error("Error polling ports", "error", err)
+			continue
+		}
+
+		now := time.Now()
+		if now.Sub(lastCleanup) > time.Second {
+			for id, p := range requests {
+				if now.Sub(p.arrived) > pendingTTL {
+					logger.Warn("No response received for request", "id", id)
+					delete(requests, id)
+				}
+			}
+			lastCleanup = now
+		}
+
+		for _, socket := range sockets {
+			ip, err := socket.Socket.RecvMessageBytes(0)
+			if err != nil {
+				logger.Error("Error receiving message", "error", err)
+				continue
+			}
+			liveness.Inc()
+			if !httputils.IsValidIP(ip) || !runtime.IsPacket(ip) {
+				continue
+			}
+
+			switch socket.Socket {
+			case requestPort:
+				req, err := httputils.IP2Request(ip)
+				if err != nil {
+					logger.Warn("Failed to convert IP to request", "error", err)
+					continue
+				}
+				requests[req.ID] = &pending{request: req, arrived: now}
+
+			case responsePort:
+				resp, err := httputils.IP2Response(ip)
+				if err != nil {
+					logger.Warn("Failed to convert IP to response", "error", err)
+					continue
+				}
+				p, ok := requests[resp.ID]
+				if !ok {
+					logger.Warn("Didn't find request for a given ID", "id", resp.ID)
+					continue
+				}
+				delete(requests, resp.ID)
+
+				data, err := json.Marshal(NewEntry(p, resp, now))
+				if err != nil {
+					logger.Error("Failed to marshal log entry", "error", err)
+					continue
+				}
+				outPort.SendMessage(runtime.NewPacket(data))
+			}
+		}
+	}
+	shutdown.Exit(closePorts)
+}
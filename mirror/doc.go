@@ -0,0 +1,50 @@
+package main
+
+import "github.com/cascades-fbp/cascades/library"
+
+var registryEntry = &library.Entry{
+	Description: `Duplicates incoming request IPs to a SHADOW output port while the original IP proceeds to OUT.
+Shadow copies are sent fire-and-forget: they are dropped when the shadow consumer is not ready or when
+the configured rate cap is exceeded, so the primary flow is never slowed down.`,
+	Elementary: true,
+	Inports: []library.EntryPort{
+		library.EntryPort{
+			Name:        "OPTIONS",
+			Type:        "string",
+			Description: "Optional configuration port with maximum number of shadow copies per second (0 means unlimited)",
+			Required:    false,
+		},
+		library.EntryPort{
+			Name:        "IN",
+			Type:        "json",
+			Description: "Input port for receiving requests in predefined JSON format",
+			Required:    true,
+		},
+	},
+	Outports: []library.EntryPort{
+		library.EntryPort{
+			Name:        "OUT",
+			Type:        "json",
+			Description: "Output port for the primary copy of requests",
+			Required:    true,
+		},
+		library.EntryPort{
+			Name:        "SHADOW",
+			Type:        "json",
+			Description: "Output port for mirrored copies of requests",
+			Required:    true,
+		},
+		library.EntryPort{
+			Name:        "LOG",
+			Type:        "json",
+			Description: "Optional output port for structured log entries (log entry JSON object defined in utils of HTTP components library)",
+			Required:    false,
+		},
+		library.EntryPort{
+			Name:        "HEARTBEAT",
+			Type:        "json",
+			Description: "Optional output port for periodic liveness IPs (heartbeat JSON object defined in utils of HTTP components library)",
+			Required:    false,
+		},
+	},
+}
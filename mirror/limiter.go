@@ -0,0 +1,43 @@
+package main
+
+import (
+	"time"
+)
+
+// Limiter is a token bucket allowing up to rate events per second
+type Limiter struct {
+	rate   float64
+	tokens float64
+	last   time.Time
+}
+
+// NewLimiter returns a limiter for a given rate or nil if rate is unlimited
+func NewLimiter(rate int) *Limiter {
+	if rate <= 0 {
+		return nil
+	}
+	return &Limiter{
+		rate:   float64(rate),
+		tokens: float64(rate),
+		last:   time.Now(),
+	}
+}
+
+// Allow reports whether an event may happen now and consumes a token if so.
+// A nil limiter allows everything.
+func (l *Limiter) Allow() bool {
+	if l == nil {
+		return true
+	}
+	now := time.Now()
+	l.tokens += now.Sub(l.last).Seconds() * l.rate
+	if l.tokens > l.rate {
+		l.tokens = l.rate
+	}
+	l.last = now
+	if l.tokens < 1 {
+		return false
+	}
+	l.tokens--
+	return true
+}
@@ -0,0 +1,129 @@
+package main
+
+import (
+	"crypto/tls"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"regexp"
+	"time"
+)
+
+// Options describe the configuration IP of the component
+type Options struct {
+	Interval string   `json:"interval"` // Probing interval, i.e. 30s
+	Timeout  string   `json:"timeout"`  // Timeout of a single probe, i.e. 5s
+	Checks   []*Check `json:"checks"`
+}
+
+// Check describes a single monitored URL
+type Check struct {
+	Name    string `json:"name"`
+	URL     string `json:"url"`
+	Method  string `json:"method"`   // GET by default
+	Status  int    `json:"status"`   // Expected status code, any 2xx by default
+	Match   string `json:"match"`    // Regular expression the body has to match
+	TLSDays int    `json:"tls_days"` // Minimal days before certificate expiration
+
+	match *regexp.Regexp
+}
+
+// Result of a single probe
+type Result struct {
+	Name       string  `json:"name"`
+	URL        string  `json:"url"`
+	Time       string  `json:"time"`
+	Up         bool    `json:"up"`
+	StatusCode int     `json:"status,omitempty"`
+	Latency    float64 `json:"latency_ms"`
+	TLSDays    *int    `json:"tls_days_left,omitempty"`
+	Reason     string  `json:"reason,omitempty"`
+}
+
+// Transition describes a change of the check state
+type Transition struct {
+	Name   string `json:"name"`
+	URL    string `json:"url"`
+	Time   string `json:"time"`
+	State  string `json:"state"` // UP or DOWN
+	Reason string `json:"reason,omitempty"`
+}
+
+// Prepare validates the check and fills defaults
+func (c *Check) Prepare() (err error) {
+	if c.URL == "" {
+		return fmt.Errorf("check %q has no URL", c.Name)
+	}
+	if c.Name == "" {
+		c.Name = c.URL
+	}
+	if c.Method == "" {
+		c.Method = "GET"
+	}
+	if c.Match != "" {
+		c.match, err = regexp.Compile(c.Match)
+	}
+	return err
+}
+
+// Probe performs the check with a given client
+func (c *Check) Probe(client *http.Client) *Result {
+	start := time.Now()
+	res := &Result{
+		Name: c.Name,
+		URL:  c.URL,
+		Time: start.UTC().Format(time.RFC3339),
+	}
+
+	req, err := http.NewRequest(c.Method, c.URL, nil)
+	if err != nil {
+		res.Reason = err.Error()
+		return res
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		res.Latency = msSince(start)
+		res.Reason = err.Error()
+		return res
+	}
+	defer resp.Body.Close()
+	body, err := ioutil.ReadAll(resp.Body)
+	res.Latency = msSince(start)
+	res.StatusCode = resp.StatusCode
+	if err != nil {
+		res.Reason = err.Error()
+		return res
+	}
+
+	res.Reason = c.verify(resp.StatusCode, body, resp.TLS)
+	if resp.TLS != nil && len(resp.TLS.PeerCertificates) > 0 {
+		days := int(resp.TLS.PeerCertificates[0].NotAfter.Sub(time.Now()).Hours() / 24)
+		res.TLSDays = &days
+	}
+	res.Up = res.Reason == ""
+	return res
+}
+
+// verify returns the reason why the check failed or empty string
+func (c *Check) verify(status int, body []byte, state *tls.ConnectionState) string {
+	if c.Status != 0 && status != c.Status {
+		return fmt.Sprintf("unexpected status %d (expected %d)", status, c.Status)
+	}
+	if c.Status == 0 && (status < 200 || status > 299) {
+		return fmt.Sprintf("unexpected status %d", status)
+	}
+	if c.match != nil && !c.match.Match(body) {
+		return "body doesn't match " + c.Match
+	}
+	if c.TLSDays > 0 && state != nil && len(state.PeerCertificates) > 0 {
+		left := state.PeerCertificates[0].NotAfter.Sub(time.Now())
+		if left < time.Duration(c.TLSDays)*24*time.Hour {
+			return fmt.Sprintf("certificate expires in %v", left.Truncate(time.Hour))
+		}
+	}
+	return ""
+}
+
+func msSince(t time.Time) float64 {
+	return float64(time.Since(t)) / float64(time.Millisecond)
+}
@@ -0,0 +1,45 @@
+package main
+
+import "github.com/cascades-fbp/cascades/library"
+
+var registryEntry = &library.Entry{
+	Description: `Periodically probes a list of URLs and emits UP/DOWN transitions and probe statistics.
+Each check can verify expected status code, match the body against a regular expression and require
+a minimal number of days before the TLS certificate expires.`,
+	Elementary: true,
+	Inports: []library.EntryPort{
+		library.EntryPort{
+			Name: "OPTIONS",
+			Type: "json",
+			Description: `Configuration port, i.e. {"interval": "30s", "timeout": "5s", "checks": [{"name": "api",
+"url": "https://api.example.com/health", "method": "GET", "status": 200, "match": "ok", "tls_days": 14}]}`,
+			Required: true,
+		},
+	},
+	Outports: []library.EntryPort{
+		library.EntryPort{
+			Name:        "STATUS",
+			Type:        "json",
+			Description: "Output port for UP/DOWN state transitions",
+			Required:    false,
+		},
+		library.EntryPort{
+			Name:        "STATS",
+			Type:        "json",
+			Description: "Output port for results of every probe (latency, status)",
+			Required:    false,
+		},
+		library.EntryPort{
+			Name:        "LOG",
+			Type:        "json",
+			Description: "Optional output port for structured log entries (log entry JSON object defined in utils of HTTP components library)",
+			Required:    false,
+		},
+		library.EntryPort{
+			Name:        "HEARTBEAT",
+			Type:        "json",
+			Description: "Optional output port for periodic liveness IPs (heartbeat JSON object defined in utils of HTTP components library)",
+			Required:    false,
+		},
+	},
+}
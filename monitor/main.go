@@ -0,0 +1,214 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"time"
+
+	httputils "github.com/cascades-fbp/cascades-http/utils"
+	"github.com/cascades-fbp/cascades/components/utils"
+	"github.com/cascades-fbp/cascades/runtime"
+	zmq "github.com/pebbe/zmq4"
+)
+
+var (
+	// Flags
+	optionsEndpoint   = flag.String("port.options", "", "Component's options port endpoint")
+	statusEndpoint    = flag.String("port.status", "", "Component's status output port endpoint")
+	statsEndpoint     = flag.String("port.stats", "", "Component's stats output port endpoint")
+	logEndpoint       = flag.String("port.log", "", "Component's log port endpoint")
+	heartbeatEndpoint = flag.String("port.heartbeat", "", "Component's heartbeat port endpoint")
+	metricsAddr       = flag.String("metrics", "", "Address to expose metrics on, i.e. 127.0.0.1:9090 (disabled if empty)")
+	jsonFlag          = flag.Bool("json", false, "Print component documentation in JSON")
+	debug             = flag.Bool("debug", false, "Enable debug mode")
+
+	// Internal
+	optionsPort, statusPort, statsPort, logPort, heartbeatPort *zmq.Socket
+	err                                                        error
+	logger                                                     = httputils.NewLogger("http/monitor")
+	liveness                                                   = httputils.NewLiveness("http/monitor")
+	metrics                                                    = httputils.NewMetrics(logger, liveness)
+	shutdown                                                   *httputils.Shutdown
+)
+
+// validateArgs checks all required flags
+func validateArgs() {
+	if *optionsEndpoint == "" {
+		flag.Usage()
+		os.Exit(1)
+	}
+	if *statusEndpoint == "" && *statsEndpoint == "" {
+		flag.Usage()
+		os.Exit(1)
+	}
+}
+
+// openPorts create ZMQ sockets
+func openPorts() {
+	optionsPort, err = utils.CreateInputPort("http/monitor.options", *optionsEndpoint, nil)
+	utils.AssertError(err)
+
+	if *statusEndpoint != "" {
+		statusPort, err = utils.CreateOutputPort("http/monitor.status", *statusEndpoint, nil)
+		utils.AssertError(err)
+	}
+	if *statsEndpoint != "" {
+		statsPort, err = utils.CreateOutputPort("http/monitor.stats", *statsEndpoint, nil)
+		utils.AssertError(err)
+	}
+
+	if *logEndpoint != "" {
+		logPort, err = utils.CreateOutputPort("http/monitor.log", *logEndpoint, nil)
+		utils.AssertError(err)
+		logger.SetSink(func(ip [][]byte) { logPort.SendMessageDontwait(ip) })
+	}
+
+	if *heartbeatEndpoint != "" {
+		heartbeatPort, err = utils.CreateOutputPort("http/monitor.heartbeat", *heartbeatEndpoint, nil)
+		utils.AssertError(err)
+		liveness.Start(func(ip [][]byte) { heartbeatPort.SendMessageDontwait(ip) })
+	}
+}
+
+// closePorts closes all active ports and terminates ZMQ context
+func closePorts() {
+	shutdown.Close(optionsPort)
+	liveness.Stop()
+	shutdown.Flush(statusPort, statsPort, heartbeatPort, logPort)
+	zmq.Term()
+}
+
+// parseOptions parses and validates configuration IP
+func parseOptions(data []byte) (*Options, time.Duration, time.Duration, error) {
+	options := &Options{Interval: "30s", Timeout: "10s"}
+	if err := json.Unmarshal(data, options); err != nil {
+		return nil, 0, 0, err
+	}
+	interval, err := time.ParseDuration(options.Interval)
+	if err != nil {
+		return nil, 0, 0, err
+	}
+	timeout, err := time.ParseDuration(options.Timeout)
+	if err != nil {
+		return nil, 0, 0, err
+	}
+	if len(options.Checks) == 0 {
+		return nil, 0, 0, fmt.Errorf("no checks configured")
+	}
+	for _, c := range options.Checks {
+		if err = c.Prepare(); err != nil {
+			return nil, 0, 0, err
+		}
+	}
+	return options, interval, timeout, nil
+}
+
+func main() {
+	flag.Parse()
+
+	if *jsonFlag {
+		doc, _ := registryEntry.JSON()
+		fmt.Println(string(doc))
+		os.Exit(0)
+	}
+
+	log.SetFlags(0)
+	if *debug {
+		logger.SetLevel(httputils.LevelDebug)
+		logger.SetOutput(os.Stdout)
+	}
+	log.SetOutput(logger.Writer())
+
+	validateArgs()
+
+	shutdown = httputils.NewShutdown(logger)
+	openPorts()
+
+	if *metricsAddr != "" {
+		err = metrics.Serve(*metricsAddr)
+		utils.AssertError(err)
+	}
+
+	// Wait for the configuration on the options port
+	var (
+		options           *Options
+		interval, timeout time.Duration
+	)
+	for {
+		log.Println("Waiting for configuration...")
+		ip, err := shutdown.Receive(optionsPort)
+		if err == httputils.ErrShutdown {
+			shutdown.Exit(closePorts)
+		}
+		if err != nil {
+			logger.Error("Error receiving IP", "error", err)
+			continue
+		}
+		if !runtime.IsValidIP(ip) || !runtime.IsPacket(ip) {
+			continue
+		}
+		options, interval, timeout, err = parseOptions(ip[1])
+		if err != nil {
+			logger.Error("Invalid configuration", "error", err)
+			continue
+		}
+		break
+	}
+	optionsPort.Close()
+	optionsPort = nil
+
+	client := &http.Client{Timeout: timeout}
+	resultCh := make(chan *Result)
+
+	// Every check is probed in its own goroutine
+	for _, c := range options.Checks {
+		go func(c *Check) {
+			for {
+				resultCh <- c.Probe(client)
+				time.Sleep(interval)
+			}
+		}(c)
+	}
+
+	// Last known state of every check
+	states := make(map[string]bool)
+
+	for {
+		var res *Result
+		select {
+		case res = <-resultCh:
+		case <-shutdown.Done():
+			shutdown.Exit(closePorts)
+		}
+		liveness.Inc()
+		logger.Info("Probe finished", "name", res.Name, "up", res.Up, "latency_ms", res.Latency, "reason", res.Reason)
+
+		if statsPort != nil {
+			data, _ := json.Marshal(res)
+			statsPort.SendMessage(runtime.NewPacket(data))
+		}
+
+		prev, known := states[res.Name]
+		states[res.Name] = res.Up
+		if known && prev == res.Up || statusPort == nil {
+			continue
+		}
+
+		t := &Transition{
+			Name:   res.Name,
+			URL:    res.URL,
+			Time:   res.Time,
+			State:  "DOWN",
+			Reason: res.Reason,
+		}
+		if res.Up {
+			t.State = "UP"
+		}
+		data, _ := json.Marshal(t)
+		statusPort.SendMessage(runtime.NewPacket(data))
+	}
+}
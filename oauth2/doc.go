@@ -0,0 +1,76 @@
+package main
+
+import "github.com/cascades-fbp/cascades/library"
+
+var registryEntry = &library.Entry{
+	Description: `Runs OAuth2 authorization code grant with PKCE for CLI/desktop graphs. The authorization URL is
+emitted to URL port (and logged) to be opened in a browser. The browser is redirected to redirect_uri served by
+the server component whose requests arrive on REQUEST port and are answered on RESPONSE port. The code is
+exchanged for tokens which are emitted on TOKEN, ACCESS and REFRESH ports and refreshed before they expire.
+With a refresh_token in options the authorization is skipped, a new one is started when refreshing is rejected.`,
+	Elementary: true,
+	Inports: []library.EntryPort{
+		library.EntryPort{
+			Name:        "OPTIONS",
+			Type:        "json",
+			Description: `Configuration port, i.e. {"client_id": "cli", "auth_url": "https://example.com/oauth/authorize", "token_url": "https://example.com/oauth/token", "redirect_uri": "http://127.0.0.1:8080/callback", "scopes": ["read"]}`,
+			Required:    true,
+		},
+		library.EntryPort{
+			Name:        "REQUEST",
+			Type:        "json",
+			Description: "Input port for callback requests from the server component",
+			Required:    true,
+		},
+	},
+	Outports: []library.EntryPort{
+		library.EntryPort{
+			Name:        "RESPONSE",
+			Type:        "json",
+			Description: "Output port for responses to callback requests",
+			Required:    true,
+		},
+		library.EntryPort{
+			Name:        "URL",
+			Type:        "string",
+			Description: "Optional output port for authorization URLs to open in a browser",
+			Required:    false,
+		},
+		library.EntryPort{
+			Name:        "TOKEN",
+			Type:        "json",
+			Description: `Optional output port for issued tokens, i.e. {"access_token": "...", "token_type": "Bearer", "refresh_token": "...", "expires_at": "2024-01-01T12:00:00Z"}`,
+			Required:    false,
+		},
+		library.EntryPort{
+			Name:        "ACCESS",
+			Type:        "string",
+			Description: "Optional output port for access tokens",
+			Required:    false,
+		},
+		library.EntryPort{
+			Name:        "REFRESH",
+			Type:        "string",
+			Description: "Optional output port for refresh tokens",
+			Required:    false,
+		},
+		library.EntryPort{
+			Name:        "ERR",
+			Type:        "json",
+			Description: "Optional error port for failed authorizations and refreshes (error JSON object defined in utils of HTTP components library)",
+			Required:    false,
+		},
+		library.EntryPort{
+			Name:        "LOG",
+			Type:        "json",
+			Description: "Optional output port for structured log entries (log entry JSON object defined in utils of HTTP components library)",
+			Required:    false,
+		},
+		library.EntryPort{
+			Name:        "HEARTBEAT",
+			Type:        "json",
+			Description: "Optional output port for periodic liveness IPs (heartbeat JSON object defined in utils of HTTP components library)",
+			Required:    false,
+		},
+	},
+}
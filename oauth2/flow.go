@@ -0,0 +1,257 @@
+package main
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	httputils "github.com/cascades-fbp/cascades-http/utils"
+)
+
+// Options describe the configuration IP of the component
+type Options struct {
+	ClientID      string             `json:"client_id"`
+	ClientSecret  string             `json:"client_secret"`  // Empty for public clients
+	AuthURL       string             `json:"auth_url"`       // Authorization endpoint
+	TokenURL      string             `json:"token_url"`      // Token endpoint
+	RedirectURI   string             `json:"redirect_uri"`   // Callback served by the server component, i.e. http://127.0.0.1:8080/callback
+	Scopes        []string           `json:"scopes"`         // Requested scopes
+	Params        map[string]string  `json:"params"`         // Extra parameters of the authorization URL, i.e. access_type
+	AuthStyle     string             `json:"auth_style"`     // header (Basic auth, default) or params to send client credentials
+	RefreshToken  string             `json:"refresh_token"`  // Skip the authorization if a refresh token is known
+	RefreshBefore httputils.Duration `json:"refresh_before"` // Refresh access tokens this long before expiration
+	Timeout       httputils.Duration `json:"timeout"`        // Timeout of token requests
+}
+
+// Token is emitted to TOKEN port after authorization and every refresh
+type Token struct {
+	AccessToken  string `json:"access_token"`
+	TokenType    string `json:"token_type"`
+	RefreshToken string `json:"refresh_token,omitempty"`
+	Scope        string `json:"scope,omitempty"`
+	ExpiresIn    int64  `json:"expires_in,omitempty"`
+	ExpiresAt    string `json:"expires_at,omitempty"` // RFC 3339 time computed from expires_in
+	IDToken      string `json:"id_token,omitempty"`
+
+	expires time.Time
+}
+
+// TokenError is an error response of the token endpoint (RFC 6749, 5.2)
+type TokenError struct {
+	Code        string `json:"error"`
+	Description string `json:"error_description"`
+	StatusCode  int    `json:"-"`
+}
+
+func (e *TokenError) Error() string {
+	if e.Description != "" {
+		return fmt.Sprintf("token endpoint returned %s: %s", e.Code, e.Description)
+	}
+	return fmt.Sprintf("token endpoint returned %s (status %d)", e.Code, e.StatusCode)
+}
+
+// Flow is the state of the authorization code grant with PKCE (RFC 7636)
+type Flow struct {
+	options  *Options
+	client   *http.Client
+	callback string // Path of redirect URI
+
+	state    string // Pending authorization, empty once the code was exchanged
+	verifier string
+	token    *Token
+}
+
+// NewFlow validates options and creates a flow
+func NewFlow(options *Options) (*Flow, error) {
+	if options.ClientID == "" {
+		return nil, fmt.Errorf("client_id is required")
+	}
+	if options.AuthURL == "" || options.TokenURL == "" {
+		return nil, fmt.Errorf("auth_url and token_url are required")
+	}
+	u, err := url.Parse(options.RedirectURI)
+	if err != nil || u.Host == "" {
+		return nil, fmt.Errorf("invalid redirect_uri %q", options.RedirectURI)
+	}
+	if options.AuthStyle != "" && options.AuthStyle != "header" && options.AuthStyle != "params" {
+		return nil, fmt.Errorf("unknown auth_style %q", options.AuthStyle)
+	}
+	callback := u.Path
+	if callback == "" {
+		callback = "/"
+	}
+	timeout := time.Duration(options.Timeout)
+	if timeout <= 0 {
+		timeout = 30 * time.Second
+	}
+	return &Flow{
+		options:  options,
+		client:   &http.Client{Timeout: timeout},
+		callback: callback,
+	}, nil
+}
+
+// Authorize starts a new authorization returning the URL to open in a browser,
+// the current token is no longer refreshed
+func (f *Flow) Authorize() string {
+	f.token = nil
+	f.state = randomString(16)
+	f.verifier = randomString(32)
+	challenge := sha256.Sum256([]byte(f.verifier))
+
+	q := url.Values{}
+	for k, v := range f.options.Params {
+		q.Set(k, v)
+	}
+	q.Set("response_type", "code")
+	q.Set("client_id", f.options.ClientID)
+	q.Set("redirect_uri", f.options.RedirectURI)
+	q.Set("state", f.state)
+	q.Set("code_challenge", base64.RawURLEncoding.EncodeToString(challenge[:]))
+	q.Set("code_challenge_method", "S256")
+	if len(f.options.Scopes) > 0 {
+		q.Set("scope", strings.Join(f.options.Scopes, " "))
+	}
+	sep := "?"
+	if strings.Contains(f.options.AuthURL, "?") {
+		sep = "&"
+	}
+	return f.options.AuthURL + sep + q.Encode()
+}
+
+// IsCallback checks if a request URI targets the redirect URI
+func (f *Flow) IsCallback(uri string) bool {
+	u, err := url.Parse(uri)
+	return err == nil && u.Path == f.callback
+}
+
+// Callback handles redirect of the browser exchanging the code for a token
+func (f *Flow) Callback(query url.Values) (*Token, error) {
+	if f.state == "" {
+		return nil, fmt.Errorf("no authorization is pending")
+	}
+	if query.Get("state") != f.state {
+		return nil, fmt.Errorf("state of the callback doesn't match")
+	}
+	if code := query.Get("error"); code != "" {
+		return nil, &TokenError{Code: code, Description: query.Get("error_description")}
+	}
+	code := query.Get("code")
+	if code == "" {
+		return nil, fmt.Errorf("callback has no code")
+	}
+	token, err := f.request(url.Values{
+		"grant_type":    {"authorization_code"},
+		"code":          {code},
+		"redirect_uri":  {f.options.RedirectURI},
+		"code_verifier": {f.verifier},
+	})
+	if err != nil {
+		return nil, err
+	}
+	f.state, f.verifier = "", ""
+	return token, nil
+}
+
+// Refresh obtains a new access token with the refresh token
+func (f *Flow) Refresh() (*Token, error) {
+	refreshToken := f.options.RefreshToken
+	if f.token != nil && f.token.RefreshToken != "" {
+		refreshToken = f.token.RefreshToken
+	}
+	if refreshToken == "" {
+		return nil, fmt.Errorf("no refresh token")
+	}
+	token, err := f.request(url.Values{
+		"grant_type":    {"refresh_token"},
+		"refresh_token": {refreshToken},
+	})
+	if err != nil {
+		return nil, err
+	}
+	if token.RefreshToken == "" {
+		// Refresh token wasn't rotated
+		token.RefreshToken = refreshToken
+	}
+	return token, nil
+}
+
+// RefreshAt returns when the current token should be refreshed, zero time if
+// it doesn't expire or can't be refreshed
+func (f *Flow) RefreshAt() time.Time {
+	if f.token == nil || f.token.expires.IsZero() || f.token.RefreshToken == "" {
+		return time.Time{}
+	}
+	before := time.Duration(f.options.RefreshBefore)
+	if before <= 0 {
+		before = time.Minute
+	}
+	if lifetime := time.Duration(f.token.ExpiresIn) * time.Second; before > lifetime/2 {
+		before = lifetime / 2
+	}
+	return f.token.expires.Add(-before)
+}
+
+// request calls the token endpoint and stores the issued token
+func (f *Flow) request(form url.Values) (*Token, error) {
+	if f.options.AuthStyle == "params" || f.options.ClientSecret == "" {
+		form.Set("client_id", f.options.ClientID)
+		if f.options.ClientSecret != "" {
+			form.Set("client_secret", f.options.ClientSecret)
+		}
+	}
+	req, err := http.NewRequest("POST", f.options.TokenURL, strings.NewReader(form.Encode()))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Accept", "application/json")
+	if f.options.AuthStyle != "params" && f.options.ClientSecret != "" {
+		req.SetBasicAuth(url.QueryEscape(f.options.ClientID), url.QueryEscape(f.options.ClientSecret))
+	}
+
+	resp, err := f.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		e := &TokenError{StatusCode: resp.StatusCode}
+		if json.Unmarshal(body, e) != nil || e.Code == "" {
+			e.Code = "http_error"
+		}
+		return nil, e
+	}
+
+	token := &Token{}
+	if err = json.Unmarshal(body, token); err != nil {
+		return nil, fmt.Errorf("failed to decode token response: %v", err)
+	}
+	if token.AccessToken == "" {
+		return nil, fmt.Errorf("token response has no access_token")
+	}
+	if token.ExpiresIn > 0 {
+		token.expires = time.Now().Add(time.Duration(token.ExpiresIn) * time.Second)
+		token.ExpiresAt = token.expires.UTC().Format(time.RFC3339)
+	}
+	f.token = token
+	return token, nil
+}
+
+// randomString returns n random bytes encoded for URLs
+func randomString(n int) string {
+	b := make([]byte, n)
+	rand.Read(b)
+	return base64.RawURLEncoding.EncodeToString(b)
+}
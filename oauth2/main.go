@@ -0,0 +1,304 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"time"
+
+	httputils "github.com/cascades-fbp/cascades-http/utils"
+	"github.com/cascades-fbp/cascades/components/utils"
+	"github.com/cascades-fbp/cascades/runtime"
+	zmq "github.com/pebbe/zmq4"
+)
+
+const (
+	// Failed refreshes are retried after this interval
+	refreshRetry = 10 * time.Second
+)
+
+var (
+	// Flags
+	optionsEndpoint   = flag.String("port.options", "", "Component's options port endpoint")
+	requestEndpoint   = flag.String("port.request", "", "Component's request port endpoint")
+	responseEndpoint  = flag.String("port.response", "", "Component's response port endpoint")
+	urlEndpoint       = flag.String("port.url", "", "Component's authorization URL port endpoint")
+	tokenEndpoint     = flag.String("port.token", "", "Component's token port endpoint")
+	accessEndpoint    = flag.String("port.access", "", "Component's access token port endpoint")
+	refreshEndpoint   = flag.String("port.refresh", "", "Component's refresh token port endpoint")
+	errorEndpoint     = flag.String("port.err", "", "Component's error port endpoint")
+	logEndpoint       = flag.String("port.log", "", "Component's log port endpoint")
+	heartbeatEndpoint = flag.String("port.heartbeat", "", "Component's heartbeat port endpoint")
+	metricsAddr       = flag.String("metrics", "", "Address to expose metrics on, i.e. 127.0.0.1:9090 (disabled if empty)")
+	jsonFlag          = flag.Bool("json", false, "Print component documentation in JSON")
+	debug             = flag.Bool("debug", false, "Enable debug mode")
+
+	// Internal
+	optionsPort, requestPort, responsePort, urlPort, tokenPort, accessPort, refreshPort, errPort, logPort, heartbeatPort *zmq.Socket
+	err                                                                                                                  error
+	logger                                                                                                               = httputils.NewLogger("http/oauth2")
+	liveness                                                                                                             = httputils.NewLiveness("http/oauth2")
+	metrics                                                                                                              = httputils.NewMetrics(logger, liveness)
+	shutdown                                                                                                             *httputils.Shutdown
+)
+
+// validateArgs checks all required flags
+func validateArgs() {
+	if *optionsEndpoint == "" {
+		flag.Usage()
+		os.Exit(1)
+	}
+	if *requestEndpoint == "" {
+		flag.Usage()
+		os.Exit(1)
+	}
+	if *responseEndpoint == "" {
+		flag.Usage()
+		os.Exit(1)
+	}
+}
+
+// openPorts create ZMQ sockets
+func openPorts() {
+	optionsPort, err = utils.CreateInputPort("http/oauth2.options", *optionsEndpoint, nil)
+	utils.AssertError(err)
+
+	requestPort, err = utils.CreateInputPort("http/oauth2.request", *requestEndpoint, nil)
+	utils.AssertError(err)
+
+	responsePort, err = utils.CreateOutputPort("http/oauth2.response", *responseEndpoint, nil)
+	utils.AssertError(err)
+
+	if *urlEndpoint != "" {
+		urlPort, err = utils.CreateOutputPort("http/oauth2.url", *urlEndpoint, nil)
+		utils.AssertError(err)
+	}
+	if *tokenEndpoint != "" {
+		tokenPort, err = utils.CreateOutputPort("http/oauth2.token", *tokenEndpoint, nil)
+		utils.AssertError(err)
+	}
+	if *accessEndpoint != "" {
+		accessPort, err = utils.CreateOutputPort("http/oauth2.access", *accessEndpoint, nil)
+		utils.AssertError(err)
+	}
+	if *refreshEndpoint != "" {
+		refreshPort, err = utils.CreateOutputPort("http/oauth2.refresh", *refreshEndpoint, nil)
+		utils.AssertError(err)
+	}
+	if *errorEndpoint != "" {
+		errPort, err = utils.CreateOutputPort("http/oauth2.err", *errorEndpoint, nil)
+		utils.AssertError(err)
+	}
+
+	if *logEndpoint != "" {
+		logPort, err = utils.CreateOutputPort("http/oauth2.log", *logEndpoint, nil)
+		utils.AssertError(err)
+		logger.SetSink(func(ip [][]byte) { logPort.SendMessageDontwait(ip) })
+	}
+
+	if *heartbeatEndpoint != "" {
+		heartbeatPort, err = utils.CreateOutputPort("http/oauth2.heartbeat", *heartbeatEndpoint, nil)
+		utils.AssertError(err)
+		liveness.Start(func(ip [][]byte) { heartbeatPort.SendMessageDontwait(ip) })
+	}
+}
+
+// closePorts closes all active ports and terminates ZMQ context
+func closePorts() {
+	shutdown.Close(optionsPort, requestPort)
+	liveness.Stop()
+	shutdown.Flush(responsePort, urlPort, tokenPort, accessPort, refreshPort, errPort, heartbeatPort, logPort)
+	zmq.Term()
+}
+
+func main() {
+	flag.Parse()
+
+	if *jsonFlag {
+		doc, _ := registryEntry.JSON()
+		fmt.Println(string(doc))
+		os.Exit(0)
+	}
+
+	log.SetFlags(0)
+	if *debug {
+		logger.SetLevel(httputils.LevelDebug)
+		logger.SetOutput(os.Stdout)
+	}
+	log.SetOutput(logger.Writer())
+
+	validateArgs()
+
+	shutdown = httputils.NewShutdown(logger)
+	openPorts()
+
+	if *metricsAddr != "" {
+		err = metrics.Serve(*metricsAddr)
+		utils.AssertError(err)
+	}
+
+	err = runtime.SetupShutdownByDisconnect(requestPort, "http/oauth2.request", shutdown.Signals())
+	utils.AssertError(err)
+
+	// Wait for the configuration on the options port
+	var flow *Flow
+	for flow == nil {
+		log.Println("Waiting for configuration...")
+		ip, err := shutdown.Receive(optionsPort)
+		if err == httputils.ErrShutdown {
+			shutdown.Exit(closePorts)
+		}
+		if err != nil {
+			logger.Error("Error receiving IP", "error", err)
+			continue
+		}
+		if !runtime.IsValidIP(ip) || !runtime.IsPacket(ip) {
+			continue
+		}
+		options := &Options{}
+		if err = json.Unmarshal(ip[1], options); err != nil {
+			logger.Error("Failed to unmarshal options", "error", err)
+			continue
+		}
+		if flow, err = NewFlow(options); err != nil {
+			logger.Error("Invalid OAuth2 configuration", "error", err)
+			continue
+		}
+		if options.RefreshToken == "" || !refresh(flow) && flow.state == "" {
+			authorize(flow)
+		}
+	}
+	optionsPort.Close()
+	optionsPort = nil
+
+	poller := zmq.NewPoller()
+	poller.Add(requestPort, zmq.POLLIN)
+
+	var retryAt time.Time
+
+	// Main loop
+	for !shutdown.Stopping() {
+		sockets, err := poller.Poll(shutdown.PollInterval)
+		if err != nil {
+			logger.Error("Error polling ports", "error", err)
+			continue
+		}
+
+		now := time.Now()
+		if at := flow.RefreshAt(); !at.IsZero() && now.After(at) && now.After(retryAt) {
+			if !refresh(flow) {
+				retryAt = now.Add(refreshRetry)
+			}
+		}
+
+		for _, socket := range sockets {
+			ip, err := socket.Socket.RecvMessageBytes(0)
+			if err != nil {
+				logger.Error("Error receiving message", "error", err)
+				continue
+			}
+			liveness.Inc()
+			if !httputils.IsValidIP(ip) || !runtime.IsPacket(ip) {
+				continue
+			}
+			req, err := httputils.IP2Request(ip)
+			if err != nil {
+				logger.Warn("Failed to convert IP to request", "error", err)
+				sendError(httputils.NewError("http/oauth2", httputils.ErrInvalidIP, err))
+				continue
+			}
+			responsePort.SendMessage(callback(flow, req).MustIP())
+		}
+	}
+	shutdown.Exit(closePorts)
+}
+
+// callback answers a request to the redirect URI exchanging its code
+func callback(flow *Flow, req *httputils.HTTPRequest) *httputils.HTTPResponse {
+	resp := httputils.NewResponse(http.StatusOK).WithID(req.ID).WithTrace(req.Trace)
+	if !flow.IsCallback(req.URI) {
+		resp.StatusCode = http.StatusNotFound
+		return resp.WithText("Not found")
+	}
+
+	query := url.Values(req.Query)
+	if query == nil {
+		if u, err := url.Parse(req.URI); err == nil {
+			query = u.Query()
+		}
+	}
+	token, err := flow.Callback(query)
+	if err != nil {
+		logger.Warn("Authorization failed", "error", err)
+		category := httputils.ErrInvalidRequest
+		if _, ok := err.(*TokenError); ok {
+			category = httputils.ErrUpstream
+		}
+		sendError(httputils.NewError("http/oauth2", category, err).WithRequest(req.ID))
+		resp.StatusCode = http.StatusBadRequest
+		return resp.WithText("Authorization failed: %v", err)
+	}
+	logger.Info("Authorization complete", "expires_at", token.ExpiresAt)
+	sendToken(token)
+	return resp.WithText("Authorization complete, you can close this window.")
+}
+
+// authorize starts a new authorization and emits its URL
+func authorize(flow *Flow) {
+	u := flow.Authorize()
+	logger.Info("Open the URL in a browser to authorize", "url", u)
+	if urlPort != nil {
+		urlPort.SendMessage(runtime.NewPacket([]byte(u)))
+	}
+}
+
+// refresh renews the token, a new authorization is started if the refresh
+// token was rejected. It reports whether the token was refreshed.
+func refresh(flow *Flow) bool {
+	token, err := flow.Refresh()
+	if err != nil {
+		logger.Warn("Failed to refresh token", "error", err)
+		category := httputils.ErrNetwork
+		if e, ok := err.(*TokenError); ok {
+			category = httputils.ErrUpstream
+			if e.Code == "invalid_grant" {
+				authorize(flow)
+			}
+		}
+		sendError(httputils.NewError("http/oauth2", category, err))
+		return false
+	}
+	logger.Info("Token refreshed", "expires_at", token.ExpiresAt)
+	sendToken(token)
+	return true
+}
+
+// sendToken emits a token to connected token ports
+func sendToken(token *Token) {
+	if tokenPort != nil {
+		data, _ := json.Marshal(token)
+		tokenPort.SendMessage(runtime.NewPacket(data))
+	}
+	if accessPort != nil {
+		accessPort.SendMessage(runtime.NewPacket([]byte(token.AccessToken)))
+	}
+	if refreshPort != nil && token.RefreshToken != "" {
+		refreshPort.SendMessage(runtime.NewPacket([]byte(token.RefreshToken)))
+	}
+}
+
+// sendError reports a failure to the ERR port if it's connected
+func sendError(e *httputils.Error) {
+	if errPort == nil {
+		return
+	}
+	ip, err := httputils.Error2IP(e)
+	if err != nil {
+		return
+	}
+	errPort.SendMessageDontwait(ip)
+}
@@ -0,0 +1,63 @@
+package main
+
+import "github.com/cascades-fbp/cascades/library"
+
+var registryEntry = &library.Entry{
+	Description: `Generates presigned URLs of objects in S3-compatible storage or Google Cloud Storage (HMAC keys)
+with Signature Version 4, so graphs can hand out temporary download and upload links without proxying
+the bytes. Credentials are taken from options or AWS_ACCESS_KEY_ID, AWS_SECRET_ACCESS_KEY and
+AWS_SESSION_TOKEN environment variables. Expiry is at most 7 days.`,
+	Elementary: true,
+	Inports: []library.EntryPort{
+		library.EntryPort{
+			Name:        "OPTIONS",
+			Type:        "json",
+			Description: `Configuration port, i.e. {"endpoint": "https://minio.local:9000", "path_style": true, "region": "us-east-1", "access_key": "...", "secret_key": "...", "bucket": "uploads", "method": "GET", "expires": "15m"}. Provider gcs signs for storage.googleapis.com`,
+			Required:    true,
+		},
+		library.EntryPort{
+			Name:        "KEY",
+			Type:        "string",
+			Description: `Object key to presign with defaults, or JSON object overriding them, i.e. {"key": "avatars/42.png", "method": "PUT", "expires": "5m", "content_type": "image/png"}`,
+			Required:    true,
+		},
+		library.EntryPort{
+			Name:        "BUCKET",
+			Type:        "string",
+			Description: "Optional port changing the default bucket",
+			Required:    false,
+		},
+		library.EntryPort{
+			Name:        "EXPIRY",
+			Type:        "string",
+			Description: "Optional port changing the default expiry, i.e. 1h",
+			Required:    false,
+		},
+	},
+	Outports: []library.EntryPort{
+		library.EntryPort{
+			Name:        "OUT",
+			Type:        "json",
+			Description: `Presigned URLs, i.e. {"url": "https://...", "method": "PUT", "bucket": "uploads", "key": "avatars/42.png", "expires_at": "2024-05-01T12:00:00Z", "headers": {"Content-Type": ["image/png"]}} where headers have to be sent with the request`,
+			Required:    true,
+		},
+		library.EntryPort{
+			Name:        "ERR",
+			Type:        "json",
+			Description: "Optional error port for keys which couldn't be presigned (error JSON object defined in utils of HTTP components library)",
+			Required:    false,
+		},
+		library.EntryPort{
+			Name:        "LOG",
+			Type:        "json",
+			Description: "Optional output port for structured log entries (log entry JSON object defined in utils of HTTP components library)",
+			Required:    false,
+		},
+		library.EntryPort{
+			Name:        "HEARTBEAT",
+			Type:        "json",
+			Description: "Optional output port for periodic liveness IPs (heartbeat JSON object defined in utils of HTTP components library)",
+			Required:    false,
+		},
+	},
+}
@@ -0,0 +1,325 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	httputils "github.com/cascades-fbp/cascades-http/utils"
+	"github.com/cascades-fbp/cascades/components/utils"
+	"github.com/cascades-fbp/cascades/runtime"
+	zmq "github.com/pebbe/zmq4"
+)
+
+var (
+	// Flags
+	optionsEndpoint   = flag.String("port.options", "", "Component's options port endpoint")
+	keyEndpoint       = flag.String("port.key", "", "Component's key port endpoint")
+	bucketEndpoint    = flag.String("port.bucket", "", "Component's bucket port endpoint")
+	expiryEndpoint    = flag.String("port.expiry", "", "Component's expiry port endpoint")
+	outputEndpoint    = flag.String("port.out", "", "Component's output port endpoint")
+	errorEndpoint     = flag.String("port.err", "", "Component's error port endpoint")
+	logEndpoint       = flag.String("port.log", "", "Component's log port endpoint")
+	heartbeatEndpoint = flag.String("port.heartbeat", "", "Component's heartbeat port endpoint")
+	metricsAddr       = flag.String("metrics", "", "Address to expose metrics on, i.e. 127.0.0.1:9090 (disabled if empty)")
+	jsonFlag          = flag.Bool("json", false, "Print component documentation in JSON")
+	debug             = flag.Bool("debug", false, "Enable debug mode")
+
+	// Internal
+	optionsPort, keyPort, bucketPort, expiryPort, outPort, errPort, logPort, heartbeatPort *zmq.Socket
+	err                                                                                    error
+	logger                                                                                 = httputils.NewLogger("http/presigner")
+	liveness                                                                               = httputils.NewLiveness("http/presigner")
+	metrics                                                                                = httputils.NewMetrics(logger, liveness)
+	shutdown                                                                               *httputils.Shutdown
+)
+
+// Options describe the configuration IP of the component
+type Options struct {
+	httputils.StorageCredentials
+	Bucket  string             `json:"bucket"`  // Default bucket, can be changed with the BUCKET port
+	Method  string             `json:"method"`  // Default method, GET or PUT (GET by default)
+	Expires httputils.Duration `json:"expires"` // Default validity, can be changed with the EXPIRY port (15m by default)
+}
+
+// Object describes a URL to presign received on the KEY port, a plain key uses defaults
+type Object struct {
+	Bucket      string             `json:"bucket"`
+	Key         string             `json:"key"`
+	Method      string             `json:"method"`
+	Expires     httputils.Duration `json:"expires"`
+	ContentType string             `json:"content_type"` // Signed Content-Type uploads have to be sent with
+}
+
+// Presigned is the IP sent to the OUT port
+type Presigned struct {
+	URL       string              `json:"url"`
+	Method    string              `json:"method"`
+	Bucket    string              `json:"bucket"`
+	Key       string              `json:"key"`
+	ExpiresAt time.Time           `json:"expires_at"`
+	Headers   map[string][]string `json:"headers,omitempty"` // Headers the request has to be sent with
+}
+
+const defaultExpiry = 15 * time.Minute
+
+// validateArgs checks all required flags
+func validateArgs() {
+	if *optionsEndpoint == "" || *keyEndpoint == "" || *outputEndpoint == "" {
+		flag.Usage()
+		os.Exit(1)
+	}
+}
+
+// openPorts create ZMQ sockets
+func openPorts() {
+	optionsPort, err = utils.CreateInputPort("http/presigner.options", *optionsEndpoint, nil)
+	utils.AssertError(err)
+
+	keyPort, err = utils.CreateInputPort("http/presigner.key", *keyEndpoint, nil)
+	utils.AssertError(err)
+
+	if *bucketEndpoint != "" {
+		bucketPort, err = utils.CreateInputPort("http/presigner.bucket", *bucketEndpoint, nil)
+		utils.AssertError(err)
+	}
+
+	if *expiryEndpoint != "" {
+		expiryPort, err = utils.CreateInputPort("http/presigner.expiry", *expiryEndpoint, nil)
+		utils.AssertError(err)
+	}
+
+	outPort, err = utils.CreateOutputPort("http/presigner.out", *outputEndpoint, nil)
+	utils.AssertError(err)
+
+	if *errorEndpoint != "" {
+		errPort, err = utils.CreateOutputPort("http/presigner.err", *errorEndpoint, nil)
+		utils.AssertError(err)
+	}
+
+	if *logEndpoint != "" {
+		logPort, err = utils.CreateOutputPort("http/presigner.log", *logEndpoint, nil)
+		utils.AssertError(err)
+		logger.SetSink(func(ip [][]byte) { logPort.SendMessageDontwait(ip) })
+	}
+
+	if *heartbeatEndpoint != "" {
+		heartbeatPort, err = utils.CreateOutputPort("http/presigner.heartbeat", *heartbeatEndpoint, nil)
+		utils.AssertError(err)
+		liveness.Start(func(ip [][]byte) { heartbeatPort.SendMessageDontwait(ip) })
+	}
+}
+
+// closePorts closes all active ports and terminates ZMQ context
+func closePorts() {
+	shutdown.Close(optionsPort, keyPort, bucketPort, expiryPort)
+	liveness.Stop()
+	shutdown.Flush(outPort, errPort, heartbeatPort, logPort)
+	zmq.Term()
+}
+
+// parseObject decodes a KEY payload applying defaults of options
+func parseObject(payload []byte, options *Options) (*Object, error) {
+	obj := &Object{}
+	if p := bytes.TrimSpace(payload); len(p) > 0 && p[0] == '{' {
+		if err := json.Unmarshal(p, obj); err != nil {
+			return nil, err
+		}
+	} else {
+		obj.Key = string(payload)
+	}
+	obj.Key = strings.TrimPrefix(obj.Key, "/")
+	if obj.Key == "" {
+		return nil, fmt.Errorf("key is empty")
+	}
+	if obj.Bucket == "" {
+		obj.Bucket = options.Bucket
+	}
+	if obj.Bucket == "" {
+		return nil, fmt.Errorf("bucket is not set")
+	}
+	if obj.Method == "" {
+		obj.Method = options.Method
+	}
+	obj.Method = strings.ToUpper(obj.Method)
+	switch obj.Method {
+	case "":
+		obj.Method = http.MethodGet
+	case http.MethodGet, http.MethodHead, http.MethodPut, http.MethodDelete:
+	default:
+		return nil, fmt.Errorf("unsupported method %s", obj.Method)
+	}
+	if obj.Expires <= 0 {
+		obj.Expires = options.Expires
+	}
+	return obj, nil
+}
+
+// presign creates the presigned URL of an object
+func presign(signer *httputils.SigV4, obj *Object) (*Presigned, error) {
+	var header http.Header
+	if obj.ContentType != "" {
+		header = http.Header{"Content-Type": {obj.ContentType}}
+	}
+	now := time.Now()
+	u, err := signer.Presign(obj.Method, signer.ObjectURL(obj.Bucket, obj.Key), header, time.Duration(obj.Expires), now)
+	if err != nil {
+		return nil, err
+	}
+	return &Presigned{
+		URL:       u,
+		Method:    obj.Method,
+		Bucket:    obj.Bucket,
+		Key:       obj.Key,
+		ExpiresAt: now.Add(time.Duration(obj.Expires)).UTC().Truncate(time.Second),
+		Headers:   header,
+	}, nil
+}
+
+func main() {
+	flag.Parse()
+
+	if *jsonFlag {
+		doc, _ := registryEntry.JSON()
+		fmt.Println(string(doc))
+		os.Exit(0)
+	}
+
+	log.SetFlags(0)
+	if *debug {
+		logger.SetLevel(httputils.LevelDebug)
+		logger.SetOutput(os.Stdout)
+	}
+	log.SetOutput(logger.Writer())
+
+	validateArgs()
+
+	shutdown = httputils.NewShutdown(logger)
+	openPorts()
+
+	if *metricsAddr != "" {
+		err = metrics.Serve(*metricsAddr)
+		utils.AssertError(err)
+	}
+
+	err = runtime.SetupShutdownByDisconnect(keyPort, "http/presigner.key", shutdown.Signals())
+	utils.AssertError(err)
+
+	// Wait for the configuration on the options port
+	var (
+		options *Options
+		signer  *httputils.SigV4
+	)
+	for signer == nil {
+		log.Println("Waiting for configuration...")
+		ip, err := shutdown.Receive(optionsPort)
+		if err == httputils.ErrShutdown {
+			shutdown.Exit(closePorts)
+		}
+		if err != nil {
+			logger.Error("Error receiving IP", "error", err)
+			continue
+		}
+		if !runtime.IsValidIP(ip) || !runtime.IsPacket(ip) {
+			continue
+		}
+		options = &Options{Expires: httputils.Duration(defaultExpiry)}
+		if err = json.Unmarshal(ip[1], options); err != nil {
+			logger.Error("Failed to unmarshal options", "error", err)
+			continue
+		}
+		if signer, err = httputils.NewSigV4(&options.StorageCredentials); err != nil {
+			logger.Error("Invalid storage configuration", "error", err)
+			continue
+		}
+	}
+	optionsPort.Close()
+	optionsPort = nil
+
+	poller := zmq.NewPoller()
+	poller.Add(keyPort, zmq.POLLIN)
+	if bucketPort != nil {
+		poller.Add(bucketPort, zmq.POLLIN)
+	}
+	if expiryPort != nil {
+		poller.Add(expiryPort, zmq.POLLIN)
+	}
+
+	// Main loop
+	for !shutdown.Stopping() {
+		sockets, err := poller.Poll(shutdown.PollInterval)
+		if err != nil {
+			logger.Error("Error polling ports", "error", err)
+			continue
+		}
+
+		for _, socket := range sockets {
+			ip, err := socket.Socket.RecvMessageBytes(0)
+			if err != nil {
+				logger.Error("Error receiving message", "error", err)
+				continue
+			}
+			liveness.Inc()
+			if !runtime.IsValidIP(ip) || !runtime.IsPacket(ip) {
+				continue
+			}
+
+			switch socket.Socket {
+			case bucketPort:
+				options.Bucket = strings.TrimSpace(string(ip[1]))
+				logger.Info("Changed default bucket", "bucket", options.Bucket)
+
+			case expiryPort:
+				d, err := time.ParseDuration(strings.Trim(strings.TrimSpace(string(ip[1])), `"`))
+				if err != nil || d <= 0 {
+					logger.Warn("Invalid expiry", "value", string(ip[1]))
+					sendError(httputils.NewError("http/presigner", httputils.ErrInvalidIP, fmt.Errorf("invalid expiry %q", ip[1])))
+					continue
+				}
+				options.Expires = httputils.Duration(d)
+				logger.Info("Changed default expiry", "expires", d.String())
+
+			case keyPort:
+				obj, err := parseObject(ip[1], options)
+				if err != nil {
+					logger.Warn("Invalid object", "error", err)
+					sendError(httputils.NewError("http/presigner", httputils.ErrInvalidIP, err))
+					continue
+				}
+				presigned, err := presign(signer, obj)
+				if err != nil {
+					logger.Warn("Failed to presign URL", "bucket", obj.Bucket, "key", obj.Key, "error", err)
+					sendError(httputils.NewError("http/presigner", httputils.ErrInvalidRequest, err))
+					continue
+				}
+				payload, err := json.Marshal(presigned)
+				if err != nil {
+					logger.Error("Failed to marshal presigned URL", "error", err)
+					sendError(httputils.NewError("http/presigner", httputils.ErrInternal, err))
+					continue
+				}
+				logger.Debug("Presigned URL", "method", obj.Method, "bucket", obj.Bucket, "key", obj.Key, "expires_at", presigned.ExpiresAt)
+				outPort.SendMessage(runtime.NewPacket(payload))
+			}
+		}
+	}
+	shutdown.Exit(closePorts)
+}
+
+// sendError reports a failure to the ERR port if it's connected
+func sendError(e *httputils.Error) {
+	if errPort == nil {
+		return
+	}
+	ip, err := httputils.Error2IP(e)
+	if err != nil {
+		return
+	}
+	errPort.SendMessageDontwait(ip)
+}
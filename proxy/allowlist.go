@@ -0,0 +1,64 @@
+package main
+
+import (
+	"net"
+	"strings"
+)
+
+// AllowList matches destinations against a list of host patterns.
+//
+// Supported patterns:
+//
+//	"*"                 any destination
+//	"example.com"       exact host on any port
+//	"example.com:443"   exact host on a given port
+//	"*.example.com"     any subdomain of example.com (on any port, or a given one with ":port")
+type AllowList struct {
+	patterns []string
+}
+
+// NewAllowList returns an allow-list for given patterns
+func NewAllowList(patterns []string) *AllowList {
+	l := &AllowList{}
+	for _, p := range patterns {
+		p = strings.ToLower(strings.TrimSpace(p))
+		if p != "" {
+			l.patterns = append(l.patterns, p)
+		}
+	}
+	return l
+}
+
+// Allowed reports whether a given host[:port] destination is permitted
+func (l *AllowList) Allowed(dest string) bool {
+	host, port, err := net.SplitHostPort(dest)
+	if err != nil {
+		host = dest
+		port = ""
+	}
+	host = strings.ToLower(host)
+
+	for _, p := range l.patterns {
+		if p == "*" {
+			return true
+		}
+		pHost, pPort, err := net.SplitHostPort(p)
+		if err != nil {
+			pHost = p
+			pPort = ""
+		}
+		if pPort != "" && pPort != port {
+			continue
+		}
+		if strings.HasPrefix(pHost, "*.") {
+			if strings.HasSuffix(host, pHost[1:]) {
+				return true
+			}
+			continue
+		}
+		if pHost == host {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,179 @@
+package main
+
+import (
+	"io"
+	"net"
+	"net/http"
+	"time"
+)
+
+// Event describes a proxied or rejected request logged as a record of the LOG
+// port
+type Event struct {
+	Method     string
+	Host       string
+	URI        string
+	RemoteAddr string
+	Allowed    bool
+	StatusCode int
+	Duration   time.Duration
+	Error      string
+}
+
+// log logs proxied requests on info level and rejected ones on warn level
+func (e *Event) log() {
+	keyvals := []interface{}{
+		"method", e.Method,
+		"host", e.Host,
+		"uri", e.URI,
+		"remote_addr", e.RemoteAddr,
+		"status", e.StatusCode,
+		"duration", e.Duration.String(),
+	}
+	if e.Error != "" {
+		keyvals = append(keyvals, "error", e.Error)
+	}
+	if !e.Allowed {
+		logger.Warn("Rejected request", keyvals...)
+		return
+	}
+	logger.Info("Proxied request", keyvals...)
+}
+
+// Hop-by-hop headers which must not be forwarded by proxies
+var hopHeaders = []string{
+	"Connection",
+	"Keep-Alive",
+	"Proxy-Authenticate",
+	"Proxy-Authorization",
+	"Proxy-Connection",
+	"Te",
+	"Trailer",
+	"Transfer-Encoding",
+	"Upgrade",
+}
+
+// Proxy is a forward proxy HTTP handler
+type Proxy struct {
+	allow     *AllowList
+	transport *http.Transport
+}
+
+// NewProxy returns a proxy restricted by a given allow-list
+func NewProxy(allow *AllowList) *Proxy {
+	return &Proxy{
+		allow: allow,
+		transport: &http.Transport{
+			TLSHandshakeTimeout: 10 * time.Second,
+		},
+	}
+}
+
+func (p *Proxy) ServeHTTP(rw http.ResponseWriter, req *http.Request) {
+	liveness.Inc()
+	start := time.Now()
+	event := Event{
+		Method:     req.Method,
+		Host:       req.Host,
+		URI:        req.RequestURI,
+		RemoteAddr: req.RemoteAddr,
+	}
+	defer func() {
+		event.Duration = time.Since(start)
+		event.log()
+	}()
+
+	dest := req.Host
+	if req.Method != "CONNECT" {
+		if !req.URL.IsAbs() {
+			event.StatusCode = http.StatusBadRequest
+			http.Error(rw, "This is a proxy, absolute URI is required", event.StatusCode)
+			return
+		}
+		dest = req.URL.Host
+	}
+
+	if !p.allow.Allowed(dest) {
+		event.StatusCode = http.StatusForbidden
+		http.Error(rw, "Destination is not allowed", event.StatusCode)
+		return
+	}
+	event.Allowed = true
+
+	if req.Method == "CONNECT" {
+		event.StatusCode, event.Error = p.tunnel(rw, req)
+		return
+	}
+	event.StatusCode, event.Error = p.forward(rw, req)
+}
+
+// forward performs a plain HTTP request on behalf of the client
+func (p *Proxy) forward(rw http.ResponseWriter, req *http.Request) (int, string) {
+	outreq := req.Clone(req.Context())
+	outreq.RequestURI = ""
+	removeHopHeaders(outreq.Header)
+
+	resp, err := p.transport.RoundTrip(outreq)
+	if err != nil {
+		logger.Error("Error forwarding request", "error", err)
+		http.Error(rw, err.Error(), http.StatusBadGateway)
+		return http.StatusBadGateway, err.Error()
+	}
+	defer resp.Body.Close()
+
+	removeHopHeaders(resp.Header)
+	for name, values := range resp.Header {
+		for _, value := range values {
+			rw.Header().Add(name, value)
+		}
+	}
+	rw.WriteHeader(resp.StatusCode)
+	if _, err = io.Copy(rw, resp.Body); err != nil {
+		return resp.StatusCode, err.Error()
+	}
+	return resp.StatusCode, ""
+}
+
+// tunnel establishes a TCP tunnel for CONNECT requests
+func (p *Proxy) tunnel(rw http.ResponseWriter, req *http.Request) (int, string) {
+	hijacker, ok := rw.(http.Hijacker)
+	if !ok {
+		http.Error(rw, "Tunneling is not supported", http.StatusInternalServerError)
+		return http.StatusInternalServerError, "response writer doesn't support hijacking"
+	}
+
+	upstream, err := net.DialTimeout("tcp", req.Host, 10*time.Second)
+	if err != nil {
+		logger.Error("Error connecting to upstream", "error", err)
+		http.Error(rw, err.Error(), http.StatusBadGateway)
+		return http.StatusBadGateway, err.Error()
+	}
+
+	conn, _, err := hijacker.Hijack()
+	if err != nil {
+		upstream.Close()
+		http.Error(rw, err.Error(), http.StatusInternalServerError)
+		return http.StatusInternalServerError, err.Error()
+	}
+	conn.Write([]byte("HTTP/1.1 200 Connection Established\r\n\r\n"))
+
+	done := make(chan bool, 2)
+	pipe := func(dst, src net.Conn) {
+		io.Copy(dst, src)
+		done <- true
+	}
+	go pipe(upstream, conn)
+	go pipe(conn, upstream)
+	<-done
+	conn.Close()
+	upstream.Close()
+	<-done
+
+	return http.StatusOK, ""
+}
+
+func removeHopHeaders(h http.Header) {
+	for _, name := range hopHeaders {
+		h.Del(name)
+	}
+}
@@ -0,0 +1,158 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"os"
+
+	httputils "github.com/cascades-fbp/cascades-http/utils"
+	"github.com/cascades-fbp/cascades/components/utils"
+	"github.com/cascades-fbp/cascades/runtime"
+	zmq "github.com/pebbe/zmq4"
+)
+
+var (
+	// Flags
+	optionsEndpoint   = flag.String("port.options", "", "Component's options port endpoint")
+	logEndpoint       = flag.String("port.log", "", "Component's log output port endpoint")
+	heartbeatEndpoint = flag.String("port.heartbeat", "", "Component's heartbeat port endpoint")
+	metricsAddr       = flag.String("metrics", "", "Address to expose metrics on, i.e. 127.0.0.1:9090 (disabled if empty)")
+	jsonFlag          = flag.Bool("json", false, "Print component documentation in JSON")
+	debug             = flag.Bool("debug", false, "Enable debug mode")
+
+	// Internal
+	optionsPort, logPort, heartbeatPort *zmq.Socket
+	err                                 error
+	logger                              = httputils.NewLogger("http/proxy")
+	liveness                            = httputils.NewLiveness("http/proxy")
+	metrics                             = httputils.NewMetrics(logger, liveness)
+	shutdown                            *httputils.Shutdown
+)
+
+// Options describe the configuration IP of the component
+type Options struct {
+	Listen string   `json:"listen"` // TCP endpoint to listen on
+	Allow  []string `json:"allow"`  // Allowed destination patterns
+}
+
+// validateArgs checks all required flags
+func validateArgs() {
+	if *optionsEndpoint == "" {
+		flag.Usage()
+		os.Exit(1)
+	}
+}
+
+// openPorts create ZMQ sockets
+func openPorts() {
+	optionsPort, err = utils.CreateInputPort("http/proxy.options", *optionsEndpoint, nil)
+	utils.AssertError(err)
+
+	if *logEndpoint != "" {
+		logPort, err = utils.CreateOutputPort("http/proxy.log", *logEndpoint, nil)
+		utils.AssertError(err)
+		logger.SetSink(func(ip [][]byte) { logPort.SendMessageDontwait(ip) })
+	}
+
+	if *heartbeatEndpoint != "" {
+		heartbeatPort, err = utils.CreateOutputPort("http/proxy.heartbeat", *heartbeatEndpoint, nil)
+		utils.AssertError(err)
+		liveness.Start(func(ip [][]byte) { heartbeatPort.SendMessageDontwait(ip) })
+	}
+}
+
+// closePorts closes all active ports and terminates ZMQ context
+func closePorts() {
+	shutdown.Close(optionsPort)
+	liveness.Stop()
+	shutdown.Flush(heartbeatPort, logPort)
+	zmq.Term()
+}
+
+func main() {
+	flag.Parse()
+
+	if *jsonFlag {
+		doc, _ := registryEntry.JSON()
+		fmt.Println(string(doc))
+		os.Exit(0)
+	}
+
+	log.SetFlags(0)
+	if *debug {
+		logger.SetLevel(httputils.LevelDebug)
+		logger.SetOutput(os.Stdout)
+	}
+	log.SetOutput(logger.Writer())
+
+	validateArgs()
+
+	shutdown = httputils.NewShutdown(logger)
+	openPorts()
+
+	if *metricsAddr != "" {
+		err = metrics.Serve(*metricsAddr)
+		utils.AssertError(err)
+	}
+
+	// Wait for the configuration on the options port
+	var options Options
+	for {
+		log.Println("Waiting for configuration...")
+		ip, err := shutdown.Receive(optionsPort)
+		if err == httputils.ErrShutdown {
+			shutdown.Exit(closePorts)
+		}
+		if err != nil {
+			logger.Error("Error receiving IP", "error", err)
+			continue
+		}
+		if !runtime.IsValidIP(ip) || !runtime.IsPacket(ip) {
+			continue
+		}
+		if err = json.Unmarshal(ip[1], &options); err != nil {
+			logger.Error("Failed to unmarshal options", "error", err)
+			continue
+		}
+		if options.Listen == "" {
+			logger.Error("Listen address is missing in configuration")
+			continue
+		}
+		break
+	}
+	optionsPort.Close()
+	optionsPort = nil
+
+	// Proxy server goroutine
+	s := &http.Server{
+		Handler:        NewProxy(NewAllowList(options.Allow)),
+		MaxHeaderBytes: 1 << 20,
+	}
+	go func() {
+		ln, err := net.Listen("tcp", options.Listen)
+		if err != nil {
+			logger.Error("Failed to listen", "addr", options.Listen, "error", err)
+			shutdown.Stop()
+			return
+		}
+
+		logger.Info("Starting listening", "addr", options.Listen)
+		err = s.Serve(ln)
+		if err != nil && err != http.ErrServerClosed {
+			logger.Error("Server stopped", "error", err)
+			shutdown.Stop()
+		}
+	}()
+
+	<-shutdown.Done()
+	// Stop accepting connections and let active requests finish
+	if err := s.Shutdown(context.Background()); err != nil {
+		logger.Error("Failed to shutdown server", "error", err)
+	}
+	shutdown.Exit(closePorts)
+}
@@ -0,0 +1,102 @@
+package main
+
+import (
+	"time"
+)
+
+const buckets = 10
+
+// Options describe the configuration IP of the component
+type Options struct {
+	Ratio      float64 `json:"ratio"`       // Maximal retries to primary requests ratio
+	MinRetries int     `json:"min_retries"` // Retries always allowed within window regardless of traffic
+	Window     string  `json:"window"`      // Sliding window duration
+}
+
+// Event is emitted when retries start being dropped
+type Event struct {
+	Time    string  `json:"time"`
+	Primary int     `json:"primary"`
+	Retries int     `json:"retries"`
+	Ratio   float64 `json:"ratio"`
+	Dropped int     `json:"dropped"`
+}
+
+// Budget counts primary requests and retries in a sliding window
+type Budget struct {
+	ratio      float64
+	minRetries int
+	width      time.Duration
+	primary    [buckets]int
+	retries    [buckets]int
+	current    int
+	started    time.Time
+	exhausted  bool
+	dropped    int
+}
+
+// NewBudget creates a budget with a given ratio, minimal retries and window
+func NewBudget(ratio float64, minRetries int, window time.Duration) *Budget {
+	return &Budget{
+		ratio:      ratio,
+		minRetries: minRetries,
+		width:      window / buckets,
+		started:    time.Now(),
+	}
+}
+
+// Primary records a primary request
+func (b *Budget) Primary(now time.Time) {
+	b.advance(now)
+	b.primary[b.current]++
+}
+
+// Retry records an attempt to retry and reports whether it fits the budget.
+// When the budget becomes exhausted an event is returned.
+func (b *Budget) Retry(now time.Time) (bool, *Event) {
+	b.advance(now)
+	primary, retries := b.totals()
+	allowed := float64(retries+1) <= b.ratio*float64(primary)+float64(b.minRetries)
+	if allowed {
+		b.retries[b.current]++
+		b.exhausted = false
+		return true, nil
+	}
+
+	b.dropped++
+	if b.exhausted {
+		return false, nil
+	}
+	b.exhausted = true
+	return false, &Event{
+		Time:    now.UTC().Format(time.RFC3339),
+		Primary: primary,
+		Retries: retries,
+		Ratio:   b.ratio,
+		Dropped: b.dropped,
+	}
+}
+
+// advance rotates buckets up to a given moment
+func (b *Budget) advance(now time.Time) {
+	for now.Sub(b.started) >= b.width {
+		b.started = b.started.Add(b.width)
+		b.current = (b.current + 1) % buckets
+		b.primary[b.current] = 0
+		b.retries[b.current] = 0
+		if now.Sub(b.started) > b.width*buckets {
+			// Idle for longer than the window, reset everything
+			b.primary = [buckets]int{}
+			b.retries = [buckets]int{}
+			b.started = now
+		}
+	}
+}
+
+func (b *Budget) totals() (primary, retries int) {
+	for i := 0; i < buckets; i++ {
+		primary += b.primary[i]
+		retries += b.retries[i]
+	}
+	return
+}
@@ -0,0 +1,57 @@
+package main
+
+import "github.com/cascades-fbp/cascades/library"
+
+var registryEntry = &library.Entry{
+	Description: `Enforces a retry budget shared by all retrying components of a graph. Primary requests from
+PRIMARY port and retries from RETRY port are forwarded to OUT as long as retries within a sliding window
+do not exceed a configured ratio of primary traffic. Excess retries are dropped and a budget-exhausted
+event is emitted on EXHAUSTED port.`,
+	Elementary: true,
+	Inports: []library.EntryPort{
+		library.EntryPort{
+			Name:        "OPTIONS",
+			Type:        "json",
+			Description: `Optional configuration port, i.e. {"ratio": 0.2, "min_retries": 10, "window": "10s"}`,
+			Required:    false,
+		},
+		library.EntryPort{
+			Name:        "PRIMARY",
+			Type:        "json",
+			Description: "Input port for primary (first attempt) requests",
+			Required:    true,
+		},
+		library.EntryPort{
+			Name:        "RETRY",
+			Type:        "json",
+			Description: "Input port for retried requests",
+			Required:    true,
+		},
+	},
+	Outports: []library.EntryPort{
+		library.EntryPort{
+			Name:        "OUT",
+			Type:        "json",
+			Description: "Output port for primary requests and retries within budget",
+			Required:    true,
+		},
+		library.EntryPort{
+			Name:        "EXHAUSTED",
+			Type:        "json",
+			Description: "Output port for budget-exhausted events",
+			Required:    false,
+		},
+		library.EntryPort{
+			Name:        "LOG",
+			Type:        "json",
+			Description: "Optional output port for structured log entries (log entry JSON object defined in utils of HTTP components library)",
+			Required:    false,
+		},
+		library.EntryPort{
+			Name:        "HEARTBEAT",
+			Type:        "json",
+			Description: "Optional output port for periodic liveness IPs (heartbeat JSON object defined in utils of HTTP components library)",
+			Required:    false,
+		},
+	},
+}
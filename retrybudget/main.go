@@ -0,0 +1,201 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"time"
+
+	httputils "github.com/cascades-fbp/cascades-http/utils"
+	"github.com/cascades-fbp/cascades/components/utils"
+	"github.com/cascades-fbp/cascades/runtime"
+	zmq "github.com/pebbe/zmq4"
+)
+
+var (
+	// Flags
+	optionsEndpoint   = flag.String("port.options", "", "Component's options port endpoint")
+	primaryEndpoint   = flag.String("port.primary", "", "Component's primary requests port endpoint")
+	retryEndpoint     = flag.String("port.retry", "", "Component's retries port endpoint")
+	outputEndpoint    = flag.String("port.out", "", "Component's output port endpoint")
+	exhaustedEndpoint = flag.String("port.exhausted", "", "Component's budget-exhausted events port endpoint")
+	logEndpoint       = flag.String("port.log", "", "Component's log port endpoint")
+	heartbeatEndpoint = flag.String("port.heartbeat", "", "Component's heartbeat port endpoint")
+	metricsAddr       = flag.String("metrics", "", "Address to expose metrics on, i.e. 127.0.0.1:9090 (disabled if empty)")
+	jsonFlag          = flag.Bool("json", false, "Print component documentation in JSON")
+	debug             = flag.Bool("debug", false, "Enable debug mode")
+
+	// Internal
+	optionsPort, primaryPort, retryPort, outPort, exhaustedPort, logPort, heartbeatPort *zmq.Socket
+	err                                                                                 error
+	logger                                                                              = httputils.NewLogger("http/retrybudget")
+	liveness                                                                            = httputils.NewLiveness("http/retrybudget")
+	metrics                                                                             = httputils.NewMetrics(logger, liveness)
+	shutdown                                                                            *httputils.Shutdown
+)
+
+// validateArgs checks all required flags
+func validateArgs() {
+	if *primaryEndpoint == "" {
+		flag.Usage()
+		os.Exit(1)
+	}
+	if *retryEndpoint == "" {
+		flag.Usage()
+		os.Exit(1)
+	}
+	if *outputEndpoint == "" {
+		flag.Usage()
+		os.Exit(1)
+	}
+}
+
+// openPorts create ZMQ sockets
+func openPorts() {
+	if *optionsEndpoint != "" {
+		optionsPort, err = utils.CreateInputPort("http/retrybudget.options", *optionsEndpoint, nil)
+		utils.AssertError(err)
+	}
+
+	primaryPort, err = utils.CreateInputPort("http/retrybudget.primary", *primaryEndpoint, nil)
+	utils.AssertError(err)
+
+	retryPort, err = utils.CreateInputPort("http/retrybudget.retry", *retryEndpoint, nil)
+	utils.AssertError(err)
+
+	outPort, err = utils.CreateOutputPort("http/retrybudget.out", *outputEndpoint, nil)
+	utils.AssertError(err)
+
+	if *exhaustedEndpoint != "" {
+		exhaustedPort, err = utils.CreateOutputPort("http/retrybudget.exhausted", *exhaustedEndpoint, nil)
+		utils.AssertError(err)
+	}
+
+	if *logEndpoint != "" {
+		logPort, err = utils.CreateOutputPort("http/retrybudget.log", *logEndpoint, nil)
+		utils.AssertError(err)
+		logger.SetSink(func(ip [][]byte) { logPort.SendMessageDontwait(ip) })
+	}
+
+	if *heartbeatEndpoint != "" {
+		heartbeatPort, err = utils.CreateOutputPort("http/retrybudget.heartbeat", *heartbeatEndpoint, nil)
+		utils.AssertError(err)
+		liveness.Start(func(ip [][]byte) { heartbeatPort.SendMessageDontwait(ip) })
+	}
+}
+
+// closePorts closes all active ports and terminates ZMQ context
+func closePorts() {
+	shutdown.Close(optionsPort, primaryPort, retryPort)
+	liveness.Stop()
+	shutdown.Flush(outPort, exhaustedPort, heartbeatPort, logPort)
+	zmq.Term()
+}
+
+func main() {
+	flag.Parse()
+
+	if *jsonFlag {
+		doc, _ := registryEntry.JSON()
+		fmt.Println(string(doc))
+		os.Exit(0)
+	}
+
+	log.SetFlags(0)
+	if *debug {
+		logger.SetLevel(httputils.LevelDebug)
+		logger.SetOutput(os.Stdout)
+	}
+	log.SetOutput(logger.Writer())
+
+	validateArgs()
+
+	shutdown = httputils.NewShutdown(logger)
+	openPorts()
+
+	if *metricsAddr != "" {
+		err = metrics.Serve(*metricsAddr)
+		utils.AssertError(err)
+	}
+
+	err = runtime.SetupShutdownByDisconnect(primaryPort, "http/retrybudget.primary", shutdown.Signals())
+	utils.AssertError(err)
+
+	// Wait for the configuration on the options port
+	options := &Options{Ratio: 0.2, MinRetries: 10, Window: "10s"}
+	for optionsPort != nil {
+		log.Println("Waiting for configuration...")
+		ip, err := shutdown.Receive(optionsPort)
+		if err == httputils.ErrShutdown {
+			shutdown.Exit(closePorts)
+		}
+		if err != nil {
+			logger.Error("Error receiving IP", "error", err)
+			continue
+		}
+		if !runtime.IsValidIP(ip) || !runtime.IsPacket(ip) {
+			continue
+		}
+		if err = json.Unmarshal(ip[1], options); err != nil {
+			logger.Error("Failed to unmarshal options", "error", err)
+			continue
+		}
+		optionsPort.Close()
+		optionsPort = nil
+	}
+
+	window, err := time.ParseDuration(options.Window)
+	if err == nil && window <= 0 {
+		err = fmt.Errorf("window must be positive")
+	}
+	utils.AssertError(err)
+
+	budget := NewBudget(options.Ratio, options.MinRetries, window)
+
+	poller := zmq.NewPoller()
+	poller.Add(primaryPort, zmq.POLLIN)
+	poller.Add(retryPort, zmq.POLLIN)
+
+	// Main loop
+	for !shutdown.Stopping() {
+		sockets, err := poller.Poll(shutdown.PollInterval)
+		if err != nil {
+			logger.Error("Error polling ports", "error", err)
+			continue
+		}
+		for _, socket := range sockets {
+			ip, err := socket.Socket.RecvMessageBytes(0)
+			if err != nil {
+				logger.Error("Error receiving message", "error", err)
+				continue
+			}
+			liveness.Inc()
+			if !httputils.IsValidIP(ip) {
+				logger.Warn("Received invalid IP")
+				continue
+			}
+
+			now := time.Now()
+			switch socket.Socket {
+			case primaryPort:
+				budget.Primary(now)
+				outPort.SendMessage(ip)
+
+			case retryPort:
+				allowed, event := budget.Retry(now)
+				if event != nil && exhaustedPort != nil {
+					data, _ := json.Marshal(event)
+					exhaustedPort.SendMessageDontwait(runtime.NewPacket(data))
+				}
+				if !allowed {
+					logger.Warn("Retry budget exhausted, dropping retry")
+					continue
+				}
+				outPort.SendMessage(ip)
+			}
+		}
+	}
+	shutdown.Exit(closePorts)
+}
@@ -5,13 +5,29 @@ import "github.com/cascades-fbp/cascades/library"
 var registryEntry = &library.Entry{
 	Description: `Matches a URI and method from incoming JSON requests from http/server and forwards it either
 to matching or failing output ports. Each PATTERN[index] port is routed to the corresponding SUCCESS[index]
-or a single FAIL output port.`,
+or a single FAIL output port. Options sent to the CONFIG port, or read from the -config file on SIGHUP,
+are applied without restart; router.patterns in them replace all routes. Forwarded requests carry the
+matched pattern and its metadata from router.metadata (timeout, auth-required, rate-class and labels)
+in their route field for downstream components enforcing per-route policies. Routing spans of traced
+requests are exported with -otlp flag.`,
 	Elementary: true,
 	Inports: []library.EntryPort{
+		library.EntryPort{
+			Name:        "OPTIONS",
+			Type:        "json",
+			Description: `Optional configuration port for options JSON, i.e. {"router": {"method_not_allowed": false}, "backpressure": {"hwm": 100, "overflow": "block"}}`,
+			Required:    false,
+		},
+		library.EntryPort{
+			Name:        "CONFIG",
+			Type:        "json",
+			Description: `Optional port for options JSON applied at runtime, i.e. {"router": {"patterns": ["GET /users/:id", "POST /users"], "metadata": {"POST /users": {"timeout": "5s", "auth-required": true, "rate-class": "write", "labels": {"team": "accounts"}}}}}`,
+			Required:    false,
+		},
 		library.EntryPort{
 			Name:        "PATTERN",
 			Type:        "string",
-			Description: "Input array port for matching pattern configuration",
+			Description: "Input array port for matching pattern configuration in the format \"<METHOD> <path>\", i.e. \"GET /users/:id\"",
 			Required:    true,
 			Addressable: true,
 		},
@@ -36,5 +52,23 @@ or a single FAIL output port.`,
 			Description: "Output port for emitting responses when URI/method didn't match",
 			Required:    true,
 		},
+		library.EntryPort{
+			Name:        "ERR",
+			Type:        "json",
+			Description: "Optional error port for invalid requests and patterns (error JSON object defined in utils of HTTP components library)",
+			Required:    false,
+		},
+		library.EntryPort{
+			Name:        "LOG",
+			Type:        "json",
+			Description: "Optional output port for structured log entries (log entry JSON object defined in utils of HTTP components library)",
+			Required:    false,
+		},
+		library.EntryPort{
+			Name:        "HEARTBEAT",
+			Type:        "json",
+			Description: "Optional output port for periodic liveness IPs (heartbeat JSON object defined in utils of HTTP components library)",
+			Required:    false,
+		},
 	},
 }
@@ -3,10 +3,8 @@ package main
 import (
 	"flag"
 	"fmt"
-	"io/ioutil"
 	"log"
 	"net/http"
-	"net/url"
 	"os"
 	"strings"
 
@@ -18,21 +16,37 @@ import (
 
 var (
 	// Flags
-	routesEndpoint  = flag.String("port.routes", "", "Component's input port endpoint")
-	requestEndpoint = flag.String("port.request", "", "Component's input port endpoint")
-	successEndpoint = flag.String("port.success", "", "Component's output port endpoint")
-	failEndpoint    = flag.String("port.fail", "", "Component's output port endpoint")
-	jsonFlag        = flag.Bool("json", false, "Print component documentation in JSON")
-	debug           = flag.Bool("debug", false, "Enable debug mode")
+	optionsEndpoint   = flag.String("port.options", "", "Component's options port endpoint")
+	configEndpoint    = flag.String("port.config", "", "Component's configuration reload port endpoint")
+	patternEndpoint   = flag.String("port.pattern", "", "Component's pattern array port endpoints (comma separated)")
+	requestEndpoint   = flag.String("port.request", "", "Component's input port endpoint")
+	successEndpoint   = flag.String("port.success", "", "Component's output array port endpoints (comma separated)")
+	failEndpoint      = flag.String("port.fail", "", "Component's output port endpoint")
+	errorEndpoint     = flag.String("port.err", "", "Component's error port endpoint")
+	logEndpoint       = flag.String("port.log", "", "Component's log port endpoint")
+	heartbeatEndpoint = flag.String("port.heartbeat", "", "Component's heartbeat port endpoint")
+	metricsAddr       = flag.String("metrics", "", "Address to expose metrics on, i.e. 127.0.0.1:9090 (disabled if empty)")
+	configFile        = flag.String("config", "", "Options JSON file re-applied on SIGHUP")
+	otlpEndpoint      = flag.String("otlp", "", "OTLP/HTTP endpoint to export trace spans to, i.e. http://127.0.0.1:4318/v1/traces (disabled if empty)")
+	jsonFlag          = flag.Bool("json", false, "Print component documentation in JSON")
+	debug             = flag.Bool("debug", false, "Enable debug mode")
 
 	// Internal
-	routesPort, requestPort, failPort *zmq.Socket
-	successPorts                      map[string]*zmq.Socket
-	err                               error
+	optionsPort, configPort, requestPort, failPort, errPort, logPort, heartbeatPort *zmq.Socket
+	patternPorts, successPorts                                                      []*zmq.Socket
+	failOutlet                                                                      *httputils.Outlet
+	successOutlets                                                                  []*httputils.Outlet
+	err                                                                             error
+	logger                                                                          = httputils.NewLogger("http/router")
+	liveness                                                                        = httputils.NewLiveness("http/router")
+	metrics                                                                         = httputils.NewMetrics(logger, liveness)
+	shutdown                                                                        *httputils.Shutdown
+	tracer                                                                          *httputils.Tracer
 )
 
+// validateArgs checks all required flags
 func validateArgs() {
-	if *routesEndpoint == "" {
+	if *patternEndpoint == "" {
 		flag.Usage()
 		os.Exit(1)
 	}
@@ -49,42 +63,81 @@ func validateArgs() {
 		os.Exit(1)
 	}
 
+	patterns := strings.Split(*patternEndpoint, ",")
 	successes := strings.Split(*successEndpoint, ",")
-	if len(successes) == 0 {
-		fmt.Println("ERROR: invalid length of the SUCCESS array port!")
+	if len(patterns) != len(successes) {
+		fmt.Println("ERROR: PATTERN and SUCCESS array ports must have the same length!")
 		flag.Usage()
 		os.Exit(1)
 	}
 }
 
+// openPorts create ZMQ sockets
 func openPorts() {
-	requestPort, err = utils.CreateInputPort(*requestEndpoint)
+	if *optionsEndpoint != "" {
+		optionsPort, err = utils.CreateInputPort("http/router.options", *optionsEndpoint, nil)
+		utils.AssertError(err)
+	}
+
+	if *configEndpoint != "" {
+		configPort, err = utils.CreateInputPort("http/router.config", *configEndpoint, nil)
+		utils.AssertError(err)
+	}
+
+	requestPort, err = utils.CreateInputPort("http/router.request", *requestEndpoint, nil)
 	utils.AssertError(err)
 
-	failPort, err = utils.CreateOutputPort(*failEndpoint)
+	var port *zmq.Socket
+	for i, endpoint := range strings.Split(*patternEndpoint, ",") {
+		port, err = utils.CreateInputPort(fmt.Sprintf("http/router.pattern[%v]", i), strings.TrimSpace(endpoint), nil)
+		utils.AssertError(err)
+		patternPorts = append(patternPorts, port)
+	}
+	for i, endpoint := range strings.Split(*successEndpoint, ",") {
+		port, err = utils.CreateOutputPort(fmt.Sprintf("http/router.success[%v]", i), strings.TrimSpace(endpoint), nil)
+		utils.AssertError(err)
+		successPorts = append(successPorts, port)
+	}
+
+	failPort, err = utils.CreateOutputPort("http/router.fail", *failEndpoint, nil)
 	utils.AssertError(err)
 
-	successes := strings.Split(*successEndpoint, ",")
-	successPorts = make(map[string]*zmq.Socket, len(successes))
+	// Outlets use default queue size and policy until options arrive
+	defaults := &httputils.BackpressureOptions{}
+	for i, port := range successPorts {
+		name := fmt.Sprintf("http/router.success[%v]", i)
+		successOutlets = append(successOutlets, httputils.NewOutlet(name, port, defaults, reportOverflow(name)))
+	}
+	failOutlet = httputils.NewOutlet("http/router.fail", failPort, defaults, reportOverflow("http/router.fail"))
+	for _, outlet := range append(successOutlets, failOutlet) {
+		metrics.WatchOutlet(outlet)
+	}
 
-	var port *zmq.Socket
+	if *errorEndpoint != "" {
+		errPort, err = utils.CreateOutputPort("http/router.err", *errorEndpoint, nil)
+		utils.AssertError(err)
+	}
 
-	for i, endpoint := range patterns {
-		port, err = utils.CreateOutputPort(strings.TrimSpace(successes[i]))
+	if *logEndpoint != "" {
+		logPort, err = utils.CreateOutputPort("http/router.log", *logEndpoint, nil)
 		utils.AssertError(err)
-		successPorts
+		logger.SetSink(func(ip [][]byte) { logPort.SendMessageDontwait(ip) })
+	}
+
+	if *heartbeatEndpoint != "" {
+		heartbeatPort, err = utils.CreateOutputPort("http/router.heartbeat", *heartbeatEndpoint, nil)
+		utils.AssertError(err)
+		liveness.Start(func(ip [][]byte) { heartbeatPort.SendMessageDontwait(ip) })
 	}
 }
 
+// closePorts closes all active ports and terminates ZMQ context
 func closePorts() {
-	requestPort.Close()
-	failPort.Close()
-	for _, p := range patternPorts {
-		p.Close()
-	}
-	for _, p := range successPorts {
-		p.Close()
-	}
+	shutdown.Close(append(patternPorts, optionsPort, configPort, requestPort)...)
+	shutdown.Drain(append(successOutlets, failOutlet)...)
+	liveness.Stop()
+	tracer.Stop()
+	shutdown.Flush(append(successPorts, failPort, errPort, heartbeatPort, logPort)...)
 	zmq.Term()
 }
 
@@ -99,134 +152,252 @@ func main() {
 
 	log.SetFlags(0)
 	if *debug {
-		log.SetOutput(os.Stdout)
-	} else {
-		log.SetOutput(ioutil.Discard)
+		logger.SetLevel(httputils.LevelDebug)
+		logger.SetOutput(os.Stdout)
 	}
+	log.SetOutput(logger.Writer())
 
 	validateArgs()
 
+	shutdown = httputils.NewShutdown(logger)
+	tracer = httputils.NewTracer("http/router", *otlpEndpoint, logger)
+	tracer.Start()
 	openPorts()
-	defer closePorts()
 
-	poller.Add(requestPort, zmq.POLLIN)
+	if *metricsAddr != "" {
+		err = metrics.Serve(*metricsAddr)
+		utils.AssertError(err)
+	}
 
-	exitCh := utils.HandleInterruption()
-	err = runtime.SetupShutdownByDisconnect(requestPort, "http-router.in", exitCh)
+	err = runtime.SetupShutdownByDisconnect(requestPort, "http/router.request", shutdown.Signals())
 	utils.AssertError(err)
 
+	router := NewRouter()
+
+	// Wait for the configuration on the options port
+	for optionsPort != nil {
+		log.Println("Waiting for configuration...")
+		ip, err := shutdown.Receive(optionsPort)
+		if err == httputils.ErrShutdown {
+			shutdown.Exit(closePorts)
+		}
+		if err != nil {
+			logger.Error("Error receiving IP", "error", err)
+			continue
+		}
+		if !runtime.IsValidIP(ip) || !runtime.IsPacket(ip) {
+			continue
+		}
+		r, err := applyOptions(ip[1])
+		if err != nil {
+			logger.Error("Failed to parse options", "error", err)
+			sendError(httputils.NewError("http/router", httputils.ErrInvalidIP, err))
+			continue
+		}
+		if r != nil {
+			router = r
+		}
+		optionsPort.Close()
+		optionsPort = nil
+	}
+
+	poller := zmq.NewPoller()
+	poller.Add(requestPort, zmq.POLLIN)
+	for _, p := range patternPorts {
+		poller.Add(p, zmq.POLLIN)
+	}
+	if configPort != nil {
+		poller.Add(configPort, zmq.POLLIN)
+	}
+	reloads := httputils.WatchConfigFile(*configFile, logger)
+
 	// Main loop
-	var (
-		index       int = -1
-		outputIndex int = -1
-		params      url.Values
-		ip          [][]byte
-		pLength     int     = len(pollItems)
-		router      *Router = NewRouter()
-	)
-	for {
-		// Poll sockets
-		log.Println("Polling sockets...")
-
-		sockets, err := poller.Poll(-1)
+	for !shutdown.Stopping() {
+		select {
+		case data := <-reloads:
+			router = reload(router, data)
+		default:
+		}
+
+		sockets, err := poller.Poll(shutdown.PollInterval)
 		if err != nil {
-			log.Println("Error polling ports:", err.Error())
-			os.Exit(1)
+			logger.Error("Error polling ports", "error", err)
+			continue
 		}
 
-		for index, s := range sockets {
-			ip, err = s.Socket.RecvMessageBytes(0)
+		for _, socket := range sockets {
+			ip, err := socket.Socket.RecvMessageBytes(0)
 			if err != nil {
-				log.Printf("Failed to receive data. Error: %s", err.Error())
+				logger.Error("Error receiving message", "error", err)
 				continue
 			}
-			if !runtime.IsValidIP(ip) {
-				log.Println("Received invalid IP")
+			liveness.Inc()
+			if !httputils.IsValidIP(ip) {
+				logger.Warn("Received invalid IP")
 				continue
 			}
-		}
-
-		// Pattern arrived
 
-		if index < pLength-1 {
-			// Close pattern socket
-			port = pollItems[index].Socket
-			port.Close()
+			if socket.Socket == requestPort {
+				route(router, ip)
+				continue
+			}
+			if socket.Socket == configPort {
+				router = reload(router, ip[1])
+				continue
+			}
 
-			// Resolve corresponding output socket index
-			outputIndex = -1
-			for i, s := range patternPorts {
-				if s == port {
-					outputIndex = i
+			// Pattern arrived: register it and stop listening on its port
+			index := -1
+			for i, p := range patternPorts {
+				if p == socket.Socket {
+					index = i
 				}
 			}
-			if outputIndex == -1 {
-				log.Printf("Failed to resolve output socket index")
+			if index == -1 {
+				logger.Error("Failed to resolve pattern port index")
 				continue
 			}
+			poller.RemoveBySocket(socket.Socket)
+			socket.Socket.Close()
+			patternPorts[index] = nil
 
-			// Remove closed socket from polling items
-			pollItems = append(pollItems[:index], pollItems[index+1:]...)
-			pLength -= 1
-
-			// Add pattern to router
-			parts := strings.Split(string(ip[1]), " ")
-			method := strings.ToUpper(strings.TrimSpace(parts[0]))
-			pattern := strings.TrimSpace(parts[1])
-			switch method {
-			case "GET":
-				router.Get(pattern, outputIndex)
-			case "POST":
-				router.Post(pattern, outputIndex)
-			case "PUT":
-				router.Put(pattern, outputIndex)
-			case "DELETE":
-				router.Del(pattern, outputIndex)
-			case "HEAD":
-				router.Head(pattern, outputIndex)
-			case "OPTIONS":
-				router.Options(pattern, outputIndex)
-			default:
-				log.Printf("Unsupported HTTP method %s in pattern %s", method, pattern)
+			if err = addPattern(router, string(ip[1]), index); err != nil {
+				logger.Warn("Invalid pattern", "index", index, "error", err)
+				sendError(httputils.NewError("http/router", httputils.ErrInvalidIP, err))
 			}
-			continue
 		}
+	}
+	shutdown.Exit(closePorts)
+}
 
-		// Request arrive
-		req, err := httputils.IP2Request(ip)
-		if err != nil {
-			log.Printf("Failed to convert IP to request. Error: %s", err.Error())
-			continue
-		}
+// reload applies options received at runtime returning the router to use
+func reload(router *Router, payload []byte) *Router {
+	r, err := applyOptions(payload)
+	if err != nil {
+		logger.Error("Failed to reload configuration", "error", err)
+		sendError(httputils.NewError("http/router", httputils.ErrInvalidIP, err))
+		return router
+	}
+	logger.Info("Configuration reloaded")
+	if r == nil {
+		return router
+	}
+	return r
+}
 
-		outputIndex, params = router.Route(req.Method, req.URI)
-		log.Printf("Output index for %s %s: %v (params=%#v)", req.Method, req.URI, outputIndex, params)
+// addPattern registers a pattern in the format "<METHOD> <path>" for a given output
+func addPattern(router *Router, data string, outputIndex int) error {
+	normalized, err := normalizePattern(data)
+	if err != nil {
+		return err
+	}
+	parts := strings.Fields(normalized)
+	method := parts[0]
+	pattern := parts[1]
+	switch method {
+	case "GET":
+		router.Get(pattern, outputIndex)
+	case "POST":
+		router.Post(pattern, outputIndex)
+	case "PUT":
+		router.Put(pattern, outputIndex)
+	case "DELETE":
+		router.Del(pattern, outputIndex)
+	case "HEAD":
+		router.Head(pattern, outputIndex)
+	case "OPTIONS":
+		router.Options(pattern, outputIndex)
+	default:
+		return fmt.Errorf("unsupported HTTP method %s in pattern %s", method, pattern)
+	}
+	router.patterns[outputIndex] = normalized
+	logger.Info("Registered pattern", "method", method, "pattern", pattern, "output", outputIndex)
+	return nil
+}
 
-		switch outputIndex {
-		case NotFound:
-			log.Println("Sending Not Found response to FAIL output")
-			resp := &httputils.HTTPResponse{
-				Id:         req.Id,
-				StatusCode: http.StatusNotFound,
-			}
-			ip, _ = httputils.Response2IP(resp)
-			failPort.SendMultipart(ip, 0)
-		case MethodNotAllowed:
-			log.Println("Sending Method Not Allowed response to FAIL output")
-			resp := &httputils.HTTPResponse{
-				Id:         req.Id,
-				StatusCode: http.StatusMethodNotAllowed,
-			}
-			ip, _ = httputils.Response2IP(resp)
-			failPort.SendMultipart(ip, 0)
-		default:
-			for k, values := range params {
-				req.Form[k] = values
-			}
-			ip, _ = httputils.Request2IP(req)
-			successPorts[outputIndex].SendMultipart(ip, 0)
+// normalizePattern checks the format of a pattern and upper-cases its method
+func normalizePattern(data string) (string, error) {
+	parts := strings.Fields(data)
+	if len(parts) != 2 {
+		return "", fmt.Errorf("pattern %q is not in the format \"<METHOD> <path>\"", data)
+	}
+	return strings.ToUpper(parts[0]) + " " + parts[1], nil
+}
+
+// routeInfo returns the matched route of an output with its configured metadata
+func routeInfo(router *Router, outputIndex int) *httputils.RouteInfo {
+	pattern, ok := router.patterns[outputIndex]
+	if !ok {
+		return nil
+	}
+	info := routeMetadata[pattern]
+	info.Pattern = pattern
+	return &info
+}
+
+// route forwards a request IP to the matching SUCCESS output or responds on FAIL
+func route(router *Router, ip [][]byte) {
+	req, err := httputils.IP2Request(ip)
+	if err != nil {
+		logger.Warn("Failed to convert IP to request", "error", err)
+		sendError(httputils.NewError("http/router", httputils.ErrInvalidIP, err))
+		return
+	}
+
+	span := tracer.StartSpan("route", req.Trace, httputils.SpanInternal)
+	defer span.End()
+	span.SetAttribute("cascades.request_id", req.ID)
+	req.Trace = span.Traceparent()
+
+	outputIndex, params := router.Route(req.Method, req.URI)
+	if outputIndex == MethodNotAllowed && !methodNotAllowed {
+		outputIndex = NotFound
+	}
+	log.Printf("Output index for %s %s: %v (params=%#v)", req.Method, req.URI, outputIndex, params)
+	span.SetAttribute("cascades.output", outputIndex)
+
+	switch outputIndex {
+	case NotFound:
+		log.Println("Sending Not Found response to FAIL output")
+		failOutlet.Send(httputils.NewResponse(http.StatusNotFound).WithID(req.ID).WithTrace(req.Trace).MustIP())
+	case MethodNotAllowed:
+		log.Println("Sending Method Not Allowed response to FAIL output")
+		failOutlet.Send(httputils.NewResponse(http.StatusMethodNotAllowed).WithID(req.ID).WithTrace(req.Trace).MustIP())
+	default:
+		if req.Form == nil {
+			req.Form = make(map[string][]string)
+		}
+		for k, values := range params {
+			req.Form[k] = values
 		}
+		req.Route = routeInfo(router, outputIndex)
+		ip, err = httputils.Request2IP(req)
+		if err != nil {
+			logger.Error("Failed to convert request to IP", "error", err)
+			span.SetError(err)
+			sendError(httputils.NewError("http/router", httputils.ErrInternal, err).WithRequest(req.ID))
+			return
+		}
+		successOutlets[outputIndex].Send(ip)
+	}
+}
+
+// sendError reports a failure to the ERR port if it's connected
+func sendError(e *httputils.Error) {
+	if errPort == nil {
+		return
+	}
+	ip, err := httputils.Error2IP(e)
+	if err != nil {
+		return
+	}
+	errPort.SendMessageDontwait(ip)
+}
 
-		index = -1
+// reportOverflow returns a drop handler of a given output reporting to the ERR port
+func reportOverflow(port string) func(ip [][]byte) {
+	return func(ip [][]byte) {
+		logger.Warn("Output queue is full, IP dropped", "port", port)
+		sendError(httputils.NewError("http/router", httputils.ErrOverflow, fmt.Errorf("queue of %s is full", port)))
 	}
 }
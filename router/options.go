@@ -0,0 +1,102 @@
+package main
+
+import (
+	"fmt"
+
+	httputils "github.com/cascades-fbp/cascades-http/utils"
+)
+
+// Section describes router specific section of the options IP
+type Section struct {
+	MethodNotAllowed *bool                          `json:"method_not_allowed"` // Respond with 405 when only method didn't match (default true)
+	Patterns         []string                       `json:"patterns"`           // Patterns replacing all routes, i-th one for SUCCESS[i] ("" for none)
+	Metadata         map[string]httputils.RouteInfo `json:"metadata"`           // Metadata copied into requests by pattern, replaces the previous one
+}
+
+var (
+	// methodNotAllowed is disabled to respond with 404 on method mismatch
+	methodNotAllowed = true
+
+	// routeMetadata is attached to requests matching its normalized pattern
+	routeMetadata = make(map[string]httputils.RouteInfo)
+)
+
+// applyOptions configures the router from the options IP payload. When the
+// router section lists patterns, a new router with only these routes is
+// returned to replace the current one, otherwise the result is nil. Invalid
+// patterns leave the current routes in place.
+func applyOptions(payload []byte) (*Router, error) {
+	options, err := httputils.ParseOptions(payload)
+	if err != nil {
+		return nil, err
+	}
+	var section Section
+	if err = httputils.DecodeSection(options.Router, &section); err != nil {
+		return nil, err
+	}
+	if err = options.Backpressure.Validate(); err != nil {
+		return nil, err
+	}
+	var metadata map[string]httputils.RouteInfo
+	if section.Metadata != nil {
+		if metadata, err = buildMetadata(section.Metadata); err != nil {
+			return nil, err
+		}
+	}
+	var router *Router
+	if section.Patterns != nil {
+		if router, err = buildRouter(section.Patterns); err != nil {
+			return nil, err
+		}
+	}
+	for _, outlet := range append(successOutlets, failOutlet) {
+		outlet.Apply(&options.Backpressure)
+	}
+	if section.MethodNotAllowed != nil {
+		methodNotAllowed = *section.MethodNotAllowed
+	}
+	if metadata != nil {
+		routeMetadata = metadata
+	}
+	httputils.Redaction.Apply(&options.Redact)
+	if err = options.Logging.Apply(logger); err != nil {
+		return nil, err
+	}
+	return router, nil
+}
+
+// buildRouter creates a router from patterns indexed by SUCCESS outputs
+func buildRouter(patterns []string) (*Router, error) {
+	if len(patterns) > len(successPorts) {
+		return nil, fmt.Errorf("%d patterns for %d SUCCESS outputs", len(patterns), len(successPorts))
+	}
+	router := NewRouter()
+	for i, pattern := range patterns {
+		if pattern == "" {
+			continue
+		}
+		if err := addPattern(router, pattern, i); err != nil {
+			return nil, err
+		}
+	}
+	return router, nil
+}
+
+// buildMetadata validates route metadata and keys it by normalized patterns
+func buildMetadata(section map[string]httputils.RouteInfo) (map[string]httputils.RouteInfo, error) {
+	metadata := make(map[string]httputils.RouteInfo, len(section))
+	for pattern, info := range section {
+		normalized, err := normalizePattern(pattern)
+		if err != nil {
+			return nil, err
+		}
+		if info.Timeout < 0 {
+			return nil, fmt.Errorf("negative timeout of pattern %s", pattern)
+		}
+		if _, ok := metadata[normalized]; ok {
+			return nil, fmt.Errorf("duplicate metadata of pattern %s", normalized)
+		}
+		metadata[normalized] = info
+	}
+	return metadata, nil
+}
@@ -10,12 +10,13 @@ const (
 )
 
 type Router struct {
-	outputs map[string][]*Output
+	outputs  map[string][]*Output
+	patterns map[int]string // "<METHOD> <path>" registered for every output index
 }
 
 // New returns a new Router.
 func NewRouter() *Router {
-	return &Router{make(map[string][]*Output)}
+	return &Router{make(map[string][]*Output), make(map[int]string)}
 }
 
 // Looks up the router and returns the output port index or -1 for not found,
@@ -0,0 +1,250 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/cascades-fbp/cascades-http/testutils"
+	httputils "github.com/cascades-fbp/cascades-http/utils"
+	"github.com/cascades-fbp/cascades/runtime"
+	zmq "github.com/pebbe/zmq4"
+)
+
+// readyTimeout is how long a test waits for the router to start routing
+const readyTimeout = 30 * time.Second
+
+// testRoute is a pattern of a PATTERN port with a path it matches
+type testRoute struct {
+	pattern string
+	probe   string
+}
+
+var testRoutes = []testRoute{
+	{"GET /users", "/users"},
+	{"GET /users/:id", "/users/1"},
+	{"POST /users", "/users"},
+	{"DELETE /users/:id", "/users/1"},
+	{"GET /posts/:id/comments", "/posts/1/comments"},
+}
+
+// routerGraph is a running router with sockets of its ports
+type routerGraph struct {
+	in        *zmq.Socket
+	successes []*zmq.Socket
+	fail      *zmq.Socket
+}
+
+// startRouter runs the router with given routes and waits until all of them
+// are matched
+func startRouter(t testing.TB, routes []testRoute) *routerGraph {
+	t.Helper()
+	g := testutils.NewGraph(t)
+	rg := &routerGraph{}
+
+	patterns := make([]string, len(routes))
+	successes := make([]string, len(routes))
+	for i := range routes {
+		patterns[i] = g.Endpoint(fmt.Sprintf("pattern%d", i))
+		successes[i] = g.Endpoint(fmt.Sprintf("success%d", i))
+		rg.successes = append(rg.successes, g.Output(successes[i]))
+	}
+	rg.fail = g.Output(g.Endpoint("fail"))
+
+	g.Start("router", map[string]string{
+		"pattern": strings.Join(patterns, ","),
+		"success": strings.Join(successes, ","),
+		"request": g.Endpoint("request"),
+		"fail":    g.Endpoint("fail"),
+	})
+	for i, r := range routes {
+		g.Inject(patterns[i], runtime.NewPacket([]byte(r.pattern)))
+	}
+	rg.in = g.Input(g.Endpoint("request"))
+
+	// Patterns may arrive after the first requests, probe routes until they're matched
+	for i, r := range routes {
+		probe := testutils.GetRequest(r.probe)
+		probe.Method = strings.Fields(r.pattern)[0]
+		rg.await(t, probe.MustIP(), rg.successes[i])
+	}
+	rg.drain(t)
+	return rg
+}
+
+// await sends an IP until it comes out of a given output
+func (rg *routerGraph) await(t testing.TB, ip [][]byte, output *zmq.Socket) {
+	t.Helper()
+	poller := rg.poller()
+	deadline := time.Now().Add(readyTimeout)
+	for ready := false; !ready; {
+		if time.Now().After(deadline) {
+			t.Fatalf("Router didn't route requests in %v", readyTimeout)
+		}
+		if _, err := rg.in.SendMessage(ip); err != nil {
+			t.Fatalf("Failed to send request: %v", err)
+		}
+		sockets, err := poller.Poll(100 * time.Millisecond)
+		if err != nil {
+			t.Fatalf("Failed to poll outputs: %v", err)
+		}
+		for _, s := range sockets {
+			s.Socket.RecvMessageBytes(0)
+			ready = ready || s.Socket == output
+		}
+	}
+}
+
+// drain discards IPs of probes still in flight
+func (rg *routerGraph) drain(t testing.TB) {
+	t.Helper()
+	poller := rg.poller()
+	for {
+		sockets, err := poller.Poll(200 * time.Millisecond)
+		if err != nil {
+			t.Fatalf("Failed to poll outputs: %v", err)
+		}
+		if len(sockets) == 0 {
+			return
+		}
+		for _, s := range sockets {
+			s.Socket.RecvMessageBytes(0)
+		}
+	}
+}
+
+func (rg *routerGraph) poller() *zmq.Poller {
+	poller := zmq.NewPoller()
+	for _, s := range rg.successes {
+		poller.Add(s, zmq.POLLIN)
+	}
+	poller.Add(rg.fail, zmq.POLLIN)
+	return poller
+}
+
+// serverRequest sends a request to a test server and returns it as the server
+// component would emit it
+func serverRequest(t testing.TB, method, path string) *httputils.HTTPRequest {
+	t.Helper()
+	requests := make(chan *httputils.HTTPRequest, 1)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		req, err := httputils.Request2Request(r)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		requests <- req
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer srv.Close()
+
+	request, err := http.NewRequest(method, srv.URL+path, nil)
+	if err != nil {
+		t.Fatalf("Failed to create request: %v", err)
+	}
+	response, err := srv.Client().Do(request)
+	if err != nil {
+		t.Fatalf("Failed to send request: %v", err)
+	}
+	response.Body.Close()
+	if response.StatusCode != http.StatusNoContent {
+		t.Fatalf("Test server responded with %d", response.StatusCode)
+	}
+	return (<-requests).WithID(testutils.FixtureID)
+}
+
+func TestRouterSuccess(t *testing.T) {
+	if testing.Short() {
+		t.Skip("runs the router binary")
+	}
+	rg := startRouter(t, testRoutes)
+
+	tests := []struct {
+		method  string
+		path    string
+		output  int
+		pattern string
+		form    map[string]string
+	}{
+		{"GET", "/users", 0, "GET /users", nil},
+		{"GET", "/users/42", 1, "GET /users/:id", map[string]string{":id": "42"}},
+		{"POST", "/users", 2, "POST /users", nil},
+		{"DELETE", "/users/7", 3, "DELETE /users/:id", map[string]string{":id": "7"}},
+		{"GET", "/posts/5/comments", 4, "GET /posts/:id/comments", map[string]string{":id": "5"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.method+" "+tt.path, func(t *testing.T) {
+			if _, err := rg.in.SendMessage(serverRequest(t, tt.method, tt.path).MustIP()); err != nil {
+				t.Fatalf("Failed to send request: %v", err)
+			}
+			req, err := httputils.IP2Request(testutils.Receive(t, rg.successes[tt.output], readyTimeout))
+			if err != nil {
+				t.Fatalf("IP2Request() error = %v", err)
+			}
+			if req.ID != testutils.FixtureID || req.Method != tt.method || req.URI != tt.path {
+				t.Errorf("routed request = %s %s (id %s)", req.Method, req.URI, req.ID)
+			}
+			if req.Route == nil || req.Route.Pattern != tt.pattern {
+				t.Errorf("route = %+v, want pattern %q", req.Route, tt.pattern)
+			}
+			for k, v := range tt.form {
+				if got := req.Form[k]; len(got) != 1 || got[0] != v {
+					t.Errorf("form[%s] = %v, want %q", k, got, v)
+				}
+			}
+		})
+	}
+}
+
+func TestRouterFail(t *testing.T) {
+	if testing.Short() {
+		t.Skip("runs the router binary")
+	}
+	rg := startRouter(t, testRoutes)
+
+	tests := []struct {
+		method string
+		path   string
+		status int
+	}{
+		{"GET", "/posts", http.StatusNotFound},
+		{"GET", "/users/1/friends", http.StatusNotFound},
+		{"PUT", "/users/1", http.StatusMethodNotAllowed},
+	}
+	for _, tt := range tests {
+		t.Run(tt.method+" "+tt.path, func(t *testing.T) {
+			if _, err := rg.in.SendMessage(serverRequest(t, tt.method, tt.path).MustIP()); err != nil {
+				t.Fatalf("Failed to send request: %v", err)
+			}
+			resp, err := httputils.IP2Response(testutils.Receive(t, rg.fail, readyTimeout))
+			if err != nil {
+				t.Fatalf("IP2Response() error = %v", err)
+			}
+			if resp.ID != testutils.FixtureID || resp.StatusCode != tt.status {
+				t.Errorf("response = %d (id %s), want %d", resp.StatusCode, resp.ID, tt.status)
+			}
+		})
+	}
+}
+
+func BenchmarkRouter(b *testing.B) {
+	rg := startRouter(b, testRoutes)
+	last := len(testRoutes) - 1
+	ip := testutils.GetRequest("/posts/42/comments").MustIP()
+
+	b.ResetTimer()
+	go func() {
+		for i := 0; i < b.N; i++ {
+			if _, err := rg.in.SendMessage(ip); err != nil {
+				b.Error(err)
+				return
+			}
+		}
+	}()
+	for i := 0; i < b.N; i++ {
+		testutils.Receive(b, rg.successes[last], readyTimeout)
+	}
+}
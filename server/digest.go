@@ -0,0 +1,268 @@
+package main
+
+import (
+	"bufio"
+	"crypto/hmac"
+	"crypto/md5"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"hash"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	httputils "github.com/cascades-fbp/cascades-http/utils"
+)
+
+const (
+	defaultDigestRealm = "cascades"
+	defaultNonceTTL    = 5 * time.Minute
+)
+
+// DigestOptions configure Digest access authentication (RFC 7616) of the server
+type DigestOptions struct {
+	Realm      string             `json:"realm"`      // Protection space shown to users
+	Users      map[string]string  `json:"users"`      // Passwords by user name
+	UsersFile  string             `json:"users_file"` // htdigest style file of user:realm:HA1 lines (MD5 or SHA-256 HA1)
+	Algorithms []string           `json:"algorithms"` // Offered algorithms by preference, SHA-256 by default, MD5 for legacy clients
+	NonceTTL   httputils.Duration `json:"nonce_ttl"`  // Lifetime of nonces, stale nonces are renewed transparently
+}
+
+// digestAlgorithms are hash functions of supported algorithms
+var digestAlgorithms = map[string]func() hash.Hash{
+	"SHA-256": sha256.New,
+	"MD5":     md5.New,
+}
+
+// nonceSecret signs nonces, it's kept across reloads so issued nonces stay valid
+var nonceSecret = func() []byte {
+	b := make([]byte, 32)
+	rand.Read(b)
+	return b
+}()
+
+// nonceCounts holds the highest nonce count seen per nonce to reject replays
+var nonceCounts = &nonceCounter{counts: make(map[string]nonceCount)}
+
+// DigestAuth checks Digest credentials of requests
+type DigestAuth struct {
+	realm      string
+	algorithms []string
+	ha1        map[string]map[string]string // HA1 by algorithm and user name
+	ttl        time.Duration
+}
+
+// NewDigestAuth creates an authenticator from options
+func NewDigestAuth(o *DigestOptions) (*DigestAuth, error) {
+	a := &DigestAuth{
+		realm:      o.Realm,
+		algorithms: o.Algorithms,
+		ha1:        make(map[string]map[string]string),
+		ttl:        time.Duration(o.NonceTTL),
+	}
+	if a.realm == "" {
+		a.realm = defaultDigestRealm
+	}
+	if len(a.algorithms) == 0 {
+		a.algorithms = []string{"SHA-256"}
+	}
+	if a.ttl <= 0 {
+		a.ttl = defaultNonceTTL
+	}
+	for i, alg := range a.algorithms {
+		alg = strings.ToUpper(alg)
+		if digestAlgorithms[alg] == nil {
+			return nil, fmt.Errorf("unsupported digest algorithm %s", alg)
+		}
+		a.algorithms[i] = alg
+		a.ha1[alg] = make(map[string]string)
+	}
+	for user, password := range o.Users {
+		for _, alg := range a.algorithms {
+			a.ha1[alg][user] = digestHash(alg, user+":"+a.realm+":"+password)
+		}
+	}
+	if o.UsersFile != "" {
+		if err := a.loadUsers(o.UsersFile); err != nil {
+			return nil, err
+		}
+	}
+	return a, nil
+}
+
+// loadUsers reads HA1 of users in the realm, the algorithm is told by length
+func (a *DigestAuth) loadUsers(file string) error {
+	f, err := os.Open(file)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		parts := strings.Split(strings.TrimSpace(scanner.Text()), ":")
+		if len(parts) != 3 || parts[1] != a.realm {
+			continue
+		}
+		alg := "MD5"
+		if len(parts[2]) == 2*sha256.Size {
+			alg = "SHA-256"
+		}
+		if users, ok := a.ha1[alg]; ok {
+			users[parts[0]] = strings.ToLower(parts[2])
+		}
+	}
+	return scanner.Err()
+}
+
+// Authenticate returns the user name of valid credentials. Otherwise a 401
+// response with challenges is written and false is returned.
+func (a *DigestAuth) Authenticate(rw http.ResponseWriter, req *http.Request) (string, bool) {
+	user, stale, err := a.check(req)
+	if err == nil {
+		return user, true
+	}
+	logger.Debug("Digest authentication failed", "uri", req.RequestURI, "error", err)
+	nonce := a.nonce(time.Now())
+	for _, alg := range a.algorithms {
+		challenge := fmt.Sprintf(`Digest realm="%s", qop="auth", algorithm=%s, nonce="%s"`, a.realm, alg, nonce)
+		if stale {
+			challenge += ", stale=true"
+		}
+		rw.Header().Add("WWW-Authenticate", challenge)
+	}
+	rw.WriteHeader(http.StatusUnauthorized)
+	fmt.Fprint(rw, "Authentication required")
+	return "", false
+}
+
+// check verifies Authorization header, stale reports a valid response for
+// an expired nonce
+func (a *DigestAuth) check(req *http.Request) (user string, stale bool, err error) {
+	c, ok := httputils.FindChallenge(httputils.ParseChallenges(req.Header.Get("Authorization")), "Digest")
+	if !ok {
+		return "", false, fmt.Errorf("no digest credentials")
+	}
+	p := c.Params
+	alg := strings.ToUpper(p["algorithm"])
+	if alg == "" {
+		alg = "MD5"
+	}
+	users, ok := a.ha1[alg]
+	if !ok {
+		return "", false, fmt.Errorf("algorithm %s isn't offered", alg)
+	}
+	if p["realm"] != a.realm || p["qop"] != "auth" || p["nonce"] == "" || p["cnonce"] == "" {
+		return "", false, fmt.Errorf("invalid digest parameters")
+	}
+	if p["uri"] != req.RequestURI {
+		return "", false, fmt.Errorf("uri %q doesn't match the request", p["uri"])
+	}
+	nc, err := strconv.ParseUint(p["nc"], 16, 64)
+	if err != nil {
+		return "", false, fmt.Errorf("invalid nonce count")
+	}
+
+	user = p["username"]
+	if p["userhash"] == "true" {
+		for name := range users {
+			if digestHash(alg, name+":"+a.realm) == user {
+				user = name
+				break
+			}
+		}
+	}
+	ha1, ok := users[user]
+	if !ok {
+		return "", false, fmt.Errorf("unknown user %q", user)
+	}
+	ha2 := digestHash(alg, req.Method+":"+p["uri"])
+	expected := digestHash(alg, strings.Join([]string{ha1, p["nonce"], p["nc"], p["cnonce"], "auth", ha2}, ":"))
+	if subtle.ConstantTimeCompare([]byte(expected), []byte(strings.ToLower(p["response"]))) != 1 {
+		return "", false, fmt.Errorf("invalid response of user %q", user)
+	}
+
+	issued, ok := a.verifyNonce(p["nonce"])
+	if !ok {
+		return "", false, fmt.Errorf("invalid nonce")
+	}
+	now := time.Now()
+	if now.Sub(issued) > a.ttl {
+		return "", true, fmt.Errorf("nonce expired")
+	}
+	if !nonceCounts.use(p["nonce"], nc, issued.Add(a.ttl), now) {
+		return "", false, fmt.Errorf("nonce count %s was already used", p["nc"])
+	}
+	return user, false, nil
+}
+
+// nonce returns a nonce carrying its issue time signed with the secret
+func (a *DigestAuth) nonce(now time.Time) string {
+	ts := make([]byte, 8)
+	binary.BigEndian.PutUint64(ts, uint64(now.Unix()))
+	return hex.EncodeToString(ts) + hex.EncodeToString(a.sign(ts))
+}
+
+// verifyNonce checks the signature of a nonce returning its issue time
+func (a *DigestAuth) verifyNonce(nonce string) (time.Time, bool) {
+	b, err := hex.DecodeString(nonce)
+	if err != nil || len(b) != 8+sha256.Size {
+		return time.Time{}, false
+	}
+	if !hmac.Equal(b[8:], a.sign(b[:8])) {
+		return time.Time{}, false
+	}
+	return time.Unix(int64(binary.BigEndian.Uint64(b[:8])), 0), true
+}
+
+func (a *DigestAuth) sign(ts []byte) []byte {
+	mac := hmac.New(sha256.New, nonceSecret)
+	mac.Write(ts)
+	mac.Write([]byte(a.realm))
+	return mac.Sum(nil)
+}
+
+// digestHash returns lower case hex hash of a value with an algorithm
+func digestHash(alg, value string) string {
+	h := digestAlgorithms[alg]()
+	h.Write([]byte(value))
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// nonceCount is the highest count of a nonce and when it can be forgotten
+type nonceCount struct {
+	count   uint64
+	expires time.Time
+}
+
+// nonceCounter tracks nonce counts, expired nonces are removed
+type nonceCounter struct {
+	mu      sync.Mutex
+	counts  map[string]nonceCount
+	cleaned time.Time
+}
+
+// use records a nonce count, it fails if it's not higher than the last one
+func (c *nonceCounter) use(nonce string, count uint64, expires, now time.Time) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if now.Sub(c.cleaned) > time.Minute {
+		for n, nc := range c.counts {
+			if now.After(nc.expires) {
+				delete(c.counts, n)
+			}
+		}
+		c.cleaned = now
+	}
+	if last, ok := c.counts[nonce]; ok && count <= last.count {
+		return false
+	}
+	c.counts[nonce] = nonceCount{count: count, expires: expires}
+	return true
+}
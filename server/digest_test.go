@@ -0,0 +1,113 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+// digestResponse computes response of credentials as a client does
+func digestResponse(alg, user, password, realm, method, uri, nonce, nc, cnonce string) string {
+	ha1 := digestHash(alg, user+":"+realm+":"+password)
+	ha2 := digestHash(alg, method+":"+uri)
+	return digestHash(alg, strings.Join([]string{ha1, nonce, nc, cnonce, "auth", ha2}, ":"))
+}
+
+// digestRequest returns a GET request with Digest credentials
+func digestRequest(alg, user, password, realm, uri, nonce, nc string) *http.Request {
+	const cnonce = "f2/wE4q74E6zIJEtWaHKaf5wv/H5QzzpXusqGemxURZJ"
+	req := httptest.NewRequest("GET", uri, nil)
+	req.Header.Set("Authorization", fmt.Sprintf(
+		`Digest username="%s", realm="%s", uri="%s", algorithm=%s, nonce="%s", nc=%s, cnonce="%s", qop=auth, response="%s"`,
+		user, realm, uri, alg, nonce, nc, cnonce, digestResponse(alg, user, password, realm, "GET", uri, nonce, nc, cnonce)))
+	return req
+}
+
+// TestDigestResponse checks responses of the example of RFC 7616 section 3.9.1
+func TestDigestResponse(t *testing.T) {
+	tests := []struct {
+		alg  string
+		want string
+	}{
+		{"MD5", "8ca523f5e9506fed4657c9700eebdbec"},
+		{"SHA-256", "753927fa0e85d155564e2e272a28d1802ca10daf4496794697cf8db5856cb6c1"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.alg, func(t *testing.T) {
+			got := digestResponse(tt.alg, "Mufasa", "Circle of Life", "http-auth@example.org", "GET", "/dir/index.html",
+				"7ypf/xlj9XXwfDPEoM4URrv/xwf94BcCAzFZH4GiTo0v", "00000001", "f2/wE4q74E6zIJEtWaHKaf5wv/H5QzzpXusqGemxURZJ")
+			if got != tt.want {
+				t.Errorf("response = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDigestAuth(t *testing.T) {
+	const realm = "http-auth@example.org"
+	a, err := NewDigestAuth(&DigestOptions{
+		Realm:      realm,
+		Users:      map[string]string{"Mufasa": "Circle of Life"},
+		Algorithms: []string{"sha-256", "md5"},
+	})
+	if err != nil {
+		t.Fatalf("NewDigestAuth() error = %v", err)
+	}
+	nonce := a.nonce(time.Now())
+	stale := a.nonce(time.Now().Add(-defaultNonceTTL - time.Minute))
+	forged := strings.Repeat("0", len(nonce))
+
+	tests := []struct {
+		name  string
+		req   *http.Request
+		ok    bool
+		stale bool
+	}{
+		{"SHA-256", digestRequest("SHA-256", "Mufasa", "Circle of Life", realm, "/dir/index.html", nonce, "00000001"), true, false},
+		{"MD5", digestRequest("MD5", "Mufasa", "Circle of Life", realm, "/dir/index.html", nonce, "00000002"), true, false},
+		{"replayed nonce count", digestRequest("SHA-256", "Mufasa", "Circle of Life", realm, "/dir/index.html", nonce, "00000002"), false, false},
+		{"lower nonce count", digestRequest("SHA-256", "Mufasa", "Circle of Life", realm, "/dir/index.html", nonce, "00000001"), false, false},
+		{"next nonce count", digestRequest("SHA-256", "Mufasa", "Circle of Life", realm, "/dir/index.html", nonce, "00000003"), true, false},
+		{"stale nonce", digestRequest("SHA-256", "Mufasa", "Circle of Life", realm, "/dir/index.html", stale, "00000001"), false, true},
+		{"forged nonce", digestRequest("SHA-256", "Mufasa", "Circle of Life", realm, "/dir/index.html", forged, "00000001"), false, false},
+		{"wrong password", digestRequest("SHA-256", "Mufasa", "circle of life", realm, "/dir/index.html", nonce, "00000004"), false, false},
+		{"unknown user", digestRequest("SHA-256", "Simba", "Circle of Life", realm, "/dir/index.html", nonce, "00000005"), false, false},
+		{"other realm", digestRequest("SHA-256", "Mufasa", "Circle of Life", "other", "/dir/index.html", nonce, "00000006"), false, false},
+		{"no credentials", httptest.NewRequest("GET", "/dir/index.html", nil), false, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			user, stale, err := a.check(tt.req)
+			if (err == nil) != tt.ok || stale != tt.stale {
+				t.Fatalf("check() = %q, stale %v, error %v, want ok %v, stale %v", user, stale, err, tt.ok, tt.stale)
+			}
+			if tt.ok && user != "Mufasa" {
+				t.Errorf("check() user = %q, want Mufasa", user)
+			}
+		})
+	}
+}
+
+func TestDigestChallenge(t *testing.T) {
+	a, err := NewDigestAuth(&DigestOptions{Users: map[string]string{"u": "p"}, Algorithms: []string{"SHA-256", "MD5"}})
+	if err != nil {
+		t.Fatalf("NewDigestAuth() error = %v", err)
+	}
+	stale := a.nonce(time.Now().Add(-defaultNonceTTL - time.Minute))
+	rw := httptest.NewRecorder()
+	if _, ok := a.Authenticate(rw, digestRequest("SHA-256", "u", "p", defaultDigestRealm, "/", stale, "00000001")); ok {
+		t.Fatal("Authenticate() accepted a stale nonce")
+	}
+	challenges := rw.Result().Header.Values("WWW-Authenticate")
+	if rw.Code != http.StatusUnauthorized || len(challenges) != 2 {
+		t.Fatalf("Authenticate() = %d with challenges %q", rw.Code, challenges)
+	}
+	for i, alg := range []string{"SHA-256", "MD5"} {
+		if !strings.Contains(challenges[i], "algorithm="+alg) || !strings.HasSuffix(challenges[i], ", stale=true") {
+			t.Errorf("challenge %d = %q, want stale %s challenge", i, challenges[i], alg)
+		}
+	}
+}
@@ -3,15 +3,21 @@ package main
 import "github.com/cascades-fbp/cascades/library"
 
 var registryEntry = &library.Entry{
-	Description: "Create a HTTP server and binds to an address/port received from options",
+	Description: "Create a HTTP server and binds to an address/port received from options. Configuration sent to the CONFIG port, or read from the -config file on SIGHUP, is applied without restart except for address, connection timeouts and enabling TLS. Requests carry W3C trace context in trace field continuing traceparent header of the caller, spans are exported with -otlp flag. HTTPS requests include JA3/JA4 fingerprints of the client in tls field. With digest section in server options requests require Digest authentication (RFC 7616, SHA-256 by default) and carry the user name in user field",
 	Elementary:  true,
 	Inports: []library.EntryPort{
 		library.EntryPort{
 			Name:        "OPTIONS",
-			Type:        "string",
-			Description: "Configuration port to pass IP with TCP endpoint in the format i.e. 127.0.0.1:8080",
+			Type:        "json",
+			Description: `Configuration port to pass IP with TCP endpoint in the format i.e. 127.0.0.1:8080 or options JSON, i.e. {"server": {"addr": ":8443", "gzip": true}, "timeouts": {"request": "30s"}, "limits": {"max_body_size": 1048576}, "tls": {"cert_file": "cert.pem", "key_file": "key.pem"}, "backpressure": {"hwm": 100, "overflow": "drop-new"}} (options sent after start only switch dump section)`,
 			Required:    true,
 		},
+		library.EntryPort{
+			Name:        "CONFIG",
+			Type:        "json",
+			Description: `Optional port for options JSON reloaded at runtime, i.e. {"server": {"addr": ":8443"}, "limits": {"max_body_size": 2097152}, "tls": {"cert_file": "renewed.pem", "key_file": "renewed-key.pem"}}`,
+			Required:    false,
+		},
 		library.EntryPort{
 			Name:        "IN",
 			Type:        "json",
@@ -26,5 +32,29 @@ var registryEntry = &library.Entry{
 			Description: "Output port for emitting requests in predefined JSON format",
 			Required:    true,
 		},
+		library.EntryPort{
+			Name:        "ERR",
+			Type:        "json",
+			Description: "Optional error port for timeouts and invalid requests/responses (error JSON object defined in utils of HTTP components library)",
+			Required:    false,
+		},
+		library.EntryPort{
+			Name:        "LOG",
+			Type:        "json",
+			Description: "Optional output port for structured log entries (log entry JSON object defined in utils of HTTP components library)",
+			Required:    false,
+		},
+		library.EntryPort{
+			Name:        "HEARTBEAT",
+			Type:        "json",
+			Description: "Optional output port for periodic liveness IPs (heartbeat JSON object defined in utils of HTTP components library)",
+			Required:    false,
+		},
+		library.EntryPort{
+			Name:        "DEBUG",
+			Type:        "json",
+			Description: "Optional output port for wire-level dumps of requests and responses with secrets redacted, enabled with dump section of options",
+			Required:    false,
+		},
 	},
 }
@@ -0,0 +1,89 @@
+package main
+
+import (
+	"context"
+	"crypto/tls"
+	"net"
+	"net/http"
+	"sync"
+
+	httputils "github.com/cascades-fbp/cascades-http/utils"
+)
+
+// maxHelloSize limits bytes recorded while waiting for a complete ClientHello
+const maxHelloSize = 64 * 1024
+
+// connKey is the context key of the connection a request arrived on
+type connKey struct{}
+
+// helloListener records ClientHello messages of accepted connections
+type helloListener struct {
+	net.Listener
+}
+
+func (l helloListener) Accept() (net.Conn, error) {
+	c, err := l.Listener.Accept()
+	if err != nil {
+		return nil, err
+	}
+	return &helloConn{Conn: c}, nil
+}
+
+// helloConn fingerprints the ClientHello read from a connection by the
+// TLS handshake
+type helloConn struct {
+	net.Conn
+
+	mu          sync.Mutex
+	buf         []byte
+	done        bool
+	fingerprint httputils.TLSFingerprint
+}
+
+func (c *helloConn) Read(b []byte) (int, error) {
+	n, err := c.Conn.Read(b)
+	if n > 0 {
+		c.record(b[:n])
+	}
+	return n, err
+}
+
+// record appends received bytes until the ClientHello is parsed
+func (c *helloConn) record(b []byte) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.done {
+		return
+	}
+	c.buf = append(c.buf, b...)
+	hello, err := httputils.ParseClientHello(c.buf)
+	if err == httputils.ErrIncompleteHello && len(c.buf) < maxHelloSize {
+		return
+	}
+	if err == nil {
+		c.fingerprint = hello.Fingerprint()
+	} else {
+		logger.Debug("Failed to fingerprint ClientHello", "remote", c.RemoteAddr(), "error", err)
+	}
+	c.done = true
+	c.buf = nil
+}
+
+// withConn adds the connection to the context of its requests
+func withConn(ctx context.Context, c net.Conn) context.Context {
+	return context.WithValue(ctx, connKey{}, c)
+}
+
+// addFingerprint sets JA3/JA4 of the connection a request was received on
+func addFingerprint(req *http.Request, info *httputils.TLSInfo) {
+	tc, ok := req.Context().Value(connKey{}).(*tls.Conn)
+	if !ok || info == nil {
+		return
+	}
+	if hc, ok := tc.NetConn().(*helloConn); ok {
+		hc.mu.Lock()
+		info.JA3 = hc.fingerprint.JA3
+		info.JA4 = hc.fingerprint.JA4
+		hc.mu.Unlock()
+	}
+}
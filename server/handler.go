@@ -1,6 +1,7 @@
 package main
 
 import (
+	"compress/gzip"
 	"fmt"
 	"log"
 	"net/http"
@@ -10,10 +11,6 @@ import (
 	uuid "github.com/nu7hatch/gouuid"
 )
 
-const (
-	timeout = time.Duration(15) * time.Second
-)
-
 type HandlerRequest struct {
 	ResponseCh chan httputils.HTTPResponse
 	Request    *httputils.HTTPRequest
@@ -26,15 +23,52 @@ func respondWithTimeout(rw http.ResponseWriter) {
 
 func Handler(out chan HandlerRequest) http.HandlerFunc {
 	return func(rw http.ResponseWriter, req *http.Request) {
+		cfg := currentConfig()
+		timeout := cfg.RequestTimeout
 
 		log.Println("Handler:", req.Method, req.RequestURI)
 
+		// The span continues a trace of the caller if there's one
+		span := tracer.StartSpan("HTTP "+req.Method, req.Header.Get(httputils.TraceparentHeader), httputils.SpanServer)
+		span.SetAttribute("http.method", req.Method)
+		span.SetAttribute("http.target", req.RequestURI)
+		status := http.StatusOK
+		defer func() {
+			span.SetStatusCode(status)
+			span.End()
+		}()
+
+		var user string
+		if cfg.Digest != nil {
+			var ok bool
+			if user, ok = cfg.Digest.Authenticate(rw, req); !ok {
+				status = http.StatusUnauthorized
+				return
+			}
+		}
+
+		req.Body = http.MaxBytesReader(rw, req.Body, cfg.MaxBodySize)
+		r, err := httputils.Request2Request(req)
+		if err != nil {
+			logger.Warn("Failed to read request", "error", err)
+			reportError(httputils.NewError("http/server", httputils.ErrInvalidRequest, err))
+			status = http.StatusBadRequest
+			rw.WriteHeader(status)
+			fmt.Fprint(rw, "Couldn't read request body")
+			return
+		}
+		addFingerprint(req, r.TLS)
 		id, _ := uuid.NewV4()
-		r := httputils.Request2Request(req)
-		r.Id = id.String()
+		r.ID = id.String()
+		r.Trace = span.Traceparent()
+		r.User = user
+		span.SetAttribute("cascades.request_id", r.ID)
+		if dumper.Enabled() {
+			reportDump(dumper.Request(req, r.ID, r.Body))
+		}
 
 		hr := &HandlerRequest{
-			ResponseCh: make(chan httputils.HTTPResponse),
+			ResponseCh: make(chan httputils.HTTPResponse, 1),
 			Request:    r,
 		}
 
@@ -43,7 +77,11 @@ func Handler(out chan HandlerRequest) http.HandlerFunc {
 		select {
 		case out <- *hr:
 		case <-time.Tick(timeout):
+			err = fmt.Errorf("request wasn't accepted by OUT port in %v", timeout)
+			status = http.StatusInternalServerError
+			span.SetError(err)
 			respondWithTimeout(rw)
+			reportError(httputils.NewError("http/server", httputils.ErrTimeout, err).WithRequest(r.ID))
 			return
 		}
 
@@ -54,17 +92,34 @@ func Handler(out chan HandlerRequest) http.HandlerFunc {
 		select {
 		case resp = <-hr.ResponseCh:
 		case <-time.Tick(timeout):
+			err = fmt.Errorf("no response in %v", timeout)
+			status = http.StatusInternalServerError
+			span.SetError(err)
 			respondWithTimeout(rw)
+			reportError(httputils.NewError("http/server", httputils.ErrTimeout, err).WithRequest(r.ID))
 			return
 		}
 
 		log.Println("Data arrived. Responding to HTTP response...")
-		for name, values := range resp.Header {
-			for _, value := range values {
-				rw.Header().Add(name, value)
+		if err := resp.Validate(); err != nil {
+			logger.Warn("Invalid response", "error", err)
+			reportError(httputils.NewError("http/server", httputils.ErrInvalidIP, err).WithRequest(resp.ID))
+			status = http.StatusInternalServerError
+			span.SetError(err)
+			rw.WriteHeader(status)
+			fmt.Fprint(rw, "Couldn't process request")
+			return
+		}
+		httputils.EnsureContentType(&resp)
+		if cfg.Gzip && len(resp.Body) >= minGzipSize && httputils.AcceptsGzip(r) {
+			if err := httputils.GzipResponse(&resp, gzip.DefaultCompression); err != nil {
+				logger.Error("Failed to compress response", "error", err)
 			}
 		}
-		rw.WriteHeader(resp.StatusCode)
-		fmt.Fprint(rw, string(resp.Body))
+		if dumper.Enabled() {
+			reportDump(dumper.HTTPResponse(&resp))
+		}
+		status = resp.StatusCode
+		httputils.WriteResponse(rw, &resp)
 	}
 }
@@ -1,36 +1,121 @@
 package main
 
 import (
+	"context"
+	"crypto/tls"
 	"flag"
 	"fmt"
-	"io/ioutil"
 	"log"
 	"net"
 	"net/http"
 	"os"
-	"syscall"
-	"time"
 
-	zmq "github.com/alecthomas/gozmq"
 	httputils "github.com/cascades-fbp/cascades-http/utils"
 	"github.com/cascades-fbp/cascades/components/utils"
 	"github.com/cascades-fbp/cascades/runtime"
+	zmq "github.com/pebbe/zmq4"
 )
 
 var (
 	// Flags
-	optionsEndpoint = flag.String("port.options", "", "Component's options port endpoint")
-	inputEndpoint   = flag.String("port.in", "", "Component's input port endpoint")
-	outputEndpoint  = flag.String("port.out", "", "Component's output port endpoint")
-	jsonFlag        = flag.Bool("json", false, "Print component documentation in JSON")
-	debug           = flag.Bool("debug", false, "Enable debug mode")
+	optionsEndpoint   = flag.String("port.options", "", "Component's options port endpoint")
+	configEndpoint    = flag.String("port.config", "", "Component's configuration reload port endpoint")
+	inputEndpoint     = flag.String("port.in", "", "Component's input port endpoint")
+	outputEndpoint    = flag.String("port.out", "", "Component's output port endpoint")
+	errorEndpoint     = flag.String("port.err", "", "Component's error port endpoint")
+	logEndpoint       = flag.String("port.log", "", "Component's log port endpoint")
+	heartbeatEndpoint = flag.String("port.heartbeat", "", "Component's heartbeat port endpoint")
+	metricsAddr       = flag.String("metrics", "", "Address to expose metrics on, i.e. 127.0.0.1:9090 (disabled if empty)")
+	debugEndpoint     = flag.String("port.debug", "", "Component's debug port endpoint")
+	configFile        = flag.String("config", "", "Options JSON file re-applied on SIGHUP")
+	otlpEndpoint      = flag.String("otlp", "", "OTLP/HTTP endpoint to export trace spans to, i.e. http://127.0.0.1:4318/v1/traces (disabled if empty)")
+	jsonFlag          = flag.Bool("json", false, "Print component documentation in JSON")
+	debug             = flag.Bool("debug", false, "Enable debug mode")
 
 	// Internal
-	context                      *zmq.Context
-	optionsPort, inPort, outPort *zmq.Socket
-	err                          error
+	optionsPort, configPort, inPort, outPort, errPort, logPort, heartbeatPort, debugPort *zmq.Socket
+	outOutlet                                                                            *httputils.Outlet
+	errCh                                                                                = make(chan *httputils.Error, 16)
+	dumpCh                                                                               = make(chan [][]byte, 16)
+	reloadCh                                                                             = make(chan *Config, 1)
+	err                                                                                  error
+	logger                                                                               = httputils.NewLogger("http/server")
+	liveness                                                                             = httputils.NewLiveness("http/server")
+	metrics                                                                              = httputils.NewMetrics(logger, liveness)
+	shutdown                                                                             *httputils.Shutdown
+	tracer                                                                               *httputils.Tracer
+	dumper                                                                               = httputils.NewDumper("http/server")
 )
 
+// reportError queues a failure for the ERR port dropping it if the queue is full
+func reportError(e *httputils.Error) {
+	if *errorEndpoint == "" {
+		return
+	}
+	select {
+	case errCh <- e:
+	default:
+	}
+}
+
+// reportOverflow reports a request dropped because the queue of OUT port was full
+func reportOverflow(ip [][]byte) {
+	logger.Warn("Output queue is full, request dropped", "port", "http/server.out")
+	e := httputils.NewError("http/server", httputils.ErrOverflow, fmt.Errorf("queue of http/server.out is full"))
+	if req, err := httputils.IP2Request(ip); err == nil {
+		e = e.WithRequest(req.ID)
+	}
+	reportError(e)
+}
+
+// reportDump queues a wire-level dump for the DEBUG port dropping it if the queue is full
+func reportDump(ip [][]byte, err error) {
+	if *debugEndpoint == "" {
+		return
+	}
+	if err != nil {
+		logger.Warn("Failed to dump HTTP message", "error", err)
+		return
+	}
+	select {
+	case dumpCh <- ip:
+	default:
+	}
+}
+
+// flushQueues sends errors and dumps still queued for ERR and DEBUG ports
+func flushQueues() {
+	for {
+		select {
+		case e := <-errCh:
+			if ip, err := httputils.Error2IP(e); err == nil {
+				errPort.SendMessageDontwait(ip)
+			}
+		case ip := <-dumpCh:
+			debugPort.SendMessageDontwait(ip)
+		default:
+			return
+		}
+	}
+}
+
+// reload applies configuration received at runtime, the outlet of OUT port is
+// updated by the goroutine owning it
+func reload(payload []byte) {
+	cfg, err := reloadConfig(payload)
+	if err != nil {
+		logger.Error("Failed to reload configuration", "error", err)
+		reportError(httputils.NewError("http/server", httputils.ErrInvalidIP, err))
+		return
+	}
+	select {
+	case <-reloadCh:
+	default:
+	}
+	reloadCh <- cfg
+	logger.Info("Configuration reloaded")
+}
+
 func validateArgs() {
 	if *optionsEndpoint == "" {
 		flag.Usage()
@@ -47,25 +132,37 @@ func validateArgs() {
 }
 
 func openPorts() {
-	context, err = zmq.NewContext()
+	optionsPort, err = utils.CreateInputPort("http/server.options", *optionsEndpoint, nil)
 	utils.AssertError(err)
 
-	optionsPort, err = utils.CreateInputPort(context, *optionsEndpoint)
-	utils.AssertError(err)
+	if *configEndpoint != "" {
+		configPort, err = utils.CreateInputPort("http/server.config", *configEndpoint, nil)
+		utils.AssertError(err)
+	}
 
-	inPort, err = utils.CreateInputPort(context, *inputEndpoint)
+	inPort, err = utils.CreateInputPort("http/server.in", *inputEndpoint, nil)
 	utils.AssertError(err)
-}
 
-func closePorts() {
-	optionsPort.Close()
-	if inPort != nil {
-		inPort.Close()
+	if *logEndpoint != "" {
+		logPort, err = utils.CreateOutputPort("http/server.log", *logEndpoint, nil)
+		utils.AssertError(err)
+		logger.SetSink(func(ip [][]byte) { logPort.SendMessageDontwait(ip) })
 	}
-	if outPort != nil {
-		outPort.Close()
+
+	if *heartbeatEndpoint != "" {
+		heartbeatPort, err = utils.CreateOutputPort("http/server.heartbeat", *heartbeatEndpoint, nil)
+		utils.AssertError(err)
+		liveness.Start(func(ip [][]byte) { heartbeatPort.SendMessageDontwait(ip) })
 	}
-	context.Close()
+}
+
+func closePorts() {
+	shutdown.Close(optionsPort, configPort, inPort)
+	shutdown.Drain(outOutlet)
+	liveness.Stop()
+	tracer.Stop()
+	shutdown.Flush(outPort, errPort, debugPort, heartbeatPort, logPort)
+	zmq.Term()
 }
 
 func main() {
@@ -79,42 +176,117 @@ func main() {
 
 	log.SetFlags(0)
 	if *debug {
-		log.SetOutput(os.Stdout)
-	} else {
-		log.SetOutput(ioutil.Discard)
+		logger.SetLevel(httputils.LevelDebug)
+		logger.SetOutput(os.Stdout)
 	}
+	log.SetOutput(logger.Writer())
 
 	validateArgs()
 
+	shutdown = httputils.NewShutdown(logger)
+	tracer = httputils.NewTracer("http/server", *otlpEndpoint, logger)
+	tracer.Start()
 	openPorts()
-	defer closePorts()
 
-	exitCh := utils.HandleInterruption()
+	if *metricsAddr != "" {
+		err = metrics.Serve(*metricsAddr)
+		utils.AssertError(err)
+	}
 
 	// Wait for the configuration on the options port
-	var bindAddr string
+	var cfg *Config
 	for {
 		log.Println("Waiting for configuration...")
-		ip, err := optionsPort.RecvMultipart(0)
+		ip, err := shutdown.Receive(optionsPort)
+		if err == httputils.ErrShutdown {
+			shutdown.Exit(closePorts)
+		}
 		if err != nil {
-			log.Println("Error receiving IP:", err.Error())
+			logger.Error("Error receiving IP", "error", err)
 			continue
 		}
 		if !runtime.IsValidIP(ip) || !runtime.IsPacket(ip) {
 			continue
 		}
-		bindAddr = string(ip[1])
+		cfg, err = ParseConfig(ip[1])
+		if err != nil {
+			logger.Warn("Failed to parse options", "error", err)
+			continue
+		}
 		break
 	}
-	optionsPort.Close()
+
+	cfg.apply()
+	current.Store(cfg)
+
+	// Options sent after start switch wire-level dumps only, configuration
+	// from CONFIG port or file is reloaded as a whole
+	optionsDone := make(chan struct{})
+	go func() {
+		defer close(optionsDone)
+		reloads := httputils.WatchConfigFile(*configFile, logger)
+		poller := zmq.NewPoller()
+		poller.Add(optionsPort, zmq.POLLIN)
+		if configPort != nil {
+			poller.Add(configPort, zmq.POLLIN)
+		}
+		for !shutdown.Stopping() {
+			select {
+			case data := <-reloads:
+				reload(data)
+			default:
+			}
+			sockets, err := poller.Poll(shutdown.PollInterval)
+			if err != nil {
+				logger.Error("Error polling ports", "error", err)
+				continue
+			}
+			for _, socket := range sockets {
+				ip, err := socket.Socket.RecvMessageBytes(0)
+				if err != nil {
+					logger.Error("Error receiving IP", "error", err)
+					continue
+				}
+				if !runtime.IsValidIP(ip) || !runtime.IsPacket(ip) {
+					continue
+				}
+				if socket.Socket == configPort {
+					reload(ip[1])
+					continue
+				}
+				options, err := httputils.ParseOptions(ip[1])
+				if err != nil {
+					logger.Warn("Failed to parse options", "error", err)
+					continue
+				}
+				dumper.Apply(&options.Dump)
+				logger.Info("Switched wire-level dumps", "enabled", dumper.Enabled())
+			}
+		}
+	}()
 
 	// Data from http handler and data to http handler
 	inCh := make(chan httputils.HTTPResponse)
 	outCh := make(chan HandlerRequest)
 
-	go func(ctx *zmq.Context, endpoint string) {
-		outPort, err = utils.CreateOutputPort(context, endpoint)
+	// Closed by the main loop once no more responses will be received
+	stopOut := make(chan struct{})
+	outDone := make(chan struct{})
+
+	go func(endpoint string) {
+		defer close(outDone)
+		outPort, err = utils.CreateOutputPort("http/server.out", endpoint, nil)
 		utils.AssertError(err)
+		outOutlet = httputils.NewOutlet("http/server.out", outPort, &cfg.Backpressure, reportOverflow)
+		metrics.WatchOutlet(outOutlet)
+		if *errorEndpoint != "" {
+			errPort, err = utils.CreateOutputPort("http/server.err", *errorEndpoint, nil)
+			utils.AssertError(err)
+		}
+		if *debugEndpoint != "" {
+			debugPort, err = utils.CreateOutputPort("http/server.debug", *debugEndpoint, nil)
+			utils.AssertError(err)
+		}
 
 		// Map of uuid to requests
 		dataMap := make(map[string]chan httputils.HTTPResponse)
@@ -123,64 +295,122 @@ func main() {
 		for {
 			select {
 			case data := <-outCh:
-				dataMap[data.Request.Id] = data.ResponseCh
+				dataMap[data.Request.ID] = data.ResponseCh
 				ip, _ := httputils.Request2IP(data.Request)
-				outPort.SendMultipart(ip, 0)
+				outOutlet.Send(ip)
 			case resp := <-inCh:
-				if respCh, ok := dataMap[resp.Id]; ok {
-					log.Println("Resolved channel for response", resp.Id)
+				if respCh, ok := dataMap[resp.ID]; ok {
+					log.Println("Resolved channel for response", resp.ID)
 					respCh <- resp
-					delete(dataMap, resp.Id)
+					delete(dataMap, resp.ID)
 					continue
 				}
-				log.Println("Didn't find request handler mapping for a given ID", resp.Id)
+				logger.Warn("Didn't find request handler mapping for a given ID", "id", resp.ID)
+				reportError(httputils.NewError("http/server", httputils.ErrInternal, fmt.Errorf("no pending request for response")).WithRequest(resp.ID))
+			case e := <-errCh:
+				ip, err := httputils.Error2IP(e)
+				if err == nil {
+					errPort.SendMessageDontwait(ip)
+				}
+			case ip := <-dumpCh:
+				debugPort.SendMessageDontwait(ip)
+			case cfg := <-reloadCh:
+				outOutlet.Apply(&cfg.Backpressure)
+			case <-stopOut:
+				flushQueues()
+				return
 			}
 		}
-	}(context, *outputEndpoint)
+	}(*outputEndpoint)
 
 	// Web server goroutine
-	go func() {
-		mux := http.NewServeMux()
-		mux.HandleFunc("/", Handler(outCh))
-
-		s := &http.Server{
-			Handler:        mux,
-			ReadTimeout:    10 * time.Second,
-			WriteTimeout:   10 * time.Second,
-			MaxHeaderBytes: 1 << 20,
+	mux := http.NewServeMux()
+	mux.HandleFunc("/", Handler(outCh))
+	s := &http.Server{
+		Handler:        mux,
+		ReadTimeout:    cfg.ReadTimeout,
+		WriteTimeout:   cfg.WriteTimeout,
+		IdleTimeout:    cfg.IdleTimeout,
+		MaxHeaderBytes: cfg.MaxHeaderBytes,
+	}
+	if cfg.TLS != nil {
+		// Handshakes use the TLS configuration in effect, so reloaded
+		// certificates apply to new connections
+		s.TLSConfig = cfg.TLS.Clone()
+		s.TLSConfig.GetConfigForClient = func(*tls.ClientHelloInfo) (*tls.Config, error) {
+			return currentConfig().TLS, nil
 		}
-
-		ln, err := net.Listen("tcp", bindAddr)
+		s.ConnContext = withConn
+	}
+	go func() {
+		ln, err := net.Listen("tcp", cfg.Addr)
 		if err != nil {
-			log.Println(err.Error())
-			exitCh <- syscall.SIGTERM
+			logger.Error("Failed to listen", "addr", cfg.Addr, "error", err)
+			reportError(httputils.NewError("http/server", httputils.ErrNetwork, err))
+			shutdown.Stop()
 			return
 		}
 
-		log.Printf("Starting listening %v", bindAddr)
-		err = s.Serve(ln)
-		if err != nil {
-			log.Println(err.Error())
-			exitCh <- syscall.SIGTERM
-			return
+		logger.Info("Starting listening", "addr", cfg.Addr)
+		if cfg.TLS != nil {
+			// Client hellos are recorded for JA3/JA4 fingerprints
+			err = s.ServeTLS(helloListener{ln}, "", "")
+		} else {
+			err = s.Serve(ln)
+		}
+		if err != nil && err != http.ErrServerClosed {
+			logger.Error("Server stopped", "error", err)
+			shutdown.Stop()
+		}
+	}()
+
+	// On shutdown stop accepting connections, responses of active requests
+	// are still received until their handlers return
+	serverDone := make(chan struct{})
+	go func() {
+		defer close(serverDone)
+		<-shutdown.Done()
+		if err := s.Shutdown(context.Background()); err != nil {
+			logger.Error("Failed to shutdown server", "error", err)
 		}
 	}()
 
-	// Process incoming message forever
+	// Process incoming messages until all handlers are done
+	poller := zmq.NewPoller()
+	poller.Add(inPort, zmq.POLLIN)
 	for {
-		ip, err := inPort.RecvMultipart(0)
+		select {
+		case <-serverDone:
+			close(stopOut)
+			<-outDone
+			<-optionsDone
+			shutdown.Exit(closePorts)
+		default:
+		}
+		sockets, err := poller.Poll(shutdown.PollInterval)
+		if err != nil {
+			logger.Error("Error polling ports", "error", err)
+			continue
+		}
+		if len(sockets) == 0 {
+			continue
+		}
+
+		ip, err := inPort.RecvMessageBytes(0)
 		if err != nil {
-			log.Println("Error receiving message:", err.Error())
+			logger.Error("Error receiving message", "error", err)
 			continue
 		}
-		if !runtime.IsValidIP(ip) {
-			log.Println("Received invalid IP")
+		liveness.Inc()
+		if !httputils.IsValidIP(ip) {
+			logger.Warn("Received invalid IP")
 			continue
 		}
 
 		resp, err := httputils.IP2Response(ip)
 		if err != nil {
-			log.Printf("Error converting IP to response: %s", err.Error())
+			logger.Warn("Error converting IP to response", "error", err)
+			reportError(httputils.NewError("http/server", httputils.ErrInvalidIP, err))
 			continue
 		}
 		inCh <- *resp
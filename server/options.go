@@ -0,0 +1,159 @@
+package main
+
+import (
+	"bytes"
+	"crypto/tls"
+	"fmt"
+	"sync/atomic"
+	"time"
+
+	httputils "github.com/cascades-fbp/cascades-http/utils"
+)
+
+const (
+	defaultTimeout        = 15 * time.Second
+	defaultReadTimeout    = 10 * time.Second
+	defaultWriteTimeout   = 10 * time.Second
+	defaultMaxBodySize    = 10 << 20
+	defaultMaxHeaderBytes = 1 << 20
+	minGzipSize           = 1024
+)
+
+// Section describes server specific section of the options IP
+type Section struct {
+	Addr   string         `json:"addr"`             // TCP endpoint to listen on, i.e. 127.0.0.1:8080
+	Gzip   bool           `json:"gzip"`             // Compress responses for clients accepting gzip
+	Digest *DigestOptions `json:"digest,omitempty"` // Require Digest authentication of all requests
+}
+
+// Config is the server configuration resolved from the options IP
+type Config struct {
+	Addr           string
+	RequestTimeout time.Duration
+	ReadTimeout    time.Duration
+	WriteTimeout   time.Duration
+	IdleTimeout    time.Duration
+	MaxBodySize    int64
+	MaxHeaderBytes int
+	Gzip           bool
+	TLS            *tls.Config
+	Digest         *DigestAuth
+	Backpressure   httputils.BackpressureOptions
+
+	options *httputils.Options // Dump, redaction and logging sections set by apply
+}
+
+// current holds the *Config in effect, replaced when configuration is reloaded
+var current atomic.Value
+
+// currentConfig returns the configuration in effect
+func currentConfig() *Config {
+	return current.Load().(*Config)
+}
+
+// ParseConfig accepts either a plain TCP endpoint or the unified options JSON.
+// Sections changing process wide state are only validated, apply sets them.
+func ParseConfig(payload []byte) (*Config, error) {
+	cfg := &Config{
+		RequestTimeout: defaultTimeout,
+		ReadTimeout:    defaultReadTimeout,
+		WriteTimeout:   defaultWriteTimeout,
+		MaxBodySize:    defaultMaxBodySize,
+		MaxHeaderBytes: defaultMaxHeaderBytes,
+	}
+	trimmed := bytes.TrimSpace(payload)
+	if len(trimmed) == 0 || trimmed[0] != '{' {
+		cfg.Addr = string(trimmed)
+		return cfg, nil
+	}
+
+	options, err := httputils.ParseOptions(trimmed)
+	if err != nil {
+		return nil, err
+	}
+	var section Section
+	if err = httputils.DecodeSection(options.Server, &section); err != nil {
+		return nil, err
+	}
+	if section.Addr == "" {
+		return nil, fmt.Errorf("server section has no addr")
+	}
+	cfg.Addr = section.Addr
+	cfg.Gzip = section.Gzip
+	if section.Digest != nil {
+		if cfg.Digest, err = NewDigestAuth(section.Digest); err != nil {
+			return nil, err
+		}
+	}
+	if err = options.Backpressure.Validate(); err != nil {
+		return nil, err
+	}
+	cfg.Backpressure = options.Backpressure
+
+	if v := time.Duration(options.Timeouts.Request); v > 0 {
+		cfg.RequestTimeout = v
+	}
+	if v := time.Duration(options.Timeouts.Read); v > 0 {
+		cfg.ReadTimeout = v
+	}
+	if v := time.Duration(options.Timeouts.Write); v > 0 {
+		cfg.WriteTimeout = v
+	}
+	cfg.IdleTimeout = time.Duration(options.Timeouts.Idle)
+	if options.Limits.MaxBodySize > 0 {
+		cfg.MaxBodySize = options.Limits.MaxBodySize
+	}
+	if options.Limits.MaxHeaderBytes > 0 {
+		cfg.MaxHeaderBytes = options.Limits.MaxHeaderBytes
+	}
+	if options.TLS != nil {
+		if cfg.TLS, err = options.TLS.ServerConfig(); err != nil {
+			return nil, err
+		}
+		// ServeTLS doesn't enable HTTP/2 for configurations returned per connection
+		cfg.TLS.NextProtos = []string{"h2", "http/1.1"}
+	}
+	if options.Logging.Level != "" {
+		if _, err = httputils.ParseLevel(options.Logging.Level); err != nil {
+			return nil, err
+		}
+	}
+	cfg.options = options
+	return cfg, nil
+}
+
+// apply sets wire-level dumps, redaction and logging of an accepted
+// configuration
+func (c *Config) apply() {
+	if c.options == nil {
+		return
+	}
+	dumper.Apply(&c.options.Dump)
+	httputils.Redaction.Apply(&c.options.Redact)
+	// Level is validated by ParseConfig
+	c.options.Logging.Apply(logger)
+}
+
+// reloadConfig applies configuration received at runtime. Request timeout,
+// limits, gzip, digest authentication, TLS certificates and backpressure take
+// effect for new requests and connections. The listener keeps its address,
+// connection timeouts and whether TLS is on, changing these requires a restart.
+func reloadConfig(payload []byte) (*Config, error) {
+	cfg, err := ParseConfig(payload)
+	if err != nil {
+		return nil, err
+	}
+	old := currentConfig()
+	if (cfg.TLS == nil) != (old.TLS == nil) {
+		return nil, fmt.Errorf("switching TLS on or off requires restart")
+	}
+	if cfg.Addr != old.Addr {
+		logger.Warn("Changing listen address requires restart", "addr", old.Addr)
+	}
+	cfg.Addr = old.Addr
+	cfg.ReadTimeout, cfg.WriteTimeout, cfg.IdleTimeout = old.ReadTimeout, old.WriteTimeout, old.IdleTimeout
+	cfg.MaxHeaderBytes = old.MaxHeaderBytes
+	cfg.apply()
+	current.Store(cfg)
+	return cfg, nil
+}
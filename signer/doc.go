@@ -0,0 +1,64 @@
+package main
+
+import "github.com/cascades-fbp/cascades/library"
+
+var registryEntry = &library.Entry{
+	Description: `Signs request and response IPs with HTTP Message Signatures (RFC 9421) adding Signature and
+Signature-Input headers, i.e. for signed webhooks. Requests from REQUEST port are signed to OUT, responses
+from RESPONSE port to RESP. Messages with body get a Content-Digest header (RFC 9530) which is covered by
+the signature unless digest option is disabled. Supported algorithms are hmac-sha256, ed25519,
+ecdsa-p256-sha256, ecdsa-p384-sha384, rsa-pss-sha512 and rsa-v1_5-sha256.`,
+	Elementary: true,
+	Inports: []library.EntryPort{
+		library.EntryPort{
+			Name:        "OPTIONS",
+			Type:        "json",
+			Description: `Configuration port, i.e. {"algorithm": "ed25519", "key_id": "webhooks-2024", "key_file": "/etc/keys/sign.pem", "components": ["@method", "@target-uri", "content-type"], "expires": "5m"}. HMAC secret may be given in key`,
+			Required:    true,
+		},
+		library.EntryPort{
+			Name:        "REQUEST",
+			Type:        "json",
+			Description: "Input port for requests in predefined JSON format to sign (covering @method and @target-uri by default)",
+			Required:    false,
+		},
+		library.EntryPort{
+			Name:        "RESPONSE",
+			Type:        "json",
+			Description: "Input port for responses in predefined JSON format to sign (covering @status by default)",
+			Required:    false,
+		},
+	},
+	Outports: []library.EntryPort{
+		library.EntryPort{
+			Name:        "OUT",
+			Type:        "json",
+			Description: "Output port for signed requests, required if REQUEST is connected",
+			Required:    false,
+		},
+		library.EntryPort{
+			Name:        "RESP",
+			Type:        "json",
+			Description: "Output port for signed responses, required if RESPONSE is connected",
+			Required:    false,
+		},
+		library.EntryPort{
+			Name:        "ERR",
+			Type:        "json",
+			Description: "Optional error port for IPs which couldn't be signed (error JSON object defined in utils of HTTP components library)",
+			Required:    false,
+		},
+		library.EntryPort{
+			Name:        "LOG",
+			Type:        "json",
+			Description: "Optional output port for structured log entries (log entry JSON object defined in utils of HTTP components library)",
+			Required:    false,
+		},
+		library.EntryPort{
+			Name:        "HEARTBEAT",
+			Type:        "json",
+			Description: "Optional output port for periodic liveness IPs (heartbeat JSON object defined in utils of HTTP components library)",
+			Required:    false,
+		},
+	},
+}
@@ -0,0 +1,294 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"os"
+	"time"
+
+	httputils "github.com/cascades-fbp/cascades-http/utils"
+	"github.com/cascades-fbp/cascades/components/utils"
+	"github.com/cascades-fbp/cascades/runtime"
+	zmq "github.com/pebbe/zmq4"
+)
+
+var (
+	// Flags
+	optionsEndpoint   = flag.String("port.options", "", "Component's options port endpoint")
+	requestEndpoint   = flag.String("port.request", "", "Component's request port endpoint")
+	responseEndpoint  = flag.String("port.response", "", "Component's response port endpoint")
+	outputEndpoint    = flag.String("port.out", "", "Component's output port endpoint")
+	respEndpoint      = flag.String("port.resp", "", "Component's signed response port endpoint")
+	errorEndpoint     = flag.String("port.err", "", "Component's error port endpoint")
+	logEndpoint       = flag.String("port.log", "", "Component's log port endpoint")
+	heartbeatEndpoint = flag.String("port.heartbeat", "", "Component's heartbeat port endpoint")
+	metricsAddr       = flag.String("metrics", "", "Address to expose metrics on, i.e. 127.0.0.1:9090 (disabled if empty)")
+	jsonFlag          = flag.Bool("json", false, "Print component documentation in JSON")
+	debug             = flag.Bool("debug", false, "Enable debug mode")
+
+	// Internal
+	optionsPort, requestPort, responsePort, outPort, respPort, errPort, logPort, heartbeatPort *zmq.Socket
+	err                                                                                        error
+	logger                                                                                     = httputils.NewLogger("http/signer")
+	liveness                                                                                   = httputils.NewLiveness("http/signer")
+	metrics                                                                                    = httputils.NewMetrics(logger, liveness)
+	shutdown                                                                                   *httputils.Shutdown
+)
+
+// Options describe the configuration IP of the component
+type Options struct {
+	Algorithm  string             `json:"algorithm"`  // One of RFC 9421 algorithms, i.e. ed25519
+	KeyID      string             `json:"key_id"`     // Sent as keyid parameter
+	Key        string             `json:"key"`        // HMAC secret or PEM encoded private key
+	KeyFile    string             `json:"key_file"`   // File with the key, used if key is empty
+	Label      string             `json:"label"`      // Name of the signature, sig1 by default
+	Components []string           `json:"components"` // Covered components, defaults depend on message type
+	Expires    httputils.Duration `json:"expires"`    // Validity of signatures (no expiration if zero)
+	Nonce      bool               `json:"nonce"`      // Add a random nonce to every signature
+	Tag        string             `json:"tag"`        // Application specific tag parameter
+	Digest     bool               `json:"digest"`     // Add and cover Content-Digest of bodies (enabled by default)
+}
+
+// validateArgs checks all required flags
+func validateArgs() {
+	if *optionsEndpoint == "" {
+		flag.Usage()
+		os.Exit(1)
+	}
+	if *requestEndpoint == "" && *responseEndpoint == "" {
+		flag.Usage()
+		os.Exit(1)
+	}
+	if *requestEndpoint != "" && *outputEndpoint == "" {
+		flag.Usage()
+		os.Exit(1)
+	}
+	if *responseEndpoint != "" && *respEndpoint == "" {
+		flag.Usage()
+		os.Exit(1)
+	}
+}
+
+// openPorts create ZMQ sockets
+func openPorts() {
+	optionsPort, err = utils.CreateInputPort("http/signer.options", *optionsEndpoint, nil)
+	utils.AssertError(err)
+
+	if *requestEndpoint != "" {
+		requestPort, err = utils.CreateInputPort("http/signer.request", *requestEndpoint, nil)
+		utils.AssertError(err)
+
+		outPort, err = utils.CreateOutputPort("http/signer.out", *outputEndpoint, nil)
+		utils.AssertError(err)
+	}
+
+	if *responseEndpoint != "" {
+		responsePort, err = utils.CreateInputPort("http/signer.response", *responseEndpoint, nil)
+		utils.AssertError(err)
+
+		respPort, err = utils.CreateOutputPort("http/signer.resp", *respEndpoint, nil)
+		utils.AssertError(err)
+	}
+
+	if *errorEndpoint != "" {
+		errPort, err = utils.CreateOutputPort("http/signer.err", *errorEndpoint, nil)
+		utils.AssertError(err)
+	}
+
+	if *logEndpoint != "" {
+		logPort, err = utils.CreateOutputPort("http/signer.log", *logEndpoint, nil)
+		utils.AssertError(err)
+		logger.SetSink(func(ip [][]byte) { logPort.SendMessageDontwait(ip) })
+	}
+
+	if *heartbeatEndpoint != "" {
+		heartbeatPort, err = utils.CreateOutputPort("http/signer.heartbeat", *heartbeatEndpoint, nil)
+		utils.AssertError(err)
+		liveness.Start(func(ip [][]byte) { heartbeatPort.SendMessageDontwait(ip) })
+	}
+}
+
+// closePorts closes all active ports and terminates ZMQ context
+func closePorts() {
+	shutdown.Close(optionsPort, requestPort, responsePort)
+	liveness.Stop()
+	shutdown.Flush(outPort, respPort, errPort, heartbeatPort, logPort)
+	zmq.Term()
+}
+
+// newSigner creates a signer configured by the options
+func newSigner(options *Options) (*httputils.Signer, error) {
+	data := []byte(options.Key)
+	if len(data) == 0 && options.KeyFile != "" {
+		var err error
+		if data, err = ioutil.ReadFile(options.KeyFile); err != nil {
+			return nil, err
+		}
+	}
+	key, err := httputils.ParseSigningKey(options.Algorithm, data)
+	if err != nil {
+		return nil, err
+	}
+	signer, err := httputils.NewSigner(options.Algorithm, options.KeyID, key)
+	if err != nil {
+		return nil, err
+	}
+	if options.Label != "" {
+		signer.Label = options.Label
+	}
+	signer.Components = options.Components
+	signer.Expires = time.Duration(options.Expires)
+	signer.Nonce = options.Nonce
+	signer.Tag = options.Tag
+	signer.Digest = options.Digest
+	return signer, nil
+}
+
+func main() {
+	flag.Parse()
+
+	if *jsonFlag {
+		doc, _ := registryEntry.JSON()
+		fmt.Println(string(doc))
+		os.Exit(0)
+	}
+
+	log.SetFlags(0)
+	if *debug {
+		logger.SetLevel(httputils.LevelDebug)
+		logger.SetOutput(os.Stdout)
+	}
+	log.SetOutput(logger.Writer())
+
+	validateArgs()
+
+	shutdown = httputils.NewShutdown(logger)
+	openPorts()
+
+	if *metricsAddr != "" {
+		err = metrics.Serve(*metricsAddr)
+		utils.AssertError(err)
+	}
+
+	if requestPort != nil {
+		err = runtime.SetupShutdownByDisconnect(requestPort, "http/signer.request", shutdown.Signals())
+	} else {
+		err = runtime.SetupShutdownByDisconnect(responsePort, "http/signer.response", shutdown.Signals())
+	}
+	utils.AssertError(err)
+
+	// Wait for the configuration on the options port
+	var signer *httputils.Signer
+	for signer == nil {
+		log.Println("Waiting for configuration...")
+		ip, err := shutdown.Receive(optionsPort)
+		if err == httputils.ErrShutdown {
+			shutdown.Exit(closePorts)
+		}
+		if err != nil {
+			logger.Error("Error receiving IP", "error", err)
+			continue
+		}
+		if !runtime.IsValidIP(ip) || !runtime.IsPacket(ip) {
+			continue
+		}
+		options := &Options{Digest: true}
+		if err = json.Unmarshal(ip[1], options); err != nil {
+			logger.Error("Failed to unmarshal options", "error", err)
+			continue
+		}
+		if signer, err = newSigner(options); err != nil {
+			logger.Error("Invalid signing configuration", "error", err)
+			continue
+		}
+	}
+	optionsPort.Close()
+	optionsPort = nil
+
+	poller := zmq.NewPoller()
+	if requestPort != nil {
+		poller.Add(requestPort, zmq.POLLIN)
+	}
+	if responsePort != nil {
+		poller.Add(responsePort, zmq.POLLIN)
+	}
+
+	// Main loop
+	for !shutdown.Stopping() {
+		sockets, err := poller.Poll(shutdown.PollInterval)
+		if err != nil {
+			logger.Error("Error polling ports", "error", err)
+			continue
+		}
+
+		for _, socket := range sockets {
+			ip, err := socket.Socket.RecvMessageBytes(0)
+			if err != nil {
+				logger.Error("Error receiving message", "error", err)
+				continue
+			}
+			liveness.Inc()
+			if !httputils.IsValidIP(ip) || !runtime.IsPacket(ip) {
+				continue
+			}
+
+			switch socket.Socket {
+			case requestPort:
+				req, err := httputils.IP2Request(ip)
+				if err != nil {
+					logger.Warn("Failed to convert IP to request", "error", err)
+					sendError(httputils.NewError("http/signer", httputils.ErrInvalidIP, err))
+					continue
+				}
+				if err = signer.SignRequest(req); err != nil {
+					logger.Warn("Failed to sign request", "id", req.ID, "error", err)
+					sendError(httputils.NewError("http/signer", httputils.ErrInvalidRequest, err).WithRequest(req.ID))
+					continue
+				}
+				ip, err = httputils.Request2IP(req)
+				if err != nil {
+					logger.Error("Failed to convert request to IP", "error", err)
+					sendError(httputils.NewError("http/signer", httputils.ErrInternal, err).WithRequest(req.ID))
+					continue
+				}
+				outPort.SendMessage(ip)
+
+			case responsePort:
+				resp, err := httputils.IP2Response(ip)
+				if err != nil {
+					logger.Warn("Failed to convert IP to response", "error", err)
+					sendError(httputils.NewError("http/signer", httputils.ErrInvalidIP, err))
+					continue
+				}
+				if err = signer.SignResponse(resp); err != nil {
+					logger.Warn("Failed to sign response", "id", resp.ID, "error", err)
+					sendError(httputils.NewError("http/signer", httputils.ErrInvalidRequest, err).WithRequest(resp.ID))
+					continue
+				}
+				ip, err = httputils.Response2IP(resp)
+				if err != nil {
+					logger.Error("Failed to convert response to IP", "error", err)
+					sendError(httputils.NewError("http/signer", httputils.ErrInternal, err).WithRequest(resp.ID))
+					continue
+				}
+				respPort.SendMessage(ip)
+			}
+		}
+	}
+	shutdown.Exit(closePorts)
+}
+
+// sendError reports a failure to the ERR port if it's connected
+func sendError(e *httputils.Error) {
+	if errPort == nil {
+		return
+	}
+	ip, err := httputils.Error2IP(e)
+	if err != nil {
+		return
+	}
+	errPort.SendMessageDontwait(ip)
+}
@@ -0,0 +1,287 @@
+package main
+
+import (
+	"container/heap"
+	"fmt"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	httputils "github.com/cascades-fbp/cascades-http/utils"
+)
+
+// Options describe the configuration IP of the component
+type Options struct {
+	Threshold httputils.Duration `json:"threshold"` // Threshold of requests not matching any route, 1s by default
+	Respond   bool               `json:"respond"`   // Answer requests exceeding their threshold with 504 Gateway Timeout
+	TTL       httputils.Duration `json:"ttl"`       // Requests without response are forgotten after this interval, 5m by default
+	Routes    []Route            `json:"routes"`    // Checked in order, the first matching route applies
+}
+
+// Route sets the threshold of requests by method and path prefix
+type Route struct {
+	Name      string             `json:"name"`      // Label of alerts and metrics, method and path by default
+	Methods   []string           `json:"methods"`   // Only requests with these methods match (all if empty)
+	Path      string             `json:"path"`      // Path prefix of matching requests
+	Threshold httputils.Duration `json:"threshold"` // Maximal time to wait for the response
+	Respond   *bool              `json:"respond"`   // Overrides respond option of the component
+}
+
+// Alert is the IP sent to the ALERT port when a request exceeds its threshold
+type Alert struct {
+	ID        string             `json:"id"`
+	Method    string             `json:"method"`
+	URI       string             `json:"uri"`
+	Trace     string             `json:"trace,omitempty"`
+	Route     string             `json:"route"`
+	Threshold httputils.Duration `json:"threshold"`
+	Elapsed   httputils.Duration `json:"elapsed"`
+	Started   time.Time          `json:"started"`
+	Completed bool               `json:"completed"` // The response arrived before the alert was sent
+	TimedOut  bool               `json:"timed_out"` // The request was answered with 504 Gateway Timeout
+}
+
+const (
+	defaultThreshold = time.Second
+	defaultTTL       = 5 * time.Minute
+)
+
+// route is a configured route with its counters
+type route struct {
+	Route
+	respond  bool
+	slow     int64
+	timeouts int64
+}
+
+// pending is a request waiting for its response
+type pending struct {
+	id       string
+	method   string
+	uri      string
+	trace    string
+	route    *route
+	started  time.Time
+	deadline time.Time
+	alerted  bool // Alert was sent while the request was in flight
+	timedOut bool // 504 was sent, the real response is dropped
+	index    int  // Position in the deadline heap, -1 if removed
+}
+
+// deadlines is a min-heap of pending requests by deadline
+type deadlines []*pending
+
+func (d deadlines) Len() int           { return len(d) }
+func (d deadlines) Less(i, j int) bool { return d[i].deadline.Before(d[j].deadline) }
+func (d deadlines) Swap(i, j int) {
+	d[i], d[j] = d[j], d[i]
+	d[i].index = i
+	d[j].index = j
+}
+func (d *deadlines) Push(x interface{}) {
+	p := x.(*pending)
+	p.index = len(*d)
+	*d = append(*d, p)
+}
+func (d *deadlines) Pop() interface{} {
+	old := *d
+	p := old[len(old)-1]
+	old[len(old)-1] = nil
+	p.index = -1
+	*d = old[:len(old)-1]
+	return p
+}
+
+// Detector correlates requests and responses by ID and measures time in between.
+// It's not safe for concurrent use.
+type Detector struct {
+	options  *Options
+	routes   []*route
+	fallback *route
+	byID     map[string]*pending
+	queue    deadlines
+	count    int64
+}
+
+// NewDetector validates options, applies defaults and registers metrics of routes
+func NewDetector(options *Options) (*Detector, error) {
+	if options.Threshold <= 0 {
+		options.Threshold = httputils.Duration(defaultThreshold)
+	}
+	if options.TTL <= 0 {
+		options.TTL = httputils.Duration(defaultTTL)
+	}
+	d := &Detector{
+		options: options,
+		byID:    make(map[string]*pending),
+	}
+	names := make(map[string]bool)
+	for i, r := range options.Routes {
+		if r.Threshold <= 0 {
+			return nil, fmt.Errorf("route %d: threshold is required", i)
+		}
+		if r.Name == "" {
+			method := "*"
+			if len(r.Methods) > 0 {
+				method = strings.ToUpper(strings.Join(r.Methods, ","))
+			}
+			r.Name = method + " " + r.Path
+		}
+		if names[r.Name] {
+			return nil, fmt.Errorf("route %d: duplicate name %q", i, r.Name)
+		}
+		names[r.Name] = true
+		rt := &route{Route: r, respond: options.Respond}
+		if r.Respond != nil {
+			rt.respond = *r.Respond
+		}
+		d.routes = append(d.routes, rt)
+	}
+	d.fallback = &route{Route: Route{Name: "default", Threshold: options.Threshold}, respond: options.Respond}
+	if names[d.fallback.Name] {
+		return nil, fmt.Errorf("route name %q is reserved", d.fallback.Name)
+	}
+
+	for _, rt := range append(d.routes, d.fallback) {
+		rt := rt
+		metrics.CounterFunc("cascades_http_slow_requests_total", "Number of requests which exceeded the threshold of their route", func() float64 {
+			return float64(atomic.LoadInt64(&rt.slow))
+		}, "route", rt.Name)
+		metrics.CounterFunc("cascades_http_slow_timeouts_total", "Number of requests answered with 504 Gateway Timeout", func() float64 {
+			return float64(atomic.LoadInt64(&rt.timeouts))
+		}, "route", rt.Name)
+	}
+	metrics.GaugeFunc("cascades_http_slow_pending", "Number of requests waiting for their response", func() float64 {
+		return float64(atomic.LoadInt64(&d.count))
+	})
+	return d, nil
+}
+
+// match returns the route of a request
+func (d *Detector) match(req *httputils.HTTPRequest) *route {
+	path := req.URI
+	if i := strings.IndexAny(path, "?#"); i >= 0 {
+		path = path[:i]
+	}
+	if i := strings.Index(path, "://"); i >= 0 {
+		if j := strings.IndexByte(path[i+3:], '/'); j >= 0 {
+			path = path[i+3+j:]
+		} else {
+			path = "/"
+		}
+	}
+	for _, rt := range d.routes {
+		if !strings.HasPrefix(path, rt.Path) {
+			continue
+		}
+		if len(rt.Methods) == 0 {
+			return rt
+		}
+		for _, m := range rt.Methods {
+			if strings.EqualFold(m, req.Method) {
+				return rt
+			}
+		}
+	}
+	return d.fallback
+}
+
+// Start begins timing of a request. A request with the ID already pending
+// replaces it.
+func (d *Detector) Start(req *httputils.HTTPRequest, now time.Time) {
+	if old, ok := d.byID[req.ID]; ok {
+		d.remove(old)
+	}
+	rt := d.match(req)
+	p := &pending{
+		id:       req.ID,
+		method:   req.Method,
+		uri:      httputils.Redaction.URL(req.URI),
+		trace:    req.Trace,
+		route:    rt,
+		started:  now,
+		deadline: now.Add(time.Duration(rt.Threshold)),
+	}
+	d.byID[req.ID] = p
+	heap.Push(&d.queue, p)
+	atomic.AddInt64(&d.count, 1)
+}
+
+// Finish completes a request by its response ID. It returns an alert if
+// the response arrived after the threshold without being alerted yet, and
+// drop is true if the request was already answered with 504.
+func (d *Detector) Finish(id string, now time.Time) (alert *Alert, drop bool) {
+	p, ok := d.byID[id]
+	if !ok {
+		return nil, false
+	}
+	d.remove(p)
+	if p.timedOut {
+		return nil, true
+	}
+	if p.alerted || now.Before(p.deadline) {
+		return nil, false
+	}
+	atomic.AddInt64(&p.route.slow, 1)
+	a := p.alert(now)
+	a.Completed = true
+	return a, false
+}
+
+// Expire fires deadlines due at a given time returning their alerts, requests
+// are kept until their response arrives or TTL passes
+func (d *Detector) Expire(now time.Time) []*Alert {
+	var alerts []*Alert
+	for len(d.queue) > 0 && !d.queue[0].deadline.After(now) {
+		p := heap.Pop(&d.queue).(*pending)
+		if p.alerted {
+			// TTL of a request which already fired
+			d.remove(p)
+			continue
+		}
+		atomic.AddInt64(&p.route.slow, 1)
+		p.alerted = true
+		a := p.alert(now)
+		if p.route.respond {
+			atomic.AddInt64(&p.route.timeouts, 1)
+			p.timedOut = true
+			a.TimedOut = true
+		}
+		alerts = append(alerts, a)
+		p.deadline = p.started.Add(time.Duration(d.options.TTL))
+		if p.deadline.Before(now) {
+			p.deadline = now
+		}
+		heap.Push(&d.queue, p)
+	}
+	return alerts
+}
+
+// Next returns how long until the next deadline, ok is false if nothing is pending
+func (d *Detector) Next(now time.Time) (time.Duration, bool) {
+	if len(d.queue) == 0 {
+		return 0, false
+	}
+	return d.queue[0].deadline.Sub(now), true
+}
+
+func (d *Detector) remove(p *pending) {
+	if p.index >= 0 {
+		heap.Remove(&d.queue, p.index)
+	}
+	delete(d.byID, p.id)
+	atomic.AddInt64(&d.count, -1)
+}
+
+func (p *pending) alert(now time.Time) *Alert {
+	return &Alert{
+		ID:        p.id,
+		Method:    p.method,
+		URI:       p.uri,
+		Trace:     p.trace,
+		Route:     p.route.Name,
+		Threshold: p.route.Threshold,
+		Elapsed:   httputils.Duration(now.Sub(p.started)),
+		Started:   p.started,
+	}
+}
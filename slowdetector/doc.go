@@ -0,0 +1,70 @@
+package main
+
+import "github.com/cascades-fbp/cascades/library"
+
+var registryEntry = &library.Entry{
+	Description: `Detects slow requests by correlating requests from IN with responses from RESPONSE by ID. Both
+are forwarded unchanged to OUT and RESP, requests exceeding the threshold of their route are reported to ALERT
+once, either as soon as the threshold passes or when a slow response arrives. With respond enabled such requests
+are answered with 504 Gateway Timeout on RESP and their late responses are dropped. Routes match by method and
+path prefix in order, requests matching none use the default threshold.`,
+	Elementary: true,
+	Inports: []library.EntryPort{
+		library.EntryPort{
+			Name:        "OPTIONS",
+			Type:        "json",
+			Description: `Configuration port, i.e. {"threshold": "2s", "respond": false, "routes": [{"name": "search", "methods": ["GET"], "path": "/api/search", "threshold": "500ms"}, {"path": "/api/reports", "threshold": "30s", "respond": true}]}`,
+			Required:    true,
+		},
+		library.EntryPort{
+			Name:        "IN",
+			Type:        "json",
+			Description: "Input port for requests in predefined JSON format",
+			Required:    true,
+		},
+		library.EntryPort{
+			Name:        "RESPONSE",
+			Type:        "json",
+			Description: "Input port for responses to the requests",
+			Required:    true,
+		},
+	},
+	Outports: []library.EntryPort{
+		library.EntryPort{
+			Name:        "OUT",
+			Type:        "json",
+			Description: "Output port for forwarded requests",
+			Required:    true,
+		},
+		library.EntryPort{
+			Name:        "RESP",
+			Type:        "json",
+			Description: "Output port for forwarded responses and 504 Gateway Timeout responses of timed out requests",
+			Required:    true,
+		},
+		library.EntryPort{
+			Name:        "ALERT",
+			Type:        "json",
+			Description: `Optional output port for slow requests, i.e. {"id": "...", "method": "GET", "uri": "/api/search?q=x", "route": "search", "threshold": "500ms", "elapsed": "1.2s", "started": "2024-05-01T12:00:00Z", "completed": true, "timed_out": false}`,
+			Required:    false,
+		},
+		library.EntryPort{
+			Name:        "ERR",
+			Type:        "json",
+			Description: "Optional error port for invalid IPs (error JSON object defined in utils of HTTP components library)",
+			Required:    false,
+		},
+		library.EntryPort{
+			Name:        "LOG",
+			Type:        "json",
+			Description: "Optional output port for structured log entries (log entry JSON object defined in utils of HTTP components library)",
+			Required:    false,
+		},
+		library.EntryPort{
+			Name:        "HEARTBEAT",
+			Type:        "json",
+			Description: "Optional output port for periodic liveness IPs (heartbeat JSON object defined in utils of HTTP components library)",
+			Required:    false,
+		},
+	},
+}
@@ -0,0 +1,271 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"time"
+
+	httputils "github.com/cascades-fbp/cascades-http/utils"
+	"github.com/cascades-fbp/cascades/components/utils"
+	"github.com/cascades-fbp/cascades/runtime"
+	zmq "github.com/pebbe/zmq4"
+)
+
+var (
+	// Flags
+	optionsEndpoint   = flag.String("port.options", "", "Component's options port endpoint")
+	inputEndpoint     = flag.String("port.in", "", "Component's input port endpoint")
+	responseEndpoint  = flag.String("port.response", "", "Component's response port endpoint")
+	outputEndpoint    = flag.String("port.out", "", "Component's output port endpoint")
+	respEndpoint      = flag.String("port.resp", "", "Component's response output port endpoint")
+	alertEndpoint     = flag.String("port.alert", "", "Component's alert port endpoint")
+	errorEndpoint     = flag.String("port.err", "", "Component's error port endpoint")
+	logEndpoint       = flag.String("port.log", "", "Component's log port endpoint")
+	heartbeatEndpoint = flag.String("port.heartbeat", "", "Component's heartbeat port endpoint")
+	metricsAddr       = flag.String("metrics", "", "Address to expose metrics on, i.e. 127.0.0.1:9090 (disabled if empty)")
+	jsonFlag          = flag.Bool("json", false, "Print component documentation in JSON")
+	debug             = flag.Bool("debug", false, "Enable debug mode")
+
+	// Internal
+	optionsPort, inPort, responsePort, outPort, respPort, alertPort, errPort, logPort, heartbeatPort *zmq.Socket
+	err                                                                                              error
+	logger                                                                                           = httputils.NewLogger("http/slowdetector")
+	liveness                                                                                         = httputils.NewLiveness("http/slowdetector")
+	metrics                                                                                          = httputils.NewMetrics(logger, liveness)
+	shutdown                                                                                         *httputils.Shutdown
+)
+
+// validateArgs checks all required flags
+func validateArgs() {
+	if *optionsEndpoint == "" {
+		flag.Usage()
+		os.Exit(1)
+	}
+	if *inputEndpoint == "" || *responseEndpoint == "" {
+		flag.Usage()
+		os.Exit(1)
+	}
+	if *outputEndpoint == "" || *respEndpoint == "" {
+		flag.Usage()
+		os.Exit(1)
+	}
+}
+
+// openPorts create ZMQ sockets
+func openPorts() {
+	optionsPort, err = utils.CreateInputPort("http/slowdetector.options", *optionsEndpoint, nil)
+	utils.AssertError(err)
+
+	inPort, err = utils.CreateInputPort("http/slowdetector.in", *inputEndpoint, nil)
+	utils.AssertError(err)
+
+	responsePort, err = utils.CreateInputPort("http/slowdetector.response", *responseEndpoint, nil)
+	utils.AssertError(err)
+
+	outPort, err = utils.CreateOutputPort("http/slowdetector.out", *outputEndpoint, nil)
+	utils.AssertError(err)
+
+	respPort, err = utils.CreateOutputPort("http/slowdetector.resp", *respEndpoint, nil)
+	utils.AssertError(err)
+
+	if *alertEndpoint != "" {
+		alertPort, err = utils.CreateOutputPort("http/slowdetector.alert", *alertEndpoint, nil)
+		utils.AssertError(err)
+	}
+
+	if *errorEndpoint != "" {
+		errPort, err = utils.CreateOutputPort("http/slowdetector.err", *errorEndpoint, nil)
+		utils.AssertError(err)
+	}
+
+	if *logEndpoint != "" {
+		logPort, err = utils.CreateOutputPort("http/slowdetector.log", *logEndpoint, nil)
+		utils.AssertError(err)
+		logger.SetSink(func(ip [][]byte) { logPort.SendMessageDontwait(ip) })
+	}
+
+	if *heartbeatEndpoint != "" {
+		heartbeatPort, err = utils.CreateOutputPort("http/slowdetector.heartbeat", *heartbeatEndpoint, nil)
+		utils.AssertError(err)
+		liveness.Start(func(ip [][]byte) { heartbeatPort.SendMessageDontwait(ip) })
+	}
+}
+
+// closePorts closes all active ports and terminates ZMQ context
+func closePorts() {
+	shutdown.Close(optionsPort, inPort, responsePort)
+	liveness.Stop()
+	shutdown.Flush(outPort, respPort, alertPort, errPort, heartbeatPort, logPort)
+	zmq.Term()
+}
+
+func main() {
+	flag.Parse()
+
+	if *jsonFlag {
+		doc, _ := registryEntry.JSON()
+		fmt.Println(string(doc))
+		os.Exit(0)
+	}
+
+	log.SetFlags(0)
+	if *debug {
+		logger.SetLevel(httputils.LevelDebug)
+		logger.SetOutput(os.Stdout)
+	}
+	log.SetOutput(logger.Writer())
+
+	validateArgs()
+
+	shutdown = httputils.NewShutdown(logger)
+	openPorts()
+
+	if *metricsAddr != "" {
+		err = metrics.Serve(*metricsAddr)
+		utils.AssertError(err)
+	}
+
+	err = runtime.SetupShutdownByDisconnect(inPort, "http/slowdetector.in", shutdown.Signals())
+	utils.AssertError(err)
+
+	// Wait for the configuration on the options port
+	var detector *Detector
+	for detector == nil {
+		log.Println("Waiting for configuration...")
+		ip, err := shutdown.Receive(optionsPort)
+		if err == httputils.ErrShutdown {
+			shutdown.Exit(closePorts)
+		}
+		if err != nil {
+			logger.Error("Error receiving IP", "error", err)
+			continue
+		}
+		if !runtime.IsValidIP(ip) || !runtime.IsPacket(ip) {
+			continue
+		}
+		options := &Options{}
+		if err = json.Unmarshal(ip[1], options); err != nil {
+			logger.Error("Failed to unmarshal options", "error", err)
+			continue
+		}
+		if detector, err = NewDetector(options); err != nil {
+			logger.Error("Invalid options", "error", err)
+		}
+	}
+	optionsPort.Close()
+	optionsPort = nil
+
+	poller := zmq.NewPoller()
+	poller.Add(inPort, zmq.POLLIN)
+	poller.Add(responsePort, zmq.POLLIN)
+
+	// Main loop
+	for !shutdown.Stopping() {
+		timeout := shutdown.PollInterval
+		if next, ok := detector.Next(time.Now()); ok && next < timeout {
+			// Negative timeout would block until a message arrives
+			timeout = next
+			if timeout < 0 {
+				timeout = 0
+			}
+		}
+		sockets, err := poller.Poll(timeout)
+		if err != nil {
+			logger.Error("Error polling ports", "error", err)
+			continue
+		}
+
+		for _, socket := range sockets {
+			ip, err := socket.Socket.RecvMessageBytes(0)
+			if err != nil {
+				logger.Error("Error receiving message", "error", err)
+				continue
+			}
+			liveness.Inc()
+			if !httputils.IsValidIP(ip) {
+				logger.Warn("Received invalid IP")
+				continue
+			}
+
+			switch socket.Socket {
+			case inPort:
+				outPort.SendMessage(ip)
+				if !runtime.IsPacket(ip) {
+					continue
+				}
+				req, err := httputils.IP2Request(ip)
+				if err != nil {
+					logger.Warn("Failed to convert IP to request", "error", err)
+					sendError(httputils.NewError("http/slowdetector", httputils.ErrInvalidIP, err))
+					continue
+				}
+				detector.Start(req, time.Now())
+
+			case responsePort:
+				if !runtime.IsPacket(ip) {
+					respPort.SendMessage(ip)
+					continue
+				}
+				resp, err := httputils.IP2Response(ip)
+				if err != nil {
+					logger.Warn("Failed to convert IP to response", "error", err)
+					sendError(httputils.NewError("http/slowdetector", httputils.ErrInvalidIP, err))
+					respPort.SendMessage(ip)
+					continue
+				}
+				alert, drop := detector.Finish(resp.ID, time.Now())
+				if drop {
+					logger.Debug("Dropped response of a timed out request", "id", resp.ID)
+					continue
+				}
+				respPort.SendMessage(ip)
+				if alert != nil {
+					sendAlert(alert)
+				}
+			}
+		}
+
+		for _, alert := range detector.Expire(time.Now()) {
+			if alert.TimedOut {
+				respPort.SendMessage(httputils.NewResponse(http.StatusGatewayTimeout).
+					WithID(alert.ID).
+					WithTrace(alert.Trace).
+					WithText("Request exceeded %s", time.Duration(alert.Threshold)).
+					MustIP())
+			}
+			sendAlert(alert)
+		}
+	}
+	shutdown.Exit(closePorts)
+}
+
+// sendAlert logs a slow request and reports it to the ALERT port if it's connected
+func sendAlert(alert *Alert) {
+	logger.Warn("Slow request", "id", alert.ID, "method", alert.Method, "uri", alert.URI, "route", alert.Route,
+		"threshold", time.Duration(alert.Threshold), "elapsed", time.Duration(alert.Elapsed), "timed_out", alert.TimedOut)
+	if alertPort == nil {
+		return
+	}
+	payload, err := json.Marshal(alert)
+	if err != nil {
+		logger.Error("Failed to marshal alert", "error", err)
+		return
+	}
+	alertPort.SendMessage(runtime.NewPacket(payload))
+}
+
+// sendError reports a failure to the ERR port if it's connected
+func sendError(e *httputils.Error) {
+	if errPort == nil {
+		return
+	}
+	ip, err := httputils.Error2IP(e)
+	if err != nil {
+		return
+	}
+	errPort.SendMessageDontwait(ip)
+}
@@ -0,0 +1,56 @@
+package main
+
+import "github.com/cascades-fbp/cascades/library"
+
+var registryEntry = &library.Entry{
+	Description: `Splits JSON responses in predefined format into ID, STATUS, HEADERS and BODY output ports.
+Every response is emitted as a bracketed substream on each connected port, so parts of the same
+response arriving at different ports can be tied together.`,
+	Elementary: true,
+	Inports: []library.EntryPort{
+		library.EntryPort{
+			Name:        "IN",
+			Type:        "json",
+			Description: "Input port for responses in predefined JSON format",
+			Required:    true,
+		},
+	},
+	Outports: []library.EntryPort{
+		library.EntryPort{
+			Name:        "ID",
+			Type:        "string",
+			Description: "Output port for response IDs",
+			Required:    false,
+		},
+		library.EntryPort{
+			Name:        "STATUS",
+			Type:        "string",
+			Description: "Output port for response status codes",
+			Required:    false,
+		},
+		library.EntryPort{
+			Name:        "HEADERS",
+			Type:        "json",
+			Description: "Output port for response headers as JSON object",
+			Required:    false,
+		},
+		library.EntryPort{
+			Name:        "BODY",
+			Type:        "string",
+			Description: "Output port for response bodies",
+			Required:    false,
+		},
+		library.EntryPort{
+			Name:        "LOG",
+			Type:        "json",
+			Description: "Optional output port for structured log entries (log entry JSON object defined in utils of HTTP components library)",
+			Required:    false,
+		},
+		library.EntryPort{
+			Name:        "HEARTBEAT",
+			Type:        "json",
+			Description: "Optional output port for periodic liveness IPs (heartbeat JSON object defined in utils of HTTP components library)",
+			Required:    false,
+		},
+	},
+}
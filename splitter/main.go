@@ -0,0 +1,167 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"strconv"
+
+	httputils "github.com/cascades-fbp/cascades-http/utils"
+	"github.com/cascades-fbp/cascades/components/utils"
+	"github.com/cascades-fbp/cascades/runtime"
+	zmq "github.com/pebbe/zmq4"
+)
+
+var (
+	// Flags
+	inputEndpoint     = flag.String("port.in", "", "Component's input port endpoint")
+	idEndpoint        = flag.String("port.id", "", "Component's ID output port endpoint")
+	statusEndpoint    = flag.String("port.status", "", "Component's status output port endpoint")
+	headersEndpoint   = flag.String("port.headers", "", "Component's headers output port endpoint")
+	bodyEndpoint      = flag.String("port.body", "", "Component's body output port endpoint")
+	logEndpoint       = flag.String("port.log", "", "Component's log port endpoint")
+	heartbeatEndpoint = flag.String("port.heartbeat", "", "Component's heartbeat port endpoint")
+	metricsAddr       = flag.String("metrics", "", "Address to expose metrics on, i.e. 127.0.0.1:9090 (disabled if empty)")
+	jsonFlag          = flag.Bool("json", false, "Print component documentation in JSON")
+	debug             = flag.Bool("debug", false, "Enable debug mode")
+
+	// Internal
+	inPort, idPort, statusPort, headersPort, bodyPort, logPort, heartbeatPort *zmq.Socket
+	err                                                                       error
+	logger                                                                    = httputils.NewLogger("http/splitter")
+	liveness                                                                  = httputils.NewLiveness("http/splitter")
+	metrics                                                                   = httputils.NewMetrics(logger, liveness)
+	shutdown                                                                  *httputils.Shutdown
+)
+
+// validateArgs checks all required flags
+func validateArgs() {
+	if *inputEndpoint == "" {
+		flag.Usage()
+		os.Exit(1)
+	}
+	if *idEndpoint == "" && *statusEndpoint == "" && *headersEndpoint == "" && *bodyEndpoint == "" {
+		flag.Usage()
+		os.Exit(1)
+	}
+}
+
+// openPorts create ZMQ sockets
+func openPorts() {
+	inPort, err = utils.CreateInputPort("http/splitter.in", *inputEndpoint, nil)
+	utils.AssertError(err)
+
+	if *idEndpoint != "" {
+		idPort, err = utils.CreateOutputPort("http/splitter.id", *idEndpoint, nil)
+		utils.AssertError(err)
+	}
+	if *statusEndpoint != "" {
+		statusPort, err = utils.CreateOutputPort("http/splitter.status", *statusEndpoint, nil)
+		utils.AssertError(err)
+	}
+	if *headersEndpoint != "" {
+		headersPort, err = utils.CreateOutputPort("http/splitter.headers", *headersEndpoint, nil)
+		utils.AssertError(err)
+	}
+	if *bodyEndpoint != "" {
+		bodyPort, err = utils.CreateOutputPort("http/splitter.body", *bodyEndpoint, nil)
+		utils.AssertError(err)
+	}
+
+	if *logEndpoint != "" {
+		logPort, err = utils.CreateOutputPort("http/splitter.log", *logEndpoint, nil)
+		utils.AssertError(err)
+		logger.SetSink(func(ip [][]byte) { logPort.SendMessageDontwait(ip) })
+	}
+
+	if *heartbeatEndpoint != "" {
+		heartbeatPort, err = utils.CreateOutputPort("http/splitter.heartbeat", *heartbeatEndpoint, nil)
+		utils.AssertError(err)
+		liveness.Start(func(ip [][]byte) { heartbeatPort.SendMessageDontwait(ip) })
+	}
+}
+
+// closePorts closes all active ports and terminates ZMQ context
+func closePorts() {
+	shutdown.Close(inPort)
+	liveness.Stop()
+	shutdown.Flush(idPort, statusPort, headersPort, bodyPort, heartbeatPort, logPort)
+	zmq.Term()
+}
+
+// sendSubstream wraps data into brackets and sends it to a given port (if connected)
+func sendSubstream(port *zmq.Socket, data []byte) {
+	if port == nil {
+		return
+	}
+	port.SendMessage(runtime.NewOpenBracket())
+	port.SendMessage(runtime.NewPacket(data))
+	port.SendMessage(runtime.NewCloseBracket())
+}
+
+func main() {
+	flag.Parse()
+
+	if *jsonFlag {
+		doc, _ := registryEntry.JSON()
+		fmt.Println(string(doc))
+		os.Exit(0)
+	}
+
+	log.SetFlags(0)
+	if *debug {
+		logger.SetLevel(httputils.LevelDebug)
+		logger.SetOutput(os.Stdout)
+	}
+	log.SetOutput(logger.Writer())
+
+	validateArgs()
+
+	shutdown = httputils.NewShutdown(logger)
+	openPorts()
+
+	if *metricsAddr != "" {
+		err = metrics.Serve(*metricsAddr)
+		utils.AssertError(err)
+	}
+
+	err = runtime.SetupShutdownByDisconnect(inPort, "http/splitter.in", shutdown.Signals())
+	utils.AssertError(err)
+
+	// Process incoming messages until shutdown
+	for {
+		ip, err := shutdown.Receive(inPort)
+		if err == httputils.ErrShutdown {
+			break
+		}
+		if err != nil {
+			logger.Error("Error receiving message", "error", err)
+			continue
+		}
+		liveness.Inc()
+		if !httputils.IsValidIP(ip) || !runtime.IsPacket(ip) {
+			logger.Warn("Received invalid IP")
+			continue
+		}
+
+		resp, err := httputils.IP2Response(ip)
+		if err != nil {
+			logger.Warn("Error converting IP to response", "error", err)
+			continue
+		}
+
+		headers, err := json.Marshal(resp.Header)
+		if err != nil {
+			logger.Error("Error marshaling headers", "error", err)
+			continue
+		}
+
+		sendSubstream(idPort, []byte(resp.ID))
+		sendSubstream(statusPort, []byte(strconv.Itoa(resp.StatusCode)))
+		sendSubstream(headersPort, headers)
+		sendSubstream(bodyPort, resp.Body)
+	}
+	shutdown.Exit(closePorts)
+}
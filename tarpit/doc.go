@@ -0,0 +1,58 @@
+package main
+
+import "github.com/cascades-fbp/cascades/library"
+
+var registryEntry = &library.Entry{
+	Description: `Answers requests flagged by upstream security components (i.e. BOT port of botdetect) with a
+delay instead of rejecting them immediately. Every further request of a client within the memory interval waits for
+the next step of the schedule, the last one repeats. Responses are held in order of their due time up to max_pending
+and sent whole once due, so delays should stay below the request timeout of the server. Every tarpitted request is
+reported on AUDIT port.`,
+	Elementary: true,
+	Inports: []library.EntryPort{
+		library.EntryPort{
+			Name:        "OPTIONS",
+			Type:        "json",
+			Description: `Configuration port, i.e. {"schedule": ["1s", "3s", "5s", "10s"], "jitter": 0.2, "memory": "10m", "max_pending": 1000, "status": 429, "body": "Too many requests", "headers": {"Retry-After": "60"}}`,
+			Required:    true,
+		},
+		library.EntryPort{
+			Name:        "IN",
+			Type:        "json",
+			Description: "Input port for flagged requests in predefined JSON format",
+			Required:    true,
+		},
+	},
+	Outports: []library.EntryPort{
+		library.EntryPort{
+			Name:        "RESPONSE",
+			Type:        "json",
+			Description: "Output port for delayed responses",
+			Required:    true,
+		},
+		library.EntryPort{
+			Name:        "AUDIT",
+			Type:        "json",
+			Description: `Optional output port for audit records, i.e. {"time": "2024-01-01T12:00:00Z", "id": "...", "client": "203.0.113.7", "method": "GET", "uri": "/login", "strike": 3, "delay_ms": 5000}`,
+			Required:    false,
+		},
+		library.EntryPort{
+			Name:        "ERR",
+			Type:        "json",
+			Description: "Optional error port for invalid IPs (error JSON object defined in utils of HTTP components library)",
+			Required:    false,
+		},
+		library.EntryPort{
+			Name:        "LOG",
+			Type:        "json",
+			Description: "Optional output port for structured log entries (log entry JSON object defined in utils of HTTP components library)",
+			Required:    false,
+		},
+		library.EntryPort{
+			Name:        "HEARTBEAT",
+			Type:        "json",
+			Description: "Optional output port for periodic liveness IPs (heartbeat JSON object defined in utils of HTTP components library)",
+			Required:    false,
+		},
+	},
+}
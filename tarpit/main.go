@@ -0,0 +1,264 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"time"
+
+	httputils "github.com/cascades-fbp/cascades-http/utils"
+	"github.com/cascades-fbp/cascades/components/utils"
+	"github.com/cascades-fbp/cascades/runtime"
+	zmq "github.com/pebbe/zmq4"
+)
+
+var (
+	// Flags
+	optionsEndpoint   = flag.String("port.options", "", "Component's options port endpoint")
+	inputEndpoint     = flag.String("port.in", "", "Component's input port endpoint")
+	responseEndpoint  = flag.String("port.response", "", "Component's response port endpoint")
+	auditEndpoint     = flag.String("port.audit", "", "Component's audit port endpoint")
+	errorEndpoint     = flag.String("port.err", "", "Component's error port endpoint")
+	logEndpoint       = flag.String("port.log", "", "Component's log port endpoint")
+	heartbeatEndpoint = flag.String("port.heartbeat", "", "Component's heartbeat port endpoint")
+	metricsAddr       = flag.String("metrics", "", "Address to expose metrics on, i.e. 127.0.0.1:9090 (disabled if empty)")
+	jsonFlag          = flag.Bool("json", false, "Print component documentation in JSON")
+	debug             = flag.Bool("debug", false, "Enable debug mode")
+
+	// Internal
+	optionsPort, inPort, responsePort, auditPort, errPort, logPort, heartbeatPort *zmq.Socket
+	err                                                                           error
+	logger                                                                        = httputils.NewLogger("http/tarpit")
+	liveness                                                                      = httputils.NewLiveness("http/tarpit")
+	metrics                                                                       = httputils.NewMetrics(logger, liveness)
+	shutdown                                                                      *httputils.Shutdown
+)
+
+// validateArgs checks all required flags
+func validateArgs() {
+	if *optionsEndpoint == "" {
+		flag.Usage()
+		os.Exit(1)
+	}
+	if *inputEndpoint == "" {
+		flag.Usage()
+		os.Exit(1)
+	}
+	if *responseEndpoint == "" {
+		flag.Usage()
+		os.Exit(1)
+	}
+}
+
+// openPorts create ZMQ sockets
+func openPorts() {
+	optionsPort, err = utils.CreateInputPort("http/tarpit.options", *optionsEndpoint, nil)
+	utils.AssertError(err)
+
+	inPort, err = utils.CreateInputPort("http/tarpit.in", *inputEndpoint, nil)
+	utils.AssertError(err)
+
+	responsePort, err = utils.CreateOutputPort("http/tarpit.response", *responseEndpoint, nil)
+	utils.AssertError(err)
+
+	if *auditEndpoint != "" {
+		auditPort, err = utils.CreateOutputPort("http/tarpit.audit", *auditEndpoint, nil)
+		utils.AssertError(err)
+	}
+
+	if *errorEndpoint != "" {
+		errPort, err = utils.CreateOutputPort("http/tarpit.err", *errorEndpoint, nil)
+		utils.AssertError(err)
+	}
+
+	if *logEndpoint != "" {
+		logPort, err = utils.CreateOutputPort("http/tarpit.log", *logEndpoint, nil)
+		utils.AssertError(err)
+		logger.SetSink(func(ip [][]byte) { logPort.SendMessageDontwait(ip) })
+	}
+
+	if *heartbeatEndpoint != "" {
+		heartbeatPort, err = utils.CreateOutputPort("http/tarpit.heartbeat", *heartbeatEndpoint, nil)
+		utils.AssertError(err)
+		liveness.Start(func(ip [][]byte) { heartbeatPort.SendMessageDontwait(ip) })
+	}
+}
+
+// closePorts closes all active ports and terminates ZMQ context
+func closePorts() {
+	shutdown.Close(optionsPort, inPort)
+	liveness.Stop()
+	shutdown.Flush(responsePort, auditPort, errPort, heartbeatPort, logPort)
+	zmq.Term()
+}
+
+func main() {
+	flag.Parse()
+
+	if *jsonFlag {
+		doc, _ := registryEntry.JSON()
+		fmt.Println(string(doc))
+		os.Exit(0)
+	}
+
+	log.SetFlags(0)
+	if *debug {
+		logger.SetLevel(httputils.LevelDebug)
+		logger.SetOutput(os.Stdout)
+	}
+	log.SetOutput(logger.Writer())
+
+	validateArgs()
+
+	shutdown = httputils.NewShutdown(logger)
+	openPorts()
+
+	if *metricsAddr != "" {
+		err = metrics.Serve(*metricsAddr)
+		utils.AssertError(err)
+	}
+
+	err = runtime.SetupShutdownByDisconnect(inPort, "http/tarpit.in", shutdown.Signals())
+	utils.AssertError(err)
+
+	// Wait for the configuration on the options port
+	options := &Options{
+		Memory:     httputils.Duration(10 * time.Minute),
+		MaxPending: 1000,
+		Status:     http.StatusTooManyRequests,
+		Body:       "Too many requests",
+	}
+	for {
+		log.Println("Waiting for configuration...")
+		ip, err := shutdown.Receive(optionsPort)
+		if err == httputils.ErrShutdown {
+			shutdown.Exit(closePorts)
+		}
+		if err != nil {
+			logger.Error("Error receiving IP", "error", err)
+			continue
+		}
+		if !runtime.IsValidIP(ip) || !runtime.IsPacket(ip) {
+			continue
+		}
+		if err = json.Unmarshal(ip[1], options); err != nil {
+			logger.Error("Failed to unmarshal options", "error", err)
+			continue
+		}
+		break
+	}
+	optionsPort.Close()
+	optionsPort = nil
+	if len(options.Schedule) == 0 {
+		for _, d := range []time.Duration{time.Second, 3 * time.Second, 5 * time.Second, 10 * time.Second} {
+			options.Schedule = append(options.Schedule, httputils.Duration(d))
+		}
+	}
+
+	pit := NewPit(options)
+
+	poller := zmq.NewPoller()
+	poller.Add(inPort, zmq.POLLIN)
+
+	// Main loop
+	for !shutdown.Stopping() {
+		timeout := shutdown.PollInterval
+		if next, ok := pit.Next(time.Now()); ok && next < timeout {
+			// Negative timeout would block until a message arrives
+			timeout = next
+			if timeout < 0 {
+				timeout = 0
+			}
+		}
+		sockets, err := poller.Poll(timeout)
+		if err != nil {
+			logger.Error("Error polling ports", "error", err)
+			continue
+		}
+
+		for _, socket := range sockets {
+			ip, err := socket.Socket.RecvMessageBytes(0)
+			if err != nil {
+				logger.Error("Error receiving message", "error", err)
+				continue
+			}
+			liveness.Inc()
+			if !httputils.IsValidIP(ip) || !runtime.IsPacket(ip) {
+				continue
+			}
+			req, err := httputils.IP2Request(ip)
+			if err != nil {
+				logger.Warn("Failed to convert IP to request", "error", err)
+				sendError(httputils.NewError("http/tarpit", httputils.ErrInvalidIP, err))
+				continue
+			}
+			trap(pit, options, req, time.Now())
+		}
+
+		for _, ip := range pit.Release(time.Now()) {
+			responsePort.SendMessage(ip)
+		}
+	}
+
+	// Held clients are answered right away instead of timing out
+	for _, ip := range pit.Drain() {
+		responsePort.SendMessage(ip)
+	}
+	shutdown.Exit(closePorts)
+}
+
+// trap holds the response to a request for the delay of its client's strike
+func trap(pit *Pit, options *Options, req *httputils.HTTPRequest, now time.Time) {
+	client := req.ClientIP(options.TrustForwarded)
+	strike, delay := pit.Strike(client, now)
+
+	resp := httputils.NewResponse(options.Status).WithID(req.ID).WithTrace(req.Trace).WithText("%s", options.Body)
+	for k, v := range options.Headers {
+		resp.SetHeader(k, v)
+	}
+	ip, err := httputils.Response2IP(resp)
+	if err != nil {
+		logger.Error("Failed to convert response to IP", "error", err)
+		sendError(httputils.NewError("http/tarpit", httputils.ErrInternal, err).WithRequest(req.ID))
+		return
+	}
+	if !pit.Hold(ip, now.Add(delay)) {
+		logger.Warn("Tarpit is full, responding immediately", "id", req.ID, "client", client)
+		delay = 0
+		responsePort.SendMessage(ip)
+	}
+	logger.Debug("Tarpitted request", "id", req.ID, "client", client, "strike", strike, "delay", delay)
+
+	if auditPort != nil {
+		audit := &Audit{
+			Time:      now.UTC().Format(time.RFC3339Nano),
+			ID:        req.ID,
+			Client:    client,
+			Method:    req.Method,
+			URI:       httputils.Redaction.URL(req.URI),
+			UserAgent: req.GetHeader("User-Agent"),
+			Strike:    strike,
+			Delay:     float64(delay) / float64(time.Millisecond),
+		}
+		if req.Bot != nil {
+			audit.Reasons = req.Bot.Reasons
+		}
+		data, _ := json.Marshal(audit)
+		auditPort.SendMessage(runtime.NewPacket(data))
+	}
+}
+
+// sendError reports a failure to the ERR port if it's connected
+func sendError(e *httputils.Error) {
+	if errPort == nil {
+		return
+	}
+	ip, err := httputils.Error2IP(e)
+	if err != nil {
+		return
+	}
+	errPort.SendMessageDontwait(ip)
+}
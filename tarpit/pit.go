@@ -0,0 +1,141 @@
+package main
+
+import (
+	"container/heap"
+	"math/rand"
+	"time"
+
+	httputils "github.com/cascades-fbp/cascades-http/utils"
+)
+
+// Options describe the configuration IP of the component
+type Options struct {
+	Schedule       []httputils.Duration `json:"schedule"`        // Delay of the n-th request of a client, the last one repeats
+	Jitter         float64              `json:"jitter"`          // Random fraction added to delays, i.e. 0.2
+	Memory         httputils.Duration   `json:"memory"`          // Strikes of a client are forgotten after this quiet interval
+	MaxPending     int                  `json:"max_pending"`     // Responses held at most, others are sent immediately
+	Status         int                  `json:"status"`          // Status of responses
+	Body           string               `json:"body"`            // Body of responses
+	Headers        map[string]string    `json:"headers"`         // Headers of responses
+	TrustForwarded bool                 `json:"trust_forwarded"` // Count strikes by X-Forwarded-For address
+}
+
+// Audit is emitted to AUDIT port for every tarpitted request
+type Audit struct {
+	Time      string   `json:"time"`
+	ID        string   `json:"id"`
+	Client    string   `json:"client"`
+	Method    string   `json:"method"`
+	URI       string   `json:"uri"`
+	UserAgent string   `json:"user_agent,omitempty"`
+	Strike    int      `json:"strike"`            // Number of tarpitted requests of the client
+	Delay     float64  `json:"delay_ms"`          // How long the response is held
+	Reasons   []string `json:"reasons,omitempty"` // Signals of botdetect component if scored
+}
+
+// held is a response waiting for its due time
+type held struct {
+	due time.Time
+	ip  [][]byte
+}
+
+// heldQueue is a min-heap of held responses by due time
+type heldQueue []held
+
+func (q heldQueue) Len() int            { return len(q) }
+func (q heldQueue) Less(i, j int) bool  { return q[i].due.Before(q[j].due) }
+func (q heldQueue) Swap(i, j int)       { q[i], q[j] = q[j], q[i] }
+func (q *heldQueue) Push(x interface{}) { *q = append(*q, x.(held)) }
+func (q *heldQueue) Pop() interface{} {
+	old := *q
+	h := old[len(old)-1]
+	*q = old[:len(old)-1]
+	return h
+}
+
+// strikes of a client
+type strikes struct {
+	count int
+	last  time.Time
+}
+
+// Pit holds responses of clients for increasing delays, it isn't safe for
+// concurrent use
+type Pit struct {
+	options *Options
+	clients map[string]*strikes
+	queue   heldQueue
+	cleaned time.Time
+}
+
+// NewPit creates a pit, options are expected to have defaults applied
+func NewPit(options *Options) *Pit {
+	return &Pit{options: options, clients: make(map[string]*strikes)}
+}
+
+// Strike records a request of a client returning its number and delay
+func (p *Pit) Strike(client string, now time.Time) (int, time.Duration) {
+	memory := time.Duration(p.options.Memory)
+	if now.Sub(p.cleaned) > memory {
+		for k, s := range p.clients {
+			if now.Sub(s.last) > memory {
+				delete(p.clients, k)
+			}
+		}
+		p.cleaned = now
+	}
+	s, ok := p.clients[client]
+	if !ok || now.Sub(s.last) > memory {
+		s = &strikes{}
+		p.clients[client] = s
+	}
+	s.count++
+	s.last = now
+
+	schedule := p.options.Schedule
+	i := s.count - 1
+	if i >= len(schedule) {
+		i = len(schedule) - 1
+	}
+	delay := time.Duration(schedule[i])
+	if p.options.Jitter > 0 {
+		delay += time.Duration(rand.Float64() * p.options.Jitter * float64(delay))
+	}
+	return s.count, delay
+}
+
+// Hold queues a response until due, false is returned if the pit is full
+func (p *Pit) Hold(ip [][]byte, due time.Time) bool {
+	if len(p.queue) >= p.options.MaxPending {
+		return false
+	}
+	heap.Push(&p.queue, held{due: due, ip: ip})
+	return true
+}
+
+// Release returns responses due at a given time
+func (p *Pit) Release(now time.Time) [][][]byte {
+	var ips [][][]byte
+	for len(p.queue) > 0 && !p.queue[0].due.After(now) {
+		ips = append(ips, heap.Pop(&p.queue).(held).ip)
+	}
+	return ips
+}
+
+// Next returns how long until the next response is due, ok is false if
+// nothing is held
+func (p *Pit) Next(now time.Time) (time.Duration, bool) {
+	if len(p.queue) == 0 {
+		return 0, false
+	}
+	return p.queue[0].due.Sub(now), true
+}
+
+// Drain returns all held responses regardless of their due time
+func (p *Pit) Drain() [][][]byte {
+	var ips [][][]byte
+	for len(p.queue) > 0 {
+		ips = append(ips, heap.Pop(&p.queue).(held).ip)
+	}
+	return ips
+}
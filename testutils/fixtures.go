@@ -0,0 +1,53 @@
+// Package testutils provides fixtures and helpers for testing cascades-http components:
+// canned request/response IPs, golden-file comparison, zmq pipes for tests and
+// graphs of component binaries wired over ipc:// endpoints.
+package testutils
+
+import (
+	"net/http"
+	"net/url"
+
+	httputils "github.com/cascades-fbp/cascades-http/utils"
+)
+
+// FixtureID is the request ID used by all fixtures
+const FixtureID = "00000000-0000-4000-8000-000000000000"
+
+// GetRequest returns a GET request as the server component would emit it
+func GetRequest(uri string) *httputils.HTTPRequest {
+	return httputils.NewRequest("GET", uri).
+		WithID(FixtureID).
+		WithHeader("Accept", "*/*").
+		WithHeader("User-Agent", "testutils")
+}
+
+// FormRequest returns a POST request with urlencoded form body
+func FormRequest(uri string, form map[string]string) *httputils.HTTPRequest {
+	req := httputils.NewRequest("POST", uri).WithID(FixtureID)
+	values := url.Values{}
+	for k, v := range form {
+		req.WithForm(k, v)
+		values.Set(k, v)
+	}
+	return req.WithBody("application/x-www-form-urlencoded", []byte(values.Encode()))
+}
+
+// JSONRequest returns a request with JSON encoding of a given value as body
+func JSONRequest(method, uri string, v interface{}) *httputils.HTTPRequest {
+	return httputils.NewRequest(method, uri).WithID(FixtureID).WithJSONBody(v)
+}
+
+// TextResponse returns a response with plain text body
+func TextResponse(status int, text string) *httputils.HTTPResponse {
+	return httputils.NewResponse(status).WithID(FixtureID).WithText("%s", text)
+}
+
+// JSONResponse returns a response with JSON encoding of a given value as body
+func JSONResponse(status int, v interface{}) *httputils.HTTPResponse {
+	return httputils.NewResponse(status).WithID(FixtureID).WithJSON(v)
+}
+
+// NotFound returns an empty 404 response
+func NotFound() *httputils.HTTPResponse {
+	return httputils.NewResponse(http.StatusNotFound).WithID(FixtureID)
+}
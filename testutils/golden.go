@@ -0,0 +1,62 @@
+package testutils
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"unicode/utf8"
+)
+
+// UpdateGoldenEnv is the environment variable which, set to 1, makes AssertGolden
+// rewrite golden files
+const UpdateGoldenEnv = "UPDATE_GOLDEN"
+
+// AssertGolden compares a serialized IP with testdata/<name>.golden. Running tests
+// with UPDATE_GOLDEN=1 rewrites golden files with the actual IPs.
+func AssertGolden(t testing.TB, name string, ip [][]byte) {
+	t.Helper()
+	actual := RenderIP(ip)
+	path := filepath.Join("testdata", name+".golden")
+	if os.Getenv(UpdateGoldenEnv) == "1" {
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatalf("Failed to create testdata: %v", err)
+		}
+		if err := ioutil.WriteFile(path, actual, 0644); err != nil {
+			t.Fatalf("Failed to update golden file: %v", err)
+		}
+		return
+	}
+	expected, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatalf("Failed to read golden file (run with UPDATE_GOLDEN=1 to create it): %v", err)
+	}
+	if !bytes.Equal(expected, actual) {
+		t.Errorf("IP doesn't match %s\n--- expected\n%s\n--- actual\n%s", path, expected, actual)
+	}
+}
+
+// RenderIP formats IP frames in a stable human readable form: JSON payloads are
+// indented, other text is kept as is and binary frames are base64 encoded
+func RenderIP(ip [][]byte) []byte {
+	var buf bytes.Buffer
+	for i, frame := range ip {
+		fmt.Fprintf(&buf, "--- frame %d\n", i)
+		var pretty bytes.Buffer
+		switch {
+		case json.Valid(frame) && json.Indent(&pretty, frame, "", "  ") == nil:
+			buf.Write(pretty.Bytes())
+		case utf8.Valid(frame):
+			buf.Write(frame)
+		default:
+			buf.WriteString("base64:")
+			buf.WriteString(base64.StdEncoding.EncodeToString(frame))
+		}
+		buf.WriteByte('\n')
+	}
+	return buf.Bytes()
+}
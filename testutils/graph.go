@@ -0,0 +1,199 @@
+package testutils
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"sync"
+	"syscall"
+	"time"
+
+	zmq "github.com/pebbe/zmq4"
+)
+
+// modulePath is the import path prefix of components
+const modulePath = "github.com/cascades-fbp/cascades-http/"
+
+// stopTimeout is how long a stopped component may take to exit before it's killed
+const stopTimeout = 5 * time.Second
+
+// binaries caches built components between graphs of a test binary
+var (
+	binariesMu sync.Mutex
+	binaries   = map[string]string{}
+	binDir     string
+)
+
+// T is the part of testing.TB used by graphs, so they can also run outside of
+// tests, i.e. in cmd/examples
+type T interface {
+	Helper()
+	Log(args ...interface{})
+	Fatalf(format string, args ...interface{})
+	TempDir() string
+	Cleanup(f func())
+}
+
+// Graph runs component binaries wired over ipc:// endpoints living in a temporary
+// directory. Components and sockets are stopped when the test finishes.
+type Graph struct {
+	t       T
+	dir     string
+	procs   []*exec.Cmd
+	sockets []*zmq.Socket
+}
+
+// NewGraph creates an empty graph for a given test
+func NewGraph(t T) *Graph {
+	t.Helper()
+	g := &Graph{t: t, dir: t.TempDir()}
+	t.Cleanup(g.Stop)
+	return g
+}
+
+// Endpoint returns ipc:// endpoint for a given connection name
+func (g *Graph) Endpoint(name string) string {
+	return "ipc://" + filepath.Join(g.dir, name+".ipc")
+}
+
+// Start builds (once per test binary) and launches a component, i.e. "router", with
+// ports given as a map of port name to endpoint. Output of the component is added
+// to the test log.
+func (g *Graph) Start(component string, ports map[string]string, args ...string) {
+	g.t.Helper()
+	bin := build(g.t, component)
+
+	names := make([]string, 0, len(ports))
+	for name := range ports {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	flags := []string{"-debug"}
+	for _, name := range names {
+		flags = append(flags, fmt.Sprintf("-port.%s=%s", name, ports[name]))
+	}
+
+	cmd := exec.Command(bin, append(flags, args...)...)
+	w := &logWriter{t: g.t, prefix: component + ": "}
+	cmd.Stdout = w
+	cmd.Stderr = w
+	if err := cmd.Start(); err != nil {
+		g.t.Fatalf("Failed to start %s: %v", component, err)
+	}
+	g.procs = append(g.procs, cmd)
+}
+
+// Inject sends an IP to a component's input port
+func (g *Graph) Inject(endpoint string, ip [][]byte) {
+	g.t.Helper()
+	s := g.Input(endpoint)
+	if _, err := s.SendMessage(ip); err != nil {
+		g.t.Fatalf("Failed to send IP to %s: %v", endpoint, err)
+	}
+}
+
+// Input connects a socket for sending many IPs to a component's input port
+func (g *Graph) Input(endpoint string) *zmq.Socket {
+	g.t.Helper()
+	s, err := zmq.NewSocket(zmq.PUSH)
+	if err != nil {
+		g.t.Fatalf("Failed to create PUSH socket: %v", err)
+	}
+	g.sockets = append(g.sockets, s)
+	s.SetSndtimeo(stopTimeout)
+	if err = s.Connect(endpoint); err != nil {
+		g.t.Fatalf("Failed to connect %s: %v", endpoint, err)
+	}
+	return s
+}
+
+// Output binds a socket collecting IPs from a component's output port. Use it
+// with Receive.
+func (g *Graph) Output(endpoint string) *zmq.Socket {
+	g.t.Helper()
+	s, err := zmq.NewSocket(zmq.PULL)
+	if err != nil {
+		g.t.Fatalf("Failed to create PULL socket: %v", err)
+	}
+	g.sockets = append(g.sockets, s)
+	if err = s.Bind(endpoint); err != nil {
+		g.t.Fatalf("Failed to bind %s: %v", endpoint, err)
+	}
+	return s
+}
+
+// Stop terminates all components and closes sockets of the graph
+func (g *Graph) Stop() {
+	for _, cmd := range g.procs {
+		cmd.Process.Signal(syscall.SIGTERM)
+	}
+	for _, cmd := range g.procs {
+		done := make(chan struct{})
+		go func(cmd *exec.Cmd) {
+			cmd.Wait()
+			close(done)
+		}(cmd)
+		select {
+		case <-done:
+		case <-time.After(stopTimeout):
+			cmd.Process.Kill()
+			<-done
+		}
+	}
+	g.procs = nil
+	for _, s := range g.sockets {
+		s.SetLinger(0)
+		s.Close()
+	}
+	g.sockets = nil
+}
+
+// build compiles a component into a directory shared by all graphs
+func build(t T, component string) string {
+	t.Helper()
+	binariesMu.Lock()
+	defer binariesMu.Unlock()
+	if bin, ok := binaries[component]; ok {
+		return bin
+	}
+	if binDir == "" {
+		dir, err := os.MkdirTemp("", "cascades-http-graph")
+		if err != nil {
+			t.Fatalf("Failed to create directory for binaries: %v", err)
+		}
+		binDir = dir
+	}
+	bin := filepath.Join(binDir, component)
+	out, err := exec.Command("go", "build", "-o", bin, modulePath+component).CombinedOutput()
+	if err != nil {
+		t.Fatalf("Failed to build %s: %v\n%s", component, err, out)
+	}
+	binaries[component] = bin
+	return bin
+}
+
+// logWriter adds lines written by a component to the test log
+type logWriter struct {
+	t      T
+	prefix string
+	mu     sync.Mutex
+	buf    []byte
+}
+
+func (w *logWriter) Write(p []byte) (int, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.buf = append(w.buf, p...)
+	for {
+		i := bytes.IndexByte(w.buf, '\n')
+		if i < 0 {
+			break
+		}
+		w.t.Log(w.prefix + string(w.buf[:i]))
+		w.buf = w.buf[i+1:]
+	}
+	return len(p), nil
+}
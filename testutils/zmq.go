@@ -0,0 +1,63 @@
+package testutils
+
+import (
+	"fmt"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	zmq "github.com/pebbe/zmq4"
+)
+
+var pairs uint64
+
+// NewPipe creates connected PUSH/PULL sockets over an ipc:// endpoint in the
+// test's temporary directory. The endpoint can be passed to a component's port
+// flag, i.e. to send IPs a component reads from or binds to. Sockets are closed
+// when the test finishes.
+func NewPipe(t testing.TB) (push, pull *zmq.Socket, endpoint string) {
+	t.Helper()
+	endpoint = "ipc://" + filepath.Join(t.TempDir(), fmt.Sprintf("pipe-%d.ipc", atomic.AddUint64(&pairs, 1)))
+
+	pull, err := zmq.NewSocket(zmq.PULL)
+	if err != nil {
+		t.Fatalf("Failed to create PULL socket: %v", err)
+	}
+	if err = pull.Bind(endpoint); err != nil {
+		t.Fatalf("Failed to bind %s: %v", endpoint, err)
+	}
+	push, err = zmq.NewSocket(zmq.PUSH)
+	if err != nil {
+		t.Fatalf("Failed to create PUSH socket: %v", err)
+	}
+	if err = push.Connect(endpoint); err != nil {
+		t.Fatalf("Failed to connect %s: %v", endpoint, err)
+	}
+	push.SetLinger(0)
+	pull.SetLinger(0)
+	t.Cleanup(func() {
+		push.Close()
+		pull.Close()
+	})
+	return push, pull, endpoint
+}
+
+// Receive waits for an IP on a socket failing the test after a given timeout
+func Receive(t T, s *zmq.Socket, timeout time.Duration) [][]byte {
+	t.Helper()
+	poller := zmq.NewPoller()
+	poller.Add(s, zmq.POLLIN)
+	polled, err := poller.Poll(timeout)
+	if err != nil {
+		t.Fatalf("Failed to poll socket: %v", err)
+	}
+	if len(polled) == 0 {
+		t.Fatalf("No IP received in %v", timeout)
+	}
+	ip, err := s.RecvMessageBytes(zmq.DONTWAIT)
+	if err != nil {
+		t.Fatalf("Failed to receive IP: %v", err)
+	}
+	return ip
+}
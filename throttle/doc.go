@@ -0,0 +1,64 @@
+package main
+
+import "github.com/cascades-fbp/cascades/library"
+
+var registryEntry = &library.Entry{
+	Description: `Limits the byte rate of requests from IN forwarded to OUT and responses from RESPONSE forwarded
+to RESP with token buckets per connection and for all connections of a direction, i.e. to simulate slow clients
+or to protect a constrained upstream. A connection is a single request or response (by ID), paced by the size of
+its IP, or a bracketed body substream, paced by its packets which can be split into smaller chunks for smoother
+delivery. IPs wait in a queue until their bytes are available, inputs aren't read while the queue is full.`,
+	Elementary: true,
+	Inports: []library.EntryPort{
+		library.EntryPort{
+			Name:        "OPTIONS",
+			Type:        "json",
+			Description: `Configuration port, i.e. {"response": {"rate": 16384, "burst": 65536, "global_rate": 1048576}, "request": {"global_rate": 262144}, "chunk_size": 4096, "max_pending": 10000}`,
+			Required:    true,
+		},
+		library.EntryPort{
+			Name:        "IN",
+			Type:        "json",
+			Description: "Input port for requests in predefined JSON format or body substreams (required if RESPONSE isn't connected)",
+			Required:    false,
+		},
+		library.EntryPort{
+			Name:        "RESPONSE",
+			Type:        "json",
+			Description: "Input port for responses in predefined JSON format or body substreams (required if IN isn't connected)",
+			Required:    false,
+		},
+	},
+	Outports: []library.EntryPort{
+		library.EntryPort{
+			Name:        "OUT",
+			Type:        "json",
+			Description: "Output port for paced requests (required if IN is connected)",
+			Required:    false,
+		},
+		library.EntryPort{
+			Name:        "RESP",
+			Type:        "json",
+			Description: "Output port for paced responses (required if RESPONSE is connected)",
+			Required:    false,
+		},
+		library.EntryPort{
+			Name:        "ERR",
+			Type:        "json",
+			Description: "Optional error port for invalid IPs (error JSON object defined in utils of HTTP components library)",
+			Required:    false,
+		},
+		library.EntryPort{
+			Name:        "LOG",
+			Type:        "json",
+			Description: "Optional output port for structured log entries (log entry JSON object defined in utils of HTTP components library)",
+			Required:    false,
+		},
+		library.EntryPort{
+			Name:        "HEARTBEAT",
+			Type:        "json",
+			Description: "Optional output port for periodic liveness IPs (heartbeat JSON object defined in utils of HTTP components library)",
+			Required:    false,
+		},
+	},
+}
@@ -0,0 +1,156 @@
+package main
+
+import (
+	"container/heap"
+	"time"
+)
+
+// Options describe the configuration IP of the component
+type Options struct {
+	Request    *Limit `json:"request"`     // Limits of requests from IN, unthrottled if empty
+	Response   *Limit `json:"response"`    // Limits of responses from RESPONSE, unthrottled if empty
+	ChunkSize  int    `json:"chunk_size"`  // Packets of body substreams are split to this size for smoother pacing (disabled if 0)
+	MaxPending int    `json:"max_pending"` // IPs held at most, inputs aren't read while the queue is full
+}
+
+// Limit describes byte rates of a direction. A connection is a single request or
+// response (by ID) or a single body substream.
+type Limit struct {
+	Rate        int64 `json:"rate"`         // Bytes per second of a connection (unlimited if 0)
+	Burst       int64 `json:"burst"`        // Bytes of a connection passing without delay, equals rate by default
+	GlobalRate  int64 `json:"global_rate"`  // Bytes per second of all connections (unlimited if 0)
+	GlobalBurst int64 `json:"global_burst"` // Bytes of all connections passing without delay, equals global rate by default
+}
+
+// Bucket is a token bucket implemented as generic cell rate algorithm, it keeps
+// the theoretical arrival time instead of the number of tokens
+type Bucket struct {
+	rate  float64
+	burst float64
+	tat   time.Time
+}
+
+// Reserve takes n bytes from the bucket returning when they may be sent.
+// Reservations are granted in order, so release times never decrease.
+func (b *Bucket) Reserve(n int, now time.Time) time.Time {
+	if b.rate <= 0 {
+		return now
+	}
+	tat := b.tat
+	if tat.Before(now) {
+		tat = now
+	}
+	b.tat = tat.Add(seconds(float64(n) / b.rate))
+	at := b.tat.Add(-seconds(b.burst / b.rate))
+	if at.Before(now) {
+		return now
+	}
+	return at
+}
+
+// Full checks if the bucket refilled completely, so it's equal to a new one
+func (b *Bucket) Full(now time.Time) bool {
+	return !b.tat.After(now)
+}
+
+// Limiter paces connections of a single direction, it isn't safe for concurrent use
+type Limiter struct {
+	limit  *Limit
+	global Bucket
+	conns  map[string]*Bucket
+}
+
+// NewLimiter creates a limiter applying defaults to a given limit
+func NewLimiter(limit *Limit) *Limiter {
+	if limit.Burst <= 0 {
+		limit.Burst = limit.Rate
+	}
+	if limit.GlobalBurst <= 0 {
+		limit.GlobalBurst = limit.GlobalRate
+	}
+	return &Limiter{
+		limit:  limit,
+		global: Bucket{rate: float64(limit.GlobalRate), burst: float64(limit.GlobalBurst)},
+		conns:  make(map[string]*Bucket),
+	}
+}
+
+// Reserve returns when n bytes of a connection may be sent
+func (l *Limiter) Reserve(conn string, n int, now time.Time) time.Time {
+	b, ok := l.conns[conn]
+	if !ok {
+		b = &Bucket{rate: float64(l.limit.Rate), burst: float64(l.limit.Burst)}
+		l.conns[conn] = b
+	}
+	at := b.Reserve(n, now)
+	if g := l.global.Reserve(n, now); g.After(at) {
+		at = g
+	}
+	return at
+}
+
+// Sweep forgets connections with refilled buckets
+func (l *Limiter) Sweep(now time.Time) {
+	for k, b := range l.conns {
+		if b.Full(now) {
+			delete(l.conns, k)
+		}
+	}
+}
+
+func seconds(s float64) time.Duration {
+	return time.Duration(s * float64(time.Second))
+}
+
+// paced is an IP waiting for its release time
+type paced struct {
+	due      time.Time
+	seq      uint64
+	ip       [][]byte
+	response bool
+}
+
+// paceQueue is a min-heap of IPs by release time, IPs due at the same time keep their order
+type paceQueue struct {
+	items []paced
+	seq   uint64
+}
+
+func (q *paceQueue) Len() int { return len(q.items) }
+func (q *paceQueue) Less(i, j int) bool {
+	if q.items[i].due.Equal(q.items[j].due) {
+		return q.items[i].seq < q.items[j].seq
+	}
+	return q.items[i].due.Before(q.items[j].due)
+}
+func (q *paceQueue) Swap(i, j int)      { q.items[i], q.items[j] = q.items[j], q.items[i] }
+func (q *paceQueue) Push(x interface{}) { q.items = append(q.items, x.(paced)) }
+func (q *paceQueue) Pop() interface{} {
+	old := q.items
+	p := old[len(old)-1]
+	q.items = old[:len(old)-1]
+	return p
+}
+
+// Hold queues an IP until due
+func (q *paceQueue) Hold(ip [][]byte, due time.Time, response bool) {
+	q.seq++
+	heap.Push(q, paced{due: due, seq: q.seq, ip: ip, response: response})
+}
+
+// Release returns IPs due at a given time
+func (q *paceQueue) Release(now time.Time) []paced {
+	var res []paced
+	for len(q.items) > 0 && !q.items[0].due.After(now) {
+		res = append(res, heap.Pop(q).(paced))
+	}
+	return res
+}
+
+// Next returns how long until the next IP is due, ok is false if the queue is empty
+func (q *paceQueue) Next(now time.Time) (time.Duration, bool) {
+	if len(q.items) == 0 {
+		return 0, false
+	}
+	return q.items[0].due.Sub(now), true
+}
@@ -0,0 +1,336 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"time"
+
+	httputils "github.com/cascades-fbp/cascades-http/utils"
+	"github.com/cascades-fbp/cascades/components/utils"
+	"github.com/cascades-fbp/cascades/runtime"
+	zmq "github.com/pebbe/zmq4"
+)
+
+var (
+	// Flags
+	optionsEndpoint   = flag.String("port.options", "", "Component's options port endpoint")
+	inputEndpoint     = flag.String("port.in", "", "Component's input port endpoint")
+	responseEndpoint  = flag.String("port.response", "", "Component's response port endpoint")
+	outputEndpoint    = flag.String("port.out", "", "Component's output port endpoint")
+	respEndpoint      = flag.String("port.resp", "", "Component's response output port endpoint")
+	errorEndpoint     = flag.String("port.err", "", "Component's error port endpoint")
+	logEndpoint       = flag.String("port.log", "", "Component's log port endpoint")
+	heartbeatEndpoint = flag.String("port.heartbeat", "", "Component's heartbeat port endpoint")
+	metricsAddr       = flag.String("metrics", "", "Address to expose metrics on, i.e. 127.0.0.1:9090 (disabled if empty)")
+	jsonFlag          = flag.Bool("json", false, "Print component documentation in JSON")
+	debug             = flag.Bool("debug", false, "Enable debug mode")
+
+	// Internal
+	optionsPort, inPort, responsePort, outPort, respPort, errPort, logPort, heartbeatPort *zmq.Socket
+	err                                                                                   error
+	logger                                                                                = httputils.NewLogger("http/throttle")
+	liveness                                                                              = httputils.NewLiveness("http/throttle")
+	metrics                                                                               = httputils.NewMetrics(logger, liveness)
+	shutdown                                                                              *httputils.Shutdown
+)
+
+// Buckets of finished connections are swept with this interval
+const sweepInterval = 10 * time.Second
+
+// validateArgs checks all required flags
+func validateArgs() {
+	if *optionsEndpoint == "" {
+		flag.Usage()
+		os.Exit(1)
+	}
+	if *inputEndpoint == "" && *responseEndpoint == "" {
+		flag.Usage()
+		os.Exit(1)
+	}
+	if *inputEndpoint != "" && *outputEndpoint == "" {
+		flag.Usage()
+		os.Exit(1)
+	}
+	if *responseEndpoint != "" && *respEndpoint == "" {
+		flag.Usage()
+		os.Exit(1)
+	}
+}
+
+// openPorts create ZMQ sockets
+func openPorts() {
+	optionsPort, err = utils.CreateInputPort("http/throttle.options", *optionsEndpoint, nil)
+	utils.AssertError(err)
+
+	if *inputEndpoint != "" {
+		inPort, err = utils.CreateInputPort("http/throttle.in", *inputEndpoint, nil)
+		utils.AssertError(err)
+
+		outPort, err = utils.CreateOutputPort("http/throttle.out", *outputEndpoint, nil)
+		utils.AssertError(err)
+	}
+
+	if *responseEndpoint != "" {
+		responsePort, err = utils.CreateInputPort("http/throttle.response", *responseEndpoint, nil)
+		utils.AssertError(err)
+
+		respPort, err = utils.CreateOutputPort("http/throttle.resp", *respEndpoint, nil)
+		utils.AssertError(err)
+	}
+
+	if *errorEndpoint != "" {
+		errPort, err = utils.CreateOutputPort("http/throttle.err", *errorEndpoint, nil)
+		utils.AssertError(err)
+	}
+
+	if *logEndpoint != "" {
+		logPort, err = utils.CreateOutputPort("http/throttle.log", *logEndpoint, nil)
+		utils.AssertError(err)
+		logger.SetSink(func(ip [][]byte) { logPort.SendMessageDontwait(ip) })
+	}
+
+	if *heartbeatEndpoint != "" {
+		heartbeatPort, err = utils.CreateOutputPort("http/throttle.heartbeat", *heartbeatEndpoint, nil)
+		utils.AssertError(err)
+		liveness.Start(func(ip [][]byte) { heartbeatPort.SendMessageDontwait(ip) })
+	}
+}
+
+// closePorts closes all active ports and terminates ZMQ context
+func closePorts() {
+	shutdown.Close(optionsPort, inPort, responsePort)
+	liveness.Stop()
+	shutdown.Flush(outPort, respPort, errPort, heartbeatPort, logPort)
+	zmq.Term()
+}
+
+// direction keeps pacing state of requests or responses
+type direction struct {
+	limiter  *Limiter
+	response bool
+	depth    int    // Depth of nested brackets
+	streams  int    // Number of substreams seen so far
+	conn     string // Connection of the current substream
+}
+
+func main() {
+	flag.Parse()
+
+	if *jsonFlag {
+		doc, _ := registryEntry.JSON()
+		fmt.Println(string(doc))
+		os.Exit(0)
+	}
+
+	log.SetFlags(0)
+	if *debug {
+		logger.SetLevel(httputils.LevelDebug)
+		logger.SetOutput(os.Stdout)
+	}
+	log.SetOutput(logger.Writer())
+
+	validateArgs()
+
+	shutdown = httputils.NewShutdown(logger)
+	openPorts()
+
+	if *metricsAddr != "" {
+		err = metrics.Serve(*metricsAddr)
+		utils.AssertError(err)
+	}
+
+	disconnectPort, disconnectName := inPort, "http/throttle.in"
+	if inPort == nil {
+		disconnectPort, disconnectName = responsePort, "http/throttle.response"
+	}
+	err = runtime.SetupShutdownByDisconnect(disconnectPort, disconnectName, shutdown.Signals())
+	utils.AssertError(err)
+
+	// Wait for the configuration on the options port
+	options := &Options{MaxPending: 10000}
+	for {
+		log.Println("Waiting for configuration...")
+		ip, err := shutdown.Receive(optionsPort)
+		if err == httputils.ErrShutdown {
+			shutdown.Exit(closePorts)
+		}
+		if err != nil {
+			logger.Error("Error receiving IP", "error", err)
+			continue
+		}
+		if !runtime.IsValidIP(ip) || !runtime.IsPacket(ip) {
+			continue
+		}
+		if err = json.Unmarshal(ip[1], options); err != nil {
+			logger.Error("Failed to unmarshal options", "error", err)
+			continue
+		}
+		break
+	}
+	optionsPort.Close()
+	optionsPort = nil
+
+	requests := &direction{}
+	if options.Request != nil {
+		requests.limiter = NewLimiter(options.Request)
+	}
+	responses := &direction{response: true}
+	if options.Response != nil {
+		responses.limiter = NewLimiter(options.Response)
+	}
+
+	var queue paceQueue
+	poller := zmq.NewPoller()
+	if inPort != nil {
+		poller.Add(inPort, zmq.POLLIN)
+	}
+	if responsePort != nil {
+		poller.Add(responsePort, zmq.POLLIN)
+	}
+	swept := time.Now()
+
+	// Main loop
+	for !shutdown.Stopping() {
+		timeout := shutdown.PollInterval
+		if next, ok := queue.Next(time.Now()); ok && next < timeout {
+			// Negative timeout would block until a message arrives
+			timeout = next
+			if timeout < 0 {
+				timeout = 0
+			}
+		}
+
+		// Inputs aren't read while the queue is full, so upstream components are slowed down too
+		if queue.Len() >= options.MaxPending {
+			time.Sleep(timeout)
+		} else {
+			sockets, err := poller.Poll(timeout)
+			if err != nil {
+				logger.Error("Error polling ports", "error", err)
+				continue
+			}
+			for _, socket := range sockets {
+				ip, err := socket.Socket.RecvMessageBytes(0)
+				if err != nil {
+					logger.Error("Error receiving message", "error", err)
+					continue
+				}
+				liveness.Inc()
+				if !httputils.IsValidIP(ip) {
+					logger.Warn("Received invalid IP")
+					continue
+				}
+				d := requests
+				if socket.Socket == responsePort {
+					d = responses
+				}
+				d.pace(&queue, options, ip, time.Now())
+			}
+		}
+
+		now := time.Now()
+		for _, p := range queue.Release(now) {
+			send(p.ip, p.response)
+		}
+		if now.Sub(swept) > sweepInterval {
+			for _, d := range []*direction{requests, responses} {
+				if d.limiter != nil {
+					d.limiter.Sweep(now)
+				}
+			}
+			swept = now
+		}
+	}
+
+	// Held IPs are sent right away instead of being lost
+	for _, p := range queue.Release(time.Now().Add(24 * time.Hour)) {
+		send(p.ip, p.response)
+	}
+	shutdown.Exit(closePorts)
+}
+
+// pace schedules an IP of the direction. Packets of a body substream pace the
+// substream as a single connection, brackets keep their position.
+func (d *direction) pace(queue *paceQueue, options *Options, ip [][]byte, now time.Time) {
+	if d.limiter == nil {
+		send(ip, d.response)
+		return
+	}
+
+	switch {
+	case runtime.IsOpenBracket(ip):
+		if d.depth == 0 {
+			d.streams++
+			d.conn = fmt.Sprintf("substream-%d", d.streams)
+		}
+		d.depth++
+		queue.Hold(ip, d.limiter.Reserve(d.conn, 0, now), d.response)
+	case runtime.IsCloseBracket(ip):
+		if d.depth > 0 {
+			d.depth--
+		}
+		queue.Hold(ip, d.limiter.Reserve(d.conn, 0, now), d.response)
+	case d.depth > 0:
+		chunk := ip[1]
+		for options.ChunkSize > 0 && len(chunk) > options.ChunkSize {
+			queue.Hold(runtime.NewPacket(chunk[:options.ChunkSize]), d.limiter.Reserve(d.conn, options.ChunkSize, now), d.response)
+			chunk = chunk[options.ChunkSize:]
+		}
+		if len(chunk) < len(ip[1]) {
+			ip = runtime.NewPacket(chunk)
+		}
+		queue.Hold(ip, d.limiter.Reserve(d.conn, len(chunk), now), d.response)
+	default:
+		id, err := ipID(ip, d.response)
+		if err != nil {
+			logger.Warn("Failed to decode IP", "error", err)
+			sendError(httputils.NewError("http/throttle", httputils.ErrInvalidIP, err))
+			return
+		}
+		size := 0
+		for _, frame := range ip[1:] {
+			size += len(frame)
+		}
+		queue.Hold(ip, d.limiter.Reserve(id, size, now), d.response)
+	}
+}
+
+// ipID returns ID of a request or response IP
+func ipID(ip [][]byte, response bool) (string, error) {
+	if response {
+		resp, err := httputils.IP2Response(ip)
+		if err != nil {
+			return "", err
+		}
+		return resp.ID, nil
+	}
+	req, err := httputils.IP2Request(ip)
+	if err != nil {
+		return "", err
+	}
+	return req.ID, nil
+}
+
+// send emits an IP to the output of its direction
+func send(ip [][]byte, response bool) {
+	if response {
+		respPort.SendMessage(ip)
+		return
+	}
+	outPort.SendMessage(ip)
+}
+
+// sendError reports a failure to the ERR port if it's connected
+func sendError(e *httputils.Error) {
+	if errPort == nil {
+		return
+	}
+	ip, err := httputils.Error2IP(e)
+	if err != nil {
+		return
+	}
+	errPort.SendMessageDontwait(ip)
+}
@@ -0,0 +1,374 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"mime"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	httputils "github.com/cascades-fbp/cascades-http/utils"
+)
+
+// Supported body formats
+const (
+	FormatJSON = "json"
+	FormatForm = "form"
+	FormatXML  = "xml"
+)
+
+// Content types of produced bodies
+var contentTypes = map[string]string{
+	FormatJSON: "application/json; charset=utf-8",
+	FormatForm: "application/x-www-form-urlencoded",
+	FormatXML:  "application/xml; charset=utf-8",
+}
+
+// XML documents map to values as follows: child elements become keys (repeated ones arrays),
+// attributes become keys with "@" prefix and text of elements having attributes or children
+// is kept under "#text" key. The root element name is dropped when decoding.
+const (
+	attrPrefix = "@"
+	textKey    = "#text"
+)
+
+// DetectFormat returns body format of a Content-Type, empty string if it's not supported
+func DetectFormat(contentType string) string {
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return ""
+	}
+	switch {
+	case httputils.IsJSONContentType(mediaType):
+		return FormatJSON
+	case mediaType == "application/x-www-form-urlencoded":
+		return FormatForm
+	case mediaType == "application/xml" || mediaType == "text/xml" || strings.HasSuffix(mediaType, "+xml"):
+		return FormatXML
+	}
+	return ""
+}
+
+// Decode parses a body of a given format into a generic value (maps, arrays, strings,
+// json.Number, booleans and nil)
+func Decode(format string, body []byte, style httputils.FormStyle) (interface{}, error) {
+	switch format {
+	case FormatJSON:
+		d := json.NewDecoder(bytes.NewReader(body))
+		d.UseNumber()
+		var v interface{}
+		if err := d.Decode(&v); err != nil {
+			return nil, fmt.Errorf("decoding JSON body: %w", err)
+		}
+		if _, err := d.Token(); err != io.EOF {
+			return nil, fmt.Errorf("decoding JSON body: invalid character after top-level value")
+		}
+		return v, nil
+	case FormatForm:
+		values, err := url.ParseQuery(string(body))
+		if err != nil {
+			return nil, fmt.Errorf("decoding form body: %w", err)
+		}
+		return unflatten(values, style), nil
+	case FormatXML:
+		v, err := decodeXML(body)
+		if err != nil {
+			return nil, fmt.Errorf("decoding XML body: %w", err)
+		}
+		return v, nil
+	}
+	return nil, fmt.Errorf("unsupported format %q", format)
+}
+
+// Encode renders a generic value as a body of a given format, root names the XML document element
+func Encode(format string, v interface{}, style httputils.FormStyle, root string) ([]byte, error) {
+	switch format {
+	case FormatJSON:
+		return json.Marshal(v)
+	case FormatForm:
+		if _, ok := v.(map[string]interface{}); !ok {
+			return nil, fmt.Errorf("encoding form body: top level value isn't an object")
+		}
+		data, err := json.Marshal(v)
+		if err != nil {
+			return nil, err
+		}
+		values, err := httputils.FlattenJSON(data, style)
+		if err != nil {
+			return nil, fmt.Errorf("encoding form body: %w", err)
+		}
+		return []byte(values.Encode()), nil
+	case FormatXML:
+		var buf bytes.Buffer
+		buf.WriteString(xml.Header)
+		e := xml.NewEncoder(&buf)
+		if err := encodeXML(e, root, v); err != nil {
+			return nil, fmt.Errorf("encoding XML body: %w", err)
+		}
+		if err := e.Flush(); err != nil {
+			return nil, err
+		}
+		return buf.Bytes(), nil
+	}
+	return nil, fmt.Errorf("unsupported format %q", format)
+}
+
+// unflatten nests form fields by their keys in a given style (reverse of httputils.FlattenJSON),
+// objects with keys 0..n-1 become arrays
+func unflatten(values url.Values, style httputils.FormStyle) interface{} {
+	keys := make([]string, 0, len(values))
+	for k := range values {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	root := make(map[string]interface{})
+	for _, k := range keys {
+		path, list := splitKey(k, style)
+		m := root
+		for _, p := range path[:len(path)-1] {
+			next, ok := m[p].(map[string]interface{})
+			if !ok {
+				next = make(map[string]interface{})
+				m[p] = next
+			}
+			m = next
+		}
+		last := path[len(path)-1]
+		vs := values[k]
+		if !list && len(vs) == 1 {
+			m[last] = vs[0]
+			continue
+		}
+		items, _ := m[last].([]interface{})
+		for _, v := range vs {
+			items = append(items, v)
+		}
+		m[last] = items
+	}
+	return arrays(root)
+}
+
+// splitKey splits a form key into path segments, list is true for keys ending with "[]"
+func splitKey(key string, style httputils.FormStyle) ([]string, bool) {
+	if style == httputils.DotStyle {
+		return strings.Split(key, "."), false
+	}
+	list := strings.HasSuffix(key, "[]")
+	key = strings.TrimSuffix(key, "[]")
+	i := strings.IndexByte(key, '[')
+	if i <= 0 || !strings.HasSuffix(key, "]") {
+		return []string{key}, list
+	}
+	path := []string{key[:i]}
+	path = append(path, strings.Split(key[i+1:len(key)-1], "][")...)
+	return path, list
+}
+
+// arrays converts objects with keys 0..n-1 to arrays recursively
+func arrays(v interface{}) interface{} {
+	switch t := v.(type) {
+	case map[string]interface{}:
+		for k, item := range t {
+			t[k] = arrays(item)
+		}
+		if len(t) == 0 {
+			return t
+		}
+		list := make([]interface{}, len(t))
+		for k, item := range t {
+			i, err := strconv.Atoi(k)
+			if err != nil || i < 0 || i >= len(t) || strconv.Itoa(i) != k {
+				return t
+			}
+			list[i] = item
+		}
+		return list
+	case []interface{}:
+		for i, item := range t {
+			t[i] = arrays(item)
+		}
+	}
+	return v
+}
+
+// xmlNode is an element being decoded
+type xmlNode struct {
+	fields map[string]interface{}
+	text   strings.Builder
+}
+
+func decodeXML(body []byte) (interface{}, error) {
+	d := xml.NewDecoder(bytes.NewReader(body))
+	d.CharsetReader = func(label string, r io.Reader) (io.Reader, error) {
+		data, err := io.ReadAll(r)
+		if err != nil {
+			return nil, err
+		}
+		// Documents declaring another encoding are often UTF-8 anyway
+		if utf8.Valid(data) {
+			return bytes.NewReader(data), nil
+		}
+		data, err = httputils.ToUTF8("text/xml; charset="+label, data)
+		if err != nil {
+			return nil, err
+		}
+		return bytes.NewReader(data), nil
+	}
+
+	var (
+		stack []*xmlNode
+		names []string
+		value interface{}
+		found bool
+	)
+	for {
+		tok, err := d.Token()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		switch t := tok.(type) {
+		case xml.StartElement:
+			if found {
+				return nil, fmt.Errorf("multiple root elements")
+			}
+			n := &xmlNode{fields: make(map[string]interface{})}
+			for _, a := range t.Attr {
+				if a.Name.Space == "xmlns" || a.Name.Local == "xmlns" {
+					continue
+				}
+				n.fields[attrPrefix+a.Name.Local] = a.Value
+			}
+			stack = append(stack, n)
+			names = append(names, t.Name.Local)
+		case xml.CharData:
+			if len(stack) > 0 {
+				stack[len(stack)-1].text.Write(t)
+			}
+		case xml.EndElement:
+			n := stack[len(stack)-1]
+			name := names[len(names)-1]
+			stack, names = stack[:len(stack)-1], names[:len(names)-1]
+			v := n.value()
+			if len(stack) == 0 {
+				value, found = v, true
+				continue
+			}
+			parent := stack[len(stack)-1].fields
+			switch prev := parent[name].(type) {
+			case nil:
+				parent[name] = v
+			case []interface{}:
+				parent[name] = append(prev, v)
+			default:
+				parent[name] = []interface{}{prev, v}
+			}
+		}
+	}
+	if !found {
+		return nil, fmt.Errorf("no root element")
+	}
+	return value, nil
+}
+
+// value returns text of a leaf element or fields of others
+func (n *xmlNode) value() interface{} {
+	text := strings.TrimSpace(n.text.String())
+	if len(n.fields) == 0 {
+		return text
+	}
+	if text != "" {
+		n.fields[textKey] = text
+	}
+	return n.fields
+}
+
+func encodeXML(e *xml.Encoder, name string, v interface{}) error {
+	start := xml.StartElement{Name: xml.Name{Local: name}}
+	switch t := v.(type) {
+	case map[string]interface{}:
+		keys := make([]string, 0, len(t))
+		for k := range t {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		var children []string
+		for _, k := range keys {
+			switch {
+			case k == textKey:
+			case strings.HasPrefix(k, attrPrefix):
+				start.Attr = append(start.Attr, xml.Attr{Name: xml.Name{Local: k[len(attrPrefix):]}, Value: scalar(t[k])})
+			default:
+				children = append(children, k)
+			}
+		}
+		if err := e.EncodeToken(start); err != nil {
+			return err
+		}
+		if text, ok := t[textKey]; ok {
+			if err := e.EncodeToken(xml.CharData(scalar(text))); err != nil {
+				return err
+			}
+		}
+		for _, k := range children {
+			if items, ok := t[k].([]interface{}); ok {
+				for _, item := range items {
+					if err := encodeXML(e, k, item); err != nil {
+						return err
+					}
+				}
+				continue
+			}
+			if err := encodeXML(e, k, t[k]); err != nil {
+				return err
+			}
+		}
+	case []interface{}:
+		// Top level and nested arrays have no name of their own
+		if err := e.EncodeToken(start); err != nil {
+			return err
+		}
+		for _, item := range t {
+			if err := encodeXML(e, "item", item); err != nil {
+				return err
+			}
+		}
+	default:
+		if err := e.EncodeToken(start); err != nil {
+			return err
+		}
+		if t != nil {
+			if err := e.EncodeToken(xml.CharData(scalar(t))); err != nil {
+				return err
+			}
+		}
+	}
+	return e.EncodeToken(start.End())
+}
+
+// scalar formats a value as text
+func scalar(v interface{}) string {
+	switch t := v.(type) {
+	case nil:
+		return ""
+	case string:
+		return t
+	case json.Number:
+		return t.String()
+	case bool:
+		return strconv.FormatBool(t)
+	case float64:
+		return strconv.FormatFloat(t, 'f', -1, 64)
+	}
+	data, _ := json.Marshal(v)
+	return string(data)
+}
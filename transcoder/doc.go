@@ -0,0 +1,65 @@
+package main
+
+import "github.com/cascades-fbp/cascades/library"
+
+var registryEntry = &library.Entry{
+	Description: `Converts bodies of requests from IN and responses from RESPONSE between JSON, urlencoded form and
+XML (detected by Content-Type), so JSON APIs can be fronted over form based backends and vice versa. Nested form keys
+use brackets (a[b][]=v) or dots (a.b=v) style. XML child elements map to fields (repeated ones to arrays), attributes
+to fields with "@" prefix and text of elements with attributes to "#text" field. Mapping rules move, remove, set and
+convert fields after decoding. Bodies of other types and compressed bodies pass unchanged. Requests with malformed
+bodies are answered with 400 on RESP port.`,
+	Elementary: true,
+	Inports: []library.EntryPort{
+		library.EntryPort{
+			Name:        "OPTIONS",
+			Type:        "json",
+			Description: `Configuration port, i.e. {"request": {"to": "form", "from": ["json"], "rules": [{"from": "user.name", "to": "username"}, {"to": "version", "value": "2"}]}, "response": {"to": "json", "rules": [{"from": "items.item", "to": "items", "type": "array"}]}}`,
+			Required:    true,
+		},
+		library.EntryPort{
+			Name:        "IN",
+			Type:        "json",
+			Description: "Input port for requests in predefined JSON format",
+			Required:    true,
+		},
+		library.EntryPort{
+			Name:        "RESPONSE",
+			Type:        "json",
+			Description: "Optional input port for responses in predefined JSON format",
+			Required:    false,
+		},
+	},
+	Outports: []library.EntryPort{
+		library.EntryPort{
+			Name:        "OUT",
+			Type:        "json",
+			Description: "Output port for converted requests",
+			Required:    true,
+		},
+		library.EntryPort{
+			Name:        "RESP",
+			Type:        "json",
+			Description: "Output port for converted responses and rejections of malformed requests (required if RESPONSE is connected)",
+			Required:    false,
+		},
+		library.EntryPort{
+			Name:        "ERR",
+			Type:        "json",
+			Description: "Optional error port for invalid IPs and failed conversions (error JSON object defined in utils of HTTP components library)",
+			Required:    false,
+		},
+		library.EntryPort{
+			Name:        "LOG",
+			Type:        "json",
+			Description: "Optional output port for structured log entries (log entry JSON object defined in utils of HTTP components library)",
+			Required:    false,
+		},
+		library.EntryPort{
+			Name:        "HEARTBEAT",
+			Type:        "json",
+			Description: "Optional output port for periodic liveness IPs (heartbeat JSON object defined in utils of HTTP components library)",
+			Required:    false,
+		},
+	},
+}
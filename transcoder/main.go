@@ -0,0 +1,329 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"strings"
+	"unicode/utf8"
+
+	httputils "github.com/cascades-fbp/cascades-http/utils"
+	"github.com/cascades-fbp/cascades/components/utils"
+	"github.com/cascades-fbp/cascades/runtime"
+	zmq "github.com/pebbe/zmq4"
+)
+
+var (
+	// Flags
+	optionsEndpoint   = flag.String("port.options", "", "Component's options port endpoint")
+	inputEndpoint     = flag.String("port.in", "", "Component's input port endpoint")
+	responseEndpoint  = flag.String("port.response", "", "Component's response port endpoint")
+	outputEndpoint    = flag.String("port.out", "", "Component's output port endpoint")
+	respEndpoint      = flag.String("port.resp", "", "Component's response output port endpoint")
+	errorEndpoint     = flag.String("port.err", "", "Component's error port endpoint")
+	logEndpoint       = flag.String("port.log", "", "Component's log port endpoint")
+	heartbeatEndpoint = flag.String("port.heartbeat", "", "Component's heartbeat port endpoint")
+	metricsAddr       = flag.String("metrics", "", "Address to expose metrics on, i.e. 127.0.0.1:9090 (disabled if empty)")
+	jsonFlag          = flag.Bool("json", false, "Print component documentation in JSON")
+	debug             = flag.Bool("debug", false, "Enable debug mode")
+
+	// Internal
+	optionsPort, inPort, responsePort, outPort, respPort, errPort, logPort, heartbeatPort *zmq.Socket
+	err                                                                                   error
+	logger                                                                                = httputils.NewLogger("http/transcoder")
+	liveness                                                                              = httputils.NewLiveness("http/transcoder")
+	metrics                                                                               = httputils.NewMetrics(logger, liveness)
+	shutdown                                                                              *httputils.Shutdown
+)
+
+// validateArgs checks all required flags
+func validateArgs() {
+	if *optionsEndpoint == "" {
+		flag.Usage()
+		os.Exit(1)
+	}
+	if *inputEndpoint == "" {
+		flag.Usage()
+		os.Exit(1)
+	}
+	if *outputEndpoint == "" {
+		flag.Usage()
+		os.Exit(1)
+	}
+	if *responseEndpoint != "" && *respEndpoint == "" {
+		flag.Usage()
+		os.Exit(1)
+	}
+}
+
+// openPorts create ZMQ sockets
+func openPorts() {
+	optionsPort, err = utils.CreateInputPort("http/transcoder.options", *optionsEndpoint, nil)
+	utils.AssertError(err)
+
+	inPort, err = utils.CreateInputPort("http/transcoder.in", *inputEndpoint, nil)
+	utils.AssertError(err)
+
+	if *responseEndpoint != "" {
+		responsePort, err = utils.CreateInputPort("http/transcoder.response", *responseEndpoint, nil)
+		utils.AssertError(err)
+	}
+
+	outPort, err = utils.CreateOutputPort("http/transcoder.out", *outputEndpoint, nil)
+	utils.AssertError(err)
+
+	if *respEndpoint != "" {
+		respPort, err = utils.CreateOutputPort("http/transcoder.resp", *respEndpoint, nil)
+		utils.AssertError(err)
+	}
+
+	if *errorEndpoint != "" {
+		errPort, err = utils.CreateOutputPort("http/transcoder.err", *errorEndpoint, nil)
+		utils.AssertError(err)
+	}
+
+	if *logEndpoint != "" {
+		logPort, err = utils.CreateOutputPort("http/transcoder.log", *logEndpoint, nil)
+		utils.AssertError(err)
+		logger.SetSink(func(ip [][]byte) { logPort.SendMessageDontwait(ip) })
+	}
+
+	if *heartbeatEndpoint != "" {
+		heartbeatPort, err = utils.CreateOutputPort("http/transcoder.heartbeat", *heartbeatEndpoint, nil)
+		utils.AssertError(err)
+		liveness.Start(func(ip [][]byte) { heartbeatPort.SendMessageDontwait(ip) })
+	}
+}
+
+// closePorts closes all active ports and terminates ZMQ context
+func closePorts() {
+	shutdown.Close(optionsPort, inPort, responsePort)
+	liveness.Stop()
+	shutdown.Flush(outPort, respPort, errPort, heartbeatPort, logPort)
+	zmq.Term()
+}
+
+func main() {
+	flag.Parse()
+
+	if *jsonFlag {
+		doc, _ := registryEntry.JSON()
+		fmt.Println(string(doc))
+		os.Exit(0)
+	}
+
+	log.SetFlags(0)
+	if *debug {
+		logger.SetLevel(httputils.LevelDebug)
+		logger.SetOutput(os.Stdout)
+	}
+	log.SetOutput(logger.Writer())
+
+	validateArgs()
+
+	shutdown = httputils.NewShutdown(logger)
+	openPorts()
+
+	if *metricsAddr != "" {
+		err = metrics.Serve(*metricsAddr)
+		utils.AssertError(err)
+	}
+
+	err = runtime.SetupShutdownByDisconnect(inPort, "http/transcoder.in", shutdown.Signals())
+	utils.AssertError(err)
+
+	// Wait for the configuration on the options port
+	var options *Options
+	for options == nil {
+		log.Println("Waiting for configuration...")
+		ip, err := shutdown.Receive(optionsPort)
+		if err == httputils.ErrShutdown {
+			shutdown.Exit(closePorts)
+		}
+		if err != nil {
+			logger.Error("Error receiving IP", "error", err)
+			continue
+		}
+		if !runtime.IsValidIP(ip) || !runtime.IsPacket(ip) {
+			continue
+		}
+		opts := &Options{}
+		if err = json.Unmarshal(ip[1], opts); err != nil {
+			logger.Error("Failed to unmarshal options", "error", err)
+			continue
+		}
+		if err = validateOptions(opts); err != nil {
+			logger.Error("Invalid options", "error", err)
+			continue
+		}
+		options = opts
+	}
+	optionsPort.Close()
+	optionsPort = nil
+
+	poller := zmq.NewPoller()
+	poller.Add(inPort, zmq.POLLIN)
+	if responsePort != nil {
+		poller.Add(responsePort, zmq.POLLIN)
+	}
+
+	// Main loop
+	for !shutdown.Stopping() {
+		sockets, err := poller.Poll(shutdown.PollInterval)
+		if err != nil {
+			logger.Error("Error polling ports", "error", err)
+			continue
+		}
+		for _, socket := range sockets {
+			ip, err := socket.Socket.RecvMessageBytes(0)
+			if err != nil {
+				logger.Error("Error receiving message", "error", err)
+				continue
+			}
+			liveness.Inc()
+			if !httputils.IsValidIP(ip) {
+				logger.Warn("Received invalid IP")
+				continue
+			}
+
+			switch socket.Socket {
+			case inPort:
+				if !runtime.IsPacket(ip) || options.Request == nil {
+					outPort.SendMessage(ip)
+					continue
+				}
+				handleRequest(options.Request, ip)
+			case responsePort:
+				if !runtime.IsPacket(ip) || options.Response == nil {
+					respPort.SendMessage(ip)
+					continue
+				}
+				handleResponse(options.Response, ip)
+			}
+		}
+	}
+	shutdown.Exit(closePorts)
+}
+
+// validateOptions checks configured transforms
+func validateOptions(options *Options) error {
+	if options.Request != nil {
+		if err := options.Request.Validate(); err != nil {
+			return fmt.Errorf("request: %w", err)
+		}
+	}
+	if options.Response != nil {
+		if err := options.Response.Validate(); err != nil {
+			return fmt.Errorf("response: %w", err)
+		}
+	}
+	return nil
+}
+
+// handleRequest converts request body and forwards the request to OUT. Requests with
+// malformed bodies are answered with 400 on RESP port if it's connected.
+func handleRequest(t *Transform, ip [][]byte) {
+	req, err := httputils.IP2Request(ip)
+	if err != nil {
+		logger.Warn("Failed to convert IP to request", "error", err)
+		sendError(httputils.NewError("http/transcoder", httputils.ErrInvalidIP, err))
+		return
+	}
+	contentType := req.GetHeader("Content-Type")
+	format := DetectFormat(contentType)
+	if len(req.Body) == 0 || !t.Accepts(format) || isEncoded(req.GetHeader("Content-Encoding")) {
+		outPort.SendMessage(ip)
+		return
+	}
+
+	body, newType, err := transcode(t, format, contentType, req.Body, "request")
+	if err != nil {
+		logger.Warn("Failed to transcode request body", "id", req.ID, "error", err)
+		sendError(httputils.NewError("http/transcoder", httputils.ErrInvalidRequest, err).WithRequest(req.ID))
+		if respPort == nil {
+			outPort.SendMessage(ip)
+			return
+		}
+		resp := httputils.NewResponse(http.StatusBadRequest).
+			WithID(req.ID).
+			WithTrace(req.Trace).
+			WithText("Malformed %s body", format)
+		respPort.SendMessage(resp.MustIP())
+		return
+	}
+	req.WithBody(newType, body)
+	ip, err = httputils.Request2IP(req)
+	if err != nil {
+		logger.Error("Failed to convert request to IP", "error", err)
+		sendError(httputils.NewError("http/transcoder", httputils.ErrInternal, err).WithRequest(req.ID))
+		return
+	}
+	outPort.SendMessage(ip)
+}
+
+// handleResponse converts response body and forwards the response to RESP. Responses
+// failing to convert are forwarded unchanged.
+func handleResponse(t *Transform, ip [][]byte) {
+	resp, err := httputils.IP2Response(ip)
+	if err != nil {
+		logger.Warn("Failed to convert IP to response", "error", err)
+		sendError(httputils.NewError("http/transcoder", httputils.ErrInvalidIP, err))
+		return
+	}
+	contentType := resp.GetHeader("Content-Type")
+	format := DetectFormat(contentType)
+	if len(resp.Body) == 0 || !t.Accepts(format) || isEncoded(resp.GetHeader("Content-Encoding")) {
+		respPort.SendMessage(ip)
+		return
+	}
+
+	body, newType, err := transcode(t, format, contentType, resp.Body, "response")
+	if err != nil {
+		logger.Warn("Failed to transcode response body", "id", resp.ID, "error", err)
+		sendError(httputils.NewError("http/transcoder", httputils.ErrUpstream, err).WithRequest(resp.ID))
+		respPort.SendMessage(ip)
+		return
+	}
+	resp.WithBody(newType, body)
+	ip, err = httputils.Response2IP(resp)
+	if err != nil {
+		logger.Error("Failed to convert response to IP", "error", err)
+		sendError(httputils.NewError("http/transcoder", httputils.ErrInternal, err).WithRequest(resp.ID))
+		return
+	}
+	respPort.SendMessage(ip)
+}
+
+// transcode converts form bodies declaring another charset to UTF-8 and then to
+// the target format. JSON is always UTF-8, XML declares its own encoding and
+// bodies already valid as UTF-8 are left as they are.
+func transcode(t *Transform, format, contentType string, body []byte, root string) ([]byte, string, error) {
+	if format != FormatXML && !httputils.IsJSONContentType(contentType) && !utf8.Valid(body) {
+		data, err := httputils.ToUTF8(contentType, body)
+		if err != nil {
+			return nil, "", err
+		}
+		body = data
+	}
+	return t.Convert(format, body, root)
+}
+
+// isEncoded checks if a body is compressed by Content-Encoding
+func isEncoded(encoding string) bool {
+	return encoding != "" && !strings.EqualFold(encoding, "identity")
+}
+
+// sendError reports a failure to the ERR port if it's connected
+func sendError(e *httputils.Error) {
+	if errPort == nil {
+		return
+	}
+	ip, err := httputils.Error2IP(e)
+	if err != nil {
+		return
+	}
+	errPort.SendMessageDontwait(ip)
+}
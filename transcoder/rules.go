@@ -0,0 +1,249 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+
+	httputils "github.com/cascades-fbp/cascades-http/utils"
+)
+
+// Options describe the configuration IP of the component
+type Options struct {
+	Request  *Transform `json:"request"`  // Transcoding of requests from IN, requests pass unchanged if empty
+	Response *Transform `json:"response"` // Transcoding of responses from RESPONSE, responses pass unchanged if empty
+}
+
+// Transform describes how bodies of one direction are converted
+type Transform struct {
+	To     string   `json:"to"`     // Target format: json, form or xml
+	From   []string `json:"from"`   // Source formats to convert, all supported ones if empty
+	Style  string   `json:"style"`  // Nested form keys style: brackets (default) or dots
+	Root   string   `json:"root"`   // Name of XML document element
+	Rules  []Rule   `json:"rules"`  // Mapping rules applied in order after decoding
+	Strict bool     `json:"strict"` // Keep only fields produced by rules
+
+	style httputils.FormStyle
+}
+
+// Rule maps a single field, paths are dot separated (numeric segments index arrays).
+// With from and to the value is moved, with from only the field is removed and with
+// value and to a constant is set. Type converts the value: string, number, bool or array
+// (a single value is wrapped, i.e. for repeated form fields or XML elements seen once).
+type Rule struct {
+	From  string          `json:"from"`
+	To    string          `json:"to"`
+	Value json.RawMessage `json:"value"`
+	Type  string          `json:"type"`
+
+	value interface{}
+}
+
+// Validate checks the transform and prepares it for use
+func (t *Transform) Validate() error {
+	if _, ok := contentTypes[t.To]; !ok {
+		return fmt.Errorf("unsupported target format %q", t.To)
+	}
+	for _, f := range t.From {
+		if _, ok := contentTypes[f]; !ok {
+			return fmt.Errorf("unsupported source format %q", f)
+		}
+	}
+	switch t.Style {
+	case "", "brackets":
+		t.style = httputils.BracketStyle
+	case "dots":
+		t.style = httputils.DotStyle
+	default:
+		return fmt.Errorf("unsupported form style %q", t.Style)
+	}
+	for i := range t.Rules {
+		r := &t.Rules[i]
+		if r.Value != nil {
+			if r.To == "" {
+				return fmt.Errorf("rule %d: value requires to", i)
+			}
+			d := json.NewDecoder(bytes.NewReader(r.Value))
+			d.UseNumber()
+			if err := d.Decode(&r.value); err != nil {
+				return fmt.Errorf("rule %d: %w", i, err)
+			}
+		} else if r.From == "" {
+			return fmt.Errorf("rule %d: either from or value is required", i)
+		}
+		switch r.Type {
+		case "", "string", "number", "bool", "array":
+		default:
+			return fmt.Errorf("rule %d: unsupported type %q", i, r.Type)
+		}
+	}
+	return nil
+}
+
+// Accepts checks if bodies of a given source format are converted
+func (t *Transform) Accepts(format string) bool {
+	if format == "" {
+		return false
+	}
+	if len(t.From) == 0 {
+		return true
+	}
+	for _, f := range t.From {
+		if f == format {
+			return true
+		}
+	}
+	return false
+}
+
+// Convert transcodes a body of a given format returning the new body and its Content-Type
+func (t *Transform) Convert(format string, body []byte, root string) ([]byte, string, error) {
+	v, err := Decode(format, body, t.style)
+	if err != nil {
+		return nil, "", err
+	}
+	if v, err = t.apply(v); err != nil {
+		return nil, "", err
+	}
+	if t.Root != "" {
+		root = t.Root
+	}
+	data, err := Encode(t.To, v, t.style, root)
+	if err != nil {
+		return nil, "", err
+	}
+	return data, contentTypes[t.To], nil
+}
+
+// apply runs mapping rules on a decoded value
+func (t *Transform) apply(v interface{}) (interface{}, error) {
+	if len(t.Rules) == 0 {
+		return v, nil
+	}
+	src, ok := v.(map[string]interface{})
+	if !ok {
+		return nil, fmt.Errorf("mapping rules require an object body")
+	}
+	dst := src
+	if t.Strict {
+		dst = make(map[string]interface{})
+	}
+	for i, r := range t.Rules {
+		var (
+			value interface{}
+			found bool
+		)
+		if r.Value != nil {
+			value, found = r.value, true
+		} else {
+			value, found = lookupPath(src, r.From)
+			if found && !t.Strict {
+				deletePath(src, r.From)
+			}
+		}
+		if !found || r.To == "" {
+			continue
+		}
+		value, err := convert(value, r.Type)
+		if err != nil {
+			return nil, fmt.Errorf("rule %d: %w", i, err)
+		}
+		if err := setPath(dst, r.To, value); err != nil {
+			return nil, fmt.Errorf("rule %d: %w", i, err)
+		}
+	}
+	return dst, nil
+}
+
+func lookupPath(v interface{}, path string) (interface{}, bool) {
+	for _, p := range strings.Split(path, ".") {
+		switch t := v.(type) {
+		case map[string]interface{}:
+			var ok bool
+			if v, ok = t[p]; !ok {
+				return nil, false
+			}
+		case []interface{}:
+			i, err := strconv.Atoi(p)
+			if err != nil || i < 0 || i >= len(t) {
+				return nil, false
+			}
+			v = t[i]
+		default:
+			return nil, false
+		}
+	}
+	return v, true
+}
+
+func deletePath(m map[string]interface{}, path string) {
+	parts := strings.Split(path, ".")
+	for _, p := range parts[:len(parts)-1] {
+		next, ok := m[p].(map[string]interface{})
+		if !ok {
+			return
+		}
+		m = next
+	}
+	delete(m, parts[len(parts)-1])
+}
+
+func setPath(m map[string]interface{}, path string, v interface{}) error {
+	parts := strings.Split(path, ".")
+	for _, p := range parts[:len(parts)-1] {
+		switch next := m[p].(type) {
+		case map[string]interface{}:
+			m = next
+		case nil:
+			n := make(map[string]interface{})
+			m[p] = n
+			m = n
+		default:
+			return fmt.Errorf("%s isn't an object", p)
+		}
+	}
+	m[parts[len(parts)-1]] = v
+	return nil
+}
+
+// convert changes type of a value as requested by a rule
+func convert(v interface{}, typ string) (interface{}, error) {
+	switch typ {
+	case "string":
+		switch v.(type) {
+		case map[string]interface{}, []interface{}:
+			return nil, fmt.Errorf("can't convert %T to string", v)
+		}
+		return scalar(v), nil
+	case "number":
+		s := strings.TrimSpace(scalar(v))
+		if _, err := strconv.ParseFloat(s, 64); err != nil {
+			return nil, fmt.Errorf("%q isn't a number", s)
+		}
+		return json.Number(s), nil
+	case "bool":
+		if b, ok := v.(bool); ok {
+			return b, nil
+		}
+		s := strings.ToLower(strings.TrimSpace(scalar(v)))
+		switch s {
+		case "1", "on", "yes":
+			return true, nil
+		case "", "0", "off", "no":
+			return false, nil
+		}
+		b, err := strconv.ParseBool(s)
+		if err != nil {
+			return nil, fmt.Errorf("%q isn't a boolean", s)
+		}
+		return b, nil
+	case "array":
+		if list, ok := v.([]interface{}); ok {
+			return list, nil
+		}
+		return []interface{}{v}, nil
+	}
+	return v, nil
+}
@@ -0,0 +1,71 @@
+// Package tunnel implements the protocol spoken between http/tunnelagent
+// and http/tunnelrelay components over the outbound agent connection.
+package tunnel
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"io"
+
+	httputils "github.com/cascades-fbp/cascades-http/utils"
+)
+
+// Message types
+const (
+	Hello    = "hello"
+	Welcome  = "welcome"
+	Request  = "request"
+	Response = "response"
+)
+
+// MaxMessageSize limits the size of a single message on the wire
+const MaxMessageSize = 64 << 20
+
+// Message is exchanged between relay and agent
+type Message struct {
+	Type     string                  `json:"type"`
+	Token    string                  `json:"token,omitempty"`
+	Error    string                  `json:"error,omitempty"`
+	Request  *httputils.HTTPRequest  `json:"request,omitempty"`
+	Response *httputils.HTTPResponse `json:"response,omitempty"`
+}
+
+// WriteMessage writes a length-prefixed JSON message
+func WriteMessage(w io.Writer, m *Message) error {
+	data, err := json.Marshal(m)
+	if err != nil {
+		return err
+	}
+	if len(data) > MaxMessageSize {
+		return fmt.Errorf("message is too large: %d bytes", len(data))
+	}
+	header := make([]byte, 4)
+	binary.BigEndian.PutUint32(header, uint32(len(data)))
+	if _, err = w.Write(header); err != nil {
+		return err
+	}
+	_, err = w.Write(data)
+	return err
+}
+
+// ReadMessage reads a length-prefixed JSON message
+func ReadMessage(r io.Reader) (*Message, error) {
+	header := make([]byte, 4)
+	if _, err := io.ReadFull(r, header); err != nil {
+		return nil, err
+	}
+	size := binary.BigEndian.Uint32(header)
+	if size > MaxMessageSize {
+		return nil, fmt.Errorf("message is too large: %d bytes", size)
+	}
+	data := make([]byte, size)
+	if _, err := io.ReadFull(r, data); err != nil {
+		return nil, err
+	}
+	m := &Message{}
+	if err := json.Unmarshal(data, m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
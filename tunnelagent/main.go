@@ -0,0 +1,285 @@
+package main
+
+import (
+	"crypto/tls"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log"
+	"net"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/cascades-fbp/cascades-http/tunnel"
+	httputils "github.com/cascades-fbp/cascades-http/utils"
+	"github.com/cascades-fbp/cascades/components/utils"
+	"github.com/cascades-fbp/cascades/runtime"
+	zmq "github.com/pebbe/zmq4"
+)
+
+var (
+	// Flags
+	optionsEndpoint   = flag.String("port.options", "", "Component's options port endpoint")
+	inputEndpoint     = flag.String("port.in", "", "Component's input port endpoint")
+	outputEndpoint    = flag.String("port.out", "", "Component's output port endpoint")
+	logEndpoint       = flag.String("port.log", "", "Component's log port endpoint")
+	heartbeatEndpoint = flag.String("port.heartbeat", "", "Component's heartbeat port endpoint")
+	metricsAddr       = flag.String("metrics", "", "Address to expose metrics on, i.e. 127.0.0.1:9090 (disabled if empty)")
+	jsonFlag          = flag.Bool("json", false, "Print component documentation in JSON")
+	debug             = flag.Bool("debug", false, "Enable debug mode")
+
+	// Internal
+	optionsPort, inPort, outPort, logPort, heartbeatPort *zmq.Socket
+	err                                                  error
+	logger                                               = httputils.NewLogger("http/tunnelagent")
+	liveness                                             = httputils.NewLiveness("http/tunnelagent")
+	metrics                                              = httputils.NewMetrics(logger, liveness)
+	shutdown                                             *httputils.Shutdown
+)
+
+// Options describe the configuration IP of the component
+type Options struct {
+	Relay string `json:"relay"` // Tunnel address of the relay
+	Token string `json:"token"` // Shared secret
+	TLS   bool   `json:"tls"`   // Use TLS for the tunnel connection
+}
+
+// validateArgs checks all required flags
+func validateArgs() {
+	if *optionsEndpoint == "" {
+		flag.Usage()
+		os.Exit(1)
+	}
+	if *inputEndpoint == "" {
+		flag.Usage()
+		os.Exit(1)
+	}
+	if *outputEndpoint == "" {
+		flag.Usage()
+		os.Exit(1)
+	}
+}
+
+// openPorts create ZMQ sockets
+func openPorts() {
+	optionsPort, err = utils.CreateInputPort("http/tunnelagent.options", *optionsEndpoint, nil)
+	utils.AssertError(err)
+
+	inPort, err = utils.CreateInputPort("http/tunnelagent.in", *inputEndpoint, nil)
+	utils.AssertError(err)
+
+	if *logEndpoint != "" {
+		logPort, err = utils.CreateOutputPort("http/tunnelagent.log", *logEndpoint, nil)
+		utils.AssertError(err)
+		logger.SetSink(func(ip [][]byte) { logPort.SendMessageDontwait(ip) })
+	}
+
+	if *heartbeatEndpoint != "" {
+		heartbeatPort, err = utils.CreateOutputPort("http/tunnelagent.heartbeat", *heartbeatEndpoint, nil)
+		utils.AssertError(err)
+		liveness.Start(func(ip [][]byte) { heartbeatPort.SendMessageDontwait(ip) })
+	}
+}
+
+// closePorts closes all active ports and terminates ZMQ context
+func closePorts() {
+	shutdown.Close(optionsPort, inPort)
+	liveness.Stop()
+	shutdown.Flush(outPort, heartbeatPort, logPort)
+	zmq.Term()
+}
+
+// connection holds the current tunnel connection
+type connection struct {
+	mu   sync.Mutex
+	conn net.Conn
+}
+
+func (c *connection) set(conn net.Conn) {
+	c.mu.Lock()
+	c.conn = conn
+	c.mu.Unlock()
+}
+
+// send writes a response to the tunnel if it's connected
+func (c *connection) send(resp *httputils.HTTPResponse) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.conn == nil {
+		return fmt.Errorf("tunnel is not connected")
+	}
+	return tunnel.WriteMessage(c.conn, &tunnel.Message{Type: tunnel.Response, Response: resp})
+}
+
+// dial connects to the relay and performs the handshake
+func dial(options *Options) (net.Conn, error) {
+	var (
+		conn net.Conn
+		err  error
+	)
+	if options.TLS {
+		conn, err = tls.Dial("tcp", options.Relay, &tls.Config{})
+	} else {
+		conn, err = net.DialTimeout("tcp", options.Relay, 10*time.Second)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err = tunnel.WriteMessage(conn, &tunnel.Message{Type: tunnel.Hello, Token: options.Token}); err != nil {
+		conn.Close()
+		return nil, err
+	}
+	welcome, err := tunnel.ReadMessage(conn)
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+	if welcome.Type != tunnel.Welcome || welcome.Error != "" {
+		conn.Close()
+		return nil, fmt.Errorf("relay refused connection: %s", welcome.Error)
+	}
+	return conn, nil
+}
+
+func main() {
+	flag.Parse()
+
+	if *jsonFlag {
+		doc, _ := registryEntry.JSON()
+		fmt.Println(string(doc))
+		os.Exit(0)
+	}
+
+	log.SetFlags(0)
+	if *debug {
+		logger.SetLevel(httputils.LevelDebug)
+		logger.SetOutput(os.Stdout)
+	}
+	log.SetOutput(logger.Writer())
+
+	validateArgs()
+
+	shutdown = httputils.NewShutdown(logger)
+	openPorts()
+
+	if *metricsAddr != "" {
+		err = metrics.Serve(*metricsAddr)
+		utils.AssertError(err)
+	}
+
+	// Wait for the configuration on the options port
+	options := &Options{}
+	for {
+		log.Println("Waiting for configuration...")
+		ip, err := shutdown.Receive(optionsPort)
+		if err == httputils.ErrShutdown {
+			shutdown.Exit(closePorts)
+		}
+		if err != nil {
+			logger.Error("Error receiving IP", "error", err)
+			continue
+		}
+		if !runtime.IsValidIP(ip) || !runtime.IsPacket(ip) {
+			continue
+		}
+		if err = json.Unmarshal(ip[1], options); err != nil {
+			logger.Error("Failed to unmarshal options", "error", err)
+			continue
+		}
+		if options.Relay == "" {
+			logger.Error("Relay address is missing in configuration")
+			continue
+		}
+		break
+	}
+	optionsPort.Close()
+	optionsPort = nil
+
+	// Requests from the tunnel
+	requestCh := make(chan *httputils.HTTPRequest)
+	current := &connection{}
+
+	// Tunnel goroutine keeps reconnecting with backoff
+	go func() {
+		backoff := time.Second
+		for {
+			conn, err := dial(options)
+			if err != nil {
+				logger.Error("Failed to connect to relay", "error", err)
+				time.Sleep(backoff)
+				if backoff < time.Minute {
+					backoff *= 2
+				}
+				continue
+			}
+			logger.Info("Connected to relay", "addr", options.Relay)
+			backoff = time.Second
+			current.set(conn)
+
+			for {
+				m, err := tunnel.ReadMessage(conn)
+				if err != nil {
+					logger.Error("Tunnel disconnected", "error", err)
+					break
+				}
+				if m.Type == tunnel.Request && m.Request != nil {
+					requestCh <- m.Request
+				}
+			}
+			current.set(nil)
+			conn.Close()
+		}
+	}()
+
+	// Requests to OUT port goroutine, it stops using the port on shutdown
+	outDone := make(chan struct{})
+	go func() {
+		defer close(outDone)
+		outPort, err = utils.CreateOutputPort("http/tunnelagent.out", *outputEndpoint, nil)
+		utils.AssertError(err)
+
+		for {
+			var req *httputils.HTTPRequest
+			select {
+			case req = <-requestCh:
+			case <-shutdown.Done():
+				return
+			}
+			ip, err := httputils.Request2IP(req)
+			if err != nil {
+				logger.Warn("Failed to convert request to IP", "error", err)
+				continue
+			}
+			outPort.SendMessage(ip)
+		}
+	}()
+
+	// Process incoming responses until shutdown
+	for {
+		ip, err := shutdown.Receive(inPort)
+		if err == httputils.ErrShutdown {
+			break
+		}
+		if err != nil {
+			logger.Error("Error receiving message", "error", err)
+			continue
+		}
+		liveness.Inc()
+		if !httputils.IsValidIP(ip) {
+			logger.Warn("Received invalid IP")
+			continue
+		}
+
+		resp, err := httputils.IP2Response(ip)
+		if err != nil {
+			logger.Warn("Error converting IP to response", "error", err)
+			continue
+		}
+		if err = current.send(resp); err != nil {
+			logger.Error("Failed to send response to relay", "error", err)
+		}
+	}
+	<-outDone
+	shutdown.Exit(closePorts)
+}
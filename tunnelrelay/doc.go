@@ -0,0 +1,33 @@
+package main
+
+import "github.com/cascades-fbp/cascades/library"
+
+var registryEntry = &library.Entry{
+	Description: `Public side of an HTTP tunnel. Accepts an outbound connection from http/tunnelagent on the tunnel
+address and relays all HTTP requests received on the public address through it, so a graph behind NAT
+can serve public traffic (i.e. webhooks).`,
+	Elementary: true,
+	Inports: []library.EntryPort{
+		library.EntryPort{
+			Name: "OPTIONS",
+			Type: "json",
+			Description: `Configuration port, i.e. {"listen": ":8080", "tunnel": ":9000", "token": "secret",
+"timeout": "30s", "cert": "/path/cert.pem", "key": "/path/key.pem"} (cert/key enable TLS on the tunnel address)`,
+			Required: true,
+		},
+	},
+	Outports: []library.EntryPort{
+		library.EntryPort{
+			Name:        "LOG",
+			Type:        "json",
+			Description: "Optional output port for structured log entries (log entry JSON object defined in utils of HTTP components library)",
+			Required:    false,
+		},
+		library.EntryPort{
+			Name:        "HEARTBEAT",
+			Type:        "json",
+			Description: "Optional output port for periodic liveness IPs (heartbeat JSON object defined in utils of HTTP components library)",
+			Required:    false,
+		},
+	},
+}
@@ -0,0 +1,214 @@
+package main
+
+import (
+	"context"
+	"crypto/tls"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"os"
+	"time"
+
+	httputils "github.com/cascades-fbp/cascades-http/utils"
+	"github.com/cascades-fbp/cascades/components/utils"
+	"github.com/cascades-fbp/cascades/runtime"
+	zmq "github.com/pebbe/zmq4"
+)
+
+var (
+	// Flags
+	optionsEndpoint   = flag.String("port.options", "", "Component's options port endpoint")
+	logEndpoint       = flag.String("port.log", "", "Component's log port endpoint")
+	heartbeatEndpoint = flag.String("port.heartbeat", "", "Component's heartbeat port endpoint")
+	metricsAddr       = flag.String("metrics", "", "Address to expose metrics on, i.e. 127.0.0.1:9090 (disabled if empty)")
+	jsonFlag          = flag.Bool("json", false, "Print component documentation in JSON")
+	debug             = flag.Bool("debug", false, "Enable debug mode")
+
+	// Internal
+	optionsPort, logPort, heartbeatPort *zmq.Socket
+	err                                 error
+	logger                              = httputils.NewLogger("http/tunnelrelay")
+	liveness                            = httputils.NewLiveness("http/tunnelrelay")
+	metrics                             = httputils.NewMetrics(logger, liveness)
+	shutdown                            *httputils.Shutdown
+)
+
+// Options describe the configuration IP of the component
+type Options struct {
+	Listen  string `json:"listen"`  // Public HTTP address
+	Tunnel  string `json:"tunnel"`  // Address agents connect to
+	Token   string `json:"token"`   // Shared secret agents have to present
+	Timeout string `json:"timeout"` // Maximal time to wait for agent response
+	Cert    string `json:"cert"`    // TLS certificate for the tunnel address
+	Key     string `json:"key"`     // TLS key for the tunnel address
+}
+
+// validateArgs checks all required flags
+func validateArgs() {
+	if *optionsEndpoint == "" {
+		flag.Usage()
+		os.Exit(1)
+	}
+}
+
+// openPorts create ZMQ sockets
+func openPorts() {
+	optionsPort, err = utils.CreateInputPort("http/tunnelrelay.options", *optionsEndpoint, nil)
+	utils.AssertError(err)
+
+	if *logEndpoint != "" {
+		logPort, err = utils.CreateOutputPort("http/tunnelrelay.log", *logEndpoint, nil)
+		utils.AssertError(err)
+		logger.SetSink(func(ip [][]byte) { logPort.SendMessageDontwait(ip) })
+	}
+
+	if *heartbeatEndpoint != "" {
+		heartbeatPort, err = utils.CreateOutputPort("http/tunnelrelay.heartbeat", *heartbeatEndpoint, nil)
+		utils.AssertError(err)
+		liveness.Start(func(ip [][]byte) { heartbeatPort.SendMessageDontwait(ip) })
+	}
+}
+
+// closePorts closes all active ports and terminates ZMQ context
+func closePorts() {
+	shutdown.Close(optionsPort)
+	liveness.Stop()
+	shutdown.Flush(heartbeatPort, logPort)
+	zmq.Term()
+}
+
+// parseOptions parses and validates configuration IP
+func parseOptions(data []byte) (*Options, time.Duration, error) {
+	options := &Options{Timeout: "30s"}
+	if err := json.Unmarshal(data, options); err != nil {
+		return nil, 0, err
+	}
+	if options.Listen == "" || options.Tunnel == "" {
+		return nil, 0, fmt.Errorf("both listen and tunnel addresses are required")
+	}
+	if options.Token == "" {
+		return nil, 0, fmt.Errorf("token is required")
+	}
+	timeout, err := time.ParseDuration(options.Timeout)
+	if err != nil {
+		return nil, 0, err
+	}
+	return options, timeout, nil
+}
+
+func main() {
+	flag.Parse()
+
+	if *jsonFlag {
+		doc, _ := registryEntry.JSON()
+		fmt.Println(string(doc))
+		os.Exit(0)
+	}
+
+	log.SetFlags(0)
+	if *debug {
+		logger.SetLevel(httputils.LevelDebug)
+		logger.SetOutput(os.Stdout)
+	}
+	log.SetOutput(logger.Writer())
+
+	validateArgs()
+
+	shutdown = httputils.NewShutdown(logger)
+	openPorts()
+
+	if *metricsAddr != "" {
+		err = metrics.Serve(*metricsAddr)
+		utils.AssertError(err)
+	}
+
+	// Wait for the configuration on the options port
+	var (
+		options *Options
+		timeout time.Duration
+	)
+	for {
+		log.Println("Waiting for configuration...")
+		ip, err := shutdown.Receive(optionsPort)
+		if err == httputils.ErrShutdown {
+			shutdown.Exit(closePorts)
+		}
+		if err != nil {
+			logger.Error("Error receiving IP", "error", err)
+			continue
+		}
+		if !runtime.IsValidIP(ip) || !runtime.IsPacket(ip) {
+			continue
+		}
+		options, timeout, err = parseOptions(ip[1])
+		if err != nil {
+			logger.Error("Invalid configuration", "error", err)
+			continue
+		}
+		break
+	}
+	optionsPort.Close()
+	optionsPort = nil
+
+	relay := NewRelay(options.Token, timeout)
+
+	// Tunnel listener goroutine
+	go func() {
+		var (
+			ln   net.Listener
+			cert tls.Certificate
+			err  error
+		)
+		if options.Cert != "" {
+			cert, err = tls.LoadX509KeyPair(options.Cert, options.Key)
+			if err != nil {
+				logger.Error("Failed to load certificate", "error", err)
+				shutdown.Stop()
+				return
+			}
+			ln, err = tls.Listen("tcp", options.Tunnel, &tls.Config{Certificates: []tls.Certificate{cert}})
+		} else {
+			ln, err = net.Listen("tcp", options.Tunnel)
+		}
+		if err != nil {
+			logger.Error("Failed to listen", "addr", options.Tunnel, "error", err)
+			shutdown.Stop()
+			return
+		}
+
+		logger.Info("Waiting for agents", "addr", options.Tunnel)
+		if err = relay.Accept(ln); err != nil {
+			logger.Error("Relay stopped", "error", err)
+			shutdown.Stop()
+		}
+	}()
+
+	// Public web server
+	s := &http.Server{
+		Handler:        relay,
+		ReadTimeout:    10 * time.Second,
+		WriteTimeout:   timeout + 10*time.Second,
+		MaxHeaderBytes: 1 << 20,
+	}
+	ln, err := net.Listen("tcp", options.Listen)
+	utils.AssertError(err)
+
+	logger.Info("Starting listening", "addr", options.Listen)
+	go func() {
+		err := s.Serve(ln)
+		if err != http.ErrServerClosed {
+			logger.Error("Server stopped", "error", err)
+			shutdown.Stop()
+		}
+	}()
+
+	// Stop accepting requests and let active ones get their responses
+	<-shutdown.Done()
+	if err = s.Shutdown(context.Background()); err != nil {
+		logger.Error("Failed to shutdown server", "error", err)
+	}
+	shutdown.Exit(closePorts)
+}
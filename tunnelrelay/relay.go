@@ -0,0 +1,148 @@
+package main
+
+import (
+	"crypto/subtle"
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/cascades-fbp/cascades-http/tunnel"
+	httputils "github.com/cascades-fbp/cascades-http/utils"
+	uuid "github.com/nu7hatch/gouuid"
+)
+
+// Relay forwards public requests to a connected agent
+type Relay struct {
+	token   string
+	timeout time.Duration
+
+	mu      sync.Mutex
+	agent   net.Conn
+	waiters map[string]chan *httputils.HTTPResponse
+}
+
+// NewRelay creates a relay expecting agents with a given token
+func NewRelay(token string, timeout time.Duration) *Relay {
+	return &Relay{
+		token:   token,
+		timeout: timeout,
+		waiters: make(map[string]chan *httputils.HTTPResponse),
+	}
+}
+
+// Accept serves agent connections from a given listener forever
+func (r *Relay) Accept(ln net.Listener) error {
+	for {
+		conn, err := ln.Accept()
+		if err != nil {
+			return err
+		}
+		go r.serveAgent(conn)
+	}
+}
+
+// serveAgent authenticates agent and reads its responses
+func (r *Relay) serveAgent(conn net.Conn) {
+	defer conn.Close()
+
+	conn.SetReadDeadline(time.Now().Add(10 * time.Second))
+	hello, err := tunnel.ReadMessage(conn)
+	if err != nil || hello.Type != tunnel.Hello {
+		logger.Warn("Invalid handshake", "remote", conn.RemoteAddr().String())
+		return
+	}
+	if subtle.ConstantTimeCompare([]byte(hello.Token), []byte(r.token)) != 1 {
+		logger.Warn("Invalid token", "remote", conn.RemoteAddr().String())
+		tunnel.WriteMessage(conn, &tunnel.Message{Type: tunnel.Welcome, Error: "invalid token"})
+		return
+	}
+	conn.SetReadDeadline(time.Time{})
+
+	r.mu.Lock()
+	if r.agent != nil {
+		logger.Warn("Replacing previously connected agent")
+		r.agent.Close()
+	}
+	r.agent = conn
+	err = tunnel.WriteMessage(conn, &tunnel.Message{Type: tunnel.Welcome})
+	r.mu.Unlock()
+	if err != nil {
+		logger.Error("Failed to welcome agent", "error", err)
+		return
+	}
+	logger.Info("Agent connected", "remote", conn.RemoteAddr().String())
+
+	for {
+		m, err := tunnel.ReadMessage(conn)
+		if err != nil {
+			logger.Error("Agent disconnected", "error", err)
+			break
+		}
+		if m.Type != tunnel.Response || m.Response == nil {
+			continue
+		}
+		r.mu.Lock()
+		ch, ok := r.waiters[m.Response.ID]
+		delete(r.waiters, m.Response.ID)
+		r.mu.Unlock()
+		if ok {
+			ch <- m.Response
+		}
+	}
+
+	r.mu.Lock()
+	if r.agent == conn {
+		r.agent = nil
+	}
+	r.mu.Unlock()
+}
+
+// send forwards request to the agent and registers a waiter for its response
+func (r *Relay) send(req *httputils.HTTPRequest) (chan *httputils.HTTPResponse, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.agent == nil {
+		return nil, fmt.Errorf("no agent connected")
+	}
+	ch := make(chan *httputils.HTTPResponse, 1)
+	r.waiters[req.ID] = ch
+	if err := tunnel.WriteMessage(r.agent, &tunnel.Message{Type: tunnel.Request, Request: req}); err != nil {
+		delete(r.waiters, req.ID)
+		return nil, err
+	}
+	return ch, nil
+}
+
+func (r *Relay) ServeHTTP(rw http.ResponseWriter, req *http.Request) {
+	log.Println("Relay:", req.Method, req.RequestURI)
+	liveness.Inc()
+
+	hr, err := httputils.Request2Request(req)
+	if err != nil {
+		logger.Warn("Failed to read request", "error", err)
+		http.Error(rw, "Couldn't read request body", http.StatusBadRequest)
+		return
+	}
+	id, _ := uuid.NewV4()
+	hr.ID = id.String()
+
+	ch, err := r.send(hr)
+	if err != nil {
+		logger.Error("Failed to relay request", "error", err)
+		http.Error(rw, "Tunnel is not available", http.StatusBadGateway)
+		return
+	}
+
+	select {
+	case resp := <-ch:
+		httputils.WriteResponse(rw, resp)
+	case <-time.After(r.timeout):
+		r.mu.Lock()
+		delete(r.waiters, hr.ID)
+		r.mu.Unlock()
+		http.Error(rw, "Couldn't process request in a given time", http.StatusGatewayTimeout)
+	}
+}
@@ -0,0 +1,58 @@
+package main
+
+import "github.com/cascades-fbp/cascades/library"
+
+var registryEntry = &library.Entry{
+	Description: `Uploads IP payloads and files to S3-compatible storage or Google Cloud Storage (HMAC keys) signing
+requests with Signature Version 4. Objects larger than part_size are sent with multipart upload streaming
+files from disk part by part, every request is retried on network errors, throttling and server errors,
+and failed multipart uploads are aborted. Credentials are taken from options or AWS_ACCESS_KEY_ID,
+AWS_SECRET_ACCESS_KEY and AWS_SESSION_TOKEN environment variables.`,
+	Elementary: true,
+	Inports: []library.EntryPort{
+		library.EntryPort{
+			Name:        "OPTIONS",
+			Type:        "json",
+			Description: `Configuration port, i.e. {"endpoint": "https://minio.local:9000", "path_style": true, "access_key": "...", "secret_key": "...", "bucket": "uploads", "prefix": "incoming/", "part_size": 16777216, "retries": 5}`,
+			Required:    true,
+		},
+		library.EntryPort{
+			Name:        "IN",
+			Type:        "string",
+			Description: "Payloads uploaded as objects with random UUID keys",
+			Required:    false,
+		},
+		library.EntryPort{
+			Name:        "FILE",
+			Type:        "string",
+			Description: `Path of a file uploaded with its base name as the key, or JSON object, i.e. {"path": "/tmp/report.csv", "key": "reports/2024-05.csv", "content_type": "text/csv", "remove": true}`,
+			Required:    false,
+		},
+	},
+	Outports: []library.EntryPort{
+		library.EntryPort{
+			Name:        "OUT",
+			Type:        "json",
+			Description: `Uploaded objects, i.e. {"bucket": "uploads", "key": "incoming/report.csv", "url": "https://...", "etag": "\"9b2cf535f27731c974343645a3985328-3\"", "size": 20971520, "parts": 3}`,
+			Required:    true,
+		},
+		library.EntryPort{
+			Name:        "ERR",
+			Type:        "json",
+			Description: "Optional error port for failed uploads (error JSON object defined in utils of HTTP components library)",
+			Required:    false,
+		},
+		library.EntryPort{
+			Name:        "LOG",
+			Type:        "json",
+			Description: "Optional output port for structured log entries (log entry JSON object defined in utils of HTTP components library)",
+			Required:    false,
+		},
+		library.EntryPort{
+			Name:        "HEARTBEAT",
+			Type:        "json",
+			Description: "Optional output port for periodic liveness IPs (heartbeat JSON object defined in utils of HTTP components library)",
+			Required:    false,
+		},
+	},
+}
@@ -0,0 +1,298 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+
+	httputils "github.com/cascades-fbp/cascades-http/utils"
+	"github.com/cascades-fbp/cascades/components/utils"
+	"github.com/cascades-fbp/cascades/runtime"
+	uuid "github.com/nu7hatch/gouuid"
+	zmq "github.com/pebbe/zmq4"
+)
+
+var (
+	// Flags
+	optionsEndpoint   = flag.String("port.options", "", "Component's options port endpoint")
+	inputEndpoint     = flag.String("port.in", "", "Component's input port endpoint")
+	fileEndpoint      = flag.String("port.file", "", "Component's file port endpoint")
+	outputEndpoint    = flag.String("port.out", "", "Component's output port endpoint")
+	errorEndpoint     = flag.String("port.err", "", "Component's error port endpoint")
+	logEndpoint       = flag.String("port.log", "", "Component's log port endpoint")
+	heartbeatEndpoint = flag.String("port.heartbeat", "", "Component's heartbeat port endpoint")
+	metricsAddr       = flag.String("metrics", "", "Address to expose metrics on, i.e. 127.0.0.1:9090 (disabled if empty)")
+	jsonFlag          = flag.Bool("json", false, "Print component documentation in JSON")
+	debug             = flag.Bool("debug", false, "Enable debug mode")
+
+	// Internal
+	optionsPort, inPort, filePort, outPort, errPort, logPort, heartbeatPort *zmq.Socket
+	err                                                                     error
+	logger                                                                  = httputils.NewLogger("http/uploader")
+	liveness                                                                = httputils.NewLiveness("http/uploader")
+	metrics                                                                 = httputils.NewMetrics(logger, liveness)
+	shutdown                                                                *httputils.Shutdown
+)
+
+// File describes a file to upload received on the FILE port, a plain path uses
+// its base name as the key
+type File struct {
+	Path        string `json:"path"`
+	Key         string `json:"key"`
+	ContentType string `json:"content_type"`
+	Remove      bool   `json:"remove"` // Delete the file once uploaded
+}
+
+// validateArgs checks all required flags
+func validateArgs() {
+	if *optionsEndpoint == "" || *outputEndpoint == "" {
+		flag.Usage()
+		os.Exit(1)
+	}
+	if *inputEndpoint == "" && *fileEndpoint == "" {
+		flag.Usage()
+		os.Exit(1)
+	}
+}
+
+// openPorts create ZMQ sockets
+func openPorts() {
+	optionsPort, err = utils.CreateInputPort("http/uploader.options", *optionsEndpoint, nil)
+	utils.AssertError(err)
+
+	if *inputEndpoint != "" {
+		inPort, err = utils.CreateInputPort("http/uploader.in", *inputEndpoint, nil)
+		utils.AssertError(err)
+	}
+
+	if *fileEndpoint != "" {
+		filePort, err = utils.CreateInputPort("http/uploader.file", *fileEndpoint, nil)
+		utils.AssertError(err)
+	}
+
+	outPort, err = utils.CreateOutputPort("http/uploader.out", *outputEndpoint, nil)
+	utils.AssertError(err)
+
+	if *errorEndpoint != "" {
+		errPort, err = utils.CreateOutputPort("http/uploader.err", *errorEndpoint, nil)
+		utils.AssertError(err)
+	}
+
+	if *logEndpoint != "" {
+		logPort, err = utils.CreateOutputPort("http/uploader.log", *logEndpoint, nil)
+		utils.AssertError(err)
+		logger.SetSink(func(ip [][]byte) { logPort.SendMessageDontwait(ip) })
+	}
+
+	if *heartbeatEndpoint != "" {
+		heartbeatPort, err = utils.CreateOutputPort("http/uploader.heartbeat", *heartbeatEndpoint, nil)
+		utils.AssertError(err)
+		liveness.Start(func(ip [][]byte) { heartbeatPort.SendMessageDontwait(ip) })
+	}
+}
+
+// closePorts closes all active ports and terminates ZMQ context
+func closePorts() {
+	shutdown.Close(optionsPort, inPort, filePort)
+	liveness.Stop()
+	shutdown.Flush(outPort, errPort, heartbeatPort, logPort)
+	zmq.Term()
+}
+
+// parseFile decodes a FILE payload
+func parseFile(payload []byte) (*File, error) {
+	f := &File{}
+	if p := bytes.TrimSpace(payload); len(p) > 0 && p[0] == '{' {
+		if err := json.Unmarshal(p, f); err != nil {
+			return nil, err
+		}
+	} else {
+		f.Path = string(p)
+	}
+	if f.Path == "" {
+		return nil, fmt.Errorf("path is empty")
+	}
+	if f.Key == "" {
+		f.Key = filepath.Base(f.Path)
+	}
+	return f, nil
+}
+
+// uploadFile uploads a file streaming it from disk part by part
+func uploadFile(ctx context.Context, uploader *Uploader, f *File) (*Result, error) {
+	file, err := os.Open(f.Path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	info, err := file.Stat()
+	if err != nil {
+		return nil, err
+	}
+	if info.IsDir() {
+		return nil, fmt.Errorf("%s is a directory", f.Path)
+	}
+	return uploader.Upload(ctx, strings.TrimPrefix(f.Key, "/"), file, info.Size(), f.ContentType)
+}
+
+func main() {
+	flag.Parse()
+
+	if *jsonFlag {
+		doc, _ := registryEntry.JSON()
+		fmt.Println(string(doc))
+		os.Exit(0)
+	}
+
+	log.SetFlags(0)
+	if *debug {
+		logger.SetLevel(httputils.LevelDebug)
+		logger.SetOutput(os.Stdout)
+	}
+	log.SetOutput(logger.Writer())
+
+	validateArgs()
+
+	shutdown = httputils.NewShutdown(logger)
+	openPorts()
+
+	if *metricsAddr != "" {
+		err = metrics.Serve(*metricsAddr)
+		utils.AssertError(err)
+	}
+
+	if inPort != nil {
+		err = runtime.SetupShutdownByDisconnect(inPort, "http/uploader.in", shutdown.Signals())
+	} else {
+		err = runtime.SetupShutdownByDisconnect(filePort, "http/uploader.file", shutdown.Signals())
+	}
+	utils.AssertError(err)
+
+	// Wait for the configuration on the options port
+	var uploader *Uploader
+	for uploader == nil {
+		log.Println("Waiting for configuration...")
+		ip, err := shutdown.Receive(optionsPort)
+		if err == httputils.ErrShutdown {
+			shutdown.Exit(closePorts)
+		}
+		if err != nil {
+			logger.Error("Error receiving IP", "error", err)
+			continue
+		}
+		if !runtime.IsValidIP(ip) || !runtime.IsPacket(ip) {
+			continue
+		}
+		options := &Options{}
+		if err = json.Unmarshal(ip[1], options); err != nil {
+			logger.Error("Failed to unmarshal options", "error", err)
+			continue
+		}
+		if uploader, err = NewUploader(options); err != nil {
+			logger.Error("Invalid upload configuration", "error", err)
+			continue
+		}
+	}
+	optionsPort.Close()
+	optionsPort = nil
+
+	// Uploads in progress are interrupted on shutdown
+	ctx, cancel := context.WithCancel(context.Background())
+	go func() {
+		<-shutdown.Done()
+		cancel()
+	}()
+
+	poller := zmq.NewPoller()
+	if inPort != nil {
+		poller.Add(inPort, zmq.POLLIN)
+	}
+	if filePort != nil {
+		poller.Add(filePort, zmq.POLLIN)
+	}
+
+	// Main loop
+	for !shutdown.Stopping() {
+		sockets, err := poller.Poll(shutdown.PollInterval)
+		if err != nil {
+			logger.Error("Error polling ports", "error", err)
+			continue
+		}
+
+		for _, socket := range sockets {
+			ip, err := socket.Socket.RecvMessageBytes(0)
+			if err != nil {
+				logger.Error("Error receiving message", "error", err)
+				continue
+			}
+			liveness.Inc()
+			if !runtime.IsValidIP(ip) || !runtime.IsPacket(ip) {
+				continue
+			}
+
+			var (
+				res    *Result
+				remove string
+			)
+			switch socket.Socket {
+			case inPort:
+				id, _ := uuid.NewV4()
+				res, err = uploader.Upload(ctx, id.String(), bytes.NewReader(ip[1]), int64(len(ip[1])), "")
+
+			case filePort:
+				f, ferr := parseFile(ip[1])
+				if ferr != nil {
+					logger.Warn("Invalid file", "error", ferr)
+					sendError(httputils.NewError("http/uploader", httputils.ErrInvalidIP, ferr))
+					continue
+				}
+				if f.Remove {
+					remove = f.Path
+				}
+				res, err = uploadFile(ctx, uploader, f)
+			}
+			if err != nil {
+				logger.Error("Failed to upload object", "error", err)
+				category := httputils.ErrUpstream
+				if _, ok := err.(*statusError); !ok {
+					category = httputils.ClassifyError(err)
+				}
+				sendError(httputils.NewError("http/uploader", category, err))
+				continue
+			}
+			if remove != "" {
+				if err = os.Remove(remove); err != nil {
+					logger.Warn("Failed to remove uploaded file", "file", remove, "error", err)
+				}
+			}
+
+			payload, err := json.Marshal(res)
+			if err != nil {
+				logger.Error("Failed to marshal upload result", "error", err)
+				sendError(httputils.NewError("http/uploader", httputils.ErrInternal, err))
+				continue
+			}
+			logger.Info("Uploaded object", "bucket", res.Bucket, "key", res.Key, "size", res.Size, "parts", res.Parts)
+			outPort.SendMessage(runtime.NewPacket(payload))
+		}
+	}
+	shutdown.Exit(closePorts)
+}
+
+// sendError reports a failure to the ERR port if it's connected
+func sendError(e *httputils.Error) {
+	if errPort == nil {
+		return
+	}
+	ip, err := httputils.Error2IP(e)
+	if err != nil {
+		return
+	}
+	errPort.SendMessageDontwait(ip)
+}
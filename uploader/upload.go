@@ -0,0 +1,307 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+
+	httputils "github.com/cascades-fbp/cascades-http/utils"
+)
+
+// Options describe the configuration IP of the component
+type Options struct {
+	httputils.StorageCredentials
+	Bucket       string                `json:"bucket"`        // Bucket objects are uploaded to
+	Prefix       string                `json:"prefix"`        // Prepended to keys of all objects, i.e. uploads/
+	ContentType  string                `json:"content_type"`  // Default Content-Type of objects (detected if empty)
+	PartSize     int64                 `json:"part_size"`     // Objects larger than this are uploaded in parts of this size, 8 MiB by default
+	Retries      int                   `json:"retries"`       // Attempts of every request, 3 by default
+	RetryBackoff httputils.Duration    `json:"retry_backoff"` // Delay before the first retry, doubled with every attempt (1s by default)
+	Timeout      httputils.Duration    `json:"timeout"`       // Timeout of a single request, 5m by default
+	TLS          *httputils.TLSOptions `json:"tls"`           // TLS of connections to the endpoint
+}
+
+// Result is the IP sent to the OUT port after an object is uploaded
+type Result struct {
+	Bucket string `json:"bucket"`
+	Key    string `json:"key"`
+	URL    string `json:"url"`
+	ETag   string `json:"etag"`
+	Size   int64  `json:"size"`
+	Parts  int    `json:"parts,omitempty"` // Number of parts of a multipart upload
+}
+
+const (
+	defaultPartSize = 8 << 20
+	minPartSize     = 5 << 20 // S3 rejects smaller parts but the last one
+	maxParts        = 10000
+)
+
+// statusError is a failed storage request
+type statusError struct {
+	op     string
+	status int
+	body   string
+}
+
+func (e *statusError) Error() string {
+	return fmt.Sprintf("%s failed with status %d: %s", e.op, e.status, e.body)
+}
+
+// retryable checks if repeating a failed request may succeed
+func retryable(err error) bool {
+	if se, ok := err.(*statusError); ok {
+		return se.status >= 500 || se.status == http.StatusTooManyRequests || se.status == http.StatusRequestTimeout
+	}
+	return err != context.Canceled
+}
+
+// Uploader puts objects to a bucket, large ones with multipart upload
+type Uploader struct {
+	options *Options
+	signer  *httputils.SigV4
+	client  *http.Client
+}
+
+// NewUploader validates options, applies defaults and creates an uploader
+func NewUploader(options *Options) (*Uploader, error) {
+	if options.Bucket == "" {
+		return nil, fmt.Errorf("bucket is required")
+	}
+	signer, err := httputils.NewSigV4(&options.StorageCredentials)
+	if err != nil {
+		return nil, err
+	}
+	if options.PartSize == 0 {
+		options.PartSize = defaultPartSize
+	}
+	if options.PartSize < minPartSize {
+		return nil, fmt.Errorf("part_size must be at least %d bytes", minPartSize)
+	}
+	if options.Retries <= 0 {
+		options.Retries = 3
+	}
+	if options.RetryBackoff <= 0 {
+		options.RetryBackoff = httputils.Duration(time.Second)
+	}
+	if options.Timeout <= 0 {
+		options.Timeout = httputils.Duration(5 * time.Minute)
+	}
+	tr := &http.Transport{Proxy: http.ProxyFromEnvironment}
+	if options.TLS != nil {
+		if tr.TLSClientConfig, err = options.TLS.ClientConfig(); err != nil {
+			return nil, err
+		}
+	}
+	return &Uploader{
+		options: options,
+		signer:  signer,
+		client:  &http.Client{Transport: tr, Timeout: time.Duration(options.Timeout)},
+	}, nil
+}
+
+// Upload puts size bytes of r to a key, object key is prefixed by options
+func (u *Uploader) Upload(ctx context.Context, key string, r io.ReaderAt, size int64, contentType string) (*Result, error) {
+	key = u.options.Prefix + key
+	if contentType == "" {
+		contentType = u.options.ContentType
+	}
+	if contentType == "" {
+		head := make([]byte, 512)
+		n, _ := r.ReadAt(head, 0)
+		contentType = http.DetectContentType(head[:n])
+	}
+	object := u.signer.ObjectURL(u.options.Bucket, key)
+	res := &Result{Bucket: u.options.Bucket, Key: key, URL: object.String(), Size: size}
+
+	if size <= u.options.PartSize {
+		body := make([]byte, size)
+		if _, err := r.ReadAt(body, 0); err != nil && err != io.EOF {
+			return nil, err
+		}
+		header := http.Header{"Content-Type": {contentType}}
+		resp, err := u.do(ctx, "upload", http.MethodPut, object, header, body)
+		if err != nil {
+			return nil, err
+		}
+		res.ETag = resp.Header.Get("ETag")
+		return res, nil
+	}
+
+	partSize := u.options.PartSize
+	if parts := (size + partSize - 1) / partSize; parts > maxParts {
+		partSize = (size + maxParts - 1) / maxParts
+	}
+	id, err := u.create(ctx, object, contentType)
+	if err != nil {
+		return nil, err
+	}
+	var complete completeUpload
+	buf := make([]byte, partSize)
+	for offset, number := int64(0), 1; offset < size; offset, number = offset+partSize, number+1 {
+		part := buf
+		if left := size - offset; left < partSize {
+			part = buf[:left]
+		}
+		if _, err = r.ReadAt(part, offset); err != nil && err != io.EOF {
+			break
+		}
+		var resp *response
+		if resp, err = u.do(ctx, fmt.Sprintf("part %d", number), http.MethodPut, withQuery(object, url.Values{
+			"partNumber": {strconv.Itoa(number)},
+			"uploadId":   {id},
+		}), nil, part); err != nil {
+			break
+		}
+		complete.Parts = append(complete.Parts, completedPart{Number: number, ETag: resp.Header.Get("ETag")})
+		logger.Debug("Uploaded part", "key", key, "part", number, "size", len(part))
+	}
+	if err == nil {
+		res.ETag, err = u.complete(ctx, object, id, &complete)
+	}
+	if err != nil {
+		u.abort(object, id)
+		return nil, err
+	}
+	res.Parts = len(complete.Parts)
+	return res, nil
+}
+
+type initiateResult struct {
+	UploadID string `xml:"UploadId"`
+}
+
+type completedPart struct {
+	Number int    `xml:"PartNumber"`
+	ETag   string `xml:"ETag"`
+}
+
+type completeUpload struct {
+	XMLName xml.Name        `xml:"CompleteMultipartUpload"`
+	Parts   []completedPart `xml:"Part"`
+}
+
+type completeResult struct {
+	XMLName xml.Name
+	ETag    string `xml:"ETag"`
+	Code    string `xml:"Code"`
+	Message string `xml:"Message"`
+}
+
+// create initiates a multipart upload returning its ID
+func (u *Uploader) create(ctx context.Context, object *url.URL, contentType string) (string, error) {
+	resp, err := u.do(ctx, "initiate", http.MethodPost, withQuery(object, url.Values{"uploads": {""}}), http.Header{"Content-Type": {contentType}}, nil)
+	if err != nil {
+		return "", err
+	}
+	var result initiateResult
+	if err = xml.Unmarshal(resp.body, &result); err != nil || result.UploadID == "" {
+		return "", fmt.Errorf("initiate: invalid response %q", truncate(resp.body))
+	}
+	return result.UploadID, nil
+}
+
+// complete assembles uploaded parts returning ETag of the object. Errors may
+// be reported in the body of a successful response.
+func (u *Uploader) complete(ctx context.Context, object *url.URL, id string, parts *completeUpload) (string, error) {
+	body, err := xml.Marshal(parts)
+	if err != nil {
+		return "", err
+	}
+	resp, err := u.do(ctx, "complete", http.MethodPost, withQuery(object, url.Values{"uploadId": {id}}), http.Header{"Content-Type": {"application/xml"}}, body)
+	if err != nil {
+		return "", err
+	}
+	var result completeResult
+	if err = xml.Unmarshal(resp.body, &result); err != nil {
+		return "", fmt.Errorf("complete: invalid response %q", truncate(resp.body))
+	}
+	if result.XMLName.Local == "Error" {
+		return "", fmt.Errorf("complete: %s: %s", result.Code, result.Message)
+	}
+	return result.ETag, nil
+}
+
+// abort discards uploaded parts, failures are only logged as storage expires
+// incomplete uploads by lifecycle rules
+func (u *Uploader) abort(object *url.URL, id string) {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	if _, err := u.send(ctx, "abort", http.MethodDelete, withQuery(object, url.Values{"uploadId": {id}}), nil, nil); err != nil {
+		logger.Warn("Failed to abort multipart upload", "url", object.String(), "error", err)
+	}
+}
+
+// response is a storage response with its body read
+type response struct {
+	*http.Response
+	body []byte
+}
+
+// do sends a signed request retrying network errors, throttling and server errors
+func (u *Uploader) do(ctx context.Context, op, method string, object *url.URL, header http.Header, body []byte) (*response, error) {
+	backoff := time.Duration(u.options.RetryBackoff)
+	for attempt := 1; ; attempt++ {
+		resp, err := u.send(ctx, op, method, object, header, body)
+		if err == nil || attempt >= u.options.Retries || !retryable(err) || ctx.Err() != nil {
+			return resp, err
+		}
+		logger.Warn("Storage request failed, retrying", "operation", op, "attempt", attempt, "error", err)
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-time.After(backoff):
+		}
+		backoff *= 2
+	}
+}
+
+// send performs a single signed request
+func (u *Uploader) send(ctx context.Context, op, method string, object *url.URL, header http.Header, body []byte) (*response, error) {
+	req, err := http.NewRequest(method, object.String(), bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req = req.WithContext(ctx)
+	for k, v := range header {
+		req.Header[k] = v
+	}
+	hash := sha256.Sum256(body)
+	u.signer.Sign(req, hex.EncodeToString(hash[:]), time.Now())
+
+	resp, err := u.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	data, err := ioutil.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode >= 300 {
+		return nil, &statusError{op: op, status: resp.StatusCode, body: truncate(data)}
+	}
+	return &response{Response: resp, body: data}, nil
+}
+
+func withQuery(object *url.URL, q url.Values) *url.URL {
+	u := *object
+	u.RawQuery = q.Encode()
+	return &u
+}
+
+func truncate(body []byte) string {
+	if len(body) > 256 {
+		body = body[:256]
+	}
+	return string(body)
+}
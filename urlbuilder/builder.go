@@ -0,0 +1,91 @@
+package main
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+)
+
+// Builder holds the current URL parts
+type Builder struct {
+	Scheme string
+	Host   string
+	Path   string
+}
+
+// Build returns the URL for given query values. Values with ":name" keys
+// fill corresponding path placeholders.
+func (b *Builder) Build(query url.Values) (string, error) {
+	if b.Host == "" {
+		return "", fmt.Errorf("host is not set")
+	}
+	scheme := b.Scheme
+	if scheme == "" {
+		scheme = "http"
+	}
+
+	path, err := expand(b.Path, query)
+	if err != nil {
+		return "", err
+	}
+	if path != "" && path[0] != '/' {
+		path = "/" + path
+	}
+
+	rest := make(url.Values, len(query))
+	for k, v := range query {
+		if !strings.HasPrefix(k, ":") {
+			rest[k] = v
+		}
+	}
+
+	u := &url.URL{
+		Scheme:   scheme,
+		Host:     b.Host,
+		RawQuery: rest.Encode(),
+	}
+	u.Path, err = url.PathUnescape(path)
+	if err != nil {
+		return "", err
+	}
+	u.RawPath = path
+	return u.String(), nil
+}
+
+// expand replaces :name placeholders in the template with escaped values. A
+// colon not followed by a letter or underscore (i.e. /items:batchGet is a
+// placeholder, /at/12:30 isn't) is kept as is.
+func expand(tpl string, query url.Values) (string, error) {
+	var buf strings.Builder
+	for i := 0; i < len(tpl); {
+		if tpl[i] != ':' || i+1 == len(tpl) || !isAlpha(tpl[i+1]) {
+			buf.WriteByte(tpl[i])
+			i++
+			continue
+		}
+		j := i + 1
+		for j < len(tpl) && isAlnum(tpl[j]) {
+			j++
+		}
+		name := tpl[i:j]
+		value, ok := query[name]
+		if !ok || len(value) == 0 {
+			return "", fmt.Errorf("missing value for path parameter %s", name)
+		}
+		buf.WriteString(url.PathEscape(value[0]))
+		i = j
+	}
+	return buf.String(), nil
+}
+
+func isAlpha(ch byte) bool {
+	return 'a' <= ch && ch <= 'z' || 'A' <= ch && ch <= 'Z' || ch == '_'
+}
+
+func isDigit(ch byte) bool {
+	return '0' <= ch && ch <= '9'
+}
+
+func isAlnum(ch byte) bool {
+	return isAlpha(ch) || isDigit(ch)
+}
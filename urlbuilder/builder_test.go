@@ -0,0 +1,54 @@
+package main
+
+import (
+	"net/url"
+	"testing"
+)
+
+func TestBuild(t *testing.T) {
+	tests := []struct {
+		name  string
+		path  string
+		query url.Values
+		want  string
+	}{
+		{"placeholder", "/users/:id/posts", url.Values{":id": {"42"}, "tag": {"a", "b"}}, "http://example.com/users/42/posts?tag=a&tag=b"},
+		{"escaped value", "/files/:name", url.Values{":name": {"a b/c"}}, "http://example.com/files/a%20b%2Fc"},
+		{"placeholder in segment", "/v1/items:batchGet", url.Values{":batchGet": {"get"}}, "http://example.com/v1/itemsget"},
+		{"time", "/at/12:30", nil, "http://example.com/at/12:30"},
+		{"digit after colon", "/ratio/:16:9", nil, "http://example.com/ratio/:16:9"},
+		{"trailing colon", "/items:", nil, "http://example.com/items:"},
+		{"double colon", "/a::b", url.Values{":b": {"x"}}, "http://example.com/a:x"},
+		{"no leading slash", "users", nil, "http://example.com/users"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			b := &Builder{Host: "example.com", Path: tt.path}
+			got, err := b.Build(tt.query)
+			if err != nil {
+				t.Fatalf("Build() error = %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("Build() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestBuildInvalid(t *testing.T) {
+	tests := []struct {
+		name string
+		b    Builder
+	}{
+		{"no host", Builder{Path: "/users"}},
+		{"missing placeholder", Builder{Host: "example.com", Path: "/v1/items:batchGet"}},
+		{"missing value", Builder{Host: "example.com", Path: "/users/:id"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got, err := tt.b.Build(url.Values{"q": {"go"}}); err == nil {
+				t.Errorf("Build() = %v, expected error", got)
+			}
+		})
+	}
+}
@@ -0,0 +1,63 @@
+package main
+
+import "github.com/cascades-fbp/cascades/library"
+
+var registryEntry = &library.Entry{
+	Description: `Assembles URLs from scheme, host, path template and query map with correct escaping.
+Path template may contain :name placeholders (same syntax as in http/router) which are filled from
+":name" keys of the query map, the rest of the keys form the query string. A URL is emitted every time
+a QUERY IP arrives (or a PATH IP, when QUERY port is not connected).`,
+	Elementary: true,
+	Inports: []library.EntryPort{
+		library.EntryPort{
+			Name:        "SCHEME",
+			Type:        "string",
+			Description: "Optional URL scheme (http by default)",
+			Required:    false,
+		},
+		library.EntryPort{
+			Name:        "HOST",
+			Type:        "string",
+			Description: "Host with optional port, i.e. api.example.com:8080",
+			Required:    true,
+		},
+		library.EntryPort{
+			Name:        "PATH",
+			Type:        "string",
+			Description: "Path template, i.e. /users/:id/posts",
+			Required:    false,
+		},
+		library.EntryPort{
+			Name:        "QUERY",
+			Type:        "json",
+			Description: `JSON object with path parameters and query values, i.e. {":id": 42, "tag": ["a", "b"]}`,
+			Required:    false,
+		},
+	},
+	Outports: []library.EntryPort{
+		library.EntryPort{
+			Name:        "OUT",
+			Type:        "string",
+			Description: "Output port for assembled URLs",
+			Required:    true,
+		},
+		library.EntryPort{
+			Name:        "ERR",
+			Type:        "string",
+			Description: "Error port for URLs that couldn't be assembled",
+			Required:    false,
+		},
+		library.EntryPort{
+			Name:        "LOG",
+			Type:        "json",
+			Description: "Optional output port for structured log entries (log entry JSON object defined in utils of HTTP components library)",
+			Required:    false,
+		},
+		library.EntryPort{
+			Name:        "HEARTBEAT",
+			Type:        "json",
+			Description: "Optional output port for periodic liveness IPs (heartbeat JSON object defined in utils of HTTP components library)",
+			Required:    false,
+		},
+	},
+}
@@ -0,0 +1,205 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"net/url"
+	"os"
+	"strings"
+
+	httputils "github.com/cascades-fbp/cascades-http/utils"
+	"github.com/cascades-fbp/cascades/components/utils"
+	"github.com/cascades-fbp/cascades/runtime"
+	zmq "github.com/pebbe/zmq4"
+)
+
+var (
+	// Flags
+	schemeEndpoint    = flag.String("port.scheme", "", "Component's scheme input port endpoint")
+	hostEndpoint      = flag.String("port.host", "", "Component's host input port endpoint")
+	pathEndpoint      = flag.String("port.path", "", "Component's path input port endpoint")
+	queryEndpoint     = flag.String("port.query", "", "Component's query input port endpoint")
+	outputEndpoint    = flag.String("port.out", "", "Component's output port endpoint")
+	errorEndpoint     = flag.String("port.err", "", "Component's error port endpoint")
+	logEndpoint       = flag.String("port.log", "", "Component's log port endpoint")
+	heartbeatEndpoint = flag.String("port.heartbeat", "", "Component's heartbeat port endpoint")
+	metricsAddr       = flag.String("metrics", "", "Address to expose metrics on, i.e. 127.0.0.1:9090 (disabled if empty)")
+	jsonFlag          = flag.Bool("json", false, "Print component documentation in JSON")
+	debug             = flag.Bool("debug", false, "Enable debug mode")
+
+	// Internal
+	schemePort, hostPort, pathPort, queryPort, outPort, errPort, logPort, heartbeatPort *zmq.Socket
+	err                                                                                 error
+	logger                                                                              = httputils.NewLogger("http/urlbuilder")
+	liveness                                                                            = httputils.NewLiveness("http/urlbuilder")
+	metrics                                                                             = httputils.NewMetrics(logger, liveness)
+	shutdown                                                                            *httputils.Shutdown
+)
+
+// validateArgs checks all required flags
+func validateArgs() {
+	if *hostEndpoint == "" {
+		flag.Usage()
+		os.Exit(1)
+	}
+	if *pathEndpoint == "" && *queryEndpoint == "" {
+		flag.Usage()
+		os.Exit(1)
+	}
+	if *outputEndpoint == "" {
+		flag.Usage()
+		os.Exit(1)
+	}
+}
+
+// openPorts create ZMQ sockets
+func openPorts() {
+	if *schemeEndpoint != "" {
+		schemePort, err = utils.CreateInputPort("http/urlbuilder.scheme", *schemeEndpoint, nil)
+		utils.AssertError(err)
+	}
+
+	hostPort, err = utils.CreateInputPort("http/urlbuilder.host", *hostEndpoint, nil)
+	utils.AssertError(err)
+
+	if *pathEndpoint != "" {
+		pathPort, err = utils.CreateInputPort("http/urlbuilder.path", *pathEndpoint, nil)
+		utils.AssertError(err)
+	}
+	if *queryEndpoint != "" {
+		queryPort, err = utils.CreateInputPort("http/urlbuilder.query", *queryEndpoint, nil)
+		utils.AssertError(err)
+	}
+
+	outPort, err = utils.CreateOutputPort("http/urlbuilder.out", *outputEndpoint, nil)
+	utils.AssertError(err)
+
+	if *errorEndpoint != "" {
+		errPort, err = utils.CreateOutputPort("http/urlbuilder.err", *errorEndpoint, nil)
+		utils.AssertError(err)
+	}
+
+	if *logEndpoint != "" {
+		logPort, err = utils.CreateOutputPort("http/urlbuilder.log", *logEndpoint, nil)
+		utils.AssertError(err)
+		logger.SetSink(func(ip [][]byte) { logPort.SendMessageDontwait(ip) })
+	}
+
+	if *heartbeatEndpoint != "" {
+		heartbeatPort, err = utils.CreateOutputPort("http/urlbuilder.heartbeat", *heartbeatEndpoint, nil)
+		utils.AssertError(err)
+		liveness.Start(func(ip [][]byte) { heartbeatPort.SendMessageDontwait(ip) })
+	}
+}
+
+// closePorts closes all active ports and terminates ZMQ context
+func closePorts() {
+	shutdown.Close(schemePort, hostPort, pathPort, queryPort)
+	liveness.Stop()
+	shutdown.Flush(outPort, errPort, heartbeatPort, logPort)
+	zmq.Term()
+}
+
+func main() {
+	flag.Parse()
+
+	if *jsonFlag {
+		doc, _ := registryEntry.JSON()
+		fmt.Println(string(doc))
+		os.Exit(0)
+	}
+
+	log.SetFlags(0)
+	if *debug {
+		logger.SetLevel(httputils.LevelDebug)
+		logger.SetOutput(os.Stdout)
+	}
+	log.SetOutput(logger.Writer())
+
+	validateArgs()
+
+	shutdown = httputils.NewShutdown(logger)
+	openPorts()
+
+	if *metricsAddr != "" {
+		err = metrics.Serve(*metricsAddr)
+		utils.AssertError(err)
+	}
+
+	// The component terminates when its triggering port is disconnected
+	triggerPort, triggerName := queryPort, "http/urlbuilder.query"
+	if triggerPort == nil {
+		triggerPort, triggerName = pathPort, "http/urlbuilder.path"
+	}
+
+	err = runtime.SetupShutdownByDisconnect(triggerPort, triggerName, shutdown.Signals())
+	utils.AssertError(err)
+
+	poller := zmq.NewPoller()
+	for _, p := range []*zmq.Socket{schemePort, hostPort, pathPort, queryPort} {
+		if p != nil {
+			poller.Add(p, zmq.POLLIN)
+		}
+	}
+
+	builder := &Builder{}
+
+	// Main loop
+	for !shutdown.Stopping() {
+		sockets, err := poller.Poll(shutdown.PollInterval)
+		if err != nil {
+			logger.Error("Error polling ports", "error", err)
+			continue
+		}
+		for _, socket := range sockets {
+			ip, err := socket.Socket.RecvMessageBytes(0)
+			if err != nil {
+				logger.Error("Error receiving message", "error", err)
+				continue
+			}
+			liveness.Inc()
+			if !runtime.IsValidIP(ip) || !runtime.IsPacket(ip) {
+				continue
+			}
+
+			var query url.Values
+			switch socket.Socket {
+			case schemePort:
+				builder.Scheme = strings.TrimSpace(string(ip[1]))
+				continue
+			case hostPort:
+				builder.Host = strings.TrimSpace(string(ip[1]))
+				continue
+			case pathPort:
+				builder.Path = strings.TrimSpace(string(ip[1]))
+				if queryPort != nil {
+					continue
+				}
+			case queryPort:
+				query, err = httputils.JSON2Values(ip[1])
+				if err != nil {
+					logger.Warn("Failed to parse query", "error", err)
+					sendError(err)
+					continue
+				}
+			}
+
+			u, err := builder.Build(query)
+			if err != nil {
+				logger.Error("Failed to build URL", "error", err)
+				sendError(err)
+				continue
+			}
+			outPort.SendMessage(runtime.NewPacket([]byte(u)))
+		}
+	}
+	shutdown.Exit(closePorts)
+}
+
+// sendError emits error to ERR port if it's connected
+func sendError(err error) {
+	if errPort != nil {
+		errPort.SendMessageDontwait(runtime.NewPacket([]byte(err.Error())))
+	}
+}
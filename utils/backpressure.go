@@ -0,0 +1,185 @@
+package utils
+
+import (
+	"fmt"
+	"sync"
+	"sync/atomic"
+
+	zmq "github.com/pebbe/zmq4"
+)
+
+// Overflow policies of output queues
+const (
+	OverflowBlock      = "block"       // Sender waits until the queue has room
+	OverflowDropOldest = "drop-oldest" // The oldest queued IP is dropped
+	OverflowDropNew    = "drop-new"    // The IP being sent is dropped
+)
+
+const defaultHWM = 1000
+
+// BackpressureOptions configure queues of output ports
+type BackpressureOptions struct {
+	HWM      int    `json:"hwm"`      // Maximal number of IPs queued per output port (default 1000)
+	Overflow string `json:"overflow"` // Policy when the queue is full: block (default), drop-oldest or drop-new
+}
+
+// Validate checks the options are supported
+func (b *BackpressureOptions) Validate() error {
+	if b.HWM < 0 {
+		return fmt.Errorf("negative hwm %d", b.HWM)
+	}
+	switch b.Overflow {
+	case "", OverflowBlock, OverflowDropOldest, OverflowDropNew:
+		return nil
+	}
+	return fmt.Errorf("unknown overflow policy %s", b.Overflow)
+}
+
+// Outlet is a bounded queue in front of an output port. IPs are sent to the
+// socket by a background goroutine, so a slow consumer fills the queue (on
+// top of ZMQ's own pipe) instead of growing memory of the component. When it's
+// full the overflow policy applies; dropped IPs are passed to the drop handler,
+// usually reporting them on the ERR port. The socket must not be used
+// elsewhere while the outlet is open.
+type Outlet struct {
+	name    string
+	socket  *zmq.Socket
+	onDrop  func(ip [][]byte)
+	dropped uint64
+	sent    uint64
+
+	mu     sync.Mutex
+	cond   *sync.Cond
+	queue  [][][]byte
+	hwm    int
+	policy string
+	closed bool
+	done   chan struct{}
+}
+
+// NewOutlet starts an outlet for a socket with a given port name, i.e. http/router.fail.
+// The drop handler may be nil.
+func NewOutlet(name string, socket *zmq.Socket, options *BackpressureOptions, onDrop func(ip [][]byte)) *Outlet {
+	o := &Outlet{
+		name:   name,
+		socket: socket,
+		onDrop: onDrop,
+		done:   make(chan struct{}),
+	}
+	o.cond = sync.NewCond(&o.mu)
+	o.Apply(options)
+	go o.run()
+	return o
+}
+
+// Apply changes queue size and overflow policy. Invalid options are ignored,
+// they're expected to be validated beforehand.
+func (o *Outlet) Apply(options *BackpressureOptions) {
+	if options == nil || options.Validate() != nil {
+		return
+	}
+	o.mu.Lock()
+	o.hwm = options.HWM
+	if o.hwm == 0 {
+		o.hwm = defaultHWM
+	}
+	o.policy = options.Overflow
+	if o.policy == "" {
+		o.policy = OverflowBlock
+	}
+	o.cond.Broadcast()
+	o.mu.Unlock()
+}
+
+// Name returns the port name of the outlet
+func (o *Outlet) Name() string {
+	return o.name
+}
+
+// Dropped returns the number of IPs dropped by the overflow policy
+func (o *Outlet) Dropped() uint64 {
+	return atomic.LoadUint64(&o.dropped)
+}
+
+// Sent returns the number of IPs handed to the socket
+func (o *Outlet) Sent() uint64 {
+	return atomic.LoadUint64(&o.sent)
+}
+
+// Len returns the number of queued IPs
+func (o *Outlet) Len() int {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	return len(o.queue)
+}
+
+// Send queues an IP applying the overflow policy when the queue is full. IPs
+// sent after Close are discarded.
+func (o *Outlet) Send(ip [][]byte) {
+	o.mu.Lock()
+	for len(o.queue) >= o.hwm && o.policy == OverflowBlock && !o.closed {
+		o.cond.Wait()
+	}
+	if o.closed {
+		o.mu.Unlock()
+		return
+	}
+	var dropped [][]byte
+	if len(o.queue) >= o.hwm {
+		if o.policy == OverflowDropNew {
+			dropped, ip = ip, nil
+		} else {
+			dropped = o.queue[0]
+			o.queue = o.queue[1:]
+		}
+	}
+	if ip != nil {
+		o.queue = append(o.queue, ip)
+		o.cond.Broadcast()
+	}
+	o.mu.Unlock()
+
+	if dropped != nil {
+		atomic.AddUint64(&o.dropped, 1)
+		if o.onDrop != nil {
+			o.onDrop(dropped)
+		}
+	}
+}
+
+// Close stops accepting IPs and waits until queued ones are handed to the
+// socket. The socket itself is left open. Nil outlets are ignored.
+func (o *Outlet) Close() {
+	if o == nil {
+		return
+	}
+	o.mu.Lock()
+	o.closed = true
+	o.cond.Broadcast()
+	o.mu.Unlock()
+	<-o.done
+}
+
+// run sends queued IPs to the socket until the outlet is closed and drained
+func (o *Outlet) run() {
+	defer close(o.done)
+	for {
+		o.mu.Lock()
+		for len(o.queue) == 0 && !o.closed {
+			o.cond.Wait()
+		}
+		if len(o.queue) == 0 {
+			o.mu.Unlock()
+			return
+		}
+		ip := o.queue[0]
+		o.queue[0] = nil
+		o.queue = o.queue[1:]
+		o.cond.Broadcast()
+		o.mu.Unlock()
+
+		if _, err := o.socket.SendMessage(ip); err == nil {
+			atomic.AddUint64(&o.sent, 1)
+		}
+	}
+}
@@ -0,0 +1,105 @@
+package utils_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/cascades-fbp/cascades-http/testutils"
+	httputils "github.com/cascades-fbp/cascades-http/utils"
+)
+
+// benchRequest is a typical JSON API request
+func benchRequest() *httputils.HTTPRequest {
+	return testutils.JSONRequest("POST", "/api/v1/users?limit=10", map[string]interface{}{
+		"name":  "John",
+		"email": "john@example.com",
+		"tags":  []string{"a", "b", "c"},
+	}).WithHeader("Authorization", "Bearer token").WithQuery("limit", "10")
+}
+
+// benchResponse is a response with 4KB text body
+func benchResponse() *httputils.HTTPResponse {
+	return testutils.TextResponse(200, strings.Repeat("lorem ipsum ", 340))
+}
+
+func BenchmarkRequest2IP(b *testing.B) {
+	req := benchRequest()
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		if _, err := httputils.Request2IP(req); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkIP2Request(b *testing.B) {
+	ip, err := httputils.Request2IP(benchRequest())
+	if err != nil {
+		b.Fatal(err)
+	}
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := httputils.IP2Request(ip); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkResponse2IP(b *testing.B) {
+	resp := benchResponse()
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		if _, err := httputils.Response2IP(resp); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkIP2Response(b *testing.B) {
+	ip, err := httputils.Response2IP(benchResponse())
+	if err != nil {
+		b.Fatal(err)
+	}
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := httputils.IP2Response(ip); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkRequest2IPMsgpack(b *testing.B) {
+	req := benchRequest()
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		if _, err := httputils.Request2IPMsgpack(req); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkRequest2IPProtobuf(b *testing.B) {
+	req := benchRequest()
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		if _, err := httputils.Request2IPProtobuf(req); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkIP2RequestProtobuf(b *testing.B) {
+	ip, err := httputils.Request2IPProtobuf(benchRequest())
+	if err != nil {
+		b.Fatal(err)
+	}
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := httputils.IP2Request(ip); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
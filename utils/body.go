@@ -0,0 +1,85 @@
+package utils
+
+import (
+	"bytes"
+	"io"
+	"io/ioutil"
+)
+
+// SetBodyReader makes the request body streamed from a given reader. The body is read
+// only when the request is written to net/http or materialized for serialization.
+func (r *HTTPRequest) SetBodyReader(rd io.Reader, length int64) {
+	r.Body = nil
+	r.BodyReader = rd
+	r.ContentLength = length
+}
+
+// SetBodyReader makes the response body streamed from a given reader. The body is read
+// only when the response is written to net/http or materialized for serialization.
+func (r *HTTPResponse) SetBodyReader(rd io.Reader) {
+	r.Body = nil
+	r.BodyReader = rd
+}
+
+// BodyStream returns a reader over the request body without materializing it
+func (r *HTTPRequest) BodyStream() io.Reader {
+	return bodyStream(r.Body, r.BodyReader)
+}
+
+// BodyStream returns a reader over the response body without materializing it
+func (r *HTTPResponse) BodyStream() io.Reader {
+	return bodyStream(r.Body, r.BodyReader)
+}
+
+// Materialize reads streamed body into Body. IP codecs call it before encoding.
+func (r *HTTPRequest) Materialize() error {
+	if r.BodyReader == nil {
+		return nil
+	}
+	var err error
+	r.Body, r.BodyReader, err = materialize(r.Body, r.BodyReader)
+	r.ContentLength = int64(len(r.Body))
+	return err
+}
+
+// Materialize reads streamed body into Body. IP codecs call it before encoding.
+func (r *HTTPResponse) Materialize() error {
+	if r.BodyReader == nil {
+		return nil
+	}
+	var err error
+	r.Body, r.BodyReader, err = materialize(r.Body, r.BodyReader)
+	return err
+}
+
+func bodyStream(body []byte, rd io.Reader) io.Reader {
+	if rd == nil {
+		return bytes.NewReader(body)
+	}
+	if len(body) == 0 {
+		return rd
+	}
+	return io.MultiReader(bytes.NewReader(body), rd)
+}
+
+// materialize reads the rest of the stream after already materialized bytes. On failure
+// the reader is replaced with one repeating the error, so it isn't silently lost.
+func materialize(body []byte, rd io.Reader) ([]byte, io.Reader, error) {
+	data, err := ioutil.ReadAll(rd)
+	body = append(body, data...)
+	if closer, ok := rd.(io.Closer); ok {
+		closer.Close()
+	}
+	if err != nil {
+		return body, errReader{err}, err
+	}
+	return body, nil, nil
+}
+
+type errReader struct {
+	err error
+}
+
+func (r errReader) Read([]byte) (int, error) {
+	return 0, r.err
+}
@@ -0,0 +1,172 @@
+package utils
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+)
+
+// NewRequest creates a request with a given method and URI ready for chaining
+func NewRequest(method, uri string) *HTTPRequest {
+	return &HTTPRequest{
+		Method: method,
+		URI:    uri,
+		Header: make(map[string][]string),
+		Form:   make(map[string][]string),
+		Query:  make(map[string][]string),
+	}
+}
+
+// WithID sets request ID
+func (r *HTTPRequest) WithID(id string) *HTTPRequest {
+	r.ID = id
+	return r
+}
+
+// WithTrace sets W3C traceparent of the request
+func (r *HTTPRequest) WithTrace(traceparent string) *HTTPRequest {
+	r.Trace = traceparent
+	return r
+}
+
+// WithHeader adds a header value to the request
+func (r *HTTPRequest) WithHeader(name, value string) *HTTPRequest {
+	r.AddHeader(name, value)
+	return r
+}
+
+// WithForm adds a body form value to the request
+func (r *HTTPRequest) WithForm(name, value string) *HTTPRequest {
+	if r.Form == nil {
+		r.Form = make(map[string][]string)
+	}
+	if r.PostForm == nil {
+		r.PostForm = make(map[string][]string)
+	}
+	r.Form[name] = append(r.Form[name], value)
+	r.PostForm[name] = append(r.PostForm[name], value)
+	return r
+}
+
+// WithQuery adds a URL query value to the request
+func (r *HTTPRequest) WithQuery(name, value string) *HTTPRequest {
+	if r.Form == nil {
+		r.Form = make(map[string][]string)
+	}
+	if r.Query == nil {
+		r.Query = make(map[string][]string)
+	}
+	r.Form[name] = append(r.Form[name], value)
+	r.Query[name] = append(r.Query[name], value)
+	return r
+}
+
+// WithBody sets request body of a given content type
+func (r *HTTPRequest) WithBody(contentType string, body []byte) *HTTPRequest {
+	if r.Header == nil {
+		r.Header = make(map[string][]string)
+	}
+	h := http.Header(r.Header)
+	h.Set("Content-Type", contentType)
+	h.Set("Content-Length", strconv.Itoa(len(body)))
+	r.Body = body
+	r.ContentLength = int64(len(body))
+	return r
+}
+
+// WithJSONBody sets request body to JSON encoding of a given value. If the value can't
+// be encoded, i.e. NaN floats or failing MarshalJSON methods, the body is left unset
+// and the error is returned by IP.
+func (r *HTTPRequest) WithJSONBody(v interface{}) *HTTPRequest {
+	if err := r.SetJSON(v); err != nil && r.buildErr == nil {
+		r.buildErr = err
+	}
+	return r
+}
+
+// IP converts the request to IP, failing with the first error of builder methods
+func (r *HTTPRequest) IP() ([][]byte, error) {
+	if r.buildErr != nil {
+		return nil, r.buildErr
+	}
+	return Request2IP(r)
+}
+
+// MustIP converts the request to IP panicking on error
+func (r *HTTPRequest) MustIP() [][]byte {
+	ip, err := r.IP()
+	if err != nil {
+		panic(err)
+	}
+	return ip
+}
+
+// NewResponse creates a response with a given status ready for chaining
+func NewResponse(status int) *HTTPResponse {
+	return &HTTPResponse{
+		StatusCode: status,
+		Header:     make(map[string][]string),
+	}
+}
+
+// WithID sets response ID (normally the ID of the request being answered)
+func (r *HTTPResponse) WithID(id string) *HTTPResponse {
+	r.ID = id
+	return r
+}
+
+// WithTrace sets W3C traceparent of the response (normally the one of the request being answered)
+func (r *HTTPResponse) WithTrace(traceparent string) *HTTPResponse {
+	r.Trace = traceparent
+	return r
+}
+
+// WithHeader adds a header value to the response
+func (r *HTTPResponse) WithHeader(name, value string) *HTTPResponse {
+	r.AddHeader(name, value)
+	return r
+}
+
+// WithBody sets response body of a given content type
+func (r *HTTPResponse) WithBody(contentType string, body []byte) *HTTPResponse {
+	if r.Header == nil {
+		r.Header = make(map[string][]string)
+	}
+	h := http.Header(r.Header)
+	h.Set("Content-Type", contentType)
+	h.Set("Content-Length", strconv.Itoa(len(body)))
+	r.Body = body
+	return r
+}
+
+// WithText sets plain text response body
+func (r *HTTPResponse) WithText(format string, args ...interface{}) *HTTPResponse {
+	return r.WithBody("text/plain; charset=utf-8", []byte(fmt.Sprintf(format, args...)))
+}
+
+// WithJSON sets response body to JSON encoding of a given value. If the value can't
+// be encoded, i.e. NaN floats or failing MarshalJSON methods, the body is left unset
+// and the error is returned by IP.
+func (r *HTTPResponse) WithJSON(v interface{}) *HTTPResponse {
+	if err := r.SetJSON(v); err != nil && r.buildErr == nil {
+		r.buildErr = err
+	}
+	return r
+}
+
+// IP converts the response to IP, failing with the first error of builder methods
+func (r *HTTPResponse) IP() ([][]byte, error) {
+	if r.buildErr != nil {
+		return nil, r.buildErr
+	}
+	return Response2IP(r)
+}
+
+// MustIP converts the response to IP panicking on error
+func (r *HTTPResponse) MustIP() [][]byte {
+	ip, err := r.IP()
+	if err != nil {
+		panic(err)
+	}
+	return ip
+}
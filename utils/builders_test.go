@@ -0,0 +1,48 @@
+package utils
+
+import (
+	"errors"
+	"math"
+	"testing"
+)
+
+type failingMarshaler struct{}
+
+func (failingMarshaler) MarshalJSON() ([]byte, error) {
+	return nil, errors.New("boom")
+}
+
+func TestWithJSONErrors(t *testing.T) {
+	for name, v := range map[string]interface{}{
+		"nan":       math.NaN(),
+		"inf":       map[string]float64{"x": math.Inf(1)},
+		"marshaler": failingMarshaler{},
+		"channel":   make(chan int),
+	} {
+		t.Run(name, func(t *testing.T) {
+			if _, err := NewRequest("POST", "/").WithJSONBody(v).IP(); err == nil {
+				t.Error("request IP() expected error")
+			}
+			if _, err := NewResponse(200).WithJSON(v).WithHeader("X-Test", "1").IP(); err == nil {
+				t.Error("response IP() expected error")
+			}
+		})
+	}
+}
+
+func TestWithJSON(t *testing.T) {
+	ip, err := NewResponse(200).WithJSON(map[string]int{"n": 1}).IP()
+	if err != nil {
+		t.Fatalf("IP() error = %v", err)
+	}
+	resp, err := IP2Response(ip)
+	if err != nil {
+		t.Fatalf("IP2Response() error = %v", err)
+	}
+	if string(resp.Body) != `{"n":1}` {
+		t.Errorf("body = %s", resp.Body)
+	}
+	if ct := resp.GetHeader("Content-Type"); ct != "application/json; charset=utf-8" {
+		t.Errorf("Content-Type = %q", ct)
+	}
+}
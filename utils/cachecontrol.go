@@ -0,0 +1,124 @@
+package utils
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// CacheControl is a parsed Cache-Control header. Duration directives are -1 when absent.
+type CacheControl struct {
+	MaxAge               time.Duration
+	SMaxAge              time.Duration
+	StaleWhileRevalidate time.Duration
+	StaleIfError         time.Duration
+	NoStore              bool
+	NoCache              bool
+	Private              bool
+	Public               bool
+	MustRevalidate       bool
+	Immutable            bool
+	Extensions           map[string]string // Unknown directives
+}
+
+// ParseCacheControl parses Cache-Control header value
+func ParseCacheControl(header string) *CacheControl {
+	cc := &CacheControl{
+		MaxAge:               -1,
+		SMaxAge:              -1,
+		StaleWhileRevalidate: -1,
+		StaleIfError:         -1,
+	}
+	for _, part := range strings.Split(header, ",") {
+		kv := strings.SplitN(strings.TrimSpace(part), "=", 2)
+		name := strings.ToLower(kv[0])
+		value := ""
+		if len(kv) == 2 {
+			value = strings.Trim(kv[1], `"`)
+		}
+		switch name {
+		case "":
+		case "max-age":
+			cc.MaxAge = parseSeconds(value)
+		case "s-maxage":
+			cc.SMaxAge = parseSeconds(value)
+		case "stale-while-revalidate":
+			cc.StaleWhileRevalidate = parseSeconds(value)
+		case "stale-if-error":
+			cc.StaleIfError = parseSeconds(value)
+		case "no-store":
+			cc.NoStore = true
+		case "no-cache":
+			cc.NoCache = true
+		case "private":
+			cc.Private = true
+		case "public":
+			cc.Public = true
+		case "must-revalidate", "proxy-revalidate":
+			cc.MustRevalidate = true
+		case "immutable":
+			cc.Immutable = true
+		default:
+			if cc.Extensions == nil {
+				cc.Extensions = make(map[string]string)
+			}
+			cc.Extensions[name] = value
+		}
+	}
+	return cc
+}
+
+// Storable reports whether a response may be stored by a private or shared cache
+func (cc *CacheControl) Storable(shared bool) bool {
+	return !cc.NoStore && !(shared && cc.Private)
+}
+
+// Lifetime returns freshness lifetime given by the directives (s-maxage wins for
+// shared caches) and false if neither of them is present
+func (cc *CacheControl) Lifetime(shared bool) (time.Duration, bool) {
+	if shared && cc.SMaxAge >= 0 {
+		return cc.SMaxAge, true
+	}
+	if cc.MaxAge >= 0 {
+		return cc.MaxAge, true
+	}
+	return 0, false
+}
+
+// FreshnessLifetime computes freshness lifetime of a response from its Cache-Control,
+// Expires and Date headers. It returns false if headers give no explicit lifetime.
+func FreshnessLifetime(header map[string][]string, shared bool) (time.Duration, bool) {
+	h := http.Header(header)
+	cc := ParseCacheControl(strings.Join(h["Cache-Control"], ","))
+	if !cc.Storable(shared) || cc.NoCache {
+		return 0, true
+	}
+	if lifetime, ok := cc.Lifetime(shared); ok {
+		return lifetime, true
+	}
+	if v := h.Get("Expires"); v != "" {
+		expires, err := http.ParseTime(v)
+		if err != nil {
+			// Invalid Expires means already expired
+			return 0, true
+		}
+		date, err := http.ParseTime(h.Get("Date"))
+		if err != nil {
+			date = time.Now()
+		}
+		if lifetime := expires.Sub(date); lifetime > 0 {
+			return lifetime, true
+		}
+		return 0, true
+	}
+	return 0, false
+}
+
+func parseSeconds(value string) time.Duration {
+	n, err := strconv.ParseInt(value, 10, 64)
+	if err != nil || n < 0 {
+		return -1
+	}
+	return time.Duration(n) * time.Second
+}
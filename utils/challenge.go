@@ -0,0 +1,117 @@
+package utils
+
+import (
+	"strings"
+)
+
+// Challenge is a single authentication challenge of WWW-Authenticate header
+type Challenge struct {
+	Scheme  string            // Basic, Digest, Bearer, etc
+	Params  map[string]string // Auth parameters with lowercase names
+	Token68 string            // Token form of the challenge (i.e. Negotiate)
+}
+
+// ParseChallenges parses WWW-Authenticate (or Proxy-Authenticate) header values
+func ParseChallenges(values ...string) []Challenge {
+	var challenges []Challenge
+	for _, value := range values {
+		for _, item := range splitQuoted(value, ',') {
+			item = strings.TrimSpace(item)
+			if item == "" {
+				continue
+			}
+			word := item
+			if i := strings.IndexAny(item, " \t"); i >= 0 {
+				word = item[:i]
+			}
+			if !strings.Contains(word, "=") {
+				// New challenge starts with auth scheme
+				challenges = append(challenges, Challenge{
+					Scheme: word,
+					Params: make(map[string]string),
+				})
+				item = strings.TrimSpace(item[len(word):])
+				if item == "" {
+					continue
+				}
+			}
+			if len(challenges) == 0 {
+				continue
+			}
+			c := &challenges[len(challenges)-1]
+			if isToken68(item) {
+				c.Token68 = item
+				continue
+			}
+			kv := strings.SplitN(item, "=", 2)
+			if len(kv) != 2 {
+				continue
+			}
+			c.Params[strings.ToLower(strings.TrimSpace(kv[0]))] = unquote(strings.TrimSpace(kv[1]))
+		}
+	}
+	return challenges
+}
+
+// FindChallenge returns the first challenge of a given scheme
+func FindChallenge(challenges []Challenge, scheme string) (Challenge, bool) {
+	for _, c := range challenges {
+		if strings.EqualFold(c.Scheme, scheme) {
+			return c, true
+		}
+	}
+	return Challenge{}, false
+}
+
+// Realm returns protection space of the challenge
+func (c Challenge) Realm() string {
+	return c.Params["realm"]
+}
+
+// ErrorCode returns error code of Bearer challenge (invalid_request, invalid_token or insufficient_scope)
+func (c Challenge) ErrorCode() string {
+	return c.Params["error"]
+}
+
+// ErrorDescription returns human readable error description of Bearer challenge
+func (c Challenge) ErrorDescription() string {
+	return c.Params["error_description"]
+}
+
+// InvalidToken reports whether Bearer challenge asks the client to refresh its token
+func (c Challenge) InvalidToken() bool {
+	return strings.EqualFold(c.Scheme, "Bearer") && c.ErrorCode() == "invalid_token"
+}
+
+// isToken68 checks if a value has token68 syntax (RFC 7235)
+func isToken68(value string) bool {
+	trimmed := strings.TrimRight(value, "=")
+	if trimmed == "" {
+		return false
+	}
+	for _, r := range trimmed {
+		if !(r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' || strings.ContainsRune("-._~+/", r)) {
+			return false
+		}
+	}
+	return true
+}
+
+// unquote removes quotes and escapes of a quoted-string
+func unquote(value string) string {
+	if len(value) < 2 || value[0] != '"' || value[len(value)-1] != '"' {
+		return value
+	}
+	value = value[1 : len(value)-1]
+	var b strings.Builder
+	escaped := false
+	for _, r := range value {
+		if r == '\\' && !escaped {
+			escaped = true
+			continue
+		}
+		escaped = false
+		b.WriteRune(r)
+	}
+	return b.String()
+}
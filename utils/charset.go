@@ -0,0 +1,122 @@
+package utils
+
+import (
+	"bytes"
+	"mime"
+	"strings"
+	"unicode/utf8"
+
+	"golang.org/x/net/html/charset"
+)
+
+// DetectCharset returns charset of a body using Content-Type parameter, BOM or
+// HTML meta tags (in this order) and whether the detection is certain
+func DetectCharset(contentType string, body []byte) (string, bool) {
+	_, name, certain := charset.DetermineEncoding(body, contentType)
+	return name, certain
+}
+
+// ToUTF8 transcodes a body to UTF-8 when its BOM or Content-Type charset names
+// another encoding. JSON bodies and bodies which are valid UTF-8 as a whole are
+// returned unchanged, so are ones without a declared charset.
+func ToUTF8(contentType string, body []byte) ([]byte, error) {
+	body, _, err := toUTF8(contentType, body)
+	return body, err
+}
+
+// toUTF8 is ToUTF8 which also reports whether the body was transcoded
+func toUTF8(contentType string, body []byte) ([]byte, bool, error) {
+	if IsJSONContentType(contentType) || utf8.Valid(body) {
+		return body, false, nil
+	}
+	label, n := bomCharset(body)
+	if label == "" {
+		if _, params, err := mime.ParseMediaType(contentType); err == nil {
+			label = params["charset"]
+		}
+	}
+	if label == "" {
+		return body, false, nil
+	}
+	e, name := charset.Lookup(label)
+	if e == nil {
+		return nil, false, &UnknownCharsetError{Label: label}
+	}
+	if name == "utf-8" {
+		return body, false, nil
+	}
+	data, err := e.NewDecoder().Bytes(body[n:])
+	if err != nil {
+		return nil, false, err
+	}
+	return data, true, nil
+}
+
+// bomCharset returns charset named by the byte order mark of a body and its length
+func bomCharset(body []byte) (string, int) {
+	switch {
+	case bytes.HasPrefix(body, []byte{0xEF, 0xBB, 0xBF}):
+		return "utf-8", 3
+	case bytes.HasPrefix(body, []byte{0xFE, 0xFF}):
+		return "utf-16be", 2
+	case bytes.HasPrefix(body, []byte{0xFF, 0xFE}):
+		return "utf-16le", 2
+	}
+	return "", 0
+}
+
+// FromUTF8 transcodes UTF-8 body to a given charset
+func FromUTF8(label string, body []byte) ([]byte, error) {
+	e, name := charset.Lookup(label)
+	if e == nil {
+		return nil, &UnknownCharsetError{Label: label}
+	}
+	if name == "utf-8" {
+		return body, nil
+	}
+	return e.NewEncoder().Bytes(body)
+}
+
+// UnknownCharsetError is returned for charset labels missing in the WHATWG encoding list
+type UnknownCharsetError struct {
+	Label string
+}
+
+func (e *UnknownCharsetError) Error() string {
+	return "unknown charset " + e.Label
+}
+
+// DecodeUTF8 transcodes text response body to UTF-8 with ToUTF8 and updates
+// Content-Type charset
+func (r *HTTPResponse) DecodeUTF8() error {
+	contentType := r.GetHeader("Content-Type")
+	if !isTextual(contentType) {
+		return nil
+	}
+	body, transcoded, err := toUTF8(contentType, r.Body)
+	if err != nil {
+		return err
+	}
+	r.Body = body
+	if mediaType, params, err := mime.ParseMediaType(contentType); err == nil && (transcoded || params["charset"] != "") {
+		params["charset"] = "utf-8"
+		r.SetHeader("Content-Type", mime.FormatMediaType(mediaType, params))
+	}
+	return nil
+}
+
+// isTextual checks if a content type carries text which charset conversion applies to
+func isTextual(contentType string) bool {
+	if contentType == "" {
+		return true
+	}
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return false
+	}
+	return strings.HasPrefix(mediaType, "text/") ||
+		IsJSONContentType(mediaType) ||
+		strings.HasSuffix(mediaType, "xml") ||
+		mediaType == "application/javascript" ||
+		mediaType == "application/x-www-form-urlencoded"
+}
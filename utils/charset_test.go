@@ -0,0 +1,66 @@
+package utils
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestToUTF8(t *testing.T) {
+	// Valid UTF-8 past the 1024 bytes sniffed by charset detection
+	late := []byte(strings.Repeat("a", 2048) + "zażółć")
+
+	tests := []struct {
+		name        string
+		contentType string
+		body        []byte
+		want        []byte
+	}{
+		{"valid utf-8 beyond sniffing", "text/html", late, late},
+		{"valid utf-8 with latin1 header", "text/plain; charset=iso-8859-1", []byte("zażółć"), []byte("zażółć")},
+		{"json", "application/json", []byte{'"', 0xE9, '"'}, []byte{'"', 0xE9, '"'}},
+		{"json suffix", "application/ld+json; charset=windows-1252", []byte{'"', 0xE9, '"'}, []byte{'"', 0xE9, '"'}},
+		{"latin1 header", "text/plain; charset=iso-8859-1", []byte{'c', 'a', 'f', 0xE9}, []byte("café")},
+		{"windows-1252 header", "text/html; charset=windows-1252", []byte{0x93, 'q', 0x94}, []byte("“q”")},
+		{"utf-16le bom", "text/plain", []byte{0xFF, 0xFE, 'h', 0, 'i', 0}, []byte("hi")},
+		{"utf-16be bom over header", "text/plain; charset=iso-8859-1", []byte{0xFE, 0xFF, 0, 'h', 0, 'i'}, []byte("hi")},
+		{"undeclared", "text/plain", []byte{'c', 'a', 'f', 0xE9}, []byte{'c', 'a', 'f', 0xE9}},
+		{"utf-8 header", "text/plain; charset=utf-8", []byte{'c', 'a', 'f', 0xE9}, []byte{'c', 'a', 'f', 0xE9}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ToUTF8(tt.contentType, tt.body)
+			if err != nil {
+				t.Fatalf("ToUTF8() error = %v", err)
+			}
+			if !bytes.Equal(got, tt.want) {
+				t.Errorf("ToUTF8() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+
+	if _, err := ToUTF8("text/plain; charset=klingon", []byte{0xE9}); err == nil {
+		t.Error("ToUTF8() expected error for unknown charset")
+	}
+}
+
+func TestDecodeUTF8(t *testing.T) {
+	resp := NewResponse(200).WithBody("text/plain; charset=iso-8859-1", []byte{'c', 'a', 'f', 0xE9})
+	if err := resp.DecodeUTF8(); err != nil {
+		t.Fatalf("DecodeUTF8() error = %v", err)
+	}
+	if string(resp.Body) != "café" {
+		t.Errorf("body = %q", resp.Body)
+	}
+	if ct := resp.GetHeader("Content-Type"); ct != "text/plain; charset=utf-8" {
+		t.Errorf("Content-Type = %q", ct)
+	}
+
+	undeclared := NewResponse(200).WithBody("text/plain", []byte{0xE9})
+	if err := undeclared.DecodeUTF8(); err != nil {
+		t.Fatalf("DecodeUTF8() error = %v", err)
+	}
+	if ct := undeclared.GetHeader("Content-Type"); ct != "text/plain" {
+		t.Errorf("Content-Type of undeclared body = %q", ct)
+	}
+}
@@ -0,0 +1,24 @@
+package utils
+
+import (
+	"net"
+	"strings"
+)
+
+// ClientIP returns the address of the client without port. With trustForwarded
+// the first X-Forwarded-For or X-Real-Ip value is used, it must only be set when
+// a proxy in front of the server replaces these headers.
+func (r *HTTPRequest) ClientIP(trustForwarded bool) string {
+	if trustForwarded {
+		if forwarded := r.GetHeader("X-Forwarded-For"); forwarded != "" {
+			return strings.TrimSpace(strings.Split(forwarded, ",")[0])
+		}
+		if real := r.GetHeader("X-Real-Ip"); real != "" {
+			return strings.TrimSpace(real)
+		}
+	}
+	if host, _, err := net.SplitHostPort(r.RemoteAddr); err == nil {
+		return host
+	}
+	return r.RemoteAddr
+}
@@ -0,0 +1,95 @@
+package utils
+
+// Clone returns a deep copy of the request. Streamed body is materialized first,
+// a read error stays with both copies and surfaces when they are encoded.
+func (r *HTTPRequest) Clone() *HTTPRequest {
+	if r == nil {
+		return nil
+	}
+	r.Materialize()
+	c := *r
+	c.Header = cloneValues(r.Header)
+	c.Form = cloneValues(r.Form)
+	c.Query = cloneValues(r.Query)
+	c.PostForm = cloneValues(r.PostForm)
+	c.Trailer = cloneValues(r.Trailer)
+	c.Body = cloneBytes(r.Body)
+	if r.TLS != nil {
+		tls := *r.TLS
+		c.TLS = &tls
+	}
+	if r.Groups != nil {
+		c.Groups = append([]string(nil), r.Groups...)
+	}
+	if r.Roles != nil {
+		c.Roles = append([]string(nil), r.Roles...)
+	}
+	if r.Bot != nil {
+		bot := *r.Bot
+		bot.Reasons = append([]string(nil), r.Bot.Reasons...)
+		c.Bot = &bot
+	}
+	if r.Geo != nil {
+		geo := *r.Geo
+		c.Geo = &geo
+	}
+	if r.Route != nil {
+		route := *r.Route
+		if r.Route.Labels != nil {
+			route.Labels = make(map[string]string, len(r.Route.Labels))
+			for k, v := range r.Route.Labels {
+				route.Labels[k] = v
+			}
+		}
+		c.Route = &route
+	}
+	if r.Cookies != nil {
+		c.Cookies = make(map[string]string, len(r.Cookies))
+		for k, v := range r.Cookies {
+			c.Cookies[k] = v
+		}
+	}
+	return &c
+}
+
+// Clone returns a deep copy of the response. Streamed body is materialized first,
+// a read error stays with both copies and surfaces when they are encoded.
+func (r *HTTPResponse) Clone() *HTTPResponse {
+	if r == nil {
+		return nil
+	}
+	r.Materialize()
+	c := *r
+	c.Header = cloneValues(r.Header)
+	c.Trailer = cloneValues(r.Trailer)
+	c.Body = cloneBytes(r.Body)
+	if r.Redirects != nil {
+		c.Redirects = append([]string(nil), r.Redirects...)
+	}
+	if r.Cookies != nil {
+		c.Cookies = make([]*Cookie, len(r.Cookies))
+		for i, cookie := range r.Cookies {
+			cc := *cookie
+			c.Cookies[i] = &cc
+		}
+	}
+	return &c
+}
+
+func cloneValues(m map[string][]string) map[string][]string {
+	if m == nil {
+		return nil
+	}
+	c := make(map[string][]string, len(m))
+	for k, v := range m {
+		c[k] = append([]string(nil), v...)
+	}
+	return c
+}
+
+func cloneBytes(b []byte) []byte {
+	if b == nil {
+		return nil
+	}
+	return append([]byte{}, b...)
+}
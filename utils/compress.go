@@ -0,0 +1,103 @@
+package utils
+
+import (
+	"bytes"
+	"compress/flate"
+	"compress/gzip"
+	"compress/zlib"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"strconv"
+	"strings"
+)
+
+// GzipResponse compresses response body with a given gzip level and updates
+// Content-Encoding, Content-Length and Vary headers. Responses which already have
+// Content-Encoding are left untouched.
+func GzipResponse(resp *HTTPResponse, level int) error {
+	if len(resp.Body) == 0 || resp.GetHeader("Content-Encoding") != "" {
+		return nil
+	}
+	var buf bytes.Buffer
+	w, err := gzip.NewWriterLevel(&buf, level)
+	if err != nil {
+		return err
+	}
+	if _, err = w.Write(resp.Body); err != nil {
+		return err
+	}
+	if err = w.Close(); err != nil {
+		return err
+	}
+	resp.Body = buf.Bytes()
+	resp.SetHeader("Content-Encoding", "gzip")
+	resp.SetHeader("Content-Length", strconv.Itoa(len(resp.Body)))
+	if !hasToken(HeaderValues(resp.Header, "Vary"), "Accept-Encoding") {
+		resp.AddHeader("Vary", "Accept-Encoding")
+	}
+	return nil
+}
+
+// DefaultMaxDecompressedSize bounds bodies decoded by GunzipResponse without a limit
+const DefaultMaxDecompressedSize = 64 << 20
+
+// GunzipResponse decodes gzip or deflate encoded response body and removes the
+// encoding from headers. Responses without Content-Encoding are left untouched.
+// Decoded bodies exceeding maxSize bytes (DefaultMaxDecompressedSize if not
+// positive) fail, so compressed bombs can't exhaust memory.
+func GunzipResponse(resp *HTTPResponse, maxSize int64) error {
+	encoding := strings.ToLower(strings.TrimSpace(resp.GetHeader("Content-Encoding")))
+	var r io.Reader
+	switch encoding {
+	case "", "identity":
+		return nil
+	case "gzip", "x-gzip":
+		gr, err := gzip.NewReader(bytes.NewReader(resp.Body))
+		if err != nil {
+			return err
+		}
+		r = gr
+	case "deflate":
+		// HTTP deflate is zlib wrapped, raw deflate is sent by some broken servers
+		zr, err := zlib.NewReader(bytes.NewReader(resp.Body))
+		if err != nil {
+			r = flate.NewReader(bytes.NewReader(resp.Body))
+		} else {
+			r = zr
+		}
+	default:
+		return fmt.Errorf("unsupported content encoding %q", encoding)
+	}
+	if maxSize <= 0 {
+		maxSize = DefaultMaxDecompressedSize
+	}
+	body, err := ioutil.ReadAll(io.LimitReader(r, maxSize+1))
+	if err != nil {
+		return err
+	}
+	if int64(len(body)) > maxSize {
+		return fmt.Errorf("decompressed body exceeds %d bytes", maxSize)
+	}
+	resp.Body = body
+	resp.DelHeader("Content-Encoding")
+	resp.SetHeader("Content-Length", strconv.Itoa(len(body)))
+	return nil
+}
+
+// AcceptsGzip checks if a request allows gzip encoded responses
+func AcceptsGzip(req *HTTPRequest) bool {
+	return ParseAccept(req.GetHeader("Accept-Encoding")).Quality("gzip") > 0
+}
+
+// hasToken checks if comma separated header values contain a given token
+func hasToken(values []string, token string) bool {
+	for _, v := range values {
+		for _, t := range strings.Split(v, ",") {
+			if strings.EqualFold(strings.TrimSpace(t), token) {
+				return true
+			}
+		}
+	}
+	return false
+}
@@ -0,0 +1,67 @@
+package utils
+
+import (
+	"bytes"
+	"compress/flate"
+	"compress/gzip"
+	"compress/zlib"
+	"io"
+	"strings"
+	"testing"
+)
+
+func compress(t *testing.T, encoding string, data []byte) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	var w io.WriteCloser
+	switch encoding {
+	case "gzip":
+		w = gzip.NewWriter(&buf)
+	case "deflate":
+		w = zlib.NewWriter(&buf)
+	case "raw":
+		w, _ = flate.NewWriter(&buf, flate.DefaultCompression)
+	}
+	w.Write(data)
+	w.Close()
+	return buf.Bytes()
+}
+
+func TestGunzipResponse(t *testing.T) {
+	text := []byte(strings.Repeat("hello, world ", 100))
+	for _, tt := range []struct{ format, header string }{
+		{"gzip", "gzip"},
+		{"gzip", "x-gzip"},
+		{"deflate", "deflate"},
+		{"raw", "deflate"},
+	} {
+		t.Run(tt.format+"/"+tt.header, func(t *testing.T) {
+			resp := NewResponse(200).WithBody("text/plain", compress(t, tt.format, text)).WithHeader("Content-Encoding", tt.header)
+			if err := GunzipResponse(resp, 0); err != nil {
+				t.Fatalf("GunzipResponse() error = %v", err)
+			}
+			if !bytes.Equal(resp.Body, text) {
+				t.Errorf("body = %q", resp.Body)
+			}
+			if resp.GetHeader("Content-Encoding") != "" {
+				t.Error("Content-Encoding was not removed")
+			}
+		})
+	}
+}
+
+func TestGunzipResponseLimit(t *testing.T) {
+	bomb := compress(t, "gzip", make([]byte, 10<<20))
+	resp := NewResponse(200).WithBody("text/plain", bomb).WithHeader("Content-Encoding", "gzip")
+	if err := GunzipResponse(resp, 1<<20); err == nil {
+		t.Fatal("GunzipResponse() expected error for body over the limit")
+	}
+	if !bytes.Equal(resp.Body, bomb) || resp.GetHeader("Content-Encoding") != "gzip" {
+		t.Error("response was changed by failed decompression")
+	}
+
+	exact := NewResponse(200).WithBody("text/plain", compress(t, "gzip", make([]byte, 1024))).WithHeader("Content-Encoding", "gzip")
+	if err := GunzipResponse(exact, 1024); err != nil {
+		t.Errorf("GunzipResponse() error = %v for body of the limit size", err)
+	}
+}
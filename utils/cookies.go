@@ -0,0 +1,104 @@
+package utils
+
+import (
+	"net/http"
+	"strings"
+	"time"
+)
+
+// Cookie describes a cookie set by the response
+type Cookie struct {
+	Name     string    `json:"name"`
+	Value    string    `json:"value"`
+	Path     string    `json:"path"`
+	Domain   string    `json:"domain"`
+	Expires  time.Time `json:"expires"`
+	MaxAge   int       `json:"max-age"` // MaxAge<0 deletes the cookie
+	Secure   bool      `json:"secure"`
+	HttpOnly bool      `json:"http-only"`
+	SameSite string    `json:"same-site"` // Lax, Strict or None
+}
+
+var sameSiteModes = map[string]http.SameSite{
+	"lax":    http.SameSiteLaxMode,
+	"strict": http.SameSiteStrictMode,
+	"none":   http.SameSiteNoneMode,
+}
+
+// String returns serialization of the cookie for Set-Cookie header
+func (c *Cookie) String() string {
+	return c.HTTP().String()
+}
+
+// HTTP converts the cookie to the standard one
+func (c *Cookie) HTTP() *http.Cookie {
+	hc := &http.Cookie{
+		Name:     c.Name,
+		Value:    c.Value,
+		Path:     c.Path,
+		Domain:   c.Domain,
+		Expires:  c.Expires,
+		MaxAge:   c.MaxAge,
+		Secure:   c.Secure,
+		HttpOnly: c.HttpOnly,
+	}
+	if mode, ok := sameSiteModes[strings.ToLower(c.SameSite)]; ok {
+		hc.SameSite = mode
+	}
+	return hc
+}
+
+// NewCookie converts a standard cookie, i.e. parsed from Set-Cookie header
+func NewCookie(hc *http.Cookie) *Cookie {
+	c := &Cookie{
+		Name:     hc.Name,
+		Value:    hc.Value,
+		Path:     hc.Path,
+		Domain:   hc.Domain,
+		Expires:  hc.Expires,
+		MaxAge:   hc.MaxAge,
+		Secure:   hc.Secure,
+		HttpOnly: hc.HttpOnly,
+	}
+	switch hc.SameSite {
+	case http.SameSiteLaxMode:
+		c.SameSite = "Lax"
+	case http.SameSiteStrictMode:
+		c.SameSite = "Strict"
+	case http.SameSiteNoneMode:
+		c.SameSite = "None"
+	}
+	return c
+}
+
+// SetCookie adds a cookie to the response replacing the one with the same name and path
+func (r *HTTPResponse) SetCookie(c *Cookie) {
+	for i, existing := range r.Cookies {
+		if existing.Name == c.Name && existing.Path == c.Path {
+			r.Cookies[i] = c
+			return
+		}
+	}
+	r.Cookies = append(r.Cookies, c)
+}
+
+// DelCookie instructs the client to remove a cookie with a given name and path
+func (r *HTTPResponse) DelCookie(name, path string) {
+	r.SetCookie(&Cookie{
+		Name:    name,
+		Path:    path,
+		Expires: time.Unix(0, 0),
+		MaxAge:  -1,
+	})
+}
+
+// CookieHeaders returns values of Set-Cookie headers for the response cookies
+func (r *HTTPResponse) CookieHeaders() []string {
+	var values []string
+	for _, c := range r.Cookies {
+		if v := c.String(); v != "" {
+			values = append(values, v)
+		}
+	}
+	return values
+}
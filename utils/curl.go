@@ -0,0 +1,235 @@
+package utils
+
+import (
+	"encoding/base64"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// curlSwitches are curl options without arguments which don't affect the request
+var curlSwitches = map[string]bool{
+	"-s": true, "--silent": true,
+	"-S": true, "--show-error": true,
+	"-v": true, "--verbose": true,
+	"-k": true, "--insecure": true,
+	"-L": true, "--location": true,
+	"-i": true, "--include": true,
+	"--compressed": true,
+}
+
+// ParseCurl converts a curl command line into request structure. Supported options are
+// -X, -H, -d (and --data-* variants), --data-urlencode, -F, -G, -u, -A, -b and --url.
+func ParseCurl(command string) (*HTTPRequest, error) {
+	args, err := splitCommand(command)
+	if err != nil {
+		return nil, err
+	}
+	if len(args) > 0 && args[0] == "curl" {
+		args = args[1:]
+	}
+
+	var (
+		method    string
+		rawURL    string
+		data      []string
+		form      = url.Values{}
+		multipart bool
+		get       bool
+		header    = http.Header{}
+	)
+	for i := 0; i < len(args); i++ {
+		arg := args[i]
+		if !strings.HasPrefix(arg, "-") {
+			rawURL = arg
+			continue
+		}
+		if curlSwitches[arg] {
+			continue
+		}
+		if len(arg) > 2 && arg[1] != '-' && strings.Trim(arg[1:], "sSvkLi") == "" {
+			// Combined switches, i.e. -sSL
+			continue
+		}
+		if len(arg) > 2 && arg[1] != '-' && strings.ContainsRune("XHdFuAeb", rune(arg[1])) {
+			// Short option with attached argument, i.e. -XPOST
+			args = append(args[:i+1], append([]string{arg[2:]}, args[i+1:]...)...)
+			arg = arg[:2]
+		}
+		if arg == "-G" || arg == "--get" {
+			get = true
+			continue
+		}
+		if arg == "-I" || arg == "--head" {
+			method = "HEAD"
+			continue
+		}
+		if i+1 >= len(args) {
+			return nil, fmt.Errorf("curl option %s requires an argument", arg)
+		}
+		i++
+		value := args[i]
+		switch arg {
+		case "-X", "--request":
+			method = strings.ToUpper(value)
+		case "--url":
+			rawURL = value
+		case "-H", "--header":
+			parts := strings.SplitN(value, ":", 2)
+			if len(parts) != 2 {
+				return nil, fmt.Errorf("invalid curl header %q", value)
+			}
+			header.Add(strings.TrimSpace(parts[0]), strings.TrimSpace(parts[1]))
+		case "-d", "--data", "--data-raw", "--data-binary", "--data-ascii":
+			if strings.HasPrefix(value, "@") && arg != "--data-raw" {
+				return nil, fmt.Errorf("reading curl data from file %s is not supported", value[1:])
+			}
+			data = append(data, value)
+		case "--data-urlencode":
+			data = append(data, urlencodeCurlData(value))
+		case "-F", "--form":
+			parts := strings.SplitN(value, "=", 2)
+			if len(parts) != 2 {
+				return nil, fmt.Errorf("invalid curl form field %q", value)
+			}
+			if strings.HasPrefix(parts[1], "@") || strings.HasPrefix(parts[1], "<") {
+				return nil, fmt.Errorf("uploading curl form file %s is not supported", parts[1][1:])
+			}
+			form.Add(parts[0], parts[1])
+			multipart = true
+		case "-u", "--user":
+			header.Set("Authorization", "Basic "+base64.StdEncoding.EncodeToString([]byte(value)))
+		case "-A", "--user-agent":
+			header.Set("User-Agent", value)
+		case "-e", "--referer":
+			header.Set("Referer", value)
+		case "-b", "--cookie":
+			header.Add("Cookie", value)
+		default:
+			return nil, fmt.Errorf("unsupported curl option %s", arg)
+		}
+	}
+	if rawURL == "" {
+		return nil, fmt.Errorf("curl command has no URL")
+	}
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return nil, err
+	}
+	if u.Scheme == "" {
+		u, err = url.Parse("http://" + rawURL)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	req := NewRequest("GET", "")
+	req.Header = header
+	req.Host = u.Host
+	req.Scheme = u.Scheme
+
+	body := strings.Join(data, "&")
+	switch {
+	case get && body != "":
+		if u.RawQuery != "" {
+			u.RawQuery += "&"
+		}
+		u.RawQuery += body
+	case multipart:
+		payload, contentType, err := EncodeMultipart(form)
+		if err != nil {
+			return nil, err
+		}
+		req.WithBody(contentType, payload)
+		req.Method = "POST"
+	case len(data) > 0:
+		contentType := header.Get("Content-Type")
+		if contentType == "" {
+			contentType = "application/x-www-form-urlencoded"
+		}
+		req.WithBody(contentType, []byte(body))
+		req.Method = "POST"
+		if strings.HasPrefix(contentType, "application/x-www-form-urlencoded") {
+			if values, err := url.ParseQuery(body); err == nil {
+				form = values
+			}
+		}
+	}
+	if method != "" {
+		req.Method = method
+	}
+	req.URI = u.String()
+
+	for k, v := range u.Query() {
+		for _, value := range v {
+			req.WithQuery(k, value)
+		}
+	}
+	for k, v := range form {
+		for _, value := range v {
+			req.WithForm(k, value)
+		}
+	}
+	return req, nil
+}
+
+// urlencodeCurlData encodes --data-urlencode argument the way curl does
+func urlencodeCurlData(value string) string {
+	if i := strings.Index(value, "="); i >= 0 {
+		if i == 0 {
+			return url.QueryEscape(value[1:])
+		}
+		return value[:i] + "=" + url.QueryEscape(value[i+1:])
+	}
+	return url.QueryEscape(value)
+}
+
+// splitCommand splits a shell command line into arguments honoring quotes,
+// backslash escapes and line continuations
+func splitCommand(command string) ([]string, error) {
+	var (
+		args    []string
+		current strings.Builder
+		inArg   bool
+		quote   rune
+		escaped bool
+	)
+	for _, r := range command {
+		switch {
+		case escaped:
+			if r != '\n' {
+				current.WriteRune(r)
+				inArg = true
+			}
+			escaped = false
+		case r == '\\' && quote != '\'':
+			escaped = true
+		case quote != 0:
+			if r == quote {
+				quote = 0
+			} else {
+				current.WriteRune(r)
+			}
+		case r == '\'' || r == '"':
+			quote = r
+			inArg = true
+		case r == ' ' || r == '\t' || r == '\n' || r == '\r':
+			if inArg {
+				args = append(args, current.String())
+				current.Reset()
+				inArg = false
+			}
+		default:
+			current.WriteRune(r)
+			inArg = true
+		}
+	}
+	if quote != 0 {
+		return nil, fmt.Errorf("unterminated quote in command")
+	}
+	if inArg {
+		args = append(args, current.String())
+	}
+	return args, nil
+}
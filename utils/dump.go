@@ -0,0 +1,153 @@
+package utils
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httputil"
+	"net/url"
+	"sync/atomic"
+
+	"github.com/cascades-fbp/cascades/runtime"
+)
+
+const defaultDumpBody = 1024
+
+// DumpOptions configure wire-level dumps emitted on DEBUG ports
+type DumpOptions struct {
+	Enabled bool `json:"enabled"`  // Emit dumps of requests and responses
+	MaxBody int  `json:"max_body"` // Number of body bytes included in a dump (default 1024)
+}
+
+// Dump is a wire-level dump of a request or response
+type Dump struct {
+	Component string `json:"component"`            // Component emitting the dump, i.e. http/client
+	RequestID string `json:"request-id,omitempty"` // ID of the request if known
+	Direction string `json:"direction"`            // request or response
+	Data      string `json:"data"`                 // Head as on the wire followed by (truncated) body
+	Truncated bool   `json:"truncated"`            // Whether the body was cut to max_body bytes
+}
+
+// Dumper creates dumps when enabled. It can be switched at runtime and
+// is safe for concurrent use.
+type Dumper struct {
+	component string
+	enabled   int32
+	maxBody   int64
+}
+
+// NewDumper creates a disabled dumper for a given component
+func NewDumper(component string) *Dumper {
+	return &Dumper{component: component, maxBody: defaultDumpBody}
+}
+
+// Apply switches the dumper according to the options
+func (d *Dumper) Apply(o *DumpOptions) {
+	maxBody := int64(o.MaxBody)
+	if maxBody <= 0 {
+		maxBody = defaultDumpBody
+	}
+	atomic.StoreInt64(&d.maxBody, maxBody)
+	var enabled int32
+	if o.Enabled {
+		enabled = 1
+	}
+	atomic.StoreInt32(&d.enabled, enabled)
+}
+
+// Enabled reports whether dumps should be created
+func (d *Dumper) Enabled() bool {
+	return atomic.LoadInt32(&d.enabled) == 1
+}
+
+// Request dumps a received request with a given body
+func (d *Dumper) Request(req *http.Request, id string, body []byte) ([][]byte, error) {
+	head, err := httputil.DumpRequest(redactRequest(req), false)
+	if err != nil {
+		return nil, err
+	}
+	return d.ip("request", id, head, redactBody(req.Header, body))
+}
+
+// RequestOut dumps an outgoing request with a given body including headers
+// added by the transport
+func (d *Dumper) RequestOut(req *http.Request, id string, body []byte) ([][]byte, error) {
+	head, err := httputil.DumpRequestOut(redactRequest(req), false)
+	if err != nil {
+		return nil, err
+	}
+	return d.ip("request", id, head, redactBody(req.Header, body))
+}
+
+// Response dumps a response with a given body
+func (d *Dumper) Response(resp *http.Response, id string, body []byte) ([][]byte, error) {
+	r := *resp
+	r.Header = redactHeader(resp.Header)
+	head, err := httputil.DumpResponse(&r, false)
+	if err != nil {
+		return nil, err
+	}
+	return d.ip("response", id, head, body)
+}
+
+// HTTPResponse dumps a response structure as it will be written to the wire
+func (d *Dumper) HTTPResponse(resp *HTTPResponse) ([][]byte, error) {
+	status := resp.StatusCode
+	if status == 0 {
+		status = http.StatusOK
+	}
+	r := &http.Response{
+		Status:        http.StatusText(status),
+		StatusCode:    status,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        http.Header(resp.Header),
+		ContentLength: int64(len(resp.Body)),
+	}
+	return d.Response(r, resp.ID, resp.Body)
+}
+
+func (d *Dumper) ip(direction, id string, head, body []byte) ([][]byte, error) {
+	dump := &Dump{
+		Component: d.component,
+		RequestID: id,
+		Direction: direction,
+	}
+	if max := atomic.LoadInt64(&d.maxBody); int64(len(body)) > max {
+		body = body[:max]
+		dump.Truncated = true
+	}
+	dump.Data = string(head) + string(body)
+	payload, err := json.Marshal(dump)
+	if err != nil {
+		return nil, err
+	}
+	return runtime.NewPacket(payload), nil
+}
+
+// redactRequest returns a shallow copy of request without credentials
+func redactRequest(req *http.Request) *http.Request {
+	r := *req
+	r.Header = redactHeader(req.Header)
+	if req.URL != nil {
+		if u, err := url.Parse(Redaction.URL(req.URL.String())); err == nil {
+			r.URL = u
+		}
+	}
+	if req.RequestURI != "" {
+		r.RequestURI = Redaction.URL(req.RequestURI)
+	}
+	return &r
+}
+
+func redactHeader(h http.Header) http.Header {
+	return http.Header(Redaction.Header(h))
+}
+
+// redactBody redacts sensitive parameters of URL encoded form bodies
+func redactBody(h http.Header, body []byte) []byte {
+	if !isFormBody(h.Get("Content-Type")) {
+		return body
+	}
+	return []byte(Redaction.Query(string(body)))
+}
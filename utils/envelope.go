@@ -0,0 +1,73 @@
+package utils
+
+import (
+	"fmt"
+)
+
+// envelopeMarker starts versioned IP payloads. Like the codec markers it can't start
+// JSON document, so payloads without envelope are still decoded as version 1.
+const envelopeMarker byte = 0xca
+
+// Versions of IP payload format
+const (
+	// FormatV1 is the original payload format: JSON document or codec-marked
+	// (MessagePack, Protocol Buffers) payload, with optional body frame
+	FormatV1 byte = 1
+	// CurrentFormat is the version written by Envelope
+	CurrentFormat = FormatV1
+)
+
+// FormatDecoder decodes payload of a specific format version into a given value
+type FormatDecoder func(payload []byte, v interface{}) error
+
+// formats keeps decoders of known payload format versions
+var formats = map[byte]FormatDecoder{
+	FormatV1: unmarshalCodec,
+}
+
+// RegisterFormat adds decoder for a payload format version
+func RegisterFormat(version byte, decoder FormatDecoder) {
+	formats[version] = decoder
+}
+
+// Envelope marks payload of a given IP with the current format version
+func Envelope(ip [][]byte) [][]byte {
+	if len(ip) < 2 {
+		return ip
+	}
+	payload := make([]byte, 0, len(ip[1])+2)
+	payload = append(payload, envelopeMarker, CurrentFormat)
+	payload = append(payload, ip[1]...)
+
+	out := make([][]byte, len(ip))
+	copy(out, ip)
+	out[1] = payload
+	return out
+}
+
+// IPVersion returns format version of a given IP payload
+func IPVersion(ip [][]byte) byte {
+	if len(ip) < 2 {
+		return 0
+	}
+	version, _ := payloadVersion(ip[1])
+	return version
+}
+
+// payloadVersion splits payload into format version and versioned content
+func payloadVersion(payload []byte) (byte, []byte) {
+	if len(payload) >= 2 && payload[0] == envelopeMarker {
+		return payload[1], payload[2:]
+	}
+	return FormatV1, payload
+}
+
+// unmarshalPayload decodes IP payload according to its format version
+func unmarshalPayload(payload []byte, v interface{}) error {
+	version, content := payloadVersion(payload)
+	decoder, ok := formats[version]
+	if !ok {
+		return fmt.Errorf("unsupported IP format version %d", version)
+	}
+	return decoder(content, v)
+}
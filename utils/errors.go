@@ -0,0 +1,107 @@
+package utils
+
+import (
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/url"
+
+	"github.com/cascades-fbp/cascades/runtime"
+)
+
+// Error categories
+const (
+	ErrInvalidIP      = "invalid-ip"      // IP couldn't be decoded
+	ErrInvalidRequest = "invalid-request" // Request is malformed or can't be performed
+	ErrNetwork        = "network"         // Connection failed or was interrupted
+	ErrTimeout        = "timeout"         // Operation didn't finish in time
+	ErrUpstream       = "upstream"        // Upstream replied with an error
+	ErrInternal       = "internal"        // Failure inside of the component
+	ErrOverflow       = "overflow"        // IP was dropped because an output queue was full
+	ErrIntegrity      = "integrity"       // Payload doesn't match its expected checksum
+)
+
+// Error is a common structure sent to ERR ports of HTTP components
+type Error struct {
+	Component string `json:"component"`            // Component reporting the error, i.e. http/client
+	Category  string `json:"category"`             // One of Err* categories
+	Message   string `json:"message"`              // Human readable description
+	RequestID string `json:"request-id,omitempty"` // ID of the affected request if known
+	Retryable bool   `json:"retryable"`            // Whether repeating the operation may succeed
+
+	Details map[string]string `json:"details,omitempty"` // Category specific context, i.e. expected and actual checksums
+}
+
+func (e *Error) Error() string {
+	if e.RequestID != "" {
+		return fmt.Sprintf("%s: %s error (request %s): %s", e.Component, e.Category, e.RequestID, e.Message)
+	}
+	return fmt.Sprintf("%s: %s error: %s", e.Component, e.Category, e.Message)
+}
+
+// NewError creates error structure of a given category. Network and timeout
+// errors are retryable. Secrets are redacted from the message.
+func NewError(component, category string, err error) *Error {
+	return &Error{
+		Component: component,
+		Category:  category,
+		Message:   Redaction.Text(err.Error()),
+		Retryable: category == ErrNetwork || category == ErrTimeout,
+	}
+}
+
+// WithRequest sets ID of the affected request
+func (e *Error) WithRequest(id string) *Error {
+	e.RequestID = id
+	return e
+}
+
+// WithDetail adds a key-value pair to details of the error
+func (e *Error) WithDetail(key, value string) *Error {
+	if e.Details == nil {
+		e.Details = make(map[string]string)
+	}
+	e.Details[key] = value
+	return e
+}
+
+// ClassifyError returns category of an error returned by net/http client
+func ClassifyError(err error) string {
+	if uerr, ok := err.(*url.Error); ok {
+		err = uerr.Err
+	}
+	if nerr, ok := err.(net.Error); ok && nerr.Timeout() {
+		return ErrTimeout
+	}
+	if _, ok := err.(net.Error); ok {
+		return ErrNetwork
+	}
+	if _, ok := err.(*net.OpError); ok {
+		return ErrNetwork
+	}
+	return ErrInvalidRequest
+}
+
+// Error2IP converts a given error to IP
+func Error2IP(e *Error) ([][]byte, error) {
+	payload, err := json.Marshal(e)
+	if err != nil {
+		return nil, err
+	}
+	return runtime.NewPacket(payload), nil
+}
+
+// IP2Error converts a given IP to error structure
+func IP2Error(ip [][]byte) (*Error, error) {
+	if len(ip) < 2 {
+		return nil, fmt.Errorf("invalid IP with %d frames", len(ip))
+	}
+	var e *Error
+	if err := json.Unmarshal(ip[1], &e); err != nil {
+		return nil, err
+	}
+	if e == nil {
+		return nil, fmt.Errorf("empty error payload")
+	}
+	return e, nil
+}
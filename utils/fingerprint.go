@@ -0,0 +1,332 @@
+package utils
+
+import (
+	"crypto/md5"
+	"crypto/sha256"
+	"crypto/tls"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// TLS extensions read for fingerprinting
+const (
+	extensionServerName        = 0x0000
+	extensionSupportedGroups   = 0x000a
+	extensionPointFormats      = 0x000b
+	extensionSignatureSchemes  = 0x000d
+	extensionALPN              = 0x0010
+	extensionSupportedVersions = 0x002b
+)
+
+const (
+	recordTypeHandshake  = 0x16
+	handshakeClientHello = 0x01
+	recordHeaderSize     = 5
+)
+
+// ErrIncompleteHello is returned by ParseClientHello if more data is needed
+var ErrIncompleteHello = errors.New("incomplete ClientHello")
+
+// ClientHello holds fields of a TLS ClientHello message used for fingerprinting
+type ClientHello struct {
+	Version           uint16   // Legacy version of the message
+	CipherSuites      []uint16 // In order sent by the client
+	Extensions        []uint16 // Types in order sent by the client
+	ServerName        string
+	SupportedGroups   []uint16
+	PointFormats      []uint8
+	SignatureSchemes  []uint16
+	ALPN              []string
+	SupportedVersions []uint16
+}
+
+// TLSFingerprint identifies a TLS client implementation by its ClientHello
+type TLSFingerprint struct {
+	JA3    string // MD5 of JA3 string
+	JA3Raw string // SSLVersion,Ciphers,Extensions,EllipticCurves,EllipticCurvePointFormats
+	JA4    string // JA4 fingerprint, i.e. t13d1516h2_8daaf6152771_e5627efa2ab1
+}
+
+// ParseClientHello parses a ClientHello from the first bytes a client sent
+// over a TLS connection. The message may span several records,
+// ErrIncompleteHello is returned until all of them are available.
+func ParseClientHello(data []byte) (*ClientHello, error) {
+	// Reassemble the handshake message from record fragments
+	var msg []byte
+	for {
+		if len(data) < recordHeaderSize {
+			return nil, ErrIncompleteHello
+		}
+		if data[0] != recordTypeHandshake {
+			return nil, fmt.Errorf("unexpected TLS record type %d", data[0])
+		}
+		size := int(data[3])<<8 | int(data[4])
+		if len(data) < recordHeaderSize+size {
+			return nil, ErrIncompleteHello
+		}
+		msg = append(msg, data[recordHeaderSize:recordHeaderSize+size]...)
+		data = data[recordHeaderSize+size:]
+		if len(msg) >= 4 && len(msg) >= 4+(int(msg[1])<<16|int(msg[2])<<8|int(msg[3])) {
+			break
+		}
+	}
+	if msg[0] != handshakeClientHello {
+		return nil, fmt.Errorf("unexpected handshake message type %d", msg[0])
+	}
+
+	r := &helloReader{b: msg[4 : 4+(int(msg[1])<<16|int(msg[2])<<8|int(msg[3]))]}
+	ch := &ClientHello{Version: r.u16()}
+	r.skip(32) // Random
+	r.vector(1)
+	suites := r.vector(2)
+	for !suites.empty() {
+		ch.CipherSuites = append(ch.CipherSuites, suites.u16())
+	}
+	r.vector(1) // Compression methods
+	if r.empty() {
+		return ch, r.error()
+	}
+	extensions := r.vector(2)
+	for !extensions.empty() {
+		typ := extensions.u16()
+		ext := extensions.vector(2)
+		ch.Extensions = append(ch.Extensions, typ)
+		switch typ {
+		case extensionServerName:
+			names := ext.vector(2)
+			for !names.empty() {
+				nameType := names.u8()
+				name := names.vector(2)
+				if nameType == 0 {
+					ch.ServerName = string(name.b)
+				}
+			}
+		case extensionSupportedGroups:
+			groups := ext.vector(2)
+			for !groups.empty() {
+				ch.SupportedGroups = append(ch.SupportedGroups, groups.u16())
+			}
+		case extensionPointFormats:
+			ch.PointFormats = append(ch.PointFormats, ext.vector(1).b...)
+		case extensionSignatureSchemes:
+			schemes := ext.vector(2)
+			for !schemes.empty() {
+				ch.SignatureSchemes = append(ch.SignatureSchemes, schemes.u16())
+			}
+		case extensionALPN:
+			protos := ext.vector(2)
+			for !protos.empty() {
+				ch.ALPN = append(ch.ALPN, string(protos.vector(1).b))
+			}
+		case extensionSupportedVersions:
+			versions := ext.vector(1)
+			for !versions.empty() {
+				ch.SupportedVersions = append(ch.SupportedVersions, versions.u16())
+			}
+		}
+		if ext.failed || extensions.failed {
+			return nil, fmt.Errorf("malformed extension %d of ClientHello", typ)
+		}
+	}
+	return ch, r.error()
+}
+
+// Fingerprint computes JA3 and JA4 fingerprints of the message received over
+// TCP. GREASE values are ignored.
+func (ch *ClientHello) Fingerprint() TLSFingerprint {
+	ciphers := withoutGrease(ch.CipherSuites)
+	extensions := withoutGrease(ch.Extensions)
+	groups := withoutGrease(ch.SupportedGroups)
+	var points []uint16
+	for _, p := range ch.PointFormats {
+		points = append(points, uint16(p))
+	}
+
+	var fp TLSFingerprint
+	fp.JA3Raw = strings.Join([]string{
+		strconv.Itoa(int(ch.Version)),
+		joinDecimal(ciphers),
+		joinDecimal(extensions),
+		joinDecimal(groups),
+		joinDecimal(points),
+	}, ",")
+	sum := md5.Sum([]byte(fp.JA3Raw))
+	fp.JA3 = hex.EncodeToString(sum[:])
+
+	highest := ch.Version
+	for _, v := range withoutGrease(ch.SupportedVersions) {
+		if v > highest {
+			highest = v
+		}
+	}
+	sni := "i"
+	if ch.ServerName != "" {
+		sni = "d"
+	}
+	alpn := "00"
+	if len(ch.ALPN) > 0 && ch.ALPN[0] != "" {
+		alpn = ja4ALPN(ch.ALPN[0])
+	}
+	a := fmt.Sprintf("t%s%s%s%s%s", ja4Version(highest), sni, ja4Count(len(ciphers)), ja4Count(len(extensions)), alpn)
+
+	var hashed []uint16
+	for _, e := range extensions {
+		if e != extensionServerName && e != extensionALPN {
+			hashed = append(hashed, e)
+		}
+	}
+	c := joinHex(sorted(hashed))
+	if schemes := withoutGrease(ch.SignatureSchemes); len(schemes) > 0 {
+		c += "_" + joinHex(schemes)
+	}
+	fp.JA4 = a + "_" + ja4Hash(joinHex(sorted(ciphers)), len(ciphers) == 0) + "_" + ja4Hash(c, len(hashed) == 0)
+	return fp
+}
+
+// helloReader reads big endian fields of a handshake message, any read
+// beyond its end marks it and the readers of enclosing vectors as failed
+type helloReader struct {
+	b      []byte
+	failed bool
+	parent *helloReader
+}
+
+func (r *helloReader) empty() bool {
+	return len(r.b) == 0 || r.failed
+}
+
+func (r *helloReader) error() error {
+	if r.failed {
+		return errors.New("malformed ClientHello")
+	}
+	return nil
+}
+
+func (r *helloReader) take(n int) []byte {
+	if r.failed || len(r.b) < n {
+		for ; r != nil; r = r.parent {
+			r.failed = true
+		}
+		return nil
+	}
+	b := r.b[:n]
+	r.b = r.b[n:]
+	return b
+}
+
+func (r *helloReader) skip(n int) {
+	r.take(n)
+}
+
+func (r *helloReader) u8() uint8 {
+	if b := r.take(1); b != nil {
+		return b[0]
+	}
+	return 0
+}
+
+func (r *helloReader) u16() uint16 {
+	if b := r.take(2); b != nil {
+		return uint16(b[0])<<8 | uint16(b[1])
+	}
+	return 0
+}
+
+// vector reads a length prefixed field with a given size of the length
+func (r *helloReader) vector(lengthSize int) *helloReader {
+	var n int
+	for _, b := range r.take(lengthSize) {
+		n = n<<8 | int(b)
+	}
+	b := r.take(n)
+	return &helloReader{b: b, failed: r.failed, parent: r}
+}
+
+// isGrease checks for values reserved by RFC 8701, i.e. 0x0a0a or 0xfafa
+func isGrease(v uint16) bool {
+	return v&0x0f0f == 0x0a0a && v>>8 == v&0xff
+}
+
+func withoutGrease(values []uint16) []uint16 {
+	var result []uint16
+	for _, v := range values {
+		if !isGrease(v) {
+			result = append(result, v)
+		}
+	}
+	return result
+}
+
+func sorted(values []uint16) []uint16 {
+	result := append([]uint16(nil), values...)
+	sort.Slice(result, func(i, j int) bool { return result[i] < result[j] })
+	return result
+}
+
+func joinDecimal(values []uint16) string {
+	parts := make([]string, len(values))
+	for i, v := range values {
+		parts[i] = strconv.Itoa(int(v))
+	}
+	return strings.Join(parts, "-")
+}
+
+func joinHex(values []uint16) string {
+	parts := make([]string, len(values))
+	for i, v := range values {
+		parts[i] = fmt.Sprintf("%04x", v)
+	}
+	return strings.Join(parts, ",")
+}
+
+// ja4Hash returns the first 12 hex characters of SHA256 or zeros if empty
+func ja4Hash(s string, empty bool) string {
+	if empty {
+		return "000000000000"
+	}
+	sum := sha256.Sum256([]byte(s))
+	return hex.EncodeToString(sum[:])[:12]
+}
+
+// ja4Count formats a count as two digits capped at 99
+func ja4Count(n int) string {
+	if n > 99 {
+		n = 99
+	}
+	return fmt.Sprintf("%02d", n)
+}
+
+func ja4Version(v uint16) string {
+	switch v {
+	case tls.VersionTLS13:
+		return "13"
+	case tls.VersionTLS12:
+		return "12"
+	case tls.VersionTLS11:
+		return "11"
+	case tls.VersionTLS10:
+		return "10"
+	case 0x0300:
+		return "s3"
+	}
+	return "00"
+}
+
+// ja4ALPN returns first and last characters of a protocol, or first and last
+// hex digits if they aren't alphanumeric
+func ja4ALPN(proto string) string {
+	first, last := proto[0], proto[len(proto)-1]
+	if isAlphanumeric(first) && isAlphanumeric(last) {
+		return string([]byte{first, last})
+	}
+	h := hex.EncodeToString([]byte(proto))
+	return string([]byte{h[0], h[len(h)-1]})
+}
+
+func isAlphanumeric(c byte) bool {
+	return c >= '0' && c <= '9' || c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z'
+}
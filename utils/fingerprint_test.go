@@ -0,0 +1,206 @@
+package utils
+
+import (
+	"crypto/tls"
+	"encoding/binary"
+	"net"
+	"strings"
+	"testing"
+	"time"
+)
+
+// helloExtension is a type and data of a ClientHello extension
+type helloExtension struct {
+	typ  uint16
+	data []byte
+}
+
+// u16s encodes big endian values prefixed by their length of a given size
+func u16s(lengthSize int, values ...uint16) []byte {
+	b := make([]byte, 2*len(values))
+	for i, v := range values {
+		binary.BigEndian.PutUint16(b[2*i:], v)
+	}
+	return vector(lengthSize, b)
+}
+
+// vector prefixes data with its length of a given size
+func vector(lengthSize int, data []byte) []byte {
+	b := make([]byte, lengthSize, lengthSize+len(data))
+	for i, n := lengthSize-1, len(data); i >= 0; i, n = i-1, n>>8 {
+		b[i] = byte(n)
+	}
+	return append(b, data...)
+}
+
+// clientHello encodes a ClientHello in TLS records carrying at most
+// fragment bytes of the message each
+func clientHello(ciphers []uint16, extensions []helloExtension, fragment int) []byte {
+	body := []byte{0x03, 0x03}
+	body = append(body, make([]byte, 32)...) // Random
+	body = append(body, vector(1, make([]byte, 32))...)
+	body = append(body, u16s(2, ciphers...)...)
+	body = append(body, 0x01, 0x00) // Null compression
+	var exts []byte
+	for _, e := range extensions {
+		exts = append(exts, byte(e.typ>>8), byte(e.typ))
+		exts = append(exts, vector(2, e.data)...)
+	}
+	body = append(body, vector(2, exts)...)
+	msg := append([]byte{handshakeClientHello}, vector(3, body)...)
+
+	var records []byte
+	for len(msg) > 0 {
+		n := len(msg)
+		if n > fragment {
+			n = fragment
+		}
+		records = append(records, recordTypeHandshake, 0x03, 0x01)
+		records = append(records, vector(2, msg[:n])...)
+		msg = msg[n:]
+	}
+	return records
+}
+
+// chromeHello is a ClientHello of Chrome with GREASE values, the example of
+// JA4 specification
+func chromeHello(fragment int) []byte {
+	ciphers := []uint16{0x0a0a, 0x1301, 0x1302, 0x1303, 0xc02b, 0xc02f, 0xc02c, 0xc030, 0xcca9, 0xcca8, 0xc013, 0xc014, 0x009c, 0x009d, 0x002f, 0x0035}
+	extensions := []helloExtension{
+		{0x0a0a, nil},
+		{extensionServerName, vector(2, append([]byte{0}, vector(2, []byte("example.com"))...))},
+		{0x0017, nil},
+		{0xff01, []byte{0}},
+		{extensionSupportedGroups, u16s(2, 0x0a0a, 0x001d, 0x0017, 0x0018)},
+		{extensionPointFormats, vector(1, []byte{0})},
+		{0x0023, nil},
+		{extensionALPN, vector(2, append(vector(1, []byte("h2")), vector(1, []byte("http/1.1"))...))},
+		{0x0005, []byte{1, 0, 0, 0, 0}},
+		{extensionSignatureSchemes, u16s(2, 0x0403, 0x0804, 0x0401, 0x0503, 0x0805, 0x0501, 0x0806, 0x0601)},
+		{0x0012, nil},
+		{0x0033, nil},
+		{0x002d, vector(1, []byte{1})},
+		{extensionSupportedVersions, u16s(1, 0x1a1a, 0x0304, 0x0303)},
+		{0x001b, u16s(1, 0x0002)},
+		{0x4469, nil},
+		{0x1a1a, []byte{0}},
+		{0x0015, make([]byte, 200)},
+	}
+	return clientHello(ciphers, extensions, fragment)
+}
+
+// captureClientHello returns the first bytes sent by crypto/tls client
+func captureClientHello(t *testing.T, config *tls.Config) []byte {
+	t.Helper()
+	client, server := net.Pipe()
+	defer server.Close()
+	go func() {
+		tls.Client(client, config).Handshake()
+		client.Close()
+	}()
+	server.SetReadDeadline(time.Now().Add(5 * time.Second))
+	var data []byte
+	buf := make([]byte, 4096)
+	for {
+		n, err := server.Read(buf)
+		data = append(data, buf[:n]...)
+		if _, perr := ParseClientHello(data); perr != ErrIncompleteHello {
+			return data
+		}
+		if err != nil {
+			t.Fatalf("Failed to read ClientHello: %v", err)
+		}
+	}
+}
+
+func TestFingerprint(t *testing.T) {
+	tests := []struct {
+		name     string
+		fragment int
+	}{
+		{"single record", 1 << 14},
+		{"fragmented", 100},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ch, err := ParseClientHello(chromeHello(tt.fragment))
+			if err != nil {
+				t.Fatalf("ParseClientHello() error = %v", err)
+			}
+			if ch.ServerName != "example.com" || strings.Join(ch.ALPN, ",") != "h2,http/1.1" {
+				t.Errorf("ParseClientHello() server name = %q, ALPN = %v", ch.ServerName, ch.ALPN)
+			}
+			want := TLSFingerprint{
+				JA3:    "cd08e31494f9531f560d64c695473da9",
+				JA3Raw: "771,4865-4866-4867-49195-49199-49196-49200-52393-52392-49171-49172-156-157-47-53,0-23-65281-10-11-35-16-5-13-18-51-45-43-27-17513-21,29-23-24,0",
+				JA4:    "t13d1516h2_8daaf6152771_e5627efa2ab1",
+			}
+			if got := ch.Fingerprint(); got != want {
+				t.Errorf("Fingerprint() = %+v, want %+v", got, want)
+			}
+		})
+	}
+}
+
+func TestFingerprintCaptured(t *testing.T) {
+	tests := []struct {
+		name   string
+		config *tls.Config
+		ja4    string
+	}{
+		{"TLS 1.3 with ALPN", &tls.Config{ServerName: "example.com", NextProtos: []string{"h2", "http/1.1"}}, "t13d"},
+		{"TLS 1.2 without SNI", &tls.Config{InsecureSkipVerify: true, MaxVersion: tls.VersionTLS12}, "t12i"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ch, err := ParseClientHello(captureClientHello(t, tt.config))
+			if err != nil {
+				t.Fatalf("ParseClientHello() error = %v", err)
+			}
+			if ch.ServerName != tt.config.ServerName || strings.Join(ch.ALPN, ",") != strings.Join(tt.config.NextProtos, ",") {
+				t.Errorf("ParseClientHello() server name = %q, ALPN = %v", ch.ServerName, ch.ALPN)
+			}
+			fp := ch.Fingerprint()
+			if !strings.HasPrefix(fp.JA4, tt.ja4) || len(fp.JA4) != len("t13d1516h2_8daaf6152771_e5627efa2ab1") {
+				t.Errorf("Fingerprint() JA4 = %s, want prefix %s", fp.JA4, tt.ja4)
+			}
+			if !strings.HasPrefix(fp.JA3Raw, "771,") || len(fp.JA3) != 32 {
+				t.Errorf("Fingerprint() JA3 = %s of %s", fp.JA3, fp.JA3Raw)
+			}
+		})
+	}
+}
+
+func TestParseClientHelloInvalid(t *testing.T) {
+	hello := chromeHello(1 << 14)
+	if _, err := ParseClientHello(hello[:len(hello)-1]); err != ErrIncompleteHello {
+		t.Errorf("ParseClientHello() of truncated record error = %v, want ErrIncompleteHello", err)
+	}
+	fragmented := chromeHello(100)
+	if _, err := ParseClientHello(fragmented[:105]); err != ErrIncompleteHello {
+		t.Errorf("ParseClientHello() of first fragment error = %v, want ErrIncompleteHello", err)
+	}
+
+	// Message ends after the random, record and handshake lengths match it
+	short := clientHello(nil, nil, 1<<14)[:9+2+32]
+	binary.BigEndian.PutUint16(short[3:], uint16(len(short)-5))
+	short[6], short[7], short[8] = 0, 0, byte(len(short)-9)
+
+	tests := []struct {
+		name string
+		data []byte
+	}{
+		{"not handshake", []byte{0x17, 0x03, 0x03, 0x00, 0x01, 0x00}},
+		{"not ClientHello", []byte{0x16, 0x03, 0x01, 0x00, 0x04, 0x02, 0x00, 0x00, 0x00}},
+		{"short message", short},
+		{"bad extension length", clientHello([]uint16{0x1301}, []helloExtension{{extensionServerName, []byte{0x00, 0x10, 0x00}}}, 1<<14)},
+		{"bad ALPN length", clientHello([]uint16{0x1301}, []helloExtension{{extensionALPN, []byte{0x00, 0x03, 0x05, 'h', '2'}}}, 1<<14)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if ch, err := ParseClientHello(tt.data); err == nil || err == ErrIncompleteHello {
+				t.Errorf("ParseClientHello() = %+v, error = %v, want malformed error", ch, err)
+			}
+		})
+	}
+}
@@ -0,0 +1,96 @@
+package utils
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"net/url"
+	"sort"
+	"strconv"
+)
+
+// FormStyle defines how nested JSON keys are flattened into form fields
+type FormStyle int
+
+const (
+	// BracketStyle flattens to a[b][c]=v, scalar arrays to a[]=v and other arrays to a[0][b]=v
+	BracketStyle FormStyle = iota
+	// DotStyle flattens to a.b.c=v, scalar arrays to repeated a=v and other arrays to a.0.b=v
+	DotStyle
+)
+
+// FlattenJSON converts a JSON object into form values using a given style. Numbers keep
+// their original notation.
+func FlattenJSON(data []byte, style FormStyle) (url.Values, error) {
+	var raw map[string]interface{}
+	d := json.NewDecoder(bytes.NewReader(data))
+	d.UseNumber()
+	if err := d.Decode(&raw); err != nil {
+		return nil, err
+	}
+	if _, err := d.Token(); err != io.EOF {
+		return nil, fmt.Errorf("invalid character after top-level value")
+	}
+	values := make(url.Values)
+	for k, v := range raw {
+		flatten(values, k, v, style)
+	}
+	return values, nil
+}
+
+// EncodeMultipart renders form values as a multipart/form-data body (fields are sorted by name)
+// and returns it with the corresponding Content-Type
+func EncodeMultipart(values url.Values) ([]byte, string, error) {
+	keys := make([]string, 0, len(values))
+	for k := range values {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	var buf bytes.Buffer
+	w := multipart.NewWriter(&buf)
+	for _, k := range keys {
+		for _, v := range values[k] {
+			if err := w.WriteField(k, v); err != nil {
+				return nil, "", err
+			}
+		}
+	}
+	if err := w.Close(); err != nil {
+		return nil, "", err
+	}
+	return buf.Bytes(), w.FormDataContentType(), nil
+}
+
+func flatten(values url.Values, key string, v interface{}, style FormStyle) {
+	switch t := v.(type) {
+	case map[string]interface{}:
+		for k, item := range t {
+			flatten(values, nestedKey(key, k, style), item, style)
+		}
+	case []interface{}:
+		for i, item := range t {
+			switch item.(type) {
+			case map[string]interface{}, []interface{}:
+				flatten(values, nestedKey(key, strconv.Itoa(i), style), item, style)
+			default:
+				if style == BracketStyle {
+					values.Add(key+"[]", formatValue(item))
+				} else {
+					values.Add(key, formatValue(item))
+				}
+			}
+		}
+	default:
+		values.Add(key, formatValue(t))
+	}
+}
+
+func nestedKey(parent, key string, style FormStyle) string {
+	if style == DotStyle {
+		return parent + "." + key
+	}
+	return parent + "[" + key + "]"
+}
@@ -0,0 +1,166 @@
+package utils_test
+
+import (
+	"crypto/tls"
+	"net"
+	"testing"
+
+	"github.com/cascades-fbp/cascades-http/testutils"
+	httputils "github.com/cascades-fbp/cascades-http/utils"
+)
+
+// Decoded structures are encoded again with every codec and decoded back, so
+// codec asymmetries surface as well as panics on malformed input. Run one
+// target at a time, i.e. go test -fuzz FuzzIP2Request ./utils
+
+var (
+	requestEncoders  = []func(*httputils.HTTPRequest) ([][]byte, error){httputils.Request2IP, httputils.Request2FramedIP, httputils.Request2IPMsgpack, httputils.Request2IPProtobuf}
+	responseEncoders = []func(*httputils.HTTPResponse) ([][]byte, error){httputils.Response2IP, httputils.Response2FramedIP, httputils.Response2IPMsgpack, httputils.Response2IPProtobuf}
+)
+
+// seedRequests adds payloads of fixture requests in every codec to the corpus
+func seedRequests(f *testing.F) {
+	for _, req := range []*httputils.HTTPRequest{
+		testutils.GetRequest("/posts/42/comments?limit=10"),
+		testutils.FormRequest("/login", map[string]string{"user": "alice", "pass": "secret"}),
+		testutils.JSONRequest("POST", "/api/v1/users", map[string]interface{}{"name": "alice", "id": 12345678901234567}),
+	} {
+		for _, encode := range requestEncoders {
+			ip, err := encode(req.Clone())
+			if err != nil {
+				f.Fatalf("Failed to encode seed request: %v", err)
+			}
+			f.Add(ip[1])
+			f.Add(httputils.Envelope(ip)[1])
+		}
+	}
+}
+
+// seedResponses adds payloads of fixture responses in every codec to the corpus
+func seedResponses(f *testing.F) {
+	for _, res := range []*httputils.HTTPResponse{
+		testutils.TextResponse(200, "hello"),
+		testutils.JSONResponse(201, map[string]interface{}{"id": 1, "tags": []string{"a", "b"}}),
+		testutils.NotFound(),
+	} {
+		for _, encode := range responseEncoders {
+			ip, err := encode(res.Clone())
+			if err != nil {
+				f.Fatalf("Failed to encode seed response: %v", err)
+			}
+			f.Add(ip[1])
+		}
+	}
+}
+
+// FuzzIP2Request feeds arbitrary payloads (plain and framed) to IP2Request
+func FuzzIP2Request(f *testing.F) {
+	seedRequests(f)
+	f.Fuzz(func(t *testing.T, data []byte) {
+		req, err := httputils.IP2Request([][]byte{[]byte("1"), data})
+		if err != nil {
+			return
+		}
+		httputils.IP2Request([][]byte{[]byte("1"), data, data})
+		req.Validate()
+		req.Clone()
+		for _, encode := range requestEncoders {
+			ip, err := encode(req.Clone())
+			if err != nil {
+				continue
+			}
+			if _, err := httputils.IP2Request(ip); err != nil {
+				t.Fatalf("Failed to decode re-encoded request: %v", err)
+			}
+			if _, err := httputils.IP2Request(httputils.Envelope(ip)); err != nil {
+				t.Fatalf("Failed to decode enveloped request: %v", err)
+			}
+		}
+	})
+}
+
+// FuzzIP2Response feeds arbitrary payloads (plain and framed) to IP2Response
+func FuzzIP2Response(f *testing.F) {
+	seedResponses(f)
+	f.Fuzz(func(t *testing.T, data []byte) {
+		res, err := httputils.IP2Response([][]byte{[]byte("1"), data})
+		if err != nil {
+			return
+		}
+		httputils.IP2Response([][]byte{[]byte("1"), data, data})
+		res.Validate()
+		res.Clone()
+		res.CookieHeaders()
+		for _, encode := range responseEncoders {
+			ip, err := encode(res.Clone())
+			if err != nil {
+				continue
+			}
+			if _, err := httputils.IP2Response(ip); err != nil {
+				t.Fatalf("Failed to decode re-encoded response: %v", err)
+			}
+		}
+	})
+}
+
+// FuzzParseCurl checks curl command parser on arbitrary command lines
+func FuzzParseCurl(f *testing.F) {
+	f.Add(`curl -X POST -H 'Content-Type: application/json' -d '{"a":1}' https://example.com/api`)
+	f.Add(`curl --compressed -u user:pass "https://example.com/?q=a b"`)
+	f.Add(`curl -F file=@x.txt -b 'session=1' example.com`)
+	f.Fuzz(func(t *testing.T, command string) {
+		httputils.ParseCurl(command)
+	})
+}
+
+// FuzzHeaders checks parsers of structured header values
+func FuzzHeaders(f *testing.F) {
+	f.Add("text/html;q=0.8, application/json")
+	f.Add("max-age=60, stale-while-revalidate=30, no-cache=\"Set-Cookie\"")
+	f.Add(`<https://example.com/?page=2>; rel="next", <https://example.com/?page=9>; rel="last"`)
+	f.Add(`Digest realm="api", qop="auth", nonce="abc", Bearer error="invalid_token"`)
+	f.Add("bytes=0-99,200-,-50")
+	f.Add("bytes 0-99/1024")
+	f.Fuzz(func(t *testing.T, s string) {
+		httputils.ParseAccept(s).Negotiate([]string{"application/json", "text/html", "en-US", "gzip"})
+		httputils.ParseCacheControl(s)
+		httputils.ParseLinks(s)
+		httputils.ParseChallenges(s)
+		if ranges, err := httputils.ParseRange(s, 1024); err == nil {
+			if _, _, err := httputils.EncodeByteranges(make([]byte, 1024), ranges, "text/plain"); err != nil {
+				t.Fatalf("Failed to encode ranges %v parsed from %q: %v", ranges, s, err)
+			}
+		}
+		httputils.ParseContentRange(s)
+	})
+}
+
+// FuzzParseClientHello feeds arbitrary first bytes of TLS connections to
+// ParseClientHello, seeded with ClientHello messages of crypto/tls
+func FuzzParseClientHello(f *testing.F) {
+	for _, config := range []*tls.Config{
+		{ServerName: "example.com", NextProtos: []string{"h2", "http/1.1"}},
+		{InsecureSkipVerify: true, MaxVersion: tls.VersionTLS12},
+	} {
+		client, server := net.Pipe()
+		go func() {
+			tls.Client(client, config).Handshake()
+			client.Close()
+		}()
+		buf := make([]byte, 16<<10)
+		n, err := server.Read(buf)
+		server.Close()
+		if err != nil {
+			f.Fatalf("Failed to read ClientHello: %v", err)
+		}
+		f.Add(buf[:n])
+	}
+	f.Add([]byte{0x16, 0x03, 0x01, 0x00, 0x04, 0x01, 0x00, 0x00, 0x00})
+	f.Fuzz(func(t *testing.T, data []byte) {
+		ch, err := httputils.ParseClientHello(data)
+		if err != nil {
+			return
+		}
+		ch.Fingerprint()
+	})
+}
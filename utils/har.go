@@ -0,0 +1,257 @@
+package utils
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"sort"
+	"time"
+	"unicode/utf8"
+)
+
+// HTTPExchange is a request paired with the response it received
+type HTTPExchange struct {
+	Request  *HTTPRequest
+	Response *HTTPResponse
+	Started  time.Time     // When the request was sent
+	Duration time.Duration // Time until the response arrived
+}
+
+// HAR is HAR 1.2 document (http://www.softwareishard.com/blog/har-12-spec/)
+type HAR struct {
+	Log HARLog `json:"log"`
+}
+
+// HARLog is the root of HAR data
+type HARLog struct {
+	Version string     `json:"version"`
+	Creator HARCreator `json:"creator"`
+	Entries []HAREntry `json:"entries"`
+}
+
+// HARCreator names the application which created the log
+type HARCreator struct {
+	Name    string `json:"name"`
+	Version string `json:"version"`
+}
+
+// HAREntry describes a single exchange
+type HAREntry struct {
+	StartedDateTime time.Time   `json:"startedDateTime"`
+	Time            float64     `json:"time"` // Milliseconds
+	Request         HARRequest  `json:"request"`
+	Response        HARResponse `json:"response"`
+	Cache           struct{}    `json:"cache"`
+	Timings         HARTimings  `json:"timings"`
+}
+
+// HARRequest describes a request of the entry
+type HARRequest struct {
+	Method      string         `json:"method"`
+	URL         string         `json:"url"`
+	HTTPVersion string         `json:"httpVersion"`
+	Cookies     []HARNameValue `json:"cookies"`
+	Headers     []HARNameValue `json:"headers"`
+	QueryString []HARNameValue `json:"queryString"`
+	PostData    *HARPostData   `json:"postData,omitempty"`
+	HeadersSize int            `json:"headersSize"`
+	BodySize    int            `json:"bodySize"`
+}
+
+// HARResponse describes a response of the entry
+type HARResponse struct {
+	Status      int            `json:"status"`
+	StatusText  string         `json:"statusText"`
+	HTTPVersion string         `json:"httpVersion"`
+	Cookies     []HARNameValue `json:"cookies"`
+	Headers     []HARNameValue `json:"headers"`
+	Content     HARContent     `json:"content"`
+	RedirectURL string         `json:"redirectURL"`
+	HeadersSize int            `json:"headersSize"`
+	BodySize    int            `json:"bodySize"`
+}
+
+// HARNameValue is a header, cookie or query parameter
+type HARNameValue struct {
+	Name  string `json:"name"`
+	Value string `json:"value"`
+}
+
+// HARPostData describes a request body
+type HARPostData struct {
+	MimeType string `json:"mimeType"`
+	Text     string `json:"text"`
+}
+
+// HARContent describes a response body
+type HARContent struct {
+	Size     int    `json:"size"`
+	MimeType string `json:"mimeType"`
+	Text     string `json:"text,omitempty"`
+	Encoding string `json:"encoding,omitempty"` // base64 for binary bodies
+}
+
+// HARTimings breaks down the time of the entry
+type HARTimings struct {
+	Send    float64 `json:"send"`
+	Wait    float64 `json:"wait"`
+	Receive float64 `json:"receive"`
+}
+
+// Exchanges2HAR exports a given list of exchanges as HAR 1.2 document
+func Exchanges2HAR(exchanges []HTTPExchange) ([]byte, error) {
+	har := &HAR{Log: HARLog{
+		Version: "1.2",
+		Creator: HARCreator{Name: "cascades-http", Version: "1.0"},
+		Entries: make([]HAREntry, 0, len(exchanges)),
+	}}
+	for _, e := range exchanges {
+		entry := HAREntry{
+			StartedDateTime: e.Started,
+			Time:            float64(e.Duration) / float64(time.Millisecond),
+			Timings:         HARTimings{Wait: float64(e.Duration) / float64(time.Millisecond)},
+		}
+		if e.Request != nil {
+			entry.Request = harRequest(Redaction.Request(e.Request))
+		}
+		if e.Response != nil {
+			entry.Response = harResponse(Redaction.Response(e.Response))
+		}
+		har.Log.Entries = append(har.Log.Entries, entry)
+	}
+	return json.MarshalIndent(har, "", "  ")
+}
+
+// HAR2Exchanges imports exchanges from a given HAR document
+func HAR2Exchanges(data []byte) ([]HTTPExchange, error) {
+	var har HAR
+	if err := json.Unmarshal(data, &har); err != nil {
+		return nil, err
+	}
+	exchanges := make([]HTTPExchange, 0, len(har.Log.Entries))
+	for _, entry := range har.Log.Entries {
+		req := &HTTPRequest{
+			Method: entry.Request.Method,
+			URI:    entry.Request.URL,
+			Header: harHeaders(entry.Request.Headers),
+			Form:   make(map[string][]string),
+		}
+		for _, q := range entry.Request.QueryString {
+			req.WithQuery(q.Name, q.Value)
+		}
+		if u, err := url.Parse(req.URI); err == nil {
+			req.Host = u.Host
+			req.Scheme = u.Scheme
+		}
+		if entry.Request.PostData != nil {
+			req.Body = []byte(entry.Request.PostData.Text)
+			req.ContentLength = int64(len(req.Body))
+		}
+
+		resp := &HTTPResponse{
+			StatusCode: entry.Response.Status,
+			Header:     harHeaders(entry.Response.Headers),
+			Body:       []byte(entry.Response.Content.Text),
+		}
+		if entry.Response.Content.Encoding == "base64" {
+			body, err := base64.StdEncoding.DecodeString(entry.Response.Content.Text)
+			if err != nil {
+				return nil, err
+			}
+			resp.Body = body
+		}
+
+		exchanges = append(exchanges, HTTPExchange{
+			Request:  req,
+			Response: resp,
+			Started:  entry.StartedDateTime,
+			Duration: time.Duration(entry.Time * float64(time.Millisecond)),
+		})
+	}
+	return exchanges, nil
+}
+
+func harRequest(r *HTTPRequest) HARRequest {
+	h := http.Header(r.Header)
+	hr := HARRequest{
+		Method:      r.Method,
+		URL:         r.URI,
+		HTTPVersion: "HTTP/1.1",
+		Cookies:     []HARNameValue{},
+		Headers:     harNameValues(r.Header),
+		QueryString: []HARNameValue{},
+		HeadersSize: -1,
+		BodySize:    len(r.Body),
+	}
+	if u, err := url.Parse(r.URI); err == nil {
+		if u.Host == "" && r.Host != "" {
+			u.Host = r.Host
+			u.Scheme = r.Scheme
+			if u.Scheme == "" {
+				u.Scheme = "http"
+			}
+			hr.URL = u.String()
+		}
+		hr.QueryString = harNameValues(u.Query())
+	}
+	for name, value := range r.Cookies {
+		hr.Cookies = append(hr.Cookies, HARNameValue{Name: name, Value: value})
+	}
+	if len(r.Body) > 0 {
+		hr.PostData = &HARPostData{MimeType: h.Get("Content-Type"), Text: string(r.Body)}
+	}
+	return hr
+}
+
+func harResponse(r *HTTPResponse) HARResponse {
+	h := http.Header(r.Header)
+	hr := HARResponse{
+		Status:      r.StatusCode,
+		StatusText:  http.StatusText(r.StatusCode),
+		HTTPVersion: "HTTP/1.1",
+		Cookies:     []HARNameValue{},
+		Headers:     harNameValues(r.Header),
+		Content: HARContent{
+			Size:     len(r.Body),
+			MimeType: h.Get("Content-Type"),
+		},
+		RedirectURL: h.Get("Location"),
+		HeadersSize: -1,
+		BodySize:    len(r.Body),
+	}
+	for _, c := range r.Cookies {
+		hr.Cookies = append(hr.Cookies, HARNameValue{Name: c.Name, Value: c.Value})
+	}
+	if utf8.Valid(r.Body) {
+		hr.Content.Text = string(r.Body)
+	} else {
+		hr.Content.Text = base64.StdEncoding.EncodeToString(r.Body)
+		hr.Content.Encoding = "base64"
+	}
+	return hr
+}
+
+// harNameValues flattens a map of values into a sorted list of HAR name/value pairs
+func harNameValues(m map[string][]string) []HARNameValue {
+	names := make([]string, 0, len(m))
+	for name := range m {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	list := []HARNameValue{}
+	for _, name := range names {
+		for _, value := range m[name] {
+			list = append(list, HARNameValue{Name: name, Value: value})
+		}
+	}
+	return list
+}
+
+func harHeaders(list []HARNameValue) map[string][]string {
+	h := make(http.Header)
+	for _, nv := range list {
+		h.Add(nv.Name, nv.Value)
+	}
+	return h
+}
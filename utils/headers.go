@@ -0,0 +1,117 @@
+package utils
+
+import (
+	"net/http"
+	"strings"
+)
+
+// HeaderValues returns all values of a header matching name case-insensitively
+func HeaderValues(h map[string][]string, name string) []string {
+	if v, ok := h[http.CanonicalHeaderKey(name)]; ok {
+		return v
+	}
+	var values []string
+	for k, v := range h {
+		if strings.EqualFold(k, name) {
+			values = append(values, v...)
+		}
+	}
+	return values
+}
+
+// HeaderGet returns the first value of a header matching name case-insensitively
+func HeaderGet(h map[string][]string, name string) string {
+	if v := HeaderValues(h, name); len(v) > 0 {
+		return v[0]
+	}
+	return ""
+}
+
+// HeaderSet replaces all values of a header (in any casing) with a given one
+func HeaderSet(h map[string][]string, name, value string) {
+	HeaderDel(h, name)
+	h[http.CanonicalHeaderKey(name)] = []string{value}
+}
+
+// HeaderAdd appends a value to a header merging values stored in other casings
+func HeaderAdd(h map[string][]string, name, value string) {
+	key := http.CanonicalHeaderKey(name)
+	values := HeaderValues(h, name)
+	HeaderDel(h, name)
+	h[key] = append(values, value)
+}
+
+// HeaderDel removes a header in all casings
+func HeaderDel(h map[string][]string, name string) {
+	for k := range h {
+		if strings.EqualFold(k, name) {
+			delete(h, k)
+		}
+	}
+}
+
+// CanonicalizeHeader rewrites header names to canonical form merging values of names
+// which differ only in casing
+func CanonicalizeHeader(h map[string][]string) map[string][]string {
+	if h == nil {
+		return nil
+	}
+	out := make(map[string][]string, len(h))
+	for k, v := range h {
+		key := http.CanonicalHeaderKey(k)
+		out[key] = append(out[key], v...)
+	}
+	return out
+}
+
+// GetHeader returns the first value of a request header
+func (r *HTTPRequest) GetHeader(name string) string {
+	return HeaderGet(r.Header, name)
+}
+
+// SetHeader sets a request header
+func (r *HTTPRequest) SetHeader(name, value string) {
+	if r.Header == nil {
+		r.Header = make(map[string][]string)
+	}
+	HeaderSet(r.Header, name, value)
+}
+
+// AddHeader adds a value to a request header
+func (r *HTTPRequest) AddHeader(name, value string) {
+	if r.Header == nil {
+		r.Header = make(map[string][]string)
+	}
+	HeaderAdd(r.Header, name, value)
+}
+
+// DelHeader removes a request header
+func (r *HTTPRequest) DelHeader(name string) {
+	HeaderDel(r.Header, name)
+}
+
+// GetHeader returns the first value of a response header
+func (r *HTTPResponse) GetHeader(name string) string {
+	return HeaderGet(r.Header, name)
+}
+
+// SetHeader sets a response header
+func (r *HTTPResponse) SetHeader(name, value string) {
+	if r.Header == nil {
+		r.Header = make(map[string][]string)
+	}
+	HeaderSet(r.Header, name, value)
+}
+
+// AddHeader adds a value to a response header
+func (r *HTTPResponse) AddHeader(name, value string) {
+	if r.Header == nil {
+		r.Header = make(map[string][]string)
+	}
+	HeaderAdd(r.Header, name, value)
+}
+
+// DelHeader removes a response header
+func (r *HTTPResponse) DelHeader(name string) {
+	HeaderDel(r.Header, name)
+}
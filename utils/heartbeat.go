@@ -0,0 +1,119 @@
+package utils
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/cascades-fbp/cascades/runtime"
+)
+
+// DefaultHeartbeatInterval is how often liveness IPs are emitted
+const DefaultHeartbeatInterval = 5 * time.Second
+
+// Heartbeat is a liveness record emitted on HEARTBEAT ports
+type Heartbeat struct {
+	Component string    `json:"component"`
+	PID       int       `json:"pid"`
+	Time      time.Time `json:"time"`
+	Uptime    float64   `json:"uptime"`    // Seconds since the component started
+	Processed uint64    `json:"processed"` // Number of IPs processed so far
+}
+
+// Liveness counts processed IPs and periodically emits heartbeats. A hung
+// component stops emitting them or its processed count stops growing.
+type Liveness struct {
+	Interval time.Duration
+
+	component string
+	started   time.Time
+	processed uint64
+	stop      chan struct{}
+	wg        sync.WaitGroup
+}
+
+// NewLiveness creates a liveness tracker for a given component
+func NewLiveness(component string) *Liveness {
+	return &Liveness{
+		Interval:  DefaultHeartbeatInterval,
+		component: component,
+		started:   time.Now(),
+	}
+}
+
+// Inc counts a processed IP, it's safe for concurrent use
+func (l *Liveness) Inc() {
+	atomic.AddUint64(&l.processed, 1)
+}
+
+// Heartbeat returns the current liveness state
+func (l *Liveness) Heartbeat() *Heartbeat {
+	now := time.Now()
+	return &Heartbeat{
+		Component: l.component,
+		PID:       os.Getpid(),
+		Time:      now,
+		Uptime:    now.Sub(l.started).Seconds(),
+		Processed: atomic.LoadUint64(&l.processed),
+	}
+}
+
+// Start emits a heartbeat right away and then every Interval using a given
+// function, usually sending to the HEARTBEAT port
+func (l *Liveness) Start(send func(ip [][]byte)) {
+	l.stop = make(chan struct{})
+	l.wg.Add(1)
+	go func() {
+		defer l.wg.Done()
+		ticker := time.NewTicker(l.Interval)
+		defer ticker.Stop()
+		for {
+			if ip, err := Heartbeat2IP(l.Heartbeat()); err == nil {
+				send(ip)
+			}
+			select {
+			case <-ticker.C:
+			case <-l.stop:
+				return
+			}
+		}
+	}()
+}
+
+// Stop stops emitting heartbeats and waits until the send function is no
+// longer called, so the port can be closed. It's a no-op if not started.
+func (l *Liveness) Stop() {
+	if l.stop == nil {
+		return
+	}
+	close(l.stop)
+	l.wg.Wait()
+	l.stop = nil
+}
+
+// Heartbeat2IP converts a given heartbeat to IP
+func Heartbeat2IP(h *Heartbeat) ([][]byte, error) {
+	payload, err := json.Marshal(h)
+	if err != nil {
+		return nil, err
+	}
+	return runtime.NewPacket(payload), nil
+}
+
+// IP2Heartbeat converts a given IP to heartbeat
+func IP2Heartbeat(ip [][]byte) (*Heartbeat, error) {
+	if len(ip) < 2 {
+		return nil, fmt.Errorf("invalid IP with %d frames", len(ip))
+	}
+	var h *Heartbeat
+	if err := json.Unmarshal(ip[1], &h); err != nil {
+		return nil, err
+	}
+	if h == nil {
+		return nil, fmt.Errorf("empty heartbeat payload")
+	}
+	return h, nil
+}
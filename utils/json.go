@@ -0,0 +1,71 @@
+package utils
+
+import (
+	"encoding/json"
+	"fmt"
+	"mime"
+	"strings"
+)
+
+// SetJSON encodes a given value as response body setting JSON Content-Type
+func (r *HTTPResponse) SetJSON(v interface{}) error {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return fmt.Errorf("encoding JSON body: %w", err)
+	}
+	r.WithBody("application/json; charset=utf-8", data)
+	return nil
+}
+
+// SetJSON encodes a given value as request body setting JSON Content-Type
+func (r *HTTPRequest) SetJSON(v interface{}) error {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return fmt.Errorf("encoding JSON body: %w", err)
+	}
+	r.WithBody("application/json; charset=utf-8", data)
+	return nil
+}
+
+// DecodeJSON decodes JSON request body into a given value. Requests declaring
+// a non-JSON Content-Type or a charset other than UTF-8 are rejected.
+func (r *HTTPRequest) DecodeJSON(v interface{}) error {
+	return decodeJSON(r.GetHeader("Content-Type"), r.Body, v)
+}
+
+// DecodeJSON decodes JSON response body into a given value. Responses declaring
+// a non-JSON Content-Type or a charset other than UTF-8 are rejected.
+func (r *HTTPResponse) DecodeJSON(v interface{}) error {
+	return decodeJSON(r.GetHeader("Content-Type"), r.Body, v)
+}
+
+// IsJSONContentType checks if a media type is application/json or a +json suffix type
+func IsJSONContentType(contentType string) bool {
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return false
+	}
+	return mediaType == "application/json" || strings.HasSuffix(mediaType, "+json")
+}
+
+func decodeJSON(contentType string, body []byte, v interface{}) error {
+	if contentType != "" {
+		mediaType, params, err := mime.ParseMediaType(contentType)
+		if err != nil {
+			return fmt.Errorf("decoding JSON body: %w", err)
+		}
+		if mediaType != "application/json" && !strings.HasSuffix(mediaType, "+json") {
+			return fmt.Errorf("decoding JSON body: unexpected content type %s", mediaType)
+		}
+		if cs := strings.ToLower(params["charset"]); cs != "" && cs != "utf-8" && cs != "utf8" {
+			return fmt.Errorf("decoding JSON body: unsupported charset %s", cs)
+		}
+	}
+	if len(body) == 0 {
+		return fmt.Errorf("decoding JSON body: body is empty")
+	}
+	if err := json.Unmarshal(body, v); err != nil {
+		return fmt.Errorf("decoding JSON body: %w", err)
+	}
+	return nil
+}
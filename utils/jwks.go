@@ -0,0 +1,132 @@
+package utils
+
+import (
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"math/big"
+)
+
+// jwk is a JSON Web Key (RFC 7517) of a key set
+type jwk struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Alg string `json:"alg"`
+	Use string `json:"use"`
+	Crv string `json:"crv"`
+	X   string `json:"x"`
+	Y   string `json:"y"`
+	N   string `json:"n"`
+	E   string `json:"e"`
+	K   string `json:"k"`
+}
+
+// jwsAlgorithms map JWS algorithm names to signature algorithms of RFC 9421
+var jwsAlgorithms = map[string]string{
+	"HS256": AlgHMACSHA256,
+	"EdDSA": AlgEd25519,
+	"ES256": AlgECDSAP256SHA256,
+	"ES384": AlgECDSAP384SHA384,
+	"PS512": AlgRSAPSSSHA512,
+	"RS256": AlgRSAV15SHA256,
+}
+
+// ParseJWKS decodes a JSON Web Key Set into verifying keys by key ID. Keys
+// without kid, of other use than sig, of unsupported types or invalid keys
+// are skipped.
+func ParseJWKS(data []byte) (map[string]*VerifyingKey, error) {
+	var set struct {
+		Keys []jwk `json:"keys"`
+	}
+	if err := json.Unmarshal(data, &set); err != nil {
+		return nil, err
+	}
+	keys := make(map[string]*VerifyingKey, len(set.Keys))
+	for _, k := range set.Keys {
+		if k.Kid == "" || (k.Use != "" && k.Use != "sig") {
+			continue
+		}
+		if key, err := k.verifyingKey(); err == nil && key != nil {
+			keys[k.Kid] = key
+		}
+	}
+	return keys, nil
+}
+
+// verifyingKey converts the key, nil is returned for unsupported types. The
+// algorithm is taken from alg member or the key type, RSA keys without alg
+// accept both RSA algorithms.
+func (k *jwk) verifyingKey() (*VerifyingKey, error) {
+	vk := &VerifyingKey{}
+	switch k.Kty {
+	case "oct":
+		secret, err := base64.RawURLEncoding.DecodeString(k.K)
+		if err != nil || len(secret) == 0 {
+			return nil, fmt.Errorf("invalid secret")
+		}
+		vk.Key, vk.Algorithm = secret, AlgHMACSHA256
+	case "OKP":
+		if k.Crv != "Ed25519" {
+			return nil, nil
+		}
+		x, err := base64.RawURLEncoding.DecodeString(k.X)
+		if err != nil || len(x) != ed25519.PublicKeySize {
+			return nil, fmt.Errorf("invalid Ed25519 key")
+		}
+		vk.Key, vk.Algorithm = ed25519.PublicKey(x), AlgEd25519
+	case "EC":
+		var curve elliptic.Curve
+		switch k.Crv {
+		case "P-256":
+			curve, vk.Algorithm = elliptic.P256(), AlgECDSAP256SHA256
+		case "P-384":
+			curve, vk.Algorithm = elliptic.P384(), AlgECDSAP384SHA384
+		default:
+			return nil, nil
+		}
+		x, err := base64.RawURLEncoding.DecodeString(k.X)
+		if err != nil {
+			return nil, fmt.Errorf("invalid x coordinate")
+		}
+		y, err := base64.RawURLEncoding.DecodeString(k.Y)
+		if err != nil {
+			return nil, fmt.Errorf("invalid y coordinate")
+		}
+		pub := &ecdsa.PublicKey{Curve: curve, X: new(big.Int).SetBytes(x), Y: new(big.Int).SetBytes(y)}
+		if !curve.IsOnCurve(pub.X, pub.Y) {
+			return nil, fmt.Errorf("point isn't on curve %s", k.Crv)
+		}
+		vk.Key = pub
+	case "RSA":
+		n, err := base64.RawURLEncoding.DecodeString(k.N)
+		if err != nil || len(n) == 0 {
+			return nil, fmt.Errorf("invalid modulus")
+		}
+		e, err := base64.RawURLEncoding.DecodeString(k.E)
+		if err != nil || len(e) == 0 || len(e) > 4 {
+			return nil, fmt.Errorf("invalid exponent")
+		}
+		vk.Key = &rsa.PublicKey{N: new(big.Int).SetBytes(n), E: int(new(big.Int).SetBytes(e).Int64())}
+	default:
+		return nil, nil
+	}
+	if k.Alg == "" {
+		return vk, nil
+	}
+	alg, ok := jwsAlgorithms[k.Alg]
+	if !ok {
+		return nil, nil
+	}
+	if vk.Algorithm != "" && vk.Algorithm != alg {
+		return nil, fmt.Errorf("algorithm %s doesn't match key type %s", k.Alg, k.Kty)
+	}
+	vk.Algorithm = alg
+	if err := vk.check(alg); err != nil {
+		return nil, err
+	}
+	return vk, nil
+}
@@ -0,0 +1,135 @@
+package utils
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// Link is a single link of RFC 5988 Link header
+type Link struct {
+	URI    string
+	Rel    string
+	Params map[string]string // Other parameters (title, type, etc)
+}
+
+// ParseLinks parses Link header values
+func ParseLinks(values ...string) []Link {
+	var links []Link
+	for _, value := range values {
+		for _, part := range splitLinks(value) {
+			part = strings.TrimSpace(part)
+			if !strings.HasPrefix(part, "<") {
+				continue
+			}
+			end := strings.Index(part, ">")
+			if end < 0 {
+				continue
+			}
+			link := Link{URI: part[1:end]}
+			for _, param := range splitQuoted(part[end+1:], ';') {
+				kv := strings.SplitN(strings.TrimSpace(param), "=", 2)
+				key := strings.ToLower(strings.TrimSpace(kv[0]))
+				if key == "" {
+					continue
+				}
+				val := ""
+				if len(kv) == 2 {
+					val = strings.Trim(strings.TrimSpace(kv[1]), `"`)
+				}
+				if key == "rel" {
+					link.Rel = val
+					continue
+				}
+				if link.Params == nil {
+					link.Params = make(map[string]string)
+				}
+				link.Params[key] = val
+			}
+			links = append(links, link)
+		}
+	}
+	return links
+}
+
+// FindLink returns URI of the first link with a given relation type. Relation
+// parameter may list several space separated types.
+func FindLink(links []Link, rel string) (string, bool) {
+	for _, link := range links {
+		for _, r := range strings.Fields(link.Rel) {
+			if strings.EqualFold(r, rel) {
+				return link.URI, true
+			}
+		}
+	}
+	return "", false
+}
+
+// String formats the link for Link header
+func (l Link) String() string {
+	s := "<" + l.URI + ">"
+	if l.Rel != "" {
+		s += fmt.Sprintf(`; rel="%s"`, l.Rel)
+	}
+	keys := make([]string, 0, len(l.Params))
+	for k := range l.Params {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		s += fmt.Sprintf(`; %s="%s"`, k, strings.Replace(l.Params[k], `"`, `\"`, -1))
+	}
+	return s
+}
+
+// FormatLinks builds Link header value from a list of links
+func FormatLinks(links []Link) string {
+	parts := make([]string, len(links))
+	for i, l := range links {
+		parts[i] = l.String()
+	}
+	return strings.Join(parts, ", ")
+}
+
+// splitLinks splits header value by commas which are outside of URIs and quotes
+func splitLinks(value string) []string {
+	var (
+		parts []string
+		start int
+		inURI bool
+		quote bool
+	)
+	for i, r := range value {
+		switch {
+		case r == '"' && !inURI:
+			quote = !quote
+		case r == '<' && !quote:
+			inURI = true
+		case r == '>' && !quote:
+			inURI = false
+		case r == ',' && !inURI && !quote:
+			parts = append(parts, value[start:i])
+			start = i + 1
+		}
+	}
+	return append(parts, value[start:])
+}
+
+// splitQuoted splits a string by a separator which is outside of quotes
+func splitQuoted(value string, sep rune) []string {
+	var (
+		parts []string
+		start int
+		quote bool
+	)
+	for i, r := range value {
+		switch {
+		case r == '"':
+			quote = !quote
+		case r == sep && !quote:
+			parts = append(parts, value[start:i])
+			start = i + 1
+		}
+	}
+	return append(parts, value[start:])
+}
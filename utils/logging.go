@@ -0,0 +1,231 @@
+package utils
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/cascades-fbp/cascades/runtime"
+)
+
+// Level is a severity of a log entry
+type Level int
+
+// Supported log levels
+const (
+	LevelDebug Level = iota
+	LevelInfo
+	LevelWarn
+	LevelError
+)
+
+var levelNames = []string{"debug", "info", "warn", "error"}
+
+func (l Level) String() string {
+	if l < LevelDebug || l > LevelError {
+		return fmt.Sprintf("level(%d)", int(l))
+	}
+	return levelNames[l]
+}
+
+// ParseLevel resolves a level by its name
+func ParseLevel(name string) (Level, error) {
+	for i, n := range levelNames {
+		if strings.EqualFold(n, name) {
+			return Level(i), nil
+		}
+	}
+	return LevelInfo, fmt.Errorf("unknown log level %s", name)
+}
+
+// LogEntry is a structured log record emitted on LOG ports
+type LogEntry struct {
+	Time      time.Time              `json:"time"`
+	Component string                 `json:"component"`
+	Level     string                 `json:"level"`
+	Message   string                 `json:"message"`
+	Fields    map[string]interface{} `json:"fields,omitempty"`
+}
+
+// Logger writes leveled entries as text lines and optionally as IPs to a LOG port.
+// It's safe for concurrent use.
+type Logger struct {
+	component string
+	mu        sync.Mutex
+	level     Level
+	out       io.Writer
+	sink      func(ip [][]byte)
+	counts    [LevelError + 1]uint64
+}
+
+// NewLogger creates a logger for a given component which drops all entries
+// below info level and has no text output
+func NewLogger(component string) *Logger {
+	return &Logger{
+		component: component,
+		level:     LevelInfo,
+		out:       ioutil.Discard,
+	}
+}
+
+// SetLevel sets minimal level of emitted entries
+func (l *Logger) SetLevel(level Level) {
+	l.mu.Lock()
+	l.level = level
+	l.mu.Unlock()
+}
+
+// SetOutput sets destination of text lines
+func (l *Logger) SetOutput(w io.Writer) {
+	l.mu.Lock()
+	l.out = w
+	l.mu.Unlock()
+}
+
+// SetSink sets a function sending log IPs, usually to the LOG port. The sink
+// is never called concurrently.
+func (l *Logger) SetSink(sink func(ip [][]byte)) {
+	l.mu.Lock()
+	l.sink = sink
+	l.mu.Unlock()
+}
+
+// Debug logs a message with key/value pairs on debug level
+func (l *Logger) Debug(msg string, keyvals ...interface{}) {
+	l.Log(LevelDebug, msg, keyvals...)
+}
+
+// Info logs a message with key/value pairs on info level
+func (l *Logger) Info(msg string, keyvals ...interface{}) {
+	l.Log(LevelInfo, msg, keyvals...)
+}
+
+// Warn logs a message with key/value pairs on warn level
+func (l *Logger) Warn(msg string, keyvals ...interface{}) {
+	l.Log(LevelWarn, msg, keyvals...)
+}
+
+// Error logs a message with key/value pairs on error level
+func (l *Logger) Error(msg string, keyvals ...interface{}) {
+	l.Log(LevelError, msg, keyvals...)
+}
+
+// Log emits an entry if the level is enabled. Errors in values are logged
+// with their messages, secrets are redacted from the message and values.
+func (l *Logger) Log(level Level, msg string, keyvals ...interface{}) {
+	if level >= LevelDebug && level <= LevelError {
+		atomic.AddUint64(&l.counts[level], 1)
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if level < l.level {
+		return
+	}
+
+	e := &LogEntry{
+		Time:      time.Now().UTC(),
+		Component: l.component,
+		Level:     level.String(),
+		Message:   Redaction.Text(msg),
+	}
+	if len(keyvals) > 0 {
+		e.Fields = make(map[string]interface{}, (len(keyvals)+1)/2)
+		for i := 0; i < len(keyvals); i += 2 {
+			key := fmt.Sprint(keyvals[i])
+			var value interface{}
+			if i+1 < len(keyvals) {
+				value = keyvals[i+1]
+			}
+			if err, ok := value.(error); ok {
+				value = err.Error()
+			}
+			if Redaction.IsSensitive(key) {
+				value = Redacted
+			} else if s, ok := value.(string); ok {
+				value = Redaction.Text(s)
+			}
+			e.Fields[key] = value
+		}
+	}
+
+	if l.out != ioutil.Discard {
+		fmt.Fprintln(l.out, e.String())
+	}
+	if l.sink != nil {
+		if ip, err := LogEntry2IP(e); err == nil {
+			l.sink(ip)
+		}
+	}
+}
+
+// Count returns the number of entries logged on a given level, including
+// the ones below the minimal level
+func (l *Logger) Count(level Level) uint64 {
+	if level < LevelDebug || level > LevelError {
+		return 0
+	}
+	return atomic.LoadUint64(&l.counts[level])
+}
+
+// Writer returns a writer logging every line on debug level. It's meant
+// for redirecting standard log output with log.SetOutput.
+func (l *Logger) Writer() io.Writer {
+	return logWriter{l}
+}
+
+type logWriter struct {
+	l *Logger
+}
+
+func (w logWriter) Write(p []byte) (int, error) {
+	for _, line := range bytes.Split(bytes.TrimRight(p, "\n"), []byte("\n")) {
+		w.l.Debug(string(line))
+	}
+	return len(p), nil
+}
+
+// String formats the entry as a text line with sorted fields
+func (e *LogEntry) String() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "%s %s", strings.ToUpper(e.Level), e.Message)
+	keys := make([]string, 0, len(e.Fields))
+	for k := range e.Fields {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		fmt.Fprintf(&b, " %s=%v", k, e.Fields[k])
+	}
+	return b.String()
+}
+
+// LogEntry2IP converts a given log entry to IP
+func LogEntry2IP(e *LogEntry) ([][]byte, error) {
+	payload, err := json.Marshal(e)
+	if err != nil {
+		return nil, err
+	}
+	return runtime.NewPacket(payload), nil
+}
+
+// IP2LogEntry converts a given IP to log entry
+func IP2LogEntry(ip [][]byte) (*LogEntry, error) {
+	if len(ip) < 2 {
+		return nil, fmt.Errorf("invalid IP with %d frames", len(ip))
+	}
+	var e *LogEntry
+	if err := json.Unmarshal(ip[1], &e); err != nil {
+		return nil, err
+	}
+	if e == nil {
+		return nil, fmt.Errorf("empty log entry payload")
+	}
+	return e, nil
+}
@@ -0,0 +1,168 @@
+package utils
+
+import (
+	"expvar"
+	"fmt"
+	"net"
+	"net/http"
+	"runtime"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Metric types in Prometheus text format
+const (
+	metricCounter = "counter"
+	metricGauge   = "gauge"
+)
+
+// metricFamily is a named metric with its samples in registration order
+type metricFamily struct {
+	name    string
+	help    string
+	kind    string
+	samples []*metricSample
+}
+
+type metricSample struct {
+	labels string // Formatted label pairs without braces
+	value  func() float64
+}
+
+// Metrics collects counters and gauges of a component and exposes them over
+// HTTP: in Prometheus text format at /metrics and as expvar JSON at
+// /debug/vars. Values are read from functions when scraped, so nothing is
+// computed for components nobody scrapes. It's safe for concurrent use.
+type Metrics struct {
+	component string
+	started   time.Time
+
+	mu       sync.Mutex
+	families []*metricFamily
+}
+
+// NewMetrics creates metrics of a component with processed IPs counted by a
+// liveness tracker and log entries counted by a logger. Both share the
+// component name.
+func NewMetrics(logger *Logger, liveness *Liveness) *Metrics {
+	m := &Metrics{
+		component: liveness.component,
+		started:   time.Now(),
+	}
+	m.CounterFunc("cascades_http_processed_total", "Number of IPs processed", func() float64 {
+		return float64(liveness.Heartbeat().Processed)
+	})
+	for _, level := range []Level{LevelWarn, LevelError} {
+		level := level
+		m.CounterFunc("cascades_http_log_entries_total", "Number of log entries by level", func() float64 {
+			return float64(logger.Count(level))
+		}, "level", level.String())
+	}
+	m.GaugeFunc("cascades_http_uptime_seconds", "Seconds since the component started", func() float64 {
+		return time.Since(m.started).Seconds()
+	})
+	m.GaugeFunc("cascades_http_goroutines", "Number of goroutines", func() float64 {
+		return float64(runtime.NumGoroutine())
+	})
+	return m
+}
+
+// CounterFunc registers a counter read from a given function. Labels are
+// key/value pairs, the component label is always added.
+func (m *Metrics) CounterFunc(name, help string, value func() float64, labels ...string) {
+	m.register(name, help, metricCounter, value, labels)
+}
+
+// GaugeFunc registers a gauge read from a given function. Labels are
+// key/value pairs, the component label is always added.
+func (m *Metrics) GaugeFunc(name, help string, value func() float64, labels ...string) {
+	m.register(name, help, metricGauge, value, labels)
+}
+
+// WatchOutlet registers queue depth, sent and dropped IPs of an output port
+func (m *Metrics) WatchOutlet(o *Outlet) {
+	m.GaugeFunc("cascades_http_queue_depth", "Number of IPs queued for an output port", func() float64 {
+		return float64(o.Len())
+	}, "port", o.Name())
+	m.CounterFunc("cascades_http_sent_total", "Number of IPs sent to an output port", func() float64 {
+		return float64(o.Sent())
+	}, "port", o.Name())
+	m.CounterFunc("cascades_http_dropped_total", "Number of IPs dropped by the overflow policy of an output port", func() float64 {
+		return float64(o.Dropped())
+	}, "port", o.Name())
+}
+
+// Serve starts listening on a given address and serves metrics in background.
+// It must be called once per process as expvar names are global.
+func (m *Metrics) Serve(addr string) error {
+	ln, err := net.Listen("tcp", addr)
+	if err != nil {
+		return err
+	}
+	expvar.Publish("metrics", expvar.Func(func() interface{} { return m.Snapshot() }))
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", m)
+	mux.Handle("/debug/vars", expvar.Handler())
+	go http.Serve(ln, mux)
+	return nil
+}
+
+// ServeHTTP writes all metrics in Prometheus text exposition format
+func (m *Metrics) ServeHTTP(rw http.ResponseWriter, req *http.Request) {
+	rw.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	var b strings.Builder
+	for _, f := range m.snapshotFamilies() {
+		fmt.Fprintf(&b, "# HELP %s %s\n", f.name, f.help)
+		fmt.Fprintf(&b, "# TYPE %s %s\n", f.name, f.kind)
+		for _, s := range f.samples {
+			fmt.Fprintf(&b, "%s{%s} %s\n", f.name, s.labels, strconv.FormatFloat(s.value(), 'g', -1, 64))
+		}
+	}
+	rw.Write([]byte(b.String()))
+}
+
+// Snapshot returns current values keyed by metric name with labels
+func (m *Metrics) Snapshot() map[string]float64 {
+	values := make(map[string]float64)
+	for _, f := range m.snapshotFamilies() {
+		for _, s := range f.samples {
+			values[f.name+"{"+s.labels+"}"] = s.value()
+		}
+	}
+	return values
+}
+
+// register adds a sample to the family with a given name
+func (m *Metrics) register(name, help, kind string, value func() float64, labels []string) {
+	pairs := []string{"component=" + strconv.Quote(m.component)}
+	for i := 0; i+1 < len(labels); i += 2 {
+		pairs = append(pairs, labels[i]+"="+strconv.Quote(labels[i+1]))
+	}
+	sort.Strings(pairs[1:])
+	sample := &metricSample{labels: strings.Join(pairs, ","), value: value}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for _, f := range m.families {
+		if f.name == name {
+			f.samples = append(f.samples, sample)
+			return
+		}
+	}
+	m.families = append(m.families, &metricFamily{name: name, help: help, kind: kind, samples: []*metricSample{sample}})
+}
+
+// snapshotFamilies copies the registry so values are read without the lock
+func (m *Metrics) snapshotFamilies() []metricFamily {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	families := make([]metricFamily, len(m.families))
+	for i, f := range m.families {
+		families[i] = *f
+		families[i].samples = append([]*metricSample(nil), f.samples...)
+	}
+	return families
+}
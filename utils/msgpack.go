@@ -0,0 +1,67 @@
+package utils
+
+import (
+	"bytes"
+	"encoding/json"
+
+	"github.com/cascades-fbp/cascades/runtime"
+	"github.com/vmihailenco/msgpack/v5"
+)
+
+// msgpackMarker prefixes MessagePack payloads. The byte is never used by MessagePack
+// itself and can't start JSON document, so decoders can tell the codecs apart.
+const msgpackMarker byte = 0xc1
+
+// Request2IPMsgpack converts a given request to IP using MessagePack codec
+func Request2IPMsgpack(request *HTTPRequest) ([][]byte, error) {
+	if err := request.Materialize(); err != nil {
+		return nil, err
+	}
+	payload, err := marshalMsgpack(request)
+	if err != nil {
+		return nil, err
+	}
+	return runtime.NewPacket(payload), nil
+}
+
+// Response2IPMsgpack converts a given response to IP using MessagePack codec
+func Response2IPMsgpack(response *HTTPResponse) ([][]byte, error) {
+	if err := response.Materialize(); err != nil {
+		return nil, err
+	}
+	payload, err := marshalMsgpack(response)
+	if err != nil {
+		return nil, err
+	}
+	return runtime.NewPacket(payload), nil
+}
+
+// IsMsgpackIP checks if a given IP payload is encoded with MessagePack
+func IsMsgpackIP(ip [][]byte) bool {
+	return len(ip) > 1 && len(ip[1]) > 0 && ip[1][0] == msgpackMarker
+}
+
+// marshalMsgpack encodes a value reusing JSON field names
+func marshalMsgpack(v interface{}) ([]byte, error) {
+	var buf bytes.Buffer
+	buf.WriteByte(msgpackMarker)
+	enc := msgpack.NewEncoder(&buf)
+	enc.SetCustomStructTag("json")
+	if err := enc.Encode(v); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// unmarshalCodec decodes IP payload detecting the codec by its first byte
+func unmarshalCodec(payload []byte, v interface{}) error {
+	if len(payload) > 0 && payload[0] == protobufMarker {
+		return unmarshalProtobuf(payload[1:], v)
+	}
+	if len(payload) > 0 && payload[0] == msgpackMarker {
+		dec := msgpack.NewDecoder(bytes.NewReader(payload[1:]))
+		dec.SetCustomStructTag("json")
+		return dec.Decode(v)
+	}
+	return json.Unmarshal(payload, v)
+}
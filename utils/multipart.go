@@ -0,0 +1,122 @@
+package utils
+
+import (
+	"fmt"
+	"io"
+	"mime"
+	"mime/multipart"
+	"net/textproto"
+	"strings"
+)
+
+// ChunkFunc returns the next chunk of a body substream or io.EOF when the substream
+// is over (i.e. on close bracket)
+type ChunkFunc func() ([]byte, error)
+
+// chunkReader exposes a sequence of chunks as io.Reader
+type chunkReader struct {
+	next  ChunkFunc
+	chunk []byte
+	err   error
+}
+
+// NewChunkReader creates a reader pulling body chunks on demand
+func NewChunkReader(next ChunkFunc) io.Reader {
+	return &chunkReader{next: next}
+}
+
+func (r *chunkReader) Read(p []byte) (int, error) {
+	for len(r.chunk) == 0 {
+		if r.err != nil {
+			return 0, r.err
+		}
+		r.chunk, r.err = r.next()
+	}
+	n := copy(p, r.chunk)
+	r.chunk = r.chunk[n:]
+	return n, nil
+}
+
+// MultipartBoundary extracts boundary parameter of a multipart Content-Type
+func MultipartBoundary(contentType string) (string, error) {
+	mediaType, params, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return "", err
+	}
+	if !strings.HasPrefix(mediaType, "multipart/") {
+		return "", fmt.Errorf("not a multipart content type %q", contentType)
+	}
+	boundary := params["boundary"]
+	if boundary == "" {
+		return "", fmt.Errorf("no boundary in content type %q", contentType)
+	}
+	return boundary, nil
+}
+
+// NewMultipartReader iterates parts of a multipart body arriving as chunks, so parts
+// can be processed without buffering the whole body
+func NewMultipartReader(contentType string, next ChunkFunc) (*multipart.Reader, error) {
+	boundary, err := MultipartBoundary(contentType)
+	if err != nil {
+		return nil, err
+	}
+	return multipart.NewReader(NewChunkReader(next), boundary), nil
+}
+
+// MultipartStream builds a multipart body incrementally handing every written
+// piece to the emit function (i.e. to send it as a packet of a substream)
+type MultipartStream struct {
+	w *multipart.Writer
+}
+
+// emitWriter passes writes to the emit function
+type emitWriter func([]byte) error
+
+func (f emitWriter) Write(p []byte) (int, error) {
+	chunk := make([]byte, len(p))
+	copy(chunk, p)
+	if err := f(chunk); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
+
+// NewMultipartStream creates a multipart/form-data stream with a random boundary
+func NewMultipartStream(emit func([]byte) error) *MultipartStream {
+	return &MultipartStream{w: multipart.NewWriter(emitWriter(emit))}
+}
+
+// ContentType returns Content-Type of the body being built
+func (s *MultipartStream) ContentType() string {
+	return s.w.FormDataContentType()
+}
+
+// WriteField emits a form field part
+func (s *MultipartStream) WriteField(name, value string) error {
+	return s.w.WriteField(name, value)
+}
+
+// CreateFile starts a file part. Data written to the returned writer is emitted as is.
+func (s *MultipartStream) CreateFile(field, filename, contentType string) (io.Writer, error) {
+	h := make(textproto.MIMEHeader)
+	h.Set("Content-Disposition", fmt.Sprintf(`form-data; name="%s"; filename="%s"`, escapeQuotes(field), escapeQuotes(filename)))
+	if contentType == "" {
+		contentType = "application/octet-stream"
+	}
+	h.Set("Content-Type", contentType)
+	return s.w.CreatePart(h)
+}
+
+// CreatePart starts a part with arbitrary headers
+func (s *MultipartStream) CreatePart(header textproto.MIMEHeader) (io.Writer, error) {
+	return s.w.CreatePart(header)
+}
+
+// Close emits the closing boundary
+func (s *MultipartStream) Close() error {
+	return s.w.Close()
+}
+
+func escapeQuotes(s string) string {
+	return strings.NewReplacer("\\", "\\\\", `"`, "\\\"").Replace(s)
+}
@@ -0,0 +1,118 @@
+package utils
+
+import (
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// AcceptSpec is a single entry of Accept, Accept-Language or Accept-Encoding header
+type AcceptSpec struct {
+	Value  string            // Media type, language range or encoding
+	Q      float64           // Quality value
+	Params map[string]string // Other parameters (media types only)
+}
+
+// Accept is a parsed Accept-like header sorted by preference
+type Accept []AcceptSpec
+
+// ParseAccept parses Accept, Accept-Language or Accept-Encoding header value
+func ParseAccept(header string) Accept {
+	var specs Accept
+	for _, part := range strings.Split(header, ",") {
+		fields := strings.Split(part, ";")
+		value := strings.ToLower(strings.TrimSpace(fields[0]))
+		if value == "" {
+			continue
+		}
+		spec := AcceptSpec{Value: value, Q: 1}
+		for _, param := range fields[1:] {
+			kv := strings.SplitN(param, "=", 2)
+			key := strings.ToLower(strings.TrimSpace(kv[0]))
+			val := ""
+			if len(kv) == 2 {
+				val = strings.Trim(strings.TrimSpace(kv[1]), `"`)
+			}
+			if key == "q" {
+				if q, err := strconv.ParseFloat(val, 64); err == nil && q >= 0 && q <= 1 {
+					spec.Q = q
+				}
+				continue
+			}
+			if spec.Params == nil {
+				spec.Params = make(map[string]string)
+			}
+			spec.Params[key] = val
+		}
+		specs = append(specs, spec)
+	}
+	sort.SliceStable(specs, func(i, j int) bool {
+		if specs[i].Q != specs[j].Q {
+			return specs[i].Q > specs[j].Q
+		}
+		return specificity(specs[i].Value) > specificity(specs[j].Value)
+	})
+	return specs
+}
+
+// Negotiate returns the offered value the client prefers most or an empty string if
+// none of them is acceptable. Ties are resolved in the order of offers.
+func (a Accept) Negotiate(offered []string) string {
+	if len(a) == 0 && len(offered) > 0 {
+		// No header means anything is acceptable
+		return offered[0]
+	}
+	best, bestQ, bestSpecificity := "", 0.0, -1
+	for _, offer := range offered {
+		q, s := a.quality(strings.ToLower(offer))
+		if q > bestQ || (q == bestQ && q > 0 && s > bestSpecificity) {
+			best, bestQ, bestSpecificity = offer, q, s
+		}
+	}
+	return best
+}
+
+// Quality returns the quality value a given offer gets from the header
+func (a Accept) Quality(offer string) float64 {
+	q, _ := a.quality(strings.ToLower(offer))
+	return q
+}
+
+// quality finds the most specific spec matching a given offer
+func (a Accept) quality(offer string) (float64, int) {
+	q, best := 0.0, -1
+	for _, spec := range a {
+		if !acceptMatches(spec.Value, offer) {
+			continue
+		}
+		if s := specificity(spec.Value); s > best {
+			q, best = spec.Q, s
+		}
+	}
+	return q, best
+}
+
+// acceptMatches checks if a header value (possibly a wildcard) matches a given offer
+func acceptMatches(value, offer string) bool {
+	switch {
+	case value == "*" || value == "*/*" || value == offer:
+		return true
+	case strings.HasSuffix(value, "/*"):
+		return strings.HasPrefix(offer, value[:len(value)-1])
+	case !strings.Contains(value, "/"):
+		// Language range prefix, i.e. en matches en-us
+		return strings.HasPrefix(offer, value+"-")
+	}
+	return false
+}
+
+// specificity ranks wildcards below partial and exact values
+func specificity(value string) int {
+	switch {
+	case value == "*" || value == "*/*":
+		return 0
+	case strings.HasSuffix(value, "/*"):
+		return 1
+	}
+	return 2 + strings.Count(value, "-")
+}
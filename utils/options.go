@@ -0,0 +1,197 @@
+package utils
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"strconv"
+	"time"
+)
+
+// Options is the configuration envelope understood by OPTIONS ports of client, server
+// and router. Common sections apply to every component, component specific settings
+// live in the section named after the component.
+type Options struct {
+	Timeouts     TimeoutOptions      `json:"timeouts"`
+	Limits       LimitOptions        `json:"limits"`
+	TLS          *TLSOptions         `json:"tls,omitempty"`
+	Logging      LoggingOptions      `json:"logging"`
+	Dump         DumpOptions         `json:"dump"`
+	Backpressure BackpressureOptions `json:"backpressure"`
+	Redact       RedactOptions       `json:"redact"`
+	Client       json.RawMessage     `json:"client,omitempty"`
+	Server       json.RawMessage     `json:"server,omitempty"`
+	Router       json.RawMessage     `json:"router,omitempty"`
+}
+
+// TimeoutOptions configure network timeouts (zero means component's default)
+type TimeoutOptions struct {
+	Request Duration `json:"request"` // Whole request/response exchange
+	Read    Duration `json:"read"`    // Reading request (server)
+	Write   Duration `json:"write"`   // Writing response (server)
+	Idle    Duration `json:"idle"`    // Keep-alive connections
+}
+
+// LimitOptions configure size limits (zero means component's default)
+type LimitOptions struct {
+	MaxBodySize    int64 `json:"max_body_size"`    // Maximal body size in bytes
+	MaxHeaderBytes int   `json:"max_header_bytes"` // Maximal size of request headers (server)
+}
+
+// TLSOptions configure TLS of client and server connections
+type TLSOptions struct {
+	CertFile   string `json:"cert_file"`   // Certificate (server certificate or client certificate for mTLS)
+	KeyFile    string `json:"key_file"`    // Private key of the certificate
+	CAFile     string `json:"ca_file"`     // CA bundle to verify peers with
+	ServerName string `json:"server_name"` // Server name override (client)
+	MinVersion string `json:"min_version"` // Minimal TLS version, i.e. 1.2
+	Insecure   bool   `json:"insecure"`    // Skip verification of server certificates (client)
+}
+
+// LoggingOptions configure component's log output
+type LoggingOptions struct {
+	Level string `json:"level"` // debug, info, warn or error
+	Debug bool   `json:"debug"` // Same as -debug flag
+}
+
+// Duration is time.Duration which is a string (i.e. "1m30s") or a number of seconds in JSON
+type Duration time.Duration
+
+// UnmarshalJSON implements json.Unmarshaler
+func (d *Duration) UnmarshalJSON(b []byte) error {
+	var s string
+	if err := json.Unmarshal(b, &s); err == nil {
+		v, err := time.ParseDuration(s)
+		if err != nil {
+			return err
+		}
+		*d = Duration(v)
+		return nil
+	}
+	seconds, err := strconv.ParseFloat(string(b), 64)
+	if err != nil {
+		return fmt.Errorf("invalid duration %s", b)
+	}
+	*d = Duration(seconds * float64(time.Second))
+	return nil
+}
+
+// MarshalJSON implements json.Marshaler
+func (d Duration) MarshalJSON() ([]byte, error) {
+	return json.Marshal(time.Duration(d).String())
+}
+
+// ParseOptions decodes configuration envelope
+func ParseOptions(data []byte) (*Options, error) {
+	var o *Options
+	if err := json.Unmarshal(data, &o); err != nil {
+		return nil, err
+	}
+	if o == nil {
+		return nil, fmt.Errorf("empty options")
+	}
+	return o, nil
+}
+
+// DecodeSection decodes component specific section into a given value. Missing
+// sections leave the value untouched.
+func DecodeSection(section json.RawMessage, v interface{}) error {
+	if len(section) == 0 {
+		return nil
+	}
+	return json.Unmarshal(section, v)
+}
+
+// Apply configures component's logger according to the options
+func (l *LoggingOptions) Apply(logger *Logger) error {
+	if l.Level != "" {
+		level, err := ParseLevel(l.Level)
+		if err != nil {
+			return err
+		}
+		logger.SetLevel(level)
+	}
+	if l.Debug {
+		logger.SetLevel(LevelDebug)
+	}
+	if l.Debug || l.Level == "debug" {
+		logger.SetOutput(os.Stdout)
+	}
+	return nil
+}
+
+var tlsVersionNames = map[string]uint16{
+	"1.0": tls.VersionTLS10,
+	"1.1": tls.VersionTLS11,
+	"1.2": tls.VersionTLS12,
+	"1.3": tls.VersionTLS13,
+}
+
+// ClientConfig builds TLS configuration for outgoing connections
+func (t *TLSOptions) ClientConfig() (*tls.Config, error) {
+	cfg, err := t.config()
+	if err != nil {
+		return nil, err
+	}
+	cfg.ServerName = t.ServerName
+	cfg.InsecureSkipVerify = t.Insecure
+	if t.CAFile != "" {
+		if cfg.RootCAs, err = loadCertPool(t.CAFile); err != nil {
+			return nil, err
+		}
+	}
+	return cfg, nil
+}
+
+// ServerConfig builds TLS configuration for a listener. With CA file client
+// certificates are verified when presented.
+func (t *TLSOptions) ServerConfig() (*tls.Config, error) {
+	if t.CertFile == "" || t.KeyFile == "" {
+		return nil, fmt.Errorf("server TLS requires cert_file and key_file")
+	}
+	cfg, err := t.config()
+	if err != nil {
+		return nil, err
+	}
+	if t.CAFile != "" {
+		if cfg.ClientCAs, err = loadCertPool(t.CAFile); err != nil {
+			return nil, err
+		}
+		cfg.ClientAuth = tls.VerifyClientCertIfGiven
+	}
+	return cfg, nil
+}
+
+func (t *TLSOptions) config() (*tls.Config, error) {
+	cfg := &tls.Config{}
+	if t.MinVersion != "" {
+		v, ok := tlsVersionNames[t.MinVersion]
+		if !ok {
+			return nil, fmt.Errorf("unsupported TLS version %s", t.MinVersion)
+		}
+		cfg.MinVersion = v
+	}
+	if t.CertFile != "" || t.KeyFile != "" {
+		cert, err := tls.LoadX509KeyPair(t.CertFile, t.KeyFile)
+		if err != nil {
+			return nil, err
+		}
+		cfg.Certificates = []tls.Certificate{cert}
+	}
+	return cfg, nil
+}
+
+func loadCertPool(file string) (*x509.CertPool, error) {
+	data, err := ioutil.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(data) {
+		return nil, fmt.Errorf("no certificates found in %s", file)
+	}
+	return pool, nil
+}
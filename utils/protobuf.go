@@ -0,0 +1,626 @@
+package utils
+
+import (
+	"fmt"
+	"math"
+	"time"
+
+	"github.com/cascades-fbp/cascades/runtime"
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+// protobufMarker prefixes Protocol Buffers payloads (see http.proto)
+const protobufMarker byte = 0x00
+
+// Request2IPProtobuf converts a given request to IP using Protocol Buffers codec
+func Request2IPProtobuf(request *HTTPRequest) ([][]byte, error) {
+	if err := request.Materialize(); err != nil {
+		return nil, err
+	}
+	b := []byte{protobufMarker}
+	b = appendString(b, 1, request.ID)
+	b = appendString(b, 2, request.Method)
+	b = appendString(b, 3, request.URI)
+	b = appendValuesMap(b, 4, request.Header)
+	b = appendValuesMap(b, 5, request.Form)
+	if request.ContentLength != 0 {
+		b = protowire.AppendTag(b, 6, protowire.VarintType)
+		b = protowire.AppendVarint(b, uint64(request.ContentLength))
+	}
+	if len(request.Body) > 0 {
+		b = protowire.AppendTag(b, 7, protowire.BytesType)
+		b = protowire.AppendBytes(b, request.Body)
+	}
+	b = appendString(b, 8, request.RemoteAddr)
+	b = appendString(b, 9, request.Host)
+	b = appendString(b, 10, request.Scheme)
+	if request.TLS != nil {
+		var msg []byte
+		msg = appendString(msg, 1, request.TLS.Version)
+		msg = appendString(msg, 2, request.TLS.CipherSuite)
+		msg = appendString(msg, 3, request.TLS.PeerSubject)
+		msg = appendString(msg, 4, request.TLS.JA3)
+		msg = appendString(msg, 5, request.TLS.JA4)
+		b = protowire.AppendTag(b, 11, protowire.BytesType)
+		b = protowire.AppendBytes(b, msg)
+	}
+	for k, v := range request.Cookies {
+		var entry []byte
+		entry = appendString(entry, 1, k)
+		entry = appendString(entry, 2, v)
+		b = protowire.AppendTag(b, 12, protowire.BytesType)
+		b = protowire.AppendBytes(b, entry)
+	}
+	b = appendValuesMap(b, 13, request.Query)
+	b = appendValuesMap(b, 14, request.PostForm)
+	b = appendValuesMap(b, 15, request.Trailer)
+	b = appendString(b, 16, request.Trace)
+	b = appendString(b, 17, request.User)
+	for _, g := range request.Groups {
+		b = protowire.AppendTag(b, 18, protowire.BytesType)
+		b = protowire.AppendString(b, g)
+	}
+	for _, r := range request.Roles {
+		b = protowire.AppendTag(b, 19, protowire.BytesType)
+		b = protowire.AppendString(b, r)
+	}
+	if request.Bot != nil {
+		var msg []byte
+		if request.Bot.Score != 0 {
+			msg = protowire.AppendTag(msg, 1, protowire.VarintType)
+			msg = protowire.AppendVarint(msg, uint64(int64(request.Bot.Score)))
+		}
+		msg = appendString(msg, 2, request.Bot.Class)
+		msg = appendString(msg, 3, request.Bot.Name)
+		for _, r := range request.Bot.Reasons {
+			msg = protowire.AppendTag(msg, 4, protowire.BytesType)
+			msg = protowire.AppendString(msg, r)
+		}
+		b = protowire.AppendTag(b, 20, protowire.BytesType)
+		b = protowire.AppendBytes(b, msg)
+	}
+	if geo := request.Geo; geo != nil {
+		var msg []byte
+		msg = appendString(msg, 1, geo.Country)
+		msg = appendString(msg, 2, geo.CountryName)
+		msg = appendString(msg, 3, geo.Continent)
+		msg = appendString(msg, 4, geo.Region)
+		msg = appendString(msg, 5, geo.City)
+		msg = appendString(msg, 6, geo.PostalCode)
+		msg = appendDouble(msg, 7, geo.Latitude)
+		msg = appendDouble(msg, 8, geo.Longitude)
+		msg = appendString(msg, 9, geo.TimeZone)
+		if geo.ASN != 0 {
+			msg = protowire.AppendTag(msg, 10, protowire.VarintType)
+			msg = protowire.AppendVarint(msg, uint64(geo.ASN))
+		}
+		msg = appendString(msg, 11, geo.ASOrg)
+		b = protowire.AppendTag(b, 21, protowire.BytesType)
+		b = protowire.AppendBytes(b, msg)
+	}
+	if route := request.Route; route != nil {
+		var msg []byte
+		msg = appendString(msg, 1, route.Pattern)
+		if ms := time.Duration(route.Timeout).Milliseconds(); ms != 0 {
+			msg = protowire.AppendTag(msg, 2, protowire.VarintType)
+			msg = protowire.AppendVarint(msg, uint64(ms))
+		}
+		if route.AuthRequired {
+			msg = protowire.AppendTag(msg, 3, protowire.VarintType)
+			msg = protowire.AppendVarint(msg, 1)
+		}
+		msg = appendString(msg, 4, route.RateClass)
+		for k, v := range route.Labels {
+			var entry []byte
+			entry = appendString(entry, 1, k)
+			entry = appendString(entry, 2, v)
+			msg = protowire.AppendTag(msg, 5, protowire.BytesType)
+			msg = protowire.AppendBytes(msg, entry)
+		}
+		b = protowire.AppendTag(b, 22, protowire.BytesType)
+		b = protowire.AppendBytes(b, msg)
+	}
+	return runtime.NewPacket(b), nil
+}
+
+// Response2IPProtobuf converts a given response to IP using Protocol Buffers codec
+func Response2IPProtobuf(response *HTTPResponse) ([][]byte, error) {
+	if err := response.Materialize(); err != nil {
+		return nil, err
+	}
+	b := []byte{protobufMarker}
+	b = appendString(b, 1, response.ID)
+	if response.StatusCode != 0 {
+		b = protowire.AppendTag(b, 2, protowire.VarintType)
+		b = protowire.AppendVarint(b, uint64(int64(response.StatusCode)))
+	}
+	b = appendValuesMap(b, 3, response.Header)
+	if len(response.Body) > 0 {
+		b = protowire.AppendTag(b, 4, protowire.BytesType)
+		b = protowire.AppendBytes(b, response.Body)
+	}
+	for _, c := range response.Cookies {
+		b = protowire.AppendTag(b, 5, protowire.BytesType)
+		b = protowire.AppendBytes(b, appendCookie(nil, c))
+	}
+	b = appendValuesMap(b, 6, response.Trailer)
+	b = appendString(b, 7, response.Trace)
+	b = appendString(b, 8, response.URL)
+	for _, r := range response.Redirects {
+		b = protowire.AppendTag(b, 9, protowire.BytesType)
+		b = protowire.AppendString(b, r)
+	}
+	if response.Attempts != 0 {
+		b = protowire.AppendTag(b, 10, protowire.VarintType)
+		b = protowire.AppendVarint(b, uint64(int64(response.Attempts)))
+	}
+	return runtime.NewPacket(b), nil
+}
+
+// IsProtobufIP checks if a given IP payload is encoded with Protocol Buffers
+func IsProtobufIP(ip [][]byte) bool {
+	return len(ip) > 1 && len(ip[1]) > 0 && ip[1][0] == protobufMarker
+}
+
+// unmarshalRequestProtobuf decodes HTTPRequest message
+func unmarshalRequestProtobuf(b []byte) (*HTTPRequest, error) {
+	req := &HTTPRequest{}
+	err := consumeFields(b, func(num protowire.Number, typ protowire.Type, b []byte) (int, error) {
+		switch {
+		case num == 1 && typ == protowire.BytesType:
+			return consumeString(b, &req.ID)
+		case num == 2 && typ == protowire.BytesType:
+			return consumeString(b, &req.Method)
+		case num == 3 && typ == protowire.BytesType:
+			return consumeString(b, &req.URI)
+		case num == 4 && typ == protowire.BytesType:
+			return consumeValuesEntry(b, &req.Header)
+		case num == 5 && typ == protowire.BytesType:
+			return consumeValuesEntry(b, &req.Form)
+		case num == 6 && typ == protowire.VarintType:
+			v, n := protowire.ConsumeVarint(b)
+			req.ContentLength = int64(v)
+			return n, protowire.ParseError(n)
+		case num == 7 && typ == protowire.BytesType:
+			return consumeBytes(b, &req.Body)
+		case num == 8 && typ == protowire.BytesType:
+			return consumeString(b, &req.RemoteAddr)
+		case num == 9 && typ == protowire.BytesType:
+			return consumeString(b, &req.Host)
+		case num == 10 && typ == protowire.BytesType:
+			return consumeString(b, &req.Scheme)
+		case num == 11 && typ == protowire.BytesType:
+			req.TLS = &TLSInfo{}
+			return consumeTLSInfo(b, req.TLS)
+		case num == 12 && typ == protowire.BytesType:
+			return consumeStringEntry(b, &req.Cookies)
+		case num == 13 && typ == protowire.BytesType:
+			return consumeValuesEntry(b, &req.Query)
+		case num == 14 && typ == protowire.BytesType:
+			return consumeValuesEntry(b, &req.PostForm)
+		case num == 15 && typ == protowire.BytesType:
+			return consumeValuesEntry(b, &req.Trailer)
+		case num == 16 && typ == protowire.BytesType:
+			return consumeString(b, &req.Trace)
+		case num == 17 && typ == protowire.BytesType:
+			return consumeString(b, &req.User)
+		case num == 18 && typ == protowire.BytesType:
+			var g string
+			n, err := consumeString(b, &g)
+			req.Groups = append(req.Groups, g)
+			return n, err
+		case num == 19 && typ == protowire.BytesType:
+			var r string
+			n, err := consumeString(b, &r)
+			req.Roles = append(req.Roles, r)
+			return n, err
+		case num == 20 && typ == protowire.BytesType:
+			req.Bot = &BotInfo{}
+			return consumeBotInfo(b, req.Bot)
+		case num == 21 && typ == protowire.BytesType:
+			req.Geo = &GeoInfo{}
+			return consumeGeoInfo(b, req.Geo)
+		case num == 22 && typ == protowire.BytesType:
+			req.Route = &RouteInfo{}
+			return consumeRouteInfo(b, req.Route)
+		}
+		n := protowire.ConsumeFieldValue(num, typ, b)
+		return n, protowire.ParseError(n)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return req, nil
+}
+
+// unmarshalResponseProtobuf decodes HTTPResponse message
+func unmarshalResponseProtobuf(b []byte) (*HTTPResponse, error) {
+	res := &HTTPResponse{}
+	err := consumeFields(b, func(num protowire.Number, typ protowire.Type, b []byte) (int, error) {
+		switch {
+		case num == 1 && typ == protowire.BytesType:
+			return consumeString(b, &res.ID)
+		case num == 2 && typ == protowire.VarintType:
+			v, n := protowire.ConsumeVarint(b)
+			res.StatusCode = int(int32(v))
+			return n, protowire.ParseError(n)
+		case num == 3 && typ == protowire.BytesType:
+			return consumeValuesEntry(b, &res.Header)
+		case num == 4 && typ == protowire.BytesType:
+			return consumeBytes(b, &res.Body)
+		case num == 5 && typ == protowire.BytesType:
+			c := &Cookie{}
+			res.Cookies = append(res.Cookies, c)
+			return consumeCookie(b, c)
+		case num == 6 && typ == protowire.BytesType:
+			return consumeValuesEntry(b, &res.Trailer)
+		case num == 7 && typ == protowire.BytesType:
+			return consumeString(b, &res.Trace)
+		case num == 8 && typ == protowire.BytesType:
+			return consumeString(b, &res.URL)
+		case num == 9 && typ == protowire.BytesType:
+			var r string
+			n, err := consumeString(b, &r)
+			res.Redirects = append(res.Redirects, r)
+			return n, err
+		case num == 10 && typ == protowire.VarintType:
+			v, n := protowire.ConsumeVarint(b)
+			res.Attempts = int(int32(v))
+			return n, protowire.ParseError(n)
+		}
+		n := protowire.ConsumeFieldValue(num, typ, b)
+		return n, protowire.ParseError(n)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return res, nil
+}
+
+// consumeFields iterates over message fields calling a given function for every field value
+func consumeFields(b []byte, f func(protowire.Number, protowire.Type, []byte) (int, error)) error {
+	for len(b) > 0 {
+		num, typ, n := protowire.ConsumeTag(b)
+		if n < 0 {
+			return protowire.ParseError(n)
+		}
+		b = b[n:]
+		n, err := f(num, typ, b)
+		if err != nil {
+			return err
+		}
+		b = b[n:]
+	}
+	return nil
+}
+
+func consumeString(b []byte, v *string) (int, error) {
+	s, n := protowire.ConsumeString(b)
+	if n < 0 {
+		return 0, protowire.ParseError(n)
+	}
+	*v = s
+	return n, nil
+}
+
+func consumeDouble(b []byte, v *float64) (int, error) {
+	bits, n := protowire.ConsumeFixed64(b)
+	if n < 0 {
+		return 0, protowire.ParseError(n)
+	}
+	*v = math.Float64frombits(bits)
+	return n, nil
+}
+
+func consumeBytes(b []byte, v *[]byte) (int, error) {
+	data, n := protowire.ConsumeBytes(b)
+	if n < 0 {
+		return 0, protowire.ParseError(n)
+	}
+	*v = append([]byte(nil), data...)
+	return n, nil
+}
+
+// consumeTLSInfo decodes TLSInfo message
+func consumeTLSInfo(b []byte, info *TLSInfo) (int, error) {
+	msg, n := protowire.ConsumeBytes(b)
+	if n < 0 {
+		return 0, protowire.ParseError(n)
+	}
+	err := consumeFields(msg, func(num protowire.Number, typ protowire.Type, b []byte) (int, error) {
+		switch {
+		case num == 1 && typ == protowire.BytesType:
+			return consumeString(b, &info.Version)
+		case num == 2 && typ == protowire.BytesType:
+			return consumeString(b, &info.CipherSuite)
+		case num == 3 && typ == protowire.BytesType:
+			return consumeString(b, &info.PeerSubject)
+		case num == 4 && typ == protowire.BytesType:
+			return consumeString(b, &info.JA3)
+		case num == 5 && typ == protowire.BytesType:
+			return consumeString(b, &info.JA4)
+		}
+		n := protowire.ConsumeFieldValue(num, typ, b)
+		return n, protowire.ParseError(n)
+	})
+	return n, err
+}
+
+// consumeBotInfo decodes BotInfo message
+func consumeBotInfo(b []byte, info *BotInfo) (int, error) {
+	msg, n := protowire.ConsumeBytes(b)
+	if n < 0 {
+		return 0, protowire.ParseError(n)
+	}
+	err := consumeFields(msg, func(num protowire.Number, typ protowire.Type, b []byte) (int, error) {
+		switch {
+		case num == 1 && typ == protowire.VarintType:
+			v, n := protowire.ConsumeVarint(b)
+			info.Score = int(int32(v))
+			return n, protowire.ParseError(n)
+		case num == 2 && typ == protowire.BytesType:
+			return consumeString(b, &info.Class)
+		case num == 3 && typ == protowire.BytesType:
+			return consumeString(b, &info.Name)
+		case num == 4 && typ == protowire.BytesType:
+			var r string
+			n, err := consumeString(b, &r)
+			info.Reasons = append(info.Reasons, r)
+			return n, err
+		}
+		n := protowire.ConsumeFieldValue(num, typ, b)
+		return n, protowire.ParseError(n)
+	})
+	return n, err
+}
+
+// consumeGeoInfo decodes GeoInfo message
+func consumeGeoInfo(b []byte, info *GeoInfo) (int, error) {
+	msg, n := protowire.ConsumeBytes(b)
+	if n < 0 {
+		return 0, protowire.ParseError(n)
+	}
+	err := consumeFields(msg, func(num protowire.Number, typ protowire.Type, b []byte) (int, error) {
+		switch {
+		case num == 1 && typ == protowire.BytesType:
+			return consumeString(b, &info.Country)
+		case num == 2 && typ == protowire.BytesType:
+			return consumeString(b, &info.CountryName)
+		case num == 3 && typ == protowire.BytesType:
+			return consumeString(b, &info.Continent)
+		case num == 4 && typ == protowire.BytesType:
+			return consumeString(b, &info.Region)
+		case num == 5 && typ == protowire.BytesType:
+			return consumeString(b, &info.City)
+		case num == 6 && typ == protowire.BytesType:
+			return consumeString(b, &info.PostalCode)
+		case num == 7 && typ == protowire.Fixed64Type:
+			return consumeDouble(b, &info.Latitude)
+		case num == 8 && typ == protowire.Fixed64Type:
+			return consumeDouble(b, &info.Longitude)
+		case num == 9 && typ == protowire.BytesType:
+			return consumeString(b, &info.TimeZone)
+		case num == 10 && typ == protowire.VarintType:
+			v, n := protowire.ConsumeVarint(b)
+			info.ASN = uint32(v)
+			return n, protowire.ParseError(n)
+		case num == 11 && typ == protowire.BytesType:
+			return consumeString(b, &info.ASOrg)
+		}
+		n := protowire.ConsumeFieldValue(num, typ, b)
+		return n, protowire.ParseError(n)
+	})
+	return n, err
+}
+
+// consumeRouteInfo decodes RouteInfo message
+func consumeRouteInfo(b []byte, info *RouteInfo) (int, error) {
+	msg, n := protowire.ConsumeBytes(b)
+	if n < 0 {
+		return 0, protowire.ParseError(n)
+	}
+	err := consumeFields(msg, func(num protowire.Number, typ protowire.Type, b []byte) (int, error) {
+		switch {
+		case num == 1 && typ == protowire.BytesType:
+			return consumeString(b, &info.Pattern)
+		case num == 2 && typ == protowire.VarintType:
+			v, n := protowire.ConsumeVarint(b)
+			info.Timeout = Duration(time.Duration(int64(v)) * time.Millisecond)
+			return n, protowire.ParseError(n)
+		case num == 3 && typ == protowire.VarintType:
+			v, n := protowire.ConsumeVarint(b)
+			info.AuthRequired = v != 0
+			return n, protowire.ParseError(n)
+		case num == 4 && typ == protowire.BytesType:
+			return consumeString(b, &info.RateClass)
+		case num == 5 && typ == protowire.BytesType:
+			return consumeStringEntry(b, &info.Labels)
+		}
+		n := protowire.ConsumeFieldValue(num, typ, b)
+		return n, protowire.ParseError(n)
+	})
+	return n, err
+}
+
+// consumeCookie decodes Cookie message
+func consumeCookie(b []byte, c *Cookie) (int, error) {
+	msg, n := protowire.ConsumeBytes(b)
+	if n < 0 {
+		return 0, protowire.ParseError(n)
+	}
+	err := consumeFields(msg, func(num protowire.Number, typ protowire.Type, b []byte) (int, error) {
+		switch {
+		case num == 1 && typ == protowire.BytesType:
+			return consumeString(b, &c.Name)
+		case num == 2 && typ == protowire.BytesType:
+			return consumeString(b, &c.Value)
+		case num == 3 && typ == protowire.BytesType:
+			return consumeString(b, &c.Path)
+		case num == 4 && typ == protowire.BytesType:
+			return consumeString(b, &c.Domain)
+		case num == 9 && typ == protowire.BytesType:
+			return consumeString(b, &c.SameSite)
+		case typ == protowire.VarintType && num >= 5 && num <= 8:
+			v, n := protowire.ConsumeVarint(b)
+			switch num {
+			case 5:
+				c.Expires = time.Unix(int64(v), 0)
+			case 6:
+				c.MaxAge = int(int32(v))
+			case 7:
+				c.Secure = v != 0
+			case 8:
+				c.HttpOnly = v != 0
+			}
+			return n, protowire.ParseError(n)
+		}
+		n := protowire.ConsumeFieldValue(num, typ, b)
+		return n, protowire.ParseError(n)
+	})
+	return n, err
+}
+
+// consumeStringEntry decodes a single map<string, string> entry into a given map
+func consumeStringEntry(b []byte, m *map[string]string) (int, error) {
+	entry, n := protowire.ConsumeBytes(b)
+	if n < 0 {
+		return 0, protowire.ParseError(n)
+	}
+	var key, value string
+	err := consumeFields(entry, func(num protowire.Number, typ protowire.Type, b []byte) (int, error) {
+		switch {
+		case num == 1 && typ == protowire.BytesType:
+			return consumeString(b, &key)
+		case num == 2 && typ == protowire.BytesType:
+			return consumeString(b, &value)
+		}
+		n := protowire.ConsumeFieldValue(num, typ, b)
+		return n, protowire.ParseError(n)
+	})
+	if err != nil {
+		return 0, err
+	}
+	if *m == nil {
+		*m = make(map[string]string)
+	}
+	(*m)[key] = value
+	return n, nil
+}
+
+// consumeValuesEntry decodes a single map<string, Values> entry into a given map
+func consumeValuesEntry(b []byte, m *map[string][]string) (int, error) {
+	entry, n := protowire.ConsumeBytes(b)
+	if n < 0 {
+		return 0, protowire.ParseError(n)
+	}
+	var (
+		key    string
+		values []string
+	)
+	err := consumeFields(entry, func(num protowire.Number, typ protowire.Type, b []byte) (int, error) {
+		switch {
+		case num == 1 && typ == protowire.BytesType:
+			return consumeString(b, &key)
+		case num == 2 && typ == protowire.BytesType:
+			msg, n := protowire.ConsumeBytes(b)
+			if n < 0 {
+				return 0, protowire.ParseError(n)
+			}
+			err := consumeFields(msg, func(num protowire.Number, typ protowire.Type, b []byte) (int, error) {
+				if num == 1 && typ == protowire.BytesType {
+					var v string
+					n, err := consumeString(b, &v)
+					values = append(values, v)
+					return n, err
+				}
+				n := protowire.ConsumeFieldValue(num, typ, b)
+				return n, protowire.ParseError(n)
+			})
+			return n, err
+		}
+		n := protowire.ConsumeFieldValue(num, typ, b)
+		return n, protowire.ParseError(n)
+	})
+	if err != nil {
+		return 0, err
+	}
+	if *m == nil {
+		*m = make(map[string][]string)
+	}
+	(*m)[key] = append((*m)[key], values...)
+	return n, nil
+}
+
+// appendCookie encodes Cookie message
+func appendCookie(b []byte, c *Cookie) []byte {
+	b = appendString(b, 1, c.Name)
+	b = appendString(b, 2, c.Value)
+	b = appendString(b, 3, c.Path)
+	b = appendString(b, 4, c.Domain)
+	if !c.Expires.IsZero() {
+		b = protowire.AppendTag(b, 5, protowire.VarintType)
+		b = protowire.AppendVarint(b, uint64(c.Expires.Unix()))
+	}
+	if c.MaxAge != 0 {
+		b = protowire.AppendTag(b, 6, protowire.VarintType)
+		b = protowire.AppendVarint(b, uint64(int64(c.MaxAge)))
+	}
+	if c.Secure {
+		b = protowire.AppendTag(b, 7, protowire.VarintType)
+		b = protowire.AppendVarint(b, 1)
+	}
+	if c.HttpOnly {
+		b = protowire.AppendTag(b, 8, protowire.VarintType)
+		b = protowire.AppendVarint(b, 1)
+	}
+	return appendString(b, 9, c.SameSite)
+}
+
+func appendString(b []byte, num protowire.Number, v string) []byte {
+	if v == "" {
+		return b
+	}
+	b = protowire.AppendTag(b, num, protowire.BytesType)
+	return protowire.AppendString(b, v)
+}
+
+// appendDouble encodes a non-zero double field
+func appendDouble(b []byte, num protowire.Number, v float64) []byte {
+	if v == 0 {
+		return b
+	}
+	b = protowire.AppendTag(b, num, protowire.Fixed64Type)
+	return protowire.AppendFixed64(b, math.Float64bits(v))
+}
+
+// appendValuesMap encodes map<string, Values> field
+func appendValuesMap(b []byte, num protowire.Number, m map[string][]string) []byte {
+	for k, values := range m {
+		var msg []byte
+		for _, v := range values {
+			msg = protowire.AppendTag(msg, 1, protowire.BytesType)
+			msg = protowire.AppendString(msg, v)
+		}
+		var entry []byte
+		entry = appendString(entry, 1, k)
+		entry = protowire.AppendTag(entry, 2, protowire.BytesType)
+		entry = protowire.AppendBytes(entry, msg)
+
+		b = protowire.AppendTag(b, num, protowire.BytesType)
+		b = protowire.AppendBytes(b, entry)
+	}
+	return b
+}
+
+// unmarshalProtobuf decodes Protocol Buffers payload into known IP structures
+func unmarshalProtobuf(payload []byte, v interface{}) (err error) {
+	switch t := v.(type) {
+	case **HTTPRequest:
+		*t, err = unmarshalRequestProtobuf(payload)
+	case **HTTPResponse:
+		*t, err = unmarshalResponseProtobuf(payload)
+	default:
+		err = fmt.Errorf("protobuf codec doesn't support %T", v)
+	}
+	return err
+}
@@ -0,0 +1,91 @@
+package utils
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+// JSON2Values converts a JSON object into url.Values. Arrays become multiple values,
+// other values are formatted as strings. Numbers keep their original notation.
+func JSON2Values(data []byte) (url.Values, error) {
+	var raw map[string]interface{}
+	d := json.NewDecoder(bytes.NewReader(data))
+	d.UseNumber()
+	if err := d.Decode(&raw); err != nil {
+		return nil, err
+	}
+	if _, err := d.Token(); err != io.EOF {
+		return nil, fmt.Errorf("invalid character after top-level value")
+	}
+	values := make(url.Values, len(raw))
+	for k, v := range raw {
+		switch t := v.(type) {
+		case []interface{}:
+			for _, item := range t {
+				values.Add(k, formatValue(item))
+			}
+		default:
+			values.Add(k, formatValue(t))
+		}
+	}
+	return values, nil
+}
+
+// Values2Map converts url.Values into a map suitable for JSON encoding. Keys with a single
+// value map to strings, repeated keys and keys with "[]" suffix map to arrays. Values of
+// "a" and "a[]" are merged into one array, values of "a" first.
+func Values2Map(values url.Values) map[string]interface{} {
+	res := make(map[string]interface{}, len(values))
+	for k, v := range values {
+		if strings.HasSuffix(k, "[]") {
+			name := strings.TrimSuffix(k, "[]")
+			merged := make([]string, 0, len(values[name])+len(v))
+			res[name] = append(append(merged, values[name]...), v...)
+			continue
+		}
+		if _, ok := values[k+"[]"]; ok {
+			// Merged with values of k[]
+			continue
+		}
+		if len(v) == 1 {
+			res[k] = v[0]
+			continue
+		}
+		res[k] = v
+	}
+	return res
+}
+
+// ParseQuery parses a raw query string (with or without leading "?") or a full URL into url.Values
+func ParseQuery(s string) (url.Values, error) {
+	s = strings.TrimSpace(s)
+	if strings.Contains(s, "://") || strings.HasPrefix(s, "/") {
+		u, err := url.Parse(s)
+		if err != nil {
+			return nil, err
+		}
+		s = u.RawQuery
+	}
+	return url.ParseQuery(strings.TrimPrefix(s, "?"))
+}
+
+func formatValue(v interface{}) string {
+	switch t := v.(type) {
+	case nil:
+		return ""
+	case string:
+		return t
+	case float64:
+		return strconv.FormatFloat(t, 'f', -1, 64)
+	case json.Number:
+		return t.String()
+	default:
+		data, _ := json.Marshal(t)
+		return string(data)
+	}
+}
@@ -0,0 +1,72 @@
+package utils
+
+import (
+	"net/url"
+	"reflect"
+	"testing"
+)
+
+func TestJSON2Values(t *testing.T) {
+	tests := []struct {
+		name string
+		data string
+		want url.Values
+	}{
+		{"large integers", `{"page":1000000,"id":12345678901234567}`, url.Values{"page": {"1000000"}, "id": {"12345678901234567"}}},
+		{"decimals", `{"price":0.1,"rate":1.5e-7}`, url.Values{"price": {"0.1"}, "rate": {"1.5e-7"}}},
+		{"arrays", `{"id":[1,2,9007199254740993]}`, url.Values{"id": {"1", "2", "9007199254740993"}}},
+		{"other values", `{"q":"go","none":null,"ok":true,"obj":{"n":10000000}}`, url.Values{"q": {"go"}, "none": {""}, "ok": {"true"}, "obj": {`{"n":10000000}`}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := JSON2Values([]byte(tt.data))
+			if err != nil {
+				t.Fatalf("JSON2Values() error = %v", err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("JSON2Values() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestJSON2ValuesEncode(t *testing.T) {
+	values, err := JSON2Values([]byte(`{"page":1000000,"id":12345678901234567}`))
+	if err != nil {
+		t.Fatalf("JSON2Values() error = %v", err)
+	}
+	if got, want := values.Encode(), "id=12345678901234567&page=1000000"; got != want {
+		t.Errorf("Encode() = %q, want %q", got, want)
+	}
+}
+
+func TestJSON2ValuesInvalid(t *testing.T) {
+	for _, data := range []string{`[1,2]`, `{"a":1} {"b":2}`, `{"a":`} {
+		if _, err := JSON2Values([]byte(data)); err == nil {
+			t.Errorf("JSON2Values(%s) expected error", data)
+		}
+	}
+}
+
+func TestValues2Map(t *testing.T) {
+	tests := []struct {
+		name   string
+		values url.Values
+		want   map[string]interface{}
+	}{
+		{"single", url.Values{"q": {"go"}}, map[string]interface{}{"q": "go"}},
+		{"repeated", url.Values{"tag": {"a", "b"}}, map[string]interface{}{"tag": []string{"a", "b"}}},
+		{"array suffix", url.Values{"id[]": {"1"}}, map[string]interface{}{"id": []string{"1"}}},
+		{"plain and array keys", url.Values{"a": {"1", "2"}, "a[]": {"3"}, "b[]": {"4"}, "b": {"5"}}, map[string]interface{}{"a": []string{"1", "2", "3"}, "b": []string{"5", "4"}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Map iteration order is random, repeat to catch order dependent results
+			for i := 0; i < 20; i++ {
+				if got := Values2Map(tt.values); !reflect.DeepEqual(got, tt.want) {
+					t.Fatalf("Values2Map() = %v, want %v", got, tt.want)
+				}
+			}
+		})
+	}
+}
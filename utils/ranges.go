@@ -0,0 +1,215 @@
+package utils
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"mime/multipart"
+	"net/http"
+	"net/textproto"
+	"strconv"
+	"strings"
+)
+
+// ErrRangeNotSatisfiable is returned when none of requested ranges overlaps the content
+var ErrRangeNotSatisfiable = errors.New("requested range not satisfiable")
+
+// ByteRange is an inclusive range of content bytes
+type ByteRange struct {
+	Start int64
+	End   int64
+}
+
+// Length returns number of bytes in the range
+func (r ByteRange) Length() int64 {
+	return r.End - r.Start + 1
+}
+
+// ContentRange formats Content-Range header value for the range
+func (r ByteRange) ContentRange(size int64) string {
+	return fmt.Sprintf("bytes %d-%d/%d", r.Start, r.End, size)
+}
+
+// ParseRange parses Range header and returns ranges satisfiable for content of a given size
+func ParseRange(header string, size int64) ([]ByteRange, error) {
+	if !strings.HasPrefix(header, "bytes=") {
+		return nil, fmt.Errorf("invalid range unit in %q", header)
+	}
+	var ranges []ByteRange
+	for _, spec := range strings.Split(header[len("bytes="):], ",") {
+		spec = strings.TrimSpace(spec)
+		if spec == "" {
+			continue
+		}
+		i := strings.Index(spec, "-")
+		if i < 0 {
+			return nil, fmt.Errorf("invalid range %q", spec)
+		}
+		first, last := strings.TrimSpace(spec[:i]), strings.TrimSpace(spec[i+1:])
+		var r ByteRange
+		if first == "" {
+			// Suffix range: last N bytes
+			n, err := strconv.ParseInt(last, 10, 64)
+			if err != nil || n < 0 {
+				return nil, fmt.Errorf("invalid range %q", spec)
+			}
+			if n == 0 || size == 0 {
+				continue
+			}
+			if n > size {
+				n = size
+			}
+			r = ByteRange{Start: size - n, End: size - 1}
+		} else {
+			start, err := strconv.ParseInt(first, 10, 64)
+			if err != nil || start < 0 {
+				return nil, fmt.Errorf("invalid range %q", spec)
+			}
+			if start >= size {
+				continue
+			}
+			r = ByteRange{Start: start, End: size - 1}
+			if last != "" {
+				end, err := strconv.ParseInt(last, 10, 64)
+				if err != nil || end < start {
+					return nil, fmt.Errorf("invalid range %q", spec)
+				}
+				if end < size {
+					r.End = end
+				}
+			}
+		}
+		ranges = append(ranges, r)
+	}
+	if len(ranges) == 0 {
+		return nil, ErrRangeNotSatisfiable
+	}
+	return ranges, nil
+}
+
+// FormatRange builds Range header value for a given list of ranges
+func FormatRange(ranges []ByteRange) string {
+	specs := make([]string, len(ranges))
+	for i, r := range ranges {
+		if r.End < 0 {
+			specs[i] = fmt.Sprintf("%d-", r.Start)
+		} else {
+			specs[i] = fmt.Sprintf("%d-%d", r.Start, r.End)
+		}
+	}
+	return "bytes=" + strings.Join(specs, ",")
+}
+
+// ParseContentRange parses Content-Range header. Size is -1 when unknown ('*').
+func ParseContentRange(header string) (ByteRange, int64, error) {
+	var r ByteRange
+	if !strings.HasPrefix(header, "bytes ") {
+		return r, 0, fmt.Errorf("invalid content range %q", header)
+	}
+	parts := strings.SplitN(header[len("bytes "):], "/", 2)
+	if len(parts) != 2 {
+		return r, 0, fmt.Errorf("invalid content range %q", header)
+	}
+	size := int64(-1)
+	if parts[1] != "*" {
+		var err error
+		if size, err = strconv.ParseInt(parts[1], 10, 64); err != nil {
+			return r, 0, fmt.Errorf("invalid content range %q", header)
+		}
+	}
+	if parts[0] == "*" {
+		return ByteRange{Start: -1, End: -1}, size, nil
+	}
+	bounds := strings.SplitN(parts[0], "-", 2)
+	if len(bounds) != 2 {
+		return r, 0, fmt.Errorf("invalid content range %q", header)
+	}
+	var err1, err2 error
+	r.Start, err1 = strconv.ParseInt(bounds[0], 10, 64)
+	r.End, err2 = strconv.ParseInt(bounds[1], 10, 64)
+	if err1 != nil || err2 != nil || r.End < r.Start {
+		return r, 0, fmt.Errorf("invalid content range %q", header)
+	}
+	return r, size, nil
+}
+
+// EncodeByteranges renders multipart/byteranges body for given ranges of a content
+// and returns it with the corresponding Content-Type
+func EncodeByteranges(content []byte, ranges []ByteRange, contentType string) ([]byte, string, error) {
+	var buf bytes.Buffer
+	w := multipart.NewWriter(&buf)
+	size := int64(len(content))
+	for _, r := range ranges {
+		h := make(textproto.MIMEHeader)
+		if contentType != "" {
+			h.Set("Content-Type", contentType)
+		}
+		h.Set("Content-Range", r.ContentRange(size))
+		part, err := w.CreatePart(h)
+		if err != nil {
+			return nil, "", err
+		}
+		if _, err := part.Write(content[r.Start : r.End+1]); err != nil {
+			return nil, "", err
+		}
+	}
+	if err := w.Close(); err != nil {
+		return nil, "", err
+	}
+	return buf.Bytes(), "multipart/byteranges; boundary=" + w.Boundary(), nil
+}
+
+// RangeResponse answers a Range request with partial content of a given full response:
+// 206 with a single range or multipart/byteranges, or 416 if ranges aren't satisfiable.
+// Responses other than 200 and invalid Range headers leave the response as is.
+func RangeResponse(rangeHeader string, resp *HTTPResponse) *HTTPResponse {
+	if rangeHeader == "" || resp.StatusCode != http.StatusOK {
+		return resp
+	}
+	size := int64(len(resp.Body))
+	ranges, err := ParseRange(rangeHeader, size)
+	if err == ErrRangeNotSatisfiable {
+		return NewResponse(http.StatusRequestedRangeNotSatisfiable).
+			WithID(resp.ID).
+			WithHeader("Content-Range", fmt.Sprintf("bytes */%d", size))
+	}
+	if err != nil || rangesTotal(ranges) > size {
+		// Overlapping ranges asking for more than the content itself are ignored
+		return resp
+	}
+
+	partial := &HTTPResponse{
+		ID:         resp.ID,
+		StatusCode: http.StatusPartialContent,
+		Header:     make(map[string][]string, len(resp.Header)),
+		Cookies:    resp.Cookies,
+	}
+	for k, v := range resp.Header {
+		partial.Header[k] = v
+	}
+	h := http.Header(partial.Header)
+	h.Set("Accept-Ranges", "bytes")
+	if len(ranges) == 1 {
+		r := ranges[0]
+		partial.Body = resp.Body[r.Start : r.End+1]
+		h.Set("Content-Range", r.ContentRange(size))
+		h.Set("Content-Length", strconv.FormatInt(r.Length(), 10))
+		return partial
+	}
+	body, contentType, err := EncodeByteranges(resp.Body, ranges, h.Get("Content-Type"))
+	if err != nil {
+		return resp
+	}
+	partial.Body = body
+	h.Set("Content-Type", contentType)
+	h.Set("Content-Length", strconv.Itoa(len(body)))
+	return partial
+}
+
+func rangesTotal(ranges []ByteRange) int64 {
+	var total int64
+	for _, r := range ranges {
+		total += r.Length()
+	}
+	return total
+}
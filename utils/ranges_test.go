@@ -0,0 +1,59 @@
+package utils
+
+import (
+	"io"
+	"mime"
+	"mime/multipart"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func TestRangeResponse(t *testing.T) {
+	content := "0123456789abcdefghij"
+	full := NewResponse(http.StatusOK).WithID("1").WithBody("application/pdf", []byte(content))
+
+	single := RangeResponse("bytes=2-5", full)
+	if single.StatusCode != http.StatusPartialContent || string(single.Body) != "2345" {
+		t.Errorf("single range = %d %q", single.StatusCode, single.Body)
+	}
+	if cr := single.GetHeader("Content-Range"); cr != "bytes 2-5/20" {
+		t.Errorf("Content-Range = %q", cr)
+	}
+
+	multi := RangeResponse("bytes=0-1, 10-12, -3", full)
+	if multi.StatusCode != http.StatusPartialContent || multi.ID != "1" {
+		t.Fatalf("multiple ranges = %d (id %s)", multi.StatusCode, multi.ID)
+	}
+	mediaType, params, err := mime.ParseMediaType(multi.GetHeader("Content-Type"))
+	if err != nil || mediaType != "multipart/byteranges" {
+		t.Fatalf("Content-Type = %q", multi.GetHeader("Content-Type"))
+	}
+	r := multipart.NewReader(strings.NewReader(string(multi.Body)), params["boundary"])
+	want := []struct{ contentRange, body string }{
+		{"bytes 0-1/20", "01"},
+		{"bytes 10-12/20", "abc"},
+		{"bytes 17-19/20", "hij"},
+	}
+	for i, w := range want {
+		part, err := r.NextPart()
+		if err != nil {
+			t.Fatalf("part %d: %v", i, err)
+		}
+		body, _ := io.ReadAll(part)
+		if part.Header.Get("Content-Range") != w.contentRange || part.Header.Get("Content-Type") != "application/pdf" || string(body) != w.body {
+			t.Errorf("part %d = %v %q, want %s %q", i, part.Header, body, w.contentRange, w.body)
+		}
+	}
+	if _, err := r.NextPart(); err != io.EOF {
+		t.Errorf("expected %d parts, error = %v", len(want), err)
+	}
+
+	unsatisfiable := RangeResponse("bytes=50-60", full)
+	if unsatisfiable.StatusCode != http.StatusRequestedRangeNotSatisfiable || unsatisfiable.GetHeader("Content-Range") != "bytes */20" {
+		t.Errorf("unsatisfiable range = %d %q", unsatisfiable.StatusCode, unsatisfiable.GetHeader("Content-Range"))
+	}
+	if ignored := RangeResponse("items=0-1", full); ignored != full {
+		t.Error("invalid Range header changed the response")
+	}
+}
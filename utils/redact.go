@@ -0,0 +1,236 @@
+package utils
+
+import (
+	"mime"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+	"sync"
+)
+
+// Redacted replaces values of sensitive headers, parameters and cookies
+const Redacted = "[REDACTED]"
+
+// redactedURLValue replaces secrets inside of URLs where brackets would be escaped
+const redactedURLValue = "REDACTED"
+
+// defaultRedactedHeaders are always redacted
+var defaultRedactedHeaders = []string{
+	"Authorization",
+	"Proxy-Authorization",
+	"Cookie",
+	"Set-Cookie",
+	"X-Api-Key",
+	"X-Auth-Token",
+}
+
+// defaultRedactedParams are query and form parameters always redacted
+var defaultRedactedParams = []string{
+	"access_token",
+	"api_key",
+	"apikey",
+	"client_secret",
+	"password",
+	"refresh_token",
+	"secret",
+	"token",
+}
+
+var (
+	userinfoPattern = regexp.MustCompile(`(://[^/\s:@]+:)[^/\s@]+@`)
+	schemePattern   = regexp.MustCompile(`(?i)\b(bearer|basic)\s+[A-Za-z0-9\-._~+/]+=*`)
+)
+
+// RedactOptions extend the lists of redacted headers and parameters
+type RedactOptions struct {
+	Headers []string `json:"headers"` // Header names redacted on top of the defaults, i.e. X-Session-Id
+	Params  []string `json:"params"`  // Query and form parameters redacted on top of the defaults, i.e. sig
+}
+
+// Redactor hides secrets in headers, parameters, cookies, URLs and free text
+// before they reach LOG, ERR and DEBUG ports, access logs and HAR exports.
+// It's safe for concurrent use and can be reconfigured at runtime.
+type Redactor struct {
+	mu      sync.RWMutex
+	headers map[string]bool // Canonical header names
+	params  map[string]bool // Lower case parameter names
+	pattern *regexp.Regexp  // Matches name=value pairs of params in text
+}
+
+// Redaction is the redactor used by loggers, dumps, errors and HAR export.
+// Components configure it with redact section of options.
+var Redaction = NewRedactor()
+
+// NewRedactor creates a redactor of the default headers and parameters
+func NewRedactor() *Redactor {
+	r := &Redactor{}
+	r.Apply(&RedactOptions{})
+	return r
+}
+
+// Apply replaces extra headers and parameters, the defaults are always kept
+func (r *Redactor) Apply(o *RedactOptions) {
+	headers := make(map[string]bool)
+	for _, name := range append(defaultRedactedHeaders, o.Headers...) {
+		headers[http.CanonicalHeaderKey(name)] = true
+	}
+	params := make(map[string]bool)
+	var names []string
+	for _, name := range append(defaultRedactedParams, o.Params...) {
+		name = strings.ToLower(name)
+		if name != "" && !params[name] {
+			params[name] = true
+			names = append(names, regexp.QuoteMeta(name))
+		}
+	}
+	pattern := regexp.MustCompile(`(?i)((?:^|[?&;\s"'])(?:` + strings.Join(names, "|") + `)=)[^&;\s"']*`)
+
+	r.mu.Lock()
+	r.headers, r.params, r.pattern = headers, params, pattern
+	r.mu.Unlock()
+}
+
+// IsSensitive reports whether a header or parameter of a given name is redacted
+func (r *Redactor) IsSensitive(name string) bool {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.headers[http.CanonicalHeaderKey(name)] || r.params[strings.ToLower(name)]
+}
+
+// Header returns a copy of headers with sensitive values redacted
+func (r *Redactor) Header(h map[string][]string) map[string][]string {
+	if h == nil {
+		return nil
+	}
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	c := make(map[string][]string, len(h))
+	for k, v := range h {
+		if r.headers[http.CanonicalHeaderKey(k)] {
+			c[k] = []string{Redacted}
+		} else {
+			c[k] = v
+		}
+	}
+	return c
+}
+
+// Values returns a copy of query or form values with sensitive ones redacted
+func (r *Redactor) Values(values map[string][]string) map[string][]string {
+	if values == nil {
+		return nil
+	}
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	c := make(map[string][]string, len(values))
+	for k, v := range values {
+		if r.params[strings.ToLower(k)] {
+			c[k] = []string{Redacted}
+		} else {
+			c[k] = v
+		}
+	}
+	return c
+}
+
+// URL redacts password of user info and sensitive query parameters keeping
+// the rest of a URL intact
+func (r *Redactor) URL(raw string) string {
+	u, err := url.Parse(raw)
+	if err != nil {
+		return r.Text(raw)
+	}
+	if _, ok := u.User.Password(); ok {
+		u.User = url.UserPassword(u.User.Username(), redactedURLValue)
+	}
+	u.RawQuery = r.Query(u.RawQuery)
+	return u.String()
+}
+
+// Query redacts sensitive parameters of a URL encoded query or form body
+// keeping their order and encoding
+func (r *Redactor) Query(query string) string {
+	if query == "" {
+		return query
+	}
+	pairs := strings.Split(query, "&")
+	r.mu.RLock()
+	for i, pair := range pairs {
+		raw := pair
+		if j := strings.Index(pair, "="); j >= 0 {
+			raw = pair[:j]
+		}
+		name := raw
+		if unescaped, err := url.QueryUnescape(raw); err == nil {
+			name = unescaped
+		}
+		if r.params[strings.ToLower(name)] {
+			pairs[i] = raw + "=" + redactedURLValue
+		}
+	}
+	r.mu.RUnlock()
+	return strings.Join(pairs, "&")
+}
+
+// Text redacts credentials of URLs, authorization schemes and name=value
+// pairs of sensitive parameters in free text, i.e. error messages
+func (r *Redactor) Text(s string) string {
+	s = userinfoPattern.ReplaceAllString(s, "${1}"+redactedURLValue+"@")
+	s = schemePattern.ReplaceAllString(s, "${1} "+Redacted)
+	r.mu.RLock()
+	pattern := r.pattern
+	r.mu.RUnlock()
+	return pattern.ReplaceAllString(s, "${1}"+Redacted)
+}
+
+// Request returns a copy of a request with secrets redacted from URI,
+// headers, parameters, cookies and URL encoded body
+func (r *Redactor) Request(req *HTTPRequest) *HTTPRequest {
+	if req == nil {
+		return nil
+	}
+	c := *req
+	c.URI = r.URL(req.URI)
+	c.Header = r.Header(req.Header)
+	c.Trailer = r.Header(req.Trailer)
+	c.Form = r.Values(req.Form)
+	c.Query = r.Values(req.Query)
+	c.PostForm = r.Values(req.PostForm)
+	if req.Cookies != nil {
+		c.Cookies = make(map[string]string, len(req.Cookies))
+		for name := range req.Cookies {
+			c.Cookies[name] = Redacted
+		}
+	}
+	if isFormBody(HeaderGet(req.Header, "Content-Type")) {
+		c.Body = []byte(r.Query(string(req.Body)))
+	}
+	return &c
+}
+
+// Response returns a copy of a response with secrets redacted from headers
+// and cookies
+func (r *Redactor) Response(resp *HTTPResponse) *HTTPResponse {
+	if resp == nil {
+		return nil
+	}
+	c := *resp
+	c.Header = r.Header(resp.Header)
+	c.Trailer = r.Header(resp.Trailer)
+	if resp.Cookies != nil {
+		c.Cookies = make([]*Cookie, len(resp.Cookies))
+		for i, cookie := range resp.Cookies {
+			cc := *cookie
+			cc.Value = Redacted
+			c.Cookies[i] = &cc
+		}
+	}
+	return &c
+}
+
+// isFormBody checks if a content type is of URL encoded form
+func isFormBody(contentType string) bool {
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	return err == nil && mediaType == "application/x-www-form-urlencoded"
+}
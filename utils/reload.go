@@ -0,0 +1,33 @@
+package utils
+
+import (
+	"io/ioutil"
+	"os"
+	"os/signal"
+	"syscall"
+)
+
+// WatchConfigFile reads a configuration file each time the component receives
+// SIGHUP and delivers its contents to be applied as options sent to a CONFIG
+// port. Read failures are logged and skipped. For an empty path nil channel is
+// returned, so receiving from it blocks forever.
+func WatchConfigFile(path string, logger *Logger) <-chan []byte {
+	if path == "" {
+		return nil
+	}
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, syscall.SIGHUP)
+	ch := make(chan []byte)
+	go func() {
+		for range signals {
+			data, err := ioutil.ReadFile(path)
+			if err != nil {
+				logger.Error("Failed to read configuration", "path", path, "error", err)
+				continue
+			}
+			logger.Info("Reloading configuration", "path", path)
+			ch <- data
+		}
+	}()
+	return ch
+}
@@ -0,0 +1,185 @@
+package utils
+
+import (
+	"errors"
+	"os"
+	"os/signal"
+	"sync"
+	"syscall"
+	"time"
+
+	zmq "github.com/pebbe/zmq4"
+)
+
+// Exit codes of components terminated by a Shutdown
+const (
+	ExitClean  = 0 // All ports were closed and pending IPs flushed
+	ExitForced = 3 // Shutdown timed out or was interrupted by a second signal
+)
+
+// Defaults of a Shutdown
+const (
+	DefaultLinger       = time.Second
+	DefaultGrace        = 5 * time.Second
+	DefaultPollInterval = 250 * time.Millisecond
+)
+
+// ErrShutdown is returned by Shutdown.Receive once the shutdown has started
+var ErrShutdown = errors.New("component is shutting down")
+
+// Shutdown coordinates termination of a component. The first SIGINT/SIGTERM
+// (or Stop call) stops intake: Receive returns ErrShutdown and Stopping
+// reports true. The component then leaves its loop and calls Exit, which
+// closes input ports, flushes output ports within Linger and terminates the
+// process. If that takes longer than Grace, or another signal arrives, the
+// process is terminated with ExitForced.
+type Shutdown struct {
+	Linger       time.Duration // How long pending IPs of output ports are delivered
+	Grace        time.Duration // How long draining may take before exit is forced
+	PollInterval time.Duration // How often Receive checks for shutdown
+
+	logger   *Logger
+	signals  chan os.Signal
+	requests chan os.Signal
+	stopping chan struct{}
+	once     sync.Once
+}
+
+// NewShutdown creates a Shutdown handling SIGINT and SIGTERM
+func NewShutdown(logger *Logger) *Shutdown {
+	s := &Shutdown{
+		Linger:       DefaultLinger,
+		Grace:        DefaultGrace,
+		PollInterval: DefaultPollInterval,
+		logger:       logger,
+		signals:      make(chan os.Signal, 1),
+		requests:     make(chan os.Signal, 1),
+		stopping:     make(chan struct{}),
+	}
+	signal.Notify(s.signals, os.Interrupt, syscall.SIGTERM)
+	go s.watch()
+	return s
+}
+
+// Signals returns the channel starting the shutdown when written to, i.e. by
+// runtime.SetupShutdownByDisconnect. Unlike OS signals, writes after the
+// shutdown has started don't force exit.
+func (s *Shutdown) Signals() chan os.Signal {
+	return s.requests
+}
+
+// Stop starts the shutdown unless it's already in progress
+func (s *Shutdown) Stop() {
+	if !s.Stopping() {
+		s.logger.Info("Shutting down")
+	}
+	s.begin()
+}
+
+// Stopping reports whether the shutdown has started
+func (s *Shutdown) Stopping() bool {
+	select {
+	case <-s.stopping:
+		return true
+	default:
+		return false
+	}
+}
+
+// Done returns a channel closed when the shutdown starts
+func (s *Shutdown) Done() <-chan struct{} {
+	return s.stopping
+}
+
+// Receive waits for an IP on a socket. Once the shutdown starts it returns
+// ErrShutdown instead of blocking.
+func (s *Shutdown) Receive(socket *zmq.Socket) ([][]byte, error) {
+	poller := zmq.NewPoller()
+	poller.Add(socket, zmq.POLLIN)
+	for !s.Stopping() {
+		sockets, err := poller.Poll(s.PollInterval)
+		if err != nil {
+			return nil, err
+		}
+		if len(sockets) > 0 {
+			return socket.RecvMessageBytes(0)
+		}
+	}
+	return nil, ErrShutdown
+}
+
+// Close closes input ports discarding anything still queued in them. Nil
+// sockets are skipped.
+func (s *Shutdown) Close(sockets ...*zmq.Socket) {
+	for _, socket := range sockets {
+		if socket != nil {
+			socket.SetLinger(0)
+			socket.Close()
+		}
+	}
+}
+
+// Drain closes outlets waiting until their queued IPs are handed to output
+// ports, which are still to be flushed. Nil outlets are skipped.
+func (s *Shutdown) Drain(outlets ...*Outlet) {
+	for _, outlet := range outlets {
+		outlet.Close()
+	}
+}
+
+// Flush closes output ports letting them deliver pending IPs within Linger.
+// The logger sink is detached first as the LOG port is usually among them.
+// Nil sockets are skipped.
+func (s *Shutdown) Flush(sockets ...*zmq.Socket) {
+	s.logger.SetSink(nil)
+	for _, socket := range sockets {
+		if socket != nil {
+			socket.SetLinger(s.Linger)
+			socket.Close()
+		}
+	}
+}
+
+// Exit starts the shutdown if needed, calls closePorts (which is expected to
+// Close inputs, Drain outlets, Flush outputs and terminate ZMQ context) and exits the process
+// with ExitClean. It never returns.
+func (s *Shutdown) Exit(closePorts func()) {
+	s.Stop()
+	closePorts()
+	s.logger.Info("Stopped")
+	os.Exit(ExitClean)
+}
+
+// begin stops intake and arms the grace timer, only once
+func (s *Shutdown) begin() {
+	s.once.Do(func() {
+		close(s.stopping)
+		grace := s.Grace
+		go func() {
+			time.Sleep(grace)
+			s.logger.Error("Shutdown timed out, forcing exit", "grace", grace.String())
+			os.Exit(ExitForced)
+		}()
+	})
+}
+
+// watch handles signals: the first one starts the shutdown, another OS signal
+// forces exit. Repeated requests through Signals are ignored.
+func (s *Shutdown) watch() {
+	for {
+		select {
+		case sig := <-s.signals:
+			if s.Stopping() {
+				s.logger.Warn("Received another signal, forcing exit", "signal", sig.String())
+				os.Exit(ExitForced)
+			}
+			s.logger.Info("Shutting down", "signal", sig.String())
+			s.begin()
+		case sig := <-s.requests:
+			if !s.Stopping() {
+				s.logger.Info("Shutting down", "signal", sig.String())
+				s.begin()
+			}
+		}
+	}
+}
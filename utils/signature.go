@@ -0,0 +1,892 @@
+package utils
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/sha512"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"fmt"
+	"math/big"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Headers carrying HTTP Message Signatures (RFC 9421) and digests (RFC 9530)
+const (
+	SignatureHeader      = "Signature"
+	SignatureInputHeader = "Signature-Input"
+	ContentDigestHeader  = "Content-Digest"
+)
+
+// Signature algorithms registered by RFC 9421
+const (
+	AlgHMACSHA256      = "hmac-sha256"
+	AlgEd25519         = "ed25519"
+	AlgECDSAP256SHA256 = "ecdsa-p256-sha256"
+	AlgECDSAP384SHA384 = "ecdsa-p384-sha384"
+	AlgRSAPSSSHA512    = "rsa-pss-sha512"
+	AlgRSAV15SHA256    = "rsa-v1_5-sha256"
+)
+
+// SignatureParams are the parameters of a signature covering a list of
+// components, serialized into Signature-Input header and @signature-params
+type SignatureParams struct {
+	Components []string // Derived components (i.e. @method) or lower case header names, parameters follow the name (i.e. @query-param;name="Pet")
+	Created    int64    // Unix time of signing, omitted if zero
+	Expires    int64    // Unix time the signature expires at, omitted if zero
+	Nonce      string
+	Algorithm  string
+	KeyID      string
+	Tag        string
+	Raw        string // Signature-Input member the parameters were parsed from, used verbatim in @signature-params
+}
+
+// String serializes parameters as an inner list of structured fields, i.e.
+// ("@method" "@target-uri");created=1618884473;keyid="test-key"
+func (p *SignatureParams) String() string {
+	var b strings.Builder
+	b.WriteByte('(')
+	for i, c := range p.Components {
+		if i > 0 {
+			b.WriteByte(' ')
+		}
+		b.WriteString(componentString(c))
+	}
+	b.WriteByte(')')
+	if p.Created != 0 {
+		b.WriteString(";created=" + strconv.FormatInt(p.Created, 10))
+	}
+	if p.Expires != 0 {
+		b.WriteString(";expires=" + strconv.FormatInt(p.Expires, 10))
+	}
+	if p.Nonce != "" {
+		b.WriteString(";nonce=" + sfString(p.Nonce))
+	}
+	if p.Algorithm != "" {
+		b.WriteString(";alg=" + sfString(p.Algorithm))
+	}
+	if p.KeyID != "" {
+		b.WriteString(";keyid=" + sfString(p.KeyID))
+	}
+	if p.Tag != "" {
+		b.WriteString(";tag=" + sfString(p.Tag))
+	}
+	return b.String()
+}
+
+// RequestSignatureBase builds the signature base of a request. Supported
+// derived components are @method, @target-uri, @authority, @scheme,
+// @request-target, @path, @query and @query-param.
+func RequestSignatureBase(req *HTTPRequest, params *SignatureParams) (string, error) {
+	u, err := requestURL(req)
+	if err != nil {
+		return "", err
+	}
+	return signatureBase(params, func(name string) (string, bool) {
+		if strings.HasPrefix(name, queryParamPrefix) {
+			return queryParam(u, unquote(name[len(queryParamPrefix):]))
+		}
+		switch name {
+		case "@method":
+			return req.Method, true
+		case "@target-uri":
+			return u.String(), true
+		case "@authority":
+			return authority(u), true
+		case "@scheme":
+			return strings.ToLower(u.Scheme), true
+		case "@request-target":
+			return u.RequestURI(), true
+		case "@path":
+			if p := u.EscapedPath(); p != "" {
+				return p, true
+			}
+			return "/", true
+		case "@query":
+			return "?" + u.RawQuery, true
+		}
+		return headerComponent(req.Header, name)
+	})
+}
+
+// ResponseSignatureBase builds the signature base of a response, @status is
+// the only derived component of responses
+func ResponseSignatureBase(resp *HTTPResponse, params *SignatureParams) (string, error) {
+	return signatureBase(params, func(name string) (string, bool) {
+		if name == "@status" {
+			return strconv.Itoa(resp.StatusCode), true
+		}
+		return headerComponent(resp.Header, name)
+	})
+}
+
+// signatureBase joins covered components and signature parameters
+func signatureBase(params *SignatureParams, value func(name string) (string, bool)) (string, error) {
+	var b strings.Builder
+	for _, name := range params.Components {
+		if name == "@signature-params" {
+			return "", fmt.Errorf("@signature-params can't be a covered component")
+		}
+		v, ok := value(name)
+		if !ok {
+			return "", fmt.Errorf("component %s is missing or not supported", name)
+		}
+		b.WriteString(componentString(name) + ": " + v + "\n")
+	}
+	// Verified signatures are checked against the parameters as they were sent
+	serialized := params.Raw
+	if serialized == "" {
+		serialized = params.String()
+	}
+	b.WriteString(`"@signature-params": ` + serialized)
+	return b.String(), nil
+}
+
+// queryParamPrefix starts @query-param components with their name parameter
+const queryParamPrefix = `@query-param;name=`
+
+// queryParam returns the value of a query parameter percent-encoded as in
+// @query-param components. Repeated parameters aren't supported.
+func queryParam(u *url.URL, name string) (string, bool) {
+	values, err := url.ParseQuery(u.RawQuery)
+	if err != nil || len(values[name]) != 1 {
+		return "", false
+	}
+	return strings.ReplaceAll(url.QueryEscape(values[name][0]), "+", "%20"), true
+}
+
+// componentString serializes a component identifier, a quoted name followed
+// by its parameters
+func componentString(c string) string {
+	if i := strings.IndexByte(c, ';'); i > 0 {
+		return sfString(c[:i]) + c[i:]
+	}
+	return sfString(c)
+}
+
+// headerComponent returns values of a header field trimmed and combined
+func headerComponent(h map[string][]string, name string) (string, bool) {
+	if strings.HasPrefix(name, "@") {
+		return "", false
+	}
+	values := HeaderValues(h, name)
+	if len(values) == 0 {
+		return "", false
+	}
+	trimmed := make([]string, len(values))
+	for i, v := range values {
+		trimmed[i] = strings.TrimSpace(v)
+	}
+	return strings.Join(trimmed, ", "), true
+}
+
+// requestURL resolves URI of a request against its host and scheme
+func requestURL(req *HTTPRequest) (*url.URL, error) {
+	u, err := url.Parse(req.URI)
+	if err != nil {
+		return nil, err
+	}
+	if u.Host == "" {
+		u.Host = req.Host
+		u.Scheme = req.Scheme
+		if u.Scheme == "" {
+			u.Scheme = "http"
+		}
+	}
+	return u, nil
+}
+
+// authority returns lower case host of a URL without the default port
+func authority(u *url.URL) string {
+	host := strings.ToLower(u.Host)
+	scheme := strings.ToLower(u.Scheme)
+	if scheme == "http" && strings.HasSuffix(host, ":80") || scheme == "https" && strings.HasSuffix(host, ":443") {
+		host = host[:strings.LastIndex(host, ":")]
+	}
+	return host
+}
+
+// sfString serializes a structured field string
+func sfString(s string) string {
+	return `"` + strings.NewReplacer(`\`, `\\`, `"`, `\"`).Replace(s) + `"`
+}
+
+// ContentDigest returns Content-Digest value of a body using SHA-256
+func ContentDigest(body []byte) string {
+	sum := sha256.Sum256(body)
+	return "sha-256=:" + base64.StdEncoding.EncodeToString(sum[:]) + ":"
+}
+
+// ParseSigningKey decodes a key of a given algorithm: the secret itself for
+// HMAC, or PEM encoded PKCS#8, PKCS#1 or SEC 1 private key otherwise
+func ParseSigningKey(alg string, data []byte) (interface{}, error) {
+	if alg == AlgHMACSHA256 {
+		if len(data) == 0 {
+			return nil, fmt.Errorf("empty HMAC key")
+		}
+		return data, nil
+	}
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, fmt.Errorf("no PEM encoded private key found")
+	}
+	var (
+		key interface{}
+		err error
+	)
+	switch block.Type {
+	case "RSA PRIVATE KEY":
+		key, err = x509.ParsePKCS1PrivateKey(block.Bytes)
+	case "EC PRIVATE KEY":
+		key, err = x509.ParseECPrivateKey(block.Bytes)
+	default:
+		key, err = x509.ParsePKCS8PrivateKey(block.Bytes)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err = checkKey(alg, key); err != nil {
+		return nil, err
+	}
+	return key, nil
+}
+
+// checkKey verifies that a key can be used with an algorithm
+func checkKey(alg string, key interface{}) error {
+	ok := false
+	switch k := key.(type) {
+	case []byte:
+		ok = alg == AlgHMACSHA256
+	case ed25519.PrivateKey:
+		ok = alg == AlgEd25519
+	case *ecdsa.PrivateKey:
+		ok = alg == AlgECDSAP256SHA256 && k.Curve.Params().BitSize == 256 ||
+			alg == AlgECDSAP384SHA384 && k.Curve.Params().BitSize == 384
+	case *rsa.PrivateKey:
+		ok = alg == AlgRSAPSSSHA512 || alg == AlgRSAV15SHA256
+	default:
+		return fmt.Errorf("unsupported key type %T", key)
+	}
+	if !ok {
+		return fmt.Errorf("key of type %T can't be used with %s", key, alg)
+	}
+	return nil
+}
+
+// Signer signs request and response IPs adding Signature and Signature-Input
+// headers. Signatures of other labels already present are kept.
+type Signer struct {
+	Label      string        // Name of the signature in the headers, i.e. sig1
+	KeyID      string        // Sent as keyid parameter
+	Components []string      // Covered components, defaults are used if empty
+	Expires    time.Duration // Validity of signatures, no expires parameter if zero
+	Nonce      bool          // Add a random nonce parameter
+	Tag        string        // Application specific tag parameter
+	Digest     bool          // Add Content-Digest to messages with body and cover it
+
+	alg string
+	key interface{}
+}
+
+// NewSigner creates a signer of a given algorithm with a key returned by
+// ParseSigningKey
+func NewSigner(alg, keyID string, key interface{}) (*Signer, error) {
+	if err := checkKey(alg, key); err != nil {
+		return nil, err
+	}
+	return &Signer{Label: "sig1", KeyID: keyID, alg: alg, key: key}, nil
+}
+
+// SignRequest signs a request, covering @method and @target-uri by default
+func (s *Signer) SignRequest(req *HTTPRequest) error {
+	if err := req.Materialize(); err != nil {
+		return err
+	}
+	if req.Header == nil {
+		req.Header = make(map[string][]string)
+	}
+	params := s.params([]string{"@method", "@target-uri"}, req.Header, req.Body)
+	base, err := RequestSignatureBase(req, params)
+	if err != nil {
+		return err
+	}
+	return s.sign(req.Header, params, base)
+}
+
+// SignResponse signs a response, covering @status by default
+func (s *Signer) SignResponse(resp *HTTPResponse) error {
+	if err := resp.Materialize(); err != nil {
+		return err
+	}
+	if resp.Header == nil {
+		resp.Header = make(map[string][]string)
+	}
+	params := s.params([]string{"@status"}, resp.Header, resp.Body)
+	base, err := ResponseSignatureBase(resp, params)
+	if err != nil {
+		return err
+	}
+	return s.sign(resp.Header, params, base)
+}
+
+// params prepares signature parameters adding Content-Digest if enabled
+func (s *Signer) params(defaults []string, h map[string][]string, body []byte) *SignatureParams {
+	components := s.Components
+	if len(components) == 0 {
+		components = defaults
+	}
+	components = append([]string(nil), components...)
+	for i, c := range components {
+		if !strings.HasPrefix(c, "@") {
+			name := c
+			if j := strings.IndexByte(c, ';'); j > 0 {
+				name = c[:j]
+			}
+			components[i] = strings.ToLower(name) + c[len(name):]
+		}
+	}
+	if s.Digest && len(body) > 0 {
+		if HeaderGet(h, ContentDigestHeader) == "" {
+			HeaderSet(h, ContentDigestHeader, ContentDigest(body))
+		}
+		covered := false
+		for _, c := range components {
+			covered = covered || c == "content-digest"
+		}
+		if !covered {
+			components = append(components, "content-digest")
+		}
+	}
+
+	now := time.Now()
+	params := &SignatureParams{
+		Components: components,
+		Created:    now.Unix(),
+		Algorithm:  s.alg,
+		KeyID:      s.KeyID,
+		Tag:        s.Tag,
+	}
+	if s.Expires > 0 {
+		params.Expires = now.Add(s.Expires).Unix()
+	}
+	if s.Nonce {
+		nonce := make([]byte, 16)
+		rand.Read(nonce)
+		params.Nonce = base64.RawURLEncoding.EncodeToString(nonce)
+	}
+	return params
+}
+
+// sign computes the signature of a base and adds it to the headers
+func (s *Signer) sign(h map[string][]string, params *SignatureParams, base string) error {
+	signature, err := signBase(s.alg, s.key, []byte(base))
+	if err != nil {
+		return err
+	}
+	HeaderAdd(h, SignatureInputHeader, s.Label+"="+params.String())
+	HeaderAdd(h, SignatureHeader, s.Label+"=:"+base64.StdEncoding.EncodeToString(signature)+":")
+	return nil
+}
+
+// signBase signs a signature base with an algorithm
+func signBase(alg string, key interface{}, base []byte) ([]byte, error) {
+	switch alg {
+	case AlgHMACSHA256:
+		mac := hmac.New(sha256.New, key.([]byte))
+		mac.Write(base)
+		return mac.Sum(nil), nil
+	case AlgEd25519:
+		return ed25519.Sign(key.(ed25519.PrivateKey), base), nil
+	case AlgECDSAP256SHA256:
+		sum := sha256.Sum256(base)
+		return signECDSA(key.(*ecdsa.PrivateKey), sum[:], 32)
+	case AlgECDSAP384SHA384:
+		sum := sha512.Sum384(base)
+		return signECDSA(key.(*ecdsa.PrivateKey), sum[:], 48)
+	case AlgRSAPSSSHA512:
+		sum := sha512.Sum512(base)
+		return rsa.SignPSS(rand.Reader, key.(*rsa.PrivateKey), crypto.SHA512, sum[:], &rsa.PSSOptions{SaltLength: 64})
+	case AlgRSAV15SHA256:
+		sum := sha256.Sum256(base)
+		return rsa.SignPKCS1v15(rand.Reader, key.(*rsa.PrivateKey), crypto.SHA256, sum[:])
+	}
+	return nil, fmt.Errorf("unsupported signature algorithm %s", alg)
+}
+
+// signECDSA returns r and s of a signature as fixed size big endian integers
+func signECDSA(key *ecdsa.PrivateKey, digest []byte, size int) ([]byte, error) {
+	r, s, err := ecdsa.Sign(rand.Reader, key, digest)
+	if err != nil {
+		return nil, err
+	}
+	signature := make([]byte, 2*size)
+	r.FillBytes(signature[:size])
+	s.FillBytes(signature[size:])
+	return signature, nil
+}
+
+// VerifyingKey is a public key or HMAC secret of a key registry. An empty
+// algorithm accepts any algorithm matching the type of the key.
+type VerifyingKey struct {
+	Algorithm string
+	Key       interface{} // []byte, ed25519.PublicKey, *ecdsa.PublicKey or *rsa.PublicKey
+}
+
+// ParseVerifyingKey decodes a key of a given algorithm: the secret itself for
+// HMAC, or PEM encoded PKIX or PKCS#1 public key or a certificate otherwise
+func ParseVerifyingKey(alg string, data []byte) (*VerifyingKey, error) {
+	if alg == AlgHMACSHA256 {
+		if len(data) == 0 {
+			return nil, fmt.Errorf("empty HMAC key")
+		}
+		return &VerifyingKey{Algorithm: alg, Key: data}, nil
+	}
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, fmt.Errorf("no PEM encoded public key found")
+	}
+	var (
+		key interface{}
+		err error
+	)
+	switch block.Type {
+	case "RSA PUBLIC KEY":
+		key, err = x509.ParsePKCS1PublicKey(block.Bytes)
+	case "CERTIFICATE":
+		var cert *x509.Certificate
+		if cert, err = x509.ParseCertificate(block.Bytes); err == nil {
+			key = cert.PublicKey
+		}
+	default:
+		key, err = x509.ParsePKIXPublicKey(block.Bytes)
+	}
+	if err != nil {
+		return nil, err
+	}
+	vk := &VerifyingKey{Algorithm: alg, Key: key}
+	if alg != "" {
+		if err = vk.check(alg); err != nil {
+			return nil, err
+		}
+	}
+	return vk, nil
+}
+
+// check verifies that the key can be used with an algorithm
+func (k *VerifyingKey) check(alg string) error {
+	if k.Algorithm != "" && k.Algorithm != alg {
+		return fmt.Errorf("key is registered for %s, not %s", k.Algorithm, alg)
+	}
+	ok := false
+	switch key := k.Key.(type) {
+	case []byte:
+		ok = alg == AlgHMACSHA256
+	case ed25519.PublicKey:
+		ok = alg == AlgEd25519
+	case *ecdsa.PublicKey:
+		ok = alg == AlgECDSAP256SHA256 && key.Curve.Params().BitSize == 256 ||
+			alg == AlgECDSAP384SHA384 && key.Curve.Params().BitSize == 384
+	case *rsa.PublicKey:
+		ok = alg == AlgRSAPSSSHA512 || alg == AlgRSAV15SHA256
+	default:
+		return fmt.Errorf("unsupported key type %T", k.Key)
+	}
+	if !ok {
+		return fmt.Errorf("key of type %T can't be used with %s", k.Key, alg)
+	}
+	return nil
+}
+
+// ParseSignatureInput parses Signature-Input header values by label
+func ParseSignatureInput(values []string) (map[string]*SignatureParams, error) {
+	result := make(map[string]*SignatureParams)
+	for _, member := range sfDictionary(values) {
+		params, err := parseSignatureParams(member[1])
+		if err != nil {
+			return nil, fmt.Errorf("invalid signature input %s: %v", member[0], err)
+		}
+		result[member[0]] = params
+	}
+	return result, nil
+}
+
+// ParseSignatures parses Signature header values by label
+func ParseSignatures(values []string) (map[string][]byte, error) {
+	result := make(map[string][]byte)
+	for _, member := range sfDictionary(values) {
+		v := member[1]
+		if len(v) < 2 || v[0] != ':' || v[len(v)-1] != ':' {
+			return nil, fmt.Errorf("signature %s isn't a byte sequence", member[0])
+		}
+		signature, err := base64.StdEncoding.DecodeString(v[1 : len(v)-1])
+		if err != nil {
+			return nil, fmt.Errorf("invalid signature %s: %v", member[0], err)
+		}
+		result[member[0]] = signature
+	}
+	return result, nil
+}
+
+// sfDictionary splits dictionary header values into label and value pairs
+func sfDictionary(values []string) [][2]string {
+	var members [][2]string
+	for _, value := range values {
+		for _, member := range splitQuoted(value, ',') {
+			member = strings.TrimSpace(member)
+			if i := strings.Index(member, "="); i > 0 {
+				members = append(members, [2]string{strings.TrimSpace(member[:i]), strings.TrimSpace(member[i+1:])})
+			}
+		}
+	}
+	return members
+}
+
+// parseSignatureParams parses an inner list of covered components followed
+// by signature parameters in any order. Unknown parameters are ignored, the
+// member is kept as is in Raw.
+func parseSignatureParams(s string) (*SignatureParams, error) {
+	p := &sfParser{s: s}
+	if !p.consume('(') {
+		return nil, fmt.Errorf("components aren't an inner list")
+	}
+	params := &SignatureParams{Raw: s}
+	for {
+		p.skipSpaces()
+		if p.consume(')') {
+			break
+		}
+		name, err := p.string()
+		if err != nil {
+			return nil, fmt.Errorf("invalid component: %v", err)
+		}
+		componentParams, err := p.params()
+		if err != nil {
+			return nil, fmt.Errorf("invalid parameters of component %s: %v", name, err)
+		}
+		component, err := componentIdentifier(name, componentParams)
+		if err != nil {
+			return nil, err
+		}
+		params.Components = append(params.Components, component)
+		if !p.eof() && s[p.i] != ' ' && s[p.i] != ')' {
+			return nil, fmt.Errorf("unexpected %q after component %s", s[p.i:], name)
+		}
+	}
+	signatureParams, err := p.params()
+	if err != nil {
+		return nil, err
+	}
+	if !p.eof() {
+		return nil, fmt.Errorf("unexpected %q after parameters", s[p.i:])
+	}
+	for _, param := range signatureParams {
+		switch param.key {
+		case "created":
+			params.Created, err = strconv.ParseInt(param.value, 10, 64)
+		case "expires":
+			params.Expires, err = strconv.ParseInt(param.value, 10, 64)
+		case "nonce":
+			params.Nonce = param.value
+		case "alg":
+			params.Algorithm = param.value
+		case "keyid":
+			params.KeyID = param.value
+		case "tag":
+			params.Tag = param.value
+		}
+		if err != nil {
+			return nil, fmt.Errorf("invalid %s parameter", param.key)
+		}
+	}
+	return params, nil
+}
+
+// componentIdentifier returns a covered component with its parameters. Only
+// name of @query-param is supported, other parameters (sf, key, bs, req, tr)
+// are rejected.
+func componentIdentifier(name string, params []sfParam) (string, error) {
+	if name == "@query-param" {
+		if len(params) != 1 || params[0].key != "name" || !params[0].quoted {
+			return "", fmt.Errorf("@query-param needs a single name parameter")
+		}
+		return queryParamPrefix + sfString(params[0].value), nil
+	}
+	if len(params) > 0 {
+		return "", fmt.Errorf("unsupported parameter %s of component %s", params[0].key, name)
+	}
+	return name, nil
+}
+
+// sfParam is a parameter of a structured field item, value of a boolean
+// parameter without one is ?1
+type sfParam struct {
+	key    string
+	value  string
+	quoted bool // Value was a string
+}
+
+// sfParser reads items and parameters of structured fields (RFC 8941)
+type sfParser struct {
+	s string
+	i int
+}
+
+func (p *sfParser) eof() bool {
+	return p.i >= len(p.s)
+}
+
+func (p *sfParser) consume(c byte) bool {
+	if !p.eof() && p.s[p.i] == c {
+		p.i++
+		return true
+	}
+	return false
+}
+
+func (p *sfParser) skipSpaces() {
+	for !p.eof() && p.s[p.i] == ' ' {
+		p.i++
+	}
+}
+
+// string reads a quoted string
+func (p *sfParser) string() (string, error) {
+	if !p.consume('"') {
+		return "", fmt.Errorf("expected string at %q", p.s[p.i:])
+	}
+	var b strings.Builder
+	for !p.eof() {
+		c := p.s[p.i]
+		p.i++
+		switch {
+		case c == '"':
+			return b.String(), nil
+		case c == '\\':
+			if p.eof() || p.s[p.i] != '"' && p.s[p.i] != '\\' {
+				return "", fmt.Errorf("invalid escape in string")
+			}
+			b.WriteByte(p.s[p.i])
+			p.i++
+		case c < 0x20 || c > 0x7e:
+			return "", fmt.Errorf("invalid character in string")
+		default:
+			b.WriteByte(c)
+		}
+	}
+	return "", fmt.Errorf("unterminated string")
+}
+
+// params reads parameters, i.e. ;created=1618884473;keyid="test-key"
+func (p *sfParser) params() ([]sfParam, error) {
+	var params []sfParam
+	for p.consume(';') {
+		p.skipSpaces()
+		start := p.i
+		for !p.eof() && isKeyChar(p.s[p.i], p.i == start) {
+			p.i++
+		}
+		if p.i == start {
+			return nil, fmt.Errorf("invalid parameter key at %q", p.s[start:])
+		}
+		param := sfParam{key: p.s[start:p.i], value: "?1"}
+		if p.consume('=') {
+			if !p.eof() && p.s[p.i] == '"' {
+				value, err := p.string()
+				if err != nil {
+					return nil, err
+				}
+				param.value, param.quoted = value, true
+			} else {
+				start = p.i
+				for !p.eof() && strings.IndexByte(" ;()", p.s[p.i]) < 0 {
+					p.i++
+				}
+				if p.i == start {
+					return nil, fmt.Errorf("parameter %s without value", param.key)
+				}
+				param.value = p.s[start:p.i]
+			}
+		}
+		params = append(params, param)
+	}
+	return params, nil
+}
+
+// isKeyChar checks if a character may be a part of a parameter key
+func isKeyChar(c byte, first bool) bool {
+	if c >= 'a' && c <= 'z' || c == '*' {
+		return true
+	}
+	return !first && (c >= '0' && c <= '9' || c == '_' || c == '-' || c == '.')
+}
+
+// SignatureVerifier checks signatures of request IPs against a key registry
+type SignatureVerifier struct {
+	Keys     func(keyID string) (*VerifyingKey, bool) // Resolves keyid parameters
+	Label    string                                   // Only verify signature of this label if set
+	Required []string                                 // Components every accepted signature must cover
+	MaxAge   time.Duration                            // Reject signatures created earlier, no limit if zero
+	Tag      string                                   // Required tag parameter if set
+	Digest   bool                                     // Check covered Content-Digest against the body
+}
+
+// VerifyRequest verifies signatures of a request returning parameters of the
+// first valid one, or an error describing why the last candidate failed
+func (v *SignatureVerifier) VerifyRequest(req *HTTPRequest) (*SignatureParams, error) {
+	inputs, err := ParseSignatureInput(HeaderValues(req.Header, SignatureInputHeader))
+	if err != nil {
+		return nil, err
+	}
+	signatures, err := ParseSignatures(HeaderValues(req.Header, SignatureHeader))
+	if err != nil {
+		return nil, err
+	}
+	labels := make([]string, 0, len(inputs))
+	for label := range inputs {
+		labels = append(labels, label)
+	}
+	sort.Strings(labels)
+	err = fmt.Errorf("no signature found")
+	for _, label := range labels {
+		params := inputs[label]
+		if v.Label != "" && label != v.Label {
+			continue
+		}
+		signature, ok := signatures[label]
+		if !ok {
+			err = fmt.Errorf("signature %s is missing", label)
+			continue
+		}
+		if err = v.verify(req, params, signature); err == nil {
+			return params, nil
+		}
+		err = fmt.Errorf("signature %s: %v", label, err)
+	}
+	return nil, err
+}
+
+// verify checks a single signature of a request
+func (v *SignatureVerifier) verify(req *HTTPRequest, params *SignatureParams, signature []byte) error {
+	if v.Tag != "" && params.Tag != v.Tag {
+		return fmt.Errorf("unexpected tag %q", params.Tag)
+	}
+	now := time.Now()
+	if params.Expires != 0 && now.Unix() > params.Expires {
+		return fmt.Errorf("signature expired")
+	}
+	if v.MaxAge > 0 && (params.Created == 0 || now.Sub(time.Unix(params.Created, 0)) > v.MaxAge) {
+		return fmt.Errorf("signature is too old")
+	}
+	for _, required := range v.Required {
+		covered := false
+		for _, c := range params.Components {
+			covered = covered || c == strings.ToLower(required) || c == required
+		}
+		if !covered {
+			return fmt.Errorf("component %s isn't covered", required)
+		}
+	}
+
+	key, ok := v.Keys(params.KeyID)
+	if !ok {
+		return fmt.Errorf("unknown key %q", params.KeyID)
+	}
+	alg := params.Algorithm
+	if alg == "" {
+		alg = key.Algorithm
+	}
+	if err := key.check(alg); err != nil {
+		return err
+	}
+
+	base, err := RequestSignatureBase(req, params)
+	if err != nil {
+		return err
+	}
+	if err = verifyBase(alg, key.Key, []byte(base), signature); err != nil {
+		return err
+	}
+	if v.Digest {
+		for _, c := range params.Components {
+			if c == "content-digest" && !DigestMatches(HeaderGet(req.Header, ContentDigestHeader), req.Body) {
+				return fmt.Errorf("content digest doesn't match the body")
+			}
+		}
+	}
+	return nil
+}
+
+// DigestMatches checks sha-256 or sha-512 members of Content-Digest value
+// against a body, digests of other algorithms are ignored
+func DigestMatches(value string, body []byte) bool {
+	checked := false
+	for _, member := range sfDictionary([]string{value}) {
+		var sum []byte
+		switch member[0] {
+		case "sha-256":
+			s := sha256.Sum256(body)
+			sum = s[:]
+		case "sha-512":
+			s := sha512.Sum512(body)
+			sum = s[:]
+		default:
+			continue
+		}
+		if member[1] != ":"+base64.StdEncoding.EncodeToString(sum)+":" {
+			return false
+		}
+		checked = true
+	}
+	return checked
+}
+
+// verifyBase checks a signature of a signature base with an algorithm
+func verifyBase(alg string, key interface{}, base, signature []byte) error {
+	valid := false
+	switch alg {
+	case AlgHMACSHA256:
+		mac := hmac.New(sha256.New, key.([]byte))
+		mac.Write(base)
+		valid = hmac.Equal(mac.Sum(nil), signature)
+	case AlgEd25519:
+		valid = ed25519.Verify(key.(ed25519.PublicKey), base, signature)
+	case AlgECDSAP256SHA256:
+		sum := sha256.Sum256(base)
+		valid = verifyECDSA(key.(*ecdsa.PublicKey), sum[:], signature, 32)
+	case AlgECDSAP384SHA384:
+		sum := sha512.Sum384(base)
+		valid = verifyECDSA(key.(*ecdsa.PublicKey), sum[:], signature, 48)
+	case AlgRSAPSSSHA512:
+		sum := sha512.Sum512(base)
+		valid = rsa.VerifyPSS(key.(*rsa.PublicKey), crypto.SHA512, sum[:], signature, &rsa.PSSOptions{SaltLength: 64}) == nil
+	case AlgRSAV15SHA256:
+		sum := sha256.Sum256(base)
+		valid = rsa.VerifyPKCS1v15(key.(*rsa.PublicKey), crypto.SHA256, sum[:], signature) == nil
+	default:
+		return fmt.Errorf("unsupported signature algorithm %s", alg)
+	}
+	if !valid {
+		return fmt.Errorf("signature doesn't match")
+	}
+	return nil
+}
+
+// verifyECDSA checks r and s of a signature encoded as fixed size integers
+func verifyECDSA(key *ecdsa.PublicKey, digest, signature []byte, size int) bool {
+	if len(signature) != 2*size {
+		return false
+	}
+	r := new(big.Int).SetBytes(signature[:size])
+	s := new(big.Int).SetBytes(signature[size:])
+	return ecdsa.Verify(key, digest, r, s)
+}
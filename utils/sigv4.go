@@ -0,0 +1,271 @@
+package utils
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Object storage providers signed with Signature Version 4
+const (
+	ProviderS3  = "s3"  // Amazon S3 and S3-compatible servers (MinIO, Ceph, R2, ...)
+	ProviderGCS = "gcs" // Google Cloud Storage XML API with HMAC keys
+)
+
+// UnsignedPayload is the payload hash of presigned URLs and streamed uploads
+const UnsignedPayload = "UNSIGNED-PAYLOAD"
+
+// MaxPresignExpiry is the longest validity of presigned URLs accepted by S3 and GCS
+const MaxPresignExpiry = 7 * 24 * time.Hour
+
+// StorageCredentials describe access to S3-compatible object storage
+type StorageCredentials struct {
+	Provider     string `json:"provider"`      // s3 (default) or gcs
+	Endpoint     string `json:"endpoint"`      // Base URL, AWS regional endpoint or https://storage.googleapis.com by default
+	Region       string `json:"region"`        // us-east-1 by default, auto for gcs
+	AccessKey    string `json:"access_key"`    // AWS_ACCESS_KEY_ID by default
+	SecretKey    string `json:"secret_key"`    // AWS_SECRET_ACCESS_KEY by default
+	SessionToken string `json:"session_token"` // AWS_SESSION_TOKEN by default
+	PathStyle    bool   `json:"path_style"`    // Bucket in the path instead of the host name, as most S3-compatible servers expect
+}
+
+// SigV4 signs requests and presigns URLs of object storage
+type SigV4 struct {
+	creds    StorageCredentials
+	endpoint *url.URL
+	service  string
+	prefix   string // AWS4 or GOOG4 of algorithm, key and scope names
+	header   string // x-amz- or x-goog- of header names
+	query    string // X-Amz- or X-Goog- of query parameter names
+}
+
+// NewSigV4 creates a signer applying defaults and environment credentials
+func NewSigV4(c *StorageCredentials) (*SigV4, error) {
+	creds := *c
+	if creds.AccessKey == "" {
+		creds.AccessKey = os.Getenv("AWS_ACCESS_KEY_ID")
+	}
+	if creds.SecretKey == "" {
+		creds.SecretKey = os.Getenv("AWS_SECRET_ACCESS_KEY")
+	}
+	if creds.SessionToken == "" {
+		creds.SessionToken = os.Getenv("AWS_SESSION_TOKEN")
+	}
+	if creds.AccessKey == "" || creds.SecretKey == "" {
+		return nil, fmt.Errorf("access_key and secret_key are required")
+	}
+
+	s := &SigV4{}
+	switch creds.Provider {
+	case "", ProviderS3:
+		creds.Provider = ProviderS3
+		if creds.Region == "" {
+			creds.Region = "us-east-1"
+		}
+		if creds.Endpoint == "" {
+			creds.Endpoint = "https://s3." + creds.Region + ".amazonaws.com"
+		}
+		s.service, s.prefix, s.header, s.query = "s3", "AWS4", "x-amz-", "X-Amz-"
+	case ProviderGCS:
+		if creds.Region == "" {
+			creds.Region = "auto"
+		}
+		if creds.Endpoint == "" {
+			creds.Endpoint = "https://storage.googleapis.com"
+		}
+		s.service, s.prefix, s.header, s.query = "storage", "GOOG4", "x-goog-", "X-Goog-"
+	default:
+		return nil, fmt.Errorf("unsupported provider %q", creds.Provider)
+	}
+	u, err := url.Parse(strings.TrimSuffix(creds.Endpoint, "/"))
+	if err != nil {
+		return nil, fmt.Errorf("invalid endpoint: %w", err)
+	}
+	if u.Scheme != "http" && u.Scheme != "https" || u.Host == "" {
+		return nil, fmt.Errorf("invalid endpoint %q", creds.Endpoint)
+	}
+	s.creds, s.endpoint = creds, u
+	return s, nil
+}
+
+// ObjectURL returns URL of an object, bucket is a path segment with path_style
+// and a subdomain of the endpoint otherwise
+func (s *SigV4) ObjectURL(bucket, key string) *url.URL {
+	u := *s.endpoint
+	path := u.Path
+	if s.creds.PathStyle {
+		path += "/" + bucket
+	} else {
+		u.Host = bucket + "." + u.Host
+	}
+	if key != "" {
+		path += "/" + strings.TrimPrefix(key, "/")
+	}
+	if path == "" {
+		path = "/"
+	}
+	u.Path = path
+	u.RawPath = uriEncode(path, false)
+	return &u
+}
+
+// Presign returns a URL performing a request without credentials until it expires.
+// Headers are signed, so clients have to send them with the same values.
+func (s *SigV4) Presign(method string, u *url.URL, header http.Header, expires time.Duration, now time.Time) (string, error) {
+	if expires < time.Second || expires > MaxPresignExpiry {
+		return "", fmt.Errorf("expiry must be between 1s and %s", MaxPresignExpiry)
+	}
+	now = now.UTC()
+	amzDate := now.Format("20060102T150405Z")
+	scope := s.scope(now)
+
+	h := http.Header{}
+	for k, v := range header {
+		h[k] = v
+	}
+	h.Set("Host", u.Host)
+	names, canonicalHeaders := canonicalHeaders(h)
+
+	q := u.Query()
+	q.Set(s.query+"Algorithm", s.prefix+"-HMAC-SHA256")
+	q.Set(s.query+"Credential", s.creds.AccessKey+"/"+scope)
+	q.Set(s.query+"Date", amzDate)
+	q.Set(s.query+"Expires", strconv.Itoa(int(expires/time.Second)))
+	q.Set(s.query+"SignedHeaders", names)
+	if s.creds.SessionToken != "" {
+		q.Set(s.query+"Security-Token", s.creds.SessionToken)
+	}
+	query := canonicalQuery(q)
+
+	request := strings.Join([]string{method, canonicalURI(u), query, canonicalHeaders, names, UnsignedPayload}, "\n")
+	signature := s.signature(request, amzDate, scope, now)
+
+	presigned := *u
+	presigned.RawQuery = query + "&" + s.query + "Signature=" + signature
+	return presigned.String(), nil
+}
+
+// Sign adds date, payload hash, session token and Authorization headers to a
+// request. Payload hash is hex encoded SHA-256 of the body or UnsignedPayload.
+func (s *SigV4) Sign(req *http.Request, payloadHash string, now time.Time) {
+	now = now.UTC()
+	amzDate := now.Format("20060102T150405Z")
+	scope := s.scope(now)
+
+	req.Header.Set(s.header+"date", amzDate)
+	req.Header.Set(s.header+"content-sha256", payloadHash)
+	if s.creds.SessionToken != "" {
+		req.Header.Set(s.header+"security-token", s.creds.SessionToken)
+	}
+	h := http.Header{}
+	for k, v := range req.Header {
+		switch http.CanonicalHeaderKey(k) {
+		case "Authorization", "User-Agent", "Accept-Encoding", "Content-Length", "Transfer-Encoding", "Connection", "Expect":
+			continue
+		}
+		h[k] = v
+	}
+	host := req.Host
+	if host == "" {
+		host = req.URL.Host
+	}
+	h.Set("Host", host)
+	names, canonicalHeaders := canonicalHeaders(h)
+
+	request := strings.Join([]string{req.Method, canonicalURI(req.URL), canonicalQuery(req.URL.Query()), canonicalHeaders, names, payloadHash}, "\n")
+	signature := s.signature(request, amzDate, scope, now)
+	req.Header.Set("Authorization", fmt.Sprintf("%s-HMAC-SHA256 Credential=%s/%s, SignedHeaders=%s, Signature=%s",
+		s.prefix, s.creds.AccessKey, scope, names, signature))
+}
+
+func (s *SigV4) scope(now time.Time) string {
+	return fmt.Sprintf("%s/%s/%s/%s_request", now.Format("20060102"), s.creds.Region, s.service, strings.ToLower(s.prefix))
+}
+
+func (s *SigV4) signature(request, amzDate, scope string, now time.Time) string {
+	hash := sha256.Sum256([]byte(request))
+	toSign := strings.Join([]string{s.prefix + "-HMAC-SHA256", amzDate, scope, hex.EncodeToString(hash[:])}, "\n")
+	key := hmacSHA256([]byte(s.prefix+s.creds.SecretKey), now.Format("20060102"))
+	for _, part := range []string{s.creds.Region, s.service, strings.ToLower(s.prefix) + "_request"} {
+		key = hmacSHA256(key, part)
+	}
+	return hex.EncodeToString(hmacSHA256(key, toSign))
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(data))
+	return mac.Sum(nil)
+}
+
+// canonicalURI encodes the path as S3 expects, which is without double encoding
+func canonicalURI(u *url.URL) string {
+	if u.Path == "" {
+		return "/"
+	}
+	return uriEncode(u.Path, false)
+}
+
+// canonicalQuery sorts and encodes query parameters
+func canonicalQuery(q url.Values) string {
+	keys := make([]string, 0, len(q))
+	for k := range q {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	var parts []string
+	for _, k := range keys {
+		values := append([]string(nil), q[k]...)
+		sort.Strings(values)
+		for _, v := range values {
+			parts = append(parts, uriEncode(k, true)+"="+uriEncode(v, true))
+		}
+	}
+	return strings.Join(parts, "&")
+}
+
+// canonicalHeaders returns signed header names and canonical header lines
+func canonicalHeaders(h http.Header) (string, string) {
+	names := make([]string, 0, len(h))
+	values := make(map[string]string, len(h))
+	for k, v := range h {
+		name := strings.ToLower(k)
+		trimmed := make([]string, len(v))
+		for i, value := range v {
+			trimmed[i] = strings.Join(strings.Fields(value), " ")
+		}
+		if _, ok := values[name]; !ok {
+			names = append(names, name)
+		}
+		values[name] = strings.Join(trimmed, ",")
+	}
+	sort.Strings(names)
+	var b strings.Builder
+	for _, name := range names {
+		b.WriteString(name + ":" + values[name] + "\n")
+	}
+	return strings.Join(names, ";"), b.String()
+}
+
+// uriEncode percent-encodes everything but unreserved characters, slashes are
+// kept in paths
+func uriEncode(s string, encodeSlash bool) string {
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		if 'A' <= c && c <= 'Z' || 'a' <= c && c <= 'z' || '0' <= c && c <= '9' || c == '-' || c == '_' || c == '.' || c == '~' || c == '/' && !encodeSlash {
+			b.WriteByte(c)
+			continue
+		}
+		fmt.Fprintf(&b, "%%%02X", c)
+	}
+	return b.String()
+}
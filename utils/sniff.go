@@ -0,0 +1,52 @@
+package utils
+
+import (
+	"bytes"
+	"encoding/json"
+	"encoding/xml"
+	"net/http"
+	"strings"
+)
+
+// SniffContentType suggests Content-Type of a given body. It recognizes JSON and XML
+// documents which http.DetectContentType reports as plain text.
+func SniffContentType(body []byte) string {
+	trimmed := bytes.TrimSpace(body)
+	if len(trimmed) == 0 {
+		return "text/plain; charset=utf-8"
+	}
+	if (trimmed[0] == '{' || trimmed[0] == '[') && json.Valid(trimmed) {
+		return "application/json"
+	}
+	detected := http.DetectContentType(body)
+	if trimmed[0] == '<' && !strings.HasPrefix(detected, "text/html") && looksLikeXML(trimmed) {
+		return "application/xml"
+	}
+	return detected
+}
+
+// EnsureContentType sets Content-Type of a response with a body if it's missing
+func EnsureContentType(resp *HTTPResponse) {
+	if len(resp.Body) == 0 || resp.GetHeader("Content-Type") != "" {
+		return
+	}
+	resp.SetHeader("Content-Type", SniffContentType(resp.Body))
+}
+
+// looksLikeXML checks if a document starts with a well-formed XML element or declaration
+func looksLikeXML(body []byte) bool {
+	dec := xml.NewDecoder(bytes.NewReader(body))
+	for i := 0; i < 8; i++ {
+		tok, err := dec.Token()
+		if err != nil {
+			return false
+		}
+		switch tok.(type) {
+		case xml.StartElement:
+			return true
+		case xml.ProcInst, xml.Comment, xml.Directive, xml.CharData:
+			continue
+		}
+	}
+	return false
+}
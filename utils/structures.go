@@ -1,7 +1,11 @@
 package utils
 
 import (
+	"bytes"
+	"crypto/tls"
 	"encoding/json"
+	"fmt"
+	"io"
 	"io/ioutil"
 	"net/http"
 	"net/url"
@@ -11,46 +15,242 @@ import (
 
 // HTTPClientOptions describe options IP for client configuration
 type HTTPClientOptions struct {
-	URL         string              `json:"url"`
-	Method      string              `json:"method"`
-	ContentType string              `json:"content-type"`
-	Headers     map[string][]string `json:"headers"`
-	Form        url.Values          `json:"form"`
+	URL             string              `json:"url"`
+	Method          string              `json:"method"`
+	ContentType     string              `json:"content-type"`
+	Headers         map[string][]string `json:"headers"`
+	Form            url.Values          `json:"form"`
+	Body            []byte              `json:"body"`             // Raw body sent instead of form
+	Trace           string              `json:"trace"`            // W3C traceparent continued by the request span, taken from headers if empty
+	Priority        int                 `json:"priority"`         // Requests of higher priority are sent first by the client
+	Timeout         Duration            `json:"timeout"`          // Overrides request timeout of the client
+	FollowRedirects *bool               `json:"follow_redirects"` // Overrides following of redirects by the client
+	MaxRedirects    int                 `json:"max_redirects"`    // Overrides max_redirects of the client, -1 for none
+	MaxBody         int64               `json:"max_body"`         // Overrides max_body_size of the client, -1 for no limit
+	TLSVerify       *bool               `json:"tls_verify"`       // Overrides verification of server certificates
+	Proxy           string              `json:"proxy"`            // URL of http, https or socks5 proxy overriding the default one, none for direct connection
+	Attempts        int                 `json:"attempts"`         // Overrides retry attempts of the client including the first one, 1 for no retries
+	Backoff         Duration            `json:"backoff"`          // Overrides delay before the first retry
+	Stream          *bool               `json:"stream"`           // Overrides streaming of the response body in chunks
 }
 
-//
 // HTTPRequest data structure for IP
-//
 type HTTPRequest struct {
-	ID     string              `json:"id"`      // Assigned by server component
-	Method string              `json:"method"`  // GET/POST/PUT/etc
-	URI    string              `json:"uri"`     // Full URL that hit the server
-	Header map[string][]string `json:"headers"` // Map of headers
-	Form   map[string][]string `json:"form"`    // Map of GET/POST/PUT values
+	ID            string              `json:"id"`              // Assigned by server component
+	Method        string              `json:"method"`          // GET/POST/PUT/etc
+	URI           string              `json:"uri"`             // Full URL that hit the server
+	Header        map[string][]string `json:"headers"`         // Map of headers
+	Form          map[string][]string `json:"form"`            // Map of GET/POST/PUT values (query and body combined)
+	Query         map[string][]string `json:"query"`           // Map of URL query values
+	PostForm      map[string][]string `json:"post-form"`       // Map of POST/PUT/PATCH body values
+	Trailer       map[string][]string `json:"trailers"`        // Map of trailers sent after the body
+	ContentLength int64               `json:"content-length"`  // Length of the body
+	Body          []byte              `json:"body"`            // Raw body of the request
+	BodyReader    io.Reader           `json:"-"`               // Optional streamed body, see Materialize
+	RemoteAddr    string              `json:"remote-addr"`     // Network address of the client
+	Host          string              `json:"host"`            // Host requested by the client
+	Scheme        string              `json:"scheme"`          // http or https
+	TLS           *TLSInfo            `json:"tls,omitempty"`   // Connection state for https requests
+	Cookies       map[string]string   `json:"cookies"`         // Parsed request cookies
+	Trace         string              `json:"trace"`           // W3C traceparent of the span handling the request
+	User          string              `json:"user"`            // Authenticated user name if any
+	Groups        []string            `json:"groups"`          // Groups of the authenticated user
+	Roles         []string            `json:"roles"`           // Roles granted to the authenticated user
+	Bot           *BotInfo            `json:"bot,omitempty"`   // Classification of the client if scored
+	Geo           *GeoInfo            `json:"geo,omitempty"`   // Location of the client if resolved
+	Route         *RouteInfo          `json:"route,omitempty"` // Metadata of the route matched by the router
+	buildErr      error               `json:"-"`               // First error of builder methods, returned by IP
+}
+
+// TLSInfo describes TLS connection a request was received on
+type TLSInfo struct {
+	Version     string `json:"version"`      // TLS 1.2/TLS 1.3/etc
+	CipherSuite string `json:"cipher-suite"` // Negotiated cipher suite name
+	PeerSubject string `json:"peer-subject"` // Subject of the client certificate if any
+	JA3         string `json:"ja3"`          // MD5 of JA3 fingerprint of the ClientHello
+	JA4         string `json:"ja4"`          // JA4 fingerprint of the ClientHello
+}
+
+// BotInfo is a classification of the client by the botdetect component
+type BotInfo struct {
+	Score   int      `json:"score"`   // 0 (likely human) to 100 (certainly automated)
+	Class   string   `json:"class"`   // human, crawler, automation or bot
+	Name    string   `json:"name"`    // Matched crawler or tool, i.e. Googlebot
+	Reasons []string `json:"reasons"` // Signals contributing to the score
+}
+
+// GeoInfo is the location of the client resolved by the geoip component
+type GeoInfo struct {
+	Country     string  `json:"country"`      // ISO 3166-1 alpha-2 code, i.e. DE
+	CountryName string  `json:"country-name"` // English name of the country
+	Continent   string  `json:"continent"`    // Continent code, i.e. EU
+	Region      string  `json:"region"`       // ISO 3166-2 code of the largest subdivision
+	City        string  `json:"city"`         // English name of the city
+	PostalCode  string  `json:"postal-code"`  // Postal code of the location
+	Latitude    float64 `json:"latitude"`     // Approximate coordinates of the network
+	Longitude   float64 `json:"longitude"`    // Approximate coordinates of the network
+	TimeZone    string  `json:"time-zone"`    // IANA time zone, i.e. Europe/Berlin
+	ASN         uint32  `json:"asn"`          // Autonomous system number
+	ASOrg       string  `json:"as-org"`       // Organization of the autonomous system
+}
+
+// RouteInfo is the route of a request with metadata attached to it in the router
+// component, so downstream components can enforce per-route policies
+type RouteInfo struct {
+	Pattern      string            `json:"pattern"`       // Matched pattern, i.e. GET /users/:id
+	Timeout      Duration          `json:"timeout"`       // Time the request may take (0 if not limited)
+	AuthRequired bool              `json:"auth-required"` // Only authenticated requests are allowed
+	RateClass    string            `json:"rate-class"`    // Rate limit class, i.e. default or expensive
+	Labels       map[string]string `json:"labels"`        // Arbitrary metadata of the route
 }
 
-//
 // HTTPResponse data structure for IP
-//
 type HTTPResponse struct {
-	ID         string              `json:"id"`      // Retrieved from request structure
-	StatusCode int                 `json:"status"`  // Response HTTP status code
-	Header     map[string][]string `json:"headers"` // Map of headers
-	Body       []byte              `json:"body"`    // Body of the response
+	ID         string              `json:"id"`                  // Retrieved from request structure
+	StatusCode int                 `json:"status"`              // Response HTTP status code
+	Header     map[string][]string `json:"headers"`             // Map of headers
+	Body       []byte              `json:"body"`                // Body of the response
+	BodyReader io.Reader           `json:"-"`                   // Optional streamed body, see Materialize
+	Cookies    []*Cookie           `json:"cookies,omitempty"`   // Serialized into Set-Cookie headers
+	Trailer    map[string][]string `json:"trailers"`            // Map of trailers sent after the body
+	Trace      string              `json:"trace"`               // W3C traceparent copied from the request
+	URL        string              `json:"url,omitempty"`       // Final URL of a response received by the client
+	Redirects  []string            `json:"redirects,omitempty"` // URLs the client was redirected from, in order
+	Attempts   int                 `json:"attempts,omitempty"`  // Number of times the client sent the request, more than 1 with retries
+	buildErr   error               `json:"-"`                   // First error of builder methods, returned by IP
 }
 
 // Request2Request create our internal request structure based on the standard one
-func Request2Request(request *http.Request) *HTTPRequest {
+func Request2Request(request *http.Request) (*HTTPRequest, error) {
+	// Read the body and put it back for form parsing
+	var body []byte
+	if request.Body != nil {
+		var err error
+		body, err = ioutil.ReadAll(request.Body)
+		request.Body.Close()
+		if err != nil {
+			return nil, err
+		}
+		request.Body = ioutil.NopCloser(bytes.NewReader(body))
+	}
 	// Parse GET/POST/PUT params into request.Form
 	request.ParseForm()
 	// Create data structure
 	res := &HTTPRequest{
-		Method: request.Method,
-		URI:    request.RequestURI,
-		Header: request.Header,
-		Form:   request.Form,
+		Method:        request.Method,
+		URI:           request.RequestURI,
+		Header:        request.Header,
+		Form:          request.Form,
+		Query:         request.URL.Query(),
+		PostForm:      request.PostForm,
+		Trailer:       request.Trailer,
+		ContentLength: int64(len(body)),
+		Body:          body,
+		RemoteAddr:    request.RemoteAddr,
+		Host:          request.Host,
+		Scheme:        "http",
+	}
+	if request.TLS != nil {
+		res.Scheme = "https"
+		res.TLS = tlsInfo(request.TLS)
+	}
+	if cookies := request.Cookies(); len(cookies) > 0 {
+		res.Cookies = make(map[string]string, len(cookies))
+		for _, c := range cookies {
+			res.Cookies[c.Name] = c.Value
+		}
+	}
+	return res, nil
+}
+
+// Request2HTTPRequest creates a standard request (with body) from our internal structure
+func Request2HTTPRequest(request *HTTPRequest) (*http.Request, error) {
+	u, err := url.Parse(request.URI)
+	if err != nil {
+		return nil, err
+	}
+	if u.Host == "" && request.Host != "" {
+		u.Host = request.Host
+		u.Scheme = request.Scheme
+		if u.Scheme == "" {
+			u.Scheme = "http"
+		}
+	}
+	req, err := http.NewRequest(request.Method, u.String(), request.BodyStream())
+	if err != nil {
+		return nil, err
+	}
+	for name, values := range request.Header {
+		for _, value := range values {
+			req.Header.Add(name, value)
+		}
+	}
+	if request.Host != "" {
+		req.Host = request.Host
+	}
+	req.RemoteAddr = request.RemoteAddr
+	req.ContentLength = int64(len(request.Body))
+	if request.BodyReader != nil {
+		req.ContentLength = request.ContentLength
+		if req.ContentLength == 0 {
+			req.ContentLength = -1
+		}
+	}
+	if len(request.Trailer) > 0 {
+		// Trailers are only sent with chunked bodies
+		req.Trailer = http.Header(cloneValues(request.Trailer))
+		req.ContentLength = -1
+	}
+	return req, nil
+}
+
+// WriteResponse writes our internal response structure (headers, cookies, body and trailers) to a given writer
+func WriteResponse(w http.ResponseWriter, response *HTTPResponse) error {
+	for name, values := range response.Header {
+		for _, value := range values {
+			w.Header().Add(name, value)
+		}
+	}
+	for _, value := range response.CookieHeaders() {
+		w.Header().Add("Set-Cookie", value)
+	}
+	for name := range response.Trailer {
+		w.Header().Add("Trailer", name)
+	}
+	status := response.StatusCode
+	if status == 0 {
+		status = http.StatusOK
+	}
+	w.WriteHeader(status)
+	_, err := io.Copy(w, response.BodyStream())
+	for name, values := range response.Trailer {
+		for _, value := range values {
+			w.Header().Add(name, value)
+		}
+	}
+	return err
+}
+
+// tlsInfo extracts details of a given TLS connection state
+func tlsInfo(state *tls.ConnectionState) *TLSInfo {
+	info := &TLSInfo{
+		Version:     tlsVersions[state.Version],
+		CipherSuite: tls.CipherSuiteName(state.CipherSuite),
+	}
+	if info.Version == "" {
+		info.Version = fmt.Sprintf("0x%04x", state.Version)
+	}
+	if len(state.PeerCertificates) > 0 {
+		info.PeerSubject = state.PeerCertificates[0].Subject.String()
 	}
-	return res
+	return info
+}
+
+var tlsVersions = map[uint16]string{
+	tls.VersionTLS10: "TLS 1.0",
+	tls.VersionTLS11: "TLS 1.1",
+	tls.VersionTLS12: "TLS 1.2",
+	tls.VersionTLS13: "TLS 1.3",
 }
 
 // Response2Response create our internal response structure based on the standard one
@@ -64,12 +264,16 @@ func Response2Response(response *http.Response) (*HTTPResponse, error) {
 		StatusCode: response.StatusCode,
 		Header:     response.Header,
 		Body:       body,
+		Trailer:    response.Trailer,
 	}
 	return rep, nil
 }
 
 // Request2IP converts a given request to IP
 func Request2IP(request *HTTPRequest) ([][]byte, error) {
+	if err := request.Materialize(); err != nil {
+		return nil, err
+	}
 	payload, err := json.Marshal(request)
 	if err != nil {
 		return nil, err
@@ -79,6 +283,9 @@ func Request2IP(request *HTTPRequest) ([][]byte, error) {
 
 // Response2IP сonverts a given response to IP
 func Response2IP(response *HTTPResponse) ([][]byte, error) {
+	if err := response.Materialize(); err != nil {
+		return nil, err
+	}
 	payload, err := json.Marshal(response)
 	if err != nil {
 		return nil, err
@@ -86,22 +293,94 @@ func Response2IP(response *HTTPResponse) ([][]byte, error) {
 	return runtime.NewPacket(payload), nil
 }
 
-// IP2Request сonverts a given IP to request structure
+// Request2FramedIP converts a given request to IP with JSON metadata in the payload
+// frame and the raw body in a separate frame (no base64 overhead for binary bodies)
+func Request2FramedIP(request *HTTPRequest) ([][]byte, error) {
+	if err := request.Materialize(); err != nil {
+		return nil, err
+	}
+	meta := *request
+	meta.Body = nil
+	payload, err := json.Marshal(&meta)
+	if err != nil {
+		return nil, err
+	}
+	return append(runtime.NewPacket(payload), request.Body), nil
+}
+
+// Response2FramedIP converts a given response to IP with JSON metadata in the payload
+// frame and the raw body in a separate frame (no base64 overhead for binary bodies)
+func Response2FramedIP(response *HTTPResponse) ([][]byte, error) {
+	if err := response.Materialize(); err != nil {
+		return nil, err
+	}
+	meta := *response
+	meta.Body = nil
+	payload, err := json.Marshal(&meta)
+	if err != nil {
+		return nil, err
+	}
+	return append(runtime.NewPacket(payload), response.Body), nil
+}
+
+// IsFramedIP checks if a given IP carries the body in a separate frame
+func IsFramedIP(ip [][]byte) bool {
+	return len(ip) == 3
+}
+
+// IsValidIP checks if a given IP is valid accepting framed IPs as well
+func IsValidIP(ip [][]byte) bool {
+	if IsFramedIP(ip) {
+		return runtime.IsValidIP(ip[:2])
+	}
+	return runtime.IsValidIP(ip)
+}
+
+// IP2Request сonverts a given IP (plain or framed, JSON, MessagePack or Protocol Buffers) to request structure
 func IP2Request(ip [][]byte) (*HTTPRequest, error) {
+	if len(ip) < 2 {
+		return nil, fmt.Errorf("invalid IP with %d frames", len(ip))
+	}
 	var req *HTTPRequest
-	err := json.Unmarshal(ip[1], &req)
+	err := unmarshalPayload(ip[1], &req)
 	if err != nil {
 		return nil, err
 	}
+	if req == nil {
+		return nil, fmt.Errorf("empty request payload")
+	}
+	if IsFramedIP(ip) {
+		req.Body = ip[2]
+	}
+	req.Header = CanonicalizeHeader(req.Header)
+	req.Trailer = CanonicalizeHeader(req.Trailer)
 	return req, nil
 }
 
-// IP2Response сonverts a given IP to response structure
+// IP2Response сonverts a given IP (plain or framed, JSON, MessagePack or Protocol Buffers) to response structure
 func IP2Response(ip [][]byte) (*HTTPResponse, error) {
+	if len(ip) < 2 {
+		return nil, fmt.Errorf("invalid IP with %d frames", len(ip))
+	}
 	var res *HTTPResponse
-	err := json.Unmarshal(ip[1], &res)
+	err := unmarshalPayload(ip[1], &res)
 	if err != nil {
 		return nil, err
 	}
+	if res == nil {
+		return nil, fmt.Errorf("empty response payload")
+	}
+	if IsFramedIP(ip) {
+		res.Body = ip[2]
+	}
+	res.Header = CanonicalizeHeader(res.Header)
+	res.Trailer = CanonicalizeHeader(res.Trailer)
+	cookies := res.Cookies[:0]
+	for _, c := range res.Cookies {
+		if c != nil {
+			cookies = append(cookies, c)
+		}
+	}
+	res.Cookies = cookies
 	return res, nil
 }
@@ -0,0 +1,374 @@
+package utils
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// TraceparentHeader is the W3C Trace Context header propagating traces over HTTP
+const TraceparentHeader = "Traceparent"
+
+// Kinds of spans as defined by OpenTelemetry
+const (
+	SpanInternal = 1
+	SpanServer   = 2
+	SpanClient   = 3
+)
+
+// Defaults of a Tracer exporting to OTLP endpoint
+const (
+	DefaultTraceBatch    = 256
+	DefaultTraceInterval = 2 * time.Second
+	traceQueueSize       = 4096
+	traceExportTimeout   = 2 * time.Second
+)
+
+// TraceContext identifies a span of a trace, it's carried in Trace fields
+// of IPs formatted as W3C traceparent
+type TraceContext struct {
+	TraceID [16]byte
+	SpanID  [8]byte
+	Sampled bool
+}
+
+// ParseTraceparent parses a W3C traceparent value, i.e.
+// 00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01
+func ParseTraceparent(s string) (TraceContext, error) {
+	var tc TraceContext
+	parts := strings.Split(strings.TrimSpace(s), "-")
+	if len(parts) < 4 || len(parts[0]) != 2 || parts[0] == "ff" {
+		return tc, fmt.Errorf("invalid traceparent %q", s)
+	}
+	if len(parts) > 4 && parts[0] == "00" {
+		return tc, fmt.Errorf("invalid traceparent %q", s)
+	}
+	if len(parts[1]) != 32 || len(parts[2]) != 16 || len(parts[3]) != 2 {
+		return tc, fmt.Errorf("invalid traceparent %q", s)
+	}
+	if _, err := hex.Decode(tc.TraceID[:], []byte(parts[1])); err != nil {
+		return tc, fmt.Errorf("invalid trace id in traceparent %q", s)
+	}
+	if _, err := hex.Decode(tc.SpanID[:], []byte(parts[2])); err != nil {
+		return tc, fmt.Errorf("invalid span id in traceparent %q", s)
+	}
+	flags, err := strconv.ParseUint(parts[3], 16, 8)
+	if err != nil {
+		return tc, fmt.Errorf("invalid flags in traceparent %q", s)
+	}
+	if tc.TraceID == [16]byte{} || tc.SpanID == [8]byte{} {
+		return tc, fmt.Errorf("zero id in traceparent %q", s)
+	}
+	tc.Sampled = flags&1 == 1
+	return tc, nil
+}
+
+// String formats the context as W3C traceparent
+func (tc TraceContext) String() string {
+	flags := "00"
+	if tc.Sampled {
+		flags = "01"
+	}
+	return "00-" + hex.EncodeToString(tc.TraceID[:]) + "-" + hex.EncodeToString(tc.SpanID[:]) + "-" + flags
+}
+
+// Span is a timed operation of a trace. Attributes and status may be set
+// until End is called.
+type Span struct {
+	tracer   *Tracer
+	name     string
+	kind     int
+	context  TraceContext
+	parent   [8]byte
+	start    time.Time
+	end      time.Time
+	attrs    map[string]interface{}
+	errorMsg string
+	failed   bool
+}
+
+// Traceparent returns the context to propagate to children of the span. For
+// spans of a disabled tracer it's the parent context unchanged, so traces
+// pass through components that don't export spans.
+func (s *Span) Traceparent() string {
+	if s.tracer.endpoint == "" {
+		if s.parent == [8]byte{} {
+			return ""
+		}
+		return TraceContext{TraceID: s.context.TraceID, SpanID: s.parent, Sampled: s.context.Sampled}.String()
+	}
+	return s.context.String()
+}
+
+// SetAttribute sets a string, bool, integer or float attribute
+func (s *Span) SetAttribute(key string, value interface{}) {
+	s.attrs[key] = value
+}
+
+// SetStatusCode records HTTP status of the span, 5xx responses of servers
+// and 4xx/5xx responses of clients mark it as failed
+func (s *Span) SetStatusCode(code int) {
+	s.attrs["http.status_code"] = code
+	if code >= 500 || (code >= 400 && s.kind == SpanClient) {
+		s.failed = true
+	}
+}
+
+// SetError marks the span as failed
+func (s *Span) SetError(err error) {
+	s.failed = true
+	s.errorMsg = err.Error()
+}
+
+// End finishes the span and queues it for export if it's sampled
+func (s *Span) End() {
+	s.end = time.Now()
+	if s.tracer.endpoint != "" && s.context.Sampled {
+		s.tracer.enqueue(s)
+	}
+}
+
+// Tracer creates spans of a component and exports them in batches to an
+// OpenTelemetry collector using OTLP over HTTP with JSON encoding (i.e.
+// Jaeger or Tempo at http://127.0.0.1:4318/v1/traces). With an empty endpoint
+// spans get no IDs of their own and aren't exported, the context of callers
+// still propagates. Spans are dropped if the collector can't keep up.
+type Tracer struct {
+	BatchSize int
+	Interval  time.Duration
+
+	service  string
+	endpoint string
+	logger   *Logger
+	client   *http.Client
+	queue    chan *Span
+	stop     chan struct{}
+	wg       sync.WaitGroup
+}
+
+// NewTracer creates a tracer of a given service (component name) exporting
+// to an OTLP/HTTP endpoint, call Start to begin exporting
+func NewTracer(service, endpoint string, logger *Logger) *Tracer {
+	return &Tracer{
+		BatchSize: DefaultTraceBatch,
+		Interval:  DefaultTraceInterval,
+		service:   service,
+		endpoint:  endpoint,
+		logger:    logger,
+		client:    &http.Client{Timeout: traceExportTimeout},
+		queue:     make(chan *Span, traceQueueSize),
+	}
+}
+
+// Enabled reports whether spans are exported
+func (t *Tracer) Enabled() bool {
+	return t.endpoint != ""
+}
+
+// StartSpan starts a span as a child of a given traceparent, or a new sampled
+// trace if it's empty or invalid
+func (t *Tracer) StartSpan(name, traceparent string, kind int) *Span {
+	s := &Span{
+		tracer: t,
+		name:   name,
+		kind:   kind,
+		start:  time.Now(),
+		attrs:  make(map[string]interface{}),
+	}
+	if parent, err := ParseTraceparent(traceparent); err == nil {
+		s.context = parent
+		s.parent = parent.SpanID
+	} else {
+		s.context.Sampled = true
+		rand.Read(s.context.TraceID[:])
+	}
+	if t.endpoint != "" {
+		rand.Read(s.context.SpanID[:])
+	}
+	return s
+}
+
+// Start begins exporting queued spans in background, it's a no-op for
+// a disabled tracer
+func (t *Tracer) Start() {
+	if t.endpoint == "" {
+		return
+	}
+	t.stop = make(chan struct{})
+	t.wg.Add(1)
+	go func() {
+		defer t.wg.Done()
+		ticker := time.NewTicker(t.Interval)
+		defer ticker.Stop()
+		var batch []*Span
+		for {
+			select {
+			case s := <-t.queue:
+				batch = append(batch, s)
+				if len(batch) < t.BatchSize {
+					continue
+				}
+			case <-ticker.C:
+			case <-t.stop:
+				for len(t.queue) > 0 {
+					batch = append(batch, <-t.queue)
+				}
+				t.export(batch)
+				return
+			}
+			t.export(batch)
+			batch = nil
+		}
+	}()
+}
+
+// Stop exports spans still queued and stops the exporter. It's a no-op if
+// not started.
+func (t *Tracer) Stop() {
+	if t.stop == nil {
+		return
+	}
+	close(t.stop)
+	t.wg.Wait()
+	t.stop = nil
+}
+
+// enqueue queues a finished span dropping it if the queue is full
+func (t *Tracer) enqueue(s *Span) {
+	select {
+	case t.queue <- s:
+	default:
+		t.logger.Debug("Trace queue is full, span dropped", "span", s.name)
+	}
+}
+
+// export sends a batch of spans to the collector
+func (t *Tracer) export(batch []*Span) {
+	if len(batch) == 0 {
+		return
+	}
+	payload, err := json.Marshal(t.otlp(batch))
+	if err != nil {
+		t.logger.Warn("Failed to encode spans", "error", err)
+		return
+	}
+	resp, err := t.client.Post(t.endpoint, "application/json", bytes.NewReader(payload))
+	if err != nil {
+		t.logger.Warn("Failed to export spans", "endpoint", t.endpoint, "spans", len(batch), "error", err)
+		return
+	}
+	resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		t.logger.Warn("Collector rejected spans", "endpoint", t.endpoint, "spans", len(batch), "status", resp.StatusCode)
+	}
+}
+
+// OTLP/JSON structures, trace and span IDs are hex encoded and timestamps
+// are strings of nanoseconds
+type otlpValue struct {
+	StringValue *string  `json:"stringValue,omitempty"`
+	BoolValue   *bool    `json:"boolValue,omitempty"`
+	IntValue    *string  `json:"intValue,omitempty"`
+	DoubleValue *float64 `json:"doubleValue,omitempty"`
+}
+
+type otlpAttribute struct {
+	Key   string    `json:"key"`
+	Value otlpValue `json:"value"`
+}
+
+type otlpStatus struct {
+	Code    int    `json:"code"`
+	Message string `json:"message,omitempty"`
+}
+
+type otlpSpan struct {
+	TraceID           string          `json:"traceId"`
+	SpanID            string          `json:"spanId"`
+	ParentSpanID      string          `json:"parentSpanId,omitempty"`
+	Name              string          `json:"name"`
+	Kind              int             `json:"kind"`
+	StartTimeUnixNano string          `json:"startTimeUnixNano"`
+	EndTimeUnixNano   string          `json:"endTimeUnixNano"`
+	Attributes        []otlpAttribute `json:"attributes,omitempty"`
+	Status            otlpStatus      `json:"status"`
+}
+
+type otlpScope struct {
+	Name string `json:"name"`
+}
+
+type otlpScopeSpans struct {
+	Scope otlpScope  `json:"scope"`
+	Spans []otlpSpan `json:"spans"`
+}
+
+type otlpResource struct {
+	Attributes []otlpAttribute `json:"attributes"`
+}
+
+type otlpResourceSpans struct {
+	Resource   otlpResource     `json:"resource"`
+	ScopeSpans []otlpScopeSpans `json:"scopeSpans"`
+}
+
+type otlpRequest struct {
+	ResourceSpans []otlpResourceSpans `json:"resourceSpans"`
+}
+
+// otlp converts a batch to OTLP export request
+func (t *Tracer) otlp(batch []*Span) *otlpRequest {
+	ss := otlpScopeSpans{Scope: otlpScope{Name: "github.com/cascades-fbp/cascades-http"}}
+	for _, s := range batch {
+		span := otlpSpan{
+			TraceID:           hex.EncodeToString(s.context.TraceID[:]),
+			SpanID:            hex.EncodeToString(s.context.SpanID[:]),
+			Name:              s.name,
+			Kind:              s.kind,
+			StartTimeUnixNano: strconv.FormatInt(s.start.UnixNano(), 10),
+			EndTimeUnixNano:   strconv.FormatInt(s.end.UnixNano(), 10),
+		}
+		if s.parent != [8]byte{} {
+			span.ParentSpanID = hex.EncodeToString(s.parent[:])
+		}
+		for k, v := range s.attrs {
+			span.Attributes = append(span.Attributes, otlpAttr(k, v))
+		}
+		if s.failed {
+			span.Status = otlpStatus{Code: 2, Message: s.errorMsg}
+		}
+		ss.Spans = append(ss.Spans, span)
+	}
+	return &otlpRequest{ResourceSpans: []otlpResourceSpans{{
+		Resource:   otlpResource{Attributes: []otlpAttribute{otlpAttr("service.name", t.service)}},
+		ScopeSpans: []otlpScopeSpans{ss},
+	}}}
+}
+
+// otlpAttr converts a key/value pair to OTLP attribute
+func otlpAttr(key string, value interface{}) otlpAttribute {
+	a := otlpAttribute{Key: key}
+	switch v := value.(type) {
+	case bool:
+		a.Value.BoolValue = &v
+	case int:
+		i := strconv.Itoa(v)
+		a.Value.IntValue = &i
+	case int64:
+		i := strconv.FormatInt(v, 10)
+		a.Value.IntValue = &i
+	case float64:
+		a.Value.DoubleValue = &v
+	default:
+		str := fmt.Sprint(v)
+		a.Value.StringValue = &str
+	}
+	return a
+}
@@ -0,0 +1,116 @@
+package utils
+
+import (
+	"fmt"
+	"net/url"
+	"sort"
+	"strings"
+)
+
+// ValidationError describes a problem with a single field of IP structure
+type ValidationError struct {
+	Field   string
+	Message string
+}
+
+func (e *ValidationError) Error() string {
+	return e.Field + ": " + e.Message
+}
+
+// ValidationErrors collects all problems found in IP structure
+type ValidationErrors []*ValidationError
+
+func (e ValidationErrors) Error() string {
+	msgs := make([]string, len(e))
+	for i, err := range e {
+		msgs[i] = err.Error()
+	}
+	return strings.Join(msgs, "; ")
+}
+
+func (e *ValidationErrors) add(field, format string, args ...interface{}) {
+	*e = append(*e, &ValidationError{Field: field, Message: fmt.Sprintf(format, args...)})
+}
+
+func (e ValidationErrors) err() error {
+	if len(e) == 0 {
+		return nil
+	}
+	return e
+}
+
+// Validate checks the request for missing fields, illegal method and malformed headers.
+// It returns ValidationErrors listing all the problems found.
+func (r *HTTPRequest) Validate() error {
+	var errs ValidationErrors
+	switch {
+	case r.Method == "":
+		errs.add("method", "is required")
+	case !isToken(r.Method):
+		errs.add("method", "%q is not a valid method", r.Method)
+	}
+	if r.URI == "" {
+		errs.add("uri", "is required")
+	} else if _, err := url.Parse(r.URI); err != nil {
+		errs.add("uri", "%v", err)
+	}
+	if r.ContentLength < 0 {
+		errs.add("content-length", "can't be negative")
+	} else if len(r.Body) > 0 && r.ContentLength != int64(len(r.Body)) {
+		errs.add("content-length", "%d doesn't match body length %d", r.ContentLength, len(r.Body))
+	}
+	validateHeader(&errs, "headers", r.Header)
+	return errs.err()
+}
+
+// Validate checks the response for illegal status code, malformed headers and cookies.
+// It returns ValidationErrors listing all the problems found.
+func (r *HTTPResponse) Validate() error {
+	var errs ValidationErrors
+	if r.StatusCode < 100 || r.StatusCode > 599 {
+		errs.add("status", "%d is out of 100-599 range", r.StatusCode)
+	}
+	validateHeader(&errs, "headers", r.Header)
+	for i, c := range r.Cookies {
+		if c == nil || !isToken(c.Name) {
+			errs.add(fmt.Sprintf("cookies[%d]", i), "invalid cookie name")
+		}
+	}
+	return errs.err()
+}
+
+func validateHeader(errs *ValidationErrors, field string, h map[string][]string) {
+	names := make([]string, 0, len(h))
+	for name := range h {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		if !isToken(name) {
+			errs.add(field, "%q is not a valid header name", name)
+			continue
+		}
+		for _, v := range h[name] {
+			if strings.ContainsAny(v, "\r\n\x00") {
+				errs.add(field, "value of %s contains illegal characters", name)
+				break
+			}
+		}
+	}
+}
+
+// isToken checks if a string is a valid RFC 7230 token
+func isToken(s string) bool {
+	if s == "" {
+		return false
+	}
+	for _, r := range s {
+		if r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' {
+			continue
+		}
+		if !strings.ContainsRune("!#$%&'*+-.^_`|~", r) {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,57 @@
+package main
+
+import "github.com/cascades-fbp/cascades/library"
+
+var registryEntry = &library.Entry{
+	Description: `Verifies HTTP Message Signatures (RFC 9421) of incoming request IPs. Signatures are checked
+against keys configured in options or published as JWKS at a URL (refetched periodically and on unknown key IDs).
+Verified requests are forwarded to OUT, the rest are answered with 401 on REJECT port. Covered Content-Digest
+is checked against the body, required components and maximal age of signatures are enforced if configured.`,
+	Elementary: true,
+	Inports: []library.EntryPort{
+		library.EntryPort{
+			Name:        "OPTIONS",
+			Type:        "json",
+			Description: `Configuration port, i.e. {"keys": [{"key_id": "webhooks-2024", "algorithm": "ed25519", "key_file": "/etc/keys/sign.pub"}], "jwks_url": "https://example.com/.well-known/jwks.json", "required": ["@method", "@target-uri", "content-digest"], "max_age": "5m"}`,
+			Required:    true,
+		},
+		library.EntryPort{
+			Name:        "IN",
+			Type:        "json",
+			Description: "Input port for requests in predefined JSON format",
+			Required:    true,
+		},
+	},
+	Outports: []library.EntryPort{
+		library.EntryPort{
+			Name:        "OUT",
+			Type:        "json",
+			Description: "Output port for requests with a valid signature",
+			Required:    true,
+		},
+		library.EntryPort{
+			Name:        "REJECT",
+			Type:        "json",
+			Description: "Output port for 401 responses to requests without a valid signature",
+			Required:    true,
+		},
+		library.EntryPort{
+			Name:        "ERR",
+			Type:        "json",
+			Description: "Optional error port for invalid IPs (error JSON object defined in utils of HTTP components library)",
+			Required:    false,
+		},
+		library.EntryPort{
+			Name:        "LOG",
+			Type:        "json",
+			Description: "Optional output port for structured log entries (log entry JSON object defined in utils of HTTP components library)",
+			Required:    false,
+		},
+		library.EntryPort{
+			Name:        "HEARTBEAT",
+			Type:        "json",
+			Description: "Optional output port for periodic liveness IPs (heartbeat JSON object defined in utils of HTTP components library)",
+			Required:    false,
+		},
+	},
+}
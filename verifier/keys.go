@@ -0,0 +1,95 @@
+package main
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"sync"
+	"time"
+
+	httputils "github.com/cascades-fbp/cascades-http/utils"
+)
+
+const (
+	// Unknown key IDs trigger JWKS refetch at most this often
+	minRefetch = 30 * time.Second
+	// Timeout of fetching JWKS
+	fetchTimeout = 5 * time.Second
+)
+
+// Keys resolves key IDs to keys configured statically or published as JWKS
+// at a URL. The key set is refetched once it's older than refresh interval
+// and when an unknown key ID is used (i.e. after key rotation).
+type Keys struct {
+	static  map[string]*httputils.VerifyingKey
+	url     string
+	refresh time.Duration
+	client  *http.Client
+
+	mu      sync.Mutex
+	jwks    map[string]*httputils.VerifyingKey
+	fetched time.Time
+}
+
+// NewKeys creates a registry of static keys and an optional JWKS URL
+func NewKeys(static map[string]*httputils.VerifyingKey, url string, refresh time.Duration) *Keys {
+	return &Keys{
+		static:  static,
+		url:     url,
+		refresh: refresh,
+		client:  &http.Client{Timeout: fetchTimeout},
+	}
+}
+
+// Lookup returns a key by ID
+func (k *Keys) Lookup(id string) (*httputils.VerifyingKey, bool) {
+	if key, ok := k.static[id]; ok {
+		return key, true
+	}
+	if k.url == "" {
+		return nil, false
+	}
+
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	key, ok := k.jwks[id]
+	age := time.Since(k.fetched)
+	if (ok && age > k.refresh) || (!ok && age > minRefetch) {
+		if err := k.fetch(); err != nil {
+			// Keep using the previous key set
+			logger.Warn("Failed to fetch JWKS", "url", k.url, "error", err)
+		}
+		key, ok = k.jwks[id]
+	}
+	return key, ok
+}
+
+// Fetch downloads the key set
+func (k *Keys) Fetch() error {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	return k.fetch()
+}
+
+func (k *Keys) fetch() error {
+	k.fetched = time.Now()
+	resp, err := k.client.Get(k.url)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("unexpected status %d", resp.StatusCode)
+	}
+	data, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+	keys, err := httputils.ParseJWKS(data)
+	if err != nil {
+		return err
+	}
+	k.jwks = keys
+	logger.Debug("Fetched JWKS", "url", k.url, "keys", len(keys))
+	return nil
+}
@@ -0,0 +1,282 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"os"
+	"time"
+
+	httputils "github.com/cascades-fbp/cascades-http/utils"
+	"github.com/cascades-fbp/cascades/components/utils"
+	"github.com/cascades-fbp/cascades/runtime"
+	zmq "github.com/pebbe/zmq4"
+)
+
+var (
+	// Flags
+	optionsEndpoint   = flag.String("port.options", "", "Component's options port endpoint")
+	inputEndpoint     = flag.String("port.in", "", "Component's input port endpoint")
+	outputEndpoint    = flag.String("port.out", "", "Component's output port endpoint")
+	rejectEndpoint    = flag.String("port.reject", "", "Component's reject port endpoint")
+	errorEndpoint     = flag.String("port.err", "", "Component's error port endpoint")
+	logEndpoint       = flag.String("port.log", "", "Component's log port endpoint")
+	heartbeatEndpoint = flag.String("port.heartbeat", "", "Component's heartbeat port endpoint")
+	metricsAddr       = flag.String("metrics", "", "Address to expose metrics on, i.e. 127.0.0.1:9090 (disabled if empty)")
+	jsonFlag          = flag.Bool("json", false, "Print component documentation in JSON")
+	debug             = flag.Bool("debug", false, "Enable debug mode")
+
+	// Internal
+	optionsPort, inPort, outPort, rejectPort, errPort, logPort, heartbeatPort *zmq.Socket
+	err                                                                       error
+	logger                                                                    = httputils.NewLogger("http/verifier")
+	liveness                                                                  = httputils.NewLiveness("http/verifier")
+	metrics                                                                   = httputils.NewMetrics(logger, liveness)
+	shutdown                                                                  *httputils.Shutdown
+)
+
+// Options describe the configuration IP of the component
+type Options struct {
+	Keys        []KeyOptions       `json:"keys"`         // Static keys
+	JWKSURL     string             `json:"jwks_url"`     // URL of a JSON Web Key Set
+	JWKSRefresh httputils.Duration `json:"jwks_refresh"` // Maximal age of fetched key set
+	Label       string             `json:"label"`        // Only verify signature of this label
+	Required    []string           `json:"required"`     // Components every signature must cover
+	MaxAge      httputils.Duration `json:"max_age"`      // Maximal age of signatures by created parameter
+	Tag         string             `json:"tag"`          // Required tag parameter
+	Digest      bool               `json:"digest"`       // Check covered Content-Digest (enabled by default)
+}
+
+// KeyOptions describe a statically configured key
+type KeyOptions struct {
+	KeyID     string `json:"key_id"`    // Matched against keyid parameter
+	Algorithm string `json:"algorithm"` // One of RFC 9421 algorithms, i.e. ed25519
+	Key       string `json:"key"`       // HMAC secret or PEM encoded public key or certificate
+	KeyFile   string `json:"key_file"`  // File with the key, used if key is empty
+}
+
+// validateArgs checks all required flags
+func validateArgs() {
+	if *optionsEndpoint == "" {
+		flag.Usage()
+		os.Exit(1)
+	}
+	if *inputEndpoint == "" {
+		flag.Usage()
+		os.Exit(1)
+	}
+	if *outputEndpoint == "" {
+		flag.Usage()
+		os.Exit(1)
+	}
+	if *rejectEndpoint == "" {
+		flag.Usage()
+		os.Exit(1)
+	}
+}
+
+// openPorts create ZMQ sockets
+func openPorts() {
+	optionsPort, err = utils.CreateInputPort("http/verifier.options", *optionsEndpoint, nil)
+	utils.AssertError(err)
+
+	inPort, err = utils.CreateInputPort("http/verifier.in", *inputEndpoint, nil)
+	utils.AssertError(err)
+
+	outPort, err = utils.CreateOutputPort("http/verifier.out", *outputEndpoint, nil)
+	utils.AssertError(err)
+
+	rejectPort, err = utils.CreateOutputPort("http/verifier.reject", *rejectEndpoint, nil)
+	utils.AssertError(err)
+
+	if *errorEndpoint != "" {
+		errPort, err = utils.CreateOutputPort("http/verifier.err", *errorEndpoint, nil)
+		utils.AssertError(err)
+	}
+
+	if *logEndpoint != "" {
+		logPort, err = utils.CreateOutputPort("http/verifier.log", *logEndpoint, nil)
+		utils.AssertError(err)
+		logger.SetSink(func(ip [][]byte) { logPort.SendMessageDontwait(ip) })
+	}
+
+	if *heartbeatEndpoint != "" {
+		heartbeatPort, err = utils.CreateOutputPort("http/verifier.heartbeat", *heartbeatEndpoint, nil)
+		utils.AssertError(err)
+		liveness.Start(func(ip [][]byte) { heartbeatPort.SendMessageDontwait(ip) })
+	}
+}
+
+// closePorts closes all active ports and terminates ZMQ context
+func closePorts() {
+	shutdown.Close(optionsPort, inPort)
+	liveness.Stop()
+	shutdown.Flush(outPort, rejectPort, errPort, heartbeatPort, logPort)
+	zmq.Term()
+}
+
+// newVerifier creates a verifier configured by the options
+func newVerifier(options *Options) (*httputils.SignatureVerifier, error) {
+	static := make(map[string]*httputils.VerifyingKey, len(options.Keys))
+	for _, k := range options.Keys {
+		data := []byte(k.Key)
+		if len(data) == 0 && k.KeyFile != "" {
+			var err error
+			if data, err = ioutil.ReadFile(k.KeyFile); err != nil {
+				return nil, err
+			}
+		}
+		key, err := httputils.ParseVerifyingKey(k.Algorithm, data)
+		if err != nil {
+			return nil, fmt.Errorf("key %s: %v", k.KeyID, err)
+		}
+		static[k.KeyID] = key
+	}
+	if len(static) == 0 && options.JWKSURL == "" {
+		return nil, fmt.Errorf("either keys or jwks_url is required")
+	}
+
+	refresh := time.Duration(options.JWKSRefresh)
+	if refresh <= 0 {
+		refresh = 10 * time.Minute
+	}
+	keys := NewKeys(static, options.JWKSURL, refresh)
+	if options.JWKSURL != "" {
+		if err := keys.Fetch(); err != nil {
+			logger.Warn("Failed to fetch JWKS", "url", options.JWKSURL, "error", err)
+		}
+	}
+	return &httputils.SignatureVerifier{
+		Keys:     keys.Lookup,
+		Label:    options.Label,
+		Required: options.Required,
+		MaxAge:   time.Duration(options.MaxAge),
+		Tag:      options.Tag,
+		Digest:   options.Digest,
+	}, nil
+}
+
+func main() {
+	flag.Parse()
+
+	if *jsonFlag {
+		doc, _ := registryEntry.JSON()
+		fmt.Println(string(doc))
+		os.Exit(0)
+	}
+
+	log.SetFlags(0)
+	if *debug {
+		logger.SetLevel(httputils.LevelDebug)
+		logger.SetOutput(os.Stdout)
+	}
+	log.SetOutput(logger.Writer())
+
+	validateArgs()
+
+	shutdown = httputils.NewShutdown(logger)
+	openPorts()
+
+	if *metricsAddr != "" {
+		err = metrics.Serve(*metricsAddr)
+		utils.AssertError(err)
+	}
+
+	err = runtime.SetupShutdownByDisconnect(inPort, "http/verifier.in", shutdown.Signals())
+	utils.AssertError(err)
+
+	// Wait for the configuration on the options port
+	var (
+		verifier *httputils.SignatureVerifier
+		label    = "sig1"
+	)
+	for verifier == nil {
+		log.Println("Waiting for configuration...")
+		ip, err := shutdown.Receive(optionsPort)
+		if err == httputils.ErrShutdown {
+			shutdown.Exit(closePorts)
+		}
+		if err != nil {
+			logger.Error("Error receiving IP", "error", err)
+			continue
+		}
+		if !runtime.IsValidIP(ip) || !runtime.IsPacket(ip) {
+			continue
+		}
+		options := &Options{Digest: true}
+		if err = json.Unmarshal(ip[1], options); err != nil {
+			logger.Error("Failed to unmarshal options", "error", err)
+			continue
+		}
+		if verifier, err = newVerifier(options); err != nil {
+			logger.Error("Invalid verification configuration", "error", err)
+			continue
+		}
+		if options.Label != "" {
+			label = options.Label
+		}
+	}
+	optionsPort.Close()
+	optionsPort = nil
+
+	// Tell clients which components to sign
+	var acceptSignature string
+	if len(verifier.Required) > 0 {
+		acceptSignature = label + "=" + (&httputils.SignatureParams{Components: verifier.Required}).String()
+	}
+
+	// Process incoming messages until shutdown
+	for {
+		ip, err := shutdown.Receive(inPort)
+		if err == httputils.ErrShutdown {
+			break
+		}
+		if err != nil {
+			logger.Error("Error receiving message", "error", err)
+			continue
+		}
+		liveness.Inc()
+		if !httputils.IsValidIP(ip) || !runtime.IsPacket(ip) {
+			logger.Warn("Received invalid IP")
+			continue
+		}
+
+		req, err := httputils.IP2Request(ip)
+		if err != nil {
+			logger.Warn("Failed to convert IP to request", "error", err)
+			sendError(httputils.NewError("http/verifier", httputils.ErrInvalidIP, err))
+			continue
+		}
+		params, err := verifier.VerifyRequest(req)
+		if err != nil {
+			logger.Info("Rejected request", "id", req.ID, "reason", err)
+			resp := httputils.NewResponse(http.StatusUnauthorized).
+				WithID(req.ID).
+				WithTrace(req.Trace).
+				WithText("Invalid or missing signature")
+			if acceptSignature != "" {
+				resp.SetHeader("Accept-Signature", acceptSignature)
+			}
+			rejectPort.SendMessage(resp.MustIP())
+			continue
+		}
+		logger.Debug("Verified request", "id", req.ID, "keyid", params.KeyID)
+		outPort.SendMessage(ip)
+	}
+	shutdown.Exit(closePorts)
+}
+
+// sendError reports a failure to the ERR port if it's connected
+func sendError(e *httputils.Error) {
+	if errPort == nil {
+		return
+	}
+	ip, err := httputils.Error2IP(e)
+	if err != nil {
+		return
+	}
+	errPort.SendMessageDontwait(ip)
+}